@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"time"
+)
+
+func main() {
+	simpleConsumer := process.NewSimpleConsumer("mySimpleConsumerGroup")
+	simpleConsumer.SetNamesrvAddr("127.0.0.1:9876")
+	simpleConsumer.SetAwaitDuration(10 * time.Second)
+	simpleConsumer.Subscribe("TestTopic", "tagA || tagB")
+	simpleConsumer.Start()
+
+	for i := 0; i < 100; i++ {
+		msgs, err := simpleConsumer.Receive(16, 30*time.Second)
+		if err != nil {
+			fmt.Println(err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, msgExt := range msgs {
+			fmt.Println(string(msgExt.Body))
+			if err := simpleConsumer.Ack(msgExt); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+
+	simpleConsumer.Shutdown()
+}
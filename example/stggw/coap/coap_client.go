@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stggw/coap"
+)
+
+// 联调CoAP网关：依次启动namesrv、broker、网关(conf/gateway.toml、conf/coap_gateway.toml)后运行，
+// 观察devices/+/telemetry，设备上报的遥测经smartgo topic往返后以通知推送
+func main() {
+	gatewayAddr := "127.0.0.1:5683"
+
+	monitor, err := coap.DialClient(gatewayAddr, 10*time.Second)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer monitor.Close()
+
+	observation, err := monitor.Observe("devices/+/telemetry")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer observation.Cancel()
+
+	device, err := coap.DialClient(gatewayAddr, 10*time.Second)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer device.Close()
+
+	for i := 0; i < 10; i++ {
+		payload := fmt.Sprintf("{\"temperature\":%d}", 20+i)
+		response, err := device.Post("devices/42/telemetry", []byte(payload))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("post %s, response %s\n", payload, coap.CodeString(response.Code))
+	}
+
+	timeout := time.After(30 * time.Second)
+	for received := 0; received < 10; received++ {
+		select {
+		case notification := <-observation.Notifications():
+			fmt.Printf("notification payload=%s\n", notification.Payload)
+		case <-timeout:
+			fmt.Println("wait notifications timeout")
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stggw/mqtt"
+)
+
+// 联调MQTT网关：依次启动namesrv、broker、网关(conf/gateway.toml)后运行，
+// 设备上报的遥测经smartgo topic往返后推送给订阅者
+func main() {
+	gatewayAddr := "127.0.0.1:1883"
+
+	subscriber, err := mqtt.DialClient(gatewayAddr, &mqtt.ConnectPacket{CleanSession: true, ClientId: "monitor", KeepAlive: 60}, 5*time.Second)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer subscriber.Disconnect()
+
+	qos, err := subscriber.Subscribe("devices/+/telemetry", 1)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("subscribe devices/+/telemetry granted qos %d\n", qos)
+
+	device, err := mqtt.DialClient(gatewayAddr, &mqtt.ConnectPacket{CleanSession: true, ClientId: "device-42", KeepAlive: 60}, 5*time.Second)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer device.Disconnect()
+
+	for i := 0; i < 10; i++ {
+		payload := fmt.Sprintf("{\"temperature\":%d}", 20+i)
+		if err = device.Publish("devices/42/telemetry", 1, []byte(payload)); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("publish %s\n", payload)
+	}
+
+	timeout := time.After(30 * time.Second)
+	for received := 0; received < 10; received++ {
+		select {
+		case msg := <-subscriber.Messages():
+			fmt.Printf("receive topic=%s qos=%d payload=%s\n", msg.TopicName, msg.Qos, msg.Payload)
+		case <-timeout:
+			fmt.Println("wait messages timeout")
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/acl"
+)
+
+// 联调HTTP/REST网关：依次启动namesrv、broker、网关(conf/gateway.toml、conf/rest_gateway.toml)后运行，
+// 先拉取一次使GID_REST_EXAMPLE从最新位置开始消费，再发送批量消息、长轮询拉取并确认；
+// 网关开启ACL时设置accessKey、secretKey
+func main() {
+	gatewayURL := "http://127.0.0.1:8080"
+	accessKey, secretKey := "", ""
+	pollPath := "/groups/GID_REST_EXAMPLE/topics/TestTopic/messages?wait=10&max=16"
+
+	if _, err := do(gatewayURL, accessKey, secretKey, "GET", "/groups/GID_REST_EXAMPLE/topics/TestTopic/messages?wait=0", nil); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	batch := `[{"body": "hello smartgo", "tags": "TagA", "keys": ["order-1"]}, {"body": "delay 10s", "delay": 10000}]`
+	result, err := do(gatewayURL, accessKey, secretKey, "POST", "/topics/TestTopic/messages", []byte(batch))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("send result %s\n", result)
+
+	for received := 0; received < 2; {
+		data, err := do(gatewayURL, accessKey, secretKey, "GET", pollPath, nil)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		var poll struct {
+			Messages []struct {
+				Receipt string `json:"receipt"`
+				MsgId   string `json:"msgId"`
+				Body    string `json:"body"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &poll); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		receipts := make([]string, 0, len(poll.Messages))
+		for _, msg := range poll.Messages {
+			fmt.Printf("receive msgId=%s body=%s\n", msg.MsgId, msg.Body)
+			receipts = append(receipts, msg.Receipt)
+		}
+		if len(receipts) == 0 {
+			continue
+		}
+		ack, _ := json.Marshal(map[string][]string{"receipts": receipts})
+		if result, err = do(gatewayURL, accessKey, secretKey, "POST", "/groups/GID_REST_EXAMPLE/topics/TestTopic/ack", ack); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("ack result %s\n", result)
+		received += len(receipts)
+	}
+}
+
+func do(gatewayURL, accessKey, secretKey, method, path string, body []byte) ([]byte, error) {
+	request, err := http.NewRequest(method, gatewayURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if accessKey != "" {
+		acl.SignHttpRequest(request, body, accessKey, secretKey)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s failed, status=%d, %s", method, path, response.StatusCode, data)
+	}
+	return data, nil
+}
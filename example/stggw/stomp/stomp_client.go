@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stggw/stomp"
+)
+
+// 联调STOMP网关：依次启动namesrv、broker、网关(conf/gateway.toml、conf/stomp_gateway.toml)后运行，
+// 以client-individual模式订阅/queue/TestTopic，发送的消息经smartgo topic往返后逐条确认
+func main() {
+	client, err := stomp.DialClient("127.0.0.1:61613", 10*time.Second, stomp.HDR_LOGIN, "example")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer client.Disconnect()
+
+	sub, err := client.Subscribe("/queue/TestTopic", stomp.ACK_CLIENT_INDIVIDUAL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		body := fmt.Sprintf("hello stomp %d", i)
+		err := client.Send("/queue/TestTopic", []byte(body), stomp.HDR_TAGS, "TagA", stomp.HDR_CONTENT_TYPE, "text/plain")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("send %s\n", body)
+	}
+
+	timeout := time.After(30 * time.Second)
+	for received := 0; received < 10; received++ {
+		select {
+		case frame, ok := <-sub.Messages():
+			if !ok {
+				fmt.Println(client.Err())
+				return
+			}
+			fmt.Printf("message-id=%s, tags=%s, body=%s\n", frame.HeaderValue(stomp.HDR_MESSAGE_ID), frame.HeaderValue(stomp.HDR_TAGS), frame.Body)
+			if err := client.Ack(frame); err != nil {
+				fmt.Println(err)
+				return
+			}
+		case <-timeout:
+			fmt.Println("wait messages timeout")
+			return
+		}
+	}
+}
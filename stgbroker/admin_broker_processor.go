@@ -12,6 +12,8 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header/filtersrv"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/stats"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/remotingUtil"
 	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
@@ -109,6 +111,20 @@ func (self *AdminBrokerProcessor) ProcessRequest(ctx netm.Context, request *prot
 		return self.cloneGroupOffset(ctx, request)
 	case code.VIEW_BROKER_STATS_DATA:
 		return self.ViewBrokerStatsData(ctx, request) // 查看Broker统计信息
+	case code.QUERY_DLQ_MESSAGE:
+		return self.queryDLQMessage(ctx, request) // 分页查询死信消息
+	case code.RESEND_DLQ_MESSAGE:
+		return self.resendDLQMessage(ctx, request) // 重新投递死信消息
+	case code.PURGE_DLQ_MESSAGE:
+		return self.purgeDLQMessage(ctx, request) // 清除死信消息
+	case code.UPDATE_QUOTA_CONFIG:
+		return self.updateQuotaConfig(ctx, request) // 创建或更新收发配额
+	case code.GET_ALL_QUOTA_CONFIG:
+		return self.getAllQuotaConfig(ctx, request) // 获取所有收发配额
+	case code.DELETE_QUOTA_CONFIG:
+		return self.deleteQuotaConfig(ctx, request) // 删除收发配额
+	case code.DRAIN_BROKER:
+		return self.drainBroker(ctx, request) // 优雅下线broker
 	default:
 
 	}
@@ -222,7 +238,14 @@ func (adp *AdminBrokerProcessor) updateBrokerConfig(ctx netm.Context, request *p
 	logger.Infof("BrokerConfig:%s", string(content))
 	if content != nil {
 		logger.Infof("updateBrokerConfig, new config: %s, client: %s", string(content), ctx.RemoteAddr().String())
-		adp.BrokerController.UpdateAllConfig(content)
+		result, err := adp.BrokerController.UpdateAllConfig(content)
+		if err != nil {
+			logger.Errorf("updateBrokerConfig err: %s", err.Error())
+			response.Code = code.SYSTEM_ERROR
+			response.Remark = err.Error()
+			return response, nil
+		}
+		response.Body = result.CustomEncode(result)
 	} else {
 		logger.Error("string2Properties error")
 		response.Code = code.SYSTEM_ERROR
@@ -413,6 +436,14 @@ func (abp *AdminBrokerProcessor) updateAndCreateSubscriptionGroup(ctx netm.Conte
 		logger.Error(err)
 	}
 
+	if config.RetryPolicy != nil {
+		if err := config.RetryPolicy.Validate(); err != nil {
+			response.Code = code.SYSTEM_ERROR
+			response.Remark = err.Error()
+			return response, nil
+		}
+	}
+
 	if config != nil {
 		abp.BrokerController.SubscriptionGroupManager.UpdateSubscriptionGroupConfig(config)
 	}
@@ -462,6 +493,91 @@ func (abp *AdminBrokerProcessor) deleteSubscriptionGroup(ctx netm.Context, reque
 	return response, nil
 }
 
+// updateQuotaConfig 创建或更新收发配额
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) updateQuotaConfig(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+	logger.Infof("updateQuotaConfig called by %s", remotingUtil.ParseChannelRemoteAddr(ctx))
+
+	config := &quota.QuotaConfig{}
+	if err := stgcommon.Decode(request.Body, config); err != nil {
+		logger.Error(err)
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+	if err := config.Validate(); err != nil {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	abp.BrokerController.QuotaManager.UpdateQuotaConfig(config)
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// getAllQuotaConfig 获得所有收发配额
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) getAllQuotaConfig(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+	content := abp.BrokerController.QuotaManager.Encode(false)
+	if content == "" {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = "encode quota config failed"
+		return response, nil
+	}
+
+	response.Body = []byte(content)
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// deleteQuotaConfig 删除收发配额
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) deleteQuotaConfig(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+
+	requestHeader := &header.DeleteQuotaConfigRequestHeader{}
+	if err := request.DecodeCommandCustomHeader(requestHeader); err != nil {
+		logger.Error(err)
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	logger.Infof("deleteQuotaConfig called by %s", remotingUtil.ParseChannelRemoteAddr(ctx))
+	abp.BrokerController.QuotaManager.DeleteQuotaConfig(requestHeader.ResourceType, requestHeader.ResourceName)
+
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// drainBroker 异步开始优雅下线broker，下线完成后broker进程退出
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) drainBroker(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+
+	remoteAddr := remotingUtil.ParseChannelRemoteAddr(ctx)
+	logger.Infof("drainBroker called by %s", remoteAddr)
+	if err := abp.BrokerController.DrainService.StartDrain(fmt.Sprintf("admin:%s", remoteAddr)); err != nil {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	response.Code = code.SUCCESS
+	response.Remark = "broker drain started"
+	return response, nil
+}
+
 // getTopicStatsInfo 获取Topic的存储统计信息(minOffset、maxOffset、lastUpdateTime)
 // Author rongzhihong
 // Since 2017/9/19
@@ -1090,7 +1206,20 @@ func (abp *AdminBrokerProcessor) ViewBrokerStatsData(ctx netm.Context, request *
 		logger.Error(err)
 	}
 
-	statsItem := abp.BrokerController.MessageStore.BrokerStatsManager.GetStatsItem(requestHeader.StatsName, requestHeader.StatsKey)
+	brokerStatsManager := abp.BrokerController.MessageStore.BrokerStatsManager
+	if histogramItem := brokerStatsManager.GetHistogramItem(requestHeader.StatsName, requestHeader.StatsKey); histogramItem != nil {
+		brokerStatsData := body.NewBrokerStatsData()
+		brokerStatsData.StatsMinute = newBrokerLatencyStatsItem(histogramItem.GetSnapshotInMinute())
+		brokerStatsData.StatsHour = newBrokerLatencyStatsItem(histogramItem.GetSnapshotInHour())
+		brokerStatsData.StatsDay = newBrokerLatencyStatsItem(histogramItem.GetSnapshotInDay())
+
+		response.Body = stgcommon.Encode(brokerStatsData)
+		response.Code = code.SUCCESS
+		response.Remark = ""
+		return response, nil
+	}
+
+	statsItem := brokerStatsManager.GetStatsItem(requestHeader.StatsName, requestHeader.StatsKey)
 	if nil == statsItem {
 		response.Code = code.SYSTEM_ERROR
 		response.Remark = fmt.Sprintf("The stats <%s> <%s> not exist", requestHeader.StatsName, requestHeader.StatsKey)
@@ -1135,3 +1264,97 @@ func (abp *AdminBrokerProcessor) ViewBrokerStatsData(ctx netm.Context, request *
 	response.Remark = ""
 	return response, nil
 }
+
+// queryDLQMessage 分页查询死信消息
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) queryDLQMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+
+	requestHeader := &header.QueryDLQMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	messageList := abp.BrokerController.DLQMessageManager.QueryMessages(requestHeader.ConsumerGroup,
+		requestHeader.QueueId, requestHeader.Offset, requestHeader.MaxNums)
+
+	response.Body = stgcommon.Encode(messageList)
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// resendDLQMessage 将死信消息重新投递到原始Topic
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) resendDLQMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+
+	requestHeader := &header.ResendDLQMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	if !abp.BrokerController.BrokerConfig.HasWriteable() {
+		response.Code = code.NO_PERMISSION
+		response.Remark = "the broker[" + abp.BrokerController.BrokerConfig.BrokerIP1 + "] sending message is forbidden"
+		return response, nil
+	}
+
+	result := abp.BrokerController.DLQMessageManager.ResendMessages(requestHeader.ConsumerGroup, splitDLQMsgIds(requestHeader.MsgIds))
+	logger.Infof("resend dlq message, group=%s, total=%d, succeed=%d, client=%s",
+		requestHeader.ConsumerGroup, result.Total, result.Succeed, ctx.RemoteAddr().String())
+
+	response.Body = stgcommon.Encode(result)
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// purgeDLQMessage 清除指定offset或时间之前的死信消息
+// Author agent
+// Since 2026/10/19
+func (abp *AdminBrokerProcessor) purgeDLQMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+
+	requestHeader := &header.PurgeDLQMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	result := abp.BrokerController.DLQMessageManager.Purge(requestHeader.ConsumerGroup, requestHeader.Offset, requestHeader.Timestamp)
+	logger.Infof("purge dlq message, group=%s, offset=%d, timestamp=%d, purged=%d, client=%s",
+		requestHeader.ConsumerGroup, requestHeader.Offset, requestHeader.Timestamp, result.Purged, ctx.RemoteAddr().String())
+
+	response.Body = stgcommon.Encode(result)
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// newBrokerLatencyStatsItem 将延迟分布快照转换为BrokerStatsItem
+// Author agent
+// Since 2026/10/19
+func newBrokerLatencyStatsItem(snapshot *stats.HistogramSnapshot) *body.BrokerStatsItem {
+	return &body.BrokerStatsItem{
+		Sum:   snapshot.Count,
+		Tps:   snapshot.Tps,
+		Avgpt: snapshot.Avg,
+		Tp50:  snapshot.P50,
+		Tp75:  snapshot.P75,
+		Tp90:  snapshot.P90,
+		Tp99:  snapshot.P99,
+		Tp999: snapshot.P999,
+		Max:   snapshot.Max,
+	}
+}
@@ -0,0 +1,447 @@
+package stgbroker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// hotBrokerConfigKeys BrokerConfig中可以热更新的配置项，运行中的服务每次使用时都会重新读取
+var hotBrokerConfigKeys = map[string]bool{
+	"brokerPermission":               true,
+	"autoCreateTopicEnable":          true,
+	"autoCreateSubscriptionGroup":    true,
+	"defaultTopicQueueNums":          true,
+	"rejectTransactionMessage":       true,
+	"longPollingEnable":              true,
+	"shortPollingTimeMills":          true,
+	"notifyConsumerIdsChangedEnable": true,
+	"offsetCheckInSlave":             true,
+	"drainOnShutdown":                true,
+	"drainProducerTimeoutMills":      true,
+	"drainProducerQuietMills":        true,
+	"drainPullTimeoutMills":          true,
+	"drainFlushTimeoutMills":         true,
+}
+
+// hotMessageStoreConfigKeys MessageStoreConfig中可以热更新的配置项：刷盘、拉取传输上限、文件清理相关
+var hotMessageStoreConfigKeys = map[string]bool{
+	"FlushIntervalCommitLog":            true,
+	"FlushCommitLogTimed":               true,
+	"FlushCommitLogLeastPages":          true,
+	"FlushCommitLogThoroughInterval":    true,
+	"FlushIntervalConsumeQueue":         true,
+	"FlushConsumeQueueLeastPages":       true,
+	"FlushConsumeQueueThoroughInterval": true,
+	"MaxTransferBytesOnMessageInMemory": true,
+	"MaxTransferCountOnMessageInMemory": true,
+	"MaxTransferBytesOnMessageInDisk":   true,
+	"MaxTransferCountOnMessageInDisk":   true,
+	"AccessMessageInMemoryMaxRatio":     true,
+	"DiskMaxUsedSpaceRatio":             true,
+	"FileReservedTime":                  true,
+	"DeleteWhen":                        true,
+	"CleanFileForciblyEnable":           true,
+	"DeleteCommitLogFilesInterval":      true,
+	"DeleteConsumeQueueFilesInterval":   true,
+	"DestroyMapedFileIntervalForcibly":  true,
+	"RedeleteHangedFileInterval":        true,
+	"SyncFlushTimeout":                  true,
+}
+
+// IsHotBrokerConfig 配置项是否可以热更新，scope取值brokerConfig、messageStoreConfig
+// Author agent
+// Since 2026/10/19
+func IsHotBrokerConfig(scope, key string) bool {
+	if scope == body.MESSAGE_STORE_CONFIG_SCOPE {
+		return hotMessageStoreConfigKeys[key]
+	}
+	return hotBrokerConfigKeys[key]
+}
+
+// brokerConfigUpdate 一次配置修改请求，按scope拆分为 key -> json值
+type brokerConfigUpdate map[string]map[string]json.RawMessage
+
+func newBrokerConfigUpdate() brokerConfigUpdate {
+	return brokerConfigUpdate{
+		body.BROKER_CONFIG_SCOPE:        make(map[string]json.RawMessage),
+		body.MESSAGE_STORE_CONFIG_SCOPE: make(map[string]json.RawMessage),
+	}
+}
+
+// parseBrokerConfigUpdate 解析修改请求，支持两种格式：
+// (1) {"brokerConfig":{...}, "messageStoreConfig":{...}}，与GET_BROKER_CONFIG返回的格式一致
+// (2) {"longPollingEnable":false, "FlushIntervalCommitLog":500}，按名称依次在BrokerConfig、MessageStoreConfig中查找
+func parseBrokerConfigUpdate(content []byte, brokerKeys, storeKeys map[string]interface{}) (brokerConfigUpdate, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("broker config content invalid: %s", err.Error())
+	}
+
+	update := newBrokerConfigUpdate()
+	for key, value := range raw {
+		if key == body.BROKER_CONFIG_SCOPE || key == body.MESSAGE_STORE_CONFIG_SCOPE {
+			var scoped map[string]json.RawMessage
+			if err := json.Unmarshal(value, &scoped); err != nil {
+				return nil, fmt.Errorf("%s invalid: %s", key, err.Error())
+			}
+			keys := brokerKeys
+			if key == body.MESSAGE_STORE_CONFIG_SCOPE {
+				keys = storeKeys
+			}
+			for k, v := range scoped {
+				name, ok := resolveConfigKey(k, keys)
+				if !ok {
+					return nil, fmt.Errorf("unknown %s key: %s", key, k)
+				}
+				update[key][name] = v
+			}
+			continue
+		}
+
+		if name, ok := resolveConfigKey(key, brokerKeys); ok {
+			update[body.BROKER_CONFIG_SCOPE][name] = value
+		} else if name, ok := resolveConfigKey(key, storeKeys); ok {
+			update[body.MESSAGE_STORE_CONFIG_SCOPE][name] = value
+		} else {
+			return nil, fmt.Errorf("unknown broker config key: %s", key)
+		}
+	}
+	return update, nil
+}
+
+// resolveConfigKey 优先精确匹配配置项名称，其次忽略大小写匹配
+func resolveConfigKey(key string, keys map[string]interface{}) (string, bool) {
+	if _, ok := keys[key]; ok {
+		return key, true
+	}
+	for name := range keys {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// configValues 将配置结构体转换为 json名称 -> 值
+func configValues(config interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return values
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	decoder.Decode(&values)
+	return values
+}
+
+// applyConfigValues 将 json名称 -> json值 写入配置结构体，未出现的配置项保持不变；
+// 动态修改配置、启动时覆盖toml文件[brokerConfig]、[messageStoreConfig]段中的配置项均使用此方法
+func applyConfigValues(config interface{}, values interface{}) error {
+	buf, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, config)
+}
+
+// diffConfigValues 对比修改前后的配置项，只返回值发生变化的项
+func diffConfigValues(scope string, oldValues, newValues map[string]interface{}, keys map[string]json.RawMessage) []*body.BrokerConfigChange {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	changes := make([]*body.BrokerConfigChange, 0)
+	for _, key := range names {
+		oldValue := fmt.Sprint(oldValues[key])
+		newValue := fmt.Sprint(newValues[key])
+		if oldValue == newValue {
+			continue
+		}
+		changes = append(changes, &body.BrokerConfigChange{
+			Scope:     scope,
+			Key:       key,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+			HotReload: IsHotBrokerConfig(scope, key),
+		})
+	}
+	return changes
+}
+
+// validateHotConfig 校验热更新配置项的取值，避免把运行中的服务改坏
+func validateHotConfig(brokerConfig *stgcommon.BrokerConfig, storeConfig *stgstorelog.MessageStoreConfig) error {
+	positives := map[string]int64{
+		"defaultTopicQueueNums":             int64(brokerConfig.DefaultTopicQueueNums),
+		"shortPollingTimeMills":             int64(brokerConfig.ShortPollingTimeMills),
+		"drainProducerTimeoutMills":         brokerConfig.DrainProducerTimeoutMills,
+		"drainProducerQuietMills":           brokerConfig.DrainProducerQuietMills,
+		"drainPullTimeoutMills":             brokerConfig.DrainPullTimeoutMills,
+		"drainFlushTimeoutMills":            brokerConfig.DrainFlushTimeoutMills,
+		"FlushIntervalCommitLog":            int64(storeConfig.FlushIntervalCommitLog),
+		"FlushIntervalConsumeQueue":         int64(storeConfig.FlushIntervalConsumeQueue),
+		"MaxTransferBytesOnMessageInMemory": int64(storeConfig.MaxTransferBytesOnMessageInMemory),
+		"MaxTransferCountOnMessageInMemory": int64(storeConfig.MaxTransferCountOnMessageInMemory),
+		"MaxTransferBytesOnMessageInDisk":   int64(storeConfig.MaxTransferBytesOnMessageInDisk),
+		"MaxTransferCountOnMessageInDisk":   int64(storeConfig.MaxTransferCountOnMessageInDisk),
+		"FileReservedTime":                  storeConfig.FileReservedTime,
+		"SyncFlushTimeout":                  int64(storeConfig.SyncFlushTimeout),
+	}
+	for key, value := range positives {
+		if value <= 0 {
+			return fmt.Errorf("%s[%d] must be > 0", key, value)
+		}
+	}
+	if storeConfig.AccessMessageInMemoryMaxRatio <= 0 || storeConfig.AccessMessageInMemoryMaxRatio > 100 {
+		return fmt.Errorf("AccessMessageInMemoryMaxRatio[%d] must be in (0, 100]", storeConfig.AccessMessageInMemoryMaxRatio)
+	}
+	if storeConfig.DiskMaxUsedSpaceRatio <= 0 || storeConfig.DiskMaxUsedSpaceRatio > 100 {
+		return fmt.Errorf("DiskMaxUsedSpaceRatio[%d] must be in (0, 100]", storeConfig.DiskMaxUsedSpaceRatio)
+	}
+	if brokerConfig.BrokerPermission < 0 || brokerConfig.BrokerPermission > 7 {
+		return fmt.Errorf("brokerPermission[%d] invalid", brokerConfig.BrokerPermission)
+	}
+	return nil
+}
+
+// persistBrokerConfigFile 将变更写回broker的toml文件，写之前备份为*.bak。
+// 只改写发生变化的配置项所在的行，注释、空行以及其余配置项的顺序保持不变：
+// 与启动toml同名的配置项直接修改对应的顶层配置项，其余写入[brokerConfig]、[messageStoreConfig]段，启动时覆盖默认值
+// Author agent
+// Since 2026/10/19
+func persistBrokerConfigFile(path string, changes []*body.BrokerConfigChange, newValues map[string]map[string]interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fileValues := make(map[string]interface{})
+	if _, err := toml.Decode(string(content), &fileValues); err != nil {
+		return err
+	}
+
+	doc := parseTomlDocument(string(content))
+	for _, change := range changes {
+		value, err := encodeTomlValue(tomlValue(newValues[change.Scope][change.Key]))
+		if err != nil {
+			return err
+		}
+		if name, ok := topLevelTomlKey(fileValues, change.Scope, change.Key); ok {
+			doc.set("", name, value)
+		} else {
+			doc.set(change.Scope, change.Key, value)
+		}
+	}
+
+	if err := ioutil.WriteFile(path+".bak", content, 0644); err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, []byte(doc.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+// tomlDocument 按行修改的toml文件，只处理单行的顶层配置项及[table]段中的配置项，其余行原样保留
+type tomlDocument struct {
+	lines   []string
+	newline string
+}
+
+func parseTomlDocument(content string) *tomlDocument {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	return &tomlDocument{lines: strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n"), newline: newline}
+}
+
+func (doc *tomlDocument) String() string {
+	return strings.Join(doc.lines, doc.newline)
+}
+
+// set 修改table段中的配置项，table为空表示顶层配置项；已有的配置项只替换值，保留缩进与行尾注释，
+// 没有的配置项追加到该段最后一个配置项之后，段不存在时追加到文件末尾
+func (doc *tomlDocument) set(table, key, value string) {
+	current := ""
+	lastKey, header, firstHeader := -1, -1, -1
+	for i, line := range doc.lines {
+		if name, ok := tomlTableHeader(line); ok {
+			current = name
+			if firstHeader < 0 {
+				firstHeader = i
+			}
+			if name == table {
+				header = i
+			}
+			continue
+		}
+		name, ok := tomlLineKey(line)
+		if !ok || current != table {
+			continue
+		}
+		if name == key {
+			doc.lines[i] = replaceTomlLineValue(line, value)
+			return
+		}
+		lastKey = i
+	}
+
+	line := key + " = " + value
+	switch {
+	case lastKey >= 0:
+		doc.insert(lastKey+1, line)
+	case table != "" && header >= 0:
+		doc.insert(header+1, line)
+	case table == "" && firstHeader >= 0:
+		doc.insert(firstHeader, line, "")
+	default:
+		end := len(doc.lines)
+		if end > 0 && doc.lines[end-1] == "" {
+			end--
+		}
+		lines := []string{line}
+		if table != "" {
+			lines = []string{"", "# 动态修改broker配置时写入，启动时覆盖默认配置", "[" + table + "]", line}
+		}
+		doc.insert(end, lines...)
+	}
+}
+
+func (doc *tomlDocument) insert(index int, lines ...string) {
+	doc.lines = append(doc.lines[:index], append(lines, doc.lines[index:]...)...)
+}
+
+// tomlTableHeader 解析[table]行，返回段名
+func tomlTableHeader(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+	end := strings.Index(trimmed, "]")
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(strings.Trim(trimmed[:end], "[")), true
+}
+
+// tomlLineKey 解析 key=value 行的key，注释行、空行返回false
+func tomlLineKey(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' {
+		return "", false
+	}
+	index := strings.Index(trimmed, "=")
+	if index <= 0 {
+		return "", false
+	}
+	return strings.Trim(strings.TrimSpace(trimmed[:index]), `"`), true
+}
+
+// replaceTomlLineValue 替换 key=value 行的值，保留等号两侧的空白及行尾注释
+func replaceTomlLineValue(line, value string) string {
+	index := strings.Index(line, "=")
+	rest := line[index+1:]
+	leading := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+
+	// 引号外的#开始行尾注释，连同注释前的空白一起保留
+	comment := ""
+	var quote byte
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			comment = rest[len(strings.TrimRight(rest[:i], " \t")):]
+			i = len(rest)
+		}
+	}
+	return line[:index+1] + leading + value + comment
+}
+
+// encodeTomlValue 按toml格式编码单个配置项的值
+func encodeTomlValue(value interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(map[string]interface{}{"v": value}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(buf.String()), "v =")), nil
+}
+
+// topLevelTomlKey 查找与配置项对应的toml顶层配置项(SmartgoBrokerConfig中同名且类型兼容的字段)
+func topLevelTomlKey(fileValues map[string]interface{}, scope, key string) (string, bool) {
+	configType := reflect.TypeOf(stgcommon.BrokerConfig{})
+	if scope == body.MESSAGE_STORE_CONFIG_SCOPE {
+		configType = reflect.TypeOf(stgstorelog.MessageStoreConfig{})
+	}
+	configField, ok := fieldByJsonKey(configType, key)
+	if !ok {
+		return "", false
+	}
+	tomlField, ok := reflect.TypeOf(stgcommon.SmartgoBrokerConfig{}).FieldByNameFunc(func(name string) bool {
+		return strings.EqualFold(name, key)
+	})
+	if !ok || kindOf(tomlField.Type) != kindOf(configField.Type) {
+		return "", false
+	}
+
+	for name, value := range fileValues {
+		if _, isTable := value.(map[string]interface{}); !isTable && strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return tomlField.Name, true
+}
+
+// kindOf 各种整数类型在toml中都是integer，视为同一类型
+func kindOf(t reflect.Type) reflect.Kind {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int64
+	}
+	return t.Kind()
+}
+
+// fieldByJsonKey 根据json名称查找结构体字段
+func fieldByJsonKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// tomlValue json.Number转换为toml可以编码的整数或浮点数
+func tomlValue(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if v, err := number.Int64(); err == nil {
+		return v
+	}
+	if v, err := number.Float64(); err == nil {
+		return v
+	}
+	return number.String()
+}
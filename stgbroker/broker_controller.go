@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/client"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/client/rebalance"
+	"git.oschina.net/cloudzone/smartgo/stgbroker/kafka"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/mqtrace"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/out"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/stats"
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/acl"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/metrics"
 	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/static"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
@@ -20,6 +24,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 )
 
@@ -37,6 +42,7 @@ type BrokerController struct {
 	DefaultTransactionCheckExecuter      *DefaultTransactionCheckExecuter
 	PullMessageProcessor                 *PullMessageProcessor
 	PullRequestHoldService               *PullRequestHoldService
+	PopMessageProcessor                  *PopMessageProcessor
 	Broker2Client                        *Broker2Client
 	SubscriptionGroupManager             *SubscriptionGroupManager
 	ConsumerIdsChangeListener            rebalance.ConsumerIdsChangeListener
@@ -56,6 +62,16 @@ type BrokerController struct {
 	sendMessageHookList                  []mqtrace.SendMessageHook
 	consumeMessageHookList               []mqtrace.ConsumeMessageHook
 	brokerControllerTask                 *BrokerControllerTask
+	debugServer                          *BrokerDebugServer
+	metricsServer                        *metrics.Server
+	kafkaServer                          *kafka.Server
+	accessValidator                      *acl.PlainAccessValidator
+	DLQMessageManager                    *DLQMessageManager
+	QuotaManager                         *QuotaManager
+	PopCheckpointManager                 *PopCheckpointManager
+	DrainService                         *BrokerDrainService
+	traceHook                            *BrokerTraceHook
+	configLock                           sync.RWMutex
 }
 
 // NewBrokerController 初始化broker服务控制器
@@ -71,6 +87,7 @@ func NewBrokerController(brokerConfig *stgcommon.BrokerConfig, messageStoreConfi
 	controller.TopicConfigManager = NewTopicConfigManager(controller)
 	controller.PullMessageProcessor = NewPullMessageProcessor(controller)
 	controller.PullRequestHoldService = NewPullRequestHoldService(controller)
+	controller.PopMessageProcessor = NewPopMessageProcessor(controller)
 	controller.DefaultTransactionCheckExecuter = NewDefaultTransactionCheckExecuter(controller)
 	controller.ConsumerIdsChangeListener = NewDefaultConsumerIdsChangeListener(controller)
 	controller.ConsumerManager = client.NewConsumerManager(controller.ConsumerIdsChangeListener)
@@ -83,6 +100,10 @@ func NewBrokerController(brokerConfig *stgcommon.BrokerConfig, messageStoreConfi
 	controller.BrokerOuterAPI = out.NewBrokerOuterAPI(remotingClient)
 	controller.FilterServerManager = NewFilterServerManager(controller)
 	controller.brokerControllerTask = NewBrokerControllerTask(controller)
+	controller.DLQMessageManager = NewDLQMessageManager(controller)
+	controller.QuotaManager = NewQuotaManager(controller)
+	controller.PopCheckpointManager = NewPopCheckpointManager(controller)
+	controller.DrainService = NewBrokerDrainService(controller)
 
 	if strings.TrimSpace(controller.BrokerConfig.NamesrvAddr) != "" {
 		controller.BrokerOuterAPI.UpdateNameServerAddressList(strings.TrimSpace(controller.BrokerConfig.NamesrvAddr))
@@ -106,6 +127,8 @@ func (self *BrokerController) Initialize() bool {
 	result = result && self.TopicConfigManager.Load()
 	result = result && self.ConsumerOffsetManager.Load()
 	result = result && self.SubscriptionGroupManager.Load()
+	result = result && self.QuotaManager.Load()
+	result = result && self.PopCheckpointManager.Load()
 
 	brokerPort := static.BROKER_PORT
 	if self.BrokerConfig.BrokerPort > 0 {
@@ -120,6 +143,9 @@ func (self *BrokerController) Initialize() bool {
 		self.RemotingServer = remoting.NewDefalutRemotingServer(brokerIp, brokerPort)
 	}
 
+	result = result && self.initializeAcl()
+	result = result && self.initializeTls()
+
 	self.MessageStoreConfig.HaListenPort = self.RemotingServer.Port() + 1 // broker监听Slave请求端口，默认为Master服务端口+1
 	self.StoreHost = self.GetStoreHost()
 
@@ -136,6 +162,7 @@ func (self *BrokerController) Initialize() bool {
 		return result
 	}
 	self.brokerStats = storeStats.NewBrokerStats(self.MessageStore)
+	self.registerTraceHook()                                   // 开启消息轨迹时注册轨迹回调
 	self.registerProcessor()                                   // 注册各类Processor()请求
 	self.brokerControllerTask.startBrokerStatsRecordTask()     // 定时统计broker各类信息
 	self.brokerControllerTask.startPersistConsumerOffsetTask() // 定时写入ConsumerOffset文件
@@ -190,14 +217,22 @@ func (self *BrokerController) registerShutdownHook(stopChan chan bool) {
 	signal.Notify(stopSignalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL, os.Kill)
 
 	go func() {
-		//阻塞程序运行，直到收到终止的信号
-		s := <-stopSignalChan
-
-		logger.Infof("receive signal code = %d", s)
-		self.Shutdown()
+		//阻塞程序运行，直到收到终止的信号，或者通过管理命令触发的下线(drain)完成
+		select {
+		case s := <-stopSignalChan:
+			logger.Infof("receive signal code = %d", s)
+			if self.DrainService.IsDraining() || self.BrokerConfig.DrainOnShutdown {
+				self.DrainService.Drain(fmt.Sprintf("signal:%s", s.String()))
+			} else {
+				self.Shutdown()
+			}
+		case <-self.DrainService.Done():
+			logger.Info("broker drain finished, exit")
+		}
 		logger.Flush()
 
 		// 是否有必要close(stopSignalChan)??
+		signal.Stop(stopSignalChan)
 		close(stopSignalChan)
 
 		stopChan <- true
@@ -213,6 +248,22 @@ func (self *BrokerController) Shutdown() {
 	// 1.关闭Broker注册等定时任务
 	self.brokerControllerTask.Shutdown()
 
+	if self.debugServer != nil {
+		self.debugServer.Shutdown()
+	}
+
+	if self.metricsServer != nil {
+		self.metricsServer.Shutdown()
+	}
+
+	if self.kafkaServer != nil {
+		self.kafkaServer.Shutdown()
+	}
+
+	if self.accessValidator != nil {
+		self.accessValidator.Shutdown()
+	}
+
 	// 2.注销Broker依赖BrokerOuterAPI提供的服务，所以必须优先注销Broker再关闭BrokerOuterAPI
 	self.unRegisterBrokerAll()
 
@@ -229,14 +280,17 @@ func (self *BrokerController) Shutdown() {
 		logger.Info("RemotingServer shutdown successful")
 	}
 
+	if self.traceHook != nil {
+		self.traceHook.Shutdown()
+	}
+
 	if self.MessageStore != nil {
 		self.MessageStore.Shutdown()
 		logger.Info("MessageStore shutdown successful")
 	}
 
-	self.ConsumerOffsetManager.configManagerExt.Persist()
-	self.TopicConfigManager.ConfigManagerExt.Persist()
-	self.SubscriptionGroupManager.ConfigManagerExt.Persist()
+	self.persistAllConfig()
+	self.ConsumerOffsetManager.Shutdown()
 
 	if self.brokerStatsManager != nil {
 		self.brokerStatsManager.Shutdown()
@@ -254,6 +308,17 @@ func (self *BrokerController) Shutdown() {
 	logger.Infof("broker controller shutdown successful, consuming time total(ms): %d", consumingTimeTotal)
 }
 
+// persistAllConfig 持久化消费进度以及各项配置
+// Author agent
+// Since 2026/10/19
+func (self *BrokerController) persistAllConfig() {
+	self.ConsumerOffsetManager.Persist()
+	self.TopicConfigManager.ConfigManagerExt.Persist()
+	self.SubscriptionGroupManager.ConfigManagerExt.Persist()
+	self.QuotaManager.ConfigManagerExt.Persist()
+	self.PopCheckpointManager.Persist()
+}
+
 // Start BrokerController控制器的start启动入口
 // Author rongzhihong
 // Since 2017/9/12
@@ -262,6 +327,10 @@ func (self *BrokerController) Start() {
 		self.MessageStore.Start()
 	}
 
+	if self.traceHook != nil {
+		self.traceHook.Start()
+	}
+
 	if self.BrokerOuterAPI != nil {
 		self.BrokerOuterAPI.Start()
 	}
@@ -291,6 +360,35 @@ func (self *BrokerController) Start() {
 		self.brokerStatsManager.Start()
 	}
 
+	if self.BrokerConfig.DebugServerEnable {
+		self.debugServer = NewBrokerDebugServer(self)
+		if err := self.debugServer.Start(); err != nil {
+			logger.Errorf("broker debug server start err: %s", err.Error())
+			self.debugServer = nil
+		}
+	}
+
+	if self.BrokerConfig.MetricsServerEnable {
+		self.metricsServer = metrics.NewServer(self.BrokerConfig.MetricsServerAddr, NewBrokerMetricsCollector(self))
+		if err := self.metricsServer.Start(); err != nil {
+			logger.Errorf("broker metrics server start err: %s", err.Error())
+			self.metricsServer = nil
+		}
+	}
+
+	if self.BrokerConfig.KafkaServerEnable {
+		if self.BrokerConfig.AclEnable {
+			// Kafka协议服务不支持SASL鉴权，开启ACL时不允许绕过
+			logger.Errorf("broker kafka server can not start when aclEnable=true")
+		} else {
+			self.kafkaServer = NewBrokerKafkaServer(self)
+			if err := self.kafkaServer.Start(); err != nil {
+				logger.Errorf("broker kafka server start err: %s", err.Error())
+				self.kafkaServer = nil
+			}
+		}
+	}
+
 	self.RegisterBrokerAll(true, false)
 	self.brokerControllerTask.startRegisterAllBrokerTask() // 每个Broker会每隔30s向NameSrv更新自身topic信息
 	self.brokerControllerTask.startDeleteTopicTask()
@@ -312,6 +410,10 @@ func (self *BrokerController) unRegisterBrokerAll() {
 // Since 2017/9/12
 func (self *BrokerController) RegisterBrokerAll(checkOrderConfig bool, oneway bool) {
 	//logger.Infof("register all broker star, checkOrderConfig=%t, oneWay=%t", checkOrderConfig, oneway)
+	if self.DrainService.IsDraining() {
+		// 下线(drain)期间不再注册，避免namesrv上已摘除的写权限被恢复
+		return
+	}
 	if !self.BrokerConfig.HasWriteable() || !self.BrokerConfig.HasReadable() {
 		self.TopicConfigManager.TopicConfigSerializeWrapper.TopicConfigTable.ForeachUpdate(func(topic string, topicConfig *stgcommon.TopicConfig) {
 			topicConfig.Perm = self.BrokerConfig.BrokerPermission
@@ -343,43 +445,103 @@ func (self *BrokerController) RegisterBrokerAll(checkOrderConfig bool, oneway bo
 	//logger.Info("register all broker end")
 }
 
-// UpdateAllConfig 更新所有文件
+// UpdateAllConfig 动态修改broker配置：可热更新的配置项校验通过后逐项写入运行中的配置立即生效，
+// 其余配置项只写入toml文件，重启后生效；返回实际发生变化的配置项
 // Author rongzhihong
-// Since 2017/9/12
-func (self *BrokerController) UpdateAllConfig(properties []byte) {
-	defer utils.RecoveredFn()
+// Since 2026/10/19
+func (self *BrokerController) UpdateAllConfig(properties []byte) (*body.UpdateBrokerConfigResult, error) {
+	self.configLock.Lock()
+	defer self.configLock.Unlock()
+
+	oldValues := map[string]map[string]interface{}{
+		body.BROKER_CONFIG_SCOPE:        configValues(self.BrokerConfig),
+		body.MESSAGE_STORE_CONFIG_SCOPE: configValues(self.MessageStoreConfig),
+	}
+	update, err := parseBrokerConfigUpdate(properties, oldValues[body.BROKER_CONFIG_SCOPE], oldValues[body.MESSAGE_STORE_CONFIG_SCOPE])
+	if err != nil {
+		return nil, err
+	}
+
+	// 先在副本上修改，全部校验通过后再写入运行中的配置
+	newBrokerConfig := *self.BrokerConfig
+	if err := applyConfigValues(&newBrokerConfig, update[body.BROKER_CONFIG_SCOPE]); err != nil {
+		return nil, fmt.Errorf("brokerConfig invalid: %s", err.Error())
+	}
+	newStoreConfig := *self.MessageStoreConfig
+	if err := applyConfigValues(&newStoreConfig, update[body.MESSAGE_STORE_CONFIG_SCOPE]); err != nil {
+		return nil, fmt.Errorf("messageStoreConfig invalid: %s", err.Error())
+	}
+	if err := validateHotConfig(&newBrokerConfig, &newStoreConfig); err != nil {
+		return nil, err
+	}
+
+	newValues := map[string]map[string]interface{}{
+		body.BROKER_CONFIG_SCOPE:        configValues(&newBrokerConfig),
+		body.MESSAGE_STORE_CONFIG_SCOPE: configValues(&newStoreConfig),
+	}
+	result := body.NewUpdateBrokerConfigResult()
+	for _, scope := range []string{body.BROKER_CONFIG_SCOPE, body.MESSAGE_STORE_CONFIG_SCOPE} {
+		changes := diffConfigValues(scope, oldValues[scope], newValues[scope], update[scope])
+		result.Changes = append(result.Changes, changes...)
+	}
+	if len(result.Changes) == 0 {
+		return result, nil
+	}
 
-	allConfig := NewBrokerAllConfig()
-	err := stgcommon.Decode(properties, allConfig)
+	persisted, err := self.flushAllConfig(result.Changes, newValues)
 	if err != nil {
-		logger.Errorf("UpdateAllConfig[%s] err: %s", string(properties), err.Error())
-		return
+		return nil, err
+	}
+	if persisted {
+		result.ConfigFile = self.ConfigFile
 	}
 
-	self.BrokerConfig = allConfig.BrokerConfig
-	self.MessageStoreConfig = allConfig.MessageStoreConfig
+	hotValues := newBrokerConfigUpdate()
+	permissionChanged := false
+	for _, change := range result.Changes {
+		logger.Infof("update broker config %s.%s: %s -> %s, hotReload=%t", change.Scope, change.Key, change.OldValue, change.NewValue, change.HotReload)
+		if change.HotReload {
+			hotValues[change.Scope][change.Key] = update[change.Scope][change.Key]
+			permissionChanged = permissionChanged || change.Key == "brokerPermission"
+		}
+	}
 
+	// 可热更新的配置项逐项写入运行中的配置，只改写发生变化的字段。读取方不持有configLock，
+	// 各配置项生效的先后没有保证，可能先读到其中一部分新值，因此每个可热更新的配置项都必须能够单独生效
+	applyConfigValues(self.BrokerConfig, hotValues[body.BROKER_CONFIG_SCOPE])
+	applyConfigValues(self.MessageStoreConfig, hotValues[body.MESSAGE_STORE_CONFIG_SCOPE])
 	self.ConfigDataVersion.NextVersion()
-	self.flushAllConfig()
+
+	// broker读写权限变化需要通知namesrv
+	if permissionChanged {
+		go self.RegisterBrokerAll(true, false)
+	}
+	return result, nil
 }
 
-// flushAllConfig 将配置信息刷入文件中
+// flushAllConfig 将配置变更写回broker启动时的toml配置文件，未指定配置文件时只修改内存
 // Author rongzhihong
 // Since 2017/9/12
-func (self *BrokerController) flushAllConfig() {
-	defer utils.RecoveredFn()
-
-	allConfig := self.EncodeAllConfig()
-	logger.Infof("all config: %T", allConfig)
-	// TODO 当前配置信息是直接初始化的，所以暂时不写到文件中
-	//stgcommon.String2File([]byte(allConfig), self.ConfigFile)
+func (self *BrokerController) flushAllConfig(changes []*body.BrokerConfigChange, newValues map[string]map[string]interface{}) (bool, error) {
+	if exist, _ := stgcommon.ExistsFile(self.ConfigFile); self.ConfigFile == "" || !exist {
+		logger.Warnf("broker config file[%s] not exist, config changes only in memory", self.ConfigFile)
+		return false, nil
+	}
+	if err := persistBrokerConfigFile(self.ConfigFile, changes, newValues); err != nil {
+		logger.Errorf("flush broker config %s err: %s", self.ConfigFile, err.Error())
+		return false, err
+	}
 	logger.Infof("flush broker config, %s OK", self.ConfigFile)
+	return true, nil
 }
 
 // EncodeAllConfig 读取所有配置文件信息
 // Author rongzhihong
 // Since 2017/9/12
 func (self *BrokerController) EncodeAllConfig() string {
+	self.configLock.RLock()
+	defer self.configLock.RUnlock()
+
 	buf := bytes.NewBuffer([]byte{})
 	allConfig := NewDefaultBrokerAllConfig(self.BrokerConfig, self.MessageStoreConfig)
 	content := stgcommon.Encode(allConfig)
@@ -401,10 +563,12 @@ func (self *BrokerController) registerProcessor() {
 	self.RemotingServer.RegisterProcessor(code.GET_CONSUMER_LIST_BY_GROUP, clientProcessor) // 获取Consumer列表
 	self.RemotingServer.RegisterProcessor(code.QUERY_CONSUMER_OFFSET, clientProcessor)      // 查询ConsumerOffset
 	self.RemotingServer.RegisterProcessor(code.UPDATE_CONSUMER_OFFSET, clientProcessor)     // 更新ConsumerOffset
+	clientProcessor.RegisterConsumeMessageHook(self.consumeMessageHookList)                 // 消费确认回调
 
 	// 发送消息事件处理器 SendMessageProcessor
 	sendMessageProcessor := NewSendMessageProcessor(self)
 	sendMessageProcessor.RegisterSendMessageHook(self.sendMessageHookList)                   // 发送消息回调
+	sendMessageProcessor.RegisterConsumeMessageHook(self.consumeMessageHookList)             // 消费失败回传回调
 	self.RemotingServer.RegisterProcessor(code.SEND_MESSAGE, sendMessageProcessor)           // 未优化过发送消息
 	self.RemotingServer.RegisterProcessor(code.SEND_MESSAGE_V2, sendMessageProcessor)        // 优化过发送消息
 	self.RemotingServer.RegisterProcessor(code.CONSUMER_SEND_MSG_BACK, sendMessageProcessor) // 消费失败消息
@@ -414,6 +578,12 @@ func (self *BrokerController) registerProcessor() {
 	self.RemotingServer.RegisterProcessor(code.PULL_MESSAGE, pullMessageProcessor) // Broker拉取消息
 	pullMessageProcessor.RegisterConsumeMessageHook(self.consumeMessageHookList)   // 消费消息回调
 
+	// POP消息事件处理器 PopMessageProcessor
+	popMessageProcessor := self.PopMessageProcessor
+	self.RemotingServer.RegisterProcessor(code.POP_MESSAGE, popMessageProcessor)                  // Consumer POP消息
+	self.RemotingServer.RegisterProcessor(code.ACK_MESSAGE, popMessageProcessor)                  // Consumer 确认POP消息
+	self.RemotingServer.RegisterProcessor(code.CHANGE_MESSAGE_INVISIBLETIME, popMessageProcessor) // Consumer 修改POP消息不可见时间
+
 	// 查询消息事件处理器 QueryMessageProcessor
 	queryProcessor := NewQueryMessageProcessor(self)
 	self.RemotingServer.RegisterProcessor(code.QUERY_MESSAGE, queryProcessor)      // Broker 查询消息
@@ -423,11 +593,62 @@ func (self *BrokerController) registerProcessor() {
 	endTransactionProcessor := NewEndTransactionProcessor(self)
 	self.RemotingServer.RegisterProcessor(code.END_TRANSACTION, endTransactionProcessor) // Broker Commit或者Rollback事务
 
+	// 应答消息处理器 ReplyMessageProcessor
+	replyMessageProcessor := NewReplyMessageProcessor(self)
+	self.RemotingServer.RegisterProcessor(code.SEND_REPLY_MESSAGE, replyMessageProcessor) // Consumer 发送应答消息
+
 	// 默认事件处理器 DefaultProcessor
 	adminProcessor := NewAdminBrokerProcessor(self)
 	self.RemotingServer.RegisterDefaultProcessor(adminProcessor) // 默认Admin请求
 }
 
+// initializeAcl 开启ACL时注册服务端鉴权；配置了accessKey时对broker发出的请求签名
+// Author agent
+// Since 2026/10/19
+func (self *BrokerController) initializeAcl() bool {
+	if self.BrokerConfig.AccessKey != "" && self.RemotingClient != nil {
+		self.RemotingClient.RegisterRPCHook(acl.NewAclClientRPCHook(self.BrokerConfig.AccessKey, self.BrokerConfig.SecretKey))
+		logger.Infof("register acl rpc hook, accessKey=%s", self.BrokerConfig.AccessKey)
+	}
+
+	if !self.BrokerConfig.AclEnable {
+		return true
+	}
+	accessValidator, err := acl.NewPlainAccessValidator(self.BrokerConfig.AclConfigPath)
+	if err != nil {
+		logger.Errorf("initialize acl err: %s", err.Error())
+		return false
+	}
+	accessValidator.Start()
+	self.accessValidator = accessValidator
+	self.RemotingServer.RegisterAccessValidator(accessValidator)
+	logger.Infof("acl enabled, aclConfigPath=%s", self.BrokerConfig.AclConfigPath)
+	return true
+}
+
+// initializeTls 按配置为broker服务端、以及访问namesrv、master的客户端开启TLS
+// Author agent
+// Since 2026/10/19
+func (self *BrokerController) initializeTls() bool {
+	serverTLSOptions := self.BrokerConfig.ServerTLSOptions()
+	if err := self.RemotingServer.SetTLSOptions(serverTLSOptions); err != nil {
+		logger.Errorf("initialize server tls err: %s", err.Error())
+		return false
+	}
+	if serverTLSOptions.Enabled() {
+		logger.Infof("server tls enabled, tlsMode=%s", serverTLSOptions.Mode)
+	}
+
+	if self.BrokerConfig.TlsClientEnable && self.RemotingClient != nil {
+		if err := self.RemotingClient.SetTLSOptions(self.BrokerConfig.ClientTLSOptions()); err != nil {
+			logger.Errorf("initialize client tls err: %s", err.Error())
+			return false
+		}
+		logger.Info("client tls enabled")
+	}
+	return true
+}
+
 // getConfigDataVersion 获得数据配置版本号
 // Author rongzhihong
 // Since 2017/9/8
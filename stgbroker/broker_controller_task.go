@@ -106,7 +106,8 @@ func (self *BrokerControllerTask) startBrokerStatsRecordTask() {
 func (self *BrokerControllerTask) startPersistConsumerOffsetTask() {
 	period := time.Duration(self.BrokerController.BrokerConfig.FlushConsumerOffsetInterval) * time.Millisecond
 	self.PersistConsumerOffsetTask = timeutil.NewTicker(false, 10*time.Second, period, func() {
-		self.BrokerController.ConsumerOffsetManager.configManagerExt.Persist()
+		self.BrokerController.ConsumerOffsetManager.Persist()
+		self.BrokerController.PopCheckpointManager.Persist()
 	})
 	self.PersistConsumerOffsetTask.Start()
 	logger.Infof("PersistConsumerOffsetTask start ok")
@@ -0,0 +1,223 @@
+package stgbroker
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/static"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+const (
+	debugStorePrefix  = "/store/"       // 存储层只读视图
+	debugBrokerPrefix = "/broker/"      // broker层只读视图
+	debugPprofPrefix  = "/debug/pprof/" // pprof性能分析
+)
+
+// BrokerDebugServer broker内嵌调试HTTP服务，只读输出存储与客户端连接的内部状态
+//
+// 注意：默认只绑定本机地址，且所有接口仅支持GET请求，不提供任何修改能力
+//
+// Author agent
+// Since 2026/10/19
+type BrokerDebugServer struct {
+	brokerController *BrokerController
+	addr             string
+	listener         net.Listener
+	server           *http.Server
+	routes           map[string]func(r *http.Request) (interface{}, bool)
+}
+
+// NewBrokerDebugServer 初始化调试HTTP服务
+// Author agent
+// Since 2026/10/19
+func NewBrokerDebugServer(brokerController *BrokerController) *BrokerDebugServer {
+	debugServer := new(BrokerDebugServer)
+	debugServer.brokerController = brokerController
+	debugServer.addr = strings.TrimSpace(brokerController.BrokerConfig.DebugServerAddr)
+	if debugServer.addr == "" {
+		debugServer.addr = static.BROKER_DEBUG_ADDR
+	}
+	debugServer.routes = debugServer.buildRoutes()
+	return debugServer
+}
+
+// buildRoutes 构建只读视图路由表，返回值bool表示依赖的服务是否可用
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) buildRoutes() map[string]func(r *http.Request) (interface{}, bool) {
+	store := func(fn func() interface{}) func(r *http.Request) (interface{}, bool) {
+		return func(r *http.Request) (interface{}, bool) {
+			if self.brokerController.MessageStore == nil {
+				return nil, false
+			}
+			return fn(), true
+		}
+	}
+
+	return map[string]func(r *http.Request) (interface{}, bool){
+		debugStorePrefix + "commitlog": store(func() interface{} {
+			return self.brokerController.MessageStore.CommitLogInfo()
+		}),
+		debugStorePrefix + "consumequeue": func(r *http.Request) (interface{}, bool) {
+			if self.brokerController.MessageStore == nil {
+				return nil, false
+			}
+			return self.brokerController.MessageStore.ConsumeQueueInfos(r.URL.Query().Get("topic")), true
+		},
+		debugStorePrefix + "checkpoint": store(func() interface{} {
+			return self.brokerController.MessageStore.CheckpointInfo()
+		}),
+		debugStorePrefix + "flush": store(func() interface{} {
+			return self.brokerController.MessageStore.FlushInfo()
+		}),
+		debugStorePrefix + "runningflags": store(func() interface{} {
+			return self.brokerController.MessageStore.RunningFlagsInfo()
+		}),
+		debugStorePrefix + "ha": store(func() interface{} {
+			return self.brokerController.MessageStore.HAServiceInfo()
+		}),
+		debugStorePrefix + "runtime": store(func() interface{} {
+			return self.brokerController.MessageStore.GetRuntimeInfo()
+		}),
+		debugBrokerPrefix + "holds": func(r *http.Request) (interface{}, bool) {
+			if self.brokerController.PullRequestHoldService == nil {
+				return nil, false
+			}
+			return self.brokerController.PullRequestHoldService.HoldRequestViews(), true
+		},
+		debugBrokerPrefix + "consumers": func(r *http.Request) (interface{}, bool) {
+			if self.brokerController.ConsumerManager == nil {
+				return nil, false
+			}
+			return self.brokerController.ConsumerManager.ChannelViews(), true
+		},
+		debugBrokerPrefix + "producers": func(r *http.Request) (interface{}, bool) {
+			if self.brokerController.ProducerManager == nil {
+				return nil, false
+			}
+			return self.brokerController.ProducerManager.ChannelViews(), true
+		},
+	}
+}
+
+// Start 启动调试HTTP服务
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) Start() error {
+	listener, err := net.Listen("tcp", self.addr)
+	if err != nil {
+		return err
+	}
+	self.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", self.serveIndex)
+	mux.HandleFunc(debugStorePrefix, self.serveView)
+	mux.HandleFunc(debugBrokerPrefix, self.serveView)
+	mux.HandleFunc(debugPprofPrefix, pprof.Index)
+	mux.HandleFunc(debugPprofPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(debugPprofPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(debugPprofPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(debugPprofPrefix+"trace", pprof.Trace)
+	self.server = &http.Server{Handler: mux}
+
+	go func() {
+		defer utils.RecoveredFn()
+		if err := self.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("broker debug server serve err: %s", err.Error())
+		}
+	}()
+
+	logger.Infof("broker debug server start successful, listen %s", listener.Addr().String())
+	return nil
+}
+
+// Shutdown 关闭调试HTTP服务
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) Shutdown() {
+	if self.server != nil {
+		self.server.Close()
+		logger.Info("broker debug server shutdown successful")
+	}
+}
+
+// Addr 调试HTTP服务实际监听的地址
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) Addr() string {
+	if self.listener != nil {
+		return self.listener.Addr().String()
+	}
+	return self.addr
+}
+
+// serveIndex 列出所有可访问的调试路径
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	paths := make([]string, 0, len(self.routes)+1)
+	for path := range self.routes {
+		paths = append(paths, path)
+	}
+	paths = append(paths, debugPprofPrefix)
+	self.writeJSON(w, r, http.StatusOK, paths)
+}
+
+// serveView 输出只读视图
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) serveView(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	route, ok := self.routes[strings.TrimSuffix(r.URL.Path, "/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, available := route(r)
+	if !available {
+		http.Error(w, "service not available", http.StatusServiceUnavailable)
+		return
+	}
+	self.writeJSON(w, r, http.StatusOK, data)
+}
+
+// writeJSON 以JSON格式输出，携带pretty参数时格式化输出
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDebugServer) writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	var (
+		content []byte
+		err     error
+	)
+	if _, pretty := r.URL.Query()["pretty"]; pretty {
+		content, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		content, err = json.Marshal(data)
+	}
+	if err != nil {
+		logger.Errorf("broker debug server encode %s err: %s", r.URL.Path, err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(content)
+}
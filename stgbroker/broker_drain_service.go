@@ -0,0 +1,277 @@
+package stgbroker
+
+import (
+	"encoding/json"
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	drainCheckInterval = 100 * time.Millisecond // 等待生产者迁移期间的检查间隔
+)
+
+// BrokerDrainStep 下线(drain)过程中单个步骤的执行结果
+// Author agent
+// Since 2026/10/19
+type BrokerDrainStep struct {
+	Name           string `json:"name"`
+	BeginTimestamp int64  `json:"beginTimestamp"`
+	CostMills      int64  `json:"costMills"`
+	Success        bool   `json:"success"`
+	Detail         string `json:"detail"`
+}
+
+// BrokerDrainReport 下线(drain)报告，退出时写入日志以及drainReport.json
+// Author agent
+// Since 2026/10/19
+type BrokerDrainReport struct {
+	BrokerName         string             `json:"brokerName"`
+	BrokerAddr         string             `json:"brokerAddr"`
+	Trigger            string             `json:"trigger"`
+	BeginTimestamp     int64              `json:"beginTimestamp"`
+	EndTimestamp       int64              `json:"endTimestamp"`
+	CostMills          int64              `json:"costMills"`
+	Success            bool               `json:"success"`
+	WipeTopicCount     int                `json:"wipeTopicCount"`
+	NotifiedClients    int                `json:"notifiedClients"`
+	LastSendTimestamp  int64              `json:"lastSendTimestamp"`
+	InflightSends      int64              `json:"inflightSends"`
+	WakeupPullRequests int                `json:"wakeupPullRequests"`
+	MaxPhyOffset       int64              `json:"maxPhyOffset"`
+	Steps              []*BrokerDrainStep `json:"steps"`
+}
+
+// BrokerDrainService broker优雅下线：摘除namesrv上的写权限、通知客户端更新路由、等待生产者迁移、
+// 响应被Hold住的拉请求、刷盘并持久化配置，最后停止broker并输出下线报告
+// Author agent
+// Since 2026/10/19
+type BrokerDrainService struct {
+	brokerController  *BrokerController
+	draining          int32
+	inflightSends     int64
+	lastSendTimestamp int64
+	doneChan          chan struct{}
+	report            *BrokerDrainReport
+}
+
+// NewBrokerDrainService 初始化broker优雅下线服务
+// Author agent
+// Since 2026/10/19
+func NewBrokerDrainService(brokerController *BrokerController) *BrokerDrainService {
+	return &BrokerDrainService{
+		brokerController: brokerController,
+		doneChan:         make(chan struct{}),
+	}
+}
+
+// IsDraining broker是否正在下线
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) IsDraining() bool {
+	return atomic.LoadInt32(&self.draining) == 1
+}
+
+// Done 下线完成(broker已停止)时关闭的通道
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) Done() <-chan struct{} {
+	return self.doneChan
+}
+
+// Report 获得下线报告，下线完成前返回nil
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) Report() *BrokerDrainReport {
+	select {
+	case <-self.doneChan:
+		return self.report
+	default:
+		return nil
+	}
+}
+
+// BeginSend 开始处理发送请求
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) BeginSend() {
+	atomic.AddInt64(&self.inflightSends, 1)
+	atomic.StoreInt64(&self.lastSendTimestamp, timeutil.CurrentTimeMillis())
+}
+
+// EndSend 发送请求处理完毕
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) EndSend() {
+	atomic.AddInt64(&self.inflightSends, -1)
+	atomic.StoreInt64(&self.lastSendTimestamp, timeutil.CurrentTimeMillis())
+}
+
+// StartDrain 异步开始下线，已经在下线时返回错误
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) StartDrain(trigger string) error {
+	if !atomic.CompareAndSwapInt32(&self.draining, 0, 1) {
+		return fmt.Errorf("broker %s is already draining", self.brokerController.BrokerConfig.BrokerName)
+	}
+	go self.drain(trigger)
+	return nil
+}
+
+// Drain 同步下线，完成后broker已经停止；已经在下线时等待其完成
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) Drain(trigger string) *BrokerDrainReport {
+	if atomic.CompareAndSwapInt32(&self.draining, 0, 1) {
+		self.drain(trigger)
+	}
+	<-self.doneChan
+	return self.report
+}
+
+func (self *BrokerDrainService) drain(trigger string) {
+	brokerConfig := self.brokerController.BrokerConfig
+	report := &BrokerDrainReport{
+		BrokerName:     brokerConfig.BrokerName,
+		BrokerAddr:     self.brokerController.GetBrokerAddr(),
+		Trigger:        trigger,
+		BeginTimestamp: timeutil.CurrentTimeMillis(),
+		Steps:          make([]*BrokerDrainStep, 0),
+	}
+	logger.Infof("broker %s start draining, trigger: %s", report.BrokerName, trigger)
+
+	// 1.摘除namesrv上的写权限，drain期间RegisterBrokerAll不再注册，避免写权限被定时注册恢复
+	self.runStep(report, "wipeWritePerm", func() (bool, string) {
+		if self.brokerController.MessageStoreConfig.BrokerRole == config.SLAVE {
+			return true, "slave broker has no write permission, skip"
+		}
+		wipeTopicCount, failed := self.brokerController.BrokerOuterAPI.WipeWritePermOfBrokerAll(brokerConfig.BrokerName)
+		report.WipeTopicCount = wipeTopicCount
+		return len(failed) == 0, fmt.Sprintf("wipeTopicCount=%d, failedNamesrv=%v", wipeTopicCount, failed)
+	})
+
+	// 2.通知客户端立即更新路由，生产者不必等到下一次定时刷新路由才迁移走
+	self.runStep(report, "notifyClients", func() (bool, string) {
+		report.NotifiedClients = self.notifyClients()
+		return true, fmt.Sprintf("notifiedClients=%d", report.NotifiedClients)
+	})
+
+	// 3.等待生产者迁移走：连续DrainProducerQuietMills没有发送请求且没有正在处理的发送请求
+	self.runStep(report, "waitProducers", func() (bool, string) {
+		quiet := self.waitProducers(brokerConfig.DrainProducerTimeoutMills, brokerConfig.DrainProducerQuietMills)
+		report.LastSendTimestamp = atomic.LoadInt64(&self.lastSendTimestamp)
+		report.InflightSends = atomic.LoadInt64(&self.inflightSends)
+		return quiet, fmt.Sprintf("lastSendTimestamp=%d, inflightSends=%d", report.LastSendTimestamp, report.InflightSends)
+	})
+
+	// 4.立即响应被Hold住的拉消息请求，drain期间新的拉请求也不会再被Hold
+	self.runStep(report, "wakeupHoldPullRequests", func() (bool, string) {
+		wakeupCount, finished := self.brokerController.PullRequestHoldService.WakeupAll(brokerConfig.DrainPullTimeoutMills)
+		report.WakeupPullRequests = wakeupCount
+		return finished, fmt.Sprintf("wakeupPullRequests=%d, finished=%t", wakeupCount, finished)
+	})
+
+	// 5.等待消息分发完毕，CommitLog、ConsumeQueue全部刷盘
+	self.runStep(report, "flushStore", func() (bool, string) {
+		if self.brokerController.MessageStore == nil {
+			return true, "message store is nil, skip"
+		}
+		flushed := self.brokerController.MessageStore.FlushAll(brokerConfig.DrainFlushTimeoutMills)
+		report.MaxPhyOffset = self.brokerController.MessageStore.GetMaxPhyOffset()
+		return flushed, fmt.Sprintf("maxPhyOffset=%d", report.MaxPhyOffset)
+	})
+
+	// 6.持久化消费进度以及各项配置
+	self.runStep(report, "persistConfig", func() (bool, string) {
+		self.brokerController.persistAllConfig()
+		return true, ""
+	})
+
+	// 7.停止broker：从namesrv注销、停止网络服务以及存储服务
+	self.runStep(report, "shutdown", func() (bool, string) {
+		self.brokerController.Shutdown()
+		return true, ""
+	})
+
+	report.EndTimestamp = timeutil.CurrentTimeMillis()
+	report.CostMills = report.EndTimestamp - report.BeginTimestamp
+	report.Success = true
+	for _, step := range report.Steps {
+		report.Success = report.Success && step.Success
+	}
+	self.writeReport(report)
+
+	self.report = report
+	close(self.doneChan)
+}
+
+// runStep 执行单个下线步骤并记录到报告中，单个步骤失败不影响后续步骤
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) runStep(report *BrokerDrainReport, name string, fn func() (bool, string)) {
+	step := &BrokerDrainStep{Name: name, BeginTimestamp: timeutil.CurrentTimeMillis()}
+	func() {
+		defer func() {
+			if err := recover(); err != nil {
+				step.Success = false
+				step.Detail = fmt.Sprintf("panic: %v", err)
+			}
+		}()
+		step.Success, step.Detail = fn()
+	}()
+	step.CostMills = timeutil.CurrentTimeMillis() - step.BeginTimestamp
+	report.Steps = append(report.Steps, step)
+	logger.Infof("broker drain step %s done, success=%t, cost=%dms, %s", step.Name, step.Success, step.CostMills, step.Detail)
+}
+
+// waitProducers 等待生产者迁移走，超时返回false
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) waitProducers(timeoutMills, quietMills int64) bool {
+	deadline := timeutil.CurrentTimeMillis() + timeoutMills
+	for {
+		now := timeutil.CurrentTimeMillis()
+		lastSendTimestamp := atomic.LoadInt64(&self.lastSendTimestamp)
+		if atomic.LoadInt64(&self.inflightSends) <= 0 && now-lastSendTimestamp >= quietMills {
+			return true
+		}
+		if now >= deadline {
+			return false
+		}
+		time.Sleep(drainCheckInterval)
+	}
+}
+
+// notifyClients 通知所有连接的生产者、消费者更新路由，返回通知的客户端个数
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) notifyClients() int {
+	clientIds := make(map[string]bool)
+	channels := self.brokerController.ProducerManager.AllChannels()
+	channels = append(channels, self.brokerController.ConsumerManager.AllChannels()...)
+	for _, channelInfo := range channels {
+		if clientIds[channelInfo.ClientId] || channelInfo.Context == nil || channelInfo.Context.IsClosed() {
+			continue
+		}
+		clientIds[channelInfo.ClientId] = true
+		self.brokerController.Broker2Client.NotifyBrokerDraining(channelInfo.Context)
+	}
+	return len(clientIds)
+}
+
+// writeReport 输出下线报告
+// Author agent
+// Since 2026/10/19
+func (self *BrokerDrainService) writeReport(report *BrokerDrainReport) {
+	buf, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Errorf("encode broker drain report err: %s", err.Error())
+		return
+	}
+	logger.Infof("broker drain report: %s", string(buf))
+	stgcommon.String2File(buf, GetDrainReportPath(self.brokerController.BrokerConfig.StorePathRootDir))
+}
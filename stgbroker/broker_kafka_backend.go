@@ -0,0 +1,335 @@
+package stgbroker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker/kafka"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
+)
+
+const (
+	kafkaFetchGroup = "%KAFKA%" // Kafka Fetch读取消息时使用的虚拟消费组，仅用于存储层日志
+)
+
+// brokerKafkaBackend Kafka协议服务访问本broker存储、消费进度的适配
+// Author agent
+// Since 2026/10/19
+type brokerKafkaBackend struct {
+	brokerController *BrokerController
+}
+
+// NewBrokerKafkaServer 创建监听BrokerConfig.KafkaServerAddr的Kafka协议服务
+// Author agent
+// Since 2026/10/19
+func NewBrokerKafkaServer(brokerController *BrokerController) *kafka.Server {
+	backend := &brokerKafkaBackend{brokerController: brokerController}
+	return kafka.NewServer(brokerController.BrokerConfig.KafkaServerAddr, backend)
+}
+
+// BrokerName 本broker名称
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) BrokerName() string {
+	return backend.brokerController.BrokerConfig.BrokerName
+}
+
+// GetTopics 本broker上可读的业务topic，不包含系统topic、重试队列与死信队列
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetTopics() []string {
+	topicConfigManager := backend.brokerController.TopicConfigManager
+	topics := make([]string, 0)
+	topicConfigManager.TopicConfigSerializeWrapper.TopicConfigTable.Foreach(func(topic string, topicConfig *stgcommon.TopicConfig) {
+		if topicConfigManager.isSystemTopic(topic) || !topicConfigManager.isTopicCanSendMessage(topic) {
+			return
+		}
+		if strings.HasPrefix(topic, stgcommon.RETRY_GROUP_TOPIC_PREFIX) || strings.HasPrefix(topic, stgcommon.DLQ_GROUP_TOPIC_PREFIX) {
+			return
+		}
+		if constant.IsReadable(topicConfig.Perm) {
+			topics = append(topics, topic)
+		}
+	})
+	sort.Strings(topics)
+	return topics
+}
+
+// GetTopicRoute 从namesrv查询topic路由，namesrv不可用时退化为本broker上的路由
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetTopicRoute(topic string) (*route.TopicRouteData, error) {
+	topicRouteData, err := backend.brokerController.BrokerOuterAPI.GetTopicRouteInfoFromNameServer(topic)
+	if err == nil {
+		if topicRouteData == nil {
+			return nil, kafka.ERR_UNKNOWN_TOPIC_OR_PARTITION
+		}
+		return topicRouteData, nil
+	}
+
+	logger.Warnf("kafka get topic route from namesrv err: %s, use local route. topic=%s", err.Error(), topic)
+	topicConfig := backend.brokerController.TopicConfigManager.SelectTopicConfig(topic)
+	if topicConfig == nil {
+		return nil, kafka.ERR_UNKNOWN_TOPIC_OR_PARTITION
+	}
+	topicRouteData = route.NewTopicRouteData()
+	topicRouteData.QueueDatas = append(topicRouteData.QueueDatas, route.NewQueueData(backend.BrokerName(), topicConfig))
+	if backend.brokerController.BrokerConfig.BrokerId == stgcommon.MASTER_ID {
+		brokerData := &route.BrokerData{
+			BrokerName:  backend.BrokerName(),
+			BrokerAddrs: map[int]string{stgcommon.MASTER_ID: backend.brokerController.GetBrokerAddr()},
+		}
+		topicRouteData.BrokerDatas = append(topicRouteData.BrokerDatas, brokerData)
+	}
+	return topicRouteData, nil
+}
+
+// GetBrokers 本集群中全部brokerName及其master地址
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetBrokers() (map[string]string, error) {
+	clusterPlusInfo, err := backend.brokerController.BrokerOuterAPI.GetBrokerClusterInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	brokers := make(map[string]string)
+	for _, brokerName := range clusterPlusInfo.ClusterAddrTable[backend.brokerController.BrokerConfig.BrokerClusterName] {
+		brokerData, ok := clusterPlusInfo.BrokerAddrTable[brokerName]
+		if !ok || brokerData == nil {
+			continue
+		}
+		if brokerAddr, ok := brokerData.BrokerAddrs[stgcommon.MASTER_ID]; ok && brokerAddr != "" {
+			brokers[brokerName] = brokerAddr
+		}
+	}
+	return brokers, nil
+}
+
+// PutMessages 依次写入本broker的队列，返回第一条消息的队列位点
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) PutMessages(topic string, queueId int32, records []*kafka.Record) (int64, error) {
+	bc := backend.brokerController
+	if bc.MessageStoreConfig.BrokerRole == config.SLAVE || !constant.IsWriteable(bc.BrokerConfig.BrokerPermission) {
+		return 0, kafka.ERR_NOT_LEADER_FOR_PARTITION
+	}
+	topicConfig := bc.TopicConfigManager.SelectTopicConfig(topic)
+	if topicConfig == nil || !bc.TopicConfigManager.isTopicCanSendMessage(topic) {
+		return 0, kafka.ERR_UNKNOWN_TOPIC_OR_PARTITION
+	}
+	if !constant.IsWriteable(topicConfig.Perm) || queueId < 0 || queueId >= topicConfig.WriteQueueNums {
+		return 0, kafka.ERR_UNKNOWN_TOPIC_OR_PARTITION
+	}
+
+	baseOffset := int64(-1)
+	for _, record := range records {
+		msgInner := buildKafkaMessageInner(bc, topic, queueId, record)
+		putMessageResult := bc.MessageStore.PutMessage(msgInner)
+		if putMessageResult == nil {
+			return 0, fmt.Errorf("put kafka message to %s:%d failed, result is nil", topic, queueId)
+		}
+
+		switch putMessageResult.PutMessageStatus {
+		case stgstorelog.PUTMESSAGE_PUT_OK:
+		case stgstorelog.FLUSH_DISK_TIMEOUT, stgstorelog.FLUSH_SLAVE_TIMEOUT, stgstorelog.SLAVE_NOT_AVAILABLE:
+			// 消息已写入，刷盘或同步超时对应Kafka的超时应答，客户端可重试
+			return 0, kafka.ERR_REQUEST_TIMED_OUT
+		case stgstorelog.MESSAGE_ILLEGAL:
+			return 0, kafka.ERR_MESSAGE_TOO_LARGE
+		case stgstorelog.SERVICE_NOT_AVAILABLE:
+			return 0, kafka.ERR_NOT_LEADER_FOR_PARTITION
+		default:
+			return 0, fmt.Errorf("put kafka message to %s:%d failed, status=%d", topic, queueId, putMessageResult.PutMessageStatus)
+		}
+
+		if baseOffset < 0 {
+			baseOffset = putMessageResult.AppendMessageResult.LogicsOffset
+		}
+		if bc.brokerStatsManager != nil {
+			bc.brokerStatsManager.IncTopicPutNums(topic)
+			bc.brokerStatsManager.IncTopicPutSize(topic, putMessageResult.AppendMessageResult.WroteBytes)
+			bc.brokerStatsManager.IncBrokerPutNums()
+		}
+	}
+	return baseOffset, nil
+}
+
+// buildKafkaMessageInner Kafka消息转换为存储层消息，key保存在PROPERTY_KAFKA_KEY中，可读的key同时作为消息key建立索引
+// Author agent
+// Since 2026/10/19
+func buildKafkaMessageInner(bc *BrokerController, topic string, queueId int32, record *kafka.Record) *stgstorelog.MessageExtBrokerInner {
+	msgInner := new(stgstorelog.MessageExtBrokerInner)
+	msgInner.Topic = topic
+	msgInner.Body = record.Value
+	msgInner.Flag = 0
+	if record.Key != nil {
+		msgInner.PutProperty(message.PROPERTY_KAFKA_KEY, base64.StdEncoding.EncodeToString(record.Key))
+		if isIndexableKafkaKey(record.Key) {
+			msgInner.SetKeys(string(record.Key))
+		}
+	}
+	msgInner.PropertiesString = message.MessageProperties2String(msgInner.Properties)
+	msgInner.TagsCode = 0
+
+	msgInner.QueueId = queueId
+	msgInner.SysFlag = sysflag.TransactionNotType
+	msgInner.BornTimestamp = record.Timestamp
+	if msgInner.BornTimestamp <= 0 {
+		msgInner.BornTimestamp = timeutil.CurrentTimeMillis()
+	}
+	msgInner.BornHost = bc.GetBrokerAddr()
+	msgInner.StoreHost = bc.GetStoreHost()
+	msgInner.ReconsumeTimes = 0
+	return msgInner
+}
+
+// isIndexableKafkaKey key为不含空白的可打印字符时才可作为消息key
+// Author agent
+// Since 2026/10/19
+func isIndexableKafkaKey(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, r := range string(key) {
+		if r == unicode.ReplacementChar || !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetMessages 从本broker队列的offset开始读取消息，offset越界时返回ERR_OFFSET_OUT_OF_RANGE
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetMessages(topic string, queueId int32, offset int64, maxNums int32) ([]*kafka.Record, error) {
+	getMessageResult := backend.brokerController.MessageStore.GetMessage(kafkaFetchGroup, topic, queueId, offset, maxNums, nil)
+	if getMessageResult == nil {
+		return nil, nil
+	}
+	defer getMessageResult.Release()
+
+	switch getMessageResult.Status {
+	case stgstorelog.FOUND:
+	case stgstorelog.OFFSET_TOO_SMALL, stgstorelog.OFFSET_OVERFLOW_BADLY:
+		return nil, kafka.ERR_OFFSET_OUT_OF_RANGE
+	default:
+		return nil, nil
+	}
+
+	records := make([]*kafka.Record, 0, getMessageResult.MessageBufferList.Len())
+	for e := getMessageResult.MessageBufferList.Front(); e != nil; e = e.Next() {
+		mappedByteBuffer, ok := e.Value.(*stgstorelog.MappedByteBuffer)
+		if !ok {
+			continue
+		}
+		msgBuffer := append([]byte(nil), mappedByteBuffer.Bytes()...)
+		msgExt, err := message.DecodeMessageExt(msgBuffer, true, true)
+		if err != nil || msgExt == nil {
+			logger.Errorf("kafka fetch decode message failed, topic=%s, queueId=%d, err: %v", topic, queueId, err)
+			continue
+		}
+		records = append(records, &kafka.Record{
+			Offset:    msgExt.QueueOffset,
+			Timestamp: msgExt.BornTimestamp,
+			Key:       kafkaKeyOf(msgExt),
+			Value:     msgExt.Body,
+		})
+	}
+	return records, nil
+}
+
+// kafkaKeyOf 消息的Kafka key，非Kafka写入的消息以消息key代替
+// Author agent
+// Since 2026/10/19
+func kafkaKeyOf(msgExt *message.MessageExt) []byte {
+	if encodedKey := msgExt.GetProperty(message.PROPERTY_KAFKA_KEY); encodedKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(encodedKey); err == nil {
+			return key
+		}
+	}
+	if keys := msgExt.GetKeys(); keys != "" {
+		return []byte(keys)
+	}
+	return nil
+}
+
+// GetMinOffset 队列最小位点
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetMinOffset(topic string, queueId int32) int64 {
+	if offset := backend.brokerController.MessageStore.GetMinOffsetInQueue(topic, queueId); offset > 0 {
+		return offset
+	}
+	return 0
+}
+
+// GetMaxOffset 队列最大位点
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetMaxOffset(topic string, queueId int32) int64 {
+	if offset := backend.brokerController.MessageStore.GetMaxOffsetInQueue(topic, queueId); offset > 0 {
+		return offset
+	}
+	return 0
+}
+
+// GetOffsetByTime 按存储时间查找队列位点
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) GetOffsetByTime(topic string, queueId int32, timestamp int64) int64 {
+	if offset := backend.brokerController.MessageStore.GetOffsetInQueueByTime(topic, queueId, timestamp); offset > 0 {
+		return offset
+	}
+	return 0
+}
+
+// CommitOffset 提交消费进度，其他broker上的队列转发给其master
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) CommitOffset(group string, mq *message.MessageQueue, brokerAddr string, offset int64) error {
+	if mq.BrokerName == backend.BrokerName() {
+		backend.brokerController.ConsumerOffsetManager.CommitOffset(group, mq.Topic, mq.QueueId, offset)
+		return nil
+	}
+	if brokerAddr == "" {
+		return kafka.ERR_COORDINATOR_NOT_AVAILABLE
+	}
+	requestHeader := &header.UpdateConsumerOffsetRequestHeader{
+		ConsumerGroup: group,
+		Topic:         mq.Topic,
+		QueueId:       mq.QueueId,
+		CommitOffset:  offset,
+	}
+	return backend.brokerController.BrokerOuterAPI.UpdateConsumerOffset(brokerAddr, requestHeader)
+}
+
+// QueryOffset 查询消费进度，其他broker上的队列向其master查询
+// Author agent
+// Since 2026/10/19
+func (backend *brokerKafkaBackend) QueryOffset(group string, mq *message.MessageQueue, brokerAddr string) (int64, error) {
+	if mq.BrokerName == backend.BrokerName() {
+		return backend.brokerController.ConsumerOffsetManager.QueryOffset(group, mq.Topic, mq.QueueId), nil
+	}
+	if brokerAddr == "" {
+		return -1, kafka.ERR_COORDINATOR_NOT_AVAILABLE
+	}
+	requestHeader := &header.QueryConsumerOffsetRequestHeader{
+		ConsumerGroup: group,
+		Topic:         mq.Topic,
+		QueueId:       int32(mq.QueueId),
+	}
+	return backend.brokerController.BrokerOuterAPI.QueryConsumerOffset(brokerAddr, requestHeader)
+}
@@ -0,0 +1,205 @@
+package stgbroker
+
+import (
+	"strconv"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker/stats"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/metrics"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+)
+
+// broker对外暴露的Prometheus指标
+//
+// 所有指标都带有 cluster、broker 两个label，名称与label一经发布不再修改：
+//
+//	smartgo_broker_topic_put_messages_total{topic}                     counter   topic写入消息条数
+//	smartgo_broker_topic_put_bytes_total{topic}                        counter   topic写入消息字节数
+//	smartgo_broker_group_get_messages_total{topic,group}               counter   消费组拉取消息条数
+//	smartgo_broker_group_get_bytes_total{topic,group}                  counter   消费组拉取消息字节数
+//	smartgo_broker_group_sendback_messages_total{topic,group}          counter   消费组回发重试消息条数
+//	smartgo_broker_group_disk_fall_behind_bytes{topic,group,queue_id}  gauge     消费组拉取时落后于内存的字节数
+//	smartgo_broker_consumer_backlog_messages{topic,group}              gauge     消费组在该broker上的堆积消息条数
+//	smartgo_broker_connections                                         gauge     remoting服务端当前连接数
+//	smartgo_broker_producer_connections{group}                         gauge     生产组连接数
+//	smartgo_broker_consumer_connections{group}                         gauge     消费组连接数
+//	smartgo_store_put_latency_milliseconds                             histogram 存储层写消息耗时(毫秒)
+//	smartgo_store_put_failed_total                                     counter   存储层写消息失败次数
+//	smartgo_store_commitlog_max_offset                                 gauge     commitlog最大物理偏移量
+//	smartgo_store_ha_connections                                       gauge     Master上的Slave连接数
+//	smartgo_store_ha_slave_lag_bytes{slave}                            gauge     Slave确认的偏移量落后于commitlog的字节数
+//
+// Author agent
+// Since 2026/10/19
+type BrokerMetricsCollector struct {
+	brokerController *BrokerController
+}
+
+// NewBrokerMetricsCollector 初始化broker指标收集器
+// Author agent
+// Since 2026/10/19
+func NewBrokerMetricsCollector(brokerController *BrokerController) *BrokerMetricsCollector {
+	return &BrokerMetricsCollector{brokerController: brokerController}
+}
+
+// Collect 收集broker指标
+// Author agent
+// Since 2026/10/19
+func (self *BrokerMetricsCollector) Collect() []*metrics.Family {
+	cluster := self.brokerController.BrokerConfig.BrokerClusterName
+	broker := self.brokerController.BrokerConfig.BrokerName
+	labels := func(kv ...string) []string {
+		return append([]string{"cluster", cluster, "broker", broker}, kv...)
+	}
+
+	families := make([]*metrics.Family, 0, 16)
+	families = append(families, self.collectStats(labels)...)
+	families = append(families, self.collectConnections(labels)...)
+	families = append(families, self.collectStore(labels)...)
+	return families
+}
+
+// collectStats 收集BrokerStatsManager中的累计统计
+// Author agent
+// Since 2026/10/19
+func (self *BrokerMetricsCollector) collectStats(labels func(kv ...string) []string) []*metrics.Family {
+	statsManager := self.brokerController.brokerStatsManager
+	if statsManager == nil {
+		return nil
+	}
+
+	topicPutNums := metrics.NewFamily("smartgo_broker_topic_put_messages_total", "Messages put into the topic.", metrics.COUNTER)
+	statsManager.ForeachStatsItem(stats.TOPIC_PUT_NUMS, func(topic string, value, times int64) {
+		topicPutNums.Add(float64(value), labels("topic", topic)...)
+	})
+
+	topicPutSize := metrics.NewFamily("smartgo_broker_topic_put_bytes_total", "Bytes put into the topic.", metrics.COUNTER)
+	statsManager.ForeachStatsItem(stats.TOPIC_PUT_SIZE, func(topic string, value, times int64) {
+		topicPutSize.Add(float64(value), labels("topic", topic)...)
+	})
+
+	groupFamily := func(statsName, name, help string) *metrics.Family {
+		family := metrics.NewFamily(name, help, metrics.COUNTER)
+		statsManager.ForeachStatsItem(statsName, func(statsKey string, value, times int64) {
+			if topic, group, ok := splitTopicAtGroup(statsKey); ok {
+				family.Add(float64(value), labels("topic", topic, "group", group)...)
+			}
+		})
+		return family
+	}
+
+	fallBehind := metrics.NewFamily("smartgo_broker_group_disk_fall_behind_bytes", "Bytes the consumer group lags behind memory when pulling from disk.", metrics.GAUGE)
+	statsManager.ForeachDiskFallBehind(func(group, topic string, queueId int32, value int64) {
+		fallBehind.Add(float64(value), labels("topic", topic, "group", group, "queue_id", strconv.Itoa(int(queueId)))...)
+	})
+
+	return []*metrics.Family{
+		topicPutNums,
+		topicPutSize,
+		groupFamily(stats.GROUP_GET_NUMS, "smartgo_broker_group_get_messages_total", "Messages pulled by the consumer group."),
+		groupFamily(stats.GROUP_GET_SIZE, "smartgo_broker_group_get_bytes_total", "Bytes pulled by the consumer group."),
+		groupFamily(stats.SNDBCK_PUT_NUMS, "smartgo_broker_group_sendback_messages_total", "Messages sent back for retry by the consumer group."),
+		fallBehind,
+	}
+}
+
+// collectConnections 收集连接数以及消费堆积
+// Author agent
+// Since 2026/10/19
+func (self *BrokerMetricsCollector) collectConnections(labels func(kv ...string) []string) []*metrics.Family {
+	families := make([]*metrics.Family, 0, 4)
+
+	if self.brokerController.RemotingServer != nil {
+		connections := metrics.NewFamily("smartgo_broker_connections", "Connections accepted by the broker remoting server.", metrics.GAUGE)
+		connections.Add(float64(self.brokerController.RemotingServer.ConnectionCount()), labels()...)
+		families = append(families, connections)
+	}
+
+	producers := metrics.NewFamily("smartgo_broker_producer_connections", "Producer connections per producer group.", metrics.GAUGE)
+	for group, channels := range self.brokerController.ProducerManager.ChannelViews() {
+		producers.Add(float64(len(channels)), labels("group", group)...)
+	}
+
+	consumers := metrics.NewFamily("smartgo_broker_consumer_connections", "Consumer connections per consumer group.", metrics.GAUGE)
+	for group, channels := range self.brokerController.ConsumerManager.ChannelViews() {
+		consumers.Add(float64(len(channels)), labels("group", group)...)
+	}
+	families = append(families, producers, consumers)
+
+	if self.brokerController.MessageStore != nil {
+		backlog := metrics.NewFamily("smartgo_broker_consumer_backlog_messages", "Messages not yet consumed by the consumer group on this broker.", metrics.GAUGE)
+		for topicAtGroup, offsets := range self.brokerController.ConsumerOffsetManager.CloneAllOffsets() {
+			topic, group, ok := splitTopicAtGroup(topicAtGroup)
+			if !ok {
+				continue
+			}
+			total := int64(0)
+			for queueId, offset := range offsets {
+				maxOffset := self.brokerController.MessageStore.GetMaxOffsetInQueue(topic, int32(queueId))
+				if diff := maxOffset - offset; diff > 0 {
+					total += diff
+				}
+			}
+			backlog.Add(float64(total), labels("topic", topic, "group", group)...)
+		}
+		families = append(families, backlog)
+	}
+	return families
+}
+
+// collectStore 收集存储层指标
+// Author agent
+// Since 2026/10/19
+func (self *BrokerMetricsCollector) collectStore(labels func(kv ...string) []string) []*metrics.Family {
+	messageStore := self.brokerController.MessageStore
+	if messageStore == nil {
+		return nil
+	}
+	families := make([]*metrics.Family, 0, 5)
+
+	if storeStats := messageStore.StoreStatsService; storeStats != nil {
+		// 存储层按整毫秒统计且区间上界不含，le取上界-1才与Prometheus的<=语义一致
+		bounds := make([]float64, len(stgstorelog.PutMessageDistributeTimeBounds))
+		for i, bound := range stgstorelog.PutMessageDistributeTimeBounds {
+			bounds[i] = float64(bound - 1)
+		}
+		latency := metrics.NewFamily("smartgo_store_put_latency_milliseconds", "Latency of putting a message into the store in milliseconds.", metrics.HISTOGRAM)
+		latency.AddHistogram(bounds, storeStats.GetPutMessageDistributeTime(), float64(storeStats.GetPutMessageEntireTimeTotal()), labels()...)
+
+		failed := metrics.NewFamily("smartgo_store_put_failed_total", "Failed puts into the store.", metrics.COUNTER)
+		failed.Add(float64(storeStats.GetPutMessageFailedTimes()), labels()...)
+		families = append(families, latency, failed)
+	}
+
+	flushInfo := messageStore.FlushInfo()
+	maxOffset := metrics.NewFamily("smartgo_store_commitlog_max_offset", "Max physical offset of the commit log.", metrics.GAUGE)
+	maxOffset.Add(float64(flushInfo.CommitLogMaxOffset), labels()...)
+	families = append(families, maxOffset)
+
+	if haInfo := messageStore.HAServiceInfo(); haInfo != nil {
+		haConnections := metrics.NewFamily("smartgo_store_ha_connections", "Slave connections accepted by the master.", metrics.GAUGE)
+		haConnections.Add(float64(haInfo.ConnectionCount), labels()...)
+
+		lag := metrics.NewFamily("smartgo_store_ha_slave_lag_bytes", "Bytes the slave ack offset lags behind the commit log.", metrics.GAUGE)
+		for _, conn := range haInfo.Connections {
+			value := flushInfo.CommitLogMaxOffset - conn.SlaveAckOffset
+			if conn.SlaveAckOffset < 0 || value < 0 {
+				value = flushInfo.CommitLogMaxOffset
+			}
+			lag.Add(float64(value), labels("slave", conn.ClientAddress)...)
+		}
+		families = append(families, haConnections, lag)
+	}
+	return families
+}
+
+// splitTopicAtGroup 拆分 topic@group
+// Author agent
+// Since 2026/10/19
+func splitTopicAtGroup(topicAtGroup string) (string, string, bool) {
+	index := strings.Index(topicAtGroup, TOPIC_GROUP_SEPARATOR)
+	if index <= 0 || index >= len(topicAtGroup)-len(TOPIC_GROUP_SEPARATOR) {
+		return "", "", false
+	}
+	return topicAtGroup[:index], topicAtGroup[index+len(TOPIC_GROUP_SEPARATOR):], true
+}
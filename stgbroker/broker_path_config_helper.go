@@ -23,9 +23,44 @@ func GetConsumerOffsetPath(rootDir string) string {
 	return rootDir + separator + configDir + separator + "consumerOffset.json"
 }
 
+// GetConsumerOffsetSnapshotPath 获取consumerOffset.snapshot路径(消费进度二进制快照)
+// Author agent
+// Since 2026/10/19
+func GetConsumerOffsetSnapshotPath(rootDir string) string {
+	return rootDir + separator + configDir + separator + "consumerOffset.snapshot"
+}
+
+// GetConsumerOffsetJournalPath 获取consumerOffset.journal路径(消费进度提交日志)
+// Author agent
+// Since 2026/10/19
+func GetConsumerOffsetJournalPath(rootDir string) string {
+	return rootDir + separator + configDir + separator + "consumerOffset.journal"
+}
+
 // GetSubscriptionGroupPath 获取subscriptionGroup.json路径
 // Author gaoyanlei
 // Since 2017/8/21
 func GetSubscriptionGroupPath(rootDir string) string {
 	return rootDir + separator + configDir + separator + "subscriptionGroup.json"
 }
+
+// GetQuotaConfigPath 获取quota.json路径
+// Author agent
+// Since 2026/10/19
+func GetQuotaConfigPath(rootDir string) string {
+	return rootDir + separator + configDir + separator + "quota.json"
+}
+
+// GetPopCheckpointPath 获取popCheckpoint.json路径
+// Author agent
+// Since 2026/10/19
+func GetPopCheckpointPath(rootDir string) string {
+	return rootDir + separator + configDir + separator + "popCheckpoint.json"
+}
+
+// GetDrainReportPath 获取drainReport.json路径
+// Author agent
+// Since 2026/10/19
+func GetDrainReportPath(rootDir string) string {
+	return rootDir + separator + configDir + separator + "drainReport.json"
+}
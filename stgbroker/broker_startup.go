@@ -89,10 +89,22 @@ func CreateBrokerController(smartgoBrokerFilePath ...string) *BrokerController {
 		os.Exit(0)
 	}
 
+	// 动态修改broker配置时写入toml的配置项，覆盖默认值
+	if err = applyConfigValues(brokerConfig, cfg.BrokerConfigOverrides); err != nil {
+		logger.Errorf("apply [brokerConfig] of %s err: %s", cfgPath, err.Error())
+		logger.Flush()
+		os.Exit(0)
+	}
+	if err = applyConfigValues(messageStoreConfig, cfg.MessageStoreConfigOverrides); err != nil {
+		logger.Errorf("apply [messageStoreConfig] of %s err: %s", cfgPath, err.Error())
+		logger.Flush()
+		os.Exit(0)
+	}
+
 	// 构建BrokerController结构体
 	remotingClient := remoting.NewDefalutRemotingClient()
 	controller := NewBrokerController(brokerConfig, messageStoreConfig, remotingClient)
-	controller.ConfigFile = brokerConfig.StorePathRootDir
+	controller.ConfigFile = cfgPath
 
 	logger.Info("create broker controller successful")
 	return controller
@@ -49,6 +49,20 @@ func (b2c *Broker2Client) notifyConsumerIdsChanged(ctx netm.Context, consumerGro
 	b2c.BrokerController.RemotingServer.InvokeOneway(ctx, request, 10)
 }
 
+// NotifyBrokerDraining 通知客户端当前Broker正在下线，客户端收到后立即从namesrv更新路由，Oneway
+// Author agent
+// Since 2026/10/19
+func (b2c *Broker2Client) NotifyBrokerDraining(ctx netm.Context) {
+	defer utils.RecoveredFn()
+	requestHeader := &header.NotifyBrokerDrainingRequestHeader{
+		BrokerName: b2c.BrokerController.BrokerConfig.BrokerName,
+		BrokerAddr: b2c.BrokerController.GetBrokerAddr(),
+	}
+	request := protocol.CreateRequestCommand(code.NOTIFY_BROKER_DRAINING, requestHeader)
+	request.MarkOnewayRPC()
+	b2c.BrokerController.RemotingServer.InvokeOneway(ctx, request, 100)
+}
+
 // CheckProducerTransactionState Broker主动回查Producer事务状态，Oneway
 // Author rongzhihong
 // Since 2017/9/11
@@ -61,6 +75,15 @@ func (b2c *Broker2Client) CheckProducerTransactionState(channel netm.Context, re
 	selectMapedBufferResult.Release()
 }
 
+// PushReplyMessage 将应答消息直接推送给请求方，不经过存储
+// Author agent
+// Since 2026/10/19
+func (b2c *Broker2Client) PushReplyMessage(ctx netm.Context, requestHeader *header.ReplyMessageRequestHeader, msgBody []byte) (*protocol.RemotingCommand, error) {
+	request := protocol.CreateRequestCommand(code.PUSH_REPLY_MESSAGE_TO_CLIENT, requestHeader)
+	request.Body = msgBody
+	return b2c.BrokerController.RemotingServer.InvokeSync(ctx, request, 3000)
+}
+
 // CallClient 调用客户端
 // Author rongzhihong
 // Since 2017/9/18
@@ -0,0 +1,203 @@
+package stgbroker
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgbroker/mqtrace"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/trace"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+)
+
+const (
+	brokerTraceHookName = "BrokerTraceHook"
+)
+
+// BrokerTraceHook broker端消息轨迹：记录消息存储、客户端拉取、消费确认及消费失败回传
+// Author agent
+// Since 2026/10/19
+type BrokerTraceHook struct {
+	brokerController *BrokerController
+	dispatcher       *trace.TraceDispatcher
+}
+
+// NewBrokerTraceHook 初始化broker端消息轨迹回调，轨迹异步批量写入本broker的轨迹topic
+// Author agent
+// Since 2026/10/19
+func NewBrokerTraceHook(brokerController *BrokerController) *BrokerTraceHook {
+	hook := &BrokerTraceHook{brokerController: brokerController}
+	sender := &brokerTraceSender{brokerController: brokerController}
+	hook.dispatcher = trace.NewTraceDispatcher(sender, trace.DEFAULT_TRACE_QUEUE_SIZE, trace.DEFAULT_TRACE_BATCH_SIZE, trace.DEFAULT_TRACE_FLUSH_INTERVAL)
+	return hook
+}
+
+// Start 启动轨迹异步发送
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) Start() {
+	hook.dispatcher.Start()
+}
+
+// Shutdown 停止轨迹异步发送，未发送的轨迹会在停止前写入
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) Shutdown() {
+	hook.dispatcher.Shutdown()
+	logger.Infof("%s shutdown successful, discard trace count: %d", brokerTraceHookName, hook.dispatcher.DiscardCount())
+}
+
+// HookName 回调名称
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) HookName() string {
+	return brokerTraceHookName
+}
+
+// SendMessageBefore 存储消息前不记录轨迹
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) SendMessageBefore(context *mqtrace.SendMessageContext) {
+}
+
+// SendMessageAfter 记录消息存储轨迹
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) SendMessageAfter(context *mqtrace.SendMessageContext) {
+	if context == nil || context.MsgId == "" || hook.isTraceTopic(context.Topic) {
+		return
+	}
+
+	properties := message.String2messageProperties(context.MsgProps)
+	record := &trace.TraceRecord{
+		TraceType:   trace.TRACE_STORE,
+		Timestamp:   timeutil.CurrentTimeMillis(),
+		Group:       context.ProducerGroup,
+		Topic:       context.Topic,
+		MsgId:       context.MsgId,
+		Keys:        properties[message.PROPERTY_KEYS],
+		Tags:        properties[message.PROPERTY_TAGS],
+		ClientHost:  context.BornHost,
+		StoreHost:   context.BrokerAddr,
+		QueueId:     context.QueueId,
+		QueueOffset: context.QueueOffset,
+		StoreTime:   timeutil.CurrentTimeMillis(),
+		BodyLength:  context.BodyLength,
+		Success:     context.Code == int(code.SUCCESS),
+		Status:      context.ErrorMsg,
+	}
+	if record.Success {
+		record.Status = "SEND_OK"
+	}
+	hook.dispatcher.Append(record)
+}
+
+// ConsumeMessageBefore 记录客户端拉取消息轨迹
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) ConsumeMessageBefore(context *mqtrace.ConsumeMessageContext) {
+	hook.appendConsumeTrace(trace.TRACE_PULL, context)
+}
+
+// ConsumeMessageAfter 记录消费确认(提交offset)或消费失败回传的轨迹
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) ConsumeMessageAfter(context *mqtrace.ConsumeMessageContext) {
+	if context != nil && context.Success {
+		hook.appendConsumeTrace(trace.TRACE_ACK, context)
+		return
+	}
+	hook.appendConsumeTrace(trace.TRACE_RECONSUME, context)
+}
+
+// appendConsumeTrace 一次拉取或确认可能包含多条消息，每条消息记录一个轨迹节点
+// Author agent
+// Since 2026/10/19
+func (hook *BrokerTraceHook) appendConsumeTrace(traceType trace.TraceType, context *mqtrace.ConsumeMessageContext) {
+	if context == nil || len(context.MessageIds) == 0 || hook.isTraceTopic(context.Topic) {
+		return
+	}
+
+	storeHost := context.StoreHost
+	if storeHost == "" {
+		storeHost = hook.brokerController.GetBrokerAddr()
+	}
+	now := timeutil.CurrentTimeMillis()
+	for msgId, offset := range context.MessageIds {
+		record := &trace.TraceRecord{
+			TraceType:   traceType,
+			Timestamp:   now,
+			Group:       context.ConsumerGroup,
+			Topic:       context.Topic,
+			MsgId:       msgId,
+			ClientHost:  context.ClientHost,
+			StoreHost:   storeHost,
+			QueueId:     context.QueueId,
+			QueueOffset: offset,
+			BodyLength:  context.BodyLength,
+			Success:     context.Success || traceType == trace.TRACE_PULL,
+			Status:      context.Status,
+		}
+		hook.dispatcher.Append(record)
+	}
+}
+
+func (hook *BrokerTraceHook) isTraceTopic(topic string) bool {
+	return topic == hook.brokerController.BrokerConfig.MsgTraceTopicName
+}
+
+// brokerTraceSender 将轨迹批量写入本broker的轨迹topic，msgId、业务key作为消息key建立索引
+// Author agent
+// Since 2026/10/19
+type brokerTraceSender struct {
+	brokerController *BrokerController
+}
+
+// SendTrace 写入一批轨迹
+// Author agent
+// Since 2026/10/19
+func (sender *brokerTraceSender) SendTrace(records []*trace.TraceRecord) error {
+	body, err := trace.EncodeTraceRecords(records)
+	if err != nil {
+		return err
+	}
+
+	msgInner := new(stgstorelog.MessageExtBrokerInner)
+	msgInner.Topic = sender.brokerController.BrokerConfig.MsgTraceTopicName
+	msgInner.SetKeys(trace.BuildTraceKeys(records))
+	msgInner.Body = body
+	msgInner.Flag = 0
+	msgInner.PropertiesString = message.MessageProperties2String(msgInner.Properties)
+	msgInner.TagsCode = 0
+
+	msgInner.QueueId = int32(0)
+	msgInner.SysFlag = sysflag.TransactionNotType
+	msgInner.BornTimestamp = timeutil.CurrentTimeMillis()
+	msgInner.BornHost = sender.brokerController.GetBrokerAddr()
+	msgInner.StoreHost = msgInner.BornHost
+	msgInner.ReconsumeTimes = 0
+
+	putMessageResult := sender.brokerController.MessageStore.PutMessage(msgInner)
+	if putMessageResult == nil || putMessageResult.PutMessageStatus != stgstorelog.PUTMESSAGE_PUT_OK {
+		return fmt.Errorf("put trace message to topic %s failed, %v", msgInner.Topic, putMessageResult)
+	}
+	return nil
+}
+
+// registerTraceHook 开启消息轨迹时注册broker端轨迹回调，须在registerProcessor()之前调用
+// Author agent
+// Since 2026/10/19
+func (self *BrokerController) registerTraceHook() {
+	if !self.BrokerConfig.TraceTopicEnable || self.traceHook != nil {
+		return
+	}
+	if self.BrokerConfig.MsgTraceTopicName == "" {
+		self.BrokerConfig.MsgTraceTopicName = stgcommon.SYS_TRACE_TOPIC
+	}
+	self.traceHook = NewBrokerTraceHook(self)
+	self.RegisterSendMessageHook(self.traceHook)
+	self.RegisterConsumeMessageHook(self.traceHook)
+}
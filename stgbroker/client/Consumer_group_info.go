@@ -57,9 +57,16 @@ func (cg *ConsumerGroupInfo) FindSubscriptionData(topic string) *heartbeat.Subsc
 			SUB_ALL:         sdPlus.SUB_ALL,
 			SubString:       sdPlus.SubString,
 			SubVersion:      sdPlus.SubVersion,
-			TagsSet:         set.NewSet(sdPlus.TagsSet),
-			CodeSet:         set.NewSet(sdPlus.CodeSet),
+			TagsSet:         set.NewSet(),
+			CodeSet:         set.NewSet(),
 			ClassFilterMode: sdPlus.ClassFilterMode,
+			ExpressionType:  sdPlus.ExpressionType,
+		}
+		for _, tag := range sdPlus.TagsSet {
+			subscriptionData.TagsSet.Add(tag)
+		}
+		for _, code := range sdPlus.CodeSet {
+			subscriptionData.CodeSet.Add(int64(code))
 		}
 		return subscriptionData
 	}
@@ -232,6 +239,20 @@ func (cg *ConsumerGroupInfo) FindChannel(clientId string) *ChannelInfo {
 	return nil
 }
 
+// FindClientId 根据连接查找客户端ID，找不到返回空串
+// Author agent
+// Since 2026/10/19
+func (cg *ConsumerGroupInfo) FindClientId(ctx netm.Context) string {
+	value, err := cg.ConnTable.Get(ctx.Addr())
+	if err != nil || value == nil {
+		return ""
+	}
+	if info, ok := value.(*ChannelInfo); ok {
+		return info.ClientId
+	}
+	return ""
+}
+
 // SubscriptionTableToMap SubscriptionTable To Map
 // Author rongzhihong
 // Since 2017/9/17
@@ -30,3 +30,34 @@ func (info *ChannelInfo) ToString() string {
 	format := "client channel info {%s, clientId=%s, language=%s, version=%d, lastUpdateTimestamp=%d }"
 	return fmt.Sprintf(format, info.Context.ToString(), info.ClientId, info.LanguageCode, info.Version, info.LastUpdateTimestamp)
 }
+
+// ChannelView 客户端通道的只读视图，用于调试接口输出
+// Author agent
+// Since 2026/10/19
+type ChannelView struct {
+	ClientId            string `json:"clientId"`
+	LanguageCode        string `json:"languageCode"`
+	Addr                string `json:"addr"`
+	RemoteAddr          string `json:"remoteAddr"`
+	Version             int32  `json:"version"`
+	LastUpdateTimestamp int64  `json:"lastUpdateTimestamp"`
+	Closed              bool   `json:"closed"`
+}
+
+// ToView 构建通道只读视图
+// Author agent
+// Since 2026/10/19
+func (info *ChannelInfo) ToView() *ChannelView {
+	view := &ChannelView{
+		ClientId:            info.ClientId,
+		LanguageCode:        info.LanguageCode,
+		Addr:                info.Addr,
+		Version:             info.Version,
+		LastUpdateTimestamp: info.LastUpdateTimestamp,
+	}
+	if info.Context != nil {
+		view.RemoteAddr = info.Context.RemoteAddr().String()
+		view.Closed = info.Context.IsClosed()
+	}
+	return view
+}
@@ -213,3 +213,69 @@ func (cm *ConsumerManager) FindChannel(group, clientId string) *ChannelInfo {
 	}
 	return nil
 }
+
+// ChannelViews 获得所有消费组的客户端通道只读视图
+// Author agent
+// Since 2026/10/19
+func (cm *ConsumerManager) ChannelViews() map[string][]*ChannelView {
+	views := make(map[string][]*ChannelView)
+	for iterator := cm.consumerTable.Iterator(); iterator.HasNext(); {
+		key, value, _ := iterator.Next()
+		group, ok := key.(string)
+		if !ok {
+			continue
+		}
+		consumerGroupInfo, ok := value.(*ConsumerGroupInfo)
+		if !ok {
+			continue
+		}
+
+		channels := make([]*ChannelView, 0)
+		for chanIterator := consumerGroupInfo.ConnTable.Iterator(); chanIterator.HasNext(); {
+			_, clientValue, _ := chanIterator.Next()
+			if channelInfo, ok := clientValue.(*ChannelInfo); ok {
+				channels = append(channels, channelInfo.ToView())
+			}
+		}
+		views[group] = channels
+	}
+	return views
+}
+
+// FindChannelByClientId 根据clientId查找任意消费组中的客户端通道，找不到返回nil
+// Author agent
+// Since 2026/10/19
+func (cm *ConsumerManager) FindChannelByClientId(clientId string) *ChannelInfo {
+	for iterator := cm.consumerTable.Iterator(); iterator.HasNext(); {
+		_, value, _ := iterator.Next()
+		if consumerGroupInfo, ok := value.(*ConsumerGroupInfo); ok {
+			if channelInfo := consumerGroupInfo.FindChannel(clientId); channelInfo != nil {
+				return channelInfo
+			}
+		}
+	}
+	return nil
+}
+
+// AllChannels 获得所有消费组的客户端通道，同一个clientId只返回一次
+// Author agent
+// Since 2026/10/19
+func (cm *ConsumerManager) AllChannels() []*ChannelInfo {
+	channels := make([]*ChannelInfo, 0)
+	clientIds := make(map[string]bool)
+	for iterator := cm.consumerTable.Iterator(); iterator.HasNext(); {
+		_, value, _ := iterator.Next()
+		consumerGroupInfo, ok := value.(*ConsumerGroupInfo)
+		if !ok {
+			continue
+		}
+		for chanIterator := consumerGroupInfo.ConnTable.Iterator(); chanIterator.HasNext(); {
+			_, clientValue, _ := chanIterator.Next()
+			if channelInfo, ok := clientValue.(*ChannelInfo); ok && !clientIds[channelInfo.ClientId] {
+				clientIds[channelInfo.ClientId] = true
+				channels = append(channels, channelInfo)
+			}
+		}
+	}
+	return channels
+}
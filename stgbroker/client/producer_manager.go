@@ -240,3 +240,76 @@ func get(lst *list.List, index int) interface{} {
 	}
 	return nil
 }
+
+// ChannelViews 获得所有生产组的客户端通道只读视图
+// Author agent
+// Since 2026/10/19
+func (pm *ProducerManager) ChannelViews() map[string][]*ChannelView {
+	views := make(map[string][]*ChannelView)
+	pm.GroupChannelLock.RLock()
+	defer pm.GroupChannelLock.RUnlock()
+
+	pm.GroupChannelTable.foreach(func(group string, channelTable map[string]*ChannelInfo) {
+		channels := make([]*ChannelView, 0, len(channelTable))
+		for _, channelInfo := range channelTable {
+			channels = append(channels, channelInfo.ToView())
+		}
+		views[group] = channels
+	})
+	return views
+}
+
+// AllChannels 获得所有生产组的客户端通道，同一个clientId只返回一次
+// Author agent
+// Since 2026/10/19
+func (pm *ProducerManager) AllChannels() []*ChannelInfo {
+	pm.GroupChannelLock.RLock()
+	defer pm.GroupChannelLock.RUnlock()
+
+	channels := make([]*ChannelInfo, 0)
+	clientIds := make(map[string]bool)
+	pm.GroupChannelTable.foreach(func(group string, channelTable map[string]*ChannelInfo) {
+		for _, channelInfo := range channelTable {
+			if !clientIds[channelInfo.ClientId] {
+				clientIds[channelInfo.ClientId] = true
+				channels = append(channels, channelInfo)
+			}
+		}
+	})
+	return channels
+}
+
+// FindChannel 根据clientId查找任意producer组中的客户端通道，找不到返回nil
+// Author agent
+// Since 2026/10/19
+func (pm *ProducerManager) FindChannel(clientId string) *ChannelInfo {
+	pm.GroupChannelLock.RLock()
+	defer pm.GroupChannelLock.RUnlock()
+
+	var found *ChannelInfo
+	pm.GroupChannelTable.foreach(func(group string, channelTable map[string]*ChannelInfo) {
+		for _, channelInfo := range channelTable {
+			if found == nil && channelInfo.ClientId == clientId {
+				found = channelInfo
+			}
+		}
+	})
+	return found
+}
+
+// FindClientId 根据生产组及连接查找客户端ID，找不到返回空串
+// Author agent
+// Since 2026/10/19
+func (pm *ProducerManager) FindClientId(group string, ctx netm.Context) string {
+	pm.GroupChannelLock.RLock()
+	defer pm.GroupChannelLock.RUnlock()
+
+	channelTable := pm.GroupChannelTable.Get(group)
+	if channelTable == nil {
+		return ""
+	}
+	if channelInfo, ok := channelTable[ctx.Addr()]; ok && channelInfo != nil {
+		return channelInfo.ClientId
+	}
+	return ""
+}
@@ -11,3 +11,19 @@ func TestNewClientConfig(t *testing.T) {
 		fmt.Println(producerManager.generateRandmonNum())
 	}
 }
+
+func TestAllChannels(t *testing.T) {
+	producerManager := NewProducerManager()
+	producerManager.GroupChannelTable.Put("groupA", map[string]*ChannelInfo{
+		"10.0.0.1:1001": {ClientId: "client-1"},
+		"10.0.0.2:1002": {ClientId: "client-2"},
+	})
+	producerManager.GroupChannelTable.Put("groupB", map[string]*ChannelInfo{
+		"10.0.0.1:1001": {ClientId: "client-1"},
+	})
+
+	channels := producerManager.AllChannels()
+	if len(channels) != 2 {
+		t.Fatalf("AllChannels should dedupe by clientId, got %d channels", len(channels))
+	}
+}
@@ -1,11 +1,13 @@
 package stgbroker
 
 import (
+	"fmt"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/client"
 	"git.oschina.net/cloudzone/smartgo/stgbroker/mqtrace"
 	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/filter"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
@@ -58,6 +60,15 @@ func (cmp *ClientManageProcessor) heartBeat(ctx netm.Context, request *protocol.
 	heartbeatDataPlus.Decode(request.Body)
 	consumerDataSet := heartbeatDataPlus.ConsumerDataSet
 	channelInfo := client.NewClientChannelInfo(ctx, heartbeatDataPlus.ClientID, request.Language, ctx.Addr(), request.Version)
+
+	// 校验订阅表达式，语法错误的订阅不注册
+	if err := cmp.checkSubscriptionExpression(consumerDataSet); err != nil {
+		logger.Warnf("heartbeat subscription invalid, RemoteAddr:%s, %s", ctx.RemoteAddr().String(), err.Error())
+		response.Code = code.SUBSCRIPTION_PARSE_FAILED
+		response.Remark = err.Error()
+		return response, nil
+	}
+
 	for _, consumerData := range consumerDataSet {
 		subscriptionGroupConfig :=
 			cmp.BrokerController.SubscriptionGroupManager.FindSubscriptionGroupConfig(consumerData.GroupName)
@@ -91,6 +102,26 @@ func (cmp *ClientManageProcessor) heartBeat(ctx netm.Context, request *protocol.
 	return response, nil
 }
 
+// checkSubscriptionExpression 校验心跳中的订阅表达式
+// Author agent
+// Since 2026/10/19
+func (cmp *ClientManageProcessor) checkSubscriptionExpression(consumerDataSet []heartbeat.ConsumerDataPlus) error {
+	for _, consumerData := range consumerDataSet {
+		for _, sub := range consumerData.SubscriptionDataSet {
+			if filter.IsTagType(sub.ExpressionType) {
+				continue
+			}
+			if !filter.IsSql92Type(sub.ExpressionType) {
+				return fmt.Errorf("group: %s topic: %s unsupported expression type: %s", consumerData.GroupName, sub.Topic, sub.ExpressionType)
+			}
+			if _, err := filter.Compile(sub.SubString); err != nil {
+				return fmt.Errorf("group: %s topic: %s %s", consumerData.GroupName, sub.Topic, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
 // unregisterClient 注销客户端
 // Author gaoyanlei
 // Since 2017/8/24
@@ -1,8 +1,10 @@
 package stgbroker
 
 import (
+	"git.oschina.net/cloudzone/smartgo/stgbroker/offsetstore"
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	syncmap "git.oschina.net/cloudzone/smartgo/stgcommon/sync"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	set "github.com/deckarep/golang-set"
 	"github.com/pquerna/ffjson/ffjson"
@@ -16,6 +18,8 @@ const (
 )
 
 // ConsumerOffsetManager Consumer消费进度管理
+// 消费进度以二进制快照 + 提交日志的方式存储，每次提交都会追加到日志并按批fsync，日志过大时合并到快照；
+// consumerOffset.json只用于导入旧数据以及停机时导出给运维工具
 // Author gaoyanlei
 // Since 2017/8/9
 type ConsumerOffsetManager struct {
@@ -23,7 +27,9 @@ type ConsumerOffsetManager struct {
 	Offsets               *OffsetTable
 	BrokerController      *BrokerController
 	configManagerExt      *ConfigManagerExt
+	offsetStore           *offsetstore.OffsetStore
 	persistLock           sync.RWMutex
+	compactLock           sync.Mutex
 }
 
 // NewConsumerOffsetManager 初始化ConsumerOffsetManager
@@ -38,8 +44,39 @@ func NewConsumerOffsetManager(brokerController *BrokerController) *ConsumerOffse
 	return consumerOffsetManager
 }
 
+// Load 从快照 + 提交日志重建消费进度；快照与日志都不存在时从consumerOffset.json导入
+// Author agent
+// Since 2026/10/19
 func (com *ConsumerOffsetManager) Load() bool {
-	return com.configManagerExt.Load()
+	rootDir := com.rootDir()
+	com.offsetStore = offsetstore.NewOffsetStore(GetConsumerOffsetSnapshotPath(rootDir), GetConsumerOffsetJournalPath(rootDir))
+	importJson := !com.offsetStore.Exists()
+
+	table, err := com.offsetStore.Load()
+	if err != nil {
+		logger.Errorf("load consumer offset store err: %s", err.Error())
+		return false
+	}
+
+	if importJson {
+		if !com.configManagerExt.Load() {
+			return false
+		}
+		if err = com.compact(); err != nil {
+			logger.Errorf("import consumer offset from %s err: %s", com.ConfigFilePath(), err.Error())
+			return false
+		}
+		logger.Infof("import consumer offset from %s OK, size=%d", com.ConfigFilePath(), com.Offsets.Size())
+	} else {
+		com.persistLock.Lock()
+		com.Offsets.Lock()
+		com.Offsets.Offsets = table
+		com.Offsets.Unlock()
+		com.persistLock.Unlock()
+	}
+
+	com.offsetStore.Start(com.BrokerController.BrokerConfig.OffsetJournalFlushInterval)
+	return true
 }
 
 func (com *ConsumerOffsetManager) Encode(prettyFormat bool) string {
@@ -64,18 +101,22 @@ func (com *ConsumerOffsetManager) Decode(buf []byte) {
 }
 
 func (com *ConsumerOffsetManager) ConfigFilePath() string {
+	return GetConsumerOffsetPath(com.rootDir())
+}
+
+func (com *ConsumerOffsetManager) rootDir() string {
 	homeDir := stgcommon.GetUserHomeDir()
 	if com.BrokerController.BrokerConfig.StorePathRootDir != "" {
 		homeDir = com.BrokerController.BrokerConfig.StorePathRootDir
 	}
-	return GetConsumerOffsetPath(homeDir)
+	return homeDir
 }
 
 // ScanUnsubscribedTopic 扫描被删除Topic，并删除该Topic对应的Offset
 // Author gaoyanlei
 // Since 2017/8/22
 func (self *ConsumerOffsetManager) ScanUnsubscribedTopic() {
-	self.Offsets.RemoveByFlag(func(k string, v map[int]int64) bool {
+	self.removeByFlag(func(k string, v map[int]int64) bool {
 		arrays := strings.Split(k, TOPIC_GROUP_SEPARATOR)
 		if arrays == nil || len(arrays) != 2 {
 			return false
@@ -138,6 +179,53 @@ func (com *ConsumerOffsetManager) commitOffset(key string, queueId int, offset i
 	} else {
 		value[queueId] = offset
 	}
+	com.appendRecord(&offsetstore.OffsetRecord{Type: offsetstore.RECORD_COMMIT, Key: key, QueueId: queueId, Offset: offset})
+}
+
+// removeByFlag 删除满足条件的topic@group消费进度，并记录到提交日志
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) removeByFlag(fn func(k string, v map[int]int64) bool) {
+	com.persistLock.Lock()
+	defer com.persistLock.Unlock()
+
+	com.Offsets.RemoveByFlag(func(k string, v map[int]int64) bool {
+		if fn(k, v) {
+			com.appendRecord(&offsetstore.OffsetRecord{Type: offsetstore.RECORD_REMOVE, Key: k})
+			return true
+		}
+		return false
+	})
+}
+
+// appendRecord 追加到提交日志，调用方需要持有persistLock，保证日志顺序与内存中的修改顺序一致
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) appendRecord(record *offsetstore.OffsetRecord) {
+	if com.offsetStore != nil {
+		com.offsetStore.Append(record)
+	}
+}
+
+// CloneAllOffsets 克隆所有 topic@group 的消费进度
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) CloneAllOffsets() map[string]map[int]int64 {
+	com.persistLock.RLock()
+	defer com.persistLock.RUnlock()
+	return com.cloneAllOffsets()
+}
+
+func (com *ConsumerOffsetManager) cloneAllOffsets() map[string]map[int]int64 {
+	result := make(map[string]map[int]int64)
+	com.Offsets.Foreach(func(topicAtGroup string, v map[int]int64) {
+		offsets := make(map[int]int64, len(v))
+		for queueId, offset := range v {
+			offsets[queueId] = offset
+		}
+		result[topicAtGroup] = offsets
+	})
+	return result
 }
 
 // offsetBehindMuchThanData 检查偏移量与数据是否相差很大
@@ -197,9 +285,32 @@ func (com *ConsumerOffsetManager) WhichGroupByTopic(topic string) set.Set {
 // Author rongzhihong
 // Since 2017/9/18
 func (com *ConsumerOffsetManager) CloneOffset(srcGroup, destGroup, topic string) {
+	com.persistLock.Lock()
+	defer com.persistLock.Unlock()
+
 	offsets := com.Offsets.Get(topic + TOPIC_GROUP_SEPARATOR + srcGroup)
 	if offsets != nil {
-		com.Offsets.Put(topic+TOPIC_GROUP_SEPARATOR+destGroup, offsets)
+		// 复制一份，避免两个消费组共用同一个map
+		destOffsets := make(map[int]int64, len(offsets))
+		for queueId, offset := range offsets {
+			destOffsets[queueId] = offset
+		}
+		destKey := topic + TOPIC_GROUP_SEPARATOR + destGroup
+		com.Offsets.Put(destKey, destOffsets)
+		com.appendRecord(&offsetstore.OffsetRecord{Type: offsetstore.RECORD_PUT, Key: destKey, Offsets: destOffsets})
+	}
+}
+
+// PutAll 用master上的消费进度覆盖本地消费进度(slave同步)，并立即合并到快照
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) PutAll(offsetTable *syncmap.Map) {
+	com.persistLock.Lock()
+	com.Offsets.PutAll(offsetTable)
+	com.persistLock.Unlock()
+
+	if err := com.compact(); err != nil {
+		logger.Errorf("compact consumer offset err: %s", err.Error())
 	}
 }
 
@@ -211,7 +322,7 @@ func (com *ConsumerOffsetManager) QueryMinOffsetInAllGroup(topic, filterGroups s
 
 	if !stgcommon.IsBlank(filterGroups) {
 		for _, group := range strings.Split(filterGroups, ",") {
-			com.Offsets.RemoveByFlag(func(topicAtGroup string, v map[int]int64) bool {
+			com.removeByFlag(func(topicAtGroup string, v map[int]int64) bool {
 				topicGroupArr := strings.Split(topicAtGroup, TOPIC_GROUP_SEPARATOR)
 				if topicGroupArr != nil && len(topicGroupArr) == 2 {
 					if strings.EqualFold(group, topicGroupArr[1]) {
@@ -257,6 +368,56 @@ func min(a, b int64) int64 {
 	return a
 }
 
+// Persist 把提交日志刷盘，日志超过OffsetJournalCompactSize时合并到快照
+// Author agent
+// Since 2026/10/19
 func (com *ConsumerOffsetManager) Persist() {
+	if com.offsetStore == nil {
+		return
+	}
+	if err := com.offsetStore.Flush(); err != nil {
+		logger.Errorf("flush consumer offset journal err: %s", err.Error())
+		return
+	}
+	if com.offsetStore.JournalSize() >= com.BrokerController.BrokerConfig.OffsetJournalCompactSize {
+		if err := com.compact(); err != nil {
+			logger.Errorf("compact consumer offset err: %s", err.Error())
+		}
+	}
+}
+
+// compact 把当前消费进度写入快照，并清理快照已经包含的提交日志
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) compact() error {
+	com.compactLock.Lock()
+	defer com.compactLock.Unlock()
+
+	com.persistLock.Lock()
+	table := com.cloneAllOffsets()
+	point := com.offsetStore.Mark()
+	com.persistLock.Unlock()
+
+	return com.offsetStore.Compact(table, point)
+}
+
+// Export 把消费进度导出为consumerOffset.json，供运维工具使用
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) Export() {
 	com.configManagerExt.Persist()
 }
+
+// Shutdown 合并快照、关闭提交日志，并导出consumerOffset.json
+// Author agent
+// Since 2026/10/19
+func (com *ConsumerOffsetManager) Shutdown() {
+	if com.offsetStore == nil {
+		return
+	}
+	if err := com.compact(); err != nil {
+		logger.Errorf("compact consumer offset err: %s", err.Error())
+	}
+	com.offsetStore.Shutdown()
+	com.Export()
+}
@@ -0,0 +1,323 @@
+package stgbroker
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+	"math/rand"
+	"strings"
+)
+
+const (
+	DLQ_QUERY_MAX_NUMS = 64 // 单次查询死信消息的最大条数
+)
+
+// DLQMessageManager 死信消息管理：分页查询、重新投递、清除
+//
+// 死信消息不会被物理删除，清除操作只是将消费组在 %DLQ%group 上的消费进度推进到指定位置，
+// 查询和全部重新投递都从该进度开始，进度随ConsumerOffsetManager一起持久化；
+// 全部重新投递成功的消息同样推进该进度，重复调用不会再次投递
+//
+// Author agent
+// Since 2026/10/19
+type DLQMessageManager struct {
+	brokerController *BrokerController
+}
+
+// NewDLQMessageManager 初始化死信消息管理
+// Author agent
+// Since 2026/10/19
+func NewDLQMessageManager(brokerController *BrokerController) *DLQMessageManager {
+	return &DLQMessageManager{brokerController: brokerController}
+}
+
+// queueInfos 获得消费组各个死信队列的offset范围
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) queueInfos(group string) []*body.DLQQueueInfo {
+	dlqTopic := stgcommon.GetDLQTopic(group)
+	queueInfos := make([]*body.DLQQueueInfo, 0)
+	topicConfig := self.brokerController.TopicConfigManager.SelectTopicConfig(dlqTopic)
+	if topicConfig == nil {
+		return queueInfos
+	}
+
+	messageStore := self.brokerController.MessageStore
+	for queueId := int32(0); queueId < topicConfig.ReadQueueNums; queueId++ {
+		minOffset := messageStore.GetMinOffsetInQueue(dlqTopic, queueId)
+		maxOffset := messageStore.GetMaxOffsetInQueue(dlqTopic, queueId)
+		if maxOffset < 0 {
+			continue
+		}
+		if minOffset < 0 {
+			minOffset = 0
+		}
+		purgedOffset := self.brokerController.ConsumerOffsetManager.QueryOffset(group, dlqTopic, int(queueId))
+		if purgedOffset > minOffset {
+			minOffset = purgedOffset
+		}
+		if minOffset > maxOffset {
+			minOffset = maxOffset
+		}
+		queueInfos = append(queueInfos, &body.DLQQueueInfo{QueueId: queueId, MinOffset: minOffset, MaxOffset: maxOffset})
+	}
+	return queueInfos
+}
+
+// findQueueInfo 查找queueId对应的offset范围
+// Author agent
+// Since 2026/10/19
+func findDLQQueueInfo(queueInfos []*body.DLQQueueInfo, queueId int32) *body.DLQQueueInfo {
+	for _, queueInfo := range queueInfos {
+		if queueInfo.QueueId == queueId {
+			return queueInfo
+		}
+	}
+	return nil
+}
+
+// QueryMessages 从死信队列queueId的offset位置开始读取最多maxNums条消息
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) QueryMessages(group string, queueId int32, offset int64, maxNums int32) *body.DLQMessageList {
+	messageList := body.NewDLQMessageList()
+	messageList.QueueInfos = self.queueInfos(group)
+	if maxNums > DLQ_QUERY_MAX_NUMS {
+		maxNums = DLQ_QUERY_MAX_NUMS
+	}
+
+	queueInfo := findDLQQueueInfo(messageList.QueueInfos, queueId)
+	if queueInfo == nil || maxNums <= 0 {
+		return messageList
+	}
+	if offset < queueInfo.MinOffset {
+		offset = queueInfo.MinOffset
+	}
+
+	dlqTopic := stgcommon.GetDLQTopic(group)
+	for ; offset < queueInfo.MaxOffset && int32(len(messageList.Messages)) < maxNums; offset++ {
+		msgExt := self.lookMessage(dlqTopic, queueId, offset)
+		if msgExt == nil {
+			continue
+		}
+		messageList.Messages = append(messageList.Messages, self.toDLQMessage(msgExt))
+	}
+	return messageList
+}
+
+// ResendMessages 将死信消息重新投递到原始Topic，msgIds为空表示投递全部未被清除的死信消息；
+// 全部投递时每个队列按offset顺序投递，投递成功后推进清除进度，遇到失败的消息时停止投递该队列，
+// 失败的消息及其后的消息仍保留在死信队列中，可在处理失败原因后再次投递
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) ResendMessages(group string, msgIds []string) *body.DLQResendResult {
+	result := body.NewDLQResendResult()
+	dlqTopic := stgcommon.GetDLQTopic(group)
+
+	if len(msgIds) > 0 {
+		for _, msgId := range msgIds {
+			result.Total++
+			messageId, err := message.DecodeMessageId(msgId)
+			if err != nil {
+				result.FailedMsgIds[msgId] = err.Error()
+				continue
+			}
+			msgExt := self.brokerController.MessageStore.LookMessageByOffset(int64(messageId.Offset))
+			if msgExt == nil || msgExt.Topic != dlqTopic {
+				result.FailedMsgIds[msgId] = fmt.Sprintf("message not found in %s", dlqTopic)
+				continue
+			}
+			self.resendMessage(msgExt, msgId, result)
+		}
+		return result
+	}
+
+	resent := false
+	for _, queueInfo := range self.queueInfos(group) {
+		offset := queueInfo.MinOffset
+		for ; offset < queueInfo.MaxOffset; offset++ {
+			msgExt := self.lookMessage(dlqTopic, queueInfo.QueueId, offset)
+			if msgExt == nil {
+				continue
+			}
+			result.Total++
+			if !self.resendMessage(msgExt, msgExt.MsgId, result) {
+				break
+			}
+		}
+		if offset > queueInfo.MinOffset {
+			self.brokerController.ConsumerOffsetManager.CommitOffset(group, dlqTopic, int(queueInfo.QueueId), offset)
+			resent = true
+		}
+	}
+	if resent {
+		self.brokerController.ConsumerOffsetManager.Persist()
+	}
+	return result
+}
+
+// resendMessage 将一条死信消息投递到原始Topic，返回是否投递成功
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) resendMessage(msgExt *message.MessageExt, msgId string, result *body.DLQResendResult) bool {
+	originTopic := msgExt.GetProperty(message.PROPERTY_RETRY_TOPIC)
+	if originTopic == "" {
+		result.FailedMsgIds[msgId] = "origin topic not found"
+		return false
+	}
+	topicConfig := self.brokerController.TopicConfigManager.SelectTopicConfig(originTopic)
+	if topicConfig == nil || topicConfig.WriteQueueNums <= 0 {
+		result.FailedMsgIds[msgId] = fmt.Sprintf("topic[%s] not exist", originTopic)
+		return false
+	}
+	if !constant.IsWriteable(topicConfig.Perm) {
+		result.FailedMsgIds[msgId] = fmt.Sprintf("the topic[%s] sending message is forbidden", originTopic)
+		return false
+	}
+
+	properties := make(map[string]string, len(msgExt.Properties))
+	for key, value := range msgExt.Properties {
+		properties[key] = value
+	}
+	// 重新投递的消息立即可见，并且按照首次投递重新计算重试次数
+	delete(properties, message.PROPERTY_DELAY_TIME_LEVEL)
+	delete(properties, message.PROPERTY_REAL_TOPIC)
+	delete(properties, message.PROPERTY_REAL_QUEUE_ID)
+	delete(properties, message.PROPERTY_RECONSUME_TIME)
+
+	msgInner := new(stgstorelog.MessageExtBrokerInner)
+	msgInner.Topic = originTopic
+	msgInner.Body = msgExt.Body
+	msgInner.Flag = msgExt.Flag
+	message.SetPropertiesMap(&msgInner.Message, properties)
+	msgInner.PropertiesString = message.MessageProperties2String(properties)
+	msgInner.TagsCode = stgstorelog.TagsString2tagsCode(topicConfig.TopicFilterType, msgInner.GetTags())
+	msgInner.QueueId = rand.Int31n(topicConfig.WriteQueueNums)
+	msgInner.SysFlag = msgExt.SysFlag
+	msgInner.BornTimestamp = msgExt.BornTimestamp
+	msgInner.BornHost = msgExt.BornHost
+	msgInner.StoreHost = self.brokerController.StoreHost
+	msgInner.ReconsumeTimes = 0
+
+	originMsgId := message.GetOriginMessageId(msgExt.Message)
+	if originMsgId == "" {
+		originMsgId = msgExt.MsgId
+	}
+	message.SetOriginMessageId(&msgInner.Message, originMsgId)
+
+	putMessageResult := self.brokerController.MessageStore.PutMessage(msgInner)
+	if putMessageResult == nil {
+		result.FailedMsgIds[msgId] = "putMessageResult is null"
+		return false
+	}
+	if putMessageResult.PutMessageStatus != stgstorelog.PUTMESSAGE_PUT_OK {
+		result.FailedMsgIds[msgId] = putMessageResult.PutMessageStatus.PutMessageString()
+		return false
+	}
+
+	result.Succeed++
+	logger.Infof("resend dlq message %s to topic %s, originMsgId: %s", msgId, originTopic, originMsgId)
+	return true
+}
+
+// Purge 清除死信消息，offset大于等于0时清除队列offset小于offset的消息，否则清除存储时间早于timestamp的消息
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) Purge(group string, offset, timestamp int64) *body.DLQPurgeResult {
+	result := body.NewDLQPurgeResult()
+	dlqTopic := stgcommon.GetDLQTopic(group)
+
+	for _, queueInfo := range self.queueInfos(group) {
+		target := offset
+		if target < 0 {
+			target = self.searchOffsetByStoreTime(dlqTopic, queueInfo, timestamp)
+		}
+		if target > queueInfo.MaxOffset {
+			target = queueInfo.MaxOffset
+		}
+
+		if target > queueInfo.MinOffset {
+			self.brokerController.ConsumerOffsetManager.CommitOffset(group, dlqTopic, int(queueInfo.QueueId), target)
+			result.Purged += target - queueInfo.MinOffset
+			logger.Infof("purge dlq %s queueId=%d offset %d -> %d", dlqTopic, queueInfo.QueueId, queueInfo.MinOffset, target)
+			queueInfo.MinOffset = target
+		}
+		result.QueueInfos = append(result.QueueInfos, queueInfo)
+	}
+
+	if result.Purged > 0 {
+		self.brokerController.ConsumerOffsetManager.Persist()
+	}
+	return result
+}
+
+// searchOffsetByStoreTime 二分查找第一条存储时间不早于timestamp的消息offset
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) searchOffsetByStoreTime(dlqTopic string, queueInfo *body.DLQQueueInfo, timestamp int64) int64 {
+	low, high := queueInfo.MinOffset, queueInfo.MaxOffset
+	for low < high {
+		mid := low + (high-low)/2
+		storeTime := self.brokerController.MessageStore.GetMessageStoreTimeStamp(dlqTopic, queueInfo.QueueId, mid)
+		if storeTime >= 0 && storeTime < timestamp {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	return low
+}
+
+// lookMessage 根据死信队列的逻辑offset查询消息
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) lookMessage(dlqTopic string, queueId int32, offset int64) *message.MessageExt {
+	commitLogOffset := self.brokerController.MessageStore.GetCommitLogOffsetInQueue(dlqTopic, queueId, offset)
+	if commitLogOffset < 0 {
+		return nil
+	}
+	return self.brokerController.MessageStore.LookMessageByOffset(commitLogOffset)
+}
+
+// toDLQMessage 转换为死信消息摘要
+// Author agent
+// Since 2026/10/19
+func (self *DLQMessageManager) toDLQMessage(msgExt *message.MessageExt) *body.DLQMessage {
+	msgId := msgExt.MsgId
+	if msgId == "" {
+		msgId, _ = message.CreateMessageId(self.brokerController.StoreHost, msgExt.CommitLogOffset)
+	}
+	return &body.DLQMessage{
+		BrokerName:     self.brokerController.BrokerConfig.BrokerName,
+		MsgId:          msgId,
+		OriginMsgId:    message.GetOriginMessageId(msgExt.Message),
+		OriginTopic:    msgExt.GetProperty(message.PROPERTY_RETRY_TOPIC),
+		QueueId:        msgExt.QueueId,
+		QueueOffset:    msgExt.QueueOffset,
+		Tags:           msgExt.GetTags(),
+		Keys:           msgExt.GetKeys(),
+		ReconsumeTimes: msgExt.ReconsumeTimes,
+		BornHost:       msgExt.BornHost,
+		BornTimestamp:  msgExt.BornTimestamp,
+		StoreTimestamp: msgExt.StoreTimestamp,
+		BodySize:       len(msgExt.Body),
+		Properties:     msgExt.Properties,
+	}
+}
+
+// splitDLQMsgIds 拆分逗号分隔的消息ID
+// Author agent
+// Since 2026/10/19
+func splitDLQMsgIds(msgIds string) []string {
+	result := make([]string, 0)
+	for _, msgId := range strings.Split(msgIds, ",") {
+		if msgId = strings.TrimSpace(msgId); msgId != "" {
+			result = append(result, msgId)
+		}
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"hash/crc32"
+	"net"
+	"sort"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+)
+
+// Backend Kafka前端依赖的broker能力，由broker实现，测试中可替换
+//
+// 注意：除GetTopicRoute、GetBrokers外均为本broker的队列操作，
+// 返回ErrorCode类型的错误时原样应答给客户端，其他错误应答UNKNOWN_SERVER_ERROR
+//
+// Author agent
+// Since 2026/10/19
+type Backend interface {
+	// BrokerName 本broker名称，用于区分本地分区
+	BrokerName() string
+	// GetTopics 本broker上可被Kafka客户端访问的topic，Metadata请求未指定topic时返回
+	GetTopics() []string
+	// GetTopicRoute topic路由，topic不存在时返回ERR_UNKNOWN_TOPIC_OR_PARTITION
+	GetTopicRoute(topic string) (*route.TopicRouteData, error)
+	// GetBrokers 集群中全部brokerName及其master地址，用于选择消费组的协调者
+	GetBrokers() (map[string]string, error)
+	// PutMessages 依次写入消息，返回第一条消息的队列位点
+	PutMessages(topic string, queueId int32, records []*Record) (int64, error)
+	// GetMessages 从队列位点offset开始读取最多maxNums条消息
+	GetMessages(topic string, queueId int32, offset int64, maxNums int32) ([]*Record, error)
+	// GetMinOffset 队列最小位点
+	GetMinOffset(topic string, queueId int32) int64
+	// GetMaxOffset 队列最大位点，即下一条消息的位点
+	GetMaxOffset(topic string, queueId int32) int64
+	// GetOffsetByTime 队列中存储时间不早于timestamp的第一条消息的位点
+	GetOffsetByTime(topic string, queueId int32, timestamp int64) int64
+	// CommitOffset 提交消费位点，队列可能在其他broker上，brokerAddr为其master地址(无master时为空)
+	CommitOffset(group string, mq *message.MessageQueue, brokerAddr string, offset int64) error
+	// QueryOffset 查询消费位点，未提交过时返回-1
+	QueryOffset(group string, mq *message.MessageQueue, brokerAddr string) (int64, error)
+}
+
+// Node Kafka协议中的broker节点
+// Author agent
+// Since 2026/10/19
+type Node struct {
+	NodeId int32
+	Host   string
+	Port   int32
+}
+
+// partition Kafka分区与smartgo队列的对应关系
+type partition struct {
+	brokerName string
+	brokerAddr string // broker的master地址，无master时为空
+	queueId    int32
+	leader     *Node // broker无master时为nil
+}
+
+// topicLayout topic的全部分区
+type topicLayout struct {
+	partitions []*partition
+	nodes      map[int32]*Node
+}
+
+// nodeId brokerName映射为节点id，同一集群中各broker的Kafka前端计算结果一致
+func nodeId(brokerName string) int32 {
+	return int32(crc32.ChecksumIEEE([]byte(brokerName)) & 0x7fffffff)
+}
+
+// newNode broker的Kafka节点，host取自broker的master地址，端口与本前端一致
+func newNode(brokerName, brokerAddr string, port int32) *Node {
+	host, _, err := net.SplitHostPort(brokerAddr)
+	if err != nil {
+		host = brokerAddr
+	}
+	return &Node{NodeId: nodeId(brokerName), Host: host, Port: port}
+}
+
+// buildTopicLayout 由TopicRouteData构建分区：按brokerName排序后，依次将各broker的读队列编号为分区
+// Author agent
+// Since 2026/10/19
+func buildTopicLayout(routeData *route.TopicRouteData, port int32) *topicLayout {
+	layout := &topicLayout{nodes: make(map[int32]*Node)}
+	masterAddrs := make(map[string]string)
+	masters := make(map[string]*Node)
+	for _, brokerData := range routeData.BrokerDatas {
+		if brokerData == nil {
+			continue
+		}
+		if addr, ok := brokerData.BrokerAddrs[stgcommon.MASTER_ID]; ok && addr != "" {
+			node := newNode(brokerData.BrokerName, addr, port)
+			masterAddrs[brokerData.BrokerName] = addr
+			masters[brokerData.BrokerName] = node
+			layout.nodes[node.NodeId] = node
+		}
+	}
+
+	queueDatas := make([]*route.QueueData, 0, len(routeData.QueueDatas))
+	for _, queueData := range routeData.QueueDatas {
+		if queueData != nil && constant.IsReadable(queueData.Perm) {
+			queueDatas = append(queueDatas, queueData)
+		}
+	}
+	sort.Slice(queueDatas, func(i, j int) bool {
+		return queueDatas[i].BrokerName < queueDatas[j].BrokerName
+	})
+
+	for _, queueData := range queueDatas {
+		for queueId := 0; queueId < queueData.ReadQueueNums; queueId++ {
+			layout.partitions = append(layout.partitions, &partition{
+				brokerName: queueData.BrokerName,
+				brokerAddr: masterAddrs[queueData.BrokerName],
+				queueId:    int32(queueId),
+				leader:     masters[queueData.BrokerName],
+			})
+		}
+	}
+	return layout
+}
+
+// messageQueue 分区对应的smartgo队列
+func (p *partition) messageQueue(topic string) *message.MessageQueue {
+	return &message.MessageQueue{Topic: topic, BrokerName: p.brokerName, QueueId: int(p.queueId)}
+}
+
+// partition 分区号对应的队列，不存在时返回nil
+func (layout *topicLayout) partition(index int32) *partition {
+	if index < 0 || int(index) >= len(layout.partitions) {
+		return nil
+	}
+	return layout.partitions[index]
+}
+
+// chooseCoordinator 按消费组名的hash在排序后的broker中选择协调者，各broker计算结果一致
+func chooseCoordinator(group string, brokers map[string]string) (string, string) {
+	names := make([]string, 0, len(brokers))
+	for name := range brokers {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", ""
+	}
+	sort.Strings(names)
+	name := names[crc32.ChecksumIEEE([]byte(group))%uint32(len(names))]
+	return name, brokers[name]
+}
@@ -0,0 +1,498 @@
+package kafka
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// produceTimeout Produce请求中的timeout_ms
+const produceTimeout = 10 * time.Second
+
+// Client 测试用的Kafka协议客户端，只覆盖本前端支持的请求，不随broker发布
+//
+// 注意：一个Client对应一条连接，请求串行发送
+//
+// Author agent
+// Since 2026/10/19
+type Client struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	clientId      string
+	timeout       time.Duration
+	correlationId int32
+	lock          sync.Mutex
+}
+
+// TopicMetadata Metadata应答中的topic
+// Author agent
+// Since 2026/10/19
+type TopicMetadata struct {
+	ErrorCode  ErrorCode
+	Name       string
+	Partitions []*PartitionMetadata
+}
+
+// PartitionMetadata Metadata应答中的分区
+// Author agent
+// Since 2026/10/19
+type PartitionMetadata struct {
+	ErrorCode ErrorCode
+	Partition int32
+	Leader    int32
+	Replicas  []int32
+	Isr       []int32
+}
+
+// MetadataResponse Metadata应答
+// Author agent
+// Since 2026/10/19
+type MetadataResponse struct {
+	Brokers      []*Node
+	ControllerId int32
+	Topics       []*TopicMetadata
+}
+
+// FetchResult Fetch应答中的一个分区
+// Author agent
+// Since 2026/10/19
+type FetchResult struct {
+	HighWatermark int64
+	Records       []*Record
+}
+
+// DialClient 连接Kafka前端
+// Author agent
+// Since 2026/10/19
+func DialClient(addr, clientId string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, reader: bufio.NewReader(conn), clientId: clientId, timeout: timeout}, nil
+}
+
+// Close 关闭连接
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+// request 发送请求并读取应答体；expectResponse为false时只发送(acks=0的Produce)
+func (client *Client) request(apiKey, apiVersion int16, body []byte, timeout time.Duration, expectResponse bool) (*decoder, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	client.correlationId++
+	e := &encoder{}
+	sizePos := e.reserveInt32()
+	e.putInt16(apiKey)
+	e.putInt16(apiVersion)
+	e.putInt32(client.correlationId)
+	e.putString(client.clientId)
+	e.buf = append(e.buf, body...)
+	e.fillInt32(sizePos, int32(len(e.buf)-4))
+
+	client.conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := client.conn.Write(e.buf); err != nil {
+		return nil, err
+	}
+	if !expectResponse {
+		return nil, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(client.reader, size[:]); err != nil {
+		return nil, err
+	}
+	length := int32(binary.BigEndian.Uint32(size[:]))
+	if length < 4 || length > maxRequestSize {
+		return nil, fmt.Errorf("kafka response invalid size %d", length)
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(client.reader, response); err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: response}
+	if correlationId := d.int32(); correlationId != client.correlationId {
+		return nil, fmt.Errorf("kafka response correlation id %d, expect %d", correlationId, client.correlationId)
+	}
+	return d, nil
+}
+
+// call 请求并用parse解析应答
+func (client *Client) call(apiKey, apiVersion int16, body []byte, parse func(d *decoder) error) error {
+	d, err := client.request(apiKey, apiVersion, body, client.timeout, true)
+	if err != nil {
+		return err
+	}
+	if err := parse(d); err != nil {
+		return err
+	}
+	return d.err
+}
+
+// errorOf ERR_NONE返回nil
+func errorOf(code int16) error {
+	if ErrorCode(code) == ERR_NONE {
+		return nil
+	}
+	return ErrorCode(code)
+}
+
+// ApiVersions 查询服务端支持的api版本，返回apiKey对应的[min, max]
+// Author agent
+// Since 2026/10/19
+func (client *Client) ApiVersions() (map[int16][2]int16, error) {
+	versions := make(map[int16][2]int16)
+	err := client.call(API_API_VERSIONS, 1, nil, func(d *decoder) error {
+		errorCode := d.int16()
+		n := d.arrayLength()
+		for i := 0; i < n; i++ {
+			apiKey := d.int16()
+			versions[apiKey] = [2]int16{d.int16(), d.int16()}
+		}
+		d.int32() // throttle_time_ms
+		return errorOf(errorCode)
+	})
+	return versions, err
+}
+
+// Metadata 查询topic的分区信息，topics为空时查询全部topic
+// Author agent
+// Since 2026/10/19
+func (client *Client) Metadata(topics ...string) (*MetadataResponse, error) {
+	e := &encoder{}
+	if len(topics) == 0 {
+		e.putArrayLength(-1)
+	} else {
+		e.putArrayLength(len(topics))
+		for _, topic := range topics {
+			e.putString(topic)
+		}
+	}
+
+	response := &MetadataResponse{}
+	err := client.call(API_METADATA, 1, e.buf, func(d *decoder) error {
+		brokerNums := d.arrayLength()
+		for i := 0; i < brokerNums; i++ {
+			response.Brokers = append(response.Brokers, &Node{NodeId: d.int32(), Host: d.string(), Port: d.int32()})
+			d.nullableString() // rack
+		}
+		response.ControllerId = d.int32()
+		topicNums := d.arrayLength()
+		for i := 0; i < topicNums; i++ {
+			topic := &TopicMetadata{ErrorCode: ErrorCode(d.int16()), Name: d.string()}
+			d.bool() // is_internal
+			partitionNums := d.arrayLength()
+			for j := 0; j < partitionNums; j++ {
+				p := &PartitionMetadata{ErrorCode: ErrorCode(d.int16()), Partition: d.int32(), Leader: d.int32()}
+				p.Replicas = decodeInt32Array(d)
+				p.Isr = decodeInt32Array(d)
+				topic.Partitions = append(topic.Partitions, p)
+			}
+			response.Topics = append(response.Topics, topic)
+		}
+		return nil
+	})
+	return response, err
+}
+
+func decodeInt32Array(d *decoder) []int32 {
+	n := d.arrayLength()
+	var values []int32
+	for i := 0; i < n; i++ {
+		values = append(values, d.int32())
+	}
+	return values
+}
+
+// Produce 写入一个分区(Produce v2，acks=1)，返回第一条消息的位点
+// Author agent
+// Since 2026/10/19
+func (client *Client) Produce(topic string, partition int32, records ...*Record) (int64, error) {
+	body := encodeProduceRequest(1, topic, partition, records)
+	baseOffset := int64(-1)
+	err := client.call(API_PRODUCE, 2, body, func(d *decoder) error {
+		var errorCode int16
+		topicNums := d.arrayLength()
+		for i := 0; i < topicNums; i++ {
+			d.string()
+			partitionNums := d.arrayLength()
+			for j := 0; j < partitionNums; j++ {
+				d.int32()
+				errorCode = d.int16()
+				baseOffset = d.int64()
+				d.int64() // log_append_time
+			}
+		}
+		d.int32() // throttle_time_ms
+		return errorOf(errorCode)
+	})
+	return baseOffset, err
+}
+
+// ProduceNoAck 写入一个分区(acks=0)，服务端不应答
+// Author agent
+// Since 2026/10/19
+func (client *Client) ProduceNoAck(topic string, partition int32, records ...*Record) error {
+	body := encodeProduceRequest(0, topic, partition, records)
+	_, err := client.request(API_PRODUCE, 2, body, client.timeout, false)
+	return err
+}
+
+func encodeProduceRequest(acks int16, topic string, partition int32, records []*Record) []byte {
+	e := &encoder{}
+	e.putInt16(acks)
+	e.putInt32(int32(produceTimeout / time.Millisecond))
+	e.putArrayLength(1)
+	e.putString(topic)
+	e.putArrayLength(1)
+	e.putInt32(partition)
+	e.putBytes(encodeMessageSet(records, messageMagic1))
+	return e.buf
+}
+
+// Fetch 读取一个分区(Fetch v3)，maxWait内无消息时返回空
+// Author agent
+// Since 2026/10/19
+func (client *Client) Fetch(topic string, partition int32, offset int64, maxBytes int32, maxWait time.Duration) (*FetchResult, error) {
+	e := &encoder{}
+	e.putInt32(-1) // replica_id
+	e.putInt32(int32(maxWait / time.Millisecond))
+	e.putInt32(1) // min_bytes
+	e.putInt32(maxBytes)
+	e.putArrayLength(1)
+	e.putString(topic)
+	e.putArrayLength(1)
+	e.putInt32(partition)
+	e.putInt64(offset)
+	e.putInt32(maxBytes)
+
+	d, err := client.request(API_FETCH, 3, e.buf, client.timeout+maxWait, true)
+	if err != nil {
+		return nil, err
+	}
+	result := &FetchResult{HighWatermark: -1}
+	var errorCode int16
+	d.int32() // throttle_time_ms
+	topicNums := d.arrayLength()
+	for i := 0; i < topicNums; i++ {
+		d.string()
+		partitionNums := d.arrayLength()
+		for j := 0; j < partitionNums; j++ {
+			d.int32()
+			errorCode = d.int16()
+			result.HighWatermark = d.int64()
+			messageSet := d.bytes()
+			if d.err != nil {
+				return nil, d.err
+			}
+			if result.Records, err = decodeMessageSet(messageSet, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return result, errorOf(errorCode)
+}
+
+// ListOffset 查询分区位点(ListOffsets v1)，timestamp为OFFSET_LATEST、OFFSET_EARLIEST或毫秒时间戳
+// Author agent
+// Since 2026/10/19
+func (client *Client) ListOffset(topic string, partition int32, timestamp int64) (int64, error) {
+	e := &encoder{}
+	e.putInt32(-1) // replica_id
+	e.putArrayLength(1)
+	e.putString(topic)
+	e.putArrayLength(1)
+	e.putInt32(partition)
+	e.putInt64(timestamp)
+
+	offset := int64(-1)
+	err := client.call(API_LIST_OFFSETS, 1, e.buf, func(d *decoder) error {
+		var errorCode int16
+		topicNums := d.arrayLength()
+		for i := 0; i < topicNums; i++ {
+			d.string()
+			partitionNums := d.arrayLength()
+			for j := 0; j < partitionNums; j++ {
+				d.int32()
+				errorCode = d.int16()
+				d.int64() // timestamp
+				offset = d.int64()
+			}
+		}
+		return errorOf(errorCode)
+	})
+	return offset, err
+}
+
+// CommitOffset 提交消费位点(OffsetCommit v2)，generation为-1时不校验消费组成员
+// Author agent
+// Since 2026/10/19
+func (client *Client) CommitOffset(group string, generation int32, memberId, topic string, partition int32, offset int64) error {
+	e := &encoder{}
+	e.putString(group)
+	e.putInt32(generation)
+	e.putString(memberId)
+	e.putInt64(-1) // retention_time_ms
+	e.putArrayLength(1)
+	e.putString(topic)
+	e.putArrayLength(1)
+	e.putInt32(partition)
+	e.putInt64(offset)
+	e.putNullableString(nil)
+
+	return client.call(API_OFFSET_COMMIT, 2, e.buf, func(d *decoder) error {
+		var errorCode int16
+		topicNums := d.arrayLength()
+		for i := 0; i < topicNums; i++ {
+			d.string()
+			partitionNums := d.arrayLength()
+			for j := 0; j < partitionNums; j++ {
+				d.int32()
+				errorCode = d.int16()
+			}
+		}
+		return errorOf(errorCode)
+	})
+}
+
+// FetchOffset 查询消费位点(OffsetFetch v1)，未提交过时返回-1
+// Author agent
+// Since 2026/10/19
+func (client *Client) FetchOffset(group, topic string, partition int32) (int64, error) {
+	e := &encoder{}
+	e.putString(group)
+	e.putArrayLength(1)
+	e.putString(topic)
+	e.putArrayLength(1)
+	e.putInt32(partition)
+
+	offset := int64(-1)
+	err := client.call(API_OFFSET_FETCH, 1, e.buf, func(d *decoder) error {
+		var errorCode int16
+		topicNums := d.arrayLength()
+		for i := 0; i < topicNums; i++ {
+			d.string()
+			partitionNums := d.arrayLength()
+			for j := 0; j < partitionNums; j++ {
+				d.int32()
+				offset = d.int64()
+				d.nullableString() // metadata
+				errorCode = d.int16()
+			}
+		}
+		return errorOf(errorCode)
+	})
+	return offset, err
+}
+
+// FindCoordinator 查询消费组的协调者
+// Author agent
+// Since 2026/10/19
+func (client *Client) FindCoordinator(group string) (*Node, error) {
+	e := &encoder{}
+	e.putString(group)
+	node := &Node{}
+	err := client.call(API_FIND_COORDINATOR, 0, e.buf, func(d *decoder) error {
+		errorCode := d.int16()
+		node.NodeId, node.Host, node.Port = d.int32(), d.string(), d.int32()
+		return errorOf(errorCode)
+	})
+	return node, err
+}
+
+// JoinGroup 加入消费组，阻塞直到rebalance完成；首次加入时memberId为空
+// Author agent
+// Since 2026/10/19
+func (client *Client) JoinGroup(group, memberId string, sessionTimeout time.Duration, protocolType string,
+	protocols []*GroupProtocol) (*JoinGroupResult, error) {
+	e := &encoder{}
+	e.putString(group)
+	e.putInt32(int32(sessionTimeout / time.Millisecond))
+	e.putString(memberId)
+	e.putString(protocolType)
+	e.putArrayLength(len(protocols))
+	for _, p := range protocols {
+		e.putString(p.Name)
+		e.putBytes(nonNilBytes(p.Metadata))
+	}
+
+	d, err := client.request(API_JOIN_GROUP, 0, e.buf, client.timeout+sessionTimeout, true)
+	if err != nil {
+		return nil, err
+	}
+	result := &JoinGroupResult{
+		ErrorCode:    ErrorCode(d.int16()),
+		GenerationId: d.int32(),
+		Protocol:     d.string(),
+		LeaderId:     d.string(),
+		MemberId:     d.string(),
+	}
+	memberNums := d.arrayLength()
+	for i := 0; i < memberNums; i++ {
+		result.Members = append(result.Members, &GroupMember{MemberId: d.string(), Metadata: copyBytes(d.bytes())})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return result, errorOf(int16(result.ErrorCode))
+}
+
+// SyncGroup 同步分配结果，leader提交assignments，其他成员传nil
+// Author agent
+// Since 2026/10/19
+func (client *Client) SyncGroup(group string, generation int32, memberId string, assignments map[string][]byte) ([]byte, error) {
+	e := &encoder{}
+	e.putString(group)
+	e.putInt32(generation)
+	e.putString(memberId)
+	e.putArrayLength(len(assignments))
+	for id, assignment := range assignments {
+		e.putString(id)
+		e.putBytes(nonNilBytes(assignment))
+	}
+
+	d, err := client.request(API_SYNC_GROUP, 0, e.buf, client.timeout+maxSessionTimeout, true)
+	if err != nil {
+		return nil, err
+	}
+	errorCode := d.int16()
+	assignment := copyBytes(d.bytes())
+	if d.err != nil {
+		return nil, d.err
+	}
+	return assignment, errorOf(errorCode)
+}
+
+// Heartbeat 消费组成员心跳
+// Author agent
+// Since 2026/10/19
+func (client *Client) Heartbeat(group string, generation int32, memberId string) error {
+	e := &encoder{}
+	e.putString(group)
+	e.putInt32(generation)
+	e.putString(memberId)
+	return client.call(API_HEARTBEAT, 0, e.buf, func(d *decoder) error {
+		return errorOf(d.int16())
+	})
+}
+
+// LeaveGroup 离开消费组
+// Author agent
+// Since 2026/10/19
+func (client *Client) LeaveGroup(group, memberId string) error {
+	e := &encoder{}
+	e.putString(group)
+	e.putString(memberId)
+	return client.call(API_LEAVE_GROUP, 0, e.buf, func(d *decoder) error {
+		return errorOf(d.int16())
+	})
+}
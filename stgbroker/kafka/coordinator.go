@@ -0,0 +1,468 @@
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 会话超时的取值范围，与Kafka broker的默认配置一致
+const (
+	minSessionTimeout = 6 * time.Second
+	maxSessionTimeout = 300 * time.Second
+)
+
+// groupState 消费组状态
+type groupState int
+
+const (
+	groupEmpty groupState = iota
+	groupPreparingRebalance
+	groupAwaitingSync
+	groupStable
+)
+
+// GroupProtocol JoinGroup请求中成员支持的分配协议
+// Author agent
+// Since 2026/10/19
+type GroupProtocol struct {
+	Name     string
+	Metadata []byte
+}
+
+// GroupMember JoinGroup应答中发给leader的成员信息
+// Author agent
+// Since 2026/10/19
+type GroupMember struct {
+	MemberId string
+	Metadata []byte
+}
+
+// JoinGroupResult JoinGroup应答
+// Author agent
+// Since 2026/10/19
+type JoinGroupResult struct {
+	ErrorCode    ErrorCode
+	GenerationId int32
+	Protocol     string
+	LeaderId     string
+	MemberId     string
+	Members      []*GroupMember // 只有leader收到全部成员
+}
+
+type syncResult struct {
+	errorCode  ErrorCode
+	assignment []byte
+}
+
+type groupMember struct {
+	id             string
+	sessionTimeout time.Duration
+	protocols      []*GroupProtocol
+	assignment     []byte
+	lastHeartbeat  time.Time
+	joinChan       chan *JoinGroupResult // 非nil表示已发起join，等待rebalance完成
+	syncChan       chan *syncResult      // 非nil表示等待leader提交分配结果
+}
+
+// metadata 成员对指定协议的元数据
+func (member *groupMember) metadata(protocol string) ([]byte, bool) {
+	for _, p := range member.protocols {
+		if p.Name == protocol {
+			return p.Metadata, true
+		}
+	}
+	return nil, false
+}
+
+type consumerGroup struct {
+	id             string
+	state          groupState
+	generation     int32
+	protocolType   string
+	protocol       string
+	leader         string
+	members        map[string]*groupMember
+	rebalanceTimer *time.Timer
+}
+
+// groupCoordinator 最小化的消费组协调者：只支持JoinGroup/SyncGroup/Heartbeat/LeaveGroup v0，
+// 组状态只保存在内存中，broker重启后成员重新加入即可
+//
+// Author agent
+// Since 2026/10/19
+type groupCoordinator struct {
+	groups    map[string]*consumerGroup
+	lock      sync.Mutex
+	memberSeq int64
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+func newGroupCoordinator() *groupCoordinator {
+	return &groupCoordinator{groups: make(map[string]*consumerGroup), closeChan: make(chan struct{})}
+}
+
+// start 定期移除会话超时的成员
+func (gc *groupCoordinator) start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-gc.closeChan:
+				return
+			case now := <-ticker.C:
+				gc.expireMembers(now)
+			}
+		}
+	}()
+}
+
+func (gc *groupCoordinator) shutdown() {
+	gc.closeOnce.Do(func() {
+		close(gc.closeChan)
+		gc.lock.Lock()
+		for _, group := range gc.groups {
+			if group.rebalanceTimer != nil {
+				group.rebalanceTimer.Stop()
+			}
+		}
+		gc.lock.Unlock()
+	})
+}
+
+func (gc *groupCoordinator) nextMemberId(clientId string) string {
+	return fmt.Sprintf("%s-%d-%d", clientId, time.Now().UnixNano(), atomic.AddInt64(&gc.memberSeq, 1))
+}
+
+// join 加入消费组，阻塞直到本轮rebalance完成
+func (gc *groupCoordinator) join(groupId, memberId, clientId, protocolType string, sessionTimeoutMillis int32,
+	protocols []*GroupProtocol) *JoinGroupResult {
+	if groupId == "" {
+		return &JoinGroupResult{ErrorCode: ERR_INVALID_GROUP_ID}
+	}
+	sessionTimeout := time.Duration(sessionTimeoutMillis) * time.Millisecond
+	if sessionTimeout < minSessionTimeout || sessionTimeout > maxSessionTimeout {
+		return &JoinGroupResult{ErrorCode: ERR_INVALID_SESSION_TIMEOUT}
+	}
+	if protocolType == "" || len(protocols) == 0 {
+		return &JoinGroupResult{ErrorCode: ERR_INCONSISTENT_GROUP_PROTOCOL}
+	}
+
+	gc.lock.Lock()
+	group, ok := gc.groups[groupId]
+	if !ok {
+		group = &consumerGroup{id: groupId, members: make(map[string]*groupMember)}
+		gc.groups[groupId] = group
+	}
+	if len(group.members) > 0 && (group.protocolType != protocolType || !group.supportsAny(protocols)) {
+		gc.lock.Unlock()
+		return &JoinGroupResult{ErrorCode: ERR_INCONSISTENT_GROUP_PROTOCOL}
+	}
+
+	member, ok := group.members[memberId]
+	if memberId != "" && !ok {
+		gc.lock.Unlock()
+		return &JoinGroupResult{ErrorCode: ERR_UNKNOWN_MEMBER_ID, MemberId: memberId}
+	}
+	if !ok {
+		member = &groupMember{id: gc.nextMemberId(clientId)}
+		group.members[member.id] = member
+	}
+	if len(group.members) == 1 {
+		group.protocolType = protocolType
+	}
+	member.sessionTimeout = sessionTimeout
+	member.protocols = protocols
+	member.lastHeartbeat = time.Now()
+	joinChan := make(chan *JoinGroupResult, 1)
+	member.joinChan = joinChan
+
+	gc.prepareRebalance(group)
+	gc.tryCompleteJoin(group)
+	gc.lock.Unlock()
+
+	select {
+	case result := <-joinChan:
+		return result
+	case <-gc.closeChan:
+		return &JoinGroupResult{ErrorCode: ERR_COORDINATOR_NOT_AVAILABLE}
+	}
+}
+
+// supportsAny 新成员至少支持一个全部现有成员都支持的协议
+func (group *consumerGroup) supportsAny(protocols []*GroupProtocol) bool {
+	for _, p := range protocols {
+		if group.allSupport(p.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (group *consumerGroup) allSupport(protocol string) bool {
+	for _, member := range group.members {
+		if _, ok := member.metadata(protocol); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareRebalance 进入rebalance，等待全部成员重新join；等待同步的成员返回REBALANCE_IN_PROGRESS
+func (gc *groupCoordinator) prepareRebalance(group *consumerGroup) {
+	if group.state == groupPreparingRebalance {
+		return
+	}
+	for _, member := range group.members {
+		if member.syncChan != nil {
+			member.syncChan <- &syncResult{errorCode: ERR_REBALANCE_IN_PROGRESS}
+			member.syncChan = nil
+		}
+	}
+	group.state = groupPreparingRebalance
+
+	// v0协议没有单独的rebalance超时，取成员中最大的会话超时
+	var timeout time.Duration
+	for _, member := range group.members {
+		if member.sessionTimeout > timeout {
+			timeout = member.sessionTimeout
+		}
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		gc.lock.Lock()
+		defer gc.lock.Unlock()
+		if group.rebalanceTimer == timer && group.state == groupPreparingRebalance {
+			gc.completeJoin(group)
+		}
+	})
+	group.rebalanceTimer = timer
+}
+
+// tryCompleteJoin 全部成员都已重新join时立即完成rebalance
+func (gc *groupCoordinator) tryCompleteJoin(group *consumerGroup) {
+	if group.state != groupPreparingRebalance {
+		return
+	}
+	for _, member := range group.members {
+		if member.joinChan == nil {
+			return
+		}
+	}
+	gc.completeJoin(group)
+}
+
+// completeJoin 移除未重新join的成员，进入下一代并应答全部等待的JoinGroup请求
+func (gc *groupCoordinator) completeJoin(group *consumerGroup) {
+	if group.rebalanceTimer != nil {
+		group.rebalanceTimer.Stop()
+		group.rebalanceTimer = nil
+	}
+	for id, member := range group.members {
+		if member.joinChan == nil {
+			delete(group.members, id)
+		}
+	}
+	group.generation++
+	if len(group.members) == 0 {
+		group.state = groupEmpty
+		group.protocol, group.leader = "", ""
+		delete(gc.groups, group.id)
+		return
+	}
+
+	memberIds := make([]string, 0, len(group.members))
+	for id := range group.members {
+		memberIds = append(memberIds, id)
+	}
+	sort.Strings(memberIds)
+	if _, ok := group.members[group.leader]; !ok {
+		group.leader = memberIds[0]
+	}
+	group.protocol = ""
+	for _, p := range group.members[group.leader].protocols {
+		if group.allSupport(p.Name) {
+			group.protocol = p.Name
+			break
+		}
+	}
+	group.state = groupAwaitingSync
+
+	now := time.Now()
+	for _, id := range memberIds {
+		member := group.members[id]
+		result := &JoinGroupResult{
+			GenerationId: group.generation,
+			Protocol:     group.protocol,
+			LeaderId:     group.leader,
+			MemberId:     id,
+		}
+		if id == group.leader {
+			for _, memberId := range memberIds {
+				metadata, _ := group.members[memberId].metadata(group.protocol)
+				result.Members = append(result.Members, &GroupMember{MemberId: memberId, Metadata: metadata})
+			}
+		}
+		member.assignment = nil
+		member.lastHeartbeat = now
+		member.joinChan <- result
+		member.joinChan = nil
+	}
+}
+
+// checkMember 校验成员及代数
+func (gc *groupCoordinator) checkMember(groupId string, generation int32, memberId string) (*consumerGroup, *groupMember, ErrorCode) {
+	group, ok := gc.groups[groupId]
+	if !ok {
+		return nil, nil, ERR_UNKNOWN_MEMBER_ID
+	}
+	member, ok := group.members[memberId]
+	if !ok {
+		return nil, nil, ERR_UNKNOWN_MEMBER_ID
+	}
+	if generation != group.generation {
+		return nil, nil, ERR_ILLEGAL_GENERATION
+	}
+	return group, member, ERR_NONE
+}
+
+// sync leader提交分配结果，其他成员等待leader提交后返回各自的分配
+func (gc *groupCoordinator) sync(groupId string, generation int32, memberId string, assignments map[string][]byte) *syncResult {
+	gc.lock.Lock()
+	group, member, errorCode := gc.checkMember(groupId, generation, memberId)
+	if errorCode != ERR_NONE {
+		gc.lock.Unlock()
+		return &syncResult{errorCode: errorCode}
+	}
+	switch group.state {
+	case groupPreparingRebalance:
+		gc.lock.Unlock()
+		return &syncResult{errorCode: ERR_REBALANCE_IN_PROGRESS}
+	case groupStable:
+		gc.lock.Unlock()
+		return &syncResult{assignment: member.assignment}
+	}
+
+	member.lastHeartbeat = time.Now()
+	if memberId == group.leader {
+		for id, m := range group.members {
+			m.assignment = assignments[id]
+			if m.syncChan != nil {
+				m.syncChan <- &syncResult{assignment: m.assignment}
+				m.syncChan = nil
+			}
+		}
+		group.state = groupStable
+		gc.lock.Unlock()
+		return &syncResult{assignment: member.assignment}
+	}
+
+	syncChan := make(chan *syncResult, 1)
+	member.syncChan = syncChan
+	gc.lock.Unlock()
+
+	select {
+	case result := <-syncChan:
+		return result
+	case <-gc.closeChan:
+		return &syncResult{errorCode: ERR_COORDINATOR_NOT_AVAILABLE}
+	}
+}
+
+// heartbeat 成员心跳，rebalance期间返回REBALANCE_IN_PROGRESS通知成员重新join
+func (gc *groupCoordinator) heartbeat(groupId string, generation int32, memberId string) ErrorCode {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	group, member, errorCode := gc.checkMember(groupId, generation, memberId)
+	if errorCode != ERR_NONE {
+		return errorCode
+	}
+	member.lastHeartbeat = time.Now()
+	if group.state == groupPreparingRebalance {
+		return ERR_REBALANCE_IN_PROGRESS
+	}
+	return ERR_NONE
+}
+
+// leave 成员主动离开，剩余成员重新rebalance
+func (gc *groupCoordinator) leave(groupId, memberId string) ErrorCode {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	group, ok := gc.groups[groupId]
+	if !ok {
+		return ERR_UNKNOWN_MEMBER_ID
+	}
+	member, ok := group.members[memberId]
+	if !ok {
+		return ERR_UNKNOWN_MEMBER_ID
+	}
+	gc.removeMember(group, member)
+	return ERR_NONE
+}
+
+// removeMember 移除成员，调用方持有锁
+func (gc *groupCoordinator) removeMember(group *consumerGroup, member *groupMember) {
+	delete(group.members, member.id)
+	if member.joinChan != nil {
+		member.joinChan <- &JoinGroupResult{ErrorCode: ERR_UNKNOWN_MEMBER_ID, MemberId: member.id}
+		member.joinChan = nil
+	}
+	if member.syncChan != nil {
+		member.syncChan <- &syncResult{errorCode: ERR_UNKNOWN_MEMBER_ID}
+		member.syncChan = nil
+	}
+
+	if len(group.members) == 0 {
+		if group.rebalanceTimer != nil {
+			group.rebalanceTimer.Stop()
+		}
+		delete(gc.groups, group.id)
+		return
+	}
+	if group.state == groupPreparingRebalance {
+		gc.tryCompleteJoin(group)
+		return
+	}
+	gc.prepareRebalance(group)
+}
+
+// validateCommit 校验OffsetCommit请求的成员及代数；generation小于0表示不使用组管理，直接提交
+func (gc *groupCoordinator) validateCommit(groupId string, generation int32, memberId string) ErrorCode {
+	if generation < 0 {
+		return ERR_NONE
+	}
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	group, _, errorCode := gc.checkMember(groupId, generation, memberId)
+	if errorCode != ERR_NONE {
+		return errorCode
+	}
+	if group.state == groupPreparingRebalance {
+		return ERR_REBALANCE_IN_PROGRESS
+	}
+	return ERR_NONE
+}
+
+// expireMembers 移除会话超时的成员，正在等待join的成员不计超时
+func (gc *groupCoordinator) expireMembers(now time.Time) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	for _, group := range gc.groups {
+		for _, member := range group.members {
+			if member.joinChan == nil && now.Sub(member.lastHeartbeat) > member.sessionTimeout {
+				gc.removeMember(group, member)
+			}
+		}
+	}
+}
+
+// groupCount 内存中的消费组个数
+func (gc *groupCoordinator) groupCount() int {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	return len(gc.groups)
+}
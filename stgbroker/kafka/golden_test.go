@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 以下字节按Kafka协议文档逐字段编写，不经过本包的编码器，用于校验与标准客户端的线上格式一致。
+// 请求头均为api_key、api_version、correlation_id及client_id "golden"
+
+// goldenMessageSet offset 0、magic 1、timestamp 1512000000000、key "k"、value "v"的消息集合
+const goldenMessageSet = "0000000000000000" + // offset
+	"00000018" + // message_size
+	"bc1eb57c" + // crc
+	"01" + "00" + // magic、attributes
+	"000001600a391000" + // timestamp
+	"00000001" + "6b" + // key
+	"00000001" + "76" // value
+
+// goldenBytes 解码十六进制，忽略空白
+func goldenBytes(t *testing.T, parts ...string) []byte {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(strings.Join(parts, "")), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// roundTrip 发送不含长度前缀的请求，返回不含长度前缀的应答
+func roundTrip(t *testing.T, conn net.Conn, request []byte) []byte {
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(request)))
+	if _, err := conn.Write(append(size[:], request...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func expectGolden(t *testing.T, api string, response, expect []byte) {
+	if !bytes.Equal(response, expect) {
+		t.Fatalf("%s response mismatch\n got: %x\nwant: %x", api, response, expect)
+	}
+}
+
+func TestKafkaGoldenBytes(t *testing.T) {
+	server, _ := startTestServer(t)
+	defer server.Shutdown()
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Produce v2：acks 1、timeout 1000、[orders [partition 0, message_set]]
+	produce := goldenBytes(t,
+		"0000", "0002", "00000001", "0006676f6c64656e",
+		"0001", "000003e8",
+		"00000001", "00066f7264657273",
+		"00000001", "00000000", "00000024", goldenMessageSet)
+	expectGolden(t, "produce", roundTrip(t, conn, produce), goldenBytes(t,
+		"00000001",
+		"00000001", "00066f7264657273",
+		"00000001", "00000000", "0000", "0000000000000000", "ffffffffffffffff", // partition、error_code、base_offset、log_append_time
+		"00000000")) // throttle_time_ms
+
+	// Fetch v2：replica_id -1、max_wait 0、min_bytes 0、[orders [partition 0, offset 0, max_bytes 1024]]
+	fetch := goldenBytes(t,
+		"0001", "0002", "00000002", "0006676f6c64656e",
+		"ffffffff", "00000000", "00000000",
+		"00000001", "00066f7264657273",
+		"00000001", "00000000", "0000000000000000", "00000400")
+	expectGolden(t, "fetch", roundTrip(t, conn, fetch), goldenBytes(t,
+		"00000002",
+		"00000000", // throttle_time_ms
+		"00000001", "00066f7264657273",
+		"00000001", "00000000", "0000", "0000000000000001", // partition、error_code、high_watermark
+		"00000024", goldenMessageSet))
+
+	// Metadata v1：[orders]；broker按节点id排序，broker-b为1128309738，broker-a为1514795600
+	metadata := goldenBytes(t,
+		"0003", "0001", "00000003", "0006676f6c64656e",
+		"00000001", "00066f7264657273")
+	port := fmt.Sprintf("%08x", server.port)
+	partition := func(index, leader string) string {
+		return "0000" + index + leader + "00000001" + leader + "00000001" + leader // error_code、partition、leader、replicas、isr
+	}
+	expectGolden(t, "metadata", roundTrip(t, conn, metadata), goldenBytes(t,
+		"00000003",
+		"00000002",
+		"4340a3ea", "00093132372e302e302e32", port, "ffff", // broker-b：node_id、host、port、rack
+		"5a49f250", "00093132372e302e302e31", port, "ffff", // broker-a
+		"5a49f250",                                   // controller_id
+		"00000001", "0000", "00066f7264657273", "00", // error_code、name、is_internal
+		"00000004",
+		partition("00000000", "5a49f250"), partition("00000001", "5a49f250"),
+		partition("00000002", "4340a3ea"), partition("00000003", "4340a3ea")))
+
+	// JoinGroup v0：analytics、session_timeout 10000、新成员、consumer、[range [version 1, [orders], user_data null]]
+	protocolMetadata := "0001" + "00000001" + "00066f7264657273" + "ffffffff"
+	join := goldenBytes(t,
+		"000b", "0000", "00000004", "0006676f6c64656e",
+		"0009616e616c7974696373", "00002710", "0000", "0008636f6e73756d6572",
+		"00000001", "000572616e6765", "00000012", protocolMetadata)
+	response := roundTrip(t, conn, join)
+	// member_id由协调者生成，以client_id开头，从应答中取出后比较其余字节
+	d := &decoder{buf: response[4+2+4+7:]}
+	memberId := d.string()
+	if d.err != nil || !strings.HasPrefix(memberId, "golden-") {
+		t.Fatalf("unexpected member id %q in %x", memberId, response)
+	}
+	member := fmt.Sprintf("%04x%x", len(memberId), memberId)
+	expectGolden(t, "join group", response, goldenBytes(t,
+		"00000004",
+		"0000", "00000001", "000572616e6765", // error_code、generation_id、group_protocol
+		member, member, // leader_id、member_id
+		"00000001", member, "00000012", protocolMetadata))
+}
@@ -0,0 +1,602 @@
+package kafka
+
+import (
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// handleApiVersions 返回支持的api及版本区间
+func (self *Server) handleApiVersions(version int16, errorCode ErrorCode) []byte {
+	apiKeys := make([]int, 0, len(supportedApis))
+	for apiKey := range supportedApis {
+		apiKeys = append(apiKeys, int(apiKey))
+	}
+	sort.Ints(apiKeys)
+
+	e := &encoder{}
+	e.putInt16(int16(errorCode))
+	e.putArrayLength(len(apiKeys))
+	for _, apiKey := range apiKeys {
+		versions := supportedApis[int16(apiKey)]
+		e.putInt16(int16(apiKey))
+		e.putInt16(versions.min)
+		e.putInt16(versions.max)
+	}
+	if version >= 1 {
+		e.putInt32(0) // throttle_time_ms
+	}
+	return e.buf
+}
+
+// localNode 本broker的节点，host取连接的本地地址
+func (self *Server) localNode(conn net.Conn) *Node {
+	host, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		host = conn.LocalAddr().String()
+	}
+	return &Node{NodeId: nodeId(self.backend.BrokerName()), Host: host, Port: self.port}
+}
+
+type topicMetadata struct {
+	name      string
+	errorCode ErrorCode
+	layout    *topicLayout
+}
+
+// handleMetadata v0中空数组表示全部topic，v1中null表示全部topic；分区布局使用缓存，
+// 每个topic在缓存时间内最多查询一次路由，避免请求全部topic时逐个访问namesrv
+func (self *Server) handleMetadata(conn net.Conn, version int16, d *decoder) []byte {
+	n := d.arrayLength()
+	var topics []string
+	for i := 0; i < n; i++ {
+		topics = append(topics, d.string())
+	}
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || (version == 0 && n == 0) {
+		topics = self.backend.GetTopics()
+	}
+
+	local := self.localNode(conn)
+	nodes := make(map[int32]*Node)
+	metadatas := make([]*topicMetadata, 0, len(topics))
+	for _, topic := range topics {
+		layout, err := self.topicLayout(topic)
+		if err != nil {
+			metadatas = append(metadatas, &topicMetadata{name: topic, errorCode: toErrorCode(err)})
+			continue
+		}
+		if len(layout.partitions) == 0 {
+			metadatas = append(metadatas, &topicMetadata{name: topic, errorCode: ERR_UNKNOWN_TOPIC_OR_PARTITION})
+			continue
+		}
+		for id, node := range layout.nodes {
+			nodes[id] = node
+		}
+		metadatas = append(metadatas, &topicMetadata{name: topic, layout: layout})
+	}
+	if _, ok := nodes[local.NodeId]; !ok {
+		nodes[local.NodeId] = local
+	}
+	nodeIds := make([]int, 0, len(nodes))
+	for id := range nodes {
+		nodeIds = append(nodeIds, int(id))
+	}
+	sort.Ints(nodeIds)
+
+	e := &encoder{}
+	e.putArrayLength(len(nodeIds))
+	for _, id := range nodeIds {
+		node := nodes[int32(id)]
+		e.putInt32(node.NodeId)
+		e.putString(node.Host)
+		e.putInt32(node.Port)
+		if version >= 1 {
+			e.putNullableString(nil) // rack
+		}
+	}
+	if version >= 1 {
+		e.putInt32(local.NodeId) // controller_id
+	}
+	e.putArrayLength(len(metadatas))
+	for _, metadata := range metadatas {
+		e.putInt16(int16(metadata.errorCode))
+		e.putString(metadata.name)
+		if version >= 1 {
+			e.putBool(false) // is_internal
+		}
+		if metadata.layout == nil {
+			e.putArrayLength(0)
+			continue
+		}
+		e.putArrayLength(len(metadata.layout.partitions))
+		for index, p := range metadata.layout.partitions {
+			if p.leader == nil {
+				e.putInt16(int16(ERR_LEADER_NOT_AVAILABLE))
+				e.putInt32(int32(index))
+				e.putInt32(-1)
+				e.putArrayLength(0)
+				e.putArrayLength(0)
+				continue
+			}
+			e.putInt16(int16(ERR_NONE))
+			e.putInt32(int32(index))
+			e.putInt32(p.leader.NodeId)
+			e.putArrayLength(1) // replicas
+			e.putInt32(p.leader.NodeId)
+			e.putArrayLength(1) // isr
+			e.putInt32(p.leader.NodeId)
+		}
+	}
+	return e.buf
+}
+
+// handleProduce 每个分区的消息集合依次写入对应队列；acks=0时不应答
+func (self *Server) handleProduce(version int16, d *decoder) []byte {
+	acks := d.int16()
+	d.int32() // timeout_ms，写入为同步操作
+	e := &encoder{}
+	topicNums := d.arrayLength()
+	e.putArrayLength(topicNums)
+	for i := 0; i < topicNums; i++ {
+		topic := d.string()
+		e.putString(topic)
+		partitionNums := d.arrayLength()
+		e.putArrayLength(partitionNums)
+		for j := 0; j < partitionNums; j++ {
+			index := d.int32()
+			messageSet := d.bytes()
+			if d.err != nil {
+				return nil
+			}
+			baseOffset, errorCode := self.produce(topic, index, messageSet)
+			e.putInt32(index)
+			e.putInt16(int16(errorCode))
+			e.putInt64(baseOffset)
+			if version >= 2 {
+				e.putInt64(-1) // log_append_time，使用CreateTime
+			}
+		}
+	}
+	if d.err != nil {
+		return nil
+	}
+	if version >= 1 {
+		e.putInt32(0) // throttle_time_ms
+	}
+	if acks == 0 {
+		return nil
+	}
+	return e.buf
+}
+
+func (self *Server) produce(topic string, index int32, messageSet []byte) (int64, ErrorCode) {
+	p, errorCode := self.localPartition(topic, index)
+	if errorCode != ERR_NONE {
+		return -1, errorCode
+	}
+	records, err := decodeMessageSet(messageSet, false)
+	if err != nil || len(records) == 0 {
+		return -1, ERR_CORRUPT_MESSAGE
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, record := range records {
+		if record.Timestamp < 0 {
+			record.Timestamp = now
+		}
+	}
+	baseOffset, err := self.backend.PutMessages(topic, p.queueId, records)
+	if err != nil {
+		return -1, toErrorCode(err)
+	}
+	return baseOffset, ERR_NONE
+}
+
+type fetchPartition struct {
+	index    int32
+	offset   int64
+	maxBytes int32
+}
+
+type fetchTopic struct {
+	name       string
+	partitions []*fetchPartition
+}
+
+// handleFetch 按消费队列位点读取消息；数据不足min_bytes时在max_wait_ms内轮询
+func (self *Server) handleFetch(version int16, d *decoder) []byte {
+	d.int32() // replica_id
+	maxWait := time.Duration(d.int32()) * time.Millisecond
+	minBytes := d.int32()
+	maxBytes := int32(math.MaxInt32)
+	if version >= 3 {
+		maxBytes = d.int32()
+	}
+	topicNums := d.arrayLength()
+	var topics []*fetchTopic
+	for i := 0; i < topicNums; i++ {
+		topic := &fetchTopic{name: d.string()}
+		partitionNums := d.arrayLength()
+		for j := 0; j < partitionNums; j++ {
+			topic.partitions = append(topic.partitions, &fetchPartition{index: d.int32(), offset: d.int64(), maxBytes: d.int32()})
+		}
+		topics = append(topics, topic)
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	magic := messageMagic0
+	if version >= 2 {
+		magic = messageMagic1
+	}
+	deadline := time.Now().Add(maxWait)
+	for {
+		response, size, failed := self.fetch(version, topics, magic, maxBytes)
+		wait := deadline.Sub(time.Now())
+		if failed || size >= int(minBytes) || wait <= 0 {
+			return response
+		}
+		if wait > fetchPollInterval {
+			wait = fetchPollInterval
+		}
+		select {
+		case <-self.closeChan:
+			return response
+		case <-time.After(wait):
+		}
+	}
+}
+
+// fetch 读取一次，返回应答、消息总字节数以及是否有分区出错
+func (self *Server) fetch(version int16, topics []*fetchTopic, magic int8, maxBytes int32) ([]byte, int, bool) {
+	e := &encoder{}
+	if version >= 1 {
+		e.putInt32(0) // throttle_time_ms
+	}
+	budget := int(maxBytes)
+	total, failed := 0, false
+	e.putArrayLength(len(topics))
+	for _, topic := range topics {
+		e.putString(topic.name)
+		e.putArrayLength(len(topic.partitions))
+		for _, fp := range topic.partitions {
+			errorCode, highWatermark, records := self.fetchPartition(topic.name, fp, magic, budget)
+			messageSet := encodeMessageSet(records, magic)
+			budget -= len(messageSet)
+			total += len(messageSet)
+			if errorCode != ERR_NONE {
+				failed = true
+			}
+			e.putInt32(fp.index)
+			e.putInt16(int16(errorCode))
+			e.putInt64(highWatermark)
+			e.putBytes(messageSet)
+		}
+	}
+	return e.buf, total, failed
+}
+
+// fetchPartition 读取一个分区，至少返回一条消息，之后的消息不超过分区及整个应答的字节上限
+func (self *Server) fetchPartition(topic string, fp *fetchPartition, magic int8, budget int) (ErrorCode, int64, []*Record) {
+	p, errorCode := self.localPartition(topic, fp.index)
+	if errorCode != ERR_NONE {
+		return errorCode, -1, nil
+	}
+	minOffset := self.backend.GetMinOffset(topic, p.queueId)
+	maxOffset := self.backend.GetMaxOffset(topic, p.queueId)
+	if fp.offset < minOffset || fp.offset > maxOffset {
+		return ERR_OFFSET_OUT_OF_RANGE, maxOffset, nil
+	}
+	if fp.offset == maxOffset || budget <= 0 {
+		return ERR_NONE, maxOffset, nil
+	}
+
+	records, err := self.backend.GetMessages(topic, p.queueId, fp.offset, fetchMaxNumsPerQueue)
+	if err != nil {
+		return toErrorCode(err), maxOffset, nil
+	}
+	limit := int(fp.maxBytes)
+	if budget < limit {
+		limit = budget
+	}
+	size := 0
+	for i, record := range records {
+		size += messageSize(record, magic)
+		if i > 0 && size > limit {
+			return ERR_NONE, maxOffset, records[:i]
+		}
+	}
+	return ERR_NONE, maxOffset, records
+}
+
+// handleListOffsets -1表示最大位点，-2表示最小位点，其他按消息存储时间查找
+func (self *Server) handleListOffsets(version int16, d *decoder) []byte {
+	d.int32() // replica_id
+	e := &encoder{}
+	topicNums := d.arrayLength()
+	e.putArrayLength(topicNums)
+	for i := 0; i < topicNums; i++ {
+		topic := d.string()
+		e.putString(topic)
+		partitionNums := d.arrayLength()
+		e.putArrayLength(partitionNums)
+		for j := 0; j < partitionNums; j++ {
+			index := d.int32()
+			timestamp := d.int64()
+			if version == 0 {
+				d.int32() // max_num_offsets，只返回一个位点
+			}
+			if d.err != nil {
+				return nil
+			}
+			offset, errorCode := self.listOffset(topic, index, timestamp)
+			e.putInt32(index)
+			e.putInt16(int16(errorCode))
+			if version == 0 {
+				if errorCode != ERR_NONE {
+					e.putArrayLength(0)
+				} else {
+					e.putArrayLength(1)
+					e.putInt64(offset)
+				}
+				continue
+			}
+			if timestamp < 0 || errorCode != ERR_NONE {
+				e.putInt64(-1)
+			} else {
+				e.putInt64(timestamp)
+			}
+			e.putInt64(offset)
+		}
+	}
+	return e.buf
+}
+
+func (self *Server) listOffset(topic string, index int32, timestamp int64) (int64, ErrorCode) {
+	p, errorCode := self.localPartition(topic, index)
+	if errorCode != ERR_NONE {
+		return -1, errorCode
+	}
+	switch timestamp {
+	case OFFSET_LATEST:
+		return self.backend.GetMaxOffset(topic, p.queueId), ERR_NONE
+	case OFFSET_EARLIEST:
+		return self.backend.GetMinOffset(topic, p.queueId), ERR_NONE
+	default:
+		return self.backend.GetOffsetByTime(topic, p.queueId, timestamp), ERR_NONE
+	}
+}
+
+// anyPartition 查找分区，不要求在本broker上
+func (self *Server) anyPartition(topic string, index int32) (*partition, ErrorCode) {
+	layout, err := self.topicLayout(topic)
+	if err != nil {
+		return nil, toErrorCode(err)
+	}
+	p := layout.partition(index)
+	if p == nil {
+		return nil, ERR_UNKNOWN_TOPIC_OR_PARTITION
+	}
+	return p, ERR_NONE
+}
+
+// handleOffsetCommit 提交的位点为下一条要消费的消息，与smartgo的消费位点语义一致
+func (self *Server) handleOffsetCommit(version int16, d *decoder) []byte {
+	group := d.string()
+	generation, memberId := int32(-1), ""
+	if version >= 1 {
+		generation = d.int32()
+		memberId = d.string()
+	}
+	if version >= 2 {
+		d.int64() // retention_time_ms，位点由ConsumerOffsetManager持久化
+	}
+	if d.err != nil {
+		return nil
+	}
+	groupErrorCode := self.coordinator.validateCommit(group, generation, memberId)
+
+	e := &encoder{}
+	topicNums := d.arrayLength()
+	e.putArrayLength(topicNums)
+	for i := 0; i < topicNums; i++ {
+		topic := d.string()
+		e.putString(topic)
+		partitionNums := d.arrayLength()
+		e.putArrayLength(partitionNums)
+		for j := 0; j < partitionNums; j++ {
+			index := d.int32()
+			offset := d.int64()
+			if version == 1 {
+				d.int64() // commit_timestamp
+			}
+			d.nullableString() // metadata，不保存
+			if d.err != nil {
+				return nil
+			}
+			errorCode := groupErrorCode
+			if errorCode == ERR_NONE {
+				errorCode = self.commitOffset(group, topic, index, offset)
+			}
+			e.putInt32(index)
+			e.putInt16(int16(errorCode))
+		}
+	}
+	return e.buf
+}
+
+func (self *Server) commitOffset(group, topic string, index int32, offset int64) ErrorCode {
+	if group == "" {
+		return ERR_INVALID_GROUP_ID
+	}
+	p, errorCode := self.anyPartition(topic, index)
+	if errorCode != ERR_NONE {
+		return errorCode
+	}
+	return toErrorCode(self.backend.CommitOffset(group, p.messageQueue(topic), p.brokerAddr, offset))
+}
+
+// handleOffsetFetch 未提交过位点的分区返回-1，由客户端按auto.offset.reset处理
+func (self *Server) handleOffsetFetch(version int16, d *decoder) []byte {
+	group := d.string()
+	e := &encoder{}
+	topicNums := d.arrayLength()
+	e.putArrayLength(topicNums)
+	for i := 0; i < topicNums; i++ {
+		topic := d.string()
+		e.putString(topic)
+		partitionNums := d.arrayLength()
+		e.putArrayLength(partitionNums)
+		for j := 0; j < partitionNums; j++ {
+			index := d.int32()
+			if d.err != nil {
+				return nil
+			}
+			offset, errorCode := self.queryOffset(group, topic, index)
+			e.putInt32(index)
+			e.putInt64(offset)
+			e.putString("") // metadata
+			e.putInt16(int16(errorCode))
+		}
+	}
+	return e.buf
+}
+
+func (self *Server) queryOffset(group, topic string, index int32) (int64, ErrorCode) {
+	if group == "" {
+		return -1, ERR_INVALID_GROUP_ID
+	}
+	p, errorCode := self.anyPartition(topic, index)
+	if errorCode != ERR_NONE {
+		return -1, errorCode
+	}
+	offset, err := self.backend.QueryOffset(group, p.messageQueue(topic), p.brokerAddr)
+	if err != nil {
+		return -1, toErrorCode(err)
+	}
+	if offset < 0 {
+		offset = -1
+	}
+	return offset, ERR_NONE
+}
+
+// handleFindCoordinator 各broker按相同规则为消费组选择协调者，查询集群失败时由本broker协调
+func (self *Server) handleFindCoordinator(conn net.Conn, d *decoder) []byte {
+	group := d.string()
+	if d.err != nil {
+		return nil
+	}
+	node := self.localNode(conn)
+	if brokers, err := self.backend.GetBrokers(); err == nil {
+		if brokerName, brokerAddr := chooseCoordinator(group, brokers); brokerName != "" && brokerName != self.backend.BrokerName() {
+			node = newNode(brokerName, brokerAddr, self.port)
+		}
+	}
+
+	e := &encoder{}
+	if group == "" {
+		e.putInt16(int16(ERR_INVALID_GROUP_ID))
+		e.putInt32(-1)
+		e.putString("")
+		e.putInt32(-1)
+		return e.buf
+	}
+	e.putInt16(int16(ERR_NONE))
+	e.putInt32(node.NodeId)
+	e.putString(node.Host)
+	e.putInt32(node.Port)
+	return e.buf
+}
+
+// handleJoinGroup 阻塞直到本轮rebalance完成
+func (self *Server) handleJoinGroup(clientId string, d *decoder) []byte {
+	group := d.string()
+	sessionTimeout := d.int32()
+	memberId := d.string()
+	protocolType := d.string()
+	protocolNums := d.arrayLength()
+	var protocols []*GroupProtocol
+	for i := 0; i < protocolNums; i++ {
+		protocols = append(protocols, &GroupProtocol{Name: d.string(), Metadata: copyBytes(d.bytes())})
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	result := self.coordinator.join(group, memberId, clientId, protocolType, sessionTimeout, protocols)
+	e := &encoder{}
+	e.putInt16(int16(result.ErrorCode))
+	if result.ErrorCode != ERR_NONE {
+		e.putInt32(-1)
+		e.putString("")
+		e.putString("")
+		e.putString(memberId)
+		e.putArrayLength(0)
+		return e.buf
+	}
+	e.putInt32(result.GenerationId)
+	e.putString(result.Protocol)
+	e.putString(result.LeaderId)
+	e.putString(result.MemberId)
+	e.putArrayLength(len(result.Members))
+	for _, member := range result.Members {
+		e.putString(member.MemberId)
+		e.putBytes(nonNilBytes(member.Metadata))
+	}
+	return e.buf
+}
+
+func (self *Server) handleSyncGroup(d *decoder) []byte {
+	group := d.string()
+	generation := d.int32()
+	memberId := d.string()
+	assignmentNums := d.arrayLength()
+	assignments := make(map[string][]byte)
+	for i := 0; i < assignmentNums; i++ {
+		assignments[d.string()] = copyBytes(d.bytes())
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	result := self.coordinator.sync(group, generation, memberId, assignments)
+	e := &encoder{}
+	e.putInt16(int16(result.errorCode))
+	e.putBytes(nonNilBytes(result.assignment))
+	return e.buf
+}
+
+func (self *Server) handleHeartbeat(d *decoder) []byte {
+	group := d.string()
+	generation := d.int32()
+	memberId := d.string()
+	if d.err != nil {
+		return nil
+	}
+	e := &encoder{}
+	e.putInt16(int16(self.coordinator.heartbeat(group, generation, memberId)))
+	return e.buf
+}
+
+func (self *Server) handleLeaveGroup(d *decoder) []byte {
+	group := d.string()
+	memberId := d.string()
+	if d.err != nil {
+		return nil
+	}
+	e := &encoder{}
+	e.putInt16(int16(self.coordinator.leave(group, memberId)))
+	return e.buf
+}
+
+// nonNilBytes 非nullable的bytes字段，nil编码为空
+func nonNilBytes(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
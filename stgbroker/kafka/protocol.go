@@ -0,0 +1,425 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Kafka请求的api key，仅实现非flexible版本
+const (
+	API_PRODUCE          int16 = 0
+	API_FETCH            int16 = 1
+	API_LIST_OFFSETS     int16 = 2
+	API_METADATA         int16 = 3
+	API_OFFSET_COMMIT    int16 = 8
+	API_OFFSET_FETCH     int16 = 9
+	API_FIND_COORDINATOR int16 = 10
+	API_JOIN_GROUP       int16 = 11
+	API_HEARTBEAT        int16 = 12
+	API_LEAVE_GROUP      int16 = 13
+	API_SYNC_GROUP       int16 = 14
+	API_API_VERSIONS     int16 = 18
+)
+
+// apiVersionRange 支持的api版本区间
+type apiVersionRange struct {
+	min int16
+	max int16
+}
+
+// supportedApis 各api支持的版本，ApiVersions请求按此应答
+var supportedApis = map[int16]apiVersionRange{
+	API_PRODUCE:          {0, 2},
+	API_FETCH:            {0, 3},
+	API_LIST_OFFSETS:     {0, 1},
+	API_METADATA:         {0, 1},
+	API_OFFSET_COMMIT:    {0, 2},
+	API_OFFSET_FETCH:     {0, 1},
+	API_FIND_COORDINATOR: {0, 0},
+	API_JOIN_GROUP:       {0, 0},
+	API_HEARTBEAT:        {0, 0},
+	API_LEAVE_GROUP:      {0, 0},
+	API_SYNC_GROUP:       {0, 0},
+	API_API_VERSIONS:     {0, 1},
+}
+
+// ListOffsets请求中的特殊时间戳
+const (
+	OFFSET_LATEST   int64 = -1
+	OFFSET_EARLIEST int64 = -2
+)
+
+// ErrorCode Kafka协议的错误码
+// Author agent
+// Since 2026/10/19
+type ErrorCode int16
+
+// Kafka协议的错误码，仅列出本前端会用到的部分
+const (
+	ERR_UNKNOWN_SERVER_ERROR        ErrorCode = -1
+	ERR_NONE                        ErrorCode = 0
+	ERR_OFFSET_OUT_OF_RANGE         ErrorCode = 1
+	ERR_CORRUPT_MESSAGE             ErrorCode = 2
+	ERR_UNKNOWN_TOPIC_OR_PARTITION  ErrorCode = 3
+	ERR_LEADER_NOT_AVAILABLE        ErrorCode = 5
+	ERR_NOT_LEADER_FOR_PARTITION    ErrorCode = 6
+	ERR_REQUEST_TIMED_OUT           ErrorCode = 7
+	ERR_MESSAGE_TOO_LARGE           ErrorCode = 10
+	ERR_COORDINATOR_NOT_AVAILABLE   ErrorCode = 15
+	ERR_NOT_COORDINATOR             ErrorCode = 16
+	ERR_ILLEGAL_GENERATION          ErrorCode = 22
+	ERR_INCONSISTENT_GROUP_PROTOCOL ErrorCode = 23
+	ERR_INVALID_GROUP_ID            ErrorCode = 24
+	ERR_UNKNOWN_MEMBER_ID           ErrorCode = 25
+	ERR_INVALID_SESSION_TIMEOUT     ErrorCode = 26
+	ERR_REBALANCE_IN_PROGRESS       ErrorCode = 27
+	ERR_UNSUPPORTED_VERSION         ErrorCode = 35
+	ERR_INVALID_REQUEST             ErrorCode = 42
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	ERR_UNKNOWN_SERVER_ERROR:        "UNKNOWN_SERVER_ERROR",
+	ERR_NONE:                        "NONE",
+	ERR_OFFSET_OUT_OF_RANGE:         "OFFSET_OUT_OF_RANGE",
+	ERR_CORRUPT_MESSAGE:             "CORRUPT_MESSAGE",
+	ERR_UNKNOWN_TOPIC_OR_PARTITION:  "UNKNOWN_TOPIC_OR_PARTITION",
+	ERR_LEADER_NOT_AVAILABLE:        "LEADER_NOT_AVAILABLE",
+	ERR_NOT_LEADER_FOR_PARTITION:    "NOT_LEADER_FOR_PARTITION",
+	ERR_REQUEST_TIMED_OUT:           "REQUEST_TIMED_OUT",
+	ERR_MESSAGE_TOO_LARGE:           "MESSAGE_TOO_LARGE",
+	ERR_COORDINATOR_NOT_AVAILABLE:   "COORDINATOR_NOT_AVAILABLE",
+	ERR_NOT_COORDINATOR:             "NOT_COORDINATOR",
+	ERR_ILLEGAL_GENERATION:          "ILLEGAL_GENERATION",
+	ERR_INCONSISTENT_GROUP_PROTOCOL: "INCONSISTENT_GROUP_PROTOCOL",
+	ERR_INVALID_GROUP_ID:            "INVALID_GROUP_ID",
+	ERR_UNKNOWN_MEMBER_ID:           "UNKNOWN_MEMBER_ID",
+	ERR_INVALID_SESSION_TIMEOUT:     "INVALID_SESSION_TIMEOUT",
+	ERR_REBALANCE_IN_PROGRESS:       "REBALANCE_IN_PROGRESS",
+	ERR_UNSUPPORTED_VERSION:         "UNSUPPORTED_VERSION",
+	ERR_INVALID_REQUEST:             "INVALID_REQUEST",
+}
+
+func (code ErrorCode) Error() string {
+	if name, ok := errorCodeNames[code]; ok {
+		return fmt.Sprintf("kafka error %d %s", int16(code), name)
+	}
+	return fmt.Sprintf("kafka error %d", int16(code))
+}
+
+// toErrorCode Backend返回的错误转为错误码，非ErrorCode的错误视为UNKNOWN_SERVER_ERROR
+func toErrorCode(err error) ErrorCode {
+	if err == nil {
+		return ERR_NONE
+	}
+	if code, ok := err.(ErrorCode); ok {
+		return code
+	}
+	return ERR_UNKNOWN_SERVER_ERROR
+}
+
+// errShortBuffer 请求内容不完整
+var errShortBuffer = errors.New("kafka: insufficient data to decode")
+
+// encoder 按Kafka协议的大端格式编码
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) putInt8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) putBool(v bool) {
+	if v {
+		e.putInt8(1)
+	} else {
+		e.putInt8(0)
+	}
+}
+
+func (e *encoder) putInt16(v int16) {
+	e.buf = append(e.buf, byte(uint16(v)>>8), byte(v))
+}
+
+func (e *encoder) putInt32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) putInt64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) putString(v string) {
+	e.putInt16(int16(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) putNullableString(v *string) {
+	if v == nil {
+		e.putInt16(-1)
+		return
+	}
+	e.putString(*v)
+}
+
+// putBytes nil编码为null
+func (e *encoder) putBytes(v []byte) {
+	if v == nil {
+		e.putInt32(-1)
+		return
+	}
+	e.putInt32(int32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) putArrayLength(n int) {
+	e.putInt32(int32(n))
+}
+
+// reserveInt32 预留4字节，返回位置供之后回填
+func (e *encoder) reserveInt32() int {
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	return len(e.buf) - 4
+}
+
+func (e *encoder) fillInt32(pos int, v int32) {
+	binary.BigEndian.PutUint32(e.buf[pos:], uint32(v))
+}
+
+// decoder 按Kafka协议的大端格式解码，出错后的读取均返回零值，由调用方最后检查err
+type decoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *decoder) remaining() int {
+	return len(d.buf) - d.off
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.remaining() < n {
+		d.err = errShortBuffer
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool {
+	return d.int8() != 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) nullableString() *string {
+	n := d.int16()
+	if n < 0 || d.err != nil {
+		return nil
+	}
+	s := string(d.take(int(n)))
+	return &s
+}
+
+// bytes null解码为nil，返回的切片引用原始缓冲区
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return nil
+	}
+	return d.take(int(n))
+}
+
+// arrayLength 数组长度，null数组返回-1；长度超过剩余字节数时视为非法请求
+func (d *decoder) arrayLength() int {
+	n := d.int32()
+	if d.err != nil {
+		return 0
+	}
+	if n < 0 {
+		return -1
+	}
+	if int(n) > d.remaining() {
+		d.err = errShortBuffer
+		return 0
+	}
+	return int(n)
+}
+
+// Record Kafka消息，对应smartgo队列中的一条消息
+// Author agent
+// Since 2026/10/19
+type Record struct {
+	Offset    int64  // 消费队列中的逻辑位点
+	Timestamp int64  // 消息创建时间，毫秒
+	Key       []byte // 消息key，nil表示无key
+	Value     []byte // 消息体
+}
+
+// MessageSet的格式常量，只支持magic 0、1，不支持压缩
+const (
+	messageMagic0         int8 = 0
+	messageMagic1         int8 = 1
+	compressionCodecMask  int8 = 0x07
+	messageSetLogOverhead      = 12 // offset(8) + messageSize(4)
+)
+
+// encodeMessageSet 按指定magic编码消息集合
+func encodeMessageSet(records []*Record, magic int8) []byte {
+	e := &encoder{}
+	for _, record := range records {
+		appendMessage(e, record, magic)
+	}
+	if e.buf == nil {
+		return []byte{}
+	}
+	return e.buf
+}
+
+// appendMessage 追加一条消息：offset、size、crc、magic、attributes、[timestamp]、key、value
+func appendMessage(e *encoder, record *Record, magic int8) {
+	e.putInt64(record.Offset)
+	sizePos := e.reserveInt32()
+	crcPos := e.reserveInt32()
+	e.putInt8(magic)
+	e.putInt8(0)
+	if magic >= messageMagic1 {
+		e.putInt64(record.Timestamp)
+	}
+	e.putBytes(record.Key)
+	e.putBytes(record.Value)
+	e.fillInt32(crcPos, int32(crc32.ChecksumIEEE(e.buf[crcPos+4:])))
+	e.fillInt32(sizePos, int32(len(e.buf)-crcPos))
+}
+
+// messageSize 编码后的字节数
+func messageSize(record *Record, magic int8) int {
+	size := messageSetLogOverhead + 4 + 1 + 1 + 4 + len(record.Key) + 4 + len(record.Value)
+	if magic >= messageMagic1 {
+		size += 8
+	}
+	return size
+}
+
+// decodeMessageSet 解码消息集合；partial为true时忽略结尾不完整的消息(Fetch应答允许截断)
+func decodeMessageSet(data []byte, partial bool) ([]*Record, error) {
+	var records []*Record
+	d := &decoder{buf: data}
+	for d.remaining() > 0 {
+		if d.remaining() < messageSetLogOverhead {
+			if partial {
+				break
+			}
+			return nil, ERR_CORRUPT_MESSAGE
+		}
+		offset := d.int64()
+		size := d.int32()
+		if size < 0 || int(size) > d.remaining() {
+			if partial && size >= 0 {
+				break
+			}
+			return nil, ERR_CORRUPT_MESSAGE
+		}
+		record, err := decodeMessage(d.take(int(size)))
+		if err != nil {
+			return nil, err
+		}
+		record.Offset = offset
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func decodeMessage(data []byte) (*Record, error) {
+	d := &decoder{buf: data}
+	crc := uint32(d.int32())
+	if d.err != nil || crc != crc32.ChecksumIEEE(data[4:]) {
+		return nil, ERR_CORRUPT_MESSAGE
+	}
+	magic := d.int8()
+	attributes := d.int8()
+	if magic > messageMagic1 || attributes&compressionCodecMask != 0 {
+		return nil, ERR_CORRUPT_MESSAGE
+	}
+	record := &Record{Timestamp: -1}
+	if magic == messageMagic1 {
+		record.Timestamp = d.int64()
+	}
+	record.Key = copyBytes(d.bytes())
+	record.Value = copyBytes(d.bytes())
+	if d.err != nil || d.remaining() != 0 {
+		return nil, ERR_CORRUPT_MESSAGE
+	}
+	if record.Value == nil {
+		record.Value = []byte{}
+	}
+	return record, nil
+}
+
+// copyBytes 复制解码出的切片，避免引用请求缓冲区
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// requestHeader 请求头v1：api_key、api_version、correlation_id、client_id
+type requestHeader struct {
+	apiKey        int16
+	apiVersion    int16
+	correlationId int32
+	clientId      string
+}
+
+func decodeRequestHeader(d *decoder) *requestHeader {
+	header := &requestHeader{apiKey: d.int16(), apiVersion: d.int16(), correlationId: d.int32()}
+	if clientId := d.nullableString(); clientId != nil {
+		header.clientId = *clientId
+	}
+	return header
+}
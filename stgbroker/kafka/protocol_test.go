@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"bytes"
+	"hash/crc32"
+	"testing"
+)
+
+func TestMessageSetEncodeDecode(t *testing.T) {
+	records := []*Record{
+		{Offset: 7, Timestamp: 1512000000000, Key: []byte("k1"), Value: []byte("v1")},
+		{Offset: 8, Timestamp: 1512000000001, Value: []byte{}},
+	}
+	for _, magic := range []int8{messageMagic0, messageMagic1} {
+		data := encodeMessageSet(records, magic)
+		if size := messageSize(records[0], magic) + messageSize(records[1], magic); size != len(data) {
+			t.Fatalf("magic %d: messageSize %d, encoded %d", magic, size, len(data))
+		}
+		decoded, err := decodeMessageSet(data, false)
+		if err != nil {
+			t.Fatalf("magic %d: %v", magic, err)
+		}
+		if len(decoded) != 2 {
+			t.Fatalf("magic %d: expect 2 records, got %d", magic, len(decoded))
+		}
+		for i, record := range decoded {
+			if record.Offset != records[i].Offset || !bytes.Equal(record.Key, records[i].Key) || !bytes.Equal(record.Value, records[i].Value) {
+				t.Fatalf("magic %d: unexpected record %+v", magic, record)
+			}
+		}
+		if decoded[1].Key != nil {
+			t.Fatalf("magic %d: null key decoded as %q", magic, decoded[1].Key)
+		}
+		if magic == messageMagic0 && decoded[0].Timestamp != -1 {
+			t.Fatalf("magic 0 has no timestamp, got %d", decoded[0].Timestamp)
+		}
+		if magic == messageMagic1 && decoded[0].Timestamp != records[0].Timestamp {
+			t.Fatalf("magic 1 timestamp %d", decoded[0].Timestamp)
+		}
+
+		// Fetch应答允许截断最后一条消息
+		partial, err := decodeMessageSet(data[:len(data)-3], true)
+		if err != nil || len(partial) != 1 {
+			t.Fatalf("magic %d: partial decode %d records, err %v", magic, len(partial), err)
+		}
+		if _, err := decodeMessageSet(data[:len(data)-3], false); err != ERR_CORRUPT_MESSAGE {
+			t.Fatalf("magic %d: expect corrupt message, got %v", magic, err)
+		}
+	}
+}
+
+func TestMessageSetDecodeInvalid(t *testing.T) {
+	data := encodeMessageSet([]*Record{{Value: []byte("value")}}, messageMagic1)
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if _, err := decodeMessageSet(corrupted, false); err != ERR_CORRUPT_MESSAGE {
+		t.Fatalf("crc mismatch should be rejected, got %v", err)
+	}
+
+	// 不支持压缩：attributes的低3位非0
+	e := &encoder{}
+	e.putInt64(0)
+	sizePos := e.reserveInt32()
+	crcPos := e.reserveInt32()
+	e.putInt8(messageMagic1)
+	e.putInt8(1) // gzip
+	e.putInt64(0)
+	e.putBytes(nil)
+	e.putBytes([]byte("value"))
+	e.fillInt32(crcPos, int32(crc32.ChecksumIEEE(e.buf[crcPos+4:])))
+	e.fillInt32(sizePos, int32(len(e.buf)-crcPos))
+	if _, err := decodeMessageSet(e.buf, false); err != ERR_CORRUPT_MESSAGE {
+		t.Fatalf("compressed message should be rejected, got %v", err)
+	}
+}
+
+func TestDecoderShortBuffer(t *testing.T) {
+	e := &encoder{}
+	e.putString("topic")
+	e.putArrayLength(1000)
+	d := &decoder{buf: e.buf}
+	if d.string() != "topic" {
+		t.Fatal("decode string failed")
+	}
+	if n := d.arrayLength(); n != 0 || d.err != errShortBuffer {
+		t.Fatalf("array length larger than remaining should fail, n=%d err=%v", n, d.err)
+	}
+	if d.int64() != 0 {
+		t.Fatal("read after error should return zero")
+	}
+}
@@ -0,0 +1,324 @@
+package kafka
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+const (
+	maxRequestSize         = 64 * 1024 * 1024       // 单个请求的最大字节数
+	fetchMaxNumsPerQueue   = 256                    // 每个分区单次读取的最大消息数
+	fetchPollInterval      = 100 * time.Millisecond // Fetch未达到min_bytes时重新读取的间隔
+	topicLayoutCacheMillis = 10 * 1000              // 分区布局的缓存时间，分区不在本broker时提前失效
+	memberExpireInterval   = time.Second            // 检查消费组成员会话超时的间隔
+)
+
+// Server Kafka协议前端：独立监听端口，将topic的分区映射为smartgo的队列
+//
+// 注意：只实现非flexible版本的Produce/Fetch/ListOffsets/Metadata/OffsetCommit/OffsetFetch、
+// 最小化的消费组协调以及ApiVersions，不支持压缩、事务、幂等及SASL鉴权
+//
+// Author agent
+// Since 2026/10/19
+type Server struct {
+	addr        string
+	backend     Backend
+	coordinator *groupCoordinator
+	listener    net.Listener
+	port        int32
+	conns       map[net.Conn]struct{}
+	layouts     map[string]*cachedLayout
+	lock        sync.Mutex
+	closeChan   chan struct{}
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+type cachedLayout struct {
+	layout   *topicLayout
+	expireAt int64
+}
+
+// NewServer 初始化Kafka协议前端
+// Author agent
+// Since 2026/10/19
+func NewServer(addr string, backend Backend) *Server {
+	return &Server{
+		addr:        addr,
+		backend:     backend,
+		coordinator: newGroupCoordinator(),
+		conns:       make(map[net.Conn]struct{}),
+		layouts:     make(map[string]*cachedLayout),
+		closeChan:   make(chan struct{}),
+	}
+}
+
+// Start 开始监听
+// Author agent
+// Since 2026/10/19
+func (self *Server) Start() error {
+	listener, err := net.Listen("tcp", self.addr)
+	if err != nil {
+		return err
+	}
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	portNum, _ := strconv.Atoi(port)
+	self.listener = listener
+	self.port = int32(portNum)
+	self.coordinator.start(memberExpireInterval)
+
+	self.wg.Add(1)
+	go self.acceptLoop()
+	logger.Infof("kafka server start successful, listen %s", listener.Addr().String())
+	return nil
+}
+
+// Shutdown 停止监听并关闭全部连接
+// Author agent
+// Since 2026/10/19
+func (self *Server) Shutdown() {
+	self.closeOnce.Do(func() {
+		close(self.closeChan)
+		self.coordinator.shutdown()
+		if self.listener != nil {
+			self.listener.Close()
+		}
+		self.lock.Lock()
+		for conn := range self.conns {
+			conn.Close()
+		}
+		self.lock.Unlock()
+		self.wg.Wait()
+		logger.Info("kafka server shutdown successful")
+	})
+}
+
+// Addr 实际监听的地址
+// Author agent
+// Since 2026/10/19
+func (self *Server) Addr() string {
+	if self.listener != nil {
+		return self.listener.Addr().String()
+	}
+	return self.addr
+}
+
+// ConnectionCount 当前连接数
+// Author agent
+// Since 2026/10/19
+func (self *Server) ConnectionCount() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return len(self.conns)
+}
+
+func (self *Server) acceptLoop() {
+	defer self.wg.Done()
+	for {
+		conn, err := self.listener.Accept()
+		if err != nil {
+			select {
+			case <-self.closeChan:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			logger.Errorf("kafka server accept err: %s", err.Error())
+			return
+		}
+
+		self.lock.Lock()
+		select {
+		case <-self.closeChan:
+			self.lock.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		self.conns[conn] = struct{}{}
+		self.wg.Add(1)
+		self.lock.Unlock()
+		go self.serveConn(conn)
+	}
+}
+
+// serveConn 按顺序处理一个连接上的请求，Kafka协议要求应答顺序与请求一致
+func (self *Server) serveConn(conn net.Conn) {
+	defer self.wg.Done()
+	defer func() {
+		self.lock.Lock()
+		delete(self.conns, conn)
+		self.lock.Unlock()
+		conn.Close()
+	}()
+	defer utils.RecoveredFn()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(reader, size[:]); err != nil {
+			return
+		}
+		length := int32(binary.BigEndian.Uint32(size[:]))
+		if length <= 0 || length > maxRequestSize {
+			logger.Warnf("kafka request from %s invalid size %d, close connection", conn.RemoteAddr().String(), length)
+			return
+		}
+		request := make([]byte, length)
+		if _, err := io.ReadFull(reader, request); err != nil {
+			return
+		}
+
+		d := &decoder{buf: request}
+		header := decodeRequestHeader(d)
+		if d.err != nil {
+			logger.Warnf("kafka request from %s invalid header, close connection", conn.RemoteAddr().String())
+			return
+		}
+		response, err := self.handle(conn, header, d)
+		if err != nil {
+			logger.Warnf("kafka request from %s apiKey=%d, apiVersion=%d err: %s, close connection",
+				conn.RemoteAddr().String(), header.apiKey, header.apiVersion, err.Error())
+			return
+		}
+		if response == nil {
+			continue // acks=0的Produce请求不应答
+		}
+
+		e := &encoder{buf: make([]byte, 8, 8+len(response))}
+		e.fillInt32(0, int32(4+len(response)))
+		e.fillInt32(4, header.correlationId)
+		e.buf = append(e.buf, response...)
+		if _, err := writer.Write(e.buf); err != nil {
+			return
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handle 处理请求，返回应答体(不含长度及correlation_id)；返回error时关闭连接
+func (self *Server) handle(conn net.Conn, header *requestHeader, d *decoder) ([]byte, error) {
+	versions, ok := supportedApis[header.apiKey]
+	if !ok {
+		return nil, fmt.Errorf("unsupported api key")
+	}
+	if header.apiVersion < versions.min || header.apiVersion > versions.max {
+		if header.apiKey == API_API_VERSIONS {
+			// 客户端据此降级到支持的版本
+			return self.handleApiVersions(0, ERR_UNSUPPORTED_VERSION), nil
+		}
+		return nil, fmt.Errorf("unsupported api version")
+	}
+
+	var response []byte
+	switch header.apiKey {
+	case API_API_VERSIONS:
+		response = self.handleApiVersions(header.apiVersion, ERR_NONE)
+	case API_METADATA:
+		response = self.handleMetadata(conn, header.apiVersion, d)
+	case API_PRODUCE:
+		response = self.handleProduce(header.apiVersion, d)
+	case API_FETCH:
+		response = self.handleFetch(header.apiVersion, d)
+	case API_LIST_OFFSETS:
+		response = self.handleListOffsets(header.apiVersion, d)
+	case API_OFFSET_COMMIT:
+		response = self.handleOffsetCommit(header.apiVersion, d)
+	case API_OFFSET_FETCH:
+		response = self.handleOffsetFetch(header.apiVersion, d)
+	case API_FIND_COORDINATOR:
+		response = self.handleFindCoordinator(conn, d)
+	case API_JOIN_GROUP:
+		response = self.handleJoinGroup(header.clientId, d)
+	case API_SYNC_GROUP:
+		response = self.handleSyncGroup(d)
+	case API_HEARTBEAT:
+		response = self.handleHeartbeat(d)
+	case API_LEAVE_GROUP:
+		response = self.handleLeaveGroup(d)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return response, nil
+}
+
+// topicLayout 获取topic的分区布局，缓存过期或已失效时重新查询路由
+func (self *Server) topicLayout(topic string) (*topicLayout, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	self.lock.Lock()
+	cached, ok := self.layouts[topic]
+	self.lock.Unlock()
+	if ok && cached.expireAt > now {
+		return cached.layout, nil
+	}
+
+	routeData, err := self.backend.GetTopicRoute(topic)
+	if err != nil {
+		return nil, err
+	}
+	if routeData == nil {
+		routeData = route.NewTopicRouteData()
+	}
+	layout := buildTopicLayout(routeData, self.port)
+	self.lock.Lock()
+	self.layouts[topic] = &cachedLayout{layout: layout, expireAt: now + topicLayoutCacheMillis}
+	self.lock.Unlock()
+	return layout, nil
+}
+
+// invalidateLayout 丢弃topic缓存的分区布局，下一次请求重新查询路由
+func (self *Server) invalidateLayout(topic string) {
+	self.lock.Lock()
+	delete(self.layouts, topic)
+	self.lock.Unlock()
+}
+
+// localPartition 校验分区属于本broker，返回对应的队列；分区不存在或不在本broker时丢弃缓存，
+// 客户端收到错误后重新发送的Metadata请求即可取得最新的布局
+func (self *Server) localPartition(topic string, index int32) (*partition, ErrorCode) {
+	layout, err := self.topicLayout(topic)
+	if err != nil {
+		return nil, toErrorCode(err)
+	}
+	p := layout.partition(index)
+	if p == nil {
+		self.invalidateLayout(topic)
+		return nil, ERR_UNKNOWN_TOPIC_OR_PARTITION
+	}
+	if p.brokerName != self.backend.BrokerName() {
+		self.invalidateLayout(topic)
+		return nil, ERR_NOT_LEADER_FOR_PARTITION
+	}
+	return p, ERR_NONE
+}
+
+// closed 是否已关闭
+func (self *Server) closed() bool {
+	select {
+	case <-self.closeChan:
+		return true
+	default:
+		return false
+	}
+}
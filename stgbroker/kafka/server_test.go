@@ -0,0 +1,565 @@
+package kafka
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+)
+
+const (
+	testTopic  = "orders"
+	testGroup  = "analytics"
+	testLocal  = "broker-a"
+	testRemote = "broker-b"
+)
+
+// fakeBackend 内存中的broker：本地broker-a有2个队列，远端broker-b有2个队列
+type fakeBackend struct {
+	lock    sync.Mutex
+	queues  map[string][][]*Record
+	minOffs map[string]int64
+	offsets map[string]int64
+	routes  int // GetTopicRoute的调用次数
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		queues:  map[string][][]*Record{testTopic: make([][]*Record, 2)},
+		minOffs: make(map[string]int64),
+		offsets: make(map[string]int64),
+	}
+}
+
+func (fb *fakeBackend) setMinOffset(topic string, queueId int32, offset int64) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	fb.minOffs[fmt.Sprintf("%s@%d", topic, queueId)] = offset
+}
+
+func (fb *fakeBackend) committed(group, topic, brokerName string, queueId int) int64 {
+	offset, _ := fb.QueryOffset(group, &message.MessageQueue{Topic: topic, BrokerName: brokerName, QueueId: queueId}, "")
+	return offset
+}
+
+func (fb *fakeBackend) BrokerName() string {
+	return testLocal
+}
+
+func (fb *fakeBackend) GetTopics() []string {
+	return []string{testTopic}
+}
+
+func (fb *fakeBackend) routeQueries() int {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	return fb.routes
+}
+
+func (fb *fakeBackend) GetTopicRoute(topic string) (*route.TopicRouteData, error) {
+	fb.lock.Lock()
+	fb.routes++
+	fb.lock.Unlock()
+	if topic != testTopic {
+		return nil, ERR_UNKNOWN_TOPIC_OR_PARTITION
+	}
+	routeData := route.NewTopicRouteData()
+	perm := constant.PERM_READ | constant.PERM_WRITE
+	routeData.QueueDatas = append(routeData.QueueDatas,
+		&route.QueueData{BrokerName: testRemote, ReadQueueNums: 2, WriteQueueNums: 2, Perm: perm},
+		&route.QueueData{BrokerName: testLocal, ReadQueueNums: 2, WriteQueueNums: 2, Perm: perm})
+	routeData.BrokerDatas = append(routeData.BrokerDatas,
+		&route.BrokerData{BrokerName: testLocal, BrokerAddrs: map[int]string{0: "127.0.0.1:10911"}},
+		&route.BrokerData{BrokerName: testRemote, BrokerAddrs: map[int]string{0: "127.0.0.2:10911"}})
+	return routeData, nil
+}
+
+func (fb *fakeBackend) GetBrokers() (map[string]string, error) {
+	return map[string]string{testLocal: "127.0.0.1:10911"}, nil
+}
+
+func (fb *fakeBackend) PutMessages(topic string, queueId int32, records []*Record) (int64, error) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	key := fmt.Sprintf("%s@%d", topic, queueId)
+	baseOffset := fb.minOffs[key] + int64(len(fb.queues[topic][queueId]))
+	for i, record := range records {
+		stored := *record
+		stored.Offset = baseOffset + int64(i)
+		fb.queues[topic][queueId] = append(fb.queues[topic][queueId], &stored)
+	}
+	return baseOffset, nil
+}
+
+func (fb *fakeBackend) GetMessages(topic string, queueId int32, offset int64, maxNums int32) ([]*Record, error) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	var records []*Record
+	for _, record := range fb.queues[topic][queueId] {
+		if record.Offset >= offset && len(records) < int(maxNums) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (fb *fakeBackend) GetMinOffset(topic string, queueId int32) int64 {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	return fb.minOffs[fmt.Sprintf("%s@%d", topic, queueId)]
+}
+
+func (fb *fakeBackend) GetMaxOffset(topic string, queueId int32) int64 {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	return fb.minOffs[fmt.Sprintf("%s@%d", topic, queueId)] + int64(len(fb.queues[topic][queueId]))
+}
+
+func (fb *fakeBackend) GetOffsetByTime(topic string, queueId int32, timestamp int64) int64 {
+	fb.lock.Lock()
+	records := fb.queues[topic][queueId]
+	fb.lock.Unlock()
+	for _, record := range records {
+		if record.Timestamp >= timestamp {
+			return record.Offset
+		}
+	}
+	return fb.GetMaxOffset(topic, queueId)
+}
+
+func (fb *fakeBackend) CommitOffset(group string, mq *message.MessageQueue, brokerAddr string, offset int64) error {
+	if mq.BrokerName == testRemote && brokerAddr != "127.0.0.2:10911" {
+		return fmt.Errorf("unexpected broker addr %s", brokerAddr)
+	}
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	fb.offsets[fmt.Sprintf("%s@%s@%s@%d", group, mq.Topic, mq.BrokerName, mq.QueueId)] = offset
+	return nil
+}
+
+func (fb *fakeBackend) QueryOffset(group string, mq *message.MessageQueue, brokerAddr string) (int64, error) {
+	fb.lock.Lock()
+	defer fb.lock.Unlock()
+	if offset, ok := fb.offsets[fmt.Sprintf("%s@%s@%s@%d", group, mq.Topic, mq.BrokerName, mq.QueueId)]; ok {
+		return offset, nil
+	}
+	return -1, nil
+}
+
+func startTestServer(t *testing.T) (*Server, *fakeBackend) {
+	backend := newFakeBackend()
+	server := NewServer("127.0.0.1:0", backend)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return server, backend
+}
+
+func dialTestClient(t *testing.T, server *Server, clientId string) *Client {
+	client, err := DialClient(server.Addr(), clientId, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestKafkaMetadata(t *testing.T) {
+	server, backend := startTestServer(t)
+	defer server.Shutdown()
+	client := dialTestClient(t, server, "metadata")
+	defer client.Close()
+
+	versions, err := client.ApiVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if versions[API_PRODUCE] != [2]int16{0, 2} || versions[API_FETCH] != [2]int16{0, 3} {
+		t.Fatalf("unexpected api versions %v", versions)
+	}
+	// 不支持的ApiVersions版本返回UNSUPPORTED_VERSION及支持的版本
+	d, err := client.request(API_API_VERSIONS, 9, nil, time.Second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errorCode := ErrorCode(d.int16()); errorCode != ERR_UNSUPPORTED_VERSION {
+		t.Fatalf("expect UNSUPPORTED_VERSION, got %v", errorCode)
+	}
+
+	metadata, err := client.Metadata(testTopic, "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata.Brokers) != 2 || metadata.ControllerId != nodeId(testLocal) {
+		t.Fatalf("unexpected brokers %+v, controller %d", metadata.Brokers, metadata.ControllerId)
+	}
+	for _, node := range metadata.Brokers {
+		if node.Port != server.port {
+			t.Fatalf("broker port %d, expect kafka port %d", node.Port, server.port)
+		}
+	}
+	orders := metadata.Topics[0]
+	if orders.ErrorCode != ERR_NONE || len(orders.Partitions) != 4 {
+		t.Fatalf("unexpected topic metadata %+v", orders)
+	}
+	// 按brokerName排序：分区0、1在broker-a，分区2、3在broker-b
+	for i, p := range orders.Partitions {
+		expect := nodeId(testLocal)
+		if i >= 2 {
+			expect = nodeId(testRemote)
+		}
+		if p.Partition != int32(i) || p.Leader != expect || len(p.Isr) != 1 || p.Isr[0] != expect {
+			t.Fatalf("unexpected partition %+v", p)
+		}
+	}
+	if metadata.Topics[1].ErrorCode != ERR_UNKNOWN_TOPIC_OR_PARTITION {
+		t.Fatalf("missing topic error %v", metadata.Topics[1].ErrorCode)
+	}
+
+	all, err := client.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Topics) != 1 || all.Topics[0].Name != testTopic {
+		t.Fatalf("unexpected all topics %+v", all.Topics)
+	}
+
+	// 分区布局在缓存时间内只查询一次路由，分区不在本broker时失效
+	queries := backend.routeQueries()
+	if _, err := client.Metadata(testTopic); err != nil {
+		t.Fatal(err)
+	}
+	if n := backend.routeQueries(); n != queries {
+		t.Fatalf("metadata should use cached layout, route queried %d times", n-queries)
+	}
+	if _, err := client.Produce(testTopic, 2, &Record{Value: []byte("remote")}); err != ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("expect NOT_LEADER_FOR_PARTITION, got %v", err)
+	}
+	if _, err := client.Metadata(testTopic); err != nil {
+		t.Fatal(err)
+	}
+	if n := backend.routeQueries(); n != queries+1 {
+		t.Fatalf("metadata should refresh once after NOT_LEADER, route queried %d times", n-queries)
+	}
+}
+
+func TestKafkaProduceFetch(t *testing.T) {
+	server, _ := startTestServer(t)
+	defer server.Shutdown()
+	client := dialTestClient(t, server, "producer")
+	defer client.Close()
+
+	baseOffset, err := client.Produce(testTopic, 1,
+		&Record{Key: []byte("k0"), Value: []byte("v0"), Timestamp: 1000},
+		&Record{Value: []byte("v1"), Timestamp: 2000},
+		&Record{Key: []byte("k2"), Value: []byte("v2"), Timestamp: 3000})
+	if err != nil || baseOffset != 0 {
+		t.Fatalf("produce baseOffset %d, err %v", baseOffset, err)
+	}
+	if baseOffset, err = client.Produce(testTopic, 1, &Record{Value: []byte("v3"), Timestamp: -1}); err != nil || baseOffset != 3 {
+		t.Fatalf("produce baseOffset %d, err %v", baseOffset, err)
+	}
+	if err := client.ProduceNoAck(testTopic, 1, &Record{Value: []byte("v4"), Timestamp: 5000}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.Fetch(testTopic, 1, 0, 1024*1024, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.HighWatermark != 5 || len(result.Records) != 5 {
+		t.Fatalf("highWatermark %d, records %d", result.HighWatermark, len(result.Records))
+	}
+	for i, record := range result.Records {
+		if record.Offset != int64(i) || string(record.Value) != fmt.Sprintf("v%d", i) {
+			t.Fatalf("unexpected record %d: %+v", i, record)
+		}
+	}
+	if !bytes.Equal(result.Records[0].Key, []byte("k0")) || result.Records[1].Key != nil || result.Records[0].Timestamp != 1000 {
+		t.Fatalf("unexpected record %+v", result.Records[0])
+	}
+	if result.Records[3].Timestamp <= 0 {
+		t.Fatal("missing timestamp should be set by server")
+	}
+
+	// 分区字节上限只够一条消息时，仍返回第一条
+	if result, err = client.Fetch(testTopic, 1, 2, 1, 0); err != nil || len(result.Records) != 1 || result.Records[0].Offset != 2 {
+		t.Fatalf("small fetch %+v, err %v", result, err)
+	}
+
+	// 没有新消息时等待max_wait，期间写入的消息立即返回
+	begin := time.Now()
+	if result, err = client.Fetch(testTopic, 1, 5, 1024, 200*time.Millisecond); err != nil || len(result.Records) != 0 {
+		t.Fatalf("empty fetch %+v, err %v", result, err)
+	}
+	if elapsed := time.Since(begin); elapsed < 150*time.Millisecond {
+		t.Fatalf("fetch returned after %v, expect long poll", elapsed)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		producer, err := DialClient(server.Addr(), "late-producer", time.Second)
+		if err != nil {
+			return
+		}
+		defer producer.Close()
+		producer.Produce(testTopic, 1, &Record{Value: []byte("v5")})
+	}()
+	if result, err = client.Fetch(testTopic, 1, 5, 1024, 3*time.Second); err != nil || len(result.Records) != 1 {
+		t.Fatalf("long poll fetch %+v, err %v", result, err)
+	}
+
+	if _, err = client.Fetch(testTopic, 1, 100, 1024, 0); err != ERR_OFFSET_OUT_OF_RANGE {
+		t.Fatalf("expect OFFSET_OUT_OF_RANGE, got %v", err)
+	}
+	if _, err = client.Produce(testTopic, 2, &Record{Value: []byte("remote")}); err != ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("expect NOT_LEADER_FOR_PARTITION, got %v", err)
+	}
+	if _, err = client.Fetch(testTopic, 3, 0, 1024, 0); err != ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("expect NOT_LEADER_FOR_PARTITION, got %v", err)
+	}
+	if _, err = client.Produce(testTopic, 4, &Record{Value: []byte("none")}); err != ERR_UNKNOWN_TOPIC_OR_PARTITION {
+		t.Fatalf("expect UNKNOWN_TOPIC_OR_PARTITION, got %v", err)
+	}
+	if _, err = client.Produce("missing", 0, &Record{Value: []byte("none")}); err != ERR_UNKNOWN_TOPIC_OR_PARTITION {
+		t.Fatalf("expect UNKNOWN_TOPIC_OR_PARTITION, got %v", err)
+	}
+}
+
+func TestKafkaListOffsets(t *testing.T) {
+	server, backend := startTestServer(t)
+	defer server.Shutdown()
+	client := dialTestClient(t, server, "offsets")
+	defer client.Close()
+
+	backend.setMinOffset(testTopic, 0, 10)
+	for i := 0; i < 3; i++ {
+		if _, err := client.Produce(testTopic, 0, &Record{Value: []byte("v"), Timestamp: int64(1000 * (i + 1))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[int64]int64{OFFSET_EARLIEST: 10, OFFSET_LATEST: 13, 1500: 11, 3000: 12, 9999: 13}
+	for timestamp, expect := range cases {
+		offset, err := client.ListOffset(testTopic, 0, timestamp)
+		if err != nil || offset != expect {
+			t.Fatalf("timestamp %d: offset %d, expect %d, err %v", timestamp, offset, expect, err)
+		}
+	}
+	if _, err := client.Fetch(testTopic, 0, 9, 1024, 0); err != ERR_OFFSET_OUT_OF_RANGE {
+		t.Fatalf("offset below min expect OFFSET_OUT_OF_RANGE, got %v", err)
+	}
+	if _, err := client.ListOffset(testTopic, 2, OFFSET_LATEST); err != ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("expect NOT_LEADER_FOR_PARTITION, got %v", err)
+	}
+}
+
+func TestKafkaOffsetCommitFetch(t *testing.T) {
+	server, backend := startTestServer(t)
+	defer server.Shutdown()
+	client := dialTestClient(t, server, "committer")
+	defer client.Close()
+
+	if offset, err := client.FetchOffset(testGroup, testTopic, 0); err != nil || offset != -1 {
+		t.Fatalf("uncommitted offset %d, err %v", offset, err)
+	}
+	if err := client.CommitOffset(testGroup, -1, "", testTopic, 1, 42); err != nil {
+		t.Fatal(err)
+	}
+	// 其他broker上的分区提交到队列所在的broker
+	if err := client.CommitOffset(testGroup, -1, "", testTopic, 3, 7); err != nil {
+		t.Fatal(err)
+	}
+	if backend.committed(testGroup, testTopic, testLocal, 1) != 42 || backend.committed(testGroup, testTopic, testRemote, 1) != 7 {
+		t.Fatal("offsets should be committed to the broker owning the queue")
+	}
+	if offset, err := client.FetchOffset(testGroup, testTopic, 3); err != nil || offset != 7 {
+		t.Fatalf("fetch offset %d, err %v", offset, err)
+	}
+	if err := client.CommitOffset(testGroup, -1, "", testTopic, 9, 1); err != ERR_UNKNOWN_TOPIC_OR_PARTITION {
+		t.Fatalf("expect UNKNOWN_TOPIC_OR_PARTITION, got %v", err)
+	}
+	if err := client.CommitOffset(testGroup, 3, "unknown", testTopic, 0, 1); err != ERR_UNKNOWN_MEMBER_ID {
+		t.Fatalf("expect UNKNOWN_MEMBER_ID, got %v", err)
+	}
+}
+
+func TestKafkaGroupCoordination(t *testing.T) {
+	server, _ := startTestServer(t)
+	defer server.Shutdown()
+	client1 := dialTestClient(t, server, "consumer1")
+	defer client1.Close()
+	client2 := dialTestClient(t, server, "consumer2")
+	defer client2.Close()
+
+	node, err := client1.FindCoordinator(testGroup)
+	if err != nil || node.NodeId != nodeId(testLocal) || node.Port != server.port {
+		t.Fatalf("unexpected coordinator %+v, err %v", node, err)
+	}
+
+	sessionTimeout := 10 * time.Second
+	protocols := []*GroupProtocol{{Name: "range", Metadata: []byte("m1")}}
+	join1, err := client1.JoinGroup(testGroup, "", sessionTimeout, "consumer", protocols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if join1.GenerationId != 1 || join1.LeaderId != join1.MemberId || len(join1.Members) != 1 || join1.Protocol != "range" {
+		t.Fatalf("unexpected join result %+v", join1)
+	}
+	member1 := join1.MemberId
+	assignment, err := client1.SyncGroup(testGroup, 1, member1, map[string][]byte{member1: []byte("all")})
+	if err != nil || string(assignment) != "all" {
+		t.Fatalf("sync assignment %q, err %v", assignment, err)
+	}
+	if err := client1.Heartbeat(testGroup, 1, member1); err != nil {
+		t.Fatal(err)
+	}
+	if err := client1.CommitOffset(testGroup, 1, member1, testTopic, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// 协议类型不一致的成员被拒绝
+	if _, err := client2.JoinGroup(testGroup, "", sessionTimeout, "connect", protocols); err != ERR_INCONSISTENT_GROUP_PROTOCOL {
+		t.Fatalf("expect INCONSISTENT_GROUP_PROTOCOL, got %v", err)
+	}
+
+	// 第二个成员加入，等待第一个成员重新join
+	join2Chan := make(chan *JoinGroupResult, 1)
+	go func() {
+		result, err := client2.JoinGroup(testGroup, "", sessionTimeout, "consumer",
+			[]*GroupProtocol{{Name: "roundrobin"}, {Name: "range", Metadata: []byte("m2")}})
+		if err != nil {
+			t.Error(err)
+		}
+		join2Chan <- result
+	}()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		err := client1.Heartbeat(testGroup, 1, member1)
+		if err == ERR_REBALANCE_IN_PROGRESS {
+			break
+		}
+		if err != nil || time.Now().After(deadline) {
+			t.Fatalf("expect REBALANCE_IN_PROGRESS, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := client1.CommitOffset(testGroup, 1, member1, testTopic, 0, 6); err != ERR_REBALANCE_IN_PROGRESS {
+		t.Fatalf("commit during rebalance expect REBALANCE_IN_PROGRESS, got %v", err)
+	}
+
+	join1, err = client1.JoinGroup(testGroup, member1, sessionTimeout, "consumer", protocols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join2 := <-join2Chan
+	if join2 == nil {
+		t.FailNow()
+	}
+	if join1.GenerationId != 2 || join2.GenerationId != 2 || join1.LeaderId != member1 || join2.LeaderId != member1 {
+		t.Fatalf("unexpected join results %+v %+v", join1, join2)
+	}
+	if len(join1.Members) != 2 || len(join2.Members) != 0 || join2.Protocol != "range" {
+		t.Fatalf("leader should receive all members: %+v %+v", join1, join2)
+	}
+	for _, member := range join1.Members {
+		if member.MemberId == join2.MemberId && string(member.Metadata) != "m2" {
+			t.Fatalf("unexpected member metadata %q", member.Metadata)
+		}
+	}
+
+	// follower先同步，等待leader提交分配结果
+	sync2Chan := make(chan []byte, 1)
+	go func() {
+		assignment, err := client2.SyncGroup(testGroup, 2, join2.MemberId, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		sync2Chan <- assignment
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assignments := map[string][]byte{member1: []byte("p0"), join2.MemberId: []byte("p1")}
+	if assignment, err = client1.SyncGroup(testGroup, 2, member1, assignments); err != nil || string(assignment) != "p0" {
+		t.Fatalf("leader assignment %q, err %v", assignment, err)
+	}
+	if assignment := <-sync2Chan; string(assignment) != "p1" {
+		t.Fatalf("follower assignment %q", assignment)
+	}
+
+	if err := client1.CommitOffset(testGroup, 1, member1, testTopic, 0, 7); err != ERR_ILLEGAL_GENERATION {
+		t.Fatalf("expect ILLEGAL_GENERATION, got %v", err)
+	}
+	if err := client1.CommitOffset(testGroup, 2, member1, testTopic, 0, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	// 成员离开后剩余成员重新rebalance
+	if err := client2.LeaveGroup(testGroup, join2.MemberId); err != nil {
+		t.Fatal(err)
+	}
+	if err := client1.Heartbeat(testGroup, 2, member1); err != ERR_REBALANCE_IN_PROGRESS {
+		t.Fatalf("expect REBALANCE_IN_PROGRESS after leave, got %v", err)
+	}
+	if join1, err = client1.JoinGroup(testGroup, member1, sessionTimeout, "consumer", protocols); err != nil || join1.GenerationId != 3 {
+		t.Fatalf("rejoin %+v, err %v", join1, err)
+	}
+	if err := client2.Heartbeat(testGroup, 3, join2.MemberId); err != ERR_UNKNOWN_MEMBER_ID {
+		t.Fatalf("expect UNKNOWN_MEMBER_ID, got %v", err)
+	}
+}
+
+func TestGroupCoordinatorExpire(t *testing.T) {
+	gc := newGroupCoordinator()
+	defer gc.shutdown()
+
+	result := gc.join(testGroup, "", "client", "consumer", 6000, []*GroupProtocol{{Name: "range"}})
+	if result.ErrorCode != ERR_NONE {
+		t.Fatalf("join failed %v", result.ErrorCode)
+	}
+	if errorCode := gc.heartbeat(testGroup, result.GenerationId, result.MemberId); errorCode != ERR_NONE {
+		t.Fatalf("heartbeat %v", errorCode)
+	}
+	gc.expireMembers(time.Now().Add(time.Second))
+	if gc.groupCount() != 1 {
+		t.Fatal("member should not expire within session timeout")
+	}
+	gc.expireMembers(time.Now().Add(time.Minute))
+	if gc.groupCount() != 0 {
+		t.Fatal("expired member should be removed")
+	}
+	if errorCode := gc.heartbeat(testGroup, result.GenerationId, result.MemberId); errorCode != ERR_UNKNOWN_MEMBER_ID {
+		t.Fatalf("expect UNKNOWN_MEMBER_ID, got %v", errorCode)
+	}
+	if result := gc.join(testGroup, "", "client", "consumer", 1000, []*GroupProtocol{{Name: "range"}}); result.ErrorCode != ERR_INVALID_SESSION_TIMEOUT {
+		t.Fatalf("expect INVALID_SESSION_TIMEOUT, got %v", result.ErrorCode)
+	}
+}
+
+func TestKafkaServerShutdown(t *testing.T) {
+	server, _ := startTestServer(t)
+	client := dialTestClient(t, server, "waiter")
+	defer client.Close()
+
+	// 关闭时正在long poll的Fetch及连接都应结束
+	done := make(chan struct{})
+	go func() {
+		client.Fetch(testTopic, 0, 0, 1024, 10*time.Second)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if server.ConnectionCount() != 1 {
+		t.Fatalf("connection count %d", server.ConnectionCount())
+	}
+	server.Shutdown()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("fetch not finished after shutdown")
+	}
+	if server.ConnectionCount() != 0 {
+		t.Fatalf("connection count %d after shutdown", server.ConnectionCount())
+	}
+}
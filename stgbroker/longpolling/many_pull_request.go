@@ -50,3 +50,15 @@ func (req *ManyPullRequest) CloneListAndClear() []*PullRequest {
 	}
 	return nil
 }
+
+// CloneList 克隆请求列表(不清空)
+// Author agent
+// Since 2026/10/19
+func (req *ManyPullRequest) CloneList() []*PullRequest {
+	req.RLock()
+	defer req.RUnlock()
+
+	result := make([]*PullRequest, len(req.pullRequestList))
+	copy(result, req.pullRequestList)
+	return result
+}
@@ -0,0 +1,41 @@
+package longpolling
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"sync/atomic"
+)
+
+// PopRequest 没有可投递消息而被Hold住的POP消息请求
+// Author agent
+// Since 2026/10/19
+type PopRequest struct {
+	RequestCommand   *protocol.RemotingCommand
+	Context          netm.Context
+	ConsumerGroup    string
+	Topic            string
+	QueueId          int32 // -1表示由broker选择队列
+	TimeoutMillis    int64
+	SuspendTimestamp int64 // 第一次处理请求的时间，重新处理时等待时长仍从此开始计算
+	woken            int32
+}
+
+func NewPopRequest(requestCommand *protocol.RemotingCommand, ctx netm.Context, consumerGroup, topic string, queueId int32,
+	timeoutMillis, suspendTimestamp int64) *PopRequest {
+	var popRequest = new(PopRequest)
+	popRequest.RequestCommand = requestCommand
+	popRequest.Context = ctx
+	popRequest.ConsumerGroup = consumerGroup
+	popRequest.Topic = topic
+	popRequest.QueueId = queueId
+	popRequest.TimeoutMillis = timeoutMillis
+	popRequest.SuspendTimestamp = suspendTimestamp
+	return popRequest
+}
+
+// Wakeup 标记请求已被唤醒，新消息通知、定时唤醒可能同时发生，同一请求只有第一次唤醒返回true
+// Author agent
+// Since 2026/10/19
+func (req *PopRequest) Wakeup() bool {
+	return atomic.CompareAndSwapInt32(&req.woken, 0, 1)
+}
@@ -0,0 +1,199 @@
+package offsetstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+const (
+	RECORD_COMMIT = 1 // 提交单个队列的消费进度
+	RECORD_REMOVE = 2 // 删除topic@group的全部消费进度
+	RECORD_PUT    = 3 // 整体替换topic@group的消费进度
+
+	recordHeaderSize = 8       // 记录头：payload长度(4字节) + payload的crc32(4字节)
+	maxRecordSize    = 1 << 24 // 单条记录payload上限，超过认为是损坏的数据
+)
+
+// OffsetRecord 消费进度日志中的一条记录
+// Author agent
+// Since 2026/10/19
+type OffsetRecord struct {
+	Seq     int64
+	Type    uint8
+	Key     string // topic@group
+	QueueId int
+	Offset  int64
+	Offsets map[int]int64 // 仅RECORD_PUT使用
+}
+
+// Apply 把记录应用到消费进度表上
+// Author agent
+// Since 2026/10/19
+func (record *OffsetRecord) Apply(table map[string]map[int]int64) {
+	switch record.Type {
+	case RECORD_COMMIT:
+		offsets, ok := table[record.Key]
+		if !ok {
+			offsets = make(map[int]int64)
+			table[record.Key] = offsets
+		}
+		offsets[record.QueueId] = record.Offset
+	case RECORD_REMOVE:
+		delete(table, record.Key)
+	case RECORD_PUT:
+		offsets := make(map[int]int64, len(record.Offsets))
+		for queueId, offset := range record.Offsets {
+			offsets[queueId] = offset
+		}
+		table[record.Key] = offsets
+	}
+}
+
+// encodeRecord 编码为 [len][crc][seq][type][keyLen][key][body] 格式
+// Author agent
+// Since 2026/10/19
+func encodeRecord(record *OffsetRecord) []byte {
+	payloadSize := 8 + 1 + 2 + len(record.Key)
+	switch record.Type {
+	case RECORD_COMMIT:
+		payloadSize += 4 + 8
+	case RECORD_PUT:
+		payloadSize += 4 + len(record.Offsets)*(4+8)
+	}
+
+	buf := make([]byte, recordHeaderSize+payloadSize)
+	payload := buf[recordHeaderSize:]
+	pos := 0
+	binary.BigEndian.PutUint64(payload[pos:], uint64(record.Seq))
+	pos += 8
+	payload[pos] = record.Type
+	pos++
+	binary.BigEndian.PutUint16(payload[pos:], uint16(len(record.Key)))
+	pos += 2
+	pos += copy(payload[pos:], record.Key)
+
+	switch record.Type {
+	case RECORD_COMMIT:
+		binary.BigEndian.PutUint32(payload[pos:], uint32(record.QueueId))
+		pos += 4
+		binary.BigEndian.PutUint64(payload[pos:], uint64(record.Offset))
+	case RECORD_PUT:
+		binary.BigEndian.PutUint32(payload[pos:], uint32(len(record.Offsets)))
+		pos += 4
+		for _, queueId := range sortedQueueIds(record.Offsets) {
+			binary.BigEndian.PutUint32(payload[pos:], uint32(queueId))
+			pos += 4
+			binary.BigEndian.PutUint64(payload[pos:], uint64(record.Offsets[queueId]))
+			pos += 8
+		}
+	}
+
+	binary.BigEndian.PutUint32(buf[0:], uint32(payloadSize))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+// decodeRecord 从buf头部解码一条记录，返回记录以及占用的字节数；数据不完整或校验失败时返回error
+// Author agent
+// Since 2026/10/19
+func decodeRecord(buf []byte) (*OffsetRecord, int, error) {
+	if len(buf) < recordHeaderSize {
+		return nil, 0, fmt.Errorf("record header is incomplete")
+	}
+	payloadSize := int(binary.BigEndian.Uint32(buf[0:]))
+	if payloadSize < 8+1+2 || payloadSize > maxRecordSize {
+		return nil, 0, fmt.Errorf("record size %d is invalid", payloadSize)
+	}
+	if len(buf) < recordHeaderSize+payloadSize {
+		return nil, 0, fmt.Errorf("record payload is incomplete")
+	}
+	payload := buf[recordHeaderSize : recordHeaderSize+payloadSize]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(buf[4:]) {
+		return nil, 0, fmt.Errorf("record crc mismatch")
+	}
+
+	reader := &byteReader{buf: payload}
+	record := &OffsetRecord{}
+	record.Seq = int64(reader.uint64())
+	record.Type = reader.uint8()
+	record.Key = string(reader.bytes(int(reader.uint16())))
+	switch record.Type {
+	case RECORD_COMMIT:
+		record.QueueId = int(int32(reader.uint32()))
+		record.Offset = int64(reader.uint64())
+	case RECORD_REMOVE:
+	case RECORD_PUT:
+		count := int(reader.uint32())
+		record.Offsets = make(map[int]int64, count)
+		for i := 0; i < count && reader.err == nil; i++ {
+			queueId := int(int32(reader.uint32()))
+			record.Offsets[queueId] = int64(reader.uint64())
+		}
+	default:
+		return nil, 0, fmt.Errorf("record type %d is invalid", record.Type)
+	}
+	if reader.err != nil {
+		return nil, 0, reader.err
+	}
+	return record, recordHeaderSize + payloadSize, nil
+}
+
+// sortedQueueIds 队列ID升序排列，保证相同的数据编码结果一致
+func sortedQueueIds(offsets map[int]int64) []int {
+	queueIds := make([]int, 0, len(offsets))
+	for queueId := range offsets {
+		queueIds = append(queueIds, queueId)
+	}
+	sort.Ints(queueIds)
+	return queueIds
+}
+
+// byteReader 顺序读取大端编码的数据，越界时记录错误并返回零值
+type byteReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (reader *byteReader) bytes(n int) []byte {
+	if reader.err != nil {
+		return nil
+	}
+	if n < 0 || reader.pos+n > len(reader.buf) {
+		reader.err = fmt.Errorf("unexpected end of data at %d", reader.pos)
+		return nil
+	}
+	value := reader.buf[reader.pos : reader.pos+n]
+	reader.pos += n
+	return value
+}
+
+func (reader *byteReader) uint8() uint8 {
+	if value := reader.bytes(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (reader *byteReader) uint16() uint16 {
+	if value := reader.bytes(2); value != nil {
+		return binary.BigEndian.Uint16(value)
+	}
+	return 0
+}
+
+func (reader *byteReader) uint32() uint32 {
+	if value := reader.bytes(4); value != nil {
+		return binary.BigEndian.Uint32(value)
+	}
+	return 0
+}
+
+func (reader *byteReader) uint64() uint64 {
+	if value := reader.bytes(8); value != nil {
+		return binary.BigEndian.Uint64(value)
+	}
+	return 0
+}
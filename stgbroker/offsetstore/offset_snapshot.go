@@ -0,0 +1,152 @@
+package offsetstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	snapshotMagic   = 0x53474F53 // "SGOS"
+	snapshotVersion = 1
+)
+
+// writeSnapshot 把消费进度表以及对应的日志序号写入快照文件：先写临时文件并fsync，再原子rename
+// Author agent
+// Since 2026/10/19
+func writeSnapshot(fileName string, table map[string]map[int]int64, lastSeq int64) error {
+	buf := encodeSnapshot(table, lastSeq)
+
+	tmpFile := fileName + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpFile, fileName); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// readSnapshot 读取快照文件，文件不存在时返回空表；返回快照对应的日志序号
+// Author agent
+// Since 2026/10/19
+func readSnapshot(fileName string) (map[string]map[int]int64, int64, error) {
+	buf, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return make(map[string]map[int]int64), 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return decodeSnapshot(buf)
+}
+
+// encodeSnapshot 编码为 [magic][version][lastSeq][count]{[keyLen][key][queueCount]{[queueId][offset]}}[crc] 格式
+// Author agent
+// Since 2026/10/19
+func encodeSnapshot(table map[string]map[int]int64, lastSeq int64) []byte {
+	keys := make([]string, 0, len(table))
+	size := 4 + 2 + 8 + 4 + 4
+	for key, offsets := range table {
+		keys = append(keys, key)
+		size += 2 + len(key) + 4 + len(offsets)*(4+8)
+	}
+	sort.Strings(keys)
+
+	buf := make([]byte, size)
+	pos := 0
+	binary.BigEndian.PutUint32(buf[pos:], snapshotMagic)
+	pos += 4
+	binary.BigEndian.PutUint16(buf[pos:], snapshotVersion)
+	pos += 2
+	binary.BigEndian.PutUint64(buf[pos:], uint64(lastSeq))
+	pos += 8
+	binary.BigEndian.PutUint32(buf[pos:], uint32(len(keys)))
+	pos += 4
+	for _, key := range keys {
+		offsets := table[key]
+		binary.BigEndian.PutUint16(buf[pos:], uint16(len(key)))
+		pos += 2
+		pos += copy(buf[pos:], key)
+		binary.BigEndian.PutUint32(buf[pos:], uint32(len(offsets)))
+		pos += 4
+		for _, queueId := range sortedQueueIds(offsets) {
+			binary.BigEndian.PutUint32(buf[pos:], uint32(queueId))
+			pos += 4
+			binary.BigEndian.PutUint64(buf[pos:], uint64(offsets[queueId]))
+			pos += 8
+		}
+	}
+	binary.BigEndian.PutUint32(buf[pos:], crc32.ChecksumIEEE(buf[:pos]))
+	return buf
+}
+
+// decodeSnapshot 解码快照，校验失败时返回error
+// Author agent
+// Since 2026/10/19
+func decodeSnapshot(buf []byte) (map[string]map[int]int64, int64, error) {
+	if len(buf) < 4+2+8+4+4 {
+		return nil, 0, fmt.Errorf("snapshot is too short: %d bytes", len(buf))
+	}
+	body := buf[:len(buf)-4]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(buf[len(buf)-4:]) {
+		return nil, 0, fmt.Errorf("snapshot crc mismatch")
+	}
+
+	reader := &byteReader{buf: body}
+	if magic := reader.uint32(); magic != snapshotMagic {
+		return nil, 0, fmt.Errorf("snapshot magic %x is invalid", magic)
+	}
+	if version := reader.uint16(); version != snapshotVersion {
+		return nil, 0, fmt.Errorf("snapshot version %d is not supported", version)
+	}
+	lastSeq := int64(reader.uint64())
+	count := int(reader.uint32())
+
+	table := make(map[string]map[int]int64, count)
+	for i := 0; i < count && reader.err == nil; i++ {
+		key := string(reader.bytes(int(reader.uint16())))
+		queueCount := int(reader.uint32())
+		offsets := make(map[int]int64, queueCount)
+		for j := 0; j < queueCount && reader.err == nil; j++ {
+			queueId := int(int32(reader.uint32()))
+			offsets[queueId] = int64(reader.uint64())
+		}
+		table[key] = offsets
+	}
+	if reader.err != nil {
+		return nil, 0, reader.err
+	}
+	return table, lastSeq, nil
+}
+
+// syncDir fsync目录，保证rename在掉电后依然可见
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// 部分文件系统不支持对目录fsync，忽略该错误
+	file.Sync()
+	return nil
+}
@@ -0,0 +1,290 @@
+package offsetstore
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// OffsetStore 消费进度存储：二进制快照 + 追加写的提交日志，日志按批fsync，超过阈值后合并到快照
+// Author agent
+// Since 2026/10/19
+type OffsetStore struct {
+	snapshotPath string
+	journalPath  string
+	journal      *os.File
+	journalSize  int64  // 已写入文件的日志大小
+	buffer       []byte // 等待写入文件的日志
+	nextSeq      int64
+	lock         sync.Mutex
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+// CompactPoint 合并快照的位置：快照包含Seq及之前的全部记录，日志中Position之后的内容需要保留
+// Author agent
+// Since 2026/10/19
+type CompactPoint struct {
+	Seq      int64
+	Position int64
+}
+
+// NewOffsetStore 初始化消费进度存储
+// Author agent
+// Since 2026/10/19
+func NewOffsetStore(snapshotPath, journalPath string) *OffsetStore {
+	return &OffsetStore{
+		snapshotPath: snapshotPath,
+		journalPath:  journalPath,
+		nextSeq:      1,
+	}
+}
+
+// Exists 快照或日志文件是否存在，都不存在时说明需要从旧的json文件导入
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Exists() bool {
+	for _, fileName := range []string{store.snapshotPath, store.journalPath} {
+		if _, err := os.Stat(fileName); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Load 读取快照并重放快照之后的日志，重建消费进度表；日志尾部不完整的记录会被截掉
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Load() (map[string]map[int]int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	table, lastSeq, err := readSnapshot(store.snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("read offset snapshot %s failed: %s", store.snapshotPath, err.Error())
+	}
+	store.nextSeq = lastSeq + 1
+
+	if err = os.MkdirAll(filepath.Dir(store.journalPath), 0755); err != nil {
+		return nil, err
+	}
+	journal, err := os.OpenFile(store.journalPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadAll(journal)
+	if err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	replayCount := 0
+	position := 0
+	for position < len(buf) {
+		record, size, err := decodeRecord(buf[position:])
+		if err != nil {
+			logger.Warnf("offset journal %s is torn at %d, truncate %d bytes. %s", store.journalPath, position, len(buf)-position, err.Error())
+			break
+		}
+		position += size
+		if record.Seq >= store.nextSeq {
+			store.nextSeq = record.Seq + 1
+		}
+		if record.Seq > lastSeq {
+			record.Apply(table)
+			replayCount++
+		}
+	}
+
+	if position < len(buf) {
+		if err = journal.Truncate(int64(position)); err != nil {
+			journal.Close()
+			return nil, err
+		}
+	}
+	if _, err = journal.Seek(int64(position), io.SeekStart); err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	store.journal = journal
+	store.journalSize = int64(position)
+	store.buffer = nil
+	logger.Infof("load offset store OK, snapshotSeq=%d, replayRecords=%d, nextSeq=%d, groups=%d", lastSeq, replayCount, store.nextSeq, len(table))
+	return table, nil
+}
+
+// Append 追加一条记录，记录先写入内存，由Flush按批写入文件并fsync
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Append(record *OffsetRecord) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.journal == nil {
+		return
+	}
+	record.Seq = store.nextSeq
+	store.nextSeq++
+	store.buffer = append(store.buffer, encodeRecord(record)...)
+}
+
+// Flush 把内存中的日志写入文件并fsync
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Flush() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.flush()
+}
+
+func (store *OffsetStore) flush() error {
+	if store.journal == nil || len(store.buffer) == 0 {
+		return nil
+	}
+	n, err := store.journal.Write(store.buffer)
+	store.journalSize += int64(n)
+	if err != nil {
+		// 写入一部分失败时，下次重放会把不完整的尾部截掉
+		store.buffer = store.buffer[n:]
+		return err
+	}
+	store.buffer = store.buffer[:0]
+	return store.journal.Sync()
+}
+
+// JournalSize 日志大小(包含尚未写入文件的部分)
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) JournalSize() int64 {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.journalSize + int64(len(store.buffer))
+}
+
+// Mark 获得当前的合并位置；调用方需要保证获取消费进度表副本与Mark之间没有新的Append
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Mark() CompactPoint {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return CompactPoint{Seq: store.nextSeq - 1, Position: store.journalSize + int64(len(store.buffer))}
+}
+
+// Compact 把消费进度表写入快照，并从日志中删除快照已经包含的记录；调用方需要保证Mark与Compact串行执行
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Compact(table map[string]map[int]int64, point CompactPoint) error {
+	if err := writeSnapshot(store.snapshotPath, table, point.Seq); err != nil {
+		return fmt.Errorf("write offset snapshot %s failed: %s", store.snapshotPath, err.Error())
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.journal == nil {
+		return nil
+	}
+	if err := store.flush(); err != nil {
+		return err
+	}
+	if point.Position < 0 || point.Position > store.journalSize {
+		return fmt.Errorf("compact position %d is out of journal size %d", point.Position, store.journalSize)
+	}
+
+	// 快照已经写入，此后崩溃时日志中seq<=point.Seq的记录会在重放时被跳过，因此重写日志不需要原子性以外的保证
+	tail := make([]byte, store.journalSize-point.Position)
+	if len(tail) > 0 {
+		if _, err := store.journal.ReadAt(tail, point.Position); err != nil {
+			return err
+		}
+	}
+
+	tmpFile := store.journalPath + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, tail, 0644); err != nil {
+		return err
+	}
+	if err := fsyncFile(tmpFile); err != nil {
+		return err
+	}
+	store.journal.Close()
+	store.journal = nil
+	if err := os.Rename(tmpFile, store.journalPath); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(store.journalPath))
+
+	journal, err := os.OpenFile(store.journalPath, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = journal.Seek(0, io.SeekEnd); err != nil {
+		journal.Close()
+		return err
+	}
+	store.journal = journal
+	store.journalSize = int64(len(tail))
+	logger.Infof("compact offset store OK, snapshotSeq=%d, groups=%d, journalSize=%d", point.Seq, len(table), store.journalSize)
+	return nil
+}
+
+// Start 启动定时刷盘，每flushIntervalMills把内存中的日志按批写入文件并fsync
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Start(flushIntervalMills int64) {
+	if flushIntervalMills <= 0 {
+		flushIntervalMills = 1
+	}
+	store.stopChan = make(chan struct{})
+	store.wg.Add(1)
+	go func() {
+		defer store.wg.Done()
+		ticker := time.NewTicker(time.Duration(flushIntervalMills) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-store.stopChan:
+				return
+			case <-ticker.C:
+				if err := store.Flush(); err != nil {
+					logger.Errorf("flush offset journal %s err: %s", store.journalPath, err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown 停止定时刷盘，刷盘后关闭日志文件
+// Author agent
+// Since 2026/10/19
+func (store *OffsetStore) Shutdown() {
+	if store.stopChan != nil {
+		close(store.stopChan)
+		store.wg.Wait()
+		store.stopChan = nil
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if err := store.flush(); err != nil {
+		logger.Errorf("flush offset journal %s err: %s", store.journalPath, err.Error())
+	}
+	if store.journal != nil {
+		store.journal.Close()
+		store.journal = nil
+	}
+}
+
+// fsyncFile fsync指定文件
+func fsyncFile(fileName string) error {
+	file, err := os.OpenFile(fileName, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -0,0 +1,136 @@
+package offsetstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestStore(t *testing.T) (*OffsetStore, string) {
+	dir, err := ioutil.TempDir("", "offsetstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewOffsetStore(filepath.Join(dir, "consumerOffset.snapshot"), filepath.Join(dir, "consumerOffset.journal")), dir
+}
+
+func reopen(t *testing.T, store *OffsetStore) map[string]map[int]int64 {
+	store.Shutdown()
+	reopened := NewOffsetStore(store.snapshotPath, store.journalPath)
+	table, err := reopened.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.Shutdown()
+	return table
+}
+
+func TestOffsetStoreReplayJournal(t *testing.T) {
+	store, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	if store.Exists() {
+		t.Fatalf("store should not exist before load")
+	}
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 0, Offset: 10})
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 1, Offset: 20})
+	store.Append(&OffsetRecord{Type: RECORD_PUT, Key: "topicA@groupB", Offsets: map[int]int64{0: 5, 1: 6}})
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicB@groupA", QueueId: 0, Offset: 1})
+	store.Append(&OffsetRecord{Type: RECORD_REMOVE, Key: "topicB@groupA"})
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 0, Offset: 11})
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	table := reopen(t, store)
+	if len(table) != 2 {
+		t.Fatalf("expect 2 groups, got %v", table)
+	}
+	if table["topicA@groupA"][0] != 11 || table["topicA@groupA"][1] != 20 {
+		t.Fatalf("unexpected offsets of topicA@groupA: %v", table["topicA@groupA"])
+	}
+	if table["topicA@groupB"][1] != 6 {
+		t.Fatalf("unexpected offsets of topicA@groupB: %v", table["topicA@groupB"])
+	}
+}
+
+func TestOffsetStoreTornJournal(t *testing.T) {
+	store, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 0, Offset: 10})
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 0, Offset: 11})
+	store.Shutdown()
+
+	// 模拟最后一条记录只写入了一部分
+	info, _ := os.Stat(store.journalPath)
+	if err := os.Truncate(store.journalPath, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewOffsetStore(store.snapshotPath, store.journalPath)
+	table, err := reopened.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table["topicA@groupA"][0] != 10 {
+		t.Fatalf("torn record should be dropped, got %v", table)
+	}
+
+	// 截断后追加的记录可以正常重放
+	reopened.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 0, Offset: 12})
+	table = reopen(t, reopened)
+	if table["topicA@groupA"][0] != 12 {
+		t.Fatalf("expect offset 12 after torn tail truncated, got %v", table)
+	}
+}
+
+func TestOffsetStoreCompact(t *testing.T) {
+	store, dir := newTestStore(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	table := make(map[string]map[int]int64)
+	for i := 0; i < 100; i++ {
+		record := &OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: i % 4, Offset: int64(i)}
+		store.Append(record)
+		record.Apply(table)
+	}
+
+	point := store.Mark()
+	// 合并期间新增的提交需要保留在日志中
+	store.Append(&OffsetRecord{Type: RECORD_COMMIT, Key: "topicA@groupA", QueueId: 0, Offset: 1000})
+	if err := store.Compact(table, point); err != nil {
+		t.Fatal(err)
+	}
+	if store.JournalSize() >= point.Position {
+		t.Fatalf("journal should shrink after compact, size=%d, position=%d", store.JournalSize(), point.Position)
+	}
+
+	loaded := reopen(t, store)
+	if loaded["topicA@groupA"][0] != 1000 || loaded["topicA@groupA"][3] != 99 {
+		t.Fatalf("unexpected offsets after compact: %v", loaded)
+	}
+}
+
+func TestOffsetSnapshotCorrupted(t *testing.T) {
+	buf := encodeSnapshot(map[string]map[int]int64{"topicA@groupA": {0: 1}}, 7)
+	table, lastSeq, err := decodeSnapshot(buf)
+	if err != nil || lastSeq != 7 || table["topicA@groupA"][0] != 1 {
+		t.Fatalf("decode snapshot failed: %v %d %v", table, lastSeq, err)
+	}
+
+	buf[len(buf)/2] ^= 0xff
+	if _, _, err = decodeSnapshot(buf); err == nil {
+		t.Fatalf("corrupted snapshot should fail")
+	}
+}
@@ -2,11 +2,15 @@ package out
 
 import (
 	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/namesrv"
 	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
 	headerNamesrv "git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header/namesrv"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
@@ -201,6 +205,48 @@ func (self *BrokerOuterAPI) UnRegisterBrokerAll(clusterName, brokerAddr, brokerN
 	}
 }
 
+// WipeWritePermOfBroker 在单个namesrv上关闭broker写权限，返回被修改的topic个数
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) WipeWritePermOfBroker(namesrvAddr, brokerName string) (int, error) {
+	requestHeader := &headerNamesrv.WipeWritePermOfBrokerRequestHeader{BrokerName: brokerName}
+	request := protocol.CreateRequestCommand(code.WIPE_WRITE_PERM_OF_BROKER, requestHeader)
+	response, err := self.remotingClient.InvokeSync(namesrvAddr, request, timeout)
+	if err != nil {
+		return 0, err
+	}
+	if response == nil {
+		return 0, fmt.Errorf("wipeWritePermOfBroker response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		return 0, fmt.Errorf("wipeWritePermOfBroker failed. code=%d, remark=%s", response.Code, response.Remark)
+	}
+	responseHeader := new(headerNamesrv.WipeWritePermOfBrokerResponseHeader)
+	if err = response.DecodeCommandCustomHeader(responseHeader); err != nil {
+		return 0, err
+	}
+	return responseHeader.WipeTopicCount, nil
+}
+
+// WipeWritePermOfBrokerAll 在全部namesrv上关闭broker写权限，返回被修改的topic总数以及失败的namesrv
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) WipeWritePermOfBrokerAll(brokerName string) (int, map[string]error) {
+	wipeTopicCount := 0
+	failed := make(map[string]error)
+	for _, namesrvAddr := range self.remotingClient.GetNameServerAddressList() {
+		count, err := self.WipeWritePermOfBroker(namesrvAddr, brokerName)
+		if err != nil {
+			logger.Errorf("wipe write perm of broker %s on name server %s err: %s", brokerName, namesrvAddr, err.Error())
+			failed[namesrvAddr] = err
+			continue
+		}
+		wipeTopicCount += count
+		logger.Infof("wipe write perm of broker %s on name server %s OK, wipeTopicCount=%d", brokerName, namesrvAddr, count)
+	}
+	return wipeTopicCount, failed
+}
+
 // GetAllTopicConfig 获取全部topic信息
 // Author gaoyanlei
 // Since 2017/8/22
@@ -289,3 +335,121 @@ func (self *BrokerOuterAPI) GetAllSubscriptionGroupConfig(brokerAddr string) *bo
 	}
 	return subscriptionGroupWrapper
 }
+
+// GetAllQuotaConfig 获取master上的全部收发配额
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) GetAllQuotaConfig(brokerAddr string) *quota.QuotaConfigTable {
+	request := protocol.CreateRequestCommand(code.GET_ALL_QUOTA_CONFIG)
+	response, err := self.remotingClient.InvokeSync(brokerAddr, request, timeout)
+	if err != nil {
+		logger.Errorf("GetAllQuotaConfig() err: %s, brokerAddr=%s, %s", err.Error(), brokerAddr, request.ToString())
+		return nil
+	}
+	if response == nil || response.Code != code.SUCCESS {
+		logger.Errorf("GetAllQuotaConfig() failed. brokerAddr=%s, response is %s", brokerAddr, response.ToString())
+		return nil
+	}
+
+	quotaConfigTable := quota.NewQuotaConfigTable()
+	if err = stgcommon.Decode(response.Body, quotaConfigTable); err != nil {
+		logger.Errorf("GetAllQuotaConfig() decode err: %s, response.Body=%s", err.Error(), string(response.Body))
+		return nil
+	}
+	return quotaConfigTable
+}
+
+// GetTopicRouteInfoFromNameServer 从namesrv查询topic路由，topic不存在时返回nil
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) GetTopicRouteInfoFromNameServer(topic string) (*route.TopicRouteData, error) {
+	requestHeader := &headerNamesrv.GetRouteInfoRequestHeader{Topic: topic}
+	request := protocol.CreateRequestCommand(code.GET_ROUTEINTO_BY_TOPIC, requestHeader)
+	response, err := self.remotingClient.InvokeSync("", request, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("GetTopicRouteInfoFromNameServer topic[%s] failed. the response is empty", topic)
+	}
+	if response.Code == code.TOPIC_NOT_EXIST {
+		return nil, nil
+	}
+	if response.Code != code.SUCCESS {
+		return nil, fmt.Errorf("GetTopicRouteInfoFromNameServer topic[%s] failed. %d, %s", topic, response.Code, response.Remark)
+	}
+
+	topicRouteData := route.NewTopicRouteData()
+	if err = topicRouteData.Decode(response.Body); err != nil {
+		logger.Errorf("topicRouteData.Decode() err: %s, response.Body=%s", err.Error(), string(response.Body))
+		return nil, err
+	}
+	return topicRouteData, nil
+}
+
+// GetBrokerClusterInfo 从namesrv查询集群信息
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) GetBrokerClusterInfo() (*body.ClusterPlusInfo, error) {
+	request := protocol.CreateRequestCommand(code.GET_BROKER_CLUSTER_INFO)
+	response, err := self.remotingClient.InvokeSync("", request, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("GetBrokerClusterInfo failed. the response is empty")
+	}
+	if response.Code != code.SUCCESS {
+		return nil, fmt.Errorf("GetBrokerClusterInfo failed. %d, %s", response.Code, response.Remark)
+	}
+
+	clusterPlusInfo := body.NewClusterPlusInfo()
+	if err = clusterPlusInfo.CustomDecode(response.Body, clusterPlusInfo); err != nil {
+		logger.Errorf("clusterPlusInfo.CustomDecode() err: %s, response.Body=%s", err.Error(), string(response.Body))
+		return nil, err
+	}
+	return clusterPlusInfo, nil
+}
+
+// UpdateConsumerOffset 同步更新其他broker上的消费进度
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) UpdateConsumerOffset(brokerAddr string, requestHeader *header.UpdateConsumerOffsetRequestHeader) error {
+	request := protocol.CreateRequestCommand(code.UPDATE_CONSUMER_OFFSET, requestHeader)
+	response, err := self.remotingClient.InvokeSync(brokerAddr, request, timeout)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("UpdateConsumerOffset failed. brokerAddr=%s, the response is empty", brokerAddr)
+	}
+	if response.Code != code.SUCCESS {
+		return fmt.Errorf("UpdateConsumerOffset failed. brokerAddr=%s, %d, %s", brokerAddr, response.Code, response.Remark)
+	}
+	return nil
+}
+
+// QueryConsumerOffset 查询其他broker上的消费进度，未提交过时返回-1
+// Author agent
+// Since 2026/10/19
+func (self *BrokerOuterAPI) QueryConsumerOffset(brokerAddr string, requestHeader *header.QueryConsumerOffsetRequestHeader) (int64, error) {
+	request := protocol.CreateRequestCommand(code.QUERY_CONSUMER_OFFSET, requestHeader)
+	response, err := self.remotingClient.InvokeSync(brokerAddr, request, timeout)
+	if err != nil {
+		return -1, err
+	}
+	if response == nil {
+		return -1, fmt.Errorf("QueryConsumerOffset failed. brokerAddr=%s, the response is empty", brokerAddr)
+	}
+	switch response.Code {
+	case code.SUCCESS:
+		responseHeader := &header.QueryConsumerOffsetResponseHeader{}
+		if err = response.DecodeCommandCustomHeader(responseHeader); err != nil {
+			return -1, err
+		}
+		return responseHeader.Offset, nil
+	case code.QUERY_NOT_FOUND:
+		return -1, nil
+	}
+	return -1, fmt.Errorf("QueryConsumerOffset failed. brokerAddr=%s, %d, %s", brokerAddr, response.Code, response.Remark)
+}
@@ -0,0 +1,356 @@
+package stgbroker
+
+import (
+	"encoding/json"
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"github.com/pquerna/ffjson/ffjson"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// PopInflight 已经POP但尚未确认的消息
+// Author agent
+// Since 2026/10/19
+type PopInflight struct {
+	Offset        int64 `json:"offset"`        // 消息在队列中的逻辑位点
+	PopTime       int64 `json:"popTime"`       // 最近一次投递的时间(毫秒)
+	InvisibleTime int64 `json:"invisibleTime"` // 不可见时间(毫秒)
+	DeliveryTimes int32 `json:"deliveryTimes"` // 已投递次数
+}
+
+// NextVisibleTime 消息重新可见的时间(毫秒)
+// Author agent
+// Since 2026/10/19
+func (self *PopInflight) NextVisibleTime() int64 {
+	return self.PopTime + self.InvisibleTime
+}
+
+// PopCheckpoint 消费组在某个队列上的POP进度
+// Author agent
+// Since 2026/10/19
+type PopCheckpoint struct {
+	Topic         string                 `json:"topic"`
+	ConsumerGroup string                 `json:"consumerGroup"`
+	QueueId       int32                  `json:"queueId"`
+	PopOffset     int64                  `json:"popOffset"` // 下一次POP新消息的起始位点
+	Inflights     map[int64]*PopInflight `json:"inflights"` // key: offset
+}
+
+// commitOffset 可以提交的消费进度：最小的未确认位点，没有未确认消息时为PopOffset
+func (self *PopCheckpoint) commitOffset() int64 {
+	offset := self.PopOffset
+	for inflightOffset := range self.Inflights {
+		if inflightOffset < offset {
+			offset = inflightOffset
+		}
+	}
+	return offset
+}
+
+// PopCheckpointTable POP进度持久化结构
+// Author agent
+// Since 2026/10/19
+type PopCheckpointTable struct {
+	Checkpoints map[string]*PopCheckpoint `json:"checkpoints"` // key: topic@group@queueId
+}
+
+// PopCheckpointManager 管理POP消费的进度及未确认消息，持久化到popCheckpoint.json；
+// 消息被确认后把最小未确认位点提交到ConsumerOffsetManager，便于统计消费进度
+// Author agent
+// Since 2026/10/19
+type PopCheckpointManager struct {
+	BrokerController *BrokerController
+	ConfigManagerExt *ConfigManagerExt
+	checkpoints      map[string]*PopCheckpoint
+	lock             sync.RWMutex
+	queueLocks       map[string]bool // 正在POP的队列，同一队列同一时刻只允许一个POP请求
+	queueLocksLock   sync.Mutex
+	dataVersion      int64 // 进度每次变化时递增
+	persistVersion   int64 // 最近一次写入文件时的dataVersion
+}
+
+// NewPopCheckpointManager 创建PopCheckpointManager
+// Author agent
+// Since 2026/10/19
+func NewPopCheckpointManager(brokerController *BrokerController) *PopCheckpointManager {
+	popCheckpointManager := new(PopCheckpointManager)
+	popCheckpointManager.BrokerController = brokerController
+	popCheckpointManager.ConfigManagerExt = NewConfigManagerExt(popCheckpointManager)
+	popCheckpointManager.checkpoints = make(map[string]*PopCheckpoint)
+	popCheckpointManager.queueLocks = make(map[string]bool)
+	return popCheckpointManager
+}
+
+func (self *PopCheckpointManager) Load() bool {
+	return self.ConfigManagerExt.Load()
+}
+
+// Persist 进度有变化时才写入popCheckpoint.json，避免定时任务每次都重写文件
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) Persist() {
+	version := atomic.LoadInt64(&self.dataVersion)
+	if version == atomic.LoadInt64(&self.persistVersion) {
+		return
+	}
+	self.ConfigManagerExt.Persist()
+	atomic.StoreInt64(&self.persistVersion, version)
+}
+
+func (self *PopCheckpointManager) Encode(prettyFormat bool) string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	table := &PopCheckpointTable{Checkpoints: self.checkpoints}
+	if buf, err := ffjson.Marshal(table); err == nil {
+		return string(buf)
+	}
+	return ""
+}
+
+func (self *PopCheckpointManager) Decode(buf []byte) {
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	table := new(PopCheckpointTable)
+	if err := json.Unmarshal(buf, table); err != nil {
+		logger.Errorf("PopCheckpointManager.Decode() err: %s, buf = %s", err.Error(), string(buf))
+		return
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for key, checkpoint := range table.Checkpoints {
+		if checkpoint.Inflights == nil {
+			checkpoint.Inflights = make(map[int64]*PopInflight)
+		}
+		self.checkpoints[key] = checkpoint
+	}
+}
+
+func (self *PopCheckpointManager) ConfigFilePath() string {
+	homeDir := stgcommon.GetUserHomeDir()
+	if self.BrokerController.BrokerConfig.StorePathRootDir != "" {
+		homeDir = self.BrokerController.BrokerConfig.StorePathRootDir
+	}
+	return GetPopCheckpointPath(homeDir)
+}
+
+// TryLockQueue 锁定队列，队列正在被其他POP请求处理时返回false
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) TryLockQueue(group, topic string, queueId int32) bool {
+	self.queueLocksLock.Lock()
+	defer self.queueLocksLock.Unlock()
+
+	key := buildPopCheckpointKey(group, topic, queueId)
+	if self.queueLocks[key] {
+		return false
+	}
+	self.queueLocks[key] = true
+	return true
+}
+
+// UnlockQueue 释放TryLockQueue锁定的队列
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) UnlockQueue(group, topic string, queueId int32) {
+	self.queueLocksLock.Lock()
+	defer self.queueLocksLock.Unlock()
+
+	delete(self.queueLocks, buildPopCheckpointKey(group, topic, queueId))
+}
+
+// PopOffset 获取下一次POP新消息的起始位点，首次POP时从已提交的消费进度开始，没有消费进度时从队列最小位点开始
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) PopOffset(group, topic string, queueId int32) int64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.getOrCreate(group, topic, queueId).PopOffset
+}
+
+// UpdatePopOffset 更新下一次POP新消息的起始位点
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) UpdatePopOffset(group, topic string, queueId int32, popOffset int64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	checkpoint := self.getOrCreate(group, topic, queueId)
+	if checkpoint.PopOffset == popOffset {
+		return
+	}
+	checkpoint.PopOffset = popOffset
+	self.commitOffset(checkpoint)
+	atomic.AddInt64(&self.dataVersion, 1)
+}
+
+// AddInflight 记录被POP的消息，消息已经在途时(重新投递)更新投递时间并累加投递次数
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) AddInflight(group, topic string, queueId int32, offset, popTime, invisibleTime int64) PopInflight {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	checkpoint := self.getOrCreate(group, topic, queueId)
+	inflight, ok := checkpoint.Inflights[offset]
+	if !ok {
+		inflight = &PopInflight{Offset: offset}
+		checkpoint.Inflights[offset] = inflight
+	}
+	inflight.PopTime = popTime
+	inflight.InvisibleTime = invisibleTime
+	inflight.DeliveryTimes++
+	atomic.AddInt64(&self.dataVersion, 1)
+	return *inflight
+}
+
+// ExpiredInflights 按位点顺序返回不可见时间已到期、需要重新投递的消息，最多maxNums条
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) ExpiredInflights(group, topic string, queueId int32, now int64, maxNums int) []PopInflight {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	checkpoint, ok := self.checkpoints[buildPopCheckpointKey(group, topic, queueId)]
+	if !ok {
+		return nil
+	}
+
+	var expired []PopInflight
+	for _, inflight := range checkpoint.Inflights {
+		if inflight.NextVisibleTime() <= now {
+			expired = append(expired, *inflight)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Offset < expired[j].Offset })
+	if len(expired) > maxNums {
+		expired = expired[:maxNums]
+	}
+	return expired
+}
+
+// NextVisibleTime 晚于after的最早一条未确认消息重新可见的时间(毫秒)，没有这样的消息时返回false
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) NextVisibleTime(group, topic string, queueId int32, after int64) (int64, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	checkpoint, ok := self.checkpoints[buildPopCheckpointKey(group, topic, queueId)]
+	if !ok {
+		return 0, false
+	}
+
+	var nextVisibleTime int64
+	found := false
+	for _, inflight := range checkpoint.Inflights {
+		visibleTime := inflight.NextVisibleTime()
+		if visibleTime > after && (!found || visibleTime < nextVisibleTime) {
+			nextVisibleTime = visibleTime
+			found = true
+		}
+	}
+	return nextVisibleTime, found
+}
+
+// Ack 确认消息，popTime与最近一次投递不一致(消息已被重新投递)或消息不在途时返回false
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) Ack(group, topic string, queueId int32, offset, popTime int64) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	checkpoint, ok := self.checkpoints[buildPopCheckpointKey(group, topic, queueId)]
+	if !ok {
+		return false
+	}
+	inflight, ok := checkpoint.Inflights[offset]
+	if !ok || inflight.PopTime != popTime {
+		return false
+	}
+
+	delete(checkpoint.Inflights, offset)
+	self.commitOffset(checkpoint)
+	atomic.AddInt64(&self.dataVersion, 1)
+	return true
+}
+
+// RemoveInflight 移除在途消息，消息已经被删除或者转入死信队列时使用
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) RemoveInflight(group, topic string, queueId int32, offset int64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	checkpoint, ok := self.checkpoints[buildPopCheckpointKey(group, topic, queueId)]
+	if !ok {
+		return
+	}
+	if _, ok := checkpoint.Inflights[offset]; ok {
+		delete(checkpoint.Inflights, offset)
+		self.commitOffset(checkpoint)
+		atomic.AddInt64(&self.dataVersion, 1)
+	}
+}
+
+// ChangeInvisibleTime 修改消息的不可见时间，从newPopTime开始重新计时；句柄失效时返回false
+// Author agent
+// Since 2026/10/19
+func (self *PopCheckpointManager) ChangeInvisibleTime(group, topic string, queueId int32, offset, popTime, newPopTime, invisibleTime int64) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	checkpoint, ok := self.checkpoints[buildPopCheckpointKey(group, topic, queueId)]
+	if !ok {
+		return false
+	}
+	inflight, ok := checkpoint.Inflights[offset]
+	if !ok || inflight.PopTime != popTime {
+		return false
+	}
+
+	inflight.PopTime = newPopTime
+	inflight.InvisibleTime = invisibleTime
+	atomic.AddInt64(&self.dataVersion, 1)
+	return true
+}
+
+// getOrCreate 调用方需持有写锁
+func (self *PopCheckpointManager) getOrCreate(group, topic string, queueId int32) *PopCheckpoint {
+	key := buildPopCheckpointKey(group, topic, queueId)
+	checkpoint, ok := self.checkpoints[key]
+	if ok {
+		return checkpoint
+	}
+
+	popOffset := self.BrokerController.ConsumerOffsetManager.QueryOffset(group, topic, int(queueId))
+	if popOffset < 0 {
+		popOffset = self.BrokerController.MessageStore.GetMinOffsetInQueue(topic, queueId)
+	}
+	checkpoint = &PopCheckpoint{
+		Topic:         topic,
+		ConsumerGroup: group,
+		QueueId:       queueId,
+		PopOffset:     popOffset,
+		Inflights:     make(map[int64]*PopInflight),
+	}
+	self.checkpoints[key] = checkpoint
+	atomic.AddInt64(&self.dataVersion, 1)
+	return checkpoint
+}
+
+// commitOffset 调用方需持有写锁
+func (self *PopCheckpointManager) commitOffset(checkpoint *PopCheckpoint) {
+	self.BrokerController.ConsumerOffsetManager.CommitOffset(checkpoint.ConsumerGroup, checkpoint.Topic,
+		int(checkpoint.QueueId), checkpoint.commitOffset())
+}
+
+func buildPopCheckpointKey(group, topic string, queueId int32) string {
+	return fmt.Sprintf("%s%s%s%s%d", topic, TOPIC_GROUP_SEPARATOR, group, TOPIC_GROUP_SEPARATOR, queueId)
+}
@@ -0,0 +1,554 @@
+package stgbroker
+
+import (
+	"bytes"
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgbroker/longpolling"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/filter"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxPopPollTimeMills = 20000 // POP请求没有消息时最长等待时间(毫秒)
+)
+
+// PopMessageProcessor POP消费请求处理：由Broker选择队列投递消息并设置不可见时间，消息逐条确认，
+// 不可见时间到期仍未确认的消息会被重新投递，超过订阅组最大重试次数后转入死信队列
+// Author agent
+// Since 2026/10/19
+type PopMessageProcessor struct {
+	BrokerController *BrokerController
+	queueIndex       uint32 // queueId为-1时轮转选择起始队列
+}
+
+// NewPopMessageProcessor 初始化PopMessageProcessor
+// Author agent
+// Since 2026/10/19
+func NewPopMessageProcessor(brokerController *BrokerController) *PopMessageProcessor {
+	var popMessageProcessor = new(PopMessageProcessor)
+	popMessageProcessor.BrokerController = brokerController
+	return popMessageProcessor
+}
+
+// ProcessRequest 请求
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) ProcessRequest(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	switch request.Code {
+	case code.POP_MESSAGE:
+		return pmp.popMessage(ctx, request)
+	case code.ACK_MESSAGE:
+		return pmp.ackMessage(ctx, request)
+	case code.CHANGE_MESSAGE_INVISIBLETIME:
+		return pmp.changeInvisibleTime(ctx, request)
+	}
+	return nil, nil
+}
+
+// popMessage POP消息，没有可投递的消息时最多等待PollTime
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) popMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	return pmp.processPopRequest(ctx, request, timeutil.CurrentTimeMillis())
+}
+
+// ExecuteRequestWhenWakeup 异步重新处理被唤醒的POP请求
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) ExecuteRequestWhenWakeup(popRequest *longpolling.PopRequest) {
+	go func() {
+		pmp.executeRequestWhenWakeup(popRequest)
+	}()
+}
+
+// executeRequestWhenWakeup 同步重新处理被唤醒的POP请求并把结果写回客户端，仍然没有消息且未超时时再次被Hold住
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) executeRequestWhenWakeup(popRequest *longpolling.PopRequest) {
+	ctx, request := popRequest.Context, popRequest.RequestCommand
+	if ctx.IsClosed() {
+		return
+	}
+	response, err := pmp.processPopRequest(ctx, request, popRequest.SuspendTimestamp)
+	if err != nil {
+		logger.Errorf("pop ExecuteRequestWhenWakeup run, throw error:%s", err.Error())
+		return
+	}
+	if response == nil || ctx.IsClosed() {
+		return
+	}
+
+	response.Opaque = request.Opaque
+	response.MarkResponseType()
+	if _, err = ctx.WriteSerialObject(response); err != nil {
+		logger.Errorf("popMessageHold response to %s failed. error:%s", ctx.RemoteAddr().String(), err.Error())
+	}
+}
+
+// processPopRequest 处理POP请求，suspendTimestamp为第一次处理请求的时间；没有可投递的消息时Hold住请求并返回nil，
+// 有新消息到达、不可见时间到期或者等待超时后由PullRequestHoldService唤醒重新处理
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) processPopRequest(ctx netm.Context, request *protocol.RemotingCommand, suspendTimestamp int64) (*protocol.RemotingCommand, error) {
+	responseHeader := &header.PopMessageResponseHeader{}
+	response := protocol.CreateDefaultResponseCommand(responseHeader)
+	response.Opaque = request.Opaque
+
+	requestHeader := &header.PopMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err == nil {
+		err = requestHeader.CheckFields()
+	}
+	if err != nil {
+		logger.Errorf("decode PopMessageRequestHeader err: %s", err.Error())
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	// 检查Broker权限，POP进度只保存在Master上
+	if !pmp.BrokerController.BrokerConfig.HasReadable() {
+		response.Code = code.NO_PERMISSION
+		response.Remark = "the broker[" + pmp.BrokerController.BrokerConfig.BrokerIP1 + "] pulling message is forbidden"
+		return response, nil
+	}
+	if pmp.BrokerController.MessageStoreConfig.BrokerRole == config.SLAVE {
+		response.Code = code.NO_PERMISSION
+		response.Remark = "the broker[" + pmp.BrokerController.BrokerConfig.BrokerIP1 + "] is slave, pop message is forbidden"
+		return response, nil
+	}
+
+	// 确保订阅组存在
+	subscriptionGroupConfig := pmp.BrokerController.SubscriptionGroupManager.FindSubscriptionGroupConfig(requestHeader.ConsumerGroup)
+	if nil == subscriptionGroupConfig {
+		response.Code = code.SUBSCRIPTION_GROUP_NOT_EXIST
+		response.Remark = "subscription group not exist, " + requestHeader.ConsumerGroup
+		return response, nil
+	}
+	if !subscriptionGroupConfig.ConsumeEnable {
+		response.Code = code.NO_PERMISSION
+		response.Remark = "subscription group no permission, " + requestHeader.ConsumerGroup
+		return response, nil
+	}
+
+	// 收发配额
+	if wait, ok := pmp.BrokerController.QuotaManager.CheckPull(ctx, requestHeader.Topic, requestHeader.ConsumerGroup); !ok {
+		response.Code = code.QUOTA_EXCEEDED
+		response.Remark = fmt.Sprintf("the broker[%s] pull quota exceeded, topic: %s, consumerGroup: %s",
+			pmp.BrokerController.BrokerConfig.BrokerIP1, requestHeader.Topic, requestHeader.ConsumerGroup)
+		response.ExtFields[quota.RETRY_AFTER_MILLIS] = strconv.FormatInt(int64(wait/time.Millisecond), 10)
+		return response, nil
+	}
+
+	// 检查topic是否存在及权限
+	topicConfig := pmp.BrokerController.TopicConfigManager.SelectTopicConfig(requestHeader.Topic)
+	if nil == topicConfig {
+		response.Code = code.TOPIC_NOT_EXIST
+		response.Remark = "topic[" + requestHeader.Topic + "] not exist, apply first please!"
+		return response, nil
+	}
+	if !constant.IsReadable(topicConfig.Perm) {
+		response.Code = code.NO_PERMISSION
+		response.Remark = "the topic[" + requestHeader.Topic + "] pulling message is forbidden"
+		return response, nil
+	}
+	if requestHeader.QueueId < -1 || requestHeader.QueueId >= topicConfig.ReadQueueNums {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = fmt.Sprintf("queueId[%d] is illagal, topic: %s, topicConfig.readQueueNums: %d",
+			requestHeader.QueueId, requestHeader.Topic, topicConfig.ReadQueueNums)
+		return response, nil
+	}
+
+	// 订阅关系
+	subString := requestHeader.Exp
+	if subString == "" {
+		subString = "*"
+	}
+	subscriptionData, err := filter.BuildSubscriptionDataByType(requestHeader.ConsumerGroup, requestHeader.Topic,
+		subString, requestHeader.ExpressionType)
+	if err != nil {
+		logger.Warnf("parse the consumer's subscription %s failed, group: %s", subString, requestHeader.ConsumerGroup)
+		response.Code = code.SUBSCRIPTION_PARSE_FAILED
+		response.Remark = "parse the consumer's subscription failed"
+		return response, nil
+	}
+
+	pollTime := requestHeader.PollTime
+	if pollTime > maxPopPollTimeMills {
+		pollTime = maxPopPollTimeMills
+	}
+
+	beginTime := time.Now()
+	popTime := timeutil.CurrentTimeMillis()
+	msgBuffers := pmp.popFromQueues(requestHeader, topicConfig.ReadQueueNums, subscriptionGroupConfig, subscriptionData, popTime)
+	responseHeader.PopTime = popTime
+	responseHeader.InvisibleTime = requestHeader.InvisibleTime
+	if len(msgBuffers) == 0 {
+		// 长轮询，下线(drain)期间不再Hold请求，直接返回让客户端尽快切换
+		deadline := suspendTimestamp + pollTime
+		if popTime < deadline && !ctx.IsClosed() && !pmp.BrokerController.DrainService.IsDraining() {
+			popRequest := longpolling.NewPopRequest(request, ctx, requestHeader.ConsumerGroup, requestHeader.Topic,
+				requestHeader.QueueId, pollTime, suspendTimestamp)
+			wakeupMillis := pmp.nextVisibleTime(requestHeader.ConsumerGroup, requestHeader.Topic,
+				pmp.popQueueIds(requestHeader.QueueId, topicConfig.ReadQueueNums), popTime, deadline)
+			pmp.BrokerController.PullRequestHoldService.SuspendPopRequest(popRequest, wakeupMillis)
+			return nil, nil
+		}
+
+		response.Code = code.PULL_NOT_FOUND
+		response.Remark = "no new message"
+		return response, nil
+	}
+
+	bodyBuffer := bytes.NewBuffer([]byte{})
+	for _, msgBuffer := range msgBuffers {
+		bodyBuffer.Write(msgBuffer)
+	}
+	response.Code = code.SUCCESS
+	response.Body = bodyBuffer.Bytes()
+
+	group, topic := requestHeader.ConsumerGroup, requestHeader.Topic
+	pmp.BrokerController.QuotaManager.RecordPull(ctx, topic, group, int64(len(msgBuffers)), int64(len(response.Body)))
+	pmp.BrokerController.brokerStatsManager.IncGroupGetNums(group, topic, len(msgBuffers))
+	pmp.BrokerController.brokerStatsManager.IncGroupGetSize(group, topic, len(response.Body))
+	pmp.BrokerController.brokerStatsManager.IncBrokerGetNums(len(msgBuffers))
+	pmp.BrokerController.brokerStatsManager.RecordGroupGetLatency(group, topic, int64(time.Since(beginTime)/time.Microsecond))
+	return response, nil
+}
+
+// popFromQueues 依次从各个队列POP消息，直到达到MaxMsgNums
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) popFromQueues(requestHeader *header.PopMessageRequestHeader, readQueueNums int32,
+	subscriptionGroupConfig *subscription.SubscriptionGroupConfig, subscriptionData *heartbeat.SubscriptionData, popTime int64) [][]byte {
+	var msgBuffers [][]byte
+	for _, queueId := range pmp.popQueueIds(requestHeader.QueueId, readQueueNums) {
+		maxNums := int(requestHeader.MaxMsgNums) - len(msgBuffers)
+		if maxNums <= 0 {
+			break
+		}
+		buffers := pmp.popFromQueue(requestHeader, queueId, maxNums, subscriptionGroupConfig, subscriptionData, popTime)
+		msgBuffers = append(msgBuffers, buffers...)
+	}
+	return msgBuffers
+}
+
+// popQueueIds 请求对应的队列，queueId为-1时轮转选择起始队列
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) popQueueIds(queueId, readQueueNums int32) []int32 {
+	var queueIds []int32
+	if queueId >= 0 {
+		queueIds = append(queueIds, queueId)
+	} else if readQueueNums > 0 {
+		start := atomic.AddUint32(&pmp.queueIndex, 1)
+		for i := uint32(0); i < uint32(readQueueNums); i++ {
+			queueIds = append(queueIds, int32((start+i)%uint32(readQueueNums)))
+		}
+	}
+	return queueIds
+}
+
+// nextVisibleTime 被Hold住的请求最迟唤醒的时间：deadline与各队列中最早一条未确认消息重新可见的时间中较早的一个
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) nextVisibleTime(group, topic string, queueIds []int32, now, deadline int64) int64 {
+	wakeupMillis := deadline
+	for _, queueId := range queueIds {
+		visibleTime, ok := pmp.BrokerController.PopCheckpointManager.NextVisibleTime(group, topic, queueId, now)
+		if ok && visibleTime < wakeupMillis {
+			wakeupMillis = visibleTime
+		}
+	}
+	return wakeupMillis
+}
+
+// hasMessageToPop 被Hold住的POP请求对应的队列中是否有新消息，或者有不可见时间已到期的消息
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) hasMessageToPop(popRequest *longpolling.PopRequest) bool {
+	topicConfig := pmp.BrokerController.TopicConfigManager.SelectTopicConfig(popRequest.Topic)
+	if topicConfig == nil {
+		return true
+	}
+
+	group, topic := popRequest.ConsumerGroup, popRequest.Topic
+	checkpointManager := pmp.BrokerController.PopCheckpointManager
+	now := timeutil.CurrentTimeMillis()
+	for _, queueId := range pmp.popQueueIds(popRequest.QueueId, topicConfig.ReadQueueNums) {
+		if pmp.BrokerController.MessageStore.GetMaxOffsetInQueue(topic, queueId) > checkpointManager.PopOffset(group, topic, queueId) {
+			return true
+		}
+		if len(checkpointManager.ExpiredInflights(group, topic, queueId, now, 1)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// popFromQueue 从单个队列POP消息：先重新投递不可见时间已到期的消息，再从PopOffset开始投递新消息；
+// 队列正在被其他POP请求处理时跳过
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) popFromQueue(requestHeader *header.PopMessageRequestHeader, queueId int32, maxNums int,
+	subscriptionGroupConfig *subscription.SubscriptionGroupConfig, subscriptionData *heartbeat.SubscriptionData, popTime int64) [][]byte {
+	group, topic := requestHeader.ConsumerGroup, requestHeader.Topic
+	checkpointManager := pmp.BrokerController.PopCheckpointManager
+	if !checkpointManager.TryLockQueue(group, topic, queueId) {
+		return nil
+	}
+	defer checkpointManager.UnlockQueue(group, topic, queueId)
+
+	var msgBuffers [][]byte
+	for _, inflight := range checkpointManager.ExpiredInflights(group, topic, queueId, popTime, maxNums) {
+		msgBuffer := pmp.lookMessage(group, topic, queueId, inflight.Offset)
+		if msgBuffer == nil {
+			logger.Warnf("pop message not found, drop it. topic=%s, group=%s, queueId=%d, offset=%d", topic, group, queueId, inflight.Offset)
+			checkpointManager.RemoveInflight(group, topic, queueId, inflight.Offset)
+			continue
+		}
+		if inflight.DeliveryTimes > subscriptionGroupConfig.RetryMaxTimes {
+			if pmp.putMessageToDLQ(group, msgBuffer, inflight.DeliveryTimes) {
+				checkpointManager.RemoveInflight(group, topic, queueId, inflight.Offset)
+			}
+			continue
+		}
+		checkpointManager.AddInflight(group, topic, queueId, inflight.Offset, popTime, requestHeader.InvisibleTime)
+		msgBuffers = append(msgBuffers, msgBuffer)
+	}
+
+	maxNums -= len(msgBuffers)
+	if maxNums <= 0 {
+		return msgBuffers
+	}
+
+	popOffset := checkpointManager.PopOffset(group, topic, queueId)
+	getMessageResult := pmp.BrokerController.MessageStore.GetMessage(group, topic, queueId, popOffset, int32(maxNums), subscriptionData)
+	if getMessageResult == nil {
+		return msgBuffers
+	}
+	defer getMessageResult.Release()
+
+	switch getMessageResult.Status {
+	case stgstorelog.FOUND:
+		for e := getMessageResult.MessageBufferList.Front(); e != nil; e = e.Next() {
+			mappedByteBuffer, ok := e.Value.(*stgstorelog.MappedByteBuffer)
+			if !ok {
+				continue
+			}
+			msgBuffer := append([]byte(nil), mappedByteBuffer.Bytes()...)
+			msgExt, err := message.DecodeMessageExt(msgBuffer, false, false)
+			if err != nil || msgExt == nil {
+				logger.Errorf("pop message decode failed, topic=%s, queueId=%d, err: %v", topic, queueId, err)
+				continue
+			}
+			checkpointManager.AddInflight(group, topic, queueId, msgExt.QueueOffset, popTime, requestHeader.InvisibleTime)
+			msgBuffers = append(msgBuffers, msgBuffer)
+		}
+		checkpointManager.UpdatePopOffset(group, topic, queueId, getMessageResult.NextBeginOffset)
+	case stgstorelog.NO_MATCHED_MESSAGE, stgstorelog.NO_MATCHED_LOGIC_QUEUE, stgstorelog.NO_MESSAGE_IN_QUEUE,
+		stgstorelog.OFFSET_TOO_SMALL, stgstorelog.OFFSET_OVERFLOW_BADLY:
+		// 跳过未匹配的消息，或者修正越界的位点
+		checkpointManager.UpdatePopOffset(group, topic, queueId, getMessageResult.NextBeginOffset)
+	default:
+	}
+	return msgBuffers
+}
+
+// lookMessage 读取队列中指定位点的消息，消息已被删除时返回nil
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) lookMessage(group, topic string, queueId int32, offset int64) []byte {
+	getMessageResult := pmp.BrokerController.MessageStore.GetMessage(group, topic, queueId, offset, 1, nil)
+	if getMessageResult == nil {
+		return nil
+	}
+	defer getMessageResult.Release()
+
+	if getMessageResult.Status != stgstorelog.FOUND {
+		return nil
+	}
+	e := getMessageResult.MessageBufferList.Front()
+	if e == nil {
+		return nil
+	}
+	mappedByteBuffer, ok := e.Value.(*stgstorelog.MappedByteBuffer)
+	if !ok {
+		return nil
+	}
+	return append([]byte(nil), mappedByteBuffer.Bytes()...)
+}
+
+// putMessageToDLQ 超过最大重试次数的消息转入消费组的死信队列
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) putMessageToDLQ(group string, msgBuffer []byte, deliveryTimes int32) bool {
+	defer utils.RecoveredFn()
+
+	msgExt, err := message.DecodeMessageExt(msgBuffer, true, false)
+	if err != nil || msgExt == nil {
+		logger.Errorf("pop message decode failed, group=%s, err: %v", group, err)
+		return false
+	}
+
+	newTopic := stgcommon.GetDLQTopic(group)
+	dlqPerm := constant.PERM_WRITE | constant.PERM_READ
+	topicConfig, err := pmp.BrokerController.TopicConfigManager.CreateTopicInSendMessageBackMethod(newTopic, DLQ_NUMS_PER_GROUP, dlqPerm, 0)
+	if topicConfig == nil || err != nil {
+		logger.Errorf("pop message put to DLQ failed, topic[%s] not exist", newTopic)
+		return false
+	}
+
+	if msgExt.GetProperty(message.PROPERTY_RETRY_TOPIC) == "" {
+		message.PutProperty(&msgExt.Message, message.PROPERTY_RETRY_TOPIC, msgExt.Topic)
+	}
+	originMsgId := message.GetOriginMessageId(msgExt.Message)
+	if originMsgId == "" {
+		originMsgId = msgExt.MsgId
+	}
+
+	msgInner := new(stgstorelog.MessageExtBrokerInner)
+	msgInner.Topic = newTopic
+	msgInner.Body = msgExt.Body
+	msgInner.Flag = msgExt.Flag
+	message.SetPropertiesMap(&msgInner.Message, msgExt.Properties)
+	message.SetOriginMessageId(&msgInner.Message, originMsgId)
+	msgInner.PropertiesString = message.MessageProperties2String(msgInner.Properties)
+	msgInner.TagsCode = stgstorelog.TagsString2tagsCode(stgcommon.SINGLE_TAG, msgExt.GetTags())
+	msgInner.QueueId = 0
+	msgInner.SysFlag = msgExt.SysFlag
+	msgInner.BornTimestamp = msgExt.BornTimestamp
+	msgInner.BornHost = msgExt.BornHost
+	msgInner.StoreHost = pmp.BrokerController.GetStoreHost()
+	msgInner.ReconsumeTimes = deliveryTimes
+
+	putMessageResult := pmp.BrokerController.MessageStore.PutMessage(msgInner)
+	if putMessageResult == nil || putMessageResult.PutMessageStatus != stgstorelog.PUTMESSAGE_PUT_OK {
+		logger.Errorf("pop message put to DLQ failed, topic=%s, msgId=%s", newTopic, msgExt.MsgId)
+		return false
+	}
+
+	logger.Infof("pop message put to DLQ, topic=%s, msgId=%s, deliveryTimes=%d", newTopic, msgExt.MsgId, deliveryTimes)
+	pmp.BrokerController.brokerStatsManager.IncSendBackNums(group, msgExt.Topic)
+	return true
+}
+
+// ackMessage 确认单条POP消息
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) ackMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+	response.Opaque = request.Opaque
+
+	requestHeader := &header.AckMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err == nil {
+		err = requestHeader.CheckFields()
+	}
+	if err != nil {
+		logger.Errorf("decode AckMessageRequestHeader err: %s", err.Error())
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	handle, ok := pmp.checkReceiptHandle(response, requestHeader.ExtraInfo, requestHeader.Topic, requestHeader.QueueId, requestHeader.Offset)
+	if !ok {
+		return response, nil
+	}
+
+	if !pmp.BrokerController.PopCheckpointManager.Ack(requestHeader.ConsumerGroup, requestHeader.Topic, handle.QueueId, handle.Offset, handle.PopTime) {
+		response.Code = code.INVALID_RECEIPT_HANDLE
+		response.Remark = fmt.Sprintf("the receipt handle[%s] has been acked or redelivered", requestHeader.ExtraInfo)
+		return response, nil
+	}
+
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// changeInvisibleTime 修改单条POP消息的不可见时间，从当前时间重新计时
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) changeInvisibleTime(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	responseHeader := &header.ChangeInvisibleTimeResponseHeader{}
+	response := protocol.CreateDefaultResponseCommand(responseHeader)
+	response.Opaque = request.Opaque
+
+	requestHeader := &header.ChangeInvisibleTimeRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err == nil {
+		err = requestHeader.CheckFields()
+	}
+	if err != nil {
+		logger.Errorf("decode ChangeInvisibleTimeRequestHeader err: %s", err.Error())
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	handle, ok := pmp.checkReceiptHandle(response, requestHeader.ExtraInfo, requestHeader.Topic, requestHeader.QueueId, requestHeader.Offset)
+	if !ok {
+		return response, nil
+	}
+
+	popTime := timeutil.CurrentTimeMillis()
+	if !pmp.BrokerController.PopCheckpointManager.ChangeInvisibleTime(requestHeader.ConsumerGroup, requestHeader.Topic,
+		handle.QueueId, handle.Offset, handle.PopTime, popTime, requestHeader.InvisibleTime) {
+		response.Code = code.INVALID_RECEIPT_HANDLE
+		response.Remark = fmt.Sprintf("the receipt handle[%s] has been acked or redelivered", requestHeader.ExtraInfo)
+		return response, nil
+	}
+
+	responseHeader.PopTime = popTime
+	responseHeader.InvisibleTime = requestHeader.InvisibleTime
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// checkReceiptHandle 解析并校验ReceiptHandle，校验失败时设置response
+// Author agent
+// Since 2026/10/19
+func (pmp *PopMessageProcessor) checkReceiptHandle(response *protocol.RemotingCommand, extraInfo, topic string,
+	queueId int32, offset int64) (*message.ReceiptHandle, bool) {
+	if pmp.BrokerController.MessageStoreConfig.BrokerRole == config.SLAVE {
+		response.Code = code.NO_PERMISSION
+		response.Remark = "the broker[" + pmp.BrokerController.BrokerConfig.BrokerIP1 + "] is slave, pop message is forbidden"
+		return nil, false
+	}
+
+	handle, err := message.DecodeReceiptHandle(extraInfo)
+	if err != nil {
+		response.Code = code.INVALID_RECEIPT_HANDLE
+		response.Remark = err.Error()
+		return nil, false
+	}
+
+	if handle.Topic != topic || handle.QueueId != queueId || handle.Offset != offset ||
+		handle.BrokerName != pmp.BrokerController.BrokerConfig.BrokerName {
+		response.Code = code.INVALID_RECEIPT_HANDLE
+		response.Remark = fmt.Sprintf("the receipt handle[%s] not match topic=%s, queueId=%d, offset=%d, brokerName=%s",
+			extraInfo, topic, queueId, offset, pmp.BrokerController.BrokerConfig.BrokerName)
+		return nil, false
+	}
+	return handle, true
+}
@@ -14,6 +14,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/topic"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
@@ -22,6 +23,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgstorelog"
 	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
 	"strconv"
+	"time"
 )
 
 // PullMessageProcessor 拉消息请求处理
@@ -51,28 +53,35 @@ func (pull *PullMessageProcessor) ProcessRequest(ctx netm.Context, request *prot
 // Since 2017/9/5
 func (pull *PullMessageProcessor) ExecuteRequestWhenWakeup(ctx netm.Context, request *protocol.RemotingCommand) {
 	go func() {
-		//logger.Info("....唤醒HoldPullRequest: ExtFields:%v, Opaque:%d", request.ExtFields, request.Opaque)
-		response, err := pull.processRequest(request, ctx, false)
-		if err != nil {
-			logger.Errorf("ExecuteRequestWhenWakeup run, throw error:%s", err.Error())
-			return
-		}
-		if response == nil {
-			return
-		}
+		pull.executeRequestWhenWakeup(ctx, request)
+	}()
+}
 
-		if ctx.IsClosed() {
-			return
-		}
+// executeRequestWhenWakeup 同步处理被唤醒的拉取消息请求并把结果写回客户端
+// Author agent
+// Since 2026/10/19
+func (pull *PullMessageProcessor) executeRequestWhenWakeup(ctx netm.Context, request *protocol.RemotingCommand) {
+	//logger.Info("....唤醒HoldPullRequest: ExtFields:%v, Opaque:%d", request.ExtFields, request.Opaque)
+	response, err := pull.processRequest(request, ctx, false)
+	if err != nil {
+		logger.Errorf("ExecuteRequestWhenWakeup run, throw error:%s", err.Error())
+		return
+	}
+	if response == nil {
+		return
+	}
 
-		response.Opaque = request.Opaque
-		response.MarkResponseType()
-		_, err = ctx.WriteSerialObject(response)
-		if err != nil {
-			format := "pullMessageHold response to %s failed. error:%s. ### request:%s, ### response:%s"
-			logger.Errorf(format, ctx.RemoteAddr().String(), err.Error(), request.ToString(), response.ToString())
-		}
-	}()
+	if ctx.IsClosed() {
+		return
+	}
+
+	response.Opaque = request.Opaque
+	response.MarkResponseType()
+	_, err = ctx.WriteSerialObject(response)
+	if err != nil {
+		format := "pullMessageHold response to %s failed. error:%s. ### request:%s, ### response:%s"
+		logger.Errorf(format, ctx.RemoteAddr().String(), err.Error(), request.ToString(), response.ToString())
+	}
 }
 
 func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingCommand, ctx netm.Context, brokerAllowSuspend bool) (*protocol.RemotingCommand, error) {
@@ -110,6 +119,15 @@ func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingComma
 		return response, nil
 	}
 
+	// 收发配额
+	if wait, ok := pull.BrokerController.QuotaManager.CheckPull(ctx, requestHeader.Topic, requestHeader.ConsumerGroup); !ok {
+		response.Code = code.QUOTA_EXCEEDED
+		response.Remark = fmt.Sprintf("the broker[%s] pull quota exceeded, topic: %s, consumerGroup: %s",
+			pull.BrokerController.BrokerConfig.BrokerIP1, requestHeader.Topic, requestHeader.ConsumerGroup)
+		response.ExtFields[quota.RETRY_AFTER_MILLIS] = strconv.FormatInt(int64(wait/time.Millisecond), 10)
+		return response, nil
+	}
+
 	hasSuspendFlag := sysflag.HasSuspendFlag(requestHeader.SysFlag)
 	hasCommitOffsetFlag := sysflag.HasCommitOffsetFlag(requestHeader.SysFlag)
 	hasSubscriptionFlag := sysflag.HasSubscriptionFlag(requestHeader.SysFlag)
@@ -146,7 +164,8 @@ func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingComma
 	subscriptionData := &heartbeat.SubscriptionData{}
 	if hasSubscriptionFlag {
 		var err error
-		subscriptionData, err = filter.BuildSubscriptionData4Ponit(requestHeader.ConsumerGroup, requestHeader.Topic, requestHeader.Subscription)
+		subscriptionData, err = filter.BuildSubscriptionDataByType(requestHeader.ConsumerGroup, requestHeader.Topic,
+			requestHeader.Subscription, requestHeader.ExpressionType)
 		if err != nil {
 			logger.Warnf("parse the consumer's subscription %s failed, group: %s", requestHeader.Subscription, requestHeader.ConsumerGroup)
 			response.Code = code.SUBSCRIPTION_PARSE_FAILED
@@ -186,8 +205,10 @@ func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingComma
 		}
 	}
 
+	beginGetTime := time.Now()
 	getMessageResult := pull.BrokerController.MessageStore.GetMessage(requestHeader.ConsumerGroup, requestHeader.Topic,
 		requestHeader.QueueId, requestHeader.QueueOffset, int32(requestHeader.MaxMsgNums), subscriptionData)
+	getLatency := int64(time.Since(beginGetTime) / time.Microsecond)
 	if nil != getMessageResult {
 		response.Remark = getMessageResult.Status.String()
 		responseHeader.NextBeginOffset = getMessageResult.NextBeginOffset
@@ -205,6 +226,8 @@ func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingComma
 		switch getMessageResult.Status {
 		case stgstorelog.FOUND:
 			response.Code = code.SUCCESS
+			pull.BrokerController.QuotaManager.RecordPull(ctx, requestHeader.Topic, requestHeader.ConsumerGroup,
+				int64(getMessageResult.GetMessageCount()), int64(getMessageResult.BufferTotalSize))
 
 			// 消息轨迹：记录客户端拉取的消息记录（不表示消费成功）
 			if pull.hasConsumeMessageHook() {
@@ -263,6 +286,7 @@ func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingComma
 			pull.BrokerController.brokerStatsManager.IncGroupGetNums(requestHeader.ConsumerGroup, requestHeader.Topic, getMessageResult.GetMessageCount())
 			pull.BrokerController.brokerStatsManager.IncGroupGetSize(requestHeader.ConsumerGroup, requestHeader.Topic, getMessageResult.BufferTotalSize)
 			pull.BrokerController.brokerStatsManager.IncBrokerGetNums(getMessageResult.GetMessageCount())
+			pull.BrokerController.brokerStatsManager.RecordGroupGetLatency(requestHeader.ConsumerGroup, requestHeader.Topic, getLatency)
 
 			manyMessageTransfer := pagecache.NewManyMessageTransfer(response, getMessageResult)
 			_, err = ctx.WriteSerialObject(manyMessageTransfer)
@@ -273,8 +297,8 @@ func (pull *PullMessageProcessor) processRequest(request *protocol.RemotingComma
 			getMessageResult.Release()
 			response = nil
 		case code.PULL_NOT_FOUND:
-			// 长轮询
-			if brokerAllowSuspend && hasSuspendFlag {
+			// 长轮询，下线(drain)期间不再Hold请求，直接返回让客户端尽快切换
+			if brokerAllowSuspend && hasSuspendFlag && !pull.BrokerController.DrainService.IsDraining() {
 				//logger.Infof("进入hold pull: ExtFields=%#v, Opaque=%d", request.ExtFields, request.Opaque)
 				pollingTimeMills := suspendTimeoutMillisLong
 				if !pull.BrokerController.BrokerConfig.LongPollingEnable {
@@ -10,6 +10,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
 	"strconv"
 	"strings"
+	gosync "sync"
 	"time"
 )
 
@@ -21,6 +22,8 @@ type PullRequestHoldService struct {
 	pullRequestTable        *sync.Map // key:topic@queueid value:ManyPullRequest
 	brokerController        *BrokerController
 	isStopped               bool
+	popRequestTable         map[string][]*longpolling.PopRequest // key:topic
+	popRequestLock          gosync.Mutex
 }
 
 // NewPullRequestHoldService 初始化拉消息请求服务
@@ -31,6 +34,7 @@ func NewPullRequestHoldService(brokerController *BrokerController) *PullRequestH
 	holdServ.pullRequestTable = sync.NewMap()
 	holdServ.TOPIC_QUEUEID_SEPARATOR = TOPIC_GROUP_SEPARATOR
 	holdServ.brokerController = brokerController
+	holdServ.popRequestTable = make(map[string][]*longpolling.PopRequest)
 	return holdServ
 }
 
@@ -154,6 +158,7 @@ func (serv *PullRequestHoldService) run() {
 	for !serv.isStopped {
 		time.Sleep(time.Millisecond * time.Duration(1000))
 		serv.checkHoldRequest()
+		serv.checkHoldPopRequest()
 	}
 
 	logger.Info(fmt.Sprintf("%s service end", serv.getServiceName()))
@@ -177,9 +182,203 @@ func (serv *PullRequestHoldService) Shutdown() {
 	logger.Info("PullRequestHoldService shutdown successful")
 }
 
+// WakeupAll 立即响应所有被Hold住的拉消息请求，最多等待timeoutMills，返回被唤醒的请求数以及是否全部响应完毕
+// Author agent
+// Since 2026/10/19
+func (serv *PullRequestHoldService) WakeupAll(timeoutMills int64) (int, bool) {
+	requestList := make([]*longpolling.PullRequest, 0)
+	for iter := serv.pullRequestTable.Iterator(); iter.HasNext(); {
+		_, value, _ := iter.Next()
+		if mpr, ok := value.(*longpolling.ManyPullRequest); ok {
+			requestList = append(requestList, mpr.CloneListAndClear()...)
+		}
+	}
+	popRequestList := serv.takePopRequests(serv.clonePopRequests())
+	if len(requestList) == 0 && len(popRequestList) == 0 {
+		return 0, true
+	}
+
+	var wg gosync.WaitGroup
+	for _, pullRequest := range requestList {
+		wg.Add(1)
+		go func(pullRequest *longpolling.PullRequest) {
+			defer wg.Done()
+			serv.brokerController.PullMessageProcessor.executeRequestWhenWakeup(pullRequest.Context, pullRequest.RequestCommand)
+		}(pullRequest)
+	}
+	for _, popRequest := range popRequestList {
+		wg.Add(1)
+		go func(popRequest *longpolling.PopRequest) {
+			defer wg.Done()
+			serv.brokerController.PopMessageProcessor.executeRequestWhenWakeup(popRequest)
+		}(popRequest)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return len(requestList) + len(popRequestList), true
+	case <-time.After(time.Duration(timeoutMills) * time.Millisecond):
+		return len(requestList) + len(popRequestList), false
+	}
+}
+
+// SuspendPopRequest Hold住没有可投递消息的POP请求，直到有新消息到达，或者到wakeupMillis时重新处理
+// Author agent
+// Since 2026/10/19
+func (serv *PullRequestHoldService) SuspendPopRequest(popRequest *longpolling.PopRequest, wakeupMillis int64) {
+	serv.popRequestLock.Lock()
+	serv.popRequestTable[popRequest.Topic] = append(serv.popRequestTable[popRequest.Topic], popRequest)
+	serv.popRequestLock.Unlock()
+
+	delay := time.Duration(wakeupMillis-timeutil.CurrentTimeMillis()) * time.Millisecond
+	time.AfterFunc(delay, func() {
+		serv.wakeupPopRequests([]*longpolling.PopRequest{popRequest})
+	})
+}
+
+// NotifyPopMessageArriving topic有新消息时唤醒该topic上被Hold住的POP请求
+// Author agent
+// Since 2026/10/19
+func (serv *PullRequestHoldService) NotifyPopMessageArriving(topic string) {
+	serv.popRequestLock.Lock()
+	popRequestList := serv.popRequestTable[topic]
+	delete(serv.popRequestTable, topic)
+	serv.popRequestLock.Unlock()
+
+	for _, popRequest := range popRequestList {
+		if popRequest.Wakeup() {
+			serv.brokerController.PopMessageProcessor.ExecuteRequestWhenWakeup(popRequest)
+		}
+	}
+}
+
+// checkHoldPopRequest 唤醒队列中已有可投递消息的POP请求，没有经过SendMessageProcessor写入的消息靠这里发现
+// Author agent
+// Since 2026/10/19
+func (serv *PullRequestHoldService) checkHoldPopRequest() {
+	var readyList []*longpolling.PopRequest
+	for _, popRequest := range serv.clonePopRequests() {
+		if popRequest.Context.IsClosed() || serv.brokerController.PopMessageProcessor.hasMessageToPop(popRequest) {
+			readyList = append(readyList, popRequest)
+		}
+	}
+	serv.wakeupPopRequests(readyList)
+}
+
+// wakeupPopRequests 移出并重新处理POP请求，已经被唤醒过的请求跳过
+func (serv *PullRequestHoldService) wakeupPopRequests(popRequestList []*longpolling.PopRequest) {
+	for _, popRequest := range serv.takePopRequests(popRequestList) {
+		serv.brokerController.PopMessageProcessor.ExecuteRequestWhenWakeup(popRequest)
+	}
+}
+
+// takePopRequests 把POP请求移出Hold列表并标记为已唤醒，返回本次成功唤醒的请求
+func (serv *PullRequestHoldService) takePopRequests(popRequestList []*longpolling.PopRequest) []*longpolling.PopRequest {
+	var woken []*longpolling.PopRequest
+	for _, popRequest := range popRequestList {
+		if popRequest.Wakeup() {
+			woken = append(woken, popRequest)
+		}
+	}
+	if len(woken) == 0 {
+		return woken
+	}
+
+	serv.popRequestLock.Lock()
+	defer serv.popRequestLock.Unlock()
+	for _, popRequest := range woken {
+		held := serv.popRequestTable[popRequest.Topic]
+		for i, request := range held {
+			if request == popRequest {
+				held = append(held[:i], held[i+1:]...)
+				break
+			}
+		}
+		if len(held) == 0 {
+			delete(serv.popRequestTable, popRequest.Topic)
+		} else {
+			serv.popRequestTable[popRequest.Topic] = held
+		}
+	}
+	return woken
+}
+
+// clonePopRequests 当前所有被Hold住的POP请求
+func (serv *PullRequestHoldService) clonePopRequests() []*longpolling.PopRequest {
+	serv.popRequestLock.Lock()
+	defer serv.popRequestLock.Unlock()
+
+	var popRequestList []*longpolling.PopRequest
+	for _, held := range serv.popRequestTable {
+		popRequestList = append(popRequestList, held...)
+	}
+	return popRequestList
+}
+
 // getServiceName  获得类名
 // Author rongzhihong
 // Since 2017/9/5
 func (serv *PullRequestHoldService) getServiceName() string {
 	return "PullRequestHoldService"
 }
+
+// HoldRequestView 被Hold住的拉消息请求只读视图
+// Author agent
+// Since 2026/10/19
+type HoldRequestView struct {
+	Topic              string `json:"topic"`
+	QueueId            int32  `json:"queueId"`
+	RemoteAddr         string `json:"remoteAddr"`
+	PullFromThisOffset int64  `json:"pullFromThisOffset"`
+	SuspendTimestamp   int64  `json:"suspendTimestamp"`
+	TimeoutMillis      int64  `json:"timeoutMillis"`
+}
+
+// HoldRequestViews 获得当前所有被Hold住的拉消息请求
+// Author agent
+// Since 2026/10/19
+func (serv *PullRequestHoldService) HoldRequestViews() []*HoldRequestView {
+	views := make([]*HoldRequestView, 0)
+	for iter := serv.pullRequestTable.Iterator(); iter.HasNext(); {
+		key, value, _ := iter.Next()
+		item, ok := key.(string)
+		if !ok {
+			continue
+		}
+		mpr, ok := value.(*longpolling.ManyPullRequest)
+		if !ok {
+			continue
+		}
+
+		index := strings.LastIndex(item, serv.TOPIC_QUEUEID_SEPARATOR)
+		if index < 0 {
+			continue
+		}
+		topic := item[:index]
+		queueId, err := strconv.Atoi(item[index+len(serv.TOPIC_QUEUEID_SEPARATOR):])
+		if err != nil {
+			continue
+		}
+
+		for _, pullRequest := range mpr.CloneList() {
+			view := &HoldRequestView{
+				Topic:              topic,
+				QueueId:            int32(queueId),
+				PullFromThisOffset: pullRequest.PullFromThisOffset,
+				SuspendTimestamp:   pullRequest.SuspendTimestamp,
+				TimeoutMillis:      pullRequest.TimeoutMillis,
+			}
+			if pullRequest.Context != nil {
+				view.RemoteAddr = pullRequest.Context.RemoteAddr().String()
+			}
+			views = append(views, view)
+		}
+	}
+	return views
+}
@@ -48,7 +48,7 @@ func (qmp *QueryMessageProcessor) QueryMessage(ctx netm.Context, request *protoc
 	response := protocol.CreateDefaultResponseCommand(responseHeader)
 
 	requestHeader := &header.QueryMessageRequestHeader{}
-	err := response.DecodeCommandCustomHeader(requestHeader)
+	err := request.DecodeCommandCustomHeader(requestHeader)
 	if err != nil {
 		logger.Error(err)
 	}
@@ -70,7 +70,7 @@ func (qmp *QueryMessageProcessor) QueryMessage(ctx netm.Context, request *protoc
 			if err != nil {
 				logger.Errorf("transfer query message by pagecache failed, %s", err.Error())
 			}
-			// TODO queryMessageTransfer.Release()
+			queryMessageResult.Release()
 			return nil, nil
 		}
 	}
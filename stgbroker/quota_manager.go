@@ -0,0 +1,280 @@
+package stgbroker
+
+import (
+	"encoding/json"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"github.com/pquerna/ffjson/ffjson"
+	"sync"
+	"time"
+)
+
+const (
+	quotaMetricSendMsg   = "sendMsg"
+	quotaMetricSendBytes = "sendBytes"
+	quotaMetricPullMsg   = "pullMsg"
+	quotaMetricPullBytes = "pullBytes"
+)
+
+// quotaAcquired 已经获取的令牌，失败时需要归还
+type quotaAcquired struct {
+	bucket *quota.TokenBucket
+	n      int64
+}
+
+// QuotaManager 管理topic、生产组、消费组、客户端ID的收发配额，按令牌桶限流
+// Author agent
+// Since 2026/10/19
+type QuotaManager struct {
+	BrokerController *BrokerController
+	QuotaConfigTable *quota.QuotaConfigTable
+	ConfigManagerExt *ConfigManagerExt
+	buckets          map[string]*quota.TokenBucket // key: resourceType@resourceName#metric
+	bucketsLock      sync.Mutex
+}
+
+// NewQuotaManager 创建QuotaManager
+// Author agent
+// Since 2026/10/19
+func NewQuotaManager(brokerController *BrokerController) *QuotaManager {
+	quotaManager := new(QuotaManager)
+	quotaManager.BrokerController = brokerController
+	quotaManager.QuotaConfigTable = quota.NewQuotaConfigTable()
+	quotaManager.ConfigManagerExt = NewConfigManagerExt(quotaManager)
+	quotaManager.buckets = make(map[string]*quota.TokenBucket)
+	return quotaManager
+}
+
+func (self *QuotaManager) Load() bool {
+	return self.ConfigManagerExt.Load()
+}
+
+func (self *QuotaManager) Encode(prettyFormat bool) string {
+	if buf, err := ffjson.Marshal(self.QuotaConfigTable); err == nil {
+		return string(buf)
+	}
+	return ""
+}
+
+func (self *QuotaManager) Decode(buf []byte) {
+	if buf == nil || len(buf) == 0 {
+		return
+	}
+	quotaConfigTable := quota.NewQuotaConfigTable()
+	if err := json.Unmarshal(buf, quotaConfigTable); err != nil {
+		logger.Errorf("QuotaManager.Decode() err: %s, buf = %s", err.Error(), string(buf))
+		return
+	}
+	self.QuotaConfigTable.DataVersion.AssignNewOne(quotaConfigTable.DataVersion)
+	self.QuotaConfigTable.ClearAndPutAll(quotaConfigTable.QuotaConfigTable)
+	self.resetBuckets()
+}
+
+func (self *QuotaManager) ConfigFilePath() string {
+	homeDir := stgcommon.GetUserHomeDir()
+	if self.BrokerController.BrokerConfig.StorePathRootDir != "" {
+		homeDir = self.BrokerController.BrokerConfig.StorePathRootDir
+	}
+	return GetQuotaConfigPath(homeDir)
+}
+
+// UpdateQuotaConfig 创建或更新配额
+// Author agent
+// Since 2026/10/19
+func (self *QuotaManager) UpdateQuotaConfig(config *quota.QuotaConfig) {
+	old := self.QuotaConfigTable.Put(config)
+	if old != nil {
+		logger.Infof("update quota config, old: %s, new: %s", old.ToString(), config.ToString())
+	} else {
+		logger.Infof("create new quota config: %s", config.ToString())
+	}
+
+	self.removeBuckets(config.Key())
+	self.QuotaConfigTable.DataVersion.NextVersion()
+	self.ConfigManagerExt.Persist()
+}
+
+// DeleteQuotaConfig 删除配额，返回被删除的配额，不存在时返回nil
+// Author agent
+// Since 2026/10/19
+func (self *QuotaManager) DeleteQuotaConfig(resourceType, resourceName string) *quota.QuotaConfig {
+	old := self.QuotaConfigTable.Remove(resourceType, resourceName)
+	if old == nil {
+		logger.Warnf("delete quota config failed, quota config not exist. %s", quota.BuildQuotaKey(resourceType, resourceName))
+		return nil
+	}
+
+	logger.Infof("delete quota config OK, %s", old.ToString())
+	self.removeBuckets(old.Key())
+	self.QuotaConfigTable.DataVersion.NextVersion()
+	self.ConfigManagerExt.Persist()
+	return old
+}
+
+// ReplaceAll 用master的配额整体替换本地配额，slave同步时使用
+// Author agent
+// Since 2026/10/19
+func (self *QuotaManager) ReplaceAll(quotaConfigTable *quota.QuotaConfigTable) {
+	self.QuotaConfigTable.DataVersion.AssignNewOne(quotaConfigTable.DataVersion)
+	self.QuotaConfigTable.ClearAndPutAll(quotaConfigTable.QuotaConfigTable)
+	self.resetBuckets()
+	self.ConfigManagerExt.Persist()
+}
+
+// AcquireSend 发送消息前获取topic、生产组、客户端ID上的发送配额，任意一项超出时返回建议的重试间隔
+// Author agent
+// Since 2026/10/19
+func (self *QuotaManager) AcquireSend(ctx netm.Context, topic, producerGroup string, msgNums, bodySize int64) (time.Duration, bool) {
+	if self.QuotaConfigTable.Size() == 0 {
+		return 0, true
+	}
+
+	var acquiredList []quotaAcquired
+	for _, config := range self.matchSendConfigs(ctx, topic, producerGroup) {
+		for _, item := range []struct {
+			metric string
+			rate   int64
+			n      int64
+		}{
+			{quotaMetricSendMsg, config.SendMsgPerSecond, msgNums},
+			{quotaMetricSendBytes, config.SendBytesPerSecond, bodySize},
+		} {
+			bucket := self.getBucket(config.Key(), item.metric, item.rate)
+			if bucket == nil {
+				continue
+			}
+			if wait, ok := bucket.TryAcquire(item.n); !ok {
+				for _, acquired := range acquiredList {
+					acquired.bucket.Refund(acquired.n)
+				}
+				return wait, false
+			}
+			acquiredList = append(acquiredList, quotaAcquired{bucket: bucket, n: item.n})
+		}
+	}
+	return 0, true
+}
+
+// CheckPull 拉取消息前检查topic、消费组、客户端ID上的拉取配额是否已透支，
+// 拉取到的实际条数、字节数由RecordPull扣除
+// Author agent
+// Since 2026/10/19
+func (self *QuotaManager) CheckPull(ctx netm.Context, topic, consumerGroup string) (time.Duration, bool) {
+	if self.QuotaConfigTable.Size() == 0 {
+		return 0, true
+	}
+
+	var maxWait time.Duration
+	for _, config := range self.matchPullConfigs(ctx, topic, consumerGroup) {
+		for _, bucket := range self.pullBuckets(config) {
+			if wait := bucket.Wait(); wait > maxWait {
+				maxWait = wait
+			}
+		}
+	}
+	return maxWait, maxWait == 0
+}
+
+// RecordPull 扣除本次拉取到的消息条数及字节数
+// Author agent
+// Since 2026/10/19
+func (self *QuotaManager) RecordPull(ctx netm.Context, topic, consumerGroup string, msgNums, bodySize int64) {
+	if self.QuotaConfigTable.Size() == 0 {
+		return
+	}
+
+	for _, config := range self.matchPullConfigs(ctx, topic, consumerGroup) {
+		if bucket := self.getBucket(config.Key(), quotaMetricPullMsg, config.PullMsgPerSecond); bucket != nil {
+			bucket.Consume(msgNums)
+		}
+		if bucket := self.getBucket(config.Key(), quotaMetricPullBytes, config.PullBytesPerSecond); bucket != nil {
+			bucket.Consume(bodySize)
+		}
+	}
+}
+
+func (self *QuotaManager) pullBuckets(config *quota.QuotaConfig) []*quota.TokenBucket {
+	var buckets []*quota.TokenBucket
+	if bucket := self.getBucket(config.Key(), quotaMetricPullMsg, config.PullMsgPerSecond); bucket != nil {
+		buckets = append(buckets, bucket)
+	}
+	if bucket := self.getBucket(config.Key(), quotaMetricPullBytes, config.PullBytesPerSecond); bucket != nil {
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+func (self *QuotaManager) matchSendConfigs(ctx netm.Context, topic, producerGroup string) []*quota.QuotaConfig {
+	configs := self.matchConfigs(quota.RESOURCE_TOPIC, topic, quota.RESOURCE_PRODUCER_GROUP, producerGroup)
+	if clientId := self.BrokerController.ProducerManager.FindClientId(producerGroup, ctx); clientId != "" {
+		if config := self.QuotaConfigTable.Get(quota.RESOURCE_CLIENT_ID, clientId); config != nil {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
+func (self *QuotaManager) matchPullConfigs(ctx netm.Context, topic, consumerGroup string) []*quota.QuotaConfig {
+	configs := self.matchConfigs(quota.RESOURCE_TOPIC, topic, quota.RESOURCE_CONSUMER_GROUP, consumerGroup)
+	consumerGroupInfo := self.BrokerController.ConsumerManager.GetConsumerGroupInfo(consumerGroup)
+	if consumerGroupInfo == nil {
+		return configs
+	}
+	if clientId := consumerGroupInfo.FindClientId(ctx); clientId != "" {
+		if config := self.QuotaConfigTable.Get(quota.RESOURCE_CLIENT_ID, clientId); config != nil {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
+func (self *QuotaManager) matchConfigs(topicType, topic, groupType, group string) []*quota.QuotaConfig {
+	var configs []*quota.QuotaConfig
+	if config := self.QuotaConfigTable.Get(topicType, topic); config != nil {
+		configs = append(configs, config)
+	}
+	if config := self.QuotaConfigTable.Get(groupType, group); config != nil {
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// getBucket 获取配额某项指标对应的令牌桶，rate<=0表示不限制，返回nil
+func (self *QuotaManager) getBucket(configKey, metric string, rate int64) *quota.TokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	self.bucketsLock.Lock()
+	defer self.bucketsLock.Unlock()
+
+	key := configKey + "#" + metric
+	bucket, ok := self.buckets[key]
+	if !ok {
+		bucket = quota.NewTokenBucket(rate)
+		self.buckets[key] = bucket
+		return bucket
+	}
+	if bucket.Rate() != rate {
+		bucket.SetRate(rate)
+	}
+	return bucket
+}
+
+func (self *QuotaManager) removeBuckets(configKey string) {
+	self.bucketsLock.Lock()
+	defer self.bucketsLock.Unlock()
+
+	for _, metric := range []string{quotaMetricSendMsg, quotaMetricSendBytes, quotaMetricPullMsg, quotaMetricPullBytes} {
+		delete(self.buckets, configKey+"#"+metric)
+	}
+}
+
+func (self *QuotaManager) resetBuckets() {
+	self.bucketsLock.Lock()
+	defer self.bucketsLock.Unlock()
+
+	self.buckets = make(map[string]*quota.TokenBucket)
+}
@@ -0,0 +1,116 @@
+package stgbroker
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgbroker/client"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"strconv"
+)
+
+// ReplyMessageProcessor 应答消息处理，将Consumer发送的应答直接推送给请求方，不写入存储
+// Author agent
+// Since 2026/10/19
+type ReplyMessageProcessor struct {
+	BrokerController *BrokerController
+}
+
+// NewReplyMessageProcessor 初始化ReplyMessageProcessor
+// Author agent
+// Since 2026/10/19
+func NewReplyMessageProcessor(brokerController *BrokerController) *ReplyMessageProcessor {
+	var replyMessageProcessor = new(ReplyMessageProcessor)
+	replyMessageProcessor.BrokerController = brokerController
+	return replyMessageProcessor
+}
+
+// ProcessRequest 请求
+// Author agent
+// Since 2026/10/19
+func (rmp *ReplyMessageProcessor) ProcessRequest(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	switch request.Code {
+	case code.SEND_REPLY_MESSAGE:
+		return rmp.processReplyMessage(ctx, request)
+	}
+	return nil, nil
+}
+
+// processReplyMessage 根据REPLY_TO_CLIENT找到请求方的连接并推送应答
+// Author agent
+// Since 2026/10/19
+func (rmp *ReplyMessageProcessor) processReplyMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+	response.Opaque = request.Opaque
+
+	requestHeader := &header.ReplyMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		logger.Errorf("decode ReplyMessageRequestHeader err: %s", err.Error())
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+
+	properties := message.String2messageProperties(requestHeader.Properties)
+	correlationId := properties[message.PROPERTY_CORRELATION_ID]
+	replyToClient := properties[message.PROPERTY_MESSAGE_REPLY_TO_CLIENT]
+	if correlationId == "" || replyToClient == "" {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = "the reply message has no CORRELATION_ID or REPLY_TO_CLIENT property"
+		return response, nil
+	}
+
+	if deadline, err := strconv.ParseInt(properties[message.PROPERTY_REQUEST_DEADLINE], 10, 64); err == nil {
+		if timeutil.CurrentTimeMillis() > deadline {
+			logger.Warnf("reply message expired, correlationId=%s, replyToClient=%s", correlationId, replyToClient)
+			response.Code = code.SYSTEM_ERROR
+			response.Remark = fmt.Sprintf("the request %s has expired", correlationId)
+			return response, nil
+		}
+	}
+
+	channelInfo := rmp.findRequesterChannel(replyToClient)
+	if channelInfo == nil {
+		logger.Warnf("reply message requester not found, correlationId=%s, replyToClient=%s", correlationId, replyToClient)
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = fmt.Sprintf("the requester %s is not online", replyToClient)
+		return response, nil
+	}
+
+	requestHeader.StoreHost = rmp.BrokerController.GetStoreHost()
+	requestHeader.StoreTimestamp = timeutil.CurrentTimeMillis()
+	pushResponse, err := rmp.BrokerController.Broker2Client.PushReplyMessage(channelInfo.Context, requestHeader, request.Body)
+	if err != nil {
+		logger.Errorf("push reply message to %s err: %s", replyToClient, err.Error())
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = err.Error()
+		return response, nil
+	}
+	if pushResponse == nil || pushResponse.Code != code.SUCCESS {
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = fmt.Sprintf("push reply message to %s failed", replyToClient)
+		if pushResponse != nil {
+			response.Remark = pushResponse.Remark
+		}
+		return response, nil
+	}
+
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// findRequesterChannel 请求方可能是Producer也可能是Consumer，依次查找
+// Author agent
+// Since 2026/10/19
+func (rmp *ReplyMessageProcessor) findRequesterChannel(clientId string) *client.ChannelInfo {
+	if channelInfo := rmp.BrokerController.ProducerManager.FindChannel(clientId); channelInfo != nil {
+		return channelInfo
+	}
+	return rmp.BrokerController.ConsumerManager.FindChannelByClientId(clientId)
+}
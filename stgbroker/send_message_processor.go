@@ -10,11 +10,15 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
 	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
 	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgstorelog"
 	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
+	"strconv"
+	"time"
 )
 
 // SendMessageProcessor 处理客户端发送消息的请求
@@ -40,6 +44,10 @@ func (smp *SendMessageProcessor) ProcessRequest(ctx netm.Context, request *proto
 		return smp.ConsumerSendMsgBack(ctx, request), nil
 	}
 
+	// 记录正在处理的发送请求，供下线(drain)时判断生产者是否已经迁移走
+	smp.BrokerController.DrainService.BeginSend()
+	defer smp.BrokerController.DrainService.EndSend()
+
 	requestHeader := smp.abstractSendMessageProcessor.parseRequestHeader(request)
 	if requestHeader == nil {
 		return nil, nil
@@ -72,7 +80,7 @@ func (smp *SendMessageProcessor) ConsumerSendMsgBack(conn netm.Context,
 		context.Topic = requestHeader.OriginTopic
 		context.ClientHost = conn.RemoteAddr().String()
 		context.Success = false
-		context.Status = string(listener.RECONSUME_LATER)
+		context.Status = listener.RECONSUME_LATER.String()
 		messageIds := make(map[string]int64)
 		messageIds[requestHeader.OriginMsgId] = requestHeader.Offset
 		context.MessageIds = messageIds
@@ -166,18 +174,34 @@ func (smp *SendMessageProcessor) ConsumerSendMsgBack(conn netm.Context,
 			}
 		}
 
-		topicConfig, err = smp.BrokerController.TopicConfigManager.CreateTopicInSendMessageBackMethod(newTopic, DLQ_NUMS_PER_GROUP, constant.PERM_WRITE, 0)
+		// 死信Topic需要可读，才能被查询、重新投递或者被运维订阅
+		dlqPerm := constant.PERM_WRITE | constant.PERM_READ
+		topicConfig, err = smp.BrokerController.TopicConfigManager.CreateTopicInSendMessageBackMethod(newTopic, DLQ_NUMS_PER_GROUP, dlqPerm, 0)
 		if nil == topicConfig {
 			response.Code = code.SYSTEM_ERROR
 			response.Remark = fmt.Sprintf("topic[%s] not exist", newTopic)
 			return response
 		}
-	} else {
-		if 0 == delayLevel {
-			delayLevel = 3 + msgExt.ReconsumeTimes
+		if !constant.IsReadable(topicConfig.Perm) {
+			// 兼容旧版本创建的只写死信Topic
+			dlqTopicConfig := *topicConfig
+			dlqTopicConfig.Perm |= constant.PERM_READ
+			smp.BrokerController.TopicConfigManager.UpdateTopicConfig(&dlqTopicConfig)
+			smp.BrokerController.RegisterBrokerAll(false, true)
 		}
+	} else {
+		msgExt.ClearProperty(message.PROPERTY_START_DELIVER_TIME)
+		if retryPolicy := subscriptionGroupConfig.RetryPolicy; 0 == delayLevel && retryPolicy != nil {
+			// 按订阅组的重试策略计算下一次投递时间，不受服务器延时级别的限制
+			msgExt.ClearProperty(message.PROPERTY_DELAY_TIME_LEVEL)
+			msgExt.SetStartDeliverTime(timeutil.CurrentTimeMillis() + retryPolicy.NextDelay(msgExt.ReconsumeTimes))
+		} else {
+			if 0 == delayLevel {
+				delayLevel = 3 + msgExt.ReconsumeTimes
+			}
 
-		msgExt.SetDelayTimeLevel(int(delayLevel))
+			msgExt.SetDelayTimeLevel(int(delayLevel))
+		}
 	}
 
 	msgInner := new(stgstorelog.MessageExtBrokerInner)
@@ -236,6 +260,7 @@ func (smp *SendMessageProcessor) ConsumerSendMsgBack(conn netm.Context,
 // Since 2017/8/17
 func (smp *SendMessageProcessor) SendMessage(ctx netm.Context, request *protocol.RemotingCommand,
 	mqtraceContext *mqtrace.SendMessageContext, requestHeader *header.SendMessageRequestHeader) *protocol.RemotingCommand {
+	beginTime := time.Now()
 	responseHeader := new(header.SendMessageResponseHeader)
 	response := protocol.CreateDefaultResponseCommand(responseHeader)
 	response.Opaque = request.Opaque
@@ -247,6 +272,15 @@ func (smp *SendMessageProcessor) SendMessage(ctx netm.Context, request *protocol
 
 	body := request.Body
 
+	// 收发配额
+	if wait, ok := smp.BrokerController.QuotaManager.AcquireSend(ctx, requestHeader.Topic, requestHeader.ProducerGroup, 1, int64(len(body))); !ok {
+		response.Code = code.QUOTA_EXCEEDED
+		response.Remark = fmt.Sprintf("the broker[%s] send quota exceeded, topic: %s, producerGroup: %s",
+			smp.BrokerController.BrokerConfig.BrokerIP1, requestHeader.Topic, requestHeader.ProducerGroup)
+		response.ExtFields[quota.RETRY_AFTER_MILLIS] = strconv.FormatInt(int64(wait/time.Millisecond), 10)
+		return response
+	}
+
 	queueIdInt := requestHeader.QueueId
 
 	topicConfig := smp.BrokerController.TopicConfigManager.SelectTopicConfig(requestHeader.Topic)
@@ -330,6 +364,7 @@ func (smp *SendMessageProcessor) SendMessage(ctx netm.Context, request *protocol
 			smp.BrokerController.brokerStatsManager.IncTopicPutNums(msgInner.Topic)
 			smp.BrokerController.brokerStatsManager.IncTopicPutSize(msgInner.Topic, putMessageResult.AppendMessageResult.WroteBytes)
 			smp.BrokerController.brokerStatsManager.IncBrokerPutNums()
+			smp.BrokerController.brokerStatsManager.RecordTopicPutLatency(msgInner.Topic, int64(time.Since(beginTime)/time.Microsecond))
 
 			response.Remark = ""
 			responseHeader.MsgId = putMessageResult.AppendMessageResult.MsgId
@@ -341,6 +376,7 @@ func (smp *SendMessageProcessor) SendMessage(ctx netm.Context, request *protocol
 				smp.BrokerController.PullRequestHoldService.notifyMessageArriving(
 					requestHeader.Topic, queueIdInt, putMessageResult.AppendMessageResult.LogicsOffset+1)
 			}
+			smp.BrokerController.PullRequestHoldService.NotifyPopMessageArriving(requestHeader.Topic)
 
 			// 消息轨迹：记录发送成功的消息
 			if smp.HasSendMessageHook() {
@@ -28,6 +28,7 @@ func (self *SlaveSynchronize) syncAll() {
 	self.syncConsumerOffset()
 	self.syncDelayOffset()
 	self.syncSubscriptionGroupConfig()
+	self.syncQuotaConfig()
 }
 
 // syncTopicConfig 同步Topic信息
@@ -66,8 +67,7 @@ func (slave *SlaveSynchronize) syncConsumerOffset() {
 		return
 	}
 
-	slave.BrokerController.ConsumerOffsetManager.Offsets.PutAll(offsetWrapper.OffsetTable)
-	slave.BrokerController.ConsumerOffsetManager.configManagerExt.Persist()
+	slave.BrokerController.ConsumerOffsetManager.PutAll(offsetWrapper.OffsetTable)
 	buf := offsetWrapper.CustomEncode(offsetWrapper)
 	logger.Infof("update slave consumer offset from master. masterAddr=%s, offsetTable=%s", slave.masterAddr, string(buf))
 }
@@ -117,3 +117,24 @@ func (self *SlaveSynchronize) syncSubscriptionGroupConfig() {
 		logger.Infof("update slave subscription group from master, %s", self.masterAddr)
 	}
 }
+
+// syncQuotaConfig 同步收发配额
+// Author agent
+// Since 2026/10/19
+func (self *SlaveSynchronize) syncQuotaConfig() {
+	if self.masterAddr == "" {
+		return
+	}
+	quotaConfigTable := self.BrokerController.BrokerOuterAPI.GetAllQuotaConfig(self.masterAddr)
+	if quotaConfigTable == nil {
+		return
+	}
+
+	quotaManager := self.BrokerController.QuotaManager
+	slaveDataVersion := quotaManager.QuotaConfigTable.DataVersion
+	if slaveDataVersion.Timestamp != quotaConfigTable.DataVersion.Timestamp ||
+		slaveDataVersion.Counter != quotaConfigTable.DataVersion.Counter {
+		quotaManager.ReplaceAll(quotaConfigTable)
+		logger.Infof("update slave quota config from master, %s, %s", self.masterAddr, quotaManager.Encode(false))
+	}
+}
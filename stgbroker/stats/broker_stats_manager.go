@@ -5,6 +5,8 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/stats"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"strconv"
+	"strings"
 	"sync/atomic"
 )
 
@@ -19,12 +21,20 @@ const (
 	GROUP_GET_FALL  = "GROUP_GET_FALL"
 )
 
+// 延迟分布统计维度，定义见stgcommon/stats，值的单位均为微秒
+const (
+	TOPIC_PUT_LATENCY     = stats.TOPIC_PUT_LATENCY
+	GROUP_GET_LATENCY     = stats.GROUP_GET_LATENCY
+	COMMITLOG_PUT_LATENCY = stats.COMMITLOG_PUT_LATENCY
+)
+
 // BrokerStatsManager broker统计
 // Author gaoyanlei
 // Since 2017/8/18
 type BrokerStatsManager struct {
 	clusterName        string
-	statsTable         map[string]*stats.StatsItemSet // key: 统计维度，如TOPIC_PUT_SIZE等
+	statsTable         map[string]*stats.StatsItemSet     // key: 统计维度，如TOPIC_PUT_SIZE等
+	histogramTable     map[string]*stats.HistogramItemSet // key: 延迟统计维度，如TOPIC_PUT_LATENCY等
 	momentStatsItemSet *stats.MomentStatsItemSet
 }
 
@@ -45,6 +55,11 @@ func NewBrokerStatsManager(clusterName string) *BrokerStatsManager {
 	bs.statsTable[BROKER_PUT_NUMS] = stats.NewStatsItemSet(BROKER_PUT_NUMS)
 	bs.statsTable[BROKER_GET_NUMS] = stats.NewStatsItemSet(BROKER_GET_NUMS)
 
+	bs.histogramTable = make(map[string]*stats.HistogramItemSet)
+	bs.histogramTable[TOPIC_PUT_LATENCY] = stats.NewHistogramItemSet(TOPIC_PUT_LATENCY)
+	bs.histogramTable[GROUP_GET_LATENCY] = stats.NewHistogramItemSet(GROUP_GET_LATENCY)
+	bs.histogramTable[COMMITLOG_PUT_LATENCY] = stats.NewHistogramItemSet(COMMITLOG_PUT_LATENCY)
+
 	return bs
 }
 
@@ -58,6 +73,10 @@ func (bsm *BrokerStatsManager) Start() {
 		statsItemSet.StatsItemTickers.Start()
 	}
 
+	for _, histogramItemSet := range bsm.histogramTable {
+		histogramItemSet.HistogramItemTickers.Start()
+	}
+
 	logger.Info("BrokerStatsManager start successful")
 }
 
@@ -75,6 +94,10 @@ func (bsm *BrokerStatsManager) Shutdown() {
 		statsItemSet.StatsItemTickers.Close()
 	}
 
+	for _, histogramItemSet := range bsm.histogramTable {
+		histogramItemSet.HistogramItemTickers.Close()
+	}
+
 	logger.Info("BrokerStatsManager shutdown successful")
 }
 
@@ -88,6 +111,42 @@ func (bsm *BrokerStatsManager) GetStatsItem(statsName, statsKey string) *stats.S
 	return nil
 }
 
+// GetHistogramItem  根据statsName、statsKey获得延迟分布统计数据
+// Author agent
+// Since 2026/10/19
+func (bsm *BrokerStatsManager) GetHistogramItem(statsName, statsKey string) *stats.HistogramItem {
+	if histogramItemSet, ok := bsm.histogramTable[statsName]; ok && histogramItemSet != nil {
+		return histogramItemSet.GetHistogramItem(statsKey)
+	}
+	return nil
+}
+
+// ForeachStatsItem  遍历statsName维度下所有统计单元的累计值
+// Author agent
+// Since 2026/10/19
+func (bsm *BrokerStatsManager) ForeachStatsItem(statsName string, fn func(statsKey string, value, times int64)) {
+	if statItemSet, ok := bsm.statsTable[statsName]; ok && statItemSet != nil {
+		statItemSet.Foreach(fn)
+	}
+}
+
+// ForeachDiskFallBehind  遍历所有 QueueId@Topic@Group 的offset落后数量
+// Author agent
+// Since 2026/10/19
+func (bsm *BrokerStatsManager) ForeachDiskFallBehind(fn func(group, topic string, queueId int32, fallBehind int64)) {
+	bsm.momentStatsItemSet.Foreach(func(statsKey string, value int64) {
+		items := strings.SplitN(statsKey, "@", 3)
+		if len(items) != 3 {
+			return
+		}
+		queueId, err := strconv.Atoi(items[0])
+		if err != nil {
+			return
+		}
+		fn(items[2], items[1], int32(queueId), value)
+	})
+}
+
 // IncTopicPutNums  Topic Put次数加1
 // Author rongzhihong
 // Since 2017/9/17
@@ -153,3 +212,24 @@ func (bsm *BrokerStatsManager) RecordDiskFallBehind(group, topic string, queueId
 	statsKey := fmt.Sprintf("%d@%s@%s", queueId, topic, group)
 	atomic.StoreInt64(&(bsm.momentStatsItemSet.GetAndCreateStatsItem(statsKey).ValueCounter), fallBehind)
 }
+
+// RecordTopicPutLatency  记录Topic发送消息的耗时(微秒)
+// Author agent
+// Since 2026/10/19
+func (bsm *BrokerStatsManager) RecordTopicPutLatency(topic string, latencyMicros int64) {
+	bsm.histogramTable[TOPIC_PUT_LATENCY].Record(topic, latencyMicros)
+}
+
+// RecordGroupGetLatency  记录 Topic@Group 拉取消息的耗时(微秒)
+// Author agent
+// Since 2026/10/19
+func (bsm *BrokerStatsManager) RecordGroupGetLatency(group, topic string, latencyMicros int64) {
+	bsm.histogramTable[GROUP_GET_LATENCY].Record(topic+"@"+group, latencyMicros)
+}
+
+// RecordCommitLogPutLatency  记录Topic写入CommitLog的耗时(微秒)
+// Author agent
+// Since 2026/10/19
+func (bsm *BrokerStatsManager) RecordCommitLogPutLatency(topic string, latencyMicros int64) {
+	bsm.histogramTable[COMMITLOG_PUT_LATENCY].Record(topic, latencyMicros)
+}
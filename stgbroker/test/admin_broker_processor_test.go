@@ -12,7 +12,12 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
 	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -114,22 +119,113 @@ func TestUpdateBrokerConfig(t *testing.T) {
 	request := protocol.CreateRequestCommand(code.UPDATE_BROKER_CONFIG)
 	request.Body = stgcommon.Encode(allConfig)
 
-	_, err := adminProcessor.ProcessRequest(ctx, request)
+	response, err := adminProcessor.ProcessRequest(ctx, request)
 	if err != nil {
 		t.Error(err)
 	}
+	if response.Code != code.SUCCESS {
+		t.Fatalf("更新Broker配置失败, %s", response.Remark)
+	}
+
+	result := body.NewUpdateBrokerConfigResult()
+	result.CustomDecode(response.Body, result)
 
 	t.Logf(" brokerName old:%s, new:%s; accessMessageInMemoryMaxRatio old:%d, new:%d", brokerName, bc.BrokerConfig.BrokerName, accessMessageInMemoryMaxRatio, bc.MessageStoreConfig.AccessMessageInMemoryMaxRatio)
 
 	if bc.MessageStoreConfig.AccessMessageInMemoryMaxRatio == accessMessageInMemoryMaxRatio {
-		t.Fail()
 		t.Errorf("更新MessageStoreConfig.AccessMessageInMemoryMaxRatio失败, old: %d, new:%d", accessMessageInMemoryMaxRatio, bc.MessageStoreConfig.AccessMessageInMemoryMaxRatio)
 		return
 	}
 
-	if bc.BrokerConfig.BrokerName == brokerName { // 40 == 50
-		t.Fail()
-		t.Errorf("更新BrokerConfig.BrokerName失败, old: %d, new:%d", brokerName, bc.BrokerConfig.BrokerName)
+	// BrokerName需要重启broker才能生效，内存中保持不变
+	if bc.BrokerConfig.BrokerName != brokerName {
+		t.Errorf("BrokerConfig.BrokerName不应该热更新, old: %s, new:%s", brokerName, bc.BrokerConfig.BrokerName)
+	}
+
+	if len(result.Changes) != 2 || !result.RestartRequired() {
+		t.Errorf("更新Broker配置结果错误, changes: %d, restartRequired: %t", len(result.Changes), result.RestartRequired())
+	}
+}
+
+func TestUpdateBrokerConfigInvalid(t *testing.T) {
+	bc := InitBrokerController()
+	ctx := common.CreateAdminCtx()
+
+	ratio := bc.MessageStoreConfig.AccessMessageInMemoryMaxRatio
+
+	adminProcessor := stgbroker.NewAdminBrokerProcessor(bc)
+	request := protocol.CreateRequestCommand(code.UPDATE_BROKER_CONFIG)
+	request.Body = []byte(`{"AccessMessageInMemoryMaxRatio":150,"longPollingEnable":false}`)
+
+	response, err := adminProcessor.ProcessRequest(ctx, request)
+	if err != nil {
+		t.Error(err)
+	}
+	if response.Code == code.SUCCESS {
+		t.Errorf("非法配置不应该更新成功")
+	}
+	if bc.MessageStoreConfig.AccessMessageInMemoryMaxRatio != ratio || !bc.BrokerConfig.LongPollingEnable {
+		t.Errorf("非法配置不应该修改任何配置项")
+	}
+}
+
+func TestUpdateBrokerConfigKeepTomlComments(t *testing.T) {
+	bc := InitBrokerController()
+
+	dir, err := ioutil.TempDir("", "broker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lines := []string{
+		"# 集群名称",
+		"brokerClusterName = \"DefaultCluster\"",
+		"brokerName = \"broker-a\"  # 修改后重启生效",
+		"#metricsServerEnable=true",
+		"deleteWhen = 4",
+		"",
+	}
+	bc.ConfigFile = filepath.Join(dir, "broker-a.toml")
+	if err := ioutil.WriteFile(bc.ConfigFile, []byte(strings.Join(lines, "\r\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	allConfig := stgbroker.NewBrokerAllConfig()
+	stgcommon.Decode([]byte(bc.EncodeAllConfig()), allConfig)
+	allConfig.BrokerConfig.BrokerName = "UpdateBrokerName"
+	allConfig.MessageStoreConfig.AccessMessageInMemoryMaxRatio = 50
+
+	if _, err := bc.UpdateAllConfig(stgcommon.Encode(allConfig)); err != nil {
+		t.Fatalf("更新Broker配置失败, %s", err)
+	}
+
+	content, err := ioutil.ReadFile(bc.ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newLines := strings.Split(string(content), "\r\n")
+	if len(newLines) < len(lines) {
+		t.Fatalf("配置文件行数错误:\n%s", content)
+	}
+
+	// 注释及未修改的配置项原样保留，修改的配置项在原位置替换，新增的配置项写入[messageStoreConfig]段
+	lines[2] = "brokerName = \"UpdateBrokerName\"  # 修改后重启生效"
+	for i, line := range lines[:len(lines)-1] {
+		if newLines[i] != line {
+			t.Errorf("配置文件第%d行错误, expect: %s, actual: %s", i+1, line, newLines[i])
+		}
+	}
+
+	fileValues := struct {
+		BrokerName         string           `toml:"brokerName"`
+		MessageStoreConfig map[string]int64 `toml:"messageStoreConfig"`
+	}{}
+	if _, err := toml.Decode(string(content), &fileValues); err != nil {
+		t.Fatalf("解析配置文件失败, %s:\n%s", err, content)
+	}
+	if fileValues.BrokerName != "UpdateBrokerName" || fileValues.MessageStoreConfig["AccessMessageInMemoryMaxRatio"] != 50 {
+		t.Errorf("配置文件内容错误:\n%s", content)
 	}
 }
 
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"encoding/json"
+	"git.oschina.net/cloudzone/smartgo/stgbroker"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newDebugServer(t *testing.T) *stgbroker.BrokerDebugServer {
+	brokerConfig := stgcommon.NewDefaultBrokerConfig()
+	brokerConfig.DebugServerAddr = "127.0.0.1:0"
+	controller := stgbroker.NewBrokerController(brokerConfig, stgstorelog.NewMessageStoreConfig(), remoting.NewDefalutRemotingClient())
+
+	debugServer := stgbroker.NewBrokerDebugServer(controller)
+	if err := debugServer.Start(); err != nil {
+		t.Fatalf("start debug server err: %s", err.Error())
+	}
+	return debugServer
+}
+
+func TestBrokerDebugServerViews(t *testing.T) {
+	debugServer := newDebugServer(t)
+	defer debugServer.Shutdown()
+	baseUrl := "http://" + debugServer.Addr()
+
+	resp, err := http.Get(baseUrl + "/broker/consumers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("consumers status %d", resp.StatusCode)
+	}
+	views := make(map[string]interface{})
+	if err := json.NewDecoder(resp.Body).Decode(&views); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未初始化MessageStore时存储视图不可用
+	resp, err = http.Get(baseUrl + "/store/checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("checkpoint status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(baseUrl + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("pprof status %d", resp.StatusCode)
+	}
+}
+
+func TestBrokerDebugServerReadOnly(t *testing.T) {
+	debugServer := newDebugServer(t)
+	defer debugServer.Shutdown()
+
+	resp, err := http.Post("http://"+debugServer.Addr()+"/broker/holds", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("post status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker"
+	"git.oschina.net/cloudzone/smartgo/stgbroker/longpolling"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+)
+
+// testContext 记录写入的应答，只实现处理请求时用到的方法
+type testContext struct {
+	netm.Context
+	lock      sync.Mutex
+	responses []*protocol.RemotingCommand
+}
+
+func newTestContext() *testContext {
+	return &testContext{}
+}
+
+func (ctx *testContext) IsClosed() bool {
+	return false
+}
+
+func (ctx *testContext) WriteSerialObject(s netm.Serializable) (int, error) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	if response, ok := s.(*protocol.RemotingCommand); ok {
+		ctx.responses = append(ctx.responses, response)
+	}
+	return 0, nil
+}
+
+func (ctx *testContext) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10911}
+}
+
+func (ctx *testContext) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000}
+}
+
+func (ctx *testContext) Responses() []*protocol.RemotingCommand {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	return append([]*protocol.RemotingCommand(nil), ctx.responses...)
+}
+
+// suspendPullRequest Hold住一个拉消息请求
+func suspendPullRequest(controller *stgbroker.BrokerController, ctx netm.Context, topic string, queueId int32) *protocol.RemotingCommand {
+	requestHeader := &header.PullMessageRequestHeader{
+		ConsumerGroup:        "DrainConsumerGroup",
+		Topic:                topic,
+		QueueId:              queueId,
+		MaxMsgNums:           32,
+		SuspendTimeoutMillis: 30000,
+		Subscription:         "*",
+	}
+	request := protocol.CreateRequestCommand(11, requestHeader)
+	request.MakeCustomHeaderToNet()
+	pullRequest := longpolling.NewPullRequest(request, ctx, 30000, timeutil.CurrentTimeMillis(), 0)
+	controller.PullRequestHoldService.SuspendPullRequest(topic, queueId, pullRequest)
+	return request
+}
+
+func TestPullRequestHoldServiceWakeupAll(t *testing.T) {
+	controller, cleanup := newTestBrokerController(t)
+	defer cleanup()
+
+	if count, finished := controller.PullRequestHoldService.WakeupAll(1000); count != 0 || !finished {
+		t.Fatalf("unexpected wakeup result %d %t", count, finished)
+	}
+
+	ctx := newTestContext()
+	requests := []*protocol.RemotingCommand{
+		suspendPullRequest(controller, ctx, "DrainTopic", 0),
+		suspendPullRequest(controller, ctx, "DrainTopic", 1),
+		suspendPullRequest(controller, ctx, "DrainTopic", 1),
+	}
+	if views := controller.PullRequestHoldService.HoldRequestViews(); len(views) != 3 {
+		t.Fatalf("unexpected hold requests %v", views)
+	}
+
+	// 全部被Hold住的请求都得到响应，响应后不再被Hold
+	if count, finished := controller.PullRequestHoldService.WakeupAll(5000); count != 3 || !finished {
+		t.Fatalf("unexpected wakeup result %d %t", count, finished)
+	}
+	responses := ctx.Responses()
+	if len(responses) != len(requests) {
+		t.Fatalf("expect %d responses, got %d", len(requests), len(responses))
+	}
+	opaques := make(map[int32]bool)
+	for _, response := range responses {
+		opaques[response.Opaque] = true
+	}
+	for _, request := range requests {
+		if !opaques[request.Opaque] {
+			t.Fatalf("request %d not responded", request.Opaque)
+		}
+	}
+	if views := controller.PullRequestHoldService.HoldRequestViews(); len(views) != 0 {
+		t.Fatalf("unexpected hold requests after wakeup %v", views)
+	}
+	if count, _ := controller.PullRequestHoldService.WakeupAll(1000); count != 0 {
+		t.Fatalf("unexpected wakeup count %d", count)
+	}
+}
+
+func TestBrokerDrainService(t *testing.T) {
+	controller, cleanup := newTestBrokerController(t)
+	defer cleanup()
+	controller.BrokerConfig.DrainProducerQuietMills = 200
+	controller.BrokerConfig.DrainProducerTimeoutMills = 5000
+	controller.Start()
+
+	ctx := newTestContext()
+	suspendPullRequest(controller, ctx, "DrainTopic", 0)
+
+	// 下线时等待正在处理的发送请求结束
+	drainService := controller.DrainService
+	drainService.BeginSend()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		drainService.EndSend()
+	}()
+
+	if drainService.Report() != nil {
+		t.Fatal("report before drain")
+	}
+	if err := drainService.StartDrain("test"); err != nil {
+		t.Fatal(err)
+	}
+	if !drainService.IsDraining() {
+		t.Fatal("broker not draining")
+	}
+	if err := drainService.StartDrain("test"); err == nil {
+		t.Fatal("start drain twice")
+	}
+
+	select {
+	case <-drainService.Done():
+	case <-time.After(2 * time.Minute):
+		t.Fatal("wait drain timeout")
+	}
+	report := drainService.Report()
+	if report == nil || len(report.Steps) != 7 {
+		t.Fatalf("unexpected drain report %+v", report)
+	}
+	for _, step := range report.Steps {
+		if !step.Success {
+			t.Fatalf("drain step %s failed: %s", step.Name, step.Detail)
+		}
+	}
+	if !report.Success || report.Trigger != "test" || report.InflightSends != 0 || report.WakeupPullRequests != 1 {
+		t.Fatalf("unexpected drain report %+v", report)
+	}
+	if report.EndTimestamp-report.LastSendTimestamp < 0 || report.LastSendTimestamp-report.BeginTimestamp < 200 {
+		t.Fatalf("drain not wait producers, begin=%d, lastSend=%d", report.BeginTimestamp, report.LastSendTimestamp)
+	}
+	if len(ctx.Responses()) != 1 {
+		t.Fatalf("hold pull request not responded")
+	}
+	if _, err := os.Stat(stgbroker.GetDrainReportPath(controller.BrokerConfig.StorePathRootDir)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已经下线完成时同步下线直接返回报告
+	if drainService.Drain("test") != report {
+		t.Fatal("unexpected report of drain after done")
+	}
+}
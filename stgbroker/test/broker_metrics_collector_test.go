@@ -0,0 +1,84 @@
+package test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/metrics"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+)
+
+const (
+	metricsTopic = "MetricsTopic"
+	metricsGroup = "MetricsGroup"
+)
+
+// findSample 查找指标中labels全部匹配的样本
+func findSample(families []*metrics.Family, name string, labels ...string) (*metrics.Sample, bool) {
+	for _, family := range families {
+		if family.Name != name {
+			continue
+		}
+		for _, sample := range family.Samples {
+			if matchLabels(sample.Labels, labels) {
+				return sample, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func matchLabels(sampleLabels, labels []string) bool {
+	for i := 0; i+1 < len(labels); i += 2 {
+		matched := false
+		for j := 0; j+1 < len(sampleLabels); j += 2 {
+			if sampleLabels[j] == labels[i] && sampleLabels[j+1] == labels[i+1] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBrokerMetricsDiskFallBehind(t *testing.T) {
+	controller, shutdown := newStoreBrokerController(t)
+	defer shutdown()
+
+	controller.TopicConfigManager.UpdateTopicConfig(stgcommon.NewDefaultTopicConfig(metricsTopic, 1, 1, constant.PERM_READ|constant.PERM_WRITE, stgcommon.SINGLE_TAG))
+	for i := 0; i < 3; i++ {
+		msgInner := new(stgstorelog.MessageExtBrokerInner)
+		msgInner.Topic = metricsTopic
+		msgInner.Body = []byte("metrics message " + strconv.Itoa(i))
+		msgInner.BornTimestamp = time.Now().UnixNano() / int64(time.Millisecond)
+		msgInner.BornHost = "127.0.0.1:10000"
+		msgInner.StoreHost = controller.StoreHost
+		if result := controller.MessageStore.PutMessage(msgInner); result == nil || result.PutMessageStatus != stgstorelog.PUTMESSAGE_PUT_OK {
+			t.Fatalf("put message failed: %v", result)
+		}
+	}
+	waitOffset(t, controller, metricsTopic, 3)
+
+	// 从第一条消息开始拉取，落后字节数为逻辑队列最大物理偏移量减去第一条消息的物理偏移量
+	result := controller.MessageStore.GetMessage(metricsGroup, metricsTopic, 0, 0, 32, nil)
+	if result == nil || result.Status != stgstorelog.FOUND || result.GetMessageCount() != 3 {
+		t.Fatalf("unexpected get message result %v", result)
+	}
+	result.Release()
+
+	families := stgbroker.NewBrokerMetricsCollector(controller).Collect()
+	sample, ok := findSample(families, "smartgo_broker_group_disk_fall_behind_bytes", "topic", metricsTopic, "group", metricsGroup, "queue_id", "0")
+	if !ok {
+		t.Fatal("disk fall behind gauge not collected")
+	}
+	if sample.Value <= 0 {
+		t.Fatalf("unexpected disk fall behind %v", sample.Value)
+	}
+}
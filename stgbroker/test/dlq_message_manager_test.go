@@ -0,0 +1,181 @@
+package test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+)
+
+const dlqOriginTopic = "DLQOriginTopic"
+
+// newTestBrokerController 在临时目录中初始化broker，返回清理函数
+func newTestBrokerController(t *testing.T) (*stgbroker.BrokerController, func()) {
+	dir, err := ioutil.TempDir("", "smartgo-broker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+
+	brokerConfig := stgcommon.NewBrokerConfig("BrokerName", "BrokerClusterName")
+	brokerConfig.StorePathRootDir = dir
+	brokerConfig.BrokerPort = freePort(t) - 1 // HA端口为broker端口+1
+	messageStoreConfig := stgstorelog.NewMessageStoreConfig()
+	messageStoreConfig.MapedFileSizeCommitLog = 1024 * 1024 * 4
+	controller := stgbroker.NewBrokerController(brokerConfig, messageStoreConfig, remoting.NewDefalutRemotingClient())
+	if !controller.Initialize() {
+		t.Fatal("initialize broker controller failed")
+	}
+	return controller, func() {
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+// newStoreBrokerController 在临时目录中初始化broker并启动存储，返回关闭函数
+func newStoreBrokerController(t *testing.T) (*stgbroker.BrokerController, func()) {
+	controller, cleanup := newTestBrokerController(t)
+	if err := controller.MessageStore.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	controller.TopicConfigManager.UpdateTopicConfig(stgcommon.NewDefaultTopicConfig(dlqOriginTopic, 1, 1, constant.PERM_READ|constant.PERM_WRITE, stgcommon.SINGLE_TAG))
+	return controller, func() {
+		controller.MessageStore.Shutdown()
+		cleanup()
+	}
+}
+
+// freePort 获取空闲端口，同一进程中的多个broker不能共用HA端口
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// putDLQMessages 向消费组的死信队列写入消息，originTopics为各消息的原始Topic
+func putDLQMessages(t *testing.T, controller *stgbroker.BrokerController, group string, originTopics ...string) {
+	dlqTopic := stgcommon.GetDLQTopic(group)
+	controller.TopicConfigManager.UpdateTopicConfig(stgcommon.NewDefaultTopicConfig(dlqTopic, 1, 1, constant.PERM_READ|constant.PERM_WRITE, stgcommon.SINGLE_TAG))
+
+	base := controller.MessageStore.GetMaxOffsetInQueue(dlqTopic, 0)
+	for _, originTopic := range originTopics {
+		msgInner := new(stgstorelog.MessageExtBrokerInner)
+		msgInner.Topic = dlqTopic
+		msgInner.Body = []byte("dlq message of " + originTopic)
+		properties := map[string]string{message.PROPERTY_RETRY_TOPIC: originTopic}
+		message.SetPropertiesMap(&msgInner.Message, properties)
+		msgInner.PropertiesString = message.MessageProperties2String(properties)
+		msgInner.BornTimestamp = time.Now().UnixNano() / int64(time.Millisecond)
+		msgInner.BornHost = "127.0.0.1:10000"
+		msgInner.StoreHost = controller.StoreHost
+		msgInner.ReconsumeTimes = 16
+		result := controller.MessageStore.PutMessage(msgInner)
+		if result == nil || result.PutMessageStatus != stgstorelog.PUTMESSAGE_PUT_OK {
+			t.Fatalf("put dlq message failed: %v", result)
+		}
+	}
+	waitOffset(t, controller, dlqTopic, base+int64(len(originTopics)))
+}
+
+// waitOffset 等待消息分发到逻辑队列
+func waitOffset(t *testing.T, controller *stgbroker.BrokerController, topic string, offset int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for controller.MessageStore.GetMaxOffsetInQueue(topic, 0) < offset {
+		if time.Now().After(deadline) {
+			t.Fatalf("wait %s offset %d timeout", topic, offset)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDLQMessageManager(t *testing.T) {
+	controller, shutdown := newStoreBrokerController(t)
+	defer shutdown()
+	manager := controller.DLQMessageManager
+
+	t.Run("query", func(t *testing.T) {
+		putDLQMessages(t, controller, "DLQQueryGroup", dlqOriginTopic, dlqOriginTopic, dlqOriginTopic, dlqOriginTopic, dlqOriginTopic)
+
+		messageList := manager.QueryMessages("DLQQueryGroup", 0, 0, 10)
+		if len(messageList.Messages) != 5 || len(messageList.QueueInfos) != 1 || messageList.QueueInfos[0].MaxOffset != 5 {
+			t.Fatalf("unexpected message list %d %v", len(messageList.Messages), messageList.QueueInfos)
+		}
+		if msg := messageList.Messages[0]; msg.OriginTopic != dlqOriginTopic || msg.MsgId == "" || msg.ReconsumeTimes != 16 {
+			t.Fatalf("unexpected dlq message %+v", msg)
+		}
+
+		messageList = manager.QueryMessages("DLQQueryGroup", 0, 3, 1)
+		if len(messageList.Messages) != 1 || messageList.Messages[0].QueueOffset != 3 {
+			t.Fatalf("unexpected page %v", messageList.Messages)
+		}
+		if messageList = manager.QueryMessages("DLQQueryGroup", 1, 0, 10); len(messageList.Messages) != 0 {
+			t.Fatalf("unexpected messages in queue 1: %v", messageList.Messages)
+		}
+	})
+
+	t.Run("resend", func(t *testing.T) {
+		originOffset := controller.MessageStore.GetMaxOffsetInQueue(dlqOriginTopic, 0)
+		putDLQMessages(t, controller, "DLQResendGroup", dlqOriginTopic, dlqOriginTopic, "DLQNotExistTopic", dlqOriginTopic)
+
+		// 遇到失败的消息时停止投递该队列，成功的消息推进清除进度
+		result := manager.ResendMessages("DLQResendGroup", nil)
+		if result.Total != 3 || result.Succeed != 2 || len(result.FailedMsgIds) != 1 {
+			t.Fatalf("unexpected resend result %+v", result)
+		}
+		waitOffset(t, controller, dlqOriginTopic, originOffset+2)
+		messageList := manager.QueryMessages("DLQResendGroup", 0, 0, 10)
+		if messageList.QueueInfos[0].MinOffset != 2 || len(messageList.Messages) != 2 {
+			t.Fatalf("unexpected queue info after resend %v", messageList.QueueInfos)
+		}
+
+		// 再次全部投递不会重复投递已成功的消息
+		result = manager.ResendMessages("DLQResendGroup", nil)
+		if result.Total != 1 || result.Succeed != 0 {
+			t.Fatalf("unexpected resend result %+v", result)
+		}
+
+		// 按消息ID投递失败消息之后的消息
+		result = manager.ResendMessages("DLQResendGroup", []string{messageList.Messages[1].MsgId, "invalid"})
+		if result.Total != 2 || result.Succeed != 1 || result.FailedMsgIds["invalid"] == "" {
+			t.Fatalf("unexpected resend result %+v", result)
+		}
+		waitOffset(t, controller, dlqOriginTopic, originOffset+3)
+		if maxOffset := controller.MessageStore.GetMaxOffsetInQueue(dlqOriginTopic, 0); maxOffset != originOffset+3 {
+			t.Fatalf("origin topic offset %d, expect %d", maxOffset, originOffset+3)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		putDLQMessages(t, controller, "DLQPurgeGroup", dlqOriginTopic, dlqOriginTopic, dlqOriginTopic, dlqOriginTopic)
+
+		result := manager.Purge("DLQPurgeGroup", 2, -1)
+		if result.Purged != 2 || result.QueueInfos[0].MinOffset != 2 {
+			t.Fatalf("unexpected purge result %d %v", result.Purged, result.QueueInfos)
+		}
+		if messageList := manager.QueryMessages("DLQPurgeGroup", 0, 0, 10); len(messageList.Messages) != 2 || messageList.Messages[0].QueueOffset != 2 {
+			t.Fatalf("unexpected messages after purge %v", messageList.Messages)
+		}
+
+		// 按存储时间清除全部剩余消息，清除后全部投递没有消息
+		result = manager.Purge("DLQPurgeGroup", -1, time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond))
+		if result.Purged != 2 || result.QueueInfos[0].MinOffset != 4 {
+			t.Fatalf("unexpected purge result %d %v", result.Purged, result.QueueInfos)
+		}
+		if resendResult := manager.ResendMessages("DLQPurgeGroup", nil); resendResult.Total != 0 {
+			t.Fatalf("unexpected resend result %+v", resendResult)
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package test
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+)
+
+const (
+	popTopic = "PopTopic"
+	popGroup = "PopGroup"
+)
+
+func TestPopCheckpointManager(t *testing.T) {
+	controller, shutdown := newStoreBrokerController(t)
+	defer shutdown()
+	manager := controller.PopCheckpointManager
+
+	if offset := manager.PopOffset(popGroup, popTopic, 0); offset != 0 {
+		t.Fatalf("unexpected pop offset %d", offset)
+	}
+	for offset := int64(0); offset < 3; offset++ {
+		manager.AddInflight(popGroup, popTopic, 0, offset, 1000, 100)
+	}
+	manager.UpdatePopOffset(popGroup, popTopic, 0, 3)
+	if offset := controller.ConsumerOffsetManager.QueryOffset(popGroup, popTopic, 0); offset != 0 {
+		t.Fatalf("unexpected commit offset %d", offset)
+	}
+
+	// 不可见时间到期后按位点顺序返回
+	if expired := manager.ExpiredInflights(popGroup, popTopic, 0, 1099, 10); len(expired) != 0 {
+		t.Fatalf("unexpected expired inflights %v", expired)
+	}
+	if expired := manager.ExpiredInflights(popGroup, popTopic, 0, 1100, 2); len(expired) != 2 || expired[0].Offset != 0 || expired[1].Offset != 1 {
+		t.Fatalf("unexpected expired inflights %v", expired)
+	}
+
+	// popTime不一致的句柄无法确认，确认后提交最小未确认位点
+	if manager.Ack(popGroup, popTopic, 0, 0, 999) {
+		t.Fatal("ack with stale popTime")
+	}
+	if !manager.Ack(popGroup, popTopic, 0, 0, 1000) || manager.Ack(popGroup, popTopic, 0, 0, 1000) {
+		t.Fatal("ack offset 0 failed or acked twice")
+	}
+	if offset := controller.ConsumerOffsetManager.QueryOffset(popGroup, popTopic, 0); offset != 1 {
+		t.Fatalf("unexpected commit offset %d", offset)
+	}
+
+	// 重新投递后旧句柄失效
+	if inflight := manager.AddInflight(popGroup, popTopic, 0, 1, 2000, 100); inflight.DeliveryTimes != 2 {
+		t.Fatalf("unexpected inflight %+v", inflight)
+	}
+	if manager.Ack(popGroup, popTopic, 0, 1, 1000) {
+		t.Fatal("ack redelivered message with stale popTime")
+	}
+	if !manager.ChangeInvisibleTime(popGroup, popTopic, 0, 2, 1000, 3000, 500) || manager.ChangeInvisibleTime(popGroup, popTopic, 0, 2, 1000, 3000, 500) {
+		t.Fatal("change invisible time failed or stale handle accepted")
+	}
+	if expired := manager.ExpiredInflights(popGroup, popTopic, 0, 3499, 10); len(expired) != 1 || expired[0].Offset != 1 {
+		t.Fatalf("unexpected expired inflights %v", expired)
+	}
+	manager.RemoveInflight(popGroup, popTopic, 0, 2)
+	if !manager.Ack(popGroup, popTopic, 0, 1, 2000) {
+		t.Fatal("ack offset 1 failed")
+	}
+	if offset := controller.ConsumerOffsetManager.QueryOffset(popGroup, popTopic, 0); offset != 3 {
+		t.Fatalf("unexpected commit offset %d", offset)
+	}
+
+	// 进度没有变化时不重写文件
+	path := manager.ConfigFilePath()
+	manager.Persist()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(path)
+	manager.Persist()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("checkpoint persisted without changes, err: %v", err)
+	}
+	manager.AddInflight(popGroup, popTopic, 0, 3, 4000, 100)
+	manager.UpdatePopOffset(popGroup, popTopic, 0, 4)
+	manager.Persist()
+
+	reloaded := stgbroker.NewPopCheckpointManager(controller)
+	if !reloaded.Load() {
+		t.Fatal("load checkpoint failed")
+	}
+	if offset := reloaded.PopOffset(popGroup, popTopic, 0); offset != 4 {
+		t.Fatalf("unexpected reloaded pop offset %d", offset)
+	}
+	if expired := reloaded.ExpiredInflights(popGroup, popTopic, 0, 4100, 10); len(expired) != 1 || expired[0].Offset != 3 {
+		t.Fatalf("unexpected reloaded inflights %v", expired)
+	}
+}
+
+// newPopBrokerController 创建POP测试用的broker，超过retryMaxTimes次投递的消息转入死信队列
+func newPopBrokerController(t *testing.T, retryMaxTimes int32) (*stgbroker.BrokerController, func()) {
+	controller, shutdown := newStoreBrokerController(t)
+	controller.TopicConfigManager.UpdateTopicConfig(stgcommon.NewDefaultTopicConfig(popTopic, 1, 1, constant.PERM_READ|constant.PERM_WRITE, stgcommon.SINGLE_TAG))
+	groupConfig := subscription.NewSubscriptionGroupConfig()
+	groupConfig.GroupName = popGroup
+	groupConfig.RetryMaxTimes = retryMaxTimes
+	controller.SubscriptionGroupManager.UpdateSubscriptionGroupConfig(groupConfig)
+	return controller, shutdown
+}
+
+// putPopMessages 向popTopic写入count条消息
+func putPopMessages(t *testing.T, controller *stgbroker.BrokerController, count int) {
+	base := controller.MessageStore.GetMaxOffsetInQueue(popTopic, 0)
+	for i := 0; i < count; i++ {
+		msgInner := new(stgstorelog.MessageExtBrokerInner)
+		msgInner.Topic = popTopic
+		msgInner.Body = []byte("pop message " + strconv.Itoa(i))
+		msgInner.BornTimestamp = time.Now().UnixNano() / int64(time.Millisecond)
+		msgInner.BornHost = "127.0.0.1:10000"
+		msgInner.StoreHost = controller.StoreHost
+		if result := controller.MessageStore.PutMessage(msgInner); result == nil || result.PutMessageStatus != stgstorelog.PUTMESSAGE_PUT_OK {
+			t.Fatalf("put message failed: %v", result)
+		}
+	}
+	waitOffset(t, controller, popTopic, base+int64(count))
+}
+
+// newPopRequest 构造POP请求，pollTime为0时没有消息立即返回
+func newPopRequest(maxMsgNums int32, invisibleTime, pollTime int64) *protocol.RemotingCommand {
+	requestHeader := &header.PopMessageRequestHeader{
+		ConsumerGroup: popGroup,
+		Topic:         popTopic,
+		QueueId:       -1,
+		MaxMsgNums:    maxMsgNums,
+		InvisibleTime: invisibleTime,
+		PollTime:      pollTime,
+	}
+	request := protocol.CreateRequestCommand(code.POP_MESSAGE, requestHeader)
+	request.MakeCustomHeaderToNet()
+	return request
+}
+
+// popMessages 发送POP请求，返回应答码、popTime及消息
+func popMessages(t *testing.T, processor *stgbroker.PopMessageProcessor, maxMsgNums int32, invisibleTime int64) (int32, int64, []*message.MessageExt) {
+	response, err := processor.ProcessRequest(newTestContext(), newPopRequest(maxMsgNums, invisibleTime, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decodePopResponse(t, response)
+}
+
+// holdPopRequest 发送没有可投递消息、会被Hold住的POP请求，应答写入返回的testContext
+func holdPopRequest(t *testing.T, processor *stgbroker.PopMessageProcessor, invisibleTime, pollTime int64) *testContext {
+	ctx := newTestContext()
+	response, err := processor.ProcessRequest(ctx, newPopRequest(10, invisibleTime, pollTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != nil {
+		t.Fatalf("pop request not held, code %d", response.Code)
+	}
+	return ctx
+}
+
+// waitPopResponse 等待被Hold住的POP请求的应答
+func waitPopResponse(t *testing.T, ctx *testContext, timeout time.Duration) *protocol.RemotingCommand {
+	deadline := time.Now().Add(timeout)
+	for {
+		if responses := ctx.Responses(); len(responses) > 0 {
+			if len(responses) != 1 {
+				t.Fatalf("pop request answered %d times", len(responses))
+			}
+			return responses[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("wait pop response timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// decodePopResponse 解析POP应答，返回应答码、popTime及消息
+func decodePopResponse(t *testing.T, response *protocol.RemotingCommand) (int32, int64, []*message.MessageExt) {
+	if response.Code != code.SUCCESS {
+		return response.Code, 0, nil
+	}
+
+	response.MakeCustomHeaderToNet()
+	responseHeader := &header.PopMessageResponseHeader{}
+	if err := response.DecodeCommandCustomHeader(responseHeader); err != nil {
+		t.Fatal(err)
+	}
+	msgList, err := message.DecodesMessageExt(response.Body, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.Code, responseHeader.PopTime, msgList
+}
+
+// ackMessage 按ReceiptHandle确认消息，返回应答码
+func ackMessage(t *testing.T, processor *stgbroker.PopMessageProcessor, popTime, invisibleTime int64, msgExt *message.MessageExt) int32 {
+	handle := &message.ReceiptHandle{
+		PopTime:       popTime,
+		InvisibleTime: invisibleTime,
+		QueueId:       msgExt.QueueId,
+		Offset:        msgExt.QueueOffset,
+		BrokerName:    processor.BrokerController.BrokerConfig.BrokerName,
+		Topic:         popTopic,
+	}
+	requestHeader := &header.AckMessageRequestHeader{
+		ConsumerGroup: popGroup,
+		Topic:         popTopic,
+		QueueId:       msgExt.QueueId,
+		Offset:        msgExt.QueueOffset,
+		ExtraInfo:     handle.Encode(),
+	}
+	request := protocol.CreateRequestCommand(code.ACK_MESSAGE, requestHeader)
+	request.MakeCustomHeaderToNet()
+	response, err := processor.ProcessRequest(newTestContext(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.Code
+}
+
+func TestPopMessageProcessor(t *testing.T) {
+	controller, shutdown := newPopBrokerController(t, 1)
+	defer shutdown()
+
+	putPopMessages(t, controller, 3)
+	processor := stgbroker.NewPopMessageProcessor(controller)
+
+	const invisibleTime = 300
+	responseCode, popTime, msgList := popMessages(t, processor, 1, invisibleTime)
+	if responseCode != code.SUCCESS || len(msgList) != 1 || msgList[0].QueueOffset != 0 {
+		t.Fatalf("unexpected pop result %d %v", responseCode, msgList)
+	}
+	if responseCode = ackMessage(t, processor, popTime, invisibleTime, msgList[0]); responseCode != code.SUCCESS {
+		t.Fatalf("ack failed %d", responseCode)
+	}
+	if responseCode = ackMessage(t, processor, popTime, invisibleTime, msgList[0]); responseCode != code.INVALID_RECEIPT_HANDLE {
+		t.Fatalf("ack twice %d", responseCode)
+	}
+
+	for offset := int64(1); offset < 3; offset++ {
+		if responseCode, popTime, msgList = popMessages(t, processor, 1, invisibleTime); responseCode != code.SUCCESS || len(msgList) != 1 || msgList[0].QueueOffset != offset {
+			t.Fatalf("unexpected pop result %d %v", responseCode, msgList)
+		}
+	}
+	if responseCode, _, _ = popMessages(t, processor, 1, invisibleTime); responseCode != code.PULL_NOT_FOUND {
+		t.Fatalf("unexpected pop result %d", responseCode)
+	}
+
+	// 不可见时间到期后未确认的消息重新投递，旧句柄失效
+	time.Sleep(invisibleTime * time.Millisecond)
+	staleMsg := msgList[0]
+	responseCode, newPopTime, msgList := popMessages(t, processor, 10, invisibleTime)
+	if responseCode != code.SUCCESS || len(msgList) != 2 || msgList[0].QueueOffset != 1 || msgList[1].QueueOffset != 2 {
+		t.Fatalf("unexpected redelivery %d %v", responseCode, msgList)
+	}
+	if responseCode = ackMessage(t, processor, popTime, invisibleTime, staleMsg); responseCode != code.INVALID_RECEIPT_HANDLE {
+		t.Fatalf("ack with stale handle %d", responseCode)
+	}
+	if responseCode = ackMessage(t, processor, newPopTime, invisibleTime, msgList[1]); responseCode != code.SUCCESS {
+		t.Fatalf("ack failed %d", responseCode)
+	}
+
+	// 超过最大重试次数的消息转入死信队列
+	time.Sleep(invisibleTime * time.Millisecond)
+	if responseCode, _, msgList = popMessages(t, processor, 10, invisibleTime); responseCode != code.PULL_NOT_FOUND {
+		t.Fatalf("unexpected pop result %d %v", responseCode, msgList)
+	}
+	waitOffset(t, controller, stgcommon.GetDLQTopic(popGroup), 1)
+	if offset := controller.ConsumerOffsetManager.QueryOffset(popGroup, popTopic, 0); offset != 3 {
+		t.Fatalf("unexpected commit offset %d", offset)
+	}
+}
+
+func TestPopMessageLongPolling(t *testing.T) {
+	controller, shutdown := newPopBrokerController(t, 16)
+	defer shutdown()
+	processor := controller.PopMessageProcessor
+
+	// 新消息到达时唤醒被Hold住的请求
+	const invisibleTime = 300
+	ctx := holdPopRequest(t, processor, invisibleTime, 5000)
+	putPopMessages(t, controller, 1)
+	controller.PullRequestHoldService.NotifyPopMessageArriving(popTopic)
+	responseCode, popTime, msgList := decodePopResponse(t, waitPopResponse(t, ctx, 2*time.Second))
+	if responseCode != code.SUCCESS || len(msgList) != 1 || msgList[0].QueueOffset != 0 {
+		t.Fatalf("unexpected pop result %d %v", responseCode, msgList)
+	}
+
+	// 未确认消息的不可见时间到期时唤醒，重新投递
+	ctx = holdPopRequest(t, processor, invisibleTime, 5000)
+	responseCode, newPopTime, msgList := decodePopResponse(t, waitPopResponse(t, ctx, 2*time.Second))
+	if responseCode != code.SUCCESS || len(msgList) != 1 || msgList[0].QueueOffset != 0 || newPopTime < popTime+invisibleTime {
+		t.Fatalf("unexpected redelivery %d %d %v", responseCode, newPopTime, msgList)
+	}
+	if responseCode = ackMessage(t, processor, newPopTime, invisibleTime, msgList[0]); responseCode != code.SUCCESS {
+		t.Fatalf("ack failed %d", responseCode)
+	}
+
+	// 等待超时后返回没有新消息
+	begin := time.Now()
+	ctx = holdPopRequest(t, processor, invisibleTime, 300)
+	if responseCode, _, _ = decodePopResponse(t, waitPopResponse(t, ctx, 2*time.Second)); responseCode != code.PULL_NOT_FOUND {
+		t.Fatalf("unexpected pop result %d", responseCode)
+	}
+	if elapsed := time.Since(begin); elapsed < 300*time.Millisecond {
+		t.Fatalf("pop request answered before poll time, elapsed %s", elapsed)
+	}
+}
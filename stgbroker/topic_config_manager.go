@@ -116,6 +116,17 @@ func (self *TopicConfigManager) init() {
 		//logger.Infof("topicConfigManager init: %s", topicConfig.ToString())
 		self.TopicConfigSerializeWrapper.TopicConfigTable.Put(topicConfig.TopicName, topicConfig)
 	}
+
+	// SYS_TRACE_TOPIC
+	if self.BrokerController.BrokerConfig.TraceTopicEnable {
+		topicName := self.BrokerController.BrokerConfig.MsgTraceTopicName
+		topicConfig := stgcommon.NewTopicConfig(topicName)
+		self.SystemTopicList.Add(topicConfig)
+		topicConfig.ReadQueueNums = 1
+		topicConfig.WriteQueueNums = 1
+		topicConfig.Perm = constant.PERM_READ | constant.PERM_WRITE
+		self.TopicConfigSerializeWrapper.TopicConfigTable.Put(topicConfig.TopicName, topicConfig)
+	}
 }
 
 func (tcm *TopicConfigManager) isSystemTopic(topic string) bool {
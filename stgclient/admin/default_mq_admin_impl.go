@@ -11,22 +11,28 @@ import (
 	namesrvUtils "git.oschina.net/cloudzone/smartgo/stgcommon/namesrv"
 	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/stats"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/trace"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
 	set "github.com/deckarep/golang-set"
+	"sort"
 	"strings"
 )
 
 const (
-	timeoutMillis = int64(3 * 1000)
+	timeoutMillis      = int64(3 * 1000)
+	maxTraceMessageNum = 64 // 查询消息轨迹时，最多读取的轨迹消息条数
 )
 
-// 更新Broker配置
-func (impl *DefaultMQAdminExtImpl) UpdateBrokerConfig(brokerAddr string, properties map[string]interface{}) error {
-	// TODO
-	return nil
+// 更新Broker配置，返回实际发生变化的配置项
+func (impl *DefaultMQAdminExtImpl) UpdateBrokerConfig(brokerAddr string, properties map[string]interface{}) (*body.UpdateBrokerConfigResult, error) {
+	return impl.mqClientInstance.MQClientAPIImpl.UpdateBrokerConfig(brokerAddr, properties, timeoutMillis)
 }
 
 // 创建或更新Topic
@@ -36,13 +42,21 @@ func (impl *DefaultMQAdminExtImpl) CreateAndUpdateTopicConfig(brokerAddr string,
 
 // 向指定Broker创建或者更新订阅组配置
 func (impl *DefaultMQAdminExtImpl) CreateAndUpdateSubscriptionGroupConfig(addr string, config *subscription.SubscriptionGroupConfig) error {
-	// TODO
-	return nil
+	if config.RetryPolicy != nil {
+		if err := config.RetryPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	return impl.mqClientInstance.MQClientAPIImpl.CreateSubscriptionGroup(addr, config, timeoutMillis)
 }
 
 // 查询指定Broker的订阅组配置
 func (impl *DefaultMQAdminExtImpl) ExamineSubscriptionGroupConfig(addr, group string) (*subscription.SubscriptionGroupConfig, error) {
-	return nil, nil
+	subscriptionGroupTable, err := impl.mqClientInstance.MQClientAPIImpl.GetAllSubscriptionGroup(addr, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptionGroupTable.SubscriptionGroupTable[group], nil
 }
 
 // 查询指定Broker的Topic配置
@@ -671,6 +685,48 @@ func (impl *DefaultMQAdminExtImpl) ViewBrokerStatsData(brokerAddr, statsName, st
 	return impl.mqClientInstance.MQClientAPIImpl.ViewBrokerStatsData(brokerAddr, statsName, statsKey, timeoutMillis)
 }
 
+// ViewLatencyStats 查询topic在各broker上的耗时百分位，返回brokerAddr -> 统计数据
+// Author: agent
+// Since: 2026/10/19
+func (impl *DefaultMQAdminExtImpl) ViewLatencyStats(statsName, topic, group string) (map[string]*body.BrokerStatsData, error) {
+	statsKey := topic
+	switch statsName {
+	case stats.TOPIC_PUT_LATENCY, stats.COMMITLOG_PUT_LATENCY:
+	case stats.GROUP_GET_LATENCY:
+		if group == "" {
+			return nil, fmt.Errorf("group is empty for %s", statsName)
+		}
+		statsKey = topic + "@" + group
+	default:
+		return nil, fmt.Errorf("%s is not a latency stats", statsName)
+	}
+
+	topicRouteData, err := impl.ExamineTopicRouteInfo(topic)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*body.BrokerStatsData)
+	if topicRouteData == nil {
+		return result, nil
+	}
+	for _, bd := range topicRouteData.BrokerDatas {
+		brokerAddr := bd.SelectBrokerAddr()
+		if brokerAddr == "" {
+			continue
+		}
+		brokerStatsData, err := impl.ViewBrokerStatsData(brokerAddr, statsName, statsKey)
+		if err != nil {
+			// broker上没有该key的记录时返回统计不存在，其余错误直接返回
+			if strings.Contains(err.Error(), "not exist") {
+				continue
+			}
+			return nil, err
+		}
+		result[brokerAddr] = brokerStatsData
+	}
+	return result, nil
+}
+
 // 创建Topic
 // key 消息队列已存在的topic
 // newTopic 需新建的topic
@@ -706,7 +762,63 @@ func (impl *DefaultMQAdminExtImpl) ViewMessage(msgId string) (*message.MessageEx
 // begin  开始查询消息的时间戳
 // end    结束查询消息的时间戳
 func (impl *DefaultMQAdminExtImpl) QueryMessage(topic, key string, maxNum int, begin, end int64) (*admin.QueryResult, error) {
-	return nil, nil
+	topicRouteData, err := impl.ExamineTopicRouteInfo(topic)
+	if err != nil {
+		return nil, err
+	}
+	if topicRouteData == nil || topicRouteData.BrokerDatas == nil {
+		return nil, fmt.Errorf("the topic[%s] route info not exist", topic)
+	}
+
+	requestHeader := &header.QueryMessageRequestHeader{
+		Topic:          topic,
+		Key:            key,
+		MaxNum:         int32(maxNum),
+		BeginTimestamp: begin,
+		EndTimestamp:   end,
+	}
+	var indexLastUpdateTimestamp int64
+	messageList := make([]*message.MessageExt, 0)
+	for _, bd := range topicRouteData.BrokerDatas {
+		brokerAddr := bd.SelectBrokerAddr()
+		if brokerAddr == "" {
+			continue
+		}
+		queryResult, err := impl.mqClientInstance.MQClientAPIImpl.QueryMessage(brokerAddr, requestHeader, timeoutMillis)
+		if err != nil {
+			logger.Errorf("query message from broker[%s] failed. topic=%s, key=%s, err: %s", brokerAddr, topic, key, err.Error())
+			continue
+		}
+		if queryResult.IndexLastUpdateTimestamp > indexLastUpdateTimestamp {
+			indexLastUpdateTimestamp = queryResult.IndexLastUpdateTimestamp
+		}
+		messageList = append(messageList, queryResult.MessageList...)
+	}
+	return admin.NewQueryResult(indexLastUpdateTimestamp, messageList), nil
+}
+
+// 根据msgId查询消息轨迹
+// traceTopic 轨迹topic，为空时使用默认轨迹topic
+// msgId      消息ID
+func (impl *DefaultMQAdminExtImpl) QueryMessageTrace(traceTopic, msgId string) (*trace.MessageTrace, error) {
+	if traceTopic == "" {
+		traceTopic = stgcommon.SYS_TRACE_TOPIC
+	}
+	queryResult, err := impl.QueryMessage(traceTopic, msgId, maxTraceMessageNum, 0, timeutil.CurrentTimeMillis())
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*trace.TraceRecord, 0)
+	for _, msg := range queryResult.MessageList {
+		traceRecords, err := trace.DecodeTraceRecords(msg.Body)
+		if err != nil {
+			logger.Errorf("decode trace message failed. msgId=%s, err: %s", msg.MsgId, err.Error())
+			continue
+		}
+		records = append(records, traceRecords...)
+	}
+	return trace.BuildMessageTrace(msgId, records), nil
 }
 
 // 查询较早的存储消息
@@ -885,3 +997,183 @@ func (impl *DefaultMQAdminExtImpl) getBrokerAddrByName(brokerAddrTable map[strin
 	}
 	return 0, ""
 }
+
+// dlqBrokerDatas 获得消费组死信Topic所在的broker，按照brokerName排序
+func (impl *DefaultMQAdminExtImpl) dlqBrokerDatas(consumerGroup string) ([]*route.BrokerData, error) {
+	topicRouteData, err := impl.ExamineTopicRouteInfo(stgcommon.GetDLQTopic(consumerGroup))
+	if err != nil {
+		return nil, err
+	}
+	if topicRouteData == nil || topicRouteData.BrokerDatas == nil {
+		return []*route.BrokerData{}, nil
+	}
+	brokerDatas := topicRouteData.BrokerDatas
+	sort.Slice(brokerDatas, func(i, j int) bool {
+		return brokerDatas[i].BrokerName < brokerDatas[j].BrokerName
+	})
+	return brokerDatas, nil
+}
+
+// 分页查询消费组的死信消息，pageNum从1开始，按照brokerName、queueId、queueOffset的顺序排列
+func (impl *DefaultMQAdminExtImpl) QueryDLQMessage(consumerGroup string, pageNum, pageSize int) (*body.DLQMessagePage, error) {
+	if pageNum < 1 || pageSize < 1 {
+		return nil, fmt.Errorf("invalid pageNum[%d] or pageSize[%d]", pageNum, pageSize)
+	}
+	brokerDatas, err := impl.dlqBrokerDatas(consumerGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	type dlqSegment struct {
+		brokerAddr string
+		queueInfo  *body.DLQQueueInfo
+	}
+	segments := make([]*dlqSegment, 0)
+	page := &body.DLQMessagePage{ConsumerGroup: consumerGroup, PageNum: pageNum, PageSize: pageSize, Messages: make([]*body.DLQMessage, 0)}
+	for _, brokerData := range brokerDatas {
+		brokerAddr := brokerData.SelectBrokerAddr()
+		if brokerAddr == "" {
+			continue
+		}
+		messageList, err := impl.mqClientInstance.MQClientAPIImpl.QueryDLQMessage(brokerAddr, consumerGroup, 0, 0, 0, timeoutMillis)
+		if err != nil {
+			return nil, err
+		}
+		for _, queueInfo := range messageList.QueueInfos {
+			if queueInfo.MaxOffset > queueInfo.MinOffset {
+				segments = append(segments, &dlqSegment{brokerAddr: brokerAddr, queueInfo: queueInfo})
+				page.Total += queueInfo.MaxOffset - queueInfo.MinOffset
+			}
+		}
+	}
+
+	skip := int64(pageNum-1) * int64(pageSize)
+	for _, segment := range segments {
+		need := pageSize - len(page.Messages)
+		if need <= 0 {
+			break
+		}
+		count := segment.queueInfo.MaxOffset - segment.queueInfo.MinOffset
+		if skip >= count {
+			skip -= count
+			continue
+		}
+
+		offset := segment.queueInfo.MinOffset + skip
+		skip = 0
+		for need > 0 && offset < segment.queueInfo.MaxOffset {
+			messageList, err := impl.mqClientInstance.MQClientAPIImpl.QueryDLQMessage(segment.brokerAddr, consumerGroup,
+				segment.queueInfo.QueueId, offset, int32(need), timeoutMillis)
+			if err != nil {
+				return nil, err
+			}
+			if len(messageList.Messages) == 0 {
+				break
+			}
+			page.Messages = append(page.Messages, messageList.Messages...)
+			need -= len(messageList.Messages)
+			offset = messageList.Messages[len(messageList.Messages)-1].QueueOffset + 1
+		}
+	}
+	return page, nil
+}
+
+// 将死信消息重新投递到原始Topic，msgIds为空表示投递消费组在所有broker上的全部死信消息
+func (impl *DefaultMQAdminExtImpl) ResendDLQMessage(consumerGroup string, msgIds []string) (*body.DLQResendResult, error) {
+	result := body.NewDLQResendResult()
+	if len(msgIds) > 0 {
+		// msgId中包含存储消息的broker地址，按照broker分组投递
+		brokerMsgIds := make(map[string][]string)
+		for _, msgId := range msgIds {
+			messageId, err := message.DecodeMessageId(msgId)
+			if err != nil {
+				result.Total++
+				result.FailedMsgIds[msgId] = err.Error()
+				continue
+			}
+			brokerMsgIds[messageId.Address] = append(brokerMsgIds[messageId.Address], msgId)
+		}
+		for brokerAddr, ids := range brokerMsgIds {
+			brokerResult, err := impl.mqClientInstance.MQClientAPIImpl.ResendDLQMessage(brokerAddr, consumerGroup, ids, timeoutMillis)
+			if err != nil {
+				result.Total += len(ids)
+				for _, msgId := range ids {
+					result.FailedMsgIds[msgId] = err.Error()
+				}
+				continue
+			}
+			result.Merge(brokerResult)
+		}
+		return result, nil
+	}
+
+	brokerDatas, err := impl.dlqBrokerDatas(consumerGroup)
+	if err != nil {
+		return nil, err
+	}
+	for _, brokerData := range brokerDatas {
+		brokerAddr := brokerData.SelectBrokerAddr()
+		if brokerAddr == "" {
+			continue
+		}
+		brokerResult, err := impl.mqClientInstance.MQClientAPIImpl.ResendDLQMessage(brokerAddr, consumerGroup, nil, timeoutMillis)
+		if err != nil {
+			return result, err
+		}
+		result.Merge(brokerResult)
+	}
+	return result, nil
+}
+
+// 清除死信消息，brokerName为空表示所有broker
+// offset大于等于0时清除队列offset小于offset的消息，否则清除存储时间早于timestamp的消息
+func (impl *DefaultMQAdminExtImpl) PurgeDLQMessage(consumerGroup, brokerName string, offset, timestamp int64) (*body.DLQPurgeResult, error) {
+	if offset < 0 && timestamp <= 0 {
+		return nil, fmt.Errorf("either offset or timestamp must be specified")
+	}
+	brokerDatas, err := impl.dlqBrokerDatas(consumerGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	result := body.NewDLQPurgeResult()
+	for _, brokerData := range brokerDatas {
+		if brokerName != "" && brokerData.BrokerName != brokerName {
+			continue
+		}
+		brokerAddr := brokerData.SelectBrokerAddr()
+		if brokerAddr == "" {
+			continue
+		}
+		brokerResult, err := impl.mqClientInstance.MQClientAPIImpl.PurgeDLQMessage(brokerAddr, consumerGroup, offset, timestamp, timeoutMillis)
+		if err != nil {
+			return result, err
+		}
+		result.Purged += brokerResult.Purged
+		result.QueueInfos = append(result.QueueInfos, brokerResult.QueueInfos...)
+	}
+	return result, nil
+}
+
+// 在指定Broker上创建或更新收发配额
+func (impl *DefaultMQAdminExtImpl) CreateAndUpdateQuotaConfig(brokerAddr string, config *quota.QuotaConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return impl.mqClientInstance.MQClientAPIImpl.UpdateQuotaConfig(brokerAddr, config, timeoutMillis)
+}
+
+// 查询指定Broker上的全部收发配额
+func (impl *DefaultMQAdminExtImpl) ExamineQuotaConfig(brokerAddr string) (*quota.QuotaConfigTable, error) {
+	return impl.mqClientInstance.MQClientAPIImpl.GetAllQuotaConfig(brokerAddr, timeoutMillis)
+}
+
+// 删除指定Broker上的收发配额
+func (impl *DefaultMQAdminExtImpl) DeleteQuotaConfig(brokerAddr, resourceType, resourceName string) error {
+	return impl.mqClientInstance.MQClientAPIImpl.DeleteQuotaConfig(brokerAddr, resourceType, resourceName, timeoutMillis)
+}
+
+// 优雅下线指定Broker：摘除写权限、等待生产者迁移、刷盘后退出
+func (impl *DefaultMQAdminExtImpl) DrainBroker(brokerAddr string) error {
+	return impl.mqClientInstance.MQClientAPIImpl.DrainBroker(brokerAddr, timeoutMillis)
+}
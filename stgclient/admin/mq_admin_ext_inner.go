@@ -7,7 +7,9 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message/track"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/trace"
 	set "github.com/deckarep/golang-set"
 )
 
@@ -29,8 +31,8 @@ type MQAdminExtInner interface {
 	// 关闭Admin
 	Shutdown() error
 
-	// 更新Broker配置
-	UpdateBrokerConfig(brokerAddr string, properties map[string]interface{}) error
+	// 更新Broker配置，可热更新的配置项立即生效，其余配置项写入配置文件、重启Broker后生效
+	UpdateBrokerConfig(brokerAddr string, properties map[string]interface{}) (*body.UpdateBrokerConfigResult, error)
 
 	// 向指定Broker创建或者更新Topic配置
 	CreateAndUpdateTopicConfig(addr string, config *stgcommon.TopicConfig) error
@@ -155,9 +157,34 @@ type MQAdminExtInner interface {
 	// 克隆某一个组的消费进度到新的组
 	CloneGroupOffset(srcGroup, destGroup, topic string, isOffline bool) error
 
-	// 服务器统计数据输出
+	// 服务器统计数据输出，statsName为TOPIC_PUT_LATENCY等延迟统计维度时，结果中同时带有tp50、tp99等耗时百分位(微秒)
 	ViewBrokerStatsData(brokerAddr, statsName, statsKey string) (*body.BrokerStatsData, error)
 
+	// 查询topic在各broker上的耗时百分位，statsName为stats.TOPIC_PUT_LATENCY、stats.COMMITLOG_PUT_LATENCY时group为空，
+	// 为stats.GROUP_GET_LATENCY时须指定消费组；返回brokerAddr -> 统计数据，没有记录的broker不在结果中
+	ViewLatencyStats(statsName, topic, group string) (map[string]*body.BrokerStatsData, error)
+
+	// 分页查询消费组的死信消息，pageNum从1开始
+	QueryDLQMessage(consumerGroup string, pageNum, pageSize int) (*body.DLQMessagePage, error)
+
+	// 将死信消息重新投递到原始Topic，msgIds为空表示全部投递
+	ResendDLQMessage(consumerGroup string, msgIds []string) (*body.DLQResendResult, error)
+
+	// 清除offset或timestamp之前的死信消息，brokerName为空表示所有broker
+	PurgeDLQMessage(consumerGroup, brokerName string, offset, timestamp int64) (*body.DLQPurgeResult, error)
+
+	// 在指定Broker上创建或更新收发配额
+	CreateAndUpdateQuotaConfig(brokerAddr string, config *quota.QuotaConfig) error
+
+	// 查询指定Broker上的全部收发配额
+	ExamineQuotaConfig(brokerAddr string) (*quota.QuotaConfigTable, error)
+
+	// 删除指定Broker上的收发配额
+	DeleteQuotaConfig(brokerAddr, resourceType, resourceName string) error
+
+	// 优雅下线指定Broker：摘除写权限、等待生产者迁移、刷盘后退出
+	DrainBroker(brokerAddr string) error
+
 	// 创建指定Topic
 	CreateCustomTopic(brokerAddr string, topicConfig *stgcommon.TopicConfig) error
 
@@ -172,6 +199,11 @@ type MQAdminExtInner interface {
 	// end    结束查询消息的时间戳
 	QueryMessage(topic, key string, maxNum int, begin, end int64) (*admin.QueryResult, error)
 
+	// 根据msgId查询消息轨迹
+	// traceTopic 轨迹topic，为空时使用默认轨迹topic
+	// msgId      消息ID
+	QueryMessageTrace(traceTopic, msgId string) (*trace.MessageTrace, error)
+
 	// 查询较早的存储消息
 	EarliestMsgStoreTime(mq *message.MessageQueue) (int64, error)
 
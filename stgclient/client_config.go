@@ -2,6 +2,7 @@ package stgclient
 
 import (
 	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
 	"net"
 	"os"
 	"runtime"
@@ -21,6 +22,7 @@ type ClientConfig struct {
 	PollNameServerInterval        int
 	HeartbeatBrokerInterval       int
 	PersistConsumerOffsetInterval int
+	TLSOptions                    *netm.TLSOptions // 访问namesrv、broker的TLS配置，为nil表示不使用TLS
 }
 
 func NewClientConfig(namesrvAddr string) *ClientConfig {
@@ -81,7 +83,8 @@ func (client *ClientConfig) CloneClientConfig() *ClientConfig {
 		ClientCallbackExecutorThreads: client.ClientCallbackExecutorThreads,
 		PollNameServerInterval:        client.PollNameServerInterval,
 		HeartbeatBrokerInterval:       client.HeartbeatBrokerInterval,
-		PersistConsumerOffsetInterval: client.PersistConsumerOffsetInterval}
+		PersistConsumerOffsetInterval: client.PersistConsumerOffsetInterval,
+		TLSOptions:                    client.TLSOptions}
 }
 
 func (client *ClientConfig) ResetClientConfig(cc *ClientConfig) {
@@ -92,6 +95,7 @@ func (client *ClientConfig) ResetClientConfig(cc *ClientConfig) {
 	client.PollNameServerInterval = cc.PollNameServerInterval
 	client.HeartbeatBrokerInterval = cc.HeartbeatBrokerInterval
 	client.PersistConsumerOffsetInterval = cc.PersistConsumerOffsetInterval
+	client.TLSOptions = cc.TLSOptions
 }
 
 func hashCode(s string) int64 {
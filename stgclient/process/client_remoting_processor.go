@@ -30,6 +30,10 @@ func (self *ClientRemotingProcessor) ProcessRequest(ctx netm.Context, request *p
 		return self.notifyConsumerIdsChanged(ctx, request)
 	case code.CONSUME_MESSAGE_DIRECTLY:
 		return self.consumeMessageDirectly(ctx, request)
+	case code.PUSH_REPLY_MESSAGE_TO_CLIENT:
+		return self.receiveReplyMessage(ctx, request)
+	case code.NOTIFY_BROKER_DRAINING:
+		return self.notifyBrokerDraining(ctx, request)
 	default:
 		return nil, nil
 	}
@@ -80,3 +84,59 @@ func (self *ClientRemotingProcessor) consumeMessageDirectly(ctx netm.Context, re
 	response.Remark = fmt.Sprintf("The Consumer Group <%s> not exist in this consumer", requestHeader.ConsumerGroup)
 	return response, nil
 }
+
+// notifyBrokerDraining broker正在下线，立即从namesrv更新路由，生产者不再向该broker发送消息
+// Author: agent
+// Since: 2026/10/19
+func (self *ClientRemotingProcessor) notifyBrokerDraining(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	requestHeader := &header.NotifyBrokerDrainingRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		logger.Errorf("err: %s", err.Error())
+		return nil, err
+	}
+
+	format := "receive broker's notification[%s], the broker %s[%s] is draining, update topic route immediately"
+	logger.Infof(format, ctx.RemoteAddr().String(), requestHeader.BrokerName, requestHeader.BrokerAddr)
+	self.MQClientFactory.UpdateTopicRouteInfoFromNameServer()
+	return nil, nil
+}
+
+// receiveReplyMessage 接收broker推送的应答消息，唤醒对应的request
+// Author: agent
+// Since: 2026/10/19
+func (self *ClientRemotingProcessor) receiveReplyMessage(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	response := protocol.CreateDefaultResponseCommand()
+
+	requestHeader := &header.ReplyMessageRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		logger.Errorf("err: %s", err.Error())
+		return response, err
+	}
+
+	reply := &message.MessageExt{}
+	reply.Topic = requestHeader.Topic
+	reply.Flag = requestHeader.Flag
+	reply.Properties = message.String2messageProperties(requestHeader.Properties)
+	reply.Body = request.Body
+	reply.BornHost = requestHeader.BornHost
+	reply.BornTimestamp = requestHeader.BornTimestamp
+	reply.StoreHost = requestHeader.StoreHost
+	reply.StoreTimestamp = requestHeader.StoreTimestamp
+
+	correlationId := reply.GetProperty(message.PROPERTY_CORRELATION_ID)
+	future := self.MQClientFactory.RequestFutureTable.Remove(correlationId)
+	if future == nil {
+		format := "receive reply message from %s, but the request is timeout or not exist, correlationId=%s"
+		logger.Warnf(format, ctx.RemoteAddr().String(), correlationId)
+		response.Code = code.SYSTEM_ERROR
+		response.Remark = fmt.Sprintf("the request %s is timeout or not exist", correlationId)
+		return response, nil
+	}
+	future.putResponseMessage(reply)
+
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
@@ -0,0 +1,199 @@
+package process
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgclient"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/trace"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+	"os"
+)
+
+const (
+	clientTraceHookName = "ClientTraceHook"
+	traceSendOneway     = "SEND_ONEWAY"
+	traceSendFailed     = "SEND_FAILED"
+)
+
+// ClientTraceHook: 客户端消息轨迹，记录发送、消费开始及消费结束，轨迹通过内部producer异步批量发送到轨迹topic
+// Author: agent
+// Since:  2026/10/19
+type ClientTraceHook struct {
+	traceTopic    string
+	ownerGroup    string
+	rpcHook       remoting.RPCHook
+	traceProducer *DefaultMQProducer
+	dispatcher    *trace.TraceDispatcher
+}
+
+// NewClientTraceHook: 初始化客户端消息轨迹，traceTopic为空时使用默认轨迹topic
+// Author: agent
+// Since:  2026/10/19
+func NewClientTraceHook(ownerGroup, traceTopic string, rpcHook remoting.RPCHook) *ClientTraceHook {
+	if traceTopic == "" {
+		traceTopic = stgcommon.SYS_TRACE_TOPIC
+	}
+	return &ClientTraceHook{traceTopic: traceTopic, ownerGroup: ownerGroup, rpcHook: rpcHook}
+}
+
+// Start: 启动内部轨迹producer，使用与业务客户端相同的namesrv、TLS配置
+// Author: agent
+// Since:  2026/10/19
+func (hook *ClientTraceHook) Start(clientConfig *stgclient.ClientConfig) {
+	if hook.dispatcher != nil {
+		return
+	}
+	hook.traceProducer = NewCustomMQProducer(stgcommon.TRACE_PRODUCER_GROUP, hook.rpcHook)
+	hook.traceProducer.ClientConfig = clientConfig.CloneClientConfig()
+	// 独立的客户端实例，避免与业务producer共用MQClientInstance时ProducerGroup冲突
+	hook.traceProducer.ClientConfig.InstanceName = fmt.Sprintf("%d_trace_%s", os.Getpid(), hook.ownerGroup)
+	hook.traceProducer.RetryTimesWhenSendFailed = 0
+	hook.traceProducer.Start()
+
+	sender := &producerTraceSender{producer: hook.traceProducer, traceTopic: hook.traceTopic}
+	hook.dispatcher = trace.NewTraceDispatcher(sender, trace.DEFAULT_TRACE_QUEUE_SIZE, trace.DEFAULT_TRACE_BATCH_SIZE, trace.DEFAULT_TRACE_FLUSH_INTERVAL)
+	hook.dispatcher.Start()
+}
+
+// Shutdown: 发送剩余轨迹后关闭内部轨迹producer
+// Author: agent
+// Since:  2026/10/19
+func (hook *ClientTraceHook) Shutdown() {
+	if hook.dispatcher == nil {
+		return
+	}
+	hook.dispatcher.Shutdown()
+	hook.traceProducer.Shutdown()
+	logger.Infof("%s shutdown OK, discard trace count: %d", clientTraceHookName, hook.dispatcher.DiscardCount())
+}
+
+func (hook *ClientTraceHook) HookName() string {
+	return clientTraceHookName
+}
+
+func (hook *ClientTraceHook) SendMessageBefore(context *SendMessageContext) {
+}
+
+// SendMessageAfter: 记录生产者发送轨迹
+// Author: agent
+// Since:  2026/10/19
+func (hook *ClientTraceHook) SendMessageAfter(context *SendMessageContext) {
+	if hook.dispatcher == nil || context == nil || context.Message == nil || context.Message.Topic == hook.traceTopic {
+		return
+	}
+
+	msg := context.Message
+	record := &trace.TraceRecord{
+		TraceType:  trace.TRACE_PUB,
+		Timestamp:  context.BeginTimestamp,
+		Group:      context.ProducerGroup,
+		Topic:      msg.Topic,
+		Keys:       msg.GetKeys(),
+		Tags:       msg.GetTags(),
+		ClientHost: context.BornHost,
+		StoreHost:  context.BrokerAddr,
+		BodyLength: len(msg.Body),
+		CostTime:   timeutil.CurrentTimeMillis() - context.BeginTimestamp,
+	}
+	if context.MessageQueue != nil {
+		record.QueueId = int32(context.MessageQueue.QueueId)
+	}
+
+	switch {
+	case context.Err != nil:
+		record.Status = traceSendFailed
+	case context.SendResult != nil:
+		record.MsgId = context.SendResult.MsgId
+		record.QueueOffset = context.SendResult.QueueOffset
+		record.Success = context.SendResult.SendStatus == SEND_OK
+		record.Status = context.SendResult.SendStatus.String()
+	default:
+		record.Success = true
+		record.Status = traceSendOneway
+	}
+	hook.dispatcher.Append(record)
+}
+
+// ConsumeMessageBefore: 记录消费开始轨迹
+// Author: agent
+// Since:  2026/10/19
+func (hook *ClientTraceHook) ConsumeMessageBefore(context *ConsumeMessageContext) {
+	hook.appendConsumeTrace(trace.TRACE_SUB_BEFORE, context)
+}
+
+// ConsumeMessageAfter: 记录消费结果轨迹
+// Author: agent
+// Since:  2026/10/19
+func (hook *ClientTraceHook) ConsumeMessageAfter(context *ConsumeMessageContext) {
+	hook.appendConsumeTrace(trace.TRACE_SUB_AFTER, context)
+}
+
+func (hook *ClientTraceHook) appendConsumeTrace(traceType trace.TraceType, context *ConsumeMessageContext) {
+	if hook.dispatcher == nil || context == nil {
+		return
+	}
+
+	now := timeutil.CurrentTimeMillis()
+	for _, msg := range context.MsgList {
+		if msg == nil || msg.Topic == hook.traceTopic {
+			continue
+		}
+		// 重试消息的msgId是新生成的，轨迹按原始msgId记录，便于与发送轨迹串联
+		msgId := msg.GetOriginMessageID()
+		if msgId == "" {
+			msgId = msg.MsgId
+		}
+		record := &trace.TraceRecord{
+			TraceType:   traceType,
+			Timestamp:   now,
+			Group:       context.ConsumerGroup,
+			Topic:       msg.Topic,
+			MsgId:       msgId,
+			Keys:        msg.GetKeys(),
+			Tags:        msg.GetTags(),
+			ClientHost:  stgclient.GetLocalAddress(),
+			StoreHost:   msg.StoreHost,
+			QueueId:     msg.QueueId,
+			QueueOffset: msg.QueueOffset,
+			StoreTime:   msg.StoreTimestamp,
+			BodyLength:  len(msg.Body),
+			RetryTimes:  msg.ReconsumeTimes,
+		}
+		if traceType == trace.TRACE_SUB_AFTER {
+			record.CostTime = now - context.BeginTimestamp
+			record.Success = context.Success
+			record.Status = context.Status
+		} else {
+			record.Success = true
+		}
+		hook.dispatcher.Append(record)
+	}
+}
+
+// producerTraceSender: 通过内部producer将一批轨迹作为一条消息发送到轨迹topic
+// Author: agent
+// Since:  2026/10/19
+type producerTraceSender struct {
+	producer   *DefaultMQProducer
+	traceTopic string
+}
+
+func (sender *producerTraceSender) SendTrace(records []*trace.TraceRecord) error {
+	body, err := trace.EncodeTraceRecords(records)
+	if err != nil {
+		return err
+	}
+	msg := message.NewMessage(sender.traceTopic, "", body)
+	msg.SetKeys(trace.BuildTraceKeys(records))
+	sendResult, err := sender.producer.Send(msg)
+	if err != nil {
+		return err
+	}
+	if sendResult == nil || sendResult.SendStatus != SEND_OK {
+		return fmt.Errorf("send trace message to topic %s failed, result %v", sender.traceTopic, sendResult)
+	}
+	return nil
+}
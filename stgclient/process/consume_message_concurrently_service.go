@@ -8,6 +8,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
 	set "github.com/deckarep/golang-set"
 	"strings"
 	"time"
@@ -50,12 +51,27 @@ func (consume *consumeRequest) run() {
 	//	}
 	//}
 	consume.ConsumeMessageConcurrentlyService.resetRetryTopic(consume.msgs)
+	var hookContext *ConsumeMessageContext
+	if consume.defaultMQPushConsumerImpl.hasConsumeMessageHook() {
+		hookContext = &ConsumeMessageContext{
+			ConsumerGroup:  consume.consumerGroup,
+			MessageQueue:   consume.messageQueue,
+			MsgList:        consume.msgs,
+			BeginTimestamp: timeutil.CurrentTimeMillis(),
+		}
+		consume.defaultMQPushConsumerImpl.executeConsumeMessageHookBefore(hookContext)
+	}
 	status := msgListener.ConsumeMessage(consume.msgs, context)
 	// 用于客户端返回不正常处理
 	if status != listener.CONSUME_SUCCESS && status != listener.RECONSUME_LATER {
 		logger.Warnf("consumeMessage return error, Group: %v Msgs: %v MQ: %v", consume.consumerGroup, consume.msgs, consume.messageQueue.ToString())
 		status = listener.RECONSUME_LATER
 	}
+	if hookContext != nil {
+		hookContext.Success = status == listener.CONSUME_SUCCESS
+		hookContext.Status = status.String()
+		consume.defaultMQPushConsumerImpl.executeConsumeMessageHookAfter(hookContext)
+	}
 	//todo 消费统计
 	// 处理队列没有drop对消费结果进行处理
 	if !consume.processQueue.Dropped {
@@ -0,0 +1,26 @@
+package process
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// ConsumeMessageContext: 客户端消费消息上下文
+// Author: agent
+// Since:  2026/10/19
+type ConsumeMessageContext struct {
+	ConsumerGroup  string
+	MessageQueue   *message.MessageQueue
+	MsgList        []*message.MessageExt
+	BeginTimestamp int64
+	Success        bool
+	Status         string
+}
+
+// ConsumeMessageHook: 客户端消费消息回调，如消息轨迹
+// Author: agent
+// Since:  2026/10/19
+type ConsumeMessageHook interface {
+	HookName() string
+	ConsumeMessageBefore(context *ConsumeMessageContext)
+	ConsumeMessageAfter(context *ConsumeMessageContext)
+}
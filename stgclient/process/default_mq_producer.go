@@ -4,6 +4,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgclient"
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
 )
 
 // 默认发送
@@ -22,6 +23,8 @@ type DefaultMQProducer struct {
 	MaxMessageSize                   int
 	UnitMode                         bool
 	ClientConfig                     *stgclient.ClientConfig
+	rpcHook                          remoting.RPCHook
+	traceHook                        *ClientTraceHook
 }
 
 func NewDefaultMQProducer(producerGroup string) *DefaultMQProducer {
@@ -40,13 +43,31 @@ func NewDefaultMQProducer(producerGroup string) *DefaultMQProducer {
 	return defaultMQProducer
 }
 
+// 创建带rpcHook的producer，如ACL签名: acl.NewAclClientRPCHook(accessKey, secretKey)
+func NewCustomMQProducer(producerGroup string, rpcHook remoting.RPCHook) *DefaultMQProducer {
+	defaultMQProducer := NewDefaultMQProducer(producerGroup)
+	defaultMQProducer.rpcHook = rpcHook
+	return defaultMQProducer
+}
+
 func (defaultMQProducer *DefaultMQProducer) SetNamesrvAddr(namesrvAddr string) {
 	defaultMQProducer.ClientConfig.NamesrvAddr = namesrvAddr
 }
 
 func (defaultMQProducer *DefaultMQProducer) Start() {
 	defaultMQProducer.DefaultMQProducerImpl.start()
+	if defaultMQProducer.traceHook != nil {
+		defaultMQProducer.traceHook.Start(defaultMQProducer.ClientConfig)
+	}
+}
 
+// 开启消息轨迹，须在Start()之前调用，traceTopic为空时使用默认轨迹topic
+func (defaultMQProducer *DefaultMQProducer) EnableMsgTrace(traceTopic string) {
+	if defaultMQProducer.traceHook != nil {
+		return
+	}
+	defaultMQProducer.traceHook = NewClientTraceHook(defaultMQProducer.ProducerGroup, traceTopic, defaultMQProducer.rpcHook)
+	defaultMQProducer.DefaultMQProducerImpl.RegisterSendMessageHook(defaultMQProducer.traceHook)
 }
 
 // 对外提供创建topic方法
@@ -56,6 +77,9 @@ func (defaultMQProducer *DefaultMQProducer) CreateTopic(key, newTopic string, qu
 
 func (defaultMQProducer *DefaultMQProducer) Shutdown() {
 	defaultMQProducer.DefaultMQProducerImpl.Shutdown()
+	if defaultMQProducer.traceHook != nil {
+		defaultMQProducer.traceHook.Shutdown()
+	}
 }
 
 // 发送同步消息
@@ -63,6 +87,11 @@ func (defaultMQProducer *DefaultMQProducer) Send(msg *message.Message) (*SendRes
 	return defaultMQProducer.DefaultMQProducerImpl.send(msg)
 }
 
+// 按shardingKey选择队列发送同步消息，相同shardingKey的消息由集群消费的同一个consumer消费
+func (defaultMQProducer *DefaultMQProducer) SendByShardingKey(msg *message.Message, shardingKey string) (*SendResult, error) {
+	return defaultMQProducer.DefaultMQProducerImpl.sendByShardingKey(msg, shardingKey)
+}
+
 // 发送sendOneWay消息
 func (defaultMQProducer *DefaultMQProducer) SendOneWay(msg *message.Message) error {
 	return defaultMQProducer.DefaultMQProducerImpl.sendOneWay(msg)
@@ -72,3 +101,23 @@ func (defaultMQProducer *DefaultMQProducer) SendOneWay(msg *message.Message) err
 func (defaultMQProducer *DefaultMQProducer) SendCallBack(msg *message.Message, callback SendCallback) error {
 	return defaultMQProducer.DefaultMQProducerImpl.sendCallBack(msg, callback)
 }
+
+// 同步request，等待消费方的应答消息
+func (defaultMQProducer *DefaultMQProducer) Request(msg *message.Message, timeout int64) (*message.MessageExt, error) {
+	return defaultMQProducer.DefaultMQProducerImpl.request(msg, timeout)
+}
+
+// 按shardingKey选择队列同步request，相同shardingKey的请求由集群消费的同一个consumer处理
+func (defaultMQProducer *DefaultMQProducer) RequestByShardingKey(msg *message.Message, shardingKey string, timeout int64) (*message.MessageExt, error) {
+	return defaultMQProducer.DefaultMQProducerImpl.requestByShardingKey(msg, shardingKey, timeout)
+}
+
+// 异步request，收到应答或超时后回调
+func (defaultMQProducer *DefaultMQProducer) RequestCallBack(msg *message.Message, callback RequestCallback, timeout int64) error {
+	return defaultMQProducer.DefaultMQProducerImpl.requestCallBack(msg, callback, timeout)
+}
+
+// 发送应答消息给request的请求方
+func (defaultMQProducer *DefaultMQProducer) SendReply(requestMsg *message.MessageExt, body []byte, timeout int64) error {
+	return sendReplyMessage(defaultMQProducer.DefaultMQProducerImpl.MQClientFactory, defaultMQProducer.ProducerGroup, requestMsg, body, timeout)
+}
@@ -7,8 +7,11 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/sync"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
 	set "github.com/deckarep/golang-set"
 	"strconv"
 	"strings"
@@ -24,6 +27,7 @@ type DefaultMQProducerImpl struct {
 	TopicPublishInfoTable *sync.Map
 	ServiceState          stgcommon.ServiceState
 	MQClientFactory       *MQClientInstance
+	sendMessageHookList   []SendMessageHook
 	// topic *TopicPublishInfo
 }
 
@@ -51,7 +55,7 @@ func (defaultMQProducerImpl *DefaultMQProducerImpl) StartFlag(startFactory bool)
 			defaultMQProducerImpl.DefaultMQProducer.ClientConfig.ChangeInstanceNameToPID()
 		}
 		// 初始化MQClientInstance
-		defaultMQProducerImpl.MQClientFactory = GetInstance().GetAndCreateMQClientInstance(defaultMQProducerImpl.DefaultMQProducer.ClientConfig)
+		defaultMQProducerImpl.MQClientFactory = GetInstance().GetAndCreateMQClientInstanceByHook(defaultMQProducerImpl.DefaultMQProducer.ClientConfig, defaultMQProducerImpl.DefaultMQProducer.rpcHook)
 		// 注册producer
 		defaultMQProducerImpl.MQClientFactory.RegisterProducer(defaultMQProducerImpl.DefaultMQProducer.ProducerGroup, defaultMQProducerImpl)
 		// 保存topic信息
@@ -126,6 +130,113 @@ func (defaultMQProducerImpl *DefaultMQProducerImpl) SendByTimeout(msg *message.M
 	return defaultMQProducerImpl.sendDefaultImpl(msg, SYNC, nil, timeout)
 }
 
+// 按shardingKey选择队列同步发送，相同shardingKey的消息发送到同一队列，发送失败时不重试其他队列
+func (defaultMQProducerImpl *DefaultMQProducerImpl) sendByShardingKey(msg *message.Message, shardingKey string) (*SendResult, error) {
+	if defaultMQProducerImpl.ServiceState != stgcommon.RUNNING {
+		return nil, fmt.Errorf("The producer service state not OK. serviceState=%s", defaultMQProducerImpl.ServiceState.String())
+	}
+	mq, err := defaultMQProducerImpl.selectQueueByShardingKey(msg, shardingKey)
+	if err != nil {
+		return nil, err
+	}
+	return defaultMQProducerImpl.sendKernelImpl(msg, mq, SYNC, nil, defaultMQProducerImpl.DefaultMQProducer.SendMsgTimeout)
+}
+
+// 按shardingKey选择队列
+func (defaultMQProducerImpl *DefaultMQProducerImpl) selectQueueByShardingKey(msg *message.Message, shardingKey string) (*message.MessageQueue, error) {
+	CheckMessage(msg, *defaultMQProducerImpl.DefaultMQProducer)
+	topicPublishInfo := defaultMQProducerImpl.tryToFindTopicPublishInfo(msg.Topic)
+	if topicPublishInfo == nil {
+		return nil, fmt.Errorf("send by sharding key error, topicPublishInfo of %s is nil", msg.Topic)
+	}
+	mq := topicPublishInfo.SelectMessageQueueByKey(shardingKey)
+	if mq == nil {
+		return nil, fmt.Errorf("send by sharding key error, messageQueueList of %s is empty", msg.Topic)
+	}
+	return mq, nil
+}
+
+// 同步request，发送后阻塞等待应答，超时返回错误
+func (defaultMQProducerImpl *DefaultMQProducerImpl) request(msg *message.Message, timeout int64) (*message.MessageExt, error) {
+	return defaultMQProducerImpl.waitReply(msg, timeout, func() error {
+		_, err := defaultMQProducerImpl.sendDefaultImpl(msg, SYNC, nil, timeout)
+		return err
+	})
+}
+
+// 按shardingKey选择队列同步request，相同shardingKey的请求由集群消费的同一个consumer处理
+func (defaultMQProducerImpl *DefaultMQProducerImpl) requestByShardingKey(msg *message.Message, shardingKey string, timeout int64) (*message.MessageExt, error) {
+	return defaultMQProducerImpl.waitReply(msg, timeout, func() error {
+		mq, err := defaultMQProducerImpl.selectQueueByShardingKey(msg, shardingKey)
+		if err != nil {
+			return err
+		}
+		_, err = defaultMQProducerImpl.sendKernelImpl(msg, mq, SYNC, nil, timeout)
+		return err
+	})
+}
+
+// 登记应答的future后调用send发送请求，阻塞等待应答，超时返回错误
+func (defaultMQProducerImpl *DefaultMQProducerImpl) waitReply(msg *message.Message, timeout int64, send func() error) (*message.MessageExt, error) {
+	beginTimestamp := timeutil.CurrentTimeMillis()
+	if err := defaultMQProducerImpl.prepareSendRequest(msg, timeout); err != nil {
+		return nil, err
+	}
+	correlationId := msg.GetProperty(message.PROPERTY_CORRELATION_ID)
+	requestFutureTable := defaultMQProducerImpl.MQClientFactory.RequestFutureTable
+	future := NewRequestResponseFuture(correlationId, timeout, nil)
+	requestFutureTable.Put(future)
+	defer requestFutureTable.Remove(correlationId)
+
+	if err := send(); err != nil {
+		return nil, err
+	}
+
+	reply := future.waitResponseMessage(timeout - (timeutil.CurrentTimeMillis() - beginTimestamp))
+	if reply == nil {
+		return nil, fmt.Errorf("request timeout, no reply message received, correlationId=%s, timeout=%d", correlationId, timeout)
+	}
+	return reply, nil
+}
+
+// 异步request，收到应答或超时后执行回调
+func (defaultMQProducerImpl *DefaultMQProducerImpl) requestCallBack(msg *message.Message, callback RequestCallback, timeout int64) error {
+	if callback == nil {
+		return errors.New("request callback is nil")
+	}
+	if err := defaultMQProducerImpl.prepareSendRequest(msg, timeout); err != nil {
+		return err
+	}
+	correlationId := msg.GetProperty(message.PROPERTY_CORRELATION_ID)
+	requestFutureTable := defaultMQProducerImpl.MQClientFactory.RequestFutureTable
+	requestFutureTable.Put(NewRequestResponseFuture(correlationId, timeout, callback))
+
+	_, err := defaultMQProducerImpl.sendDefaultImpl(msg, ASYNC, func(sendResult *SendResult, err error) {
+		if err != nil && requestFutureTable.Remove(correlationId) != nil {
+			callback(nil, err)
+		}
+	}, timeout)
+	if err != nil {
+		requestFutureTable.Remove(correlationId)
+		return err
+	}
+	return nil
+}
+
+// 为request消息设置关联ID、请求方clientId及截止时间
+func (defaultMQProducerImpl *DefaultMQProducerImpl) prepareSendRequest(msg *message.Message, timeout int64) error {
+	if defaultMQProducerImpl.ServiceState != stgcommon.RUNNING {
+		return fmt.Errorf("The producer service state not OK. serviceState=%s", defaultMQProducerImpl.ServiceState.String())
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("request timeout must be positive, timeout=%d", timeout)
+	}
+	msg.PutProperty(message.PROPERTY_CORRELATION_ID, utils.CUID())
+	msg.PutProperty(message.PROPERTY_MESSAGE_REPLY_TO_CLIENT, defaultMQProducerImpl.MQClientFactory.ClientId)
+	msg.PutProperty(message.PROPERTY_REQUEST_DEADLINE, strconv.FormatInt(timeutil.CurrentTimeMillis()+timeout, 10))
+	return nil
+}
+
 // 选择需要发送的queue
 func (defaultMQProducerImpl *DefaultMQProducerImpl) sendDefaultImpl(msg *message.Message, communicationMode CommunicationMode,
 	sendCallback SendCallback, timeout int64) (*SendResult, error) {
@@ -152,6 +263,15 @@ func (defaultMQProducerImpl *DefaultMQProducerImpl) sendDefaultImpl(msg *message
 				mq = tmpMQ
 				sendResult, err := defaultMQProducerImpl.sendKernelImpl(msg, mq, communicationMode, sendCallback, timeout)
 				if err != nil {
+					// broker超出配额时，按broker建议的间隔等待后重试，剩余时间不足则直接返回
+					if quotaErr, ok := err.(*quota.QuotaExceededError); ok && communicationMode == SYNC && times+1 < int(timesTotal) {
+						elapsed := time.Now().Unix()*1000 - beginTimestamp
+						if quotaErr.RetryAfterMillis < maxTimeout-elapsed {
+							time.Sleep(quotaErr.RetryAfter())
+							endTimestamp = time.Now().Unix() * 1000
+							continue
+						}
+					}
 					return nil, err
 				}
 				endTimestamp = time.Now().Unix() * 1000
@@ -189,7 +309,30 @@ func (defaultMQProducerImpl *DefaultMQProducerImpl) sendKernelImpl(msg *message.
 			sysFlag |= sysflag.CompressedFlag
 		}
 		//todo 事务消息处理
-		//todo 自定义hook处理
+		var context *SendMessageContext
+		if defaultMQProducerImpl.hasSendMessageHook() {
+			context = &SendMessageContext{
+				ProducerGroup:     defaultMQProducerImpl.DefaultMQProducer.ProducerGroup,
+				Message:           msg,
+				MessageQueue:      mq,
+				BrokerAddr:        brokerAddr,
+				BornHost:          defaultMQProducerImpl.DefaultMQProducer.ClientConfig.ClientIP,
+				CommunicationMode: communicationMode,
+				BeginTimestamp:    timeutil.CurrentTimeMillis(),
+			}
+			defaultMQProducerImpl.executeSendMessageHookBefore(context)
+			if communicationMode == ASYNC {
+				callback := sendCallback
+				sendCallback = func(sendResult *SendResult, err error) {
+					context.SendResult = sendResult
+					context.Err = err
+					defaultMQProducerImpl.executeSendMessageHookAfter(context)
+					if callback != nil {
+						callback(sendResult, err)
+					}
+				}
+			}
+		}
 		// 构造SendMessageRequestHeader
 		requestHeader := header.SendMessageRequestHeader{
 			ProducerGroup:         defaultMQProducerImpl.DefaultMQProducer.ProducerGroup,
@@ -216,6 +359,11 @@ func (defaultMQProducerImpl *DefaultMQProducerImpl) sendKernelImpl(msg *message.
 
 		sendResult, err := defaultMQProducerImpl.MQClientFactory.MQClientAPIImpl.SendMessage(brokerAddr, mq.BrokerName, msg, requestHeader, timeout, communicationMode, sendCallback)
 		msg.Body = prevBody
+		if context != nil && (communicationMode != ASYNC || err != nil) {
+			context.SendResult = sendResult
+			context.Err = err
+			defaultMQProducerImpl.executeSendMessageHookAfter(context)
+		}
 		return sendResult, err
 	} else {
 		panic(fmt.Errorf("The broker[%s] not exist ", mq.BrokerName))
@@ -304,3 +452,27 @@ func (defaultMQProducerImpl *DefaultMQProducerImpl) tryToCompressMessage(msg *me
 	}
 	return false
 }
+
+// 注册发送消息回调，如消息轨迹
+func (defaultMQProducerImpl *DefaultMQProducerImpl) RegisterSendMessageHook(hook SendMessageHook) {
+	defaultMQProducerImpl.sendMessageHookList = append(defaultMQProducerImpl.sendMessageHookList, hook)
+	logger.Infof("register sendMessage Hook, %s", hook.HookName())
+}
+
+func (defaultMQProducerImpl *DefaultMQProducerImpl) hasSendMessageHook() bool {
+	return len(defaultMQProducerImpl.sendMessageHookList) > 0
+}
+
+func (defaultMQProducerImpl *DefaultMQProducerImpl) executeSendMessageHookBefore(context *SendMessageContext) {
+	defer utils.RecoveredFn()
+	for _, hook := range defaultMQProducerImpl.sendMessageHookList {
+		hook.SendMessageBefore(context)
+	}
+}
+
+func (defaultMQProducerImpl *DefaultMQProducerImpl) executeSendMessageHookAfter(context *SendMessageContext) {
+	defer utils.RecoveredFn()
+	for _, hook := range defaultMQProducerImpl.sendMessageHookList {
+		hook.SendMessageAfter(context)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/store"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
 	set "github.com/deckarep/golang-set"
 )
 
@@ -29,6 +30,7 @@ type DefaultMQPullConsumer struct {
 	offsetStore                      store.OffsetStore                      // Offset Storage
 	unitMode                         bool                                   // Whether the unit of subscription group
 	clientConfig                     *stgclient.ClientConfig                // the client config
+	rpcHook                          remoting.RPCHook                       // RPC hook, such as acl signature
 }
 
 func NewDefaultMQPullConsumer(consumerGroup string) *DefaultMQPullConsumer {
@@ -44,6 +46,13 @@ func NewDefaultMQPullConsumer(consumerGroup string) *DefaultMQPullConsumer {
 	return pullConsumer
 }
 
+// 创建带rpcHook的pull消费结构体，如ACL签名: acl.NewAclClientRPCHook(accessKey, secretKey)
+func NewCustomMQPullConsumer(consumerGroup string, rpcHook remoting.RPCHook) *DefaultMQPullConsumer {
+	pullConsumer := NewDefaultMQPullConsumer(consumerGroup)
+	pullConsumer.rpcHook = rpcHook
+	return pullConsumer
+}
+
 // 设置namesrvaddr
 func (pullConsumer *DefaultMQPullConsumer) SetNamesrvAddr(namesrvAddr string) {
 	pullConsumer.clientConfig.NamesrvAddr = namesrvAddr
@@ -61,6 +70,21 @@ func (pullConsumer *DefaultMQPullConsumer) FetchSubscribeMessageQueues(topic str
 	return pullConsumer.defaultMQPullConsumerImpl.fetchSubscribeMessageQueues(topic)
 }
 
+// 查询队列的最大offset，即下一条消息的offset
+func (pullConsumer *DefaultMQPullConsumer) MaxOffset(mq *message.MessageQueue) int64 {
+	return pullConsumer.defaultMQPullConsumerImpl.maxOffset(mq)
+}
+
 func (pullConsumer *DefaultMQPullConsumer) Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (*consumer.PullResult, error) {
 	return pullConsumer.defaultMQPullConsumerImpl.pull(mq, subExpression, offset, maxNums)
 }
+
+// 更新队列的消费进度并立即提交，集群模式下提交到broker
+func (pullConsumer *DefaultMQPullConsumer) UpdateConsumeOffset(mq *message.MessageQueue, offset int64) {
+	pullConsumer.defaultMQPullConsumerImpl.updateConsumeOffset(mq, offset)
+}
+
+// 查询队列的消费进度，fromStore为true时从broker(或本地文件)读取，-1表示没有消费进度
+func (pullConsumer *DefaultMQPullConsumer) FetchConsumeOffset(mq *message.MessageQueue, fromStore bool) int64 {
+	return pullConsumer.defaultMQPullConsumerImpl.fetchConsumeOffset(mq, fromStore)
+}
@@ -67,7 +67,7 @@ func (pullImpl*DefaultMQPullConsumerImpl)Start() {
 		if pullImpl.defaultMQPullConsumer.messageModel == heartbeat.CLUSTERING {
 			pullImpl.defaultMQPullConsumer.clientConfig.ChangeInstanceNameToPID()
 		}
-		pullImpl.mQClientFactory = GetInstance().GetAndCreateMQClientInstance(pullImpl.defaultMQPullConsumer.clientConfig)
+		pullImpl.mQClientFactory = GetInstance().GetAndCreateMQClientInstanceByHook(pullImpl.defaultMQPullConsumer.clientConfig, pullImpl.defaultMQPullConsumer.rpcHook)
 		var pullReImpl *RebalancePullImpl = pullImpl.RebalanceImpl.(*RebalancePullImpl)
 		pullReImpl.ConsumerGroup = pullImpl.defaultMQPullConsumer.consumerGroup
 		pullReImpl.MessageModel = pullImpl.defaultMQPullConsumer.messageModel
@@ -201,6 +201,28 @@ func (pullImpl *DefaultMQPullConsumerImpl)fetchSubscribeMessageQueues(topic stri
 	return pullImpl.mQClientFactory.MQAdminImpl.FetchSubscribeMessageQueues(topic)
 }
 
+// 查询队列最大offset
+func (pullImpl *DefaultMQPullConsumerImpl) maxOffset(mq *message.MessageQueue) int64 {
+	pullImpl.makeSureStateOK()
+	return pullImpl.mQClientFactory.MQAdminImpl.MaxOffset(mq)
+}
+
+// 更新消费进度并持久化
+func (pullImpl *DefaultMQPullConsumerImpl) updateConsumeOffset(mq *message.MessageQueue, offset int64) {
+	pullImpl.makeSureStateOK()
+	pullImpl.OffsetStore.UpdateOffset(mq, offset, false)
+	pullImpl.OffsetStore.Persist(mq)
+}
+
+// 查询消费进度
+func (pullImpl *DefaultMQPullConsumerImpl) fetchConsumeOffset(mq *message.MessageQueue, fromStore bool) int64 {
+	pullImpl.makeSureStateOK()
+	if fromStore {
+		return pullImpl.OffsetStore.ReadOffset(mq, store.READ_FROM_STORE)
+	}
+	return pullImpl.OffsetStore.ReadOffset(mq, store.READ_FROM_MEMORY)
+}
+
 // 拉取消息
 func (pullImpl*DefaultMQPullConsumerImpl)pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (*consumer.PullResult,error) {
 	return pullImpl.pullSyncImpl(mq, subExpression, offset, maxNums, false, pullImpl.defaultMQPullConsumer.consumerPullTimeoutMillis)
@@ -224,7 +246,7 @@ func (pullImpl*DefaultMQPullConsumerImpl)pullSyncImpl(mq *message.MessageQueue,
 	} else {
 		timeoutMillis = timeout
 	}
-	pullResultExt := pullImpl.pullAPIWrapper.PullKernelImpl(mq, subData.SubString, 0, offset, maxNums, sysFlag, 0,
+	pullResultExt := pullImpl.pullAPIWrapper.PullKernelImpl(mq, subData.SubString, subData.ExpressionType, 0, offset, maxNums, sysFlag, 0,
 		pullImpl.defaultMQPullConsumer.brokerSuspendMaxTimeMillis, timeoutMillis, SYNC, nil)
 	if pullResultExt!=nil {
 		return pullImpl.pullAPIWrapper.processPullResult(mq, pullResultExt, subData).PullResult,nil
@@ -5,7 +5,10 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
 	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/rebalance"
 	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/store"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/filter"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
 )
 
 // DefaultMQPushConsumer: push消费
@@ -55,6 +58,10 @@ type DefaultMQPushConsumer struct {
 	// Whether the unit of subscription group
 	unitMode     bool
 	clientConfig *stgclient.ClientConfig
+	// RPC hook, such as acl signature
+	rpcHook remoting.RPCHook
+	// Message trace hook
+	traceHook *ClientTraceHook
 }
 
 // 创建push消费结构体
@@ -74,6 +81,13 @@ func NewDefaultMQPushConsumer(consumerGroup string) *DefaultMQPushConsumer {
 	return pushConsumer
 }
 
+// 创建带rpcHook的push消费结构体，如ACL签名: acl.NewAclClientRPCHook(accessKey, secretKey)
+func NewCustomMQPushConsumer(consumerGroup string, rpcHook remoting.RPCHook) *DefaultMQPushConsumer {
+	pushConsumer := NewDefaultMQPushConsumer(consumerGroup)
+	pushConsumer.rpcHook = rpcHook
+	return pushConsumer
+}
+
 // 设置从哪个位置开始消费
 func (pushConsumer *DefaultMQPushConsumer) SetConsumeFromWhere(consumeFromWhere heartbeat.ConsumeFromWhere) {
 	pushConsumer.consumeFromWhere = consumeFromWhere
@@ -94,6 +108,11 @@ func (pushConsumer *DefaultMQPushConsumer) Subscribe(topic string, subExpression
 	pushConsumer.defaultMQPushConsumerImpl.subscribe(topic, subExpression)
 }
 
+// 按SQL92表达式订阅topic，如 region = 'eu' AND firmware > 3，由broker根据消息属性过滤
+func (pushConsumer *DefaultMQPushConsumer) SubscribeBySql(topic string, expression string) error {
+	return pushConsumer.defaultMQPushConsumerImpl.subscribeByType(topic, expression, filter.EXPRESSION_TYPE_SQL92)
+}
+
 // 注册监听器
 func (pushConsumer *DefaultMQPushConsumer) RegisterMessageListener(messageListener listener.MessageListener) {
 	pushConsumer.messageListener = messageListener
@@ -103,9 +122,29 @@ func (pushConsumer *DefaultMQPushConsumer) RegisterMessageListener(messageListen
 // 启动消费服务
 func (pushConsumer *DefaultMQPushConsumer) Start() {
 	pushConsumer.defaultMQPushConsumerImpl.Start()
+	if pushConsumer.traceHook != nil {
+		pushConsumer.traceHook.Start(pushConsumer.clientConfig)
+	}
+}
+
+// 开启消息轨迹，须在Start()之前调用，traceTopic为空时使用默认轨迹topic
+func (pushConsumer *DefaultMQPushConsumer) EnableMsgTrace(traceTopic string) {
+	if pushConsumer.traceHook != nil {
+		return
+	}
+	pushConsumer.traceHook = NewClientTraceHook(pushConsumer.consumerGroup, traceTopic, pushConsumer.rpcHook)
+	pushConsumer.defaultMQPushConsumerImpl.RegisterConsumeMessageHook(pushConsumer.traceHook)
 }
 
 // 关闭消费服务
 func (pushConsumer *DefaultMQPushConsumer) Shutdown() {
 	pushConsumer.defaultMQPushConsumerImpl.Shutdown()
+	if pushConsumer.traceHook != nil {
+		pushConsumer.traceHook.Shutdown()
+	}
+}
+
+// 发送应答消息给request的请求方
+func (pushConsumer *DefaultMQPushConsumer) SendReply(requestMsg *message.MessageExt, body []byte, timeoutMillis int64) error {
+	return sendReplyMessage(pushConsumer.defaultMQPushConsumerImpl.mQClientFactory, pushConsumer.consumerGroup, requestMsg, body, timeoutMillis)
 }
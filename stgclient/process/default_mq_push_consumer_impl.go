@@ -16,6 +16,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	set "github.com/deckarep/golang-set"
 )
 
@@ -41,6 +42,7 @@ type DefaultMQPushConsumerImpl struct {
 	ConsumerTimeoutMillisWhenSuspend  int
 	flowControlTimes1                 int64
 	flowControlTimes2                 int64
+	consumeMessageHookList            []ConsumeMessageHook
 }
 
 func NewDefaultMQPushConsumerImpl(defaultMQPushConsumer *DefaultMQPushConsumer) *DefaultMQPushConsumerImpl {
@@ -109,15 +111,18 @@ func (impl *DefaultMQPushConsumerImpl) pullMessage(pullRequest *consumer.PullReq
 		}
 	}
 	var subExpression string
+	var expressionType string
 	var classFilter bool = false
 	sd, _ := impl.rebalanceImpl.(*RebalancePushImpl).rebalanceImplExt.SubscriptionInner.Get(pullRequest.MessageQueue.Topic)
 	// todo class filter
 	if sd != nil {
 		subExpression = sd.(*heartbeat.SubscriptionData).SubString
+		expressionType = sd.(*heartbeat.SubscriptionData).ExpressionType
 	}
 	sysFlag := sysflag.BuildSysFlag(commitOffsetEnable, true, !strings.EqualFold(subExpression, ""), classFilter)
 	impl.pullAPIWrapper.PullKernelImpl(pullRequest.MessageQueue,
 		subExpression,
+		expressionType,
 		subData.(*heartbeat.SubscriptionData).SubVersion,
 		pullRequest.NextOffset,
 		impl.defaultMQPushConsumer.pullBatchSize,
@@ -202,6 +207,20 @@ func (impl *DefaultMQPushConsumerImpl) subscribe(topic string, subExpression str
 	}
 }
 
+// 按表达式类型订阅topic，SQL92表达式语法错误时返回错误
+func (impl *DefaultMQPushConsumerImpl) subscribeByType(topic string, subExpression string, expressionType string) error {
+	subscriptionData, err := filter.BuildSubscriptionDataByType(impl.defaultMQPushConsumer.consumerGroup, topic, subExpression, expressionType)
+	if err != nil {
+		return err
+	}
+	var pushImpl *RebalancePushImpl = impl.rebalanceImpl.(*RebalancePushImpl)
+	pushImpl.rebalanceImplExt.SubscriptionInner.Put(topic, subscriptionData)
+	if impl.mQClientFactory != nil {
+		impl.mQClientFactory.SendHeartbeatToAllBrokerWithLock()
+	}
+	return nil
+}
+
 // 注册监听器
 func (pushConsumerImpl *DefaultMQPushConsumerImpl) registerMessageListener(messageListener listener.MessageListener) {
 	pushConsumerImpl.messageListenerInner = messageListener
@@ -219,7 +238,7 @@ func (pushConsumerImpl *DefaultMQPushConsumerImpl) Start() {
 		if pushConsumerImpl.defaultMQPushConsumer.messageModel == heartbeat.CLUSTERING {
 			pushConsumerImpl.defaultMQPushConsumer.clientConfig.ChangeInstanceNameToPID()
 		}
-		pushConsumerImpl.mQClientFactory = GetInstance().GetAndCreateMQClientInstance(pushConsumerImpl.defaultMQPushConsumer.clientConfig)
+		pushConsumerImpl.mQClientFactory = GetInstance().GetAndCreateMQClientInstanceByHook(pushConsumerImpl.defaultMQPushConsumer.clientConfig, pushConsumerImpl.defaultMQPushConsumer.rpcHook)
 
 		var pushReImpl *RebalancePushImpl = pushConsumerImpl.rebalanceImpl.(*RebalancePushImpl)
 		pushReImpl.rebalanceImplExt.ConsumerGroup = pushConsumerImpl.defaultMQPushConsumer.consumerGroup
@@ -451,3 +470,27 @@ func (pushConsumerImpl *DefaultMQPushConsumerImpl) IsSubscribeTopicNeedUpdate(to
 	}
 	return false
 }
+
+// 注册消费消息回调，如消息轨迹
+func (pushConsumerImpl *DefaultMQPushConsumerImpl) RegisterConsumeMessageHook(hook ConsumeMessageHook) {
+	pushConsumerImpl.consumeMessageHookList = append(pushConsumerImpl.consumeMessageHookList, hook)
+	logger.Infof("register consumeMessage Hook, %s", hook.HookName())
+}
+
+func (pushConsumerImpl *DefaultMQPushConsumerImpl) hasConsumeMessageHook() bool {
+	return len(pushConsumerImpl.consumeMessageHookList) > 0
+}
+
+func (pushConsumerImpl *DefaultMQPushConsumerImpl) executeConsumeMessageHookBefore(context *ConsumeMessageContext) {
+	defer utils.RecoveredFn()
+	for _, hook := range pushConsumerImpl.consumeMessageHookList {
+		hook.ConsumeMessageBefore(context)
+	}
+}
+
+func (pushConsumerImpl *DefaultMQPushConsumerImpl) executeConsumeMessageHookAfter(context *ConsumeMessageContext) {
+	defer utils.RecoveredFn()
+	for _, hook := range pushConsumerImpl.consumeMessageHookList {
+		hook.ConsumeMessageAfter(context)
+	}
+}
@@ -0,0 +1,104 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+)
+
+// DeviceSendResult 推送给设备的发送结果
+// Author: agent
+// Since: 2026/10/19
+type DeviceSendResult struct {
+	*SendResult
+	DeviceId string
+	NodeName string // 设备所在的网关节点，设备不在线时为空
+	Online   bool   // false表示设备不在线，QoS1/QoS2消息由网关写入设备的持久会话，设备重连后投递
+}
+
+// DeviceMessageProducer 点对点推送：按设备ID向namesrv查询设备所在的网关节点，
+// 以节点名称为tag将消息发送到设备Topic，由该节点写入设备的会话；设备不在线时以DEVICE_OFFLINE_TAG按设备ID选择队列发送，
+// 同一设备的离线消息由消费该队列的网关节点写入设备的持久会话。
+//
+// 注意：设备不在线时QoS0消息，以及没有持久会话(以clean session连接或会话已过期)的设备的消息会被网关丢弃
+//
+// Author: agent
+// Since: 2026/10/19
+type DeviceMessageProducer struct {
+	*DefaultMQProducer
+	DeviceTopic string // 设备消息Topic，须与网关配置的DeviceTopic一致
+}
+
+// NewDeviceMessageProducer 创建点对点推送的producer，deviceTopic为空时使用DEVICE_MESSAGE_TOPIC
+// Author: agent
+// Since: 2026/10/19
+func NewDeviceMessageProducer(producerGroup, deviceTopic string) *DeviceMessageProducer {
+	return newDeviceMessageProducer(NewDefaultMQProducer(producerGroup), deviceTopic)
+}
+
+// NewCustomDeviceMessageProducer 创建带rpcHook的点对点推送producer，如ACL签名
+// Author: agent
+// Since: 2026/10/19
+func NewCustomDeviceMessageProducer(producerGroup, deviceTopic string, rpcHook remoting.RPCHook) *DeviceMessageProducer {
+	return newDeviceMessageProducer(NewCustomMQProducer(producerGroup, rpcHook), deviceTopic)
+}
+
+func newDeviceMessageProducer(producer *DefaultMQProducer, deviceTopic string) *DeviceMessageProducer {
+	if strings.TrimSpace(deviceTopic) == "" {
+		deviceTopic = stgcommon.DEVICE_MESSAGE_TOPIC
+	}
+	return &DeviceMessageProducer{DefaultMQProducer: producer, DeviceTopic: deviceTopic}
+}
+
+// QueryDeviceRoute 批量查询设备所在的网关节点，不在线的设备不在结果中
+// Author: agent
+// Since: 2026/10/19
+func (producer *DeviceMessageProducer) QueryDeviceRoute(deviceIds ...string) (map[string]string, error) {
+	factory := producer.DefaultMQProducerImpl.MQClientFactory
+	if factory == nil {
+		return nil, fmt.Errorf("query device route failed, the producer is not started")
+	}
+	return factory.MQClientAPIImpl.QueryDeviceRoute(deviceIds, producer.SendMsgTimeout)
+}
+
+// SendToDevice 将消息推送给指定设备，msg.Topic与tag由路由结果覆盖，设备ID写入消息key及DEVICE_ID属性
+// Author: agent
+// Since: 2026/10/19
+func (producer *DeviceMessageProducer) SendToDevice(deviceId string, msg *message.Message) (*DeviceSendResult, error) {
+	if strings.TrimSpace(deviceId) == "" {
+		return nil, fmt.Errorf("send to device failed, the deviceId is empty")
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("send to device failed, the message is nil")
+	}
+
+	routes, err := producer.QueryDeviceRoute(deviceId)
+	if err != nil {
+		return nil, fmt.Errorf("send to device %s failed, query device route err: %s", deviceId, err.Error())
+	}
+	nodeName, online := routes[deviceId]
+
+	msg.Topic = producer.DeviceTopic
+	if online {
+		msg.SetTags(nodeName)
+	} else {
+		msg.SetTags(stgcommon.DEVICE_OFFLINE_TAG)
+	}
+	msg.SetKeys(deviceId)
+	msg.PutProperty(message.PROPERTY_DEVICE_ID, deviceId)
+	msg.ClearProperty(message.PROPERTY_DEVICE_HOPS)
+
+	var sendResult *SendResult
+	if online {
+		sendResult, err = producer.Send(msg)
+	} else {
+		sendResult, err = producer.SendByShardingKey(msg, deviceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceSendResult{SendResult: sendResult, DeviceId: deviceId, NodeName: nodeName, Online: online}, nil
+}
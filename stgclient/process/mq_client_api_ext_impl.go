@@ -11,6 +11,8 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header/namesrv"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
 	set "github.com/deckarep/golang-set"
@@ -55,6 +57,48 @@ func (impl MQClientAPIImpl) ViewMessage(storeHost string, physicOffset uint64, t
 	return messageExt, nil
 }
 
+// QueryMessage 根据消息key在指定broker上查询消息，未查询到时返回空结果
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) QueryMessage(addr string, requestHeader *header.QueryMessageRequestHeader, timeoutMills int64) (*admin.QueryResult, error) {
+	topic := requestHeader.Topic
+	if !stgcommon.IsEmpty(impl.ProjectGroupPrefix) {
+		requestHeader.Topic = stgclient.BuildWithProjectGroup(topic, impl.ProjectGroupPrefix)
+	}
+	request := protocol.CreateRequestCommand(code.QUERY_MESSAGE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(addr, request, timeoutMills)
+	requestHeader.Topic = topic
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("QueryMessage response is nil")
+	}
+	if response.Code == code.QUERY_NOT_FOUND {
+		return admin.NewQueryResult(0, make([]*message.MessageExt, 0)), nil
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("QueryMessage failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+
+	responseHeader := &header.QueryMessageResponseHeader{}
+	err = response.DecodeCommandCustomHeader(responseHeader)
+	if err != nil {
+		return nil, err
+	}
+	messageList, err := message.DecodesMessageExt(response.Body, true)
+	if err != nil {
+		return nil, err
+	}
+	if !stgcommon.IsEmpty(impl.ProjectGroupPrefix) {
+		for _, messageExt := range messageList {
+			messageExt.Topic = stgclient.ClearProjectGroup(messageExt.Topic, impl.ProjectGroupPrefix)
+		}
+	}
+	return admin.NewQueryResult(responseHeader.IndexLastUpdateTimestamp, messageList), nil
+}
+
 // CreateCustomTopic 创建指定Topic
 // Author: tianyuliang
 // Since: 2017/11/1
@@ -464,6 +508,54 @@ func (impl *MQClientAPIImpl) DeleteSubscriptionGroup(brokerAddr, groupName strin
 	return nil
 }
 
+// CreateSubscriptionGroup 创建或更新订阅组配置
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) CreateSubscriptionGroup(brokerAddr string, config *subscription.SubscriptionGroupConfig, timeoutMillis int64) error {
+	groupConfig := *config
+	if !stgcommon.IsEmpty(impl.ProjectGroupPrefix) {
+		groupConfig.GroupName = stgclient.BuildWithProjectGroup(config.GroupName, impl.ProjectGroupPrefix)
+	}
+	request := protocol.CreateRequestCommand(code.UPDATE_AND_CREATE_SUBSCRIPTIONGROUP)
+	request.Body = stgcommon.Encode(&groupConfig)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("CreateSubscriptionGroup response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("CreateSubscriptionGroup failed. %s", response.ToString())
+		return fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	return nil
+}
+
+// GetAllSubscriptionGroup 查询broker上所有订阅组配置
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) GetAllSubscriptionGroup(brokerAddr string, timeoutMillis int64) (*subscription.SubscriptionGroupTable, error) {
+	request := protocol.CreateRequestCommand(code.GET_ALL_SUBSCRIPTIONGROUP_CONFIG)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("GetAllSubscriptionGroup response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("GetAllSubscriptionGroup failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+
+	subscriptionGroupTable := subscription.NewSubscriptionGroupTable()
+	if err := stgcommon.Decode(response.Body, subscriptionGroupTable); err != nil {
+		return nil, err
+	}
+	return subscriptionGroupTable, nil
+}
+
 // InvokeBrokerToGetConsumerStatus 反向查找broker中的consume状态
 // Author: tianyuliang
 // Since: 2017/11/3
@@ -664,3 +756,303 @@ func (impl *MQClientAPIImpl) QueryConsumeTimeSpan(brokerAddr, topic, consumerGro
 func (impl *MQClientAPIImpl) GetNameServerAddressList() []string {
 	return impl.DefalutRemotingClient.GetNameServerAddressList()
 }
+
+// QueryDLQMessage 从broker的死信队列queueId的offset位置开始查询最多maxNums条死信消息
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) QueryDLQMessage(brokerAddr, consumerGroup string, queueId int32, offset int64, maxNums int32, timeoutMillis int64) (*body.DLQMessageList, error) {
+	requestHeader := &header.QueryDLQMessageRequestHeader{ConsumerGroup: consumerGroup, QueueId: queueId, Offset: offset, MaxNums: maxNums}
+	request := protocol.CreateRequestCommand(code.QUERY_DLQ_MESSAGE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("QueryDLQMessage response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("QueryDLQMessage failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	messageList := body.NewDLQMessageList()
+	err = messageList.CustomDecode(response.Body, messageList)
+	if err != nil {
+		return nil, err
+	}
+	return messageList, nil
+}
+
+// ResendDLQMessage 将broker上的死信消息重新投递到原始Topic，msgIds为空表示全部投递
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) ResendDLQMessage(brokerAddr, consumerGroup string, msgIds []string, timeoutMillis int64) (*body.DLQResendResult, error) {
+	requestHeader := &header.ResendDLQMessageRequestHeader{ConsumerGroup: consumerGroup, MsgIds: strings.Join(msgIds, ",")}
+	request := protocol.CreateRequestCommand(code.RESEND_DLQ_MESSAGE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("ResendDLQMessage response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("ResendDLQMessage failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	result := body.NewDLQResendResult()
+	err = result.CustomDecode(response.Body, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PurgeDLQMessage 清除broker上offset或timestamp之前的死信消息
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) PurgeDLQMessage(brokerAddr, consumerGroup string, offset, timestamp int64, timeoutMillis int64) (*body.DLQPurgeResult, error) {
+	requestHeader := &header.PurgeDLQMessageRequestHeader{ConsumerGroup: consumerGroup, Offset: offset, Timestamp: timestamp}
+	request := protocol.CreateRequestCommand(code.PURGE_DLQ_MESSAGE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("PurgeDLQMessage response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("PurgeDLQMessage failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	result := body.NewDLQPurgeResult()
+	err = result.CustomDecode(response.Body, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateQuotaConfig 创建或更新broker上的收发配额
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) UpdateQuotaConfig(brokerAddr string, config *quota.QuotaConfig, timeoutMillis int64) error {
+	quotaConfig := *config
+	quotaConfig.ResourceName = impl.quotaResourceName(config.ResourceType, config.ResourceName)
+	request := protocol.CreateRequestCommand(code.UPDATE_QUOTA_CONFIG)
+	request.Body = stgcommon.Encode(&quotaConfig)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("UpdateQuotaConfig response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("UpdateQuotaConfig failed. %s", response.ToString())
+		return fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	return nil
+}
+
+// GetAllQuotaConfig 查询broker上的全部收发配额
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) GetAllQuotaConfig(brokerAddr string, timeoutMillis int64) (*quota.QuotaConfigTable, error) {
+	request := protocol.CreateRequestCommand(code.GET_ALL_QUOTA_CONFIG)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("GetAllQuotaConfig response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("GetAllQuotaConfig failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+
+	quotaConfigTable := quota.NewQuotaConfigTable()
+	if err := stgcommon.Decode(response.Body, quotaConfigTable); err != nil {
+		return nil, err
+	}
+	return quotaConfigTable, nil
+}
+
+// DeleteQuotaConfig 删除broker上的收发配额
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) DeleteQuotaConfig(brokerAddr, resourceType, resourceName string, timeoutMillis int64) error {
+	requestHeader := &header.DeleteQuotaConfigRequestHeader{
+		ResourceType: resourceType,
+		ResourceName: impl.quotaResourceName(resourceType, resourceName),
+	}
+	request := protocol.CreateRequestCommand(code.DELETE_QUOTA_CONFIG, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("DeleteQuotaConfig response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("DeleteQuotaConfig failed. %s", response.ToString())
+		return fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	return nil
+}
+
+// quotaResourceName 生产组、消费组配额需要带上项目组前缀
+func (impl *MQClientAPIImpl) quotaResourceName(resourceType, resourceName string) string {
+	if stgcommon.IsEmpty(impl.ProjectGroupPrefix) {
+		return resourceName
+	}
+	if resourceType == quota.RESOURCE_PRODUCER_GROUP || resourceType == quota.RESOURCE_CONSUMER_GROUP {
+		return stgclient.BuildWithProjectGroup(resourceName, impl.ProjectGroupPrefix)
+	}
+	return resourceName
+}
+
+// UpdateBrokerConfig 动态修改broker配置，返回实际发生变化的配置项
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) UpdateBrokerConfig(brokerAddr string, properties map[string]interface{}, timeoutMillis int64) (*body.UpdateBrokerConfigResult, error) {
+	if len(properties) == 0 {
+		return nil, fmt.Errorf("broker config properties is empty")
+	}
+	request := protocol.CreateRequestCommand(code.UPDATE_BROKER_CONFIG)
+	request.Body = stgcommon.Encode(properties)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("UpdateBrokerConfig response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("UpdateBrokerConfig failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+
+	result := body.NewUpdateBrokerConfigResult()
+	if response.Body != nil && len(response.Body) > 0 {
+		if err = result.CustomDecode(response.Body, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// DrainBroker 通知broker开始优雅下线，下线完成后broker进程退出
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) DrainBroker(brokerAddr string, timeoutMillis int64) error {
+	request := protocol.CreateRequestCommand(code.DRAIN_BROKER)
+	response, err := impl.DefalutRemotingClient.InvokeSync(brokerAddr, request, timeoutMillis)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("DrainBroker response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("DrainBroker failed. %s", response.ToString())
+		return fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	return nil
+}
+
+// RegisterDeviceRoute 网关节点向指定namesrv注册、注销设备连接并续约节点租约
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) RegisterDeviceRoute(namesrvAddr string, requestHeader *namesrv.RegisterDeviceRouteRequestHeader, registerBody *body.DeviceRouteRegisterBody, timeoutMillis int64) (*namesrv.RegisterDeviceRouteResponseHeader, error) {
+	request := protocol.CreateRequestCommand(code.REGISTER_DEVICE_ROUTE, requestHeader)
+	request.Body = registerBody.CustomEncode(registerBody)
+	response, err := impl.DefalutRemotingClient.InvokeSync(namesrvAddr, request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("RegisterDeviceRoute response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("RegisterDeviceRoute failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+
+	responseHeader := &namesrv.RegisterDeviceRouteResponseHeader{}
+	err = response.DecodeCommandCustomHeader(responseHeader)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterDeviceRouteResponseHeader Decode err: %s", err.Error())
+	}
+	return responseHeader, nil
+}
+
+// UnRegisterDeviceNode 网关节点从指定namesrv下线，删除该节点的全部设备路由
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) UnRegisterDeviceNode(namesrvAddr, nodeName string, timeoutMillis int64) error {
+	requestHeader := &namesrv.UnRegisterDeviceNodeRequestHeader{NodeName: nodeName}
+	request := protocol.CreateRequestCommand(code.UNREGISTER_DEVICE_NODE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(namesrvAddr, request, timeoutMillis)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("UnRegisterDeviceNode response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("UnRegisterDeviceNode failed. %s", response.ToString())
+		return fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	return nil
+}
+
+// QueryDeviceRoute 批量查询设备所在的网关节点，未注册的设备不在结果中
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) QueryDeviceRoute(deviceIds []string, timeoutMillis int64) (map[string]string, error) {
+	queryBody := body.NewDeviceRouteQueryBody(deviceIds)
+	request := protocol.CreateRequestCommand(code.QUERY_DEVICE_ROUTE)
+	request.Body = queryBody.CustomEncode(queryBody)
+	response, err := impl.DefalutRemotingClient.InvokeSync("", request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("QueryDeviceRoute response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("QueryDeviceRoute failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	routeTable := body.NewDeviceRouteTable()
+	err = routeTable.CustomDecode(response.Body, routeTable)
+	if err != nil {
+		return nil, err
+	}
+	return routeTable.Routes, nil
+}
+
+// GetDeviceNodeList 获取注册到namesrv的网关节点及设备数
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) GetDeviceNodeList(timeoutMillis int64) (*body.DeviceNodeList, error) {
+	request := protocol.CreateRequestCommand(code.GET_DEVICE_NODE_LIST)
+	response, err := impl.DefalutRemotingClient.InvokeSync("", request, timeoutMillis)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, fmt.Errorf("GetDeviceNodeList response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		logger.Errorf("GetDeviceNodeList failed. %s", response.ToString())
+		return nil, fmt.Errorf("%d, %s", response.Code, response.Remark)
+	}
+	nodeList := body.NewDeviceNodeList()
+	err = nodeList.CustomDecode(response.Body, nodeList)
+	if err != nil {
+		return nil, err
+	}
+	return nodeList, nil
+}
@@ -15,6 +15,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header/namesrv"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/route"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
 	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
@@ -36,6 +37,8 @@ func NewMQClientAPIImpl(clientRemotingProcessor *ClientRemotingProcessor) *MQCli
 		ClientRemotingProcessor: clientRemotingProcessor,
 	}
 	mClientAPIImpl.DefalutRemotingClient.RegisterProcessor(code.NOTIFY_CONSUMER_IDS_CHANGED, clientRemotingProcessor)
+	mClientAPIImpl.DefalutRemotingClient.RegisterProcessor(code.PUSH_REPLY_MESSAGE_TO_CLIENT, clientRemotingProcessor)
+	mClientAPIImpl.DefalutRemotingClient.RegisterProcessor(code.NOTIFY_BROKER_DRAINING, clientRemotingProcessor)
 	return mClientAPIImpl
 }
 
@@ -81,6 +84,9 @@ func (impl *MQClientAPIImpl) sendHeartbeat(addr string, heartbeatData *heartbeat
 	if response != nil && err == nil {
 		switch response.Code {
 		case code.SUCCESS:
+		default:
+			logger.Errorf("sendHeartbeat to %s failed, code=%d, remark=%s", addr, response.Code, response.Remark)
+			return errors.New(response.Remark)
 		}
 	} else {
 		logger.Errorf("sendHeartbeat error")
@@ -236,6 +242,8 @@ func (impl *MQClientAPIImpl) processSendResponse(brokerName string, msg *message
 			sendResult := NewSendResult(sendStatus, responseHeader.MsgId, messageQueue, responseHeader.QueueOffset, impl.ProjectGroupPrefix)
 			sendResult.TransactionId = responseHeader.TransactionId
 			return sendResult, nil
+		case code.QUOTA_EXCEEDED:
+			return nil, quota.NewQuotaExceededError(response.Remark, response.ExtFields)
 		}
 	} else {
 		return nil, errors.New("processSendResponse error response is nil")
@@ -557,3 +565,126 @@ func (impl *MQClientAPIImpl) GetKVListByNamespace(namespace string, timeoutMilli
 	}
 	return kvTable, nil
 }
+
+// SendReplyMessage 发送应答消息到broker，由broker推送给请求方
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) SendReplyMessage(addr string, requestHeader *header.ReplyMessageRequestHeader, msgBody []byte, timeoutMillis int64) error {
+	request := protocol.CreateRequestCommand(code.SEND_REPLY_MESSAGE, requestHeader)
+	request.Body = msgBody
+	response, err := impl.DefalutRemotingClient.InvokeSync(addr, request, timeoutMillis)
+	if err != nil {
+		return fmt.Errorf("SendReplyMessage err: %s, the request is %s", err.Error(), request.ToString())
+	}
+	if response == nil {
+		return fmt.Errorf("SendReplyMessage response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		return fmt.Errorf("SendReplyMessage failed. %s", response.ToString())
+	}
+	return nil
+}
+
+// PopMessage POP消息，没有新消息时返回空列表；每条消息的POP_CK属性中保存确认消息所需的ReceiptHandle
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) PopMessage(addr, brokerName string, requestHeader *header.PopMessageRequestHeader, timeoutMillis int64) ([]*message.MessageExt, error) {
+	if !strings.EqualFold(impl.ProjectGroupPrefix, "") {
+		requestHeader.ConsumerGroup = stgclient.BuildWithProjectGroup(requestHeader.ConsumerGroup, impl.ProjectGroupPrefix)
+		requestHeader.Topic = stgclient.BuildWithProjectGroup(requestHeader.Topic, impl.ProjectGroupPrefix)
+	}
+	request := protocol.CreateRequestCommand(code.POP_MESSAGE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(addr, request, timeoutMillis)
+	if err != nil {
+		return nil, fmt.Errorf("PopMessage err: %s, the request is %s", err.Error(), request.ToString())
+	}
+	if response == nil {
+		return nil, fmt.Errorf("PopMessage response is nil")
+	}
+
+	switch response.Code {
+	case code.SUCCESS:
+	case code.PULL_NOT_FOUND:
+		return []*message.MessageExt{}, nil
+	case code.QUOTA_EXCEEDED:
+		return nil, quota.NewQuotaExceededError(response.Remark, response.ExtFields)
+	default:
+		return nil, fmt.Errorf("PopMessage failed. %s", response.ToString())
+	}
+
+	responseHeader := &header.PopMessageResponseHeader{}
+	if err := response.DecodeCommandCustomHeader(responseHeader); err != nil {
+		return nil, fmt.Errorf("PopMessage decode response header err: %s", err.Error())
+	}
+	msgList, err := message.DecodesMessageExt(response.Body, true)
+	if err != nil {
+		return nil, fmt.Errorf("PopMessage decode messages err: %s", err.Error())
+	}
+
+	for _, msgExt := range msgList {
+		// 句柄中的topic保持Broker上的名称，确认消息时原样回传
+		handle := &message.ReceiptHandle{
+			PopTime:       responseHeader.PopTime,
+			InvisibleTime: responseHeader.InvisibleTime,
+			QueueId:       msgExt.QueueId,
+			Offset:        msgExt.QueueOffset,
+			BrokerName:    brokerName,
+			Topic:         requestHeader.Topic,
+		}
+		message.PutProperty(&msgExt.Message, message.PROPERTY_POP_CK, handle.Encode())
+		if !strings.EqualFold(impl.ProjectGroupPrefix, "") {
+			msgExt.Topic = stgclient.ClearProjectGroup(msgExt.Topic, impl.ProjectGroupPrefix)
+		}
+	}
+	return msgList, nil
+}
+
+// AckMessage 确认单条POP消息，requestHeader.Topic取自ReceiptHandle
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) AckMessage(addr string, requestHeader *header.AckMessageRequestHeader, timeoutMillis int64) error {
+	if !strings.EqualFold(impl.ProjectGroupPrefix, "") {
+		requestHeader.ConsumerGroup = stgclient.BuildWithProjectGroup(requestHeader.ConsumerGroup, impl.ProjectGroupPrefix)
+	}
+	request := protocol.CreateRequestCommand(code.ACK_MESSAGE, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(addr, request, timeoutMillis)
+	if err != nil {
+		return fmt.Errorf("AckMessage err: %s, the request is %s", err.Error(), request.ToString())
+	}
+	if response == nil {
+		return fmt.Errorf("AckMessage response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		return fmt.Errorf("AckMessage failed. %s", response.ToString())
+	}
+	return nil
+}
+
+// ChangeInvisibleTime 修改单条POP消息的不可见时间，返回新的ReceiptHandle，requestHeader.Topic取自ReceiptHandle
+// Author: agent
+// Since: 2026/10/19
+func (impl *MQClientAPIImpl) ChangeInvisibleTime(addr string, handle *message.ReceiptHandle, requestHeader *header.ChangeInvisibleTimeRequestHeader, timeoutMillis int64) (*message.ReceiptHandle, error) {
+	if !strings.EqualFold(impl.ProjectGroupPrefix, "") {
+		requestHeader.ConsumerGroup = stgclient.BuildWithProjectGroup(requestHeader.ConsumerGroup, impl.ProjectGroupPrefix)
+	}
+	request := protocol.CreateRequestCommand(code.CHANGE_MESSAGE_INVISIBLETIME, requestHeader)
+	response, err := impl.DefalutRemotingClient.InvokeSync(addr, request, timeoutMillis)
+	if err != nil {
+		return nil, fmt.Errorf("ChangeInvisibleTime err: %s, the request is %s", err.Error(), request.ToString())
+	}
+	if response == nil {
+		return nil, fmt.Errorf("ChangeInvisibleTime response is nil")
+	}
+	if response.Code != code.SUCCESS {
+		return nil, fmt.Errorf("ChangeInvisibleTime failed. %s", response.ToString())
+	}
+
+	responseHeader := &header.ChangeInvisibleTimeResponseHeader{}
+	if err := response.DecodeCommandCustomHeader(responseHeader); err != nil {
+		return nil, fmt.Errorf("ChangeInvisibleTime decode response header err: %s", err.Error())
+	}
+	newHandle := *handle
+	newHandle.PopTime = responseHeader.PopTime
+	newHandle.InvisibleTime = responseHeader.InvisibleTime
+	return &newHandle, nil
+}
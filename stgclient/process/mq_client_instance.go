@@ -42,6 +42,7 @@ type MQClientInstance struct {
 	DefaultMQProducer       *DefaultMQProducer
 	ServiceState            stgcommon.ServiceState
 	TimerTask               set.Set
+	RequestFutureTable      *RequestFutureTable // correlationId<*RequestResponseFuture>
 }
 
 // NewMQClientInstance: 初始化
@@ -61,6 +62,13 @@ func NewMQClientInstance(clientConfig *stgclient.ClientConfig, instanceIndex int
 	}
 	mqClientInstance.ClientRemotingProcessor = NewClientRemotingProcessor(mqClientInstance)
 	mqClientInstance.MQClientAPIImpl = NewMQClientAPIImpl(mqClientInstance.ClientRemotingProcessor)
+	if clientConfig.TLSOptions != nil {
+		if err := mqClientInstance.MQClientAPIImpl.DefalutRemotingClient.SetTLSOptions(clientConfig.TLSOptions); err != nil {
+			logger.Errorf("set client tls options err: %s", err.Error())
+		} else {
+			logger.Infof("client tls enabled, mode=%s", clientConfig.TLSOptions.Mode)
+		}
+	}
 	if !strings.EqualFold(mqClientInstance.ClientConfig.NamesrvAddr, "") {
 		mqClientInstance.MQClientAPIImpl.UpdateNameServerAddressList(mqClientInstance.ClientConfig.NamesrvAddr)
 		logger.Infof("user specified name server address: %v", mqClientInstance.ClientConfig.NamesrvAddr)
@@ -68,6 +76,7 @@ func NewMQClientInstance(clientConfig *stgclient.ClientConfig, instanceIndex int
 	mqClientInstance.MQAdminImpl = NewMQAdminImpl(mqClientInstance)
 	mqClientInstance.PullMessageService = NewPullMessageService(mqClientInstance)
 	mqClientInstance.RebalanceService = NewRebalanceService(mqClientInstance)
+	mqClientInstance.RequestFutureTable = NewRequestFutureTable()
 	mqClientInstance.DefaultMQProducer = NewDefaultMQProducer(stgcommon.CLIENT_INNER_PRODUCER_GROUP)
 	mqClientInstance.DefaultMQProducer.ClientConfig.ResetClientConfig(clientConfig)
 	//todo 消费统计管理器初始化
@@ -268,6 +277,12 @@ func (mqClientInstance *MQClientInstance) StartScheduledTask() {
 	})
 	persistOffsetTicker.Start()
 	mqClientInstance.TimerTask.Add(persistOffsetTicker)
+	// 定时清理超时未收到应答的request
+	scanRequestTicker := timeutil.NewTicker(true, 3000*time.Millisecond, 1000*time.Millisecond, func() {
+		mqClientInstance.RequestFutureTable.ScanExpired()
+	})
+	scanRequestTicker.Start()
+	mqClientInstance.TimerTask.Add(scanRequestTicker)
 	//todo 定时调整线程池的数量
 }
 
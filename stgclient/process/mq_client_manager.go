@@ -4,6 +4,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgclient"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	syncMap "git.oschina.net/cloudzone/smartgo/stgcommon/sync"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
 	"sync"
 	"sync/atomic"
 )
@@ -38,10 +39,19 @@ func GetInstance() *MQClientManager {
 
 // 从集合中查询MQClientInstance，无则创建一个
 func (mQClientManager *MQClientManager) GetAndCreateMQClientInstance(clientConfig *stgclient.ClientConfig) *MQClientInstance {
+	return mQClientManager.GetAndCreateMQClientInstanceByHook(clientConfig, nil)
+}
+
+// 从集合中查询MQClientInstance，无则创建一个并注册rpcHook(如ACL签名)，同一clientId共享首次创建时的rpcHook
+func (mQClientManager *MQClientManager) GetAndCreateMQClientInstanceByHook(clientConfig *stgclient.ClientConfig, rpcHook remoting.RPCHook) *MQClientInstance {
 	clientId := clientConfig.BuildMQClientId()
 	instance, _ := mQClientManager.FactoryTable.Get(clientId)
 	if nil == instance {
-		instance = NewMQClientInstance(clientConfig.CloneClientConfig(), atomic.AddInt32(&mQClientManager.FactoryIndexGenerator, 1), clientId)
+		mqClientInstance := NewMQClientInstance(clientConfig.CloneClientConfig(), atomic.AddInt32(&mQClientManager.FactoryIndexGenerator, 1), clientId)
+		if rpcHook != nil {
+			mqClientInstance.MQClientAPIImpl.DefalutRemotingClient.RegisterRPCHook(rpcHook)
+		}
+		instance = mqClientInstance
 		prev, _ := mQClientManager.FactoryTable.PutIfAbsent(clientId, instance)
 		if prev != nil {
 			instance = prev
@@ -17,4 +17,8 @@ type MQProducer interface {
 	SendOneWay(msg *message.Message) error
 	// 异步发送
 	SendCallBack(msg *message.Message,callback SendCallback) error
+	// 同步request，等待应答
+	Request(msg *message.Message, timeout int64) (*message.MessageExt, error)
+	// 异步request
+	RequestCallBack(msg *message.Message, callback RequestCallback, timeout int64) error
 }
@@ -38,6 +38,7 @@ func NewPullAPIWrapper(mQClientFactory *MQClientInstance, consumerGroup string,
 
 func (api *PullAPIWrapper) PullKernelImpl(mq *message.MessageQueue,
 	subExpression string,
+	expressionType string,
 	subVersion int,
 	offset int64,
 	maxNums int,
@@ -67,7 +68,8 @@ func (api *PullAPIWrapper) PullKernelImpl(mq *message.MessageQueue,
 			CommitOffset:         commitOffset,
 			SuspendTimeoutMillis: brokerSuspendMaxTimeMillis,
 			Subscription:         subExpression,
-			SubVersion:           subVersion}
+			SubVersion:           subVersion,
+			ExpressionType:       expressionType}
 		brokerAddr := findBrokerResult.brokerAddr
 		//todo filter处理
 		pullResultExt := api.mQClientFactory.MQClientAPIImpl.PullMessage(brokerAddr, requestHeader, timeoutMillis, communicationMode, pullCallback)
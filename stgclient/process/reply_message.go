@@ -0,0 +1,61 @@
+package process
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgclient"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"strconv"
+)
+
+// CreateReplyMessage 根据request消息创建应答消息，复制关联ID、请求方clientId及截止时间
+// Author: agent
+// Since:  2026/10/19
+func CreateReplyMessage(requestMsg *message.MessageExt, body []byte) (*message.Message, error) {
+	if requestMsg == nil {
+		return nil, fmt.Errorf("create reply message failed, the request message is nil")
+	}
+	correlationId := requestMsg.GetProperty(message.PROPERTY_CORRELATION_ID)
+	replyToClient := requestMsg.GetProperty(message.PROPERTY_MESSAGE_REPLY_TO_CLIENT)
+	if correlationId == "" || replyToClient == "" {
+		return nil, fmt.Errorf("create reply message failed, the message %s is not a request message", requestMsg.MsgId)
+	}
+
+	replyMsg := &message.Message{Topic: requestMsg.Topic, Body: body}
+	replyMsg.PutProperty(message.PROPERTY_MESSAGE_TYPE, message.REPLY_MESSAGE_FLAG)
+	replyMsg.PutProperty(message.PROPERTY_CORRELATION_ID, correlationId)
+	replyMsg.PutProperty(message.PROPERTY_MESSAGE_REPLY_TO_CLIENT, replyToClient)
+	if deadline := requestMsg.GetProperty(message.PROPERTY_REQUEST_DEADLINE); deadline != "" {
+		replyMsg.PutProperty(message.PROPERTY_REQUEST_DEADLINE, deadline)
+	}
+	return replyMsg, nil
+}
+
+// sendReplyMessage 将应答发往存储request消息的broker，由broker直接推送给请求方
+// Author: agent
+// Since:  2026/10/19
+func sendReplyMessage(factory *MQClientInstance, group string, requestMsg *message.MessageExt, body []byte, timeoutMillis int64) error {
+	if factory == nil {
+		return fmt.Errorf("send reply message failed, the client is not started")
+	}
+	replyMsg, err := CreateReplyMessage(requestMsg, body)
+	if err != nil {
+		return err
+	}
+
+	now := timeutil.CurrentTimeMillis()
+	if deadline, err := strconv.ParseInt(replyMsg.GetProperty(message.PROPERTY_REQUEST_DEADLINE), 10, 64); err == nil && now > deadline {
+		return fmt.Errorf("send reply message failed, the request %s has expired", replyMsg.GetProperty(message.PROPERTY_CORRELATION_ID))
+	}
+
+	requestHeader := &header.ReplyMessageRequestHeader{
+		ProducerGroup: group,
+		Topic:         replyMsg.Topic,
+		Flag:          replyMsg.Flag,
+		Properties:    message.MessageProperties2String(replyMsg.Properties),
+		BornHost:      stgclient.GetLocalAddress(),
+		BornTimestamp: now,
+	}
+	return factory.MQClientAPIImpl.SendReplyMessage(requestMsg.StoreHost, requestHeader, replyMsg.Body, timeoutMillis)
+}
@@ -0,0 +1,134 @@
+package process
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"sync"
+	"time"
+)
+
+// RequestCallback 异步request的应答回调，超时或发送失败时reply为nil
+// Author: agent
+// Since:  2026/10/19
+type RequestCallback func(reply *message.MessageExt, err error)
+
+// RequestResponseFuture 一次request等待应答的上下文
+// Author: agent
+// Since:  2026/10/19
+type RequestResponseFuture struct {
+	CorrelationId   string
+	TimeoutMillis   int64
+	BeginTimestamp  int64
+	RequestCallback RequestCallback
+	replyChan       chan *message.MessageExt
+}
+
+// NewRequestResponseFuture 初始化
+// Author: agent
+// Since:  2026/10/19
+func NewRequestResponseFuture(correlationId string, timeoutMillis int64, requestCallback RequestCallback) *RequestResponseFuture {
+	return &RequestResponseFuture{
+		CorrelationId:   correlationId,
+		TimeoutMillis:   timeoutMillis,
+		BeginTimestamp:  timeutil.CurrentTimeMillis(),
+		RequestCallback: requestCallback,
+		replyChan:       make(chan *message.MessageExt, 1),
+	}
+}
+
+// IsTimeout 是否已超过request的超时时间
+func (future *RequestResponseFuture) IsTimeout() bool {
+	return timeutil.CurrentTimeMillis()-future.BeginTimestamp > future.TimeoutMillis
+}
+
+// putResponseMessage 收到应答，异步request执行回调，同步request唤醒等待方
+func (future *RequestResponseFuture) putResponseMessage(reply *message.MessageExt) {
+	if future.RequestCallback != nil {
+		go future.RequestCallback(reply, nil)
+		return
+	}
+	select {
+	case future.replyChan <- reply:
+	default:
+	}
+}
+
+// waitResponseMessage 同步等待应答，超时返回nil
+func (future *RequestResponseFuture) waitResponseMessage(timeoutMillis int64) *message.MessageExt {
+	if timeoutMillis <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(timeoutMillis) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case reply := <-future.replyChan:
+		return reply
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RequestFutureTable correlationId与RequestResponseFuture的映射
+// Author: agent
+// Since:  2026/10/19
+type RequestFutureTable struct {
+	lock  sync.RWMutex
+	table map[string]*RequestResponseFuture
+}
+
+// NewRequestFutureTable 初始化
+// Author: agent
+// Since:  2026/10/19
+func NewRequestFutureTable() *RequestFutureTable {
+	return &RequestFutureTable{table: make(map[string]*RequestResponseFuture)}
+}
+
+// Put 登记一次request
+func (rft *RequestFutureTable) Put(future *RequestResponseFuture) {
+	rft.lock.Lock()
+	defer rft.lock.Unlock()
+	rft.table[future.CorrelationId] = future
+}
+
+// Remove 移除并返回correlationId对应的request，不存在返回nil
+func (rft *RequestFutureTable) Remove(correlationId string) *RequestResponseFuture {
+	rft.lock.Lock()
+	defer rft.lock.Unlock()
+	future, ok := rft.table[correlationId]
+	if !ok {
+		return nil
+	}
+	delete(rft.table, correlationId)
+	return future
+}
+
+// Size 等待应答的request个数
+func (rft *RequestFutureTable) Size() int {
+	rft.lock.RLock()
+	defer rft.lock.RUnlock()
+	return len(rft.table)
+}
+
+// ScanExpired 清理已超时的request，异步request以超时错误回调
+// Author: agent
+// Since:  2026/10/19
+func (rft *RequestFutureTable) ScanExpired() {
+	var expired []*RequestResponseFuture
+	rft.lock.Lock()
+	for correlationId, future := range rft.table {
+		if future.IsTimeout() {
+			delete(rft.table, correlationId)
+			expired = append(expired, future)
+		}
+	}
+	rft.lock.Unlock()
+
+	for _, future := range expired {
+		logger.Warnf("remove timeout request, correlationId=%s, timeoutMillis=%d", future.CorrelationId, future.TimeoutMillis)
+		if future.RequestCallback != nil {
+			go future.RequestCallback(nil, fmt.Errorf("request timeout, correlationId=%s, timeoutMillis=%d", future.CorrelationId, future.TimeoutMillis))
+		}
+	}
+}
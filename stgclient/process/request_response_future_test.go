@@ -0,0 +1,66 @@
+package process
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"testing"
+	"time"
+)
+
+func TestRequestFutureTable_PutAndRemove(t *testing.T) {
+	table := NewRequestFutureTable()
+	future := NewRequestResponseFuture("c1", 3000, nil)
+	table.Put(future)
+
+	reply := &message.MessageExt{}
+	reply.Topic = "test"
+	go table.Remove("c1").putResponseMessage(reply)
+	if got := future.waitResponseMessage(1000); got != reply {
+		t.Fatalf("expect reply message, got %v", got)
+	}
+	if table.Remove("c1") != nil || table.Size() != 0 {
+		t.Fatalf("future should be removed")
+	}
+}
+
+func TestRequestFutureTable_ScanExpired(t *testing.T) {
+	table := NewRequestFutureTable()
+	errChan := make(chan error, 1)
+	table.Put(NewRequestResponseFuture("c1", 1, func(reply *message.MessageExt, err error) {
+		errChan <- err
+	}))
+	table.Put(NewRequestResponseFuture("c2", 60000, nil))
+
+	time.Sleep(5 * time.Millisecond)
+	table.ScanExpired()
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Fatalf("expect timeout error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("callback not invoked")
+	}
+	if table.Size() != 1 {
+		t.Fatalf("expect 1 future left, got %d", table.Size())
+	}
+}
+
+func TestCreateReplyMessage(t *testing.T) {
+	requestMsg := &message.MessageExt{}
+	requestMsg.Topic = "test"
+	if _, err := CreateReplyMessage(requestMsg, nil); err == nil {
+		t.Fatalf("expect error for a non-request message")
+	}
+
+	requestMsg.PutProperty(message.PROPERTY_CORRELATION_ID, "c1")
+	requestMsg.PutProperty(message.PROPERTY_MESSAGE_REPLY_TO_CLIENT, "client1")
+	replyMsg, err := CreateReplyMessage(requestMsg, []byte("pong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replyMsg.GetProperty(message.PROPERTY_CORRELATION_ID) != "c1" ||
+		replyMsg.GetProperty(message.PROPERTY_MESSAGE_REPLY_TO_CLIENT) != "client1" ||
+		replyMsg.GetProperty(message.PROPERTY_MESSAGE_TYPE) != message.REPLY_MESSAGE_FLAG {
+		t.Fatalf("unexpected reply properties %v", replyMsg.Properties)
+	}
+}
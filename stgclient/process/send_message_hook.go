@@ -0,0 +1,29 @@
+package process
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// SendMessageContext: 客户端发送消息上下文
+// Author: agent
+// Since:  2026/10/19
+type SendMessageContext struct {
+	ProducerGroup     string
+	Message           *message.Message
+	MessageQueue      *message.MessageQueue
+	BrokerAddr        string
+	BornHost          string
+	CommunicationMode CommunicationMode
+	BeginTimestamp    int64
+	SendResult        *SendResult
+	Err               error
+}
+
+// SendMessageHook: 客户端发送消息回调，如消息轨迹
+// Author: agent
+// Since:  2026/10/19
+type SendMessageHook interface {
+	HookName() string
+	SendMessageBefore(context *SendMessageContext)
+	SendMessageAfter(context *SendMessageContext)
+}
@@ -0,0 +1,94 @@
+package process
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgclient"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/filter"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+	"time"
+)
+
+// SimpleConsumer: POP方式消费，由Broker在所有队列中分配消息，不需要客户端负载均衡，
+// 消费者数量可以超过队列数量；收到的消息在不可见时间内须逐条Ack，否则会被重新投递
+// Author: agent
+// Since: 2026/10/19
+type SimpleConsumer struct {
+	simpleConsumerImpl *SimpleConsumerImpl
+	// Do the same thing for the same Group, the application must be set,and
+	// guarantee Globally unique
+	consumerGroup string
+	// Max wait time of a receive when there is no message, it is not recommended to be greater than 20s
+	awaitDuration time.Duration
+	// The socket timeout in milliseconds, the actual timeout of a receive is awaitDuration plus it
+	consumerPopTimeoutMillis int64
+	clientConfig             *stgclient.ClientConfig
+	// RPC hook, such as acl signature
+	rpcHook remoting.RPCHook
+}
+
+// 创建POP消费结构体
+func NewSimpleConsumer(consumerGroup string) *SimpleConsumer {
+	simpleConsumer := &SimpleConsumer{clientConfig: stgclient.NewClientConfig("")}
+	simpleConsumer.consumerGroup = consumerGroup
+	simpleConsumer.awaitDuration = 5 * time.Second
+	simpleConsumer.consumerPopTimeoutMillis = 1000 * 3
+	simpleConsumer.simpleConsumerImpl = NewSimpleConsumerImpl(simpleConsumer)
+	return simpleConsumer
+}
+
+// 创建带rpcHook的POP消费结构体，如ACL签名: acl.NewAclClientRPCHook(accessKey, secretKey)
+func NewCustomSimpleConsumer(consumerGroup string, rpcHook remoting.RPCHook) *SimpleConsumer {
+	simpleConsumer := NewSimpleConsumer(consumerGroup)
+	simpleConsumer.rpcHook = rpcHook
+	return simpleConsumer
+}
+
+// 设置namesrvaddr
+func (simpleConsumer *SimpleConsumer) SetNamesrvAddr(namesrvAddr string) {
+	simpleConsumer.clientConfig.NamesrvAddr = namesrvAddr
+}
+
+// 设置没有消息时Receive的最长等待时间
+func (simpleConsumer *SimpleConsumer) SetAwaitDuration(awaitDuration time.Duration) {
+	simpleConsumer.awaitDuration = awaitDuration
+}
+
+// 订阅topic和tag
+func (simpleConsumer *SimpleConsumer) Subscribe(topic string, subExpression string) error {
+	return simpleConsumer.simpleConsumerImpl.subscribeByType(topic, subExpression, filter.EXPRESSION_TYPE_TAG)
+}
+
+// 按SQL92表达式订阅topic，如 region = 'eu' AND firmware > 3，由broker根据消息属性过滤
+func (simpleConsumer *SimpleConsumer) SubscribeBySql(topic string, expression string) error {
+	return simpleConsumer.simpleConsumerImpl.subscribeByType(topic, expression, filter.EXPRESSION_TYPE_SQL92)
+}
+
+// 取消订阅topic
+func (simpleConsumer *SimpleConsumer) Unsubscribe(topic string) {
+	simpleConsumer.simpleConsumerImpl.unsubscribe(topic)
+}
+
+// 启动消费服务
+func (simpleConsumer *SimpleConsumer) Start() {
+	simpleConsumer.simpleConsumerImpl.Start()
+}
+
+// 关闭消费服务
+func (simpleConsumer *SimpleConsumer) Shutdown() {
+	simpleConsumer.simpleConsumerImpl.Shutdown()
+}
+
+// 接收最多maxNums条消息，消息在invisibleTime内对同组的其他消费者不可见；没有消息时最多等待awaitDuration，返回空列表
+func (simpleConsumer *SimpleConsumer) Receive(maxNums int, invisibleTime time.Duration) ([]*message.MessageExt, error) {
+	return simpleConsumer.simpleConsumerImpl.receive(maxNums, invisibleTime)
+}
+
+// 确认消息已消费，须在不可见时间内调用，否则消息可能已被重新投递，返回错误
+func (simpleConsumer *SimpleConsumer) Ack(msg *message.MessageExt) error {
+	return simpleConsumer.simpleConsumerImpl.ack(msg)
+}
+
+// 修改消息的不可见时间，从调用时开始重新计时；成功后msg携带新的句柄，之后须使用该msg确认
+func (simpleConsumer *SimpleConsumer) ChangeInvisibleTime(msg *message.MessageExt, invisibleTime time.Duration) error {
+	return simpleConsumer.simpleConsumerImpl.changeInvisibleTime(msg, invisibleTime)
+}
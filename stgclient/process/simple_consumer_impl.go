@@ -0,0 +1,377 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/filter"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	set "github.com/deckarep/golang-set"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// popTarget 一次POP请求的目标topic及broker
+type popTarget struct {
+	topic            string
+	brokerName       string
+	subscriptionData *heartbeat.SubscriptionData
+}
+
+// SimpleConsumerImpl: POP消费实现，队列分配、重新投递均由Broker完成，因此不参与负载均衡
+// Author: agent
+// Since: 2026/10/19
+type SimpleConsumerImpl struct {
+	simpleConsumer    *SimpleConsumer
+	serviceState      stgcommon.ServiceState
+	mQClientFactory   *MQClientInstance
+	subscriptionInner map[string]*heartbeat.SubscriptionData // topic<SubscriptionData>
+	topicBrokerTable  map[string][]string                    // topic<可读的brokerName列表>
+	lock              sync.RWMutex
+	targetIndex       uint32
+}
+
+func NewSimpleConsumerImpl(simpleConsumer *SimpleConsumer) *SimpleConsumerImpl {
+	impl := &SimpleConsumerImpl{simpleConsumer: simpleConsumer, serviceState: stgcommon.CREATE_JUST}
+	impl.subscriptionInner = make(map[string]*heartbeat.SubscriptionData)
+	impl.topicBrokerTable = make(map[string][]string)
+	return impl
+}
+
+func (impl *SimpleConsumerImpl) Start() {
+	switch impl.serviceState {
+	case stgcommon.CREATE_JUST:
+		impl.serviceState = stgcommon.START_FAILED
+		// 检查配置
+		impl.checkConfig()
+		impl.simpleConsumer.clientConfig.ChangeInstanceNameToPID()
+		impl.mQClientFactory = GetInstance().GetAndCreateMQClientInstanceByHook(impl.simpleConsumer.clientConfig, impl.simpleConsumer.rpcHook)
+		// 注册consumer
+		impl.mQClientFactory.RegisterConsumer(impl.simpleConsumer.consumerGroup, impl)
+		// 启动核心
+		impl.mQClientFactory.Start()
+		logger.Infof("the simple consumer [%v] start OK", impl.simpleConsumer.consumerGroup)
+		impl.serviceState = stgcommon.RUNNING
+	case stgcommon.RUNNING:
+	case stgcommon.SHUTDOWN_ALREADY:
+		panic("The SimpleConsumer service state not OK, maybe started once")
+	case stgcommon.START_FAILED:
+		// 上次启动失败时mQClientFactory可能尚未创建
+		panic("The SimpleConsumer service state not OK, start failed before")
+	default:
+	}
+	impl.updateTopicSubscribeInfoWhenSubscriptionChanged()
+	impl.mQClientFactory.SendHeartbeatToAllBrokerWithLock()
+}
+
+func (impl *SimpleConsumerImpl) Shutdown() {
+	switch impl.serviceState {
+	case stgcommon.CREATE_JUST:
+	case stgcommon.RUNNING:
+		impl.mQClientFactory.UnregisterConsumer(impl.simpleConsumer.consumerGroup)
+		impl.mQClientFactory.Shutdown()
+		logger.Infof("the simple consumer [%v] shutdown OK", impl.simpleConsumer.consumerGroup)
+		impl.serviceState = stgcommon.SHUTDOWN_ALREADY
+	case stgcommon.SHUTDOWN_ALREADY:
+	default:
+	}
+}
+
+func (impl *SimpleConsumerImpl) checkConfig() {
+	CheckGroup(impl.simpleConsumer.consumerGroup)
+	if strings.EqualFold("", impl.simpleConsumer.consumerGroup) {
+		panic("consumerGroup is null")
+	}
+	if strings.EqualFold(impl.simpleConsumer.consumerGroup, stgcommon.DEFAULT_CONSUMER_GROUP) {
+		panic("consumerGroup can not equal" + stgcommon.DEFAULT_CONSUMER_GROUP + ", please specify another one.")
+	}
+}
+
+// 按表达式类型订阅topic，SQL92表达式语法错误时返回错误
+func (impl *SimpleConsumerImpl) subscribeByType(topic string, subExpression string, expressionType string) error {
+	subscriptionData, err := filter.BuildSubscriptionDataByType(impl.simpleConsumer.consumerGroup, topic, subExpression, expressionType)
+	if err != nil {
+		return err
+	}
+	impl.lock.Lock()
+	impl.subscriptionInner[topic] = subscriptionData
+	impl.lock.Unlock()
+
+	if impl.mQClientFactory != nil {
+		impl.mQClientFactory.UpdateTopicRouteInfoFromNameServerByTopic(topic)
+		impl.mQClientFactory.SendHeartbeatToAllBrokerWithLock()
+	}
+	return nil
+}
+
+// 取消订阅topic
+func (impl *SimpleConsumerImpl) unsubscribe(topic string) {
+	impl.lock.Lock()
+	delete(impl.subscriptionInner, topic)
+	delete(impl.topicBrokerTable, topic)
+	impl.lock.Unlock()
+
+	if impl.mQClientFactory != nil {
+		impl.mQClientFactory.SendHeartbeatToAllBrokerWithLock()
+	}
+}
+
+func (impl *SimpleConsumerImpl) updateTopicSubscribeInfoWhenSubscriptionChanged() {
+	impl.lock.RLock()
+	topics := make([]string, 0, len(impl.subscriptionInner))
+	for topic := range impl.subscriptionInner {
+		topics = append(topics, topic)
+	}
+	impl.lock.RUnlock()
+
+	for _, topic := range topics {
+		impl.mQClientFactory.UpdateTopicRouteInfoFromNameServerByTopic(topic)
+	}
+}
+
+// 接收消息：轮转选择topic及broker，前面的目标不等待，最后一个目标没有消息时最多等待awaitDuration
+func (impl *SimpleConsumerImpl) receive(maxNums int, invisibleTime time.Duration) ([]*message.MessageExt, error) {
+	if impl.serviceState != stgcommon.RUNNING {
+		return nil, errors.New("The consumer service state not OK")
+	}
+	if maxNums <= 0 {
+		return nil, errors.New("maxNums must be greater than 0")
+	}
+	if invisibleTime < time.Millisecond {
+		return nil, errors.New("invisibleTime must be at least 1ms")
+	}
+
+	targets := impl.popTargets()
+	if len(targets) == 0 {
+		impl.updateTopicSubscribeInfoWhenSubscriptionChanged()
+		targets = impl.popTargets()
+		if len(targets) == 0 {
+			return nil, errors.New("no route info of the subscribed topics, subscribe first please")
+		}
+	}
+
+	var lastErr error
+	succeed := false
+	for i, target := range targets {
+		var pollTime time.Duration
+		if i == len(targets)-1 {
+			pollTime = impl.simpleConsumer.awaitDuration
+		}
+		msgs, err := impl.popMessage(target, maxNums, invisibleTime, pollTime)
+		if err != nil {
+			logger.Warnf("pop message from broker[%s] failed, topic: %s, err: %s", target.brokerName, target.topic, err.Error())
+			lastErr = err
+			continue
+		}
+		succeed = true
+		if len(msgs) > 0 {
+			return msgs, nil
+		}
+	}
+	if !succeed {
+		return nil, lastErr
+	}
+	return []*message.MessageExt{}, nil
+}
+
+func (impl *SimpleConsumerImpl) popMessage(target popTarget, maxNums int, invisibleTime, pollTime time.Duration) ([]*message.MessageExt, error) {
+	brokerAddr, err := impl.findBrokerAddr(target.topic, target.brokerName)
+	if err != nil {
+		return nil, err
+	}
+
+	requestHeader := &header.PopMessageRequestHeader{
+		ConsumerGroup:  impl.simpleConsumer.consumerGroup,
+		Topic:          target.topic,
+		QueueId:        -1,
+		MaxMsgNums:     int32(maxNums),
+		InvisibleTime:  int64(invisibleTime / time.Millisecond),
+		PollTime:       int64(pollTime / time.Millisecond),
+		BornTime:       timeutil.CurrentTimeMillis(),
+		ExpressionType: target.subscriptionData.ExpressionType,
+		Exp:            target.subscriptionData.SubString,
+	}
+	timeoutMillis := impl.simpleConsumer.consumerPopTimeoutMillis + requestHeader.PollTime
+	return impl.mQClientFactory.MQClientAPIImpl.PopMessage(brokerAddr, target.brokerName, requestHeader, timeoutMillis)
+}
+
+// 确认消息
+func (impl *SimpleConsumerImpl) ack(msg *message.MessageExt) error {
+	handle, brokerAddr, err := impl.parseReceiptHandle(msg)
+	if err != nil {
+		return err
+	}
+
+	requestHeader := &header.AckMessageRequestHeader{
+		ConsumerGroup: impl.simpleConsumer.consumerGroup,
+		Topic:         handle.Topic,
+		QueueId:       handle.QueueId,
+		Offset:        handle.Offset,
+		ExtraInfo:     handle.Encode(),
+	}
+	return impl.mQClientFactory.MQClientAPIImpl.AckMessage(brokerAddr, requestHeader, impl.simpleConsumer.consumerPopTimeoutMillis)
+}
+
+// 修改消息不可见时间，成功后更新消息的句柄
+func (impl *SimpleConsumerImpl) changeInvisibleTime(msg *message.MessageExt, invisibleTime time.Duration) error {
+	if invisibleTime < time.Millisecond {
+		return errors.New("invisibleTime must be at least 1ms")
+	}
+	handle, brokerAddr, err := impl.parseReceiptHandle(msg)
+	if err != nil {
+		return err
+	}
+
+	requestHeader := &header.ChangeInvisibleTimeRequestHeader{
+		ConsumerGroup: impl.simpleConsumer.consumerGroup,
+		Topic:         handle.Topic,
+		QueueId:       handle.QueueId,
+		Offset:        handle.Offset,
+		ExtraInfo:     handle.Encode(),
+		InvisibleTime: int64(invisibleTime / time.Millisecond),
+	}
+	newHandle, err := impl.mQClientFactory.MQClientAPIImpl.ChangeInvisibleTime(brokerAddr, handle, requestHeader, impl.simpleConsumer.consumerPopTimeoutMillis)
+	if err != nil {
+		return err
+	}
+	message.PutProperty(&msg.Message, message.PROPERTY_POP_CK, newHandle.Encode())
+	return nil
+}
+
+func (impl *SimpleConsumerImpl) parseReceiptHandle(msg *message.MessageExt) (*message.ReceiptHandle, string, error) {
+	if impl.serviceState != stgcommon.RUNNING {
+		return nil, "", errors.New("The consumer service state not OK")
+	}
+	if msg == nil {
+		return nil, "", errors.New("message is nil")
+	}
+	value := msg.GetProperty(message.PROPERTY_POP_CK)
+	if value == "" {
+		return nil, "", fmt.Errorf("the message %s is not received by SimpleConsumer", msg.MsgId)
+	}
+	handle, err := message.DecodeReceiptHandle(value)
+	if err != nil {
+		return nil, "", err
+	}
+	brokerAddr, err := impl.findBrokerAddr(msg.Topic, handle.BrokerName)
+	if err != nil {
+		return nil, "", err
+	}
+	return handle, brokerAddr, nil
+}
+
+// 查找broker的master地址，找不到时从namesrv更新一次路由
+func (impl *SimpleConsumerImpl) findBrokerAddr(topic, brokerName string) (string, error) {
+	brokerAddr := impl.mQClientFactory.FindBrokerAddressInPublish(brokerName)
+	if brokerAddr == "" {
+		impl.mQClientFactory.UpdateTopicRouteInfoFromNameServerByTopic(topic)
+		brokerAddr = impl.mQClientFactory.FindBrokerAddressInPublish(brokerName)
+	}
+	if brokerAddr == "" {
+		return "", fmt.Errorf("the broker[%s] master not exist", brokerName)
+	}
+	return brokerAddr, nil
+}
+
+// 所有订阅topic的可读broker，起始位置每次轮转
+func (impl *SimpleConsumerImpl) popTargets() []popTarget {
+	impl.lock.RLock()
+	var targets []popTarget
+	for topic, subscriptionData := range impl.subscriptionInner {
+		for _, brokerName := range impl.topicBrokerTable[topic] {
+			targets = append(targets, popTarget{topic: topic, brokerName: brokerName, subscriptionData: subscriptionData})
+		}
+	}
+	impl.lock.RUnlock()
+
+	if len(targets) == 0 {
+		return targets
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].topic != targets[j].topic {
+			return targets[i].topic < targets[j].topic
+		}
+		return targets[i].brokerName < targets[j].brokerName
+	})
+	start := int(atomic.AddUint32(&impl.targetIndex, 1) % uint32(len(targets)))
+	return append(targets[start:], targets[:start]...)
+}
+
+// 获取订阅信息
+func (impl *SimpleConsumerImpl) Subscriptions() set.Set {
+	impl.lock.RLock()
+	defer impl.lock.RUnlock()
+
+	subSet := set.NewSet()
+	for _, subscriptionData := range impl.subscriptionInner {
+		subSet.Add(subscriptionData)
+	}
+	return subSet
+}
+
+// 路由变化时，更新topic可读的broker列表
+func (impl *SimpleConsumerImpl) UpdateTopicSubscribeInfo(topic string, info set.Set) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	if _, ok := impl.subscriptionInner[topic]; !ok {
+		return
+	}
+	brokerNames := set.NewSet()
+	for mq := range info.Iterator().C {
+		if messageQueue, ok := mq.(*message.MessageQueue); ok {
+			brokerNames.Add(messageQueue.BrokerName)
+		}
+	}
+	brokerList := make([]string, 0, brokerNames.Cardinality())
+	for brokerName := range brokerNames.Iterator().C {
+		brokerList = append(brokerList, brokerName.(string))
+	}
+	sort.Strings(brokerList)
+	impl.topicBrokerTable[topic] = brokerList
+}
+
+func (impl *SimpleConsumerImpl) GroupName() string {
+	return impl.simpleConsumer.consumerGroup
+}
+
+func (impl *SimpleConsumerImpl) MessageModel() heartbeat.MessageModel {
+	return heartbeat.CLUSTERING
+}
+
+func (impl *SimpleConsumerImpl) ConsumeType() heartbeat.ConsumeType {
+	return heartbeat.CONSUME_ACTIVELY
+}
+
+func (impl *SimpleConsumerImpl) ConsumeFromWhere() heartbeat.ConsumeFromWhere {
+	return heartbeat.CONSUME_FROM_LAST_OFFSET
+}
+
+func (impl *SimpleConsumerImpl) IsUnitMode() bool {
+	return false
+}
+
+func (impl *SimpleConsumerImpl) IsSubscribeTopicNeedUpdate(topic string) bool {
+	impl.lock.RLock()
+	defer impl.lock.RUnlock()
+
+	if _, ok := impl.subscriptionInner[topic]; !ok {
+		return false
+	}
+	return len(impl.topicBrokerTable[topic]) == 0
+}
+
+// 消费进度由Broker在Ack时提交，客户端无需持久化
+func (impl *SimpleConsumerImpl) PersistConsumerOffset() {
+}
+
+// 队列由Broker分配，客户端无需负载均衡
+func (impl *SimpleConsumerImpl) DoRebalance() {
+}
@@ -6,6 +6,7 @@ import (
 	//"sync/atomic"
 	//"math"
 	"fmt"
+	"hash/crc32"
 	"math"
 	"sync/atomic"
 )
@@ -49,6 +50,17 @@ func (topicPublishInfo *TopicPublishInfo) SelectOneMessageQueue(lastBrokerName s
 	}
 }
 
+// SelectMessageQueueByKey 按key的hash选择队列，队列数不变时相同key的消息总是发送到同一队列
+// Author: agent
+// Since: 2026/10/19
+func (topicPublishInfo *TopicPublishInfo) SelectMessageQueueByKey(key string) *message.MessageQueue {
+	if len(topicPublishInfo.MessageQueueList) == 0 {
+		return nil
+	}
+	pos := crc32.ChecksumIEEE([]byte(key)) % uint32(len(topicPublishInfo.MessageQueueList))
+	return topicPublishInfo.MessageQueueList[pos]
+}
+
 func (self *TopicPublishInfo) ToString() string {
 	if self == nil {
 		return ""
@@ -13,3 +13,25 @@ func TestTopicPublishInfo_ToString(t *testing.T) {
 	topicPublishInfo:=&TopicPublishInfo{MessageQueueList:messageQueueList}
 	fmt.Println(topicPublishInfo.ToString())
 }
+
+func TestTopicPublishInfo_SelectMessageQueueByKey(t *testing.T) {
+	topicPublishInfo := &TopicPublishInfo{}
+	if mq := topicPublishInfo.SelectMessageQueueByKey("device-1"); mq != nil {
+		t.Fatalf("unexpected queue %v", mq)
+	}
+	for i := 0; i < 4; i++ {
+		topicPublishInfo.MessageQueueList = append(topicPublishInfo.MessageQueueList, &message.MessageQueue{Topic: "test", BrokerName: "a", QueueId: i})
+	}
+	selected := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("device-%d", i)
+		mq := topicPublishInfo.SelectMessageQueueByKey(key)
+		if mq != topicPublishInfo.SelectMessageQueueByKey(key) {
+			t.Fatalf("key %s selects different queues", key)
+		}
+		selected[mq.QueueId] = true
+	}
+	if len(selected) != 4 {
+		t.Fatalf("keys not spread over queues: %v", selected)
+	}
+}
@@ -0,0 +1,93 @@
+package acl
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"strings"
+)
+
+// adminRequestCodes 修改集群元数据或运维类请求，仅admin账号可以调用
+var adminRequestCodes = map[int32]bool{
+	code.UPDATE_AND_CREATE_TOPIC:             true,
+	code.UPDATE_BROKER_CONFIG:                true,
+	code.TRIGGER_DELETE_FILES:                true,
+	code.PUT_KV_CONFIG:                       true,
+	code.DELETE_KV_CONFIG:                    true,
+	code.REGISTER_BROKER:                     true,
+	code.UNREGISTER_BROKER:                   true,
+	code.UPDATE_AND_CREATE_SUBSCRIPTIONGROUP: true,
+	code.WIPE_WRITE_PERM_OF_BROKER:           true,
+	code.DELETE_SUBSCRIPTIONGROUP:            true,
+	code.SUSPEND_CONSUMER:                    true,
+	code.RESUME_CONSUMER:                     true,
+	code.RESET_CONSUMER_OFFSET_IN_CONSUMER:   true,
+	code.RESET_CONSUMER_OFFSET_IN_BROKER:     true,
+	code.ADJUST_CONSUMER_THREAD_POOL:         true,
+	code.DELETE_TOPIC_IN_BROKER:              true,
+	code.DELETE_TOPIC_IN_NAMESRV:             true,
+	code.DELETE_KV_CONFIG_BY_VALUE:           true,
+	code.INVOKE_BROKER_TO_RESET_OFFSET:       true,
+	code.REGISTER_FILTER_SERVER:              true,
+	code.REGISTER_MESSAGE_FILTER_CLASS:       true,
+	code.CLEAN_EXPIRED_CONSUMEQUEUE:          true,
+	code.CONSUME_MESSAGE_DIRECTLY:            true,
+	code.CLONE_GROUP_OFFSET:                  true,
+	code.RESEND_DLQ_MESSAGE:                  true,
+	code.PURGE_DLQ_MESSAGE:                   true,
+	code.UPDATE_QUOTA_CONFIG:                 true,
+	code.DELETE_QUOTA_CONFIG:                 true,
+	code.DRAIN_BROKER:                        true,
+	code.REGISTER_DEVICE_ROUTE:               true,
+	code.UNREGISTER_DEVICE_NODE:              true,
+}
+
+// AccessResource 一次请求需要校验的topic、group及所需权限
+// Author agent
+// Since 2026/10/19
+type AccessResource struct {
+	Topic     string
+	TopicPerm Permission
+	Group     string
+	GroupPerm Permission
+	Admin     bool // 是否为admin请求
+}
+
+// ParseAccessResource 根据请求码与ExtFields解析请求访问的资源，返回nil表示只需身份认证
+// Author agent
+// Since 2026/10/19
+func ParseAccessResource(request *protocol.RemotingCommand) *AccessResource {
+	if adminRequestCodes[request.Code] {
+		return &AccessResource{Admin: true}
+	}
+
+	extFields := request.ExtFields
+	switch request.Code {
+	case code.SEND_MESSAGE:
+		return newAccessResource(extFields["Topic"], PUB, extFields["ProducerGroup"], PUB)
+	case code.SEND_MESSAGE_V2:
+		return newAccessResource(extFields["B"], PUB, extFields["A"], PUB)
+	case code.SEND_REPLY_MESSAGE:
+		return newAccessResource("", PUB, extFields["ProducerGroup"], SUB)
+	case code.END_TRANSACTION:
+		return newAccessResource("", PUB, extFields["ProducerGroup"], PUB)
+	case code.CONSUMER_SEND_MSG_BACK:
+		return newAccessResource(extFields["OriginTopic"], SUB, extFields["Group"], SUB)
+	case code.PULL_MESSAGE, code.QUERY_CONSUMER_OFFSET, code.UPDATE_CONSUMER_OFFSET,
+		code.POP_MESSAGE, code.ACK_MESSAGE, code.CHANGE_MESSAGE_INVISIBLETIME:
+		return newAccessResource(extFields["Topic"], SUB, extFields["ConsumerGroup"], SUB)
+	case code.QUERY_MESSAGE:
+		return newAccessResource(extFields["Topic"], SUB, "", SUB)
+	case code.GET_CONSUMER_LIST_BY_GROUP, code.QUERY_DLQ_MESSAGE:
+		return newAccessResource("", SUB, extFields["ConsumerGroup"], SUB)
+	}
+	return nil
+}
+
+// newAccessResource 重试Topic与死信Topic属于消费组本身，只校验group权限
+func newAccessResource(topic string, topicPerm Permission, group string, groupPerm Permission) *AccessResource {
+	if strings.HasPrefix(topic, stgcommon.RETRY_GROUP_TOPIC_PREFIX) || strings.HasPrefix(topic, stgcommon.DLQ_GROUP_TOPIC_PREFIX) {
+		topic = ""
+	}
+	return &AccessResource{Topic: topic, TopicPerm: topicPerm, Group: group, GroupPerm: groupPerm}
+}
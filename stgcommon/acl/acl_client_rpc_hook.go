@@ -0,0 +1,49 @@
+package acl
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+)
+
+// SessionCredentials 客户端身份凭证
+// Author agent
+// Since 2026/10/19
+type SessionCredentials struct {
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// AclClientRPCHook 客户端签名，在请求发出前写入AccessKey与Signature
+// Author agent
+// Since 2026/10/19
+type AclClientRPCHook struct {
+	SessionCredentials *SessionCredentials
+}
+
+// NewAclClientRPCHook 初始化AclClientRPCHook
+// Author agent
+// Since 2026/10/19
+func NewAclClientRPCHook(accessKey, secretKey string) *AclClientRPCHook {
+	return &AclClientRPCHook{SessionCredentials: &SessionCredentials{AccessKey: accessKey, SecretKey: secretKey}}
+}
+
+// DoBeforeRequest 对请求签名，响应与对端推送的请求不做处理
+// Author agent
+// Since 2026/10/19
+func (hook *AclClientRPCHook) DoBeforeRequest(ctx netm.Context, request *protocol.RemotingCommand) {
+	if request == nil || request.IsResponseType() {
+		return
+	}
+	if request.ExtFields == nil {
+		request.ExtFields = make(map[string]string)
+	}
+	request.MakeCustomHeaderToNet()
+	request.ExtFields[protocol.ACL_ACCESS_KEY] = hook.SessionCredentials.AccessKey
+	request.ExtFields[protocol.ACL_SIGNATURE] = CalSignature(CombineRequestContent(request), hook.SessionCredentials.SecretKey)
+}
+
+// DoAfterResponse 无需处理
+// Author agent
+// Since 2026/10/19
+func (hook *AclClientRPCHook) DoAfterResponse(ctx netm.Context, request *protocol.RemotingCommand, response *protocol.RemotingCommand) {
+}
@@ -0,0 +1,59 @@
+package acl
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"sort"
+	"strconv"
+)
+
+// CalSignature 使用secretKey对content做HmacSHA1签名，返回base64编码后的签名
+// Author agent
+// Since 2026/10/19
+func CalSignature(content []byte, secretKey string) string {
+	mac := hmac.New(sha1.New, []byte(secretKey))
+	mac.Write(content)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CombineRequestContent 组装待签名内容：按key排序的ExtFields(不含Signature) + Code + Body
+// Author agent
+// Since 2026/10/19
+func CombineRequestContent(request *protocol.RemotingCommand) []byte {
+	keys := make([]string, 0, len(request.ExtFields))
+	for key := range request.ExtFields {
+		if key == protocol.ACL_SIGNATURE {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer([]byte{})
+	for _, key := range keys {
+		buf.WriteString(key)
+		buf.WriteString("=")
+		buf.WriteString(request.ExtFields[key])
+		buf.WriteString(";")
+	}
+	buf.WriteString(strconv.Itoa(int(request.Code)))
+	if request.Body != nil {
+		buf.Write(request.Body)
+	}
+	return buf.Bytes()
+}
+
+// VerifySignature 校验请求中的签名
+// Author agent
+// Since 2026/10/19
+func VerifySignature(request *protocol.RemotingCommand, secretKey string) bool {
+	signature := request.ExtFields[protocol.ACL_SIGNATURE]
+	if signature == "" {
+		return false
+	}
+	expected := CalSignature(CombineRequestContent(request), secretKey)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
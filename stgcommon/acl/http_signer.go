@@ -0,0 +1,45 @@
+package acl
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+)
+
+// CombineHttpRequestContent 组装HTTP请求的待签名内容：按key排序的查询参数 + Method + Path + Body，
+// 与CombineRequestContent的格式一致，同一key的多个值按出现顺序拼接
+// Author agent
+// Since 2026/10/19
+func CombineHttpRequestContent(method, path string, query url.Values, body []byte) []byte {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer([]byte{})
+	for _, key := range keys {
+		for _, value := range query[key] {
+			buf.WriteString(key)
+			buf.WriteString("=")
+			buf.WriteString(value)
+			buf.WriteString(";")
+		}
+	}
+	buf.WriteString(method)
+	buf.WriteString(path)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// SignHttpRequest 对HTTP请求签名，写入AccessKey与Signature请求头，body须与实际发送的请求体一致
+// Author agent
+// Since 2026/10/19
+func SignHttpRequest(request *http.Request, body []byte, accessKey, secretKey string) {
+	content := CombineHttpRequestContent(request.Method, request.URL.Path, request.URL.Query(), body)
+	request.Header.Set(protocol.ACL_ACCESS_KEY, accessKey)
+	request.Header.Set(protocol.ACL_SIGNATURE, CalSignature(content, secretKey))
+}
@@ -0,0 +1,119 @@
+package acl
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Permission 资源权限位
+// Author agent
+// Since 2026/10/19
+type Permission byte
+
+const (
+	DENY  Permission = 1      // 拒绝
+	PUB   Permission = 1 << 1 // 发送
+	SUB   Permission = 1 << 2 // 订阅
+	ANY   Permission = PUB | SUB
+	ADMIN Permission = 1 << 3 // 管理操作，仅限admin账号
+)
+
+// ParsePermission 解析"PUB|SUB"、"ANY"、"DENY"格式的权限
+// Author agent
+// Since 2026/10/19
+func ParsePermission(value string) (Permission, error) {
+	var perm Permission
+	for _, item := range strings.Split(value, "|") {
+		switch strings.ToUpper(strings.TrimSpace(item)) {
+		case "DENY":
+			return DENY, nil
+		case "PUB":
+			perm |= PUB
+		case "SUB":
+			perm |= SUB
+		case "ANY":
+			perm |= ANY
+		default:
+			return DENY, fmt.Errorf("invalid permission %s", value)
+		}
+	}
+	return perm, nil
+}
+
+// Check owned是否包含needed的全部权限
+// Author agent
+// Since 2026/10/19
+func (owned Permission) Check(needed Permission) bool {
+	if owned&DENY == DENY {
+		return false
+	}
+	return owned&needed == needed
+}
+
+func (perm Permission) String() string {
+	if perm&DENY == DENY {
+		return "DENY"
+	}
+	var items []string
+	if perm&PUB == PUB {
+		items = append(items, "PUB")
+	}
+	if perm&SUB == SUB {
+		items = append(items, "SUB")
+	}
+	if perm&ADMIN == ADMIN {
+		items = append(items, "ADMIN")
+	}
+	return strings.Join(items, "|")
+}
+
+// ResourcePerm 资源名称(支持*通配)与权限
+// Author agent
+// Since 2026/10/19
+type ResourcePerm struct {
+	Pattern string
+	Perm    Permission
+}
+
+// ParseResourcePerms 解析"topicA=PUB|SUB"格式的资源权限列表
+// Author agent
+// Since 2026/10/19
+func ParseResourcePerms(values []string) ([]*ResourcePerm, error) {
+	resourcePerms := make([]*ResourcePerm, 0, len(values))
+	for _, value := range values {
+		index := strings.LastIndex(value, "=")
+		if index <= 0 {
+			return nil, fmt.Errorf("invalid resource permission %s, expect pattern=PERM", value)
+		}
+		pattern := strings.TrimSpace(value[:index])
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid resource pattern %s: %s", pattern, err.Error())
+		}
+		perm, err := ParsePermission(value[index+1:])
+		if err != nil {
+			return nil, err
+		}
+		resourcePerms = append(resourcePerms, &ResourcePerm{Pattern: pattern, Perm: perm})
+	}
+	return resourcePerms, nil
+}
+
+// matchResourcePerm 按配置顺序返回第一个匹配resource的权限
+func matchResourcePerm(resourcePerms []*ResourcePerm, resource string) (Permission, bool) {
+	for _, resourcePerm := range resourcePerms {
+		if matchPattern(resourcePerm.Pattern, resource) {
+			return resourcePerm.Perm, true
+		}
+	}
+	return DENY, false
+}
+
+// matchPattern 通配匹配，*匹配任意字符，用于资源名与IP地址
+func matchPattern(pattern, value string) bool {
+	if pattern == "*" || pattern == value {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
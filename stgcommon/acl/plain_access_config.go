@@ -0,0 +1,119 @@
+package acl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlainAccessConfig ACL配置文件(plain_acl.json)内容
+// Author agent
+// Since 2026/10/19
+type PlainAccessConfig struct {
+	GlobalWhiteRemoteAddresses []string              `json:"globalWhiteRemoteAddresses"` // 全局白名单，命中后不做任何校验
+	Accounts                   []*PlainAccessAccount `json:"accounts"`                   // 账号列表
+}
+
+// PlainAccessAccount 账号配置
+// Author agent
+// Since 2026/10/19
+type PlainAccessAccount struct {
+	AccessKey          string   `json:"accessKey"`
+	SecretKey          string   `json:"secretKey"`
+	WhiteRemoteAddress string   `json:"whiteRemoteAddress"` // 账号白名单，命中后不校验签名
+	Admin              bool     `json:"admin"`              // 是否允许调用admin请求，admin账号拥有全部权限
+	DefaultTopicPerm   string   `json:"defaultTopicPerm"`   // 未在topicPerms中配置的topic的权限，默认DENY
+	DefaultGroupPerm   string   `json:"defaultGroupPerm"`   // 未在groupPerms中配置的group的权限，默认DENY
+	TopicPerms         []string `json:"topicPerms"`         // topic权限，格式: topicA=PUB|SUB，支持*通配
+	GroupPerms         []string `json:"groupPerms"`         // group权限，格式: groupA=SUB，支持*通配
+}
+
+// plainAccessResource 解析后的账号权限
+type plainAccessResource struct {
+	accessKey          string
+	secretKey          string
+	whiteRemoteAddress string
+	admin              bool
+	defaultTopicPerm   Permission
+	defaultGroupPerm   Permission
+	topicPerms         []*ResourcePerm
+	groupPerms         []*ResourcePerm
+}
+
+// parsePlainAccessResource 校验并解析账号配置
+func parsePlainAccessResource(account *PlainAccessAccount) (*plainAccessResource, error) {
+	if strings.TrimSpace(account.AccessKey) == "" || strings.TrimSpace(account.SecretKey) == "" {
+		return nil, fmt.Errorf("accessKey and secretKey can not be empty")
+	}
+
+	resource := &plainAccessResource{
+		accessKey:          account.AccessKey,
+		secretKey:          account.SecretKey,
+		whiteRemoteAddress: strings.TrimSpace(account.WhiteRemoteAddress),
+		admin:              account.Admin,
+		defaultTopicPerm:   DENY,
+		defaultGroupPerm:   DENY,
+	}
+
+	var err error
+	if account.DefaultTopicPerm != "" {
+		if resource.defaultTopicPerm, err = ParsePermission(account.DefaultTopicPerm); err != nil {
+			return nil, fmt.Errorf("account %s defaultTopicPerm: %s", account.AccessKey, err.Error())
+		}
+	}
+	if account.DefaultGroupPerm != "" {
+		if resource.defaultGroupPerm, err = ParsePermission(account.DefaultGroupPerm); err != nil {
+			return nil, fmt.Errorf("account %s defaultGroupPerm: %s", account.AccessKey, err.Error())
+		}
+	}
+	if resource.topicPerms, err = ParseResourcePerms(account.TopicPerms); err != nil {
+		return nil, fmt.Errorf("account %s topicPerms: %s", account.AccessKey, err.Error())
+	}
+	if resource.groupPerms, err = ParseResourcePerms(account.GroupPerms); err != nil {
+		return nil, fmt.Errorf("account %s groupPerms: %s", account.AccessKey, err.Error())
+	}
+	return resource, nil
+}
+
+// checkTopicPerm 校验topic权限
+func (resource *plainAccessResource) checkTopicPerm(topic string, needed Permission) bool {
+	if resource.admin {
+		return true
+	}
+	perm, ok := matchResourcePerm(resource.topicPerms, topic)
+	if !ok {
+		perm = resource.defaultTopicPerm
+	}
+	return perm.Check(needed)
+}
+
+// checkGroupPerm 校验group权限
+func (resource *plainAccessResource) checkGroupPerm(group string, needed Permission) bool {
+	if resource.admin {
+		return true
+	}
+	perm, ok := matchResourcePerm(resource.groupPerms, group)
+	if !ok {
+		perm = resource.defaultGroupPerm
+	}
+	return perm.Check(needed)
+}
+
+// checkAccess 校验请求访问的资源，accessResource为nil表示只需身份认证
+func (resource *plainAccessResource) checkAccess(accessResource *AccessResource) error {
+	if accessResource == nil {
+		return nil
+	}
+	if accessResource.Admin {
+		if !resource.admin {
+			return fmt.Errorf("accessKey %s has no admin permission", resource.accessKey)
+		}
+		return nil
+	}
+	if accessResource.Topic != "" && !resource.checkTopicPerm(accessResource.Topic, accessResource.TopicPerm) {
+		return fmt.Errorf("accessKey %s has no %s permission on topic %s", resource.accessKey, accessResource.TopicPerm, accessResource.Topic)
+	}
+	if accessResource.Group != "" && !resource.checkGroupPerm(accessResource.Group, accessResource.GroupPerm) {
+		return fmt.Errorf("accessKey %s has no %s permission on group %s", resource.accessKey, accessResource.GroupPerm, accessResource.Group)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package acl
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	reloadIntervalMillis = 5000 // 检查ACL文件是否变化的间隔
+)
+
+// PlainAccessValidator 基于plain_acl.json的服务端鉴权，文件变化后自动重新加载
+// Author agent
+// Since 2026/10/19
+type PlainAccessValidator struct {
+	configPath                 string
+	lock                       sync.RWMutex
+	globalWhiteRemoteAddresses []string
+	accounts                   map[string]*plainAccessResource // accessKey<*plainAccessResource>
+	lastModified               time.Time
+	reloadTicker               *timeutil.Ticker
+}
+
+// NewPlainAccessValidator 初始化并加载ACL文件
+// Author agent
+// Since 2026/10/19
+func NewPlainAccessValidator(configPath string) (*PlainAccessValidator, error) {
+	validator := &PlainAccessValidator{configPath: configPath}
+	if err := validator.Load(); err != nil {
+		return nil, err
+	}
+	return validator, nil
+}
+
+// Load 加载ACL文件，解析失败时保留原有配置
+// Author agent
+// Since 2026/10/19
+func (validator *PlainAccessValidator) Load() error {
+	fileInfo, err := os.Stat(validator.configPath)
+	if err != nil {
+		return fmt.Errorf("stat acl file %s err: %s", validator.configPath, err.Error())
+	}
+	data, err := ioutil.ReadFile(validator.configPath)
+	if err != nil {
+		return fmt.Errorf("read acl file %s err: %s", validator.configPath, err.Error())
+	}
+
+	config := new(PlainAccessConfig)
+	if err := stgcommon.Decode(data, config); err != nil {
+		return fmt.Errorf("decode acl file %s err: %s", validator.configPath, err.Error())
+	}
+
+	accounts := make(map[string]*plainAccessResource, len(config.Accounts))
+	for _, account := range config.Accounts {
+		resource, err := parsePlainAccessResource(account)
+		if err != nil {
+			return fmt.Errorf("acl file %s: %s", validator.configPath, err.Error())
+		}
+		if _, ok := accounts[resource.accessKey]; ok {
+			return fmt.Errorf("acl file %s: duplicate accessKey %s", validator.configPath, resource.accessKey)
+		}
+		accounts[resource.accessKey] = resource
+	}
+
+	validator.lock.Lock()
+	validator.globalWhiteRemoteAddresses = config.GlobalWhiteRemoteAddresses
+	validator.accounts = accounts
+	validator.lastModified = fileInfo.ModTime()
+	validator.lock.Unlock()
+
+	logger.Infof("load acl file %s ok, accounts=%d, globalWhiteRemoteAddresses=%v", validator.configPath, len(accounts), config.GlobalWhiteRemoteAddresses)
+	return nil
+}
+
+// Start 定时检查ACL文件修改时间，变化后重新加载
+// Author agent
+// Since 2026/10/19
+func (validator *PlainAccessValidator) Start() {
+	validator.reloadTicker = timeutil.NewTicker(false, reloadIntervalMillis*time.Millisecond, reloadIntervalMillis*time.Millisecond, func() {
+		validator.reloadIfModified()
+	})
+	validator.reloadTicker.Start()
+}
+
+// Shutdown 停止ACL文件检查
+// Author agent
+// Since 2026/10/19
+func (validator *PlainAccessValidator) Shutdown() {
+	if validator.reloadTicker != nil {
+		validator.reloadTicker.Stop()
+	}
+}
+
+// reloadIfModified ACL文件修改时间变化时重新加载
+func (validator *PlainAccessValidator) reloadIfModified() {
+	fileInfo, err := os.Stat(validator.configPath)
+	if err != nil {
+		logger.Errorf("stat acl file %s err: %s", validator.configPath, err.Error())
+		return
+	}
+
+	validator.lock.RLock()
+	lastModified := validator.lastModified
+	validator.lock.RUnlock()
+	if fileInfo.ModTime().Equal(lastModified) {
+		return
+	}
+
+	if err := validator.Load(); err != nil {
+		logger.Errorf("reload acl file err, keep the previous config. %s", err.Error())
+	}
+}
+
+// Validate 校验白名单、签名及资源权限
+// Author agent
+// Since 2026/10/19
+func (validator *PlainAccessValidator) Validate(ctx netm.Context, request *protocol.RemotingCommand) error {
+	remoteAddr := parseRemoteHost(ctx)
+
+	validator.lock.RLock()
+	defer validator.lock.RUnlock()
+
+	resource, err := validator.authenticate(remoteAddr, request.ExtFields[protocol.ACL_ACCESS_KEY], func(secretKey string) bool {
+		return VerifySignature(request, secretKey)
+	})
+	if err != nil || resource == nil {
+		return err
+	}
+
+	return resource.checkAccess(ParseAccessResource(request))
+}
+
+// ValidateContent 校验非remoting协议的请求，content为客户端签名的内容，如HTTP网关的CombineHttpRequestContent；
+// 白名单、签名及资源权限的校验规则与Validate一致
+// Author agent
+// Since 2026/10/19
+func (validator *PlainAccessValidator) ValidateContent(remoteAddr, accessKey, signature string, content []byte, accessResource *AccessResource) error {
+	validator.lock.RLock()
+	defer validator.lock.RUnlock()
+
+	resource, err := validator.authenticate(remoteAddr, accessKey, func(secretKey string) bool {
+		return signature != "" && hmac.Equal([]byte(signature), []byte(CalSignature(content, secretKey)))
+	})
+	if err != nil || resource == nil {
+		return err
+	}
+	return resource.checkAccess(accessResource)
+}
+
+// authenticate 校验全局白名单、账号白名单及签名，调用方需持有读锁；命中全局白名单时返回nil账号
+func (validator *PlainAccessValidator) authenticate(remoteAddr, accessKey string, verify func(secretKey string) bool) (*plainAccessResource, error) {
+	for _, pattern := range validator.globalWhiteRemoteAddresses {
+		if matchPattern(pattern, remoteAddr) {
+			return nil, nil
+		}
+	}
+
+	if accessKey == "" {
+		return nil, fmt.Errorf("no accessKey in request from %s", remoteAddr)
+	}
+	resource, ok := validator.accounts[accessKey]
+	if !ok {
+		return nil, fmt.Errorf("accessKey %s is not exist", accessKey)
+	}
+
+	if resource.whiteRemoteAddress == "" || !matchPattern(resource.whiteRemoteAddress, remoteAddr) {
+		if !verify(resource.secretKey) {
+			return nil, fmt.Errorf("check signature failed, accessKey=%s", accessKey)
+		}
+	}
+	return resource, nil
+}
+
+// parseRemoteHost 取对端地址的IP部分
+func parseRemoteHost(ctx netm.Context) string {
+	if ctx == nil || ctx.RemoteAddr() == nil {
+		return ""
+	}
+	addr := ctx.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
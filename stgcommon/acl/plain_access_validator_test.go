@@ -0,0 +1,200 @@
+package acl
+
+import (
+	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testAclConfig = `{
+	"globalWhiteRemoteAddresses": ["10.10.*"],
+	"accounts": [
+		{"accessKey": "admin", "secretKey": "admin123", "admin": true},
+		{"accessKey": "app", "secretKey": "app123", "defaultGroupPerm": "SUB",
+		 "topicPerms": ["order_*=PUB", "deny_topic=DENY"], "groupPerms": ["app_producer=PUB"]}
+	]
+}`
+
+type testContext struct {
+	netm.Context
+	remoteAddr net.Addr
+}
+
+func (ctx *testContext) RemoteAddr() net.Addr {
+	return ctx.remoteAddr
+}
+
+func newTestContext(ip string) netm.Context {
+	return &testContext{remoteAddr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 10911}}
+}
+
+func newTestValidator(t *testing.T) *PlainAccessValidator {
+	dir, err := ioutil.TempDir("", "acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "plain_acl.json")
+	if err := ioutil.WriteFile(configPath, []byte(testAclConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := NewPlainAccessValidator(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return validator
+}
+
+func newSignedSendRequest(accessKey, secretKey, topic, group string) *protocol.RemotingCommand {
+	requestHeader := &header.SendMessageRequestHeader{ProducerGroup: group, Topic: topic}
+	request := protocol.CreateRequestCommand(code.SEND_MESSAGE, requestHeader)
+	request.Body = []byte("hello")
+	NewAclClientRPCHook(accessKey, secretKey).DoBeforeRequest(nil, request)
+	return request
+}
+
+func TestPlainAccessValidator_Validate(t *testing.T) {
+	validator := newTestValidator(t)
+	defer os.RemoveAll(filepath.Dir(validator.configPath))
+	ctx := newTestContext("192.168.1.10")
+
+	if err := validator.Validate(ctx, newSignedSendRequest("app", "app123", "order_1", "app_producer")); err != nil {
+		t.Fatalf("expect pass, got %v", err)
+	}
+	if err := validator.Validate(ctx, newSignedSendRequest("app", "wrong", "order_1", "app_producer")); err == nil {
+		t.Fatalf("expect signature error")
+	}
+	if err := validator.Validate(ctx, newSignedSendRequest("app", "app123", "deny_topic", "app_producer")); err == nil {
+		t.Fatalf("expect topic permission error")
+	}
+	if err := validator.Validate(ctx, newSignedSendRequest("app", "app123", "order_1", "other_group")); err == nil {
+		t.Fatalf("expect group permission error")
+	}
+
+	tampered := newSignedSendRequest("app", "app123", "order_1", "app_producer")
+	tampered.Body = []byte("tampered")
+	if err := validator.Validate(ctx, tampered); err == nil {
+		t.Fatalf("expect signature error for tampered body")
+	}
+
+	deleteTopic := protocol.CreateRequestCommand(code.DELETE_TOPIC_IN_BROKER, &header.DeleteTopicRequestHeader{Topic: "order_1"})
+	NewAclClientRPCHook("app", "app123").DoBeforeRequest(nil, deleteTopic)
+	if err := validator.Validate(ctx, deleteTopic); err == nil {
+		t.Fatalf("expect admin permission error")
+	}
+	NewAclClientRPCHook("admin", "admin123").DoBeforeRequest(nil, deleteTopic)
+	if err := validator.Validate(ctx, deleteTopic); err != nil {
+		t.Fatalf("expect admin pass, got %v", err)
+	}
+
+	unsigned := protocol.CreateRequestCommand(code.DELETE_TOPIC_IN_BROKER, &header.DeleteTopicRequestHeader{Topic: "order_1"})
+	unsigned.MakeCustomHeaderToNet()
+	if err := validator.Validate(ctx, unsigned); err == nil {
+		t.Fatalf("expect error for unsigned request")
+	}
+	if err := validator.Validate(newTestContext("10.10.1.1"), unsigned); err != nil {
+		t.Fatalf("expect white remote address pass, got %v", err)
+	}
+}
+
+func TestPlainAccessValidator_QueryDLQMessage(t *testing.T) {
+	validator := newTestValidator(t)
+	defer os.RemoveAll(filepath.Dir(validator.configPath))
+	ctx := newTestContext("192.168.1.10")
+
+	newRequest := func(accessKey, secretKey, group string) *protocol.RemotingCommand {
+		request := protocol.CreateRequestCommand(code.QUERY_DLQ_MESSAGE, &header.QueryDLQMessageRequestHeader{ConsumerGroup: group, MaxNums: 32})
+		NewAclClientRPCHook(accessKey, secretKey).DoBeforeRequest(nil, request)
+		return request
+	}
+	// 死信消息属于消费组，需要该group的SUB权限
+	if err := validator.Validate(ctx, newRequest("app", "app123", "app_consumer")); err != nil {
+		t.Fatalf("expect pass, got %v", err)
+	}
+	if err := validator.Validate(ctx, newRequest("app", "app123", "app_producer")); err == nil {
+		t.Fatalf("expect group permission error")
+	}
+	if err := validator.Validate(ctx, newRequest("admin", "admin123", "app_producer")); err != nil {
+		t.Fatalf("expect admin pass, got %v", err)
+	}
+}
+
+func TestPlainAccessValidator_Reload(t *testing.T) {
+	validator := newTestValidator(t)
+	defer os.RemoveAll(filepath.Dir(validator.configPath))
+
+	if err := ioutil.WriteFile(validator.configPath, []byte("{invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.Load(); err == nil {
+		t.Fatalf("expect decode error")
+	}
+	if len(validator.accounts) != 2 {
+		t.Fatalf("previous config should be kept, got %d accounts", len(validator.accounts))
+	}
+
+	config := `{"accounts": [{"accessKey": "app", "secretKey": "new123", "defaultTopicPerm": "PUB", "defaultGroupPerm": "PUB"}]}`
+	if err := ioutil.WriteFile(validator.configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.Load(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := newTestContext("192.168.1.10")
+	if err := validator.Validate(ctx, newSignedSendRequest("app", "new123", "any", "any")); err != nil {
+		t.Fatalf("expect pass with reloaded secretKey, got %v", err)
+	}
+	if err := validator.Validate(ctx, newSignedSendRequest("admin", "admin123", "any", "any")); err == nil {
+		t.Fatalf("expect removed account rejected")
+	}
+}
+
+func TestDecodeCommandCustomHeaderSkipAclFields(t *testing.T) {
+	request := newSignedSendRequest("app", "app123", "order_1", "app_producer")
+	requestHeader := &header.SendMessageRequestHeader{}
+	if err := request.DecodeCommandCustomHeader(requestHeader); err != nil {
+		t.Fatal(err)
+	}
+	if requestHeader.Topic != "order_1" {
+		t.Fatalf("unexpected topic %s", requestHeader.Topic)
+	}
+}
+
+func TestPlainAccessValidator_ValidateContent(t *testing.T) {
+	validator := newTestValidator(t)
+	defer os.RemoveAll(filepath.Dir(validator.configPath))
+
+	request, _ := http.NewRequest("POST", "http://127.0.0.1:8080/topics/order_1/messages?tags=a", nil)
+	body := []byte(`{"body":"hello"}`)
+	SignHttpRequest(request, body, "app", "app123")
+	accessKey, signature := request.Header.Get(protocol.ACL_ACCESS_KEY), request.Header.Get(protocol.ACL_SIGNATURE)
+	content := CombineHttpRequestContent(request.Method, request.URL.Path, request.URL.Query(), body)
+
+	if err := validator.ValidateContent("192.168.1.10", accessKey, signature, content, &AccessResource{Topic: "order_1", TopicPerm: PUB}); err != nil {
+		t.Fatalf("expect pass, got %v", err)
+	}
+	if err := validator.ValidateContent("192.168.1.10", accessKey, signature, content, &AccessResource{Topic: "deny_topic", TopicPerm: PUB}); err == nil {
+		t.Fatalf("expect topic permission error")
+	}
+	if err := validator.ValidateContent("192.168.1.10", accessKey, signature, content, &AccessResource{Admin: true}); err == nil {
+		t.Fatalf("expect admin permission error")
+	}
+
+	tampered := CombineHttpRequestContent(request.Method, request.URL.Path, url.Values{"tags": {"b"}}, body)
+	if err := validator.ValidateContent("192.168.1.10", accessKey, signature, tampered, nil); err == nil {
+		t.Fatalf("expect signature error for tampered query")
+	}
+	if err := validator.ValidateContent("192.168.1.10", accessKey, "", content, nil); err == nil {
+		t.Fatalf("expect signature error for empty signature")
+	}
+	if err := validator.ValidateContent("10.10.1.1", "", "", tampered, &AccessResource{Admin: true}); err != nil {
+		t.Fatalf("expect white remote address pass, got %v", err)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgclient"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/static"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
@@ -42,6 +45,8 @@ type BrokerConfig struct {
 	ClientManageThreadPoolNums         int    `json:"clientManageThreadPoolNums"`         // ClientManageProcessor处理线程数
 	FlushConsumerOffsetInterval        int    `json:"flushConsumerOffsetInterval"`        // 刷新ConsumerOffest定时间隔
 	FlushConsumerOffsetHistoryInterval int    `json:"flushConsumerOffsetHistoryInterval"` // 此字段目前没有使用
+	OffsetJournalFlushInterval         int64  `json:"offsetJournalFlushInterval"`         // 消费进度提交日志按批fsync的间隔(毫秒)
+	OffsetJournalCompactSize           int64  `json:"offsetJournalCompactSize"`           // 消费进度提交日志超过该大小(字节)时合并到快照
 	RejectTransactionMessage           bool   `json:"rejectTransactionMessage"`           // 是否拒绝接收事务消息
 	FetchNamesrvAddrByAddressServer    bool   `json:"fetchNamesrvAddrByAddressServer"`    // 是否从地址服务器寻找NameServer地址，正式发布后，默认值为false
 	SendThreadPoolQueueCapacity        int    `json:"sendThreadPoolQueueCapacity"`        // 发送消息对应的线程池阻塞队列size
@@ -52,6 +57,32 @@ type BrokerConfig struct {
 	NotifyConsumerIdsChangedEnable     bool   `json:"notifyConsumerIdsChangedEnable"`     // notify consumerId changed 开关
 	OffsetCheckInSlave                 bool   `json:"offsetCheckInSlave"`                 // slave 是否需要纠正位点
 	HaMasterAddress                    string `json:"haMasterAddress"`                    // 适用场景：HA功能配置(将slave角色的 ha地址，指向master角色)
+	DebugServerEnable                  bool   `json:"debugServerEnable"`                  // 是否开启调试HTTP服务(只读查看存储内部状态、pprof)
+	DebugServerAddr                    string `json:"debugServerAddr"`                    // 调试HTTP服务监听地址，默认只绑定本机
+	MetricsServerEnable                bool   `json:"metricsServerEnable"`                // 是否开启指标HTTP服务(Prometheus格式/metrics)
+	MetricsServerAddr                  string `json:"metricsServerAddr"`                  // 指标HTTP服务监听地址
+	KafkaServerEnable                  bool   `json:"kafkaServerEnable"`                  // 是否开启Kafka协议服务
+	KafkaServerAddr                    string `json:"kafkaServerAddr"`                    // Kafka协议服务监听地址
+	AclEnable                          bool   `json:"aclEnable"`                          // 是否开启ACL鉴权
+	AclConfigPath                      string `json:"aclConfigPath"`                      // ACL文件路径
+	AccessKey                          string `json:"accessKey"`                          // broker作为客户端访问namesrv、master时的accessKey
+	SecretKey                          string `json:"-"`                                  // broker作为客户端访问namesrv、master时的secretKey，不对外展示
+	TlsMode                            string `json:"tlsMode"`                            // 服务端TLS模式：disabled、permissive、enforcing
+	TlsCertFile                        string `json:"tlsCertFile"`                        // TLS证书文件
+	TlsKeyFile                         string `json:"tlsKeyFile"`                         // TLS私钥文件
+	TlsCAFile                          string `json:"tlsCAFile"`                          // TLS CA证书文件
+	TlsClientAuth                      bool   `json:"tlsClientAuth"`                      // 是否要求客户端证书
+	TlsMinVersion                      string `json:"tlsMinVersion"`                      // TLS最低版本
+	TlsCipherSuites                    string `json:"tlsCipherSuites"`                    // TLS加密套件，多个以逗号分隔
+	TlsReloadInterval                  int    `json:"tlsReloadInterval"`                  // 证书热加载检查间隔，单位秒
+	TlsClientEnable                    bool   `json:"tlsClientEnable"`                    // broker作为客户端访问namesrv、master时是否使用TLS
+	TraceTopicEnable                   bool   `json:"traceTopicEnable"`                   // 是否开启消息轨迹(记录存储、拉取、确认等环节)
+	MsgTraceTopicName                  string `json:"msgTraceTopicName"`                  // 消息轨迹存储的Topic
+	DrainOnShutdown                    bool   `json:"drainOnShutdown"`                    // 收到SIGINT、SIGTERM信号时是否先优雅下线(drain)再退出
+	DrainProducerTimeoutMills          int64  `json:"drainProducerTimeoutMills"`          // 下线时等待生产者迁移走的最长时间
+	DrainProducerQuietMills            int64  `json:"drainProducerQuietMills"`            // 连续多长时间没有收到发送请求，认为生产者已经迁移走
+	DrainPullTimeoutMills              int64  `json:"drainPullTimeoutMills"`              // 下线时等待被Hold住的拉消息请求响应完毕的最长时间
+	DrainFlushTimeoutMills             int64  `json:"drainFlushTimeoutMills"`             // 下线时等待消息分发、刷盘完毕的最长时间
 }
 
 // NewDefaultBrokerConfig 初始化默认BrokerConfig（默认AutoCreateTopicEnable=true）
@@ -78,6 +109,8 @@ func NewDefaultBrokerConfig() *BrokerConfig {
 		ClientManageThreadPoolNums:         16,
 		FlushConsumerOffsetInterval:        1000 * 5,
 		FlushConsumerOffsetHistoryInterval: 1000 * 60,
+		OffsetJournalFlushInterval:         10,
+		OffsetJournalCompactSize:           1024 * 1024 * 64,
 		RejectTransactionMessage:           false,
 		FetchNamesrvAddrByAddressServer:    false,
 		SendThreadPoolQueueCapacity:        100000,
@@ -87,6 +120,22 @@ func NewDefaultBrokerConfig() *BrokerConfig {
 		ShortPollingTimeMills:              1000,
 		NotifyConsumerIdsChangedEnable:     true,
 		OffsetCheckInSlave:                 true,
+		DebugServerEnable:                  false,
+		DebugServerAddr:                    static.BROKER_DEBUG_ADDR,
+		MetricsServerEnable:                false,
+		MetricsServerAddr:                  static.BROKER_METRICS_ADDR,
+		KafkaServerEnable:                  false,
+		KafkaServerAddr:                    static.BROKER_KAFKA_ADDR,
+		AclEnable:                          false,
+		AclConfigPath:                      filepath.Join(os.Getenv(SMARTGO_HOME_ENV), "conf", static.ACL_CONFIG_NAME),
+		TlsMode:                            netm.TLS_MODE_DISABLED,
+		TraceTopicEnable:                   false,
+		MsgTraceTopicName:                  SYS_TRACE_TOPIC,
+		DrainOnShutdown:                    false,
+		DrainProducerTimeoutMills:          1000 * 30,
+		DrainProducerQuietMills:            1000 * 3,
+		DrainPullTimeoutMills:              1000 * 5,
+		DrainFlushTimeoutMills:             1000 * 10,
 	}
 
 	return brokerConfig
@@ -104,6 +153,35 @@ func NewCustomBrokerConfig(cfg *SmartgoBrokerConfig) *BrokerConfig {
 	brokerConfig.StorePathRootDir = cfg.StorePathRootDir
 	brokerConfig.BrokerPort = cfg.BrokerPort
 	brokerConfig.HaMasterAddress = strings.TrimSpace(cfg.HaMasterAddress)
+	brokerConfig.DebugServerEnable = cfg.DebugServerEnable
+	if strings.TrimSpace(cfg.DebugServerAddr) != "" {
+		brokerConfig.DebugServerAddr = strings.TrimSpace(cfg.DebugServerAddr)
+	}
+	brokerConfig.MetricsServerEnable = cfg.MetricsServerEnable
+	if strings.TrimSpace(cfg.MetricsServerAddr) != "" {
+		brokerConfig.MetricsServerAddr = strings.TrimSpace(cfg.MetricsServerAddr)
+	}
+	brokerConfig.KafkaServerEnable = cfg.KafkaServerEnable
+	if strings.TrimSpace(cfg.KafkaServerAddr) != "" {
+		brokerConfig.KafkaServerAddr = strings.TrimSpace(cfg.KafkaServerAddr)
+	}
+	brokerConfig.AclEnable = cfg.AclEnable
+	if strings.TrimSpace(cfg.AclConfigPath) != "" {
+		brokerConfig.AclConfigPath = strings.TrimSpace(cfg.AclConfigPath)
+	}
+	brokerConfig.AccessKey = strings.TrimSpace(cfg.AccessKey)
+	brokerConfig.SecretKey = strings.TrimSpace(cfg.SecretKey)
+	if strings.TrimSpace(cfg.TlsMode) != "" {
+		brokerConfig.TlsMode = strings.TrimSpace(cfg.TlsMode)
+	}
+	brokerConfig.TlsCertFile = strings.TrimSpace(cfg.TlsCertFile)
+	brokerConfig.TlsKeyFile = strings.TrimSpace(cfg.TlsKeyFile)
+	brokerConfig.TlsCAFile = strings.TrimSpace(cfg.TlsCAFile)
+	brokerConfig.TlsClientAuth = cfg.TlsClientAuth
+	brokerConfig.TlsMinVersion = strings.TrimSpace(cfg.TlsMinVersion)
+	brokerConfig.TlsCipherSuites = strings.TrimSpace(cfg.TlsCipherSuites)
+	brokerConfig.TlsReloadInterval = cfg.TlsReloadInterval
+	brokerConfig.TlsClientEnable = cfg.TlsClientEnable
 
 	if brokerConfig.BrokerIP1 == "" {
 		if cfg.BrokerIP == "" {
@@ -191,6 +269,41 @@ func (self *BrokerConfig) HasWriteable() bool {
 	return constant.IsWriteable(self.BrokerPermission)
 }
 
+// ServerTLSOptions broker服务端TLS配置
+// Author: agent
+// Since: 2026/10/19
+func (self *BrokerConfig) ServerTLSOptions() *netm.TLSOptions {
+	return &netm.TLSOptions{
+		Mode:           self.TlsMode,
+		CertFile:       self.TlsCertFile,
+		KeyFile:        self.TlsKeyFile,
+		CAFile:         self.TlsCAFile,
+		ClientAuth:     self.TlsClientAuth,
+		MinVersion:     self.TlsMinVersion,
+		CipherSuites:   netm.SplitCipherSuites(self.TlsCipherSuites),
+		ReloadInterval: self.TlsReloadInterval,
+	}
+}
+
+// ClientTLSOptions broker作为客户端访问namesrv、master时的TLS配置，证书用于双向认证
+// Author: agent
+// Since: 2026/10/19
+func (self *BrokerConfig) ClientTLSOptions() *netm.TLSOptions {
+	if !self.TlsClientEnable {
+		return nil
+	}
+
+	return &netm.TLSOptions{
+		Mode:           netm.TLS_MODE_ENFORCING,
+		CertFile:       self.TlsCertFile,
+		KeyFile:        self.TlsKeyFile,
+		CAFile:         self.TlsCAFile,
+		MinVersion:     self.TlsMinVersion,
+		CipherSuites:   netm.SplitCipherSuites(self.TlsCipherSuites),
+		ReloadInterval: self.TlsReloadInterval,
+	}
+}
+
 // GetDefaultBrokerName 获取默认broker名称
 // Author: tianyuliang
 // Since: 2017/9/29
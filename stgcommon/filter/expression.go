@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	EXPRESSION_TYPE_TAG   = "TAG"   // 按tag过滤，如 tagA || tagB
+	EXPRESSION_TYPE_SQL92 = "SQL92" // 按消息属性过滤，如 region = 'eu' AND firmware > 3
+
+	maxCompiledExpressionCache = 1024
+)
+
+// Expression 编译后的过滤表达式
+// Author: agent
+// Since:  2026/10/19
+type Expression interface {
+	// Evaluate 根据消息属性计算表达式，属性不存在或类型不匹配时结果为false
+	Evaluate(properties map[string]string) bool
+	String() string
+}
+
+var (
+	compiledExpressions     = make(map[string]Expression)
+	compiledExpressionsLock sync.RWMutex
+)
+
+// IsTagType 是否按tag过滤，空类型兼容老版本客户端
+// Author: agent
+// Since:  2026/10/19
+func IsTagType(expressionType string) bool {
+	return expressionType == "" || strings.EqualFold(expressionType, EXPRESSION_TYPE_TAG)
+}
+
+// IsSql92Type 是否按SQL92表达式过滤
+// Author: agent
+// Since:  2026/10/19
+func IsSql92Type(expressionType string) bool {
+	return strings.EqualFold(expressionType, EXPRESSION_TYPE_SQL92)
+}
+
+// Compile 编译SQL92表达式，编译结果会被缓存，便于broker拉消息时重复使用
+// Author: agent
+// Since:  2026/10/19
+func Compile(expression string) (Expression, error) {
+	compiledExpressionsLock.RLock()
+	expr, ok := compiledExpressions[expression]
+	compiledExpressionsLock.RUnlock()
+	if ok {
+		return expr, nil
+	}
+
+	expr, err := parseSql92(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	compiledExpressionsLock.Lock()
+	if len(compiledExpressions) >= maxCompiledExpressionCache {
+		compiledExpressions = make(map[string]Expression)
+	}
+	compiledExpressions[expression] = expr
+	compiledExpressionsLock.Unlock()
+	return expr, nil
+}
@@ -52,3 +52,22 @@ func BuildSubscriptionData4Ponit(consumerGroup string, topic string, subString s
 	}
 	return subscriptionData, nil
 }
+
+// BuildSubscriptionDataByType 按表达式类型构建订阅信息，SQL92表达式在此校验语法，语法错误直接返回
+// Author: agent
+// Since:  2026/10/19
+func BuildSubscriptionDataByType(consumerGroup string, topic string, subString string, expressionType string) (*heartbeat.SubscriptionData, error) {
+	if IsTagType(expressionType) {
+		return BuildSubscriptionData(consumerGroup, topic, subString)
+	}
+	if !IsSql92Type(expressionType) {
+		return nil, errors.New("unsupported expression type: " + expressionType)
+	}
+
+	if _, err := Compile(subString); err != nil {
+		return nil, err
+	}
+	subscriptionData := &heartbeat.SubscriptionData{Topic: topic, SubString: subString, ExpressionType: EXPRESSION_TYPE_SQL92,
+		TagsSet: set.NewSet(), CodeSet: set.NewSet()}
+	return subscriptionData, nil
+}
@@ -0,0 +1,246 @@
+package filter
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// tri 三值逻辑，属性不存在或类型不匹配时为unknown，与SQL的NULL语义一致
+type tri int8
+
+const (
+	triFalse tri = iota
+	triTrue
+	triUnknown
+)
+
+func toTri(b bool) tri {
+	if b {
+		return triTrue
+	}
+	return triFalse
+}
+
+type literalKind int
+
+const (
+	literalString literalKind = iota
+	literalNumber
+	literalBool
+)
+
+type literal struct {
+	kind literalKind
+	str  string
+	num  float64
+	b    bool
+}
+
+// 属性值与常量比较，返回-1、0、1；类型不匹配时ok为false
+func (lit literal) compare(value string) (int, bool) {
+	switch lit.kind {
+	case literalNumber:
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case num < lit.num:
+			return -1, true
+		case num > lit.num:
+			return 1, true
+		}
+		return 0, true
+	case literalBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return 0, false
+		}
+		if b == lit.b {
+			return 0, true
+		}
+		return 1, true
+	}
+
+	if value == lit.str {
+		return 0, true
+	}
+	return 1, true
+}
+
+// sql92Expression 编译后的SQL92表达式
+// Author: agent
+// Since:  2026/10/19
+type sql92Expression struct {
+	expression string
+	root       sql92Node
+}
+
+func (expr *sql92Expression) Evaluate(properties map[string]string) bool {
+	return expr.root.eval(properties) == triTrue
+}
+
+func (expr *sql92Expression) String() string {
+	return expr.expression
+}
+
+type sql92Node interface {
+	eval(properties map[string]string) tri
+}
+
+type constNode struct {
+	value tri
+}
+
+func (node *constNode) eval(properties map[string]string) tri {
+	return node.value
+}
+
+type andNode struct {
+	left, right sql92Node
+}
+
+func (node *andNode) eval(properties map[string]string) tri {
+	left := node.left.eval(properties)
+	if left == triFalse {
+		return triFalse
+	}
+	right := node.right.eval(properties)
+	if right == triFalse {
+		return triFalse
+	}
+	if left == triUnknown || right == triUnknown {
+		return triUnknown
+	}
+	return triTrue
+}
+
+type orNode struct {
+	left, right sql92Node
+}
+
+func (node *orNode) eval(properties map[string]string) tri {
+	left := node.left.eval(properties)
+	if left == triTrue {
+		return triTrue
+	}
+	right := node.right.eval(properties)
+	if right == triTrue {
+		return triTrue
+	}
+	if left == triUnknown || right == triUnknown {
+		return triUnknown
+	}
+	return triFalse
+}
+
+type notNode struct {
+	node sql92Node
+}
+
+func (node *notNode) eval(properties map[string]string) tri {
+	switch node.node.eval(properties) {
+	case triTrue:
+		return triFalse
+	case triFalse:
+		return triTrue
+	}
+	return triUnknown
+}
+
+type compareNode struct {
+	key   string
+	op    string
+	value literal
+}
+
+func (node *compareNode) eval(properties map[string]string) tri {
+	value, ok := properties[node.key]
+	if !ok {
+		return triUnknown
+	}
+	cmp, ok := node.value.compare(value)
+	if !ok {
+		return triUnknown
+	}
+
+	switch node.op {
+	case "=":
+		return toTri(cmp == 0)
+	case "<>":
+		return toTri(cmp != 0)
+	case ">":
+		return toTri(cmp > 0)
+	case ">=":
+		return toTri(cmp >= 0)
+	case "<":
+		return toTri(cmp < 0)
+	case "<=":
+		return toTri(cmp <= 0)
+	}
+	return triUnknown
+}
+
+type inNode struct {
+	key    string
+	values []literal
+}
+
+func (node *inNode) eval(properties map[string]string) tri {
+	value, ok := properties[node.key]
+	if !ok {
+		return triUnknown
+	}
+	result := triFalse
+	for _, lit := range node.values {
+		cmp, ok := lit.compare(value)
+		if !ok {
+			result = triUnknown
+			continue
+		}
+		if cmp == 0 {
+			return triTrue
+		}
+	}
+	return result
+}
+
+type betweenNode struct {
+	key       string
+	low, high float64
+}
+
+func (node *betweenNode) eval(properties map[string]string) tri {
+	value, ok := properties[node.key]
+	if !ok {
+		return triUnknown
+	}
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return triUnknown
+	}
+	return toTri(num >= node.low && num <= node.high)
+}
+
+type nullNode struct {
+	key string
+	not bool
+}
+
+func (node *nullNode) eval(properties map[string]string) tri {
+	_, ok := properties[node.key]
+	return toTri(ok == node.not)
+}
+
+type likeNode struct {
+	key     string
+	pattern *regexp.Regexp
+}
+
+func (node *likeNode) eval(properties map[string]string) tri {
+	value, ok := properties[node.key]
+	if !ok {
+		return triUnknown
+	}
+	return toTri(node.pattern.MatchString(value))
+}
@@ -0,0 +1,175 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenIn
+	tokenBetween
+	tokenIs
+	tokenNull
+	tokenLike
+	tokenTrue
+	tokenFalse
+)
+
+var sql92Keywords = map[string]tokenType{
+	"AND":     tokenAnd,
+	"OR":      tokenOr,
+	"NOT":     tokenNot,
+	"IN":      tokenIn,
+	"BETWEEN": tokenBetween,
+	"IS":      tokenIs,
+	"NULL":    tokenNull,
+	"LIKE":    tokenLike,
+	"TRUE":    tokenTrue,
+	"FALSE":   tokenFalse,
+}
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t token) String() string {
+	if t.typ == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s' at position %d", t.val, t.pos)
+}
+
+// sql92Lexer 将SQL92表达式切分为token
+// Author: agent
+// Since:  2026/10/19
+type sql92Lexer struct {
+	input []rune
+	pos   int
+}
+
+func (l *sql92Lexer) tokens() ([]token, error) {
+	var tokens []token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.typ == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *sql92Lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.input) {
+		return token{typ: tokenEOF, pos: l.pos}, nil
+	}
+
+	start := l.pos
+	c := l.input[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{typ: tokenLParen, val: "(", pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{typ: tokenRParen, val: ")", pos: start}, nil
+	case c == ',':
+		l.pos++
+		return token{typ: tokenComma, val: ",", pos: start}, nil
+	case c == '=':
+		l.pos++
+		return token{typ: tokenOperator, val: "=", pos: start}, nil
+	case c == '<' || c == '>' || c == '!':
+		l.pos++
+		if l.pos < len(l.input) && (l.input[l.pos] == '=' || (c == '<' && l.input[l.pos] == '>')) {
+			l.pos++
+		}
+		op := string(l.input[start:l.pos])
+		if op == "!" {
+			return token{}, fmt.Errorf("unexpected character '!' at position %d", start)
+		}
+		return token{typ: tokenOperator, val: op, pos: start}, nil
+	case c == '\'':
+		return l.readString()
+	case unicode.IsDigit(c) || (c == '-' && l.pos+1 < len(l.input) && unicode.IsDigit(l.input[l.pos+1])):
+		return l.readNumber()
+	case unicode.IsLetter(c) || c == '_':
+		for l.pos < len(l.input) && isIdentRune(l.input[l.pos]) {
+			l.pos++
+		}
+		word := string(l.input[start:l.pos])
+		if typ, ok := sql92Keywords[strings.ToUpper(word)]; ok {
+			return token{typ: typ, val: word, pos: start}, nil
+		}
+		return token{typ: tokenIdent, val: word, pos: start}, nil
+	}
+
+	return token{}, fmt.Errorf("unexpected character '%c' at position %d", c, start)
+}
+
+// 字符串以单引号包围，两个连续单引号表示一个单引号
+func (l *sql92Lexer) readString() (token, error) {
+	start := l.pos
+	l.pos++
+	var buf bytes.Buffer
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		l.pos++
+		if c != '\'' {
+			buf.WriteRune(c)
+			continue
+		}
+		if l.pos < len(l.input) && l.input[l.pos] == '\'' {
+			buf.WriteRune('\'')
+			l.pos++
+			continue
+		}
+		return token{typ: tokenString, val: buf.String(), pos: start}, nil
+	}
+	return token{}, fmt.Errorf("unterminated string at position %d", start)
+}
+
+func (l *sql92Lexer) readNumber() (token, error) {
+	start := l.pos
+	l.pos++
+	dot := false
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if c == '.' && !dot {
+			dot = true
+		} else if !unicode.IsDigit(c) {
+			break
+		}
+		l.pos++
+	}
+	if l.pos < len(l.input) && isIdentRune(l.input[l.pos]) {
+		return token{}, fmt.Errorf("invalid number at position %d", start)
+	}
+	return token{typ: tokenNumber, val: string(l.input[start:l.pos]), pos: start}, nil
+}
+
+func isIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
@@ -0,0 +1,256 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sql92Parser SQL92表达式语法分析，支持的语法：
+// 布尔运算：AND、OR、NOT、括号；
+// 比较运算：=、<>、!=、>、>=、<、<=，字符串只支持=、<>、!=；
+// BETWEEN num AND num、IN ('a', 'b')、IS NULL、IS NOT NULL、LIKE 'a%'，均可加NOT取反；
+// 常量：'字符串'、数值、TRUE、FALSE。比较运算左边必须为属性名
+// Author: agent
+// Since:  2026/10/19
+type sql92Parser struct {
+	tokens []token
+	pos    int
+}
+
+func parseSql92(expression string) (Expression, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("sql92 expression is empty")
+	}
+
+	lexer := &sql92Lexer{input: []rune(expression)}
+	tokens, err := lexer.tokens()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sql92 expression [%s]: %s", expression, err.Error())
+	}
+
+	parser := &sql92Parser{tokens: tokens}
+	node, err := parser.parseOr()
+	if err == nil && parser.peek().typ != tokenEOF {
+		err = fmt.Errorf("unexpected %s", parser.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid sql92 expression [%s]: %s", expression, err.Error())
+	}
+
+	return &sql92Expression{expression: expression, root: node}, nil
+}
+
+func (p *sql92Parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *sql92Parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.typ != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *sql92Parser) accept(typ tokenType) bool {
+	if p.peek().typ == typ {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sql92Parser) expect(typ tokenType, what string) (token, error) {
+	tok := p.next()
+	if tok.typ != typ {
+		return tok, fmt.Errorf("expect %s but got %s", what, tok)
+	}
+	return tok, nil
+}
+
+func (p *sql92Parser) parseOr() (sql92Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenOr) {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sql92Parser) parseAnd() (sql92Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenAnd) {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sql92Parser) parseNot() (sql92Node, error) {
+	if p.accept(tokenNot) {
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *sql92Parser) parsePrimary() (sql92Node, error) {
+	tok := p.next()
+	switch tok.typ {
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case tokenTrue:
+		return &constNode{value: triTrue}, nil
+	case tokenFalse:
+		return &constNode{value: triFalse}, nil
+	case tokenIdent:
+		return p.parsePredicate(tok.val)
+	}
+	return nil, fmt.Errorf("unexpected %s", tok)
+}
+
+// 解析属性名之后的谓词
+func (p *sql92Parser) parsePredicate(key string) (sql92Node, error) {
+	tok := p.next()
+	switch tok.typ {
+	case tokenOperator:
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		op := tok.val
+		if op == "!=" {
+			op = "<>"
+		}
+		if op != "=" && op != "<>" && lit.kind != literalNumber {
+			return nil, fmt.Errorf("operator %s only supports numeric value, %s", tok.val, tok)
+		}
+		return &compareNode{key: key, op: op, value: lit}, nil
+	case tokenIs:
+		not := p.accept(tokenNot)
+		if _, err := p.expect(tokenNull, "NULL"); err != nil {
+			return nil, err
+		}
+		return &nullNode{key: key, not: not}, nil
+	case tokenNot:
+		node, err := p.parseNegatable(key, p.next())
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node: node}, nil
+	}
+	return p.parseNegatable(key, tok)
+}
+
+// 解析可加NOT的谓词：IN、BETWEEN、LIKE
+func (p *sql92Parser) parseNegatable(key string, tok token) (sql92Node, error) {
+	switch tok.typ {
+	case tokenIn:
+		if _, err := p.expect(tokenLParen, "'('"); err != nil {
+			return nil, err
+		}
+		node := &inNode{key: key}
+		for {
+			lit, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, lit)
+			if !p.accept(tokenComma) {
+				break
+			}
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case tokenBetween:
+		low, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokenAnd, "AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenNode{key: key, low: low, high: high}, nil
+	case tokenLike:
+		pattern, err := p.expect(tokenString, "string")
+		if err != nil {
+			return nil, err
+		}
+		return &likeNode{key: key, pattern: compileLike(pattern.val)}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s after property %s", tok, key)
+}
+
+func (p *sql92Parser) parseLiteral() (literal, error) {
+	tok := p.next()
+	switch tok.typ {
+	case tokenString:
+		return literal{kind: literalString, str: tok.val}, nil
+	case tokenNumber:
+		num, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return literal{}, fmt.Errorf("invalid number %s", tok)
+		}
+		return literal{kind: literalNumber, str: tok.val, num: num}, nil
+	case tokenTrue, tokenFalse:
+		return literal{kind: literalBool, str: strings.ToLower(tok.val), b: tok.typ == tokenTrue}, nil
+	}
+	return literal{}, fmt.Errorf("expect constant but got %s", tok)
+}
+
+func (p *sql92Parser) parseNumber() (float64, error) {
+	lit, err := p.parseLiteral()
+	if err != nil {
+		return 0, err
+	}
+	if lit.kind != literalNumber {
+		return 0, fmt.Errorf("BETWEEN only supports numeric value")
+	}
+	return lit.num, nil
+}
+
+// LIKE模式转换为正则：%匹配任意个字符，_匹配单个字符
+func compileLike(pattern string) *regexp.Regexp {
+	var expr []string
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			expr = append(expr, ".*")
+		case '_':
+			expr = append(expr, ".")
+		default:
+			expr = append(expr, regexp.QuoteMeta(string(c)))
+		}
+	}
+	return regexp.MustCompile("^(?s:" + strings.Join(expr, "") + ")$")
+}
@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestSql92Evaluate(t *testing.T) {
+	props := map[string]string{"region": "eu", "firmware": "5", "type": "alarm", "vip": "true", "name": "sensor-01"}
+	cases := []struct {
+		expression string
+		expect     bool
+	}{
+		{"region = 'eu' AND firmware > 3 AND type IN ('alarm','fault')", true},
+		{"region = 'us' OR firmware >= 5", true},
+		{"region <> 'eu'", false},
+		{"region != 'us'", true},
+		{"firmware BETWEEN 1 AND 4", false},
+		{"firmware NOT BETWEEN 1 AND 4", true},
+		{"type NOT IN ('fault')", true},
+		{"vip = TRUE", true},
+		{"missing IS NULL AND region IS NOT NULL", true},
+		{"name LIKE 'sensor-%'", true},
+		{"name NOT LIKE 'sensor-_'", true},
+		{"NOT (region = 'eu')", false},
+		{"(region = 'eu' OR region = 'us') AND firmware < 10.5", true},
+		{"missing = 'x'", false},
+		{"NOT (missing = 'x')", false},
+		{"missing = 'x' OR region = 'eu'", true},
+		{"region > 3", false},
+		{"TRUE", true},
+	}
+
+	for _, c := range cases {
+		expr, err := Compile(c.expression)
+		if err != nil {
+			t.Fatalf("compile %s: %s", c.expression, err.Error())
+		}
+		if actual := expr.Evaluate(props); actual != c.expect {
+			t.Errorf("evaluate %s expect %t but got %t", c.expression, c.expect, actual)
+		}
+	}
+}
+
+func TestSql92CompileError(t *testing.T) {
+	expressions := []string{
+		"",
+		"region = ",
+		"region = 'eu",
+		"region == 'eu'",
+		"region > 'eu'",
+		"firmware BETWEEN 'a' AND 'b'",
+		"type IN ()",
+		"(region = 'eu'",
+		"region = 'eu' AND",
+		"region 'eu'",
+		"region = 'eu' extra",
+	}
+
+	for _, expression := range expressions {
+		if _, err := Compile(expression); err == nil {
+			t.Errorf("compile %q expect error", expression)
+		}
+	}
+}
+
+func TestBuildSubscriptionDataByType(t *testing.T) {
+	subscriptionData, err := BuildSubscriptionDataByType("group", "topic", "region = 'eu'", EXPRESSION_TYPE_SQL92)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscriptionData.ExpressionType != EXPRESSION_TYPE_SQL92 || subscriptionData.TagsSet.Cardinality() != 0 {
+		t.Fatalf("unexpected subscription: %s", subscriptionData.ToString())
+	}
+
+	if _, err = BuildSubscriptionDataByType("group", "topic", "region = ", EXPRESSION_TYPE_SQL92); err == nil {
+		t.Fatal("expect syntax error")
+	}
+
+	subscriptionData, err = BuildSubscriptionDataByType("group", "topic", "tagA || tagB", "")
+	if err != nil || subscriptionData.TagsSet.Cardinality() != 2 {
+		t.Fatalf("unexpected tag subscription: %v", err)
+	}
+}
@@ -59,6 +59,35 @@ func (self *Message) SetDelayTimeLevel(level int) {
 	self.PutProperty(PROPERTY_DELAY_TIME_LEVEL, strconv.Itoa(level))
 }
 
+// GetDelayTimeLevel 获取延时级别，未设置时返回0
+// Author: agent
+// Since: 2026/10/19
+func (self *Message) GetDelayTimeLevel() int {
+	level, err := strconv.Atoi(self.GetProperty(PROPERTY_DELAY_TIME_LEVEL))
+	if err != nil {
+		return 0
+	}
+	return level
+}
+
+// SetStartDeliverTime 设置开始投递时间(毫秒)，不受延时级别的限制
+// Author: agent
+// Since: 2026/10/19
+func (self *Message) SetStartDeliverTime(timestamp int64) {
+	self.PutProperty(PROPERTY_START_DELIVER_TIME, strconv.FormatInt(timestamp, 10))
+}
+
+// GetStartDeliverTime 获取开始投递时间(毫秒)，未设置时返回0
+// Author: agent
+// Since: 2026/10/19
+func (self *Message) GetStartDeliverTime() int64 {
+	timestamp, err := strconv.ParseInt(self.GetProperty(PROPERTY_START_DELIVER_TIME), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return timestamp
+}
+
 func (self *Message) GetKeys() string {
 	return self.GetProperty(PROPERTY_KEYS)
 }
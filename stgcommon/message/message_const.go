@@ -13,6 +13,9 @@ const (
 	// 消息延时投递时间级别，0表示不延时，大于0表示特定延时级别（具体级别在服务器端定义）
 	PROPERTY_DELAY_TIME_LEVEL = "DELAY"
 
+	// 消息开始投递的绝对时间(毫秒)，不受延时级别的限制，由服务器按延时级别分段调度
+	PROPERTY_START_DELIVER_TIME = "__STARTDELIVERTIME"
+
 
 	// 内部使用
 	PROPERTY_RETRY_TOPIC = "RETRY_TOPIC"
@@ -28,5 +31,55 @@ const (
 	PROPERTY_CORRECTION_FLAG = "CORRECTION_FLAG"
 	PROPERTY_MQ2_FLAG = "MQ2_FLAG"
 	PROPERTY_RECONSUME_TIME = "RECONSUME_TIME"
+
+	// request-reply
+	PROPERTY_CORRELATION_ID          = "CORRELATION_ID"   // 请求与应答的关联ID
+	PROPERTY_MESSAGE_REPLY_TO_CLIENT = "REPLY_TO_CLIENT"  // 应答推送的目标，即请求方的clientId
+	PROPERTY_REQUEST_DEADLINE        = "REQUEST_DEADLINE" // 请求的截止时间(毫秒)，超过后应答被丢弃
+	PROPERTY_MESSAGE_TYPE            = "MSG_TYPE"         // 消息类型
+	REPLY_MESSAGE_FLAG               = "reply"            // 应答消息的MSG_TYPE
+
+	// POP消费
+	PROPERTY_POP_CK = "POP_CK" // POP消息的句柄，确认消息或修改不可见时间时使用
+
+	// MQTT网关
+	PROPERTY_MQTT_TOPIC     = "MQTT_TOPIC"     // 消息对应的MQTT主题，下行投递时按此主题匹配订阅
+	PROPERTY_MQTT_CLIENT_ID = "MQTT_CLIENT_ID" // 发布消息的MQTT客户端ID
+	PROPERTY_MQTT_QOS       = "MQTT_QOS"       // 发布消息的QoS，下行投递时与订阅QoS取较小值
+	PROPERTY_MQTT_RETAIN    = "MQTT_RETAIN"    // 设备发布的保留消息为true
+
+	// 设备点对点推送
+	PROPERTY_DEVICE_ID   = "DEVICE_ID"   // 推送的目标设备ID
+	PROPERTY_DEVICE_HOPS = "DEVICE_HOPS" // 设备已迁移时网关节点间转发的次数
+
+	// CoAP网关，设备POST/PUT的URI路径与MQTT主题共用，写入PROPERTY_MQTT_TOPIC
+	PROPERTY_COAP_ENDPOINT       = "COAP_ENDPOINT"       // 发送请求的CoAP终端地址
+	PROPERTY_COAP_CONTENT_FORMAT = "COAP_CONTENT_FORMAT" // 请求的Content-Format，下行通知时原样带回
+
+	// STOMP网关
+	PROPERTY_STOMP_CONTENT_TYPE = "STOMP_CONTENT_TYPE" // SEND帧的content-type，投递MESSAGE帧时原样带回
+
+	// Kafka协议服务
+	PROPERTY_KAFKA_KEY = "KAFKA_KEY" // Kafka消息的key，base64编码后保存，Fetch时原样带回
+
 	KEY_SEPARATOR = " "
 )
+
+// SYSTEM_PROPERTIES 系统属性，网关不允许客户端以自定义属性的方式设置
+var SYSTEM_PROPERTIES = map[string]bool{
+	PROPERTY_KEYS:                 true,
+	PROPERTY_TAGS:                 true,
+	PROPERTY_WAIT_STORE_MSG_OK:    true,
+	PROPERTY_DELAY_TIME_LEVEL:     true,
+	PROPERTY_START_DELIVER_TIME:   true,
+	PROPERTY_RETRY_TOPIC:          true,
+	PROPERTY_REAL_TOPIC:           true,
+	PROPERTY_REAL_QUEUE_ID:        true,
+	PROPERTY_TRANSACTION_PREPARED: true,
+	PROPERTY_PRODUCER_GROUP:       true,
+	PROPERTY_MIN_OFFSET:           true,
+	PROPERTY_MAX_OFFSET:           true,
+	PROPERTY_ORIGIN_MESSAGE_ID:    true,
+	PROPERTY_RECONSUME_TIME:       true,
+	PROPERTY_POP_CK:               true,
+}
@@ -0,0 +1,79 @@
+package message
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const receiptHandleSeparator = " "
+
+// ReceiptHandle POP消息的句柄，确认消息或修改不可见时间时由客户端原样回传给Broker
+// Author agent
+// Since 2026/10/19
+type ReceiptHandle struct {
+	PopTime       int64  // 消息被POP的时间(毫秒)
+	InvisibleTime int64  // 不可见时间(毫秒)
+	QueueId       int32  // 消息所在队列
+	Offset        int64  // 消息在队列中的逻辑位点
+	BrokerName    string // 消息所在Broker
+	Topic         string // 消息所在topic
+}
+
+// Encode 编码为字符串，字段之间以空格分隔
+// Author agent
+// Since 2026/10/19
+func (handle *ReceiptHandle) Encode() string {
+	return strings.Join([]string{
+		strconv.FormatInt(handle.PopTime, 10),
+		strconv.FormatInt(handle.InvisibleTime, 10),
+		strconv.Itoa(int(handle.QueueId)),
+		strconv.FormatInt(handle.Offset, 10),
+		handle.BrokerName,
+		handle.Topic,
+	}, receiptHandleSeparator)
+}
+
+// NextVisibleTime 消息重新可见的时间(毫秒)
+// Author agent
+// Since 2026/10/19
+func (handle *ReceiptHandle) NextVisibleTime() int64 {
+	return handle.PopTime + handle.InvisibleTime
+}
+
+// DecodeReceiptHandle 解析ReceiptHandle字符串
+// Author agent
+// Since 2026/10/19
+func DecodeReceiptHandle(value string) (*ReceiptHandle, error) {
+	fields := strings.SplitN(value, receiptHandleSeparator, 6)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("invalid receipt handle: %s", value)
+	}
+
+	popTime, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt handle popTime: %s", value)
+	}
+	invisibleTime, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt handle invisibleTime: %s", value)
+	}
+	queueId, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt handle queueId: %s", value)
+	}
+	offset, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt handle offset: %s", value)
+	}
+
+	handle := &ReceiptHandle{
+		PopTime:       popTime,
+		InvisibleTime: invisibleTime,
+		QueueId:       int32(queueId),
+		Offset:        offset,
+		BrokerName:    fields[4],
+		Topic:         fields[5],
+	}
+	return handle, nil
+}
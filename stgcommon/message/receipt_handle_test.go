@@ -0,0 +1,36 @@
+package message
+
+import (
+	"testing"
+)
+
+func TestReceiptHandleEncodeDecode(t *testing.T) {
+	handle := &ReceiptHandle{
+		PopTime:       1512540000000,
+		InvisibleTime: 30000,
+		QueueId:       3,
+		Offset:        128,
+		BrokerName:    "broker-a",
+		Topic:         "TestTopic",
+	}
+
+	decoded, err := DecodeReceiptHandle(handle.Encode())
+	if err != nil {
+		t.Fatalf("Test faild: %s", err.Error())
+	}
+	if *decoded != *handle {
+		t.Errorf("Test faild: decoded %v, expect %v", *decoded, *handle)
+	}
+	if decoded.NextVisibleTime() != 1512540030000 {
+		t.Errorf("Test faild: nextVisibleTime %d invalid", decoded.NextVisibleTime())
+	}
+}
+
+func TestDecodeInvalidReceiptHandle(t *testing.T) {
+	values := []string{"", "1 2 3", "x 30000 3 128 broker-a TestTopic", "1 30000 3 y broker-a TestTopic"}
+	for _, value := range values {
+		if _, err := DecodeReceiptHandle(value); err == nil {
+			t.Errorf("Test faild: %q should be invalid", value)
+		}
+	}
+}
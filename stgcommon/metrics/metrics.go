@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+)
+
+// MetricType Prometheus指标类型
+// Author agent
+// Since 2026/10/19
+type MetricType string
+
+const (
+	COUNTER   MetricType = "counter"
+	GAUGE     MetricType = "gauge"
+	HISTOGRAM MetricType = "histogram"
+)
+
+const (
+	ContentType = "text/plain; version=0.0.4; charset=utf-8" // Prometheus文本格式
+)
+
+// Sample 指标的一个采样点
+//
+// Suffix 用于histogram的 _bucket、_sum、_count 后缀，普通指标为空
+// Labels 按照 name1, value1, name2, value2 ... 的顺序成对存放
+//
+// Author agent
+// Since 2026/10/19
+type Sample struct {
+	Suffix string
+	Labels []string
+	Value  float64
+}
+
+// Family 同名指标集合
+// Author agent
+// Since 2026/10/19
+type Family struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Samples []*Sample
+}
+
+// NewFamily 初始化同名指标集合
+// Author agent
+// Since 2026/10/19
+func NewFamily(name, help string, metricType MetricType) *Family {
+	return &Family{
+		Name:    name,
+		Help:    help,
+		Type:    metricType,
+		Samples: make([]*Sample, 0),
+	}
+}
+
+// Add 添加采样点，labels按照 name, value 成对传入
+// Author agent
+// Since 2026/10/19
+func (family *Family) Add(value float64, labels ...string) *Family {
+	family.Samples = append(family.Samples, &Sample{Labels: labels, Value: value})
+	return family
+}
+
+// AddHistogram 添加一组histogram采样点
+//
+// bounds 为各个bucket的上界(升序)，counts 为落入各个bucket的(非累计)次数，
+// len(counts)必须等于len(bounds)+1，最后一个为超出最大上界的次数
+//
+// Author agent
+// Since 2026/10/19
+func (family *Family) AddHistogram(bounds []float64, counts []int64, sum float64, labels ...string) *Family {
+	cumulative := int64(0)
+	for i, bound := range bounds {
+		if i < len(counts) {
+			cumulative += counts[i]
+		}
+		bucketLabels := append(append([]string{}, labels...), "le", formatFloat(bound))
+		family.Samples = append(family.Samples, &Sample{Suffix: "_bucket", Labels: bucketLabels, Value: float64(cumulative)})
+	}
+	if len(counts) > len(bounds) {
+		cumulative += counts[len(bounds)]
+	}
+
+	infLabels := append(append([]string{}, labels...), "le", "+Inf")
+	family.Samples = append(family.Samples, &Sample{Suffix: "_bucket", Labels: infLabels, Value: float64(cumulative)})
+	family.Samples = append(family.Samples, &Sample{Suffix: "_sum", Labels: labels, Value: sum})
+	family.Samples = append(family.Samples, &Sample{Suffix: "_count", Labels: labels, Value: float64(cumulative)})
+	return family
+}
+
+// Collector 指标收集器，每次抓取时调用
+// Author agent
+// Since 2026/10/19
+type Collector interface {
+	Collect() []*Family
+}
+
+// CollectorFunc 函数形式的指标收集器
+// Author agent
+// Since 2026/10/19
+type CollectorFunc func() []*Family
+
+// Collect 实现Collector接口
+// Author agent
+// Since 2026/10/19
+func (fn CollectorFunc) Collect() []*Family {
+	return fn()
+}
+
+// WriteText 按照Prometheus文本格式输出指标
+// Author agent
+// Since 2026/10/19
+func WriteText(w io.Writer, families []*Family) error {
+	writer := bufio.NewWriter(w)
+	for _, family := range families {
+		if family == nil || len(family.Samples) == 0 {
+			continue
+		}
+
+		writer.WriteString("# HELP ")
+		writer.WriteString(family.Name)
+		writer.WriteString(" ")
+		writer.WriteString(escapeHelp(family.Help))
+		writer.WriteString("\n# TYPE ")
+		writer.WriteString(family.Name)
+		writer.WriteString(" ")
+		writer.WriteString(string(family.Type))
+		writer.WriteString("\n")
+
+		for _, sample := range family.Samples {
+			writer.WriteString(family.Name)
+			writer.WriteString(sample.Suffix)
+			if len(sample.Labels) > 1 {
+				writer.WriteString("{")
+				for i := 0; i+1 < len(sample.Labels); i += 2 {
+					if i > 0 {
+						writer.WriteString(",")
+					}
+					writer.WriteString(sample.Labels[i])
+					writer.WriteString("=\"")
+					writer.WriteString(escapeLabelValue(sample.Labels[i+1]))
+					writer.WriteString("\"")
+				}
+				writer.WriteString("}")
+			}
+			writer.WriteString(" ")
+			writer.WriteString(formatFloat(sample.Value))
+			writer.WriteString("\n")
+		}
+	}
+	return writer.Flush()
+}
+
+// Handler 构建/metrics的http处理器，按照指标名称排序输出
+// Author agent
+// Since 2026/10/19
+func Handler(collectors ...Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families := make([]*Family, 0)
+		for _, collector := range collectors {
+			families = append(families, collector.Collect()...)
+		}
+		sort.SliceStable(families, func(i, j int) bool {
+			return families[i].Name < families[j].Name
+		})
+
+		w.Header().Set("Content-Type", ContentType)
+		if err := WriteText(w, families); err != nil {
+			logger.Errorf("write metrics err: %s", err.Error())
+		}
+	})
+}
+
+// formatFloat 格式化指标数值
+// Author agent
+// Since 2026/10/19
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer("\\", `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer("\\", `\\`, "\n", `\n`, "\"", `\"`)
+)
+
+// escapeHelp 转义HELP文本
+// Author agent
+// Since 2026/10/19
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+// escapeLabelValue 转义label值
+// Author agent
+// Since 2026/10/19
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
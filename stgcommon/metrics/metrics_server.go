@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+const (
+	MetricsPath = "/metrics"
+)
+
+// Server 对外提供/metrics抓取的HTTP服务
+// Author agent
+// Since 2026/10/19
+type Server struct {
+	addr       string
+	collectors []Collector
+	listener   net.Listener
+	server     *http.Server
+}
+
+// NewServer 初始化指标HTTP服务
+// Author agent
+// Since 2026/10/19
+func NewServer(addr string, collectors ...Collector) *Server {
+	return &Server{
+		addr:       addr,
+		collectors: collectors,
+	}
+}
+
+// Start 启动指标HTTP服务
+// Author agent
+// Since 2026/10/19
+func (self *Server) Start() error {
+	listener, err := net.Listen("tcp", self.addr)
+	if err != nil {
+		return err
+	}
+	self.listener = listener
+
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, Handler(self.collectors...))
+	self.server = &http.Server{Handler: mux}
+
+	go func() {
+		defer utils.RecoveredFn()
+		if err := self.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("metrics server serve err: %s", err.Error())
+		}
+	}()
+
+	logger.Infof("metrics server start successful, listen %s", listener.Addr().String())
+	return nil
+}
+
+// Shutdown 关闭指标HTTP服务
+// Author agent
+// Since 2026/10/19
+func (self *Server) Shutdown() {
+	if self.server != nil {
+		self.server.Close()
+		logger.Info("metrics server shutdown successful")
+	}
+}
+
+// Addr 实际监听的地址
+// Author agent
+// Since 2026/10/19
+func (self *Server) Addr() string {
+	if self.listener != nil {
+		return self.listener.Addr().String()
+	}
+	return self.addr
+}
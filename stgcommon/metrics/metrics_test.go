@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	family := NewFamily("smartgo_test_total", "test counter\nsecond line", COUNTER)
+	family.Add(3, "topic", "Topic\"A\"", "group", "g1")
+	family.Add(1.5)
+
+	buf := &bytes.Buffer{}
+	if err := WriteText(buf, []*Family{family, NewFamily("smartgo_empty", "no samples", GAUGE)}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "# HELP smartgo_test_total test counter\\nsecond line\n" +
+		"# TYPE smartgo_test_total counter\n" +
+		"smartgo_test_total{topic=\"Topic\\\"A\\\"\",group=\"g1\"} 3\n" +
+		"smartgo_test_total 1.5\n"
+	if buf.String() != expect {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestAddHistogram(t *testing.T) {
+	family := NewFamily("smartgo_latency", "latency", HISTOGRAM)
+	family.AddHistogram([]float64{10, 100}, []int64{1, 2, 3}, 42, "broker", "b1")
+
+	buf := &bytes.Buffer{}
+	WriteText(buf, []*Family{family})
+	out := buf.String()
+
+	for _, line := range []string{
+		"smartgo_latency_bucket{broker=\"b1\",le=\"10\"} 1\n",
+		"smartgo_latency_bucket{broker=\"b1\",le=\"100\"} 3\n",
+		"smartgo_latency_bucket{broker=\"b1\",le=\"+Inf\"} 6\n",
+		"smartgo_latency_sum{broker=\"b1\"} 42\n",
+		"smartgo_latency_count{broker=\"b1\"} 6\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("missing %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	server := NewServer("127.0.0.1:0", CollectorFunc(func() []*Family {
+		return []*Family{NewFamily("smartgo_up", "up", GAUGE).Add(1)}
+	}))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	resp, err := http.Get("http://" + server.Addr() + MetricsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != ContentType || !strings.Contains(string(body), "smartgo_up 1\n") {
+		t.Fatalf("unexpected response %s:\n%s", resp.Header.Get("Content-Type"), string(body))
+	}
+}
@@ -26,6 +26,9 @@ const (
 	CLOUDMQ_HOME_PROPERTY           = "smartgo.home.dir"        // 默认smartgo home地址
 	NAMESRV_ADDR_ENV                = "NAMESRV_ADDR"            // namesrv地址环境变量
 	NAMESRV_PORT_ENV                = "NAMESRV_PORT"            // namesrv端口环境变量
+	NAMESRV_METRICS_ADDR_ENV        = "NAMESRV_METRICS_ADDR"    // namesrv指标服务监听地址环境变量，为空则不启动
+	NAMESRV_ACL_CONFIG_ENV          = "NAMESRV_ACL_CONFIG"      // namesrv的ACL文件路径环境变量，为空则不开启鉴权
+	NAMESRV_TLS_CONFIG_ENV          = "NAMESRV_TLS_CONFIG"      // namesrv的TLS配置文件(json)路径环境变量，为空则不开启TLS
 	NAMESRV_ADDR_PROPERTY           = "cloudmq.namesrv.addr"    // 默认namesrv_addr地址
 	SMARTGO_DATA_PATH_ENV           = "SMARTGO_DATA_PATH"       // broker、store等模块，存取数据的目录
 	SMARTGO_REGISTRY_CONFIG_ENV     = "SMARTGO_REGISTRY_CONFIG" // registry模块的日志配置文件路径
@@ -46,6 +49,10 @@ const (
 	SELF_TEST_CONSUMER_GROUP        = "SELF_TEST_C_GROUP"
 	SELF_TEST_TOPIC                 = "SELF_TEST_TOPIC"
 	OFFSET_MOVED_EVENT              = "OFFSET_MOVED_EVENT"
+	SYS_TRACE_TOPIC                 = "SYS_TRACE_TOPIC"             // 消息轨迹默认存储的Topic
+	TRACE_PRODUCER_GROUP            = "CLIENT_INNER_TRACE_PRODUCER" // 客户端发送消息轨迹使用的内部ProducerGroup
+	DEVICE_MESSAGE_TOPIC            = "DEVICE_MESSAGE_TOPIC"        // 点对点推送给设备的消息默认存储的Topic，tag为设备所在的网关节点名称
+	DEVICE_OFFLINE_TAG              = "%DEVICE_OFFLINE%"            // 设备不在线时推送消息的tag，由任一网关节点写入设备的持久会话
 	DEFAULT_CHARSET                 = "UTF-8"
 	MASTER_ID                       = 0
 	RETRY_GROUP_TOPIC_PREFIX        = "%RETRY%" // 为每个ConsumerGroup建立一个默认的Topic，前缀+GroupName，用来保存处理失败需要重试的消息
@@ -265,6 +272,27 @@ func GetNamesrvPort() string {
 	return strings.TrimSpace(os.Getenv(NAMESRV_PORT_ENV))
 }
 
+// GetNamesrvMetricsAddr 获取环境变量“NAMESRV_METRICS_ADDR”的值
+// Author: agent
+// Since: 2026/10/19
+func GetNamesrvMetricsAddr() string {
+	return strings.TrimSpace(os.Getenv(NAMESRV_METRICS_ADDR_ENV))
+}
+
+// GetNamesrvAclConfig 获取环境变量“NAMESRV_ACL_CONFIG”的值
+// Author: agent
+// Since: 2026/10/19
+func GetNamesrvAclConfig() string {
+	return strings.TrimSpace(os.Getenv(NAMESRV_ACL_CONFIG_ENV))
+}
+
+// GetNamesrvTlsConfig 获取环境变量“NAMESRV_TLS_CONFIG”的值
+// Author: agent
+// Since: 2026/10/19
+func GetNamesrvTlsConfig() string {
+	return strings.TrimSpace(os.Getenv(NAMESRV_TLS_CONFIG_ENV))
+}
+
 // GetSmartGoHome 获取环境变量“SMARTGO_HOME”的值
 // Author: tianyuliang
 // Since: 2017/9/27
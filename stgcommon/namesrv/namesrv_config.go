@@ -19,6 +19,9 @@ const (
 type NamesrvConfig struct {
 	smartgoHome  string
 	kvConfigPath string
+	metricsAddr  string
+	aclConfig    string
+	tlsConfig    string
 }
 
 // NewNamesrvConfig 初始化配置项
@@ -28,6 +31,9 @@ func NewNamesrvConfig() *NamesrvConfig {
 	cfg := &NamesrvConfig{
 		smartgoHome:  getSmartGoHome(),
 		kvConfigPath: getKvConfigPath(),
+		metricsAddr:  stgcommon.GetNamesrvMetricsAddr(),
+		aclConfig:    stgcommon.GetNamesrvAclConfig(),
+		tlsConfig:    stgcommon.GetNamesrvTlsConfig(),
 	}
 	return cfg
 }
@@ -46,6 +52,48 @@ func (self *NamesrvConfig) GetKvConfigPath() string {
 	return self.kvConfigPath
 }
 
+// GetMetricsAddr 获取指标服务监听地址，为空表示不启动指标服务
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvConfig) GetMetricsAddr() string {
+	return self.metricsAddr
+}
+
+// SetMetricsAddr 设置指标服务监听地址
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvConfig) SetMetricsAddr(metricsAddr string) {
+	self.metricsAddr = metricsAddr
+}
+
+// GetAclConfig 获取ACL文件路径，为空表示不开启鉴权
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvConfig) GetAclConfig() string {
+	return self.aclConfig
+}
+
+// SetAclConfig 设置ACL文件路径
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvConfig) SetAclConfig(aclConfig string) {
+	self.aclConfig = aclConfig
+}
+
+// GetTlsConfig 获取TLS配置文件路径，为空表示不开启TLS
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvConfig) GetTlsConfig() string {
+	return self.tlsConfig
+}
+
+// SetTlsConfig 设置TLS配置文件路径
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvConfig) SetTlsConfig(tlsConfig string) {
+	self.tlsConfig = tlsConfig
+}
+
 // GetKvConfigDir 获取Namesrv配置文件完整路径
 // Author: tianyuliang
 // Since: 2017/9/8
@@ -64,7 +112,8 @@ func (self *NamesrvConfig) GetKvConfigName() string {
 // Author: tianyuliang
 // Since: 2017/9/8
 func (self *NamesrvConfig) ToString() string {
-	return fmt.Sprintf("namesrv cfg [kvConfigPath=%s, smartgoHome=%s]", self.kvConfigPath, self.smartgoHome)
+	format := "namesrv cfg [kvConfigPath=%s, smartgoHome=%s, metricsAddr=%s, aclConfig=%s, tlsConfig=%s]"
+	return fmt.Sprintf(format, self.kvConfigPath, self.smartgoHome, self.metricsAddr, self.aclConfig, self.tlsConfig)
 }
 
 // getSmartGoHome 获得默认配置
@@ -0,0 +1,52 @@
+package body
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+)
+
+const (
+	BROKER_CONFIG_SCOPE        = "brokerConfig"       // BrokerConfig配置项
+	MESSAGE_STORE_CONFIG_SCOPE = "messageStoreConfig" // MessageStoreConfig配置项
+)
+
+// BrokerConfigChange broker配置项的一次变更
+// Author agent
+// Since 2026/10/19
+type BrokerConfigChange struct {
+	Scope     string `json:"scope"`     // brokerConfig、messageStoreConfig
+	Key       string `json:"key"`       // 配置项名称
+	OldValue  string `json:"oldValue"`  // 修改前的值
+	NewValue  string `json:"newValue"`  // 修改后的值
+	HotReload bool   `json:"hotReload"` // true:已立即生效；false:已写入配置文件，重启broker后生效
+}
+
+// UpdateBrokerConfigResult 动态修改broker配置的结果
+// Author agent
+// Since 2026/10/19
+type UpdateBrokerConfigResult struct {
+	Changes    []*BrokerConfigChange `json:"changes"`    // 实际发生变化的配置项
+	ConfigFile string                `json:"configFile"` // 变更写入的配置文件，为空表示未持久化
+	*protocol.RemotingSerializable
+}
+
+// NewUpdateBrokerConfigResult 初始化
+// Author agent
+// Since 2026/10/19
+func NewUpdateBrokerConfigResult() *UpdateBrokerConfigResult {
+	return &UpdateBrokerConfigResult{
+		Changes:              make([]*BrokerConfigChange, 0),
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
+
+// RestartRequired 是否存在需要重启broker才能生效的变更
+// Author agent
+// Since 2026/10/19
+func (result *UpdateBrokerConfigResult) RestartRequired() bool {
+	for _, change := range result.Changes {
+		if !change.HotReload {
+			return true
+		}
+	}
+	return false
+}
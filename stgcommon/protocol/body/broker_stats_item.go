@@ -7,4 +7,12 @@ type BrokerStatsItem struct {
 	Sum   int64   `json:"sum"`
 	Tps   float64 `json:"tps"`
 	Avgpt float64 `json:"avgpt"`
+
+	// 以下仅延迟分布统计(如TOPIC_PUT_LATENCY)有值，此时Sum为记录次数，Avgpt为平均耗时，单位均为微秒
+	Tp50  int64 `json:"tp50"`
+	Tp75  int64 `json:"tp75"`
+	Tp90  int64 `json:"tp90"`
+	Tp99  int64 `json:"tp99"`
+	Tp999 int64 `json:"tp999"`
+	Max   int64 `json:"max"`
 }
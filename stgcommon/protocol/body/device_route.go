@@ -0,0 +1,88 @@
+package body
+
+import "git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+
+// DeviceRouteRegisterBody 网关节点本次注册、注销的设备ID
+// Author: agent
+// Since: 2026/10/19
+type DeviceRouteRegisterBody struct {
+	Register   []string `json:"register"`
+	Unregister []string `json:"unregister"`
+	*protocol.RemotingSerializable
+}
+
+// NewDeviceRouteRegisterBody 初始化
+// Author: agent
+// Since: 2026/10/19
+func NewDeviceRouteRegisterBody(register, unregister []string) *DeviceRouteRegisterBody {
+	return &DeviceRouteRegisterBody{
+		Register:             register,
+		Unregister:           unregister,
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
+
+// DeviceRouteQueryBody 批量查询设备路由的设备ID
+// Author: agent
+// Since: 2026/10/19
+type DeviceRouteQueryBody struct {
+	DeviceIds []string `json:"deviceIds"`
+	*protocol.RemotingSerializable
+}
+
+// NewDeviceRouteQueryBody 初始化
+// Author: agent
+// Since: 2026/10/19
+func NewDeviceRouteQueryBody(deviceIds []string) *DeviceRouteQueryBody {
+	return &DeviceRouteQueryBody{
+		DeviceIds:            deviceIds,
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
+
+// DeviceRouteTable 设备路由查询结果，只包含已注册的设备
+// Author: agent
+// Since: 2026/10/19
+type DeviceRouteTable struct {
+	Routes map[string]string `json:"routes"` // key: 设备ID, value: 网关节点名称
+	*protocol.RemotingSerializable
+}
+
+// NewDeviceRouteTable 初始化
+// Author: agent
+// Since: 2026/10/19
+func NewDeviceRouteTable() *DeviceRouteTable {
+	return &DeviceRouteTable{
+		Routes:               make(map[string]string),
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
+
+// DeviceNodeInfo 注册到Namesrv的网关节点
+// Author: agent
+// Since: 2026/10/19
+type DeviceNodeInfo struct {
+	NodeName            string `json:"nodeName"`
+	RemoteAddr          string `json:"remoteAddr"` // 节点最近一次注册使用的连接地址
+	DeviceCount         int64  `json:"deviceCount"`
+	LeaseMillis         int64  `json:"leaseMillis"`
+	LastUpdateTimestamp int64  `json:"lastUpdateTimestamp"`
+}
+
+// DeviceNodeList 网关节点列表
+// Author: agent
+// Since: 2026/10/19
+type DeviceNodeList struct {
+	Nodes []*DeviceNodeInfo `json:"nodes"`
+	*protocol.RemotingSerializable
+}
+
+// NewDeviceNodeList 初始化
+// Author: agent
+// Since: 2026/10/19
+func NewDeviceNodeList() *DeviceNodeList {
+	return &DeviceNodeList{
+		Nodes:                make([]*DeviceNodeInfo, 0),
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
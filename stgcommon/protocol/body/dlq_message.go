@@ -0,0 +1,116 @@
+package body
+
+import "git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+
+// DLQQueueInfo 死信队列的offset范围，MinOffset已经扣除被清除的消息
+// Author agent
+// Since 2026/10/19
+type DLQQueueInfo struct {
+	QueueId   int32 `json:"queueId"`
+	MinOffset int64 `json:"minOffset"`
+	MaxOffset int64 `json:"maxOffset"`
+}
+
+// DLQMessage 死信消息摘要
+// Author agent
+// Since 2026/10/19
+type DLQMessage struct {
+	BrokerName     string            `json:"brokerName"`
+	MsgId          string            `json:"msgId"`
+	OriginMsgId    string            `json:"originMsgId"`
+	OriginTopic    string            `json:"originTopic"` // 原始Topic，即消息属性RETRY_TOPIC
+	QueueId        int32             `json:"queueId"`
+	QueueOffset    int64             `json:"queueOffset"`
+	Tags           string            `json:"tags"`
+	Keys           string            `json:"keys"`
+	ReconsumeTimes int32             `json:"reconsumeTimes"`
+	BornHost       string            `json:"bornHost"`
+	BornTimestamp  int64             `json:"bornTimestamp"`
+	StoreTimestamp int64             `json:"storeTimestamp"`
+	BodySize       int               `json:"bodySize"`
+	Properties     map[string]string `json:"properties"`
+}
+
+// DLQMessageList 单个broker返回的死信消息
+// Author agent
+// Since 2026/10/19
+type DLQMessageList struct {
+	QueueInfos []*DLQQueueInfo `json:"queueInfos"`
+	Messages   []*DLQMessage   `json:"messages"`
+	*protocol.RemotingSerializable
+}
+
+// NewDLQMessageList 初始化
+// Author agent
+// Since 2026/10/19
+func NewDLQMessageList() *DLQMessageList {
+	return &DLQMessageList{
+		QueueInfos:           make([]*DLQQueueInfo, 0),
+		Messages:             make([]*DLQMessage, 0),
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
+
+// DLQMessagePage 消费组在所有broker上的死信消息分页结果
+// Author agent
+// Since 2026/10/19
+type DLQMessagePage struct {
+	ConsumerGroup string        `json:"consumerGroup"`
+	Total         int64         `json:"total"`
+	PageNum       int           `json:"pageNum"`
+	PageSize      int           `json:"pageSize"`
+	Messages      []*DLQMessage `json:"messages"`
+}
+
+// DLQResendResult 死信消息重新投递结果
+// Author agent
+// Since 2026/10/19
+type DLQResendResult struct {
+	Total        int               `json:"total"`
+	Succeed      int               `json:"succeed"`
+	FailedMsgIds map[string]string `json:"failedMsgIds"` // key: msgId, value: 失败原因
+	*protocol.RemotingSerializable
+}
+
+// NewDLQResendResult 初始化
+// Author agent
+// Since 2026/10/19
+func NewDLQResendResult() *DLQResendResult {
+	return &DLQResendResult{
+		FailedMsgIds:         make(map[string]string),
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
+
+// Merge 合并其他broker的投递结果
+// Author agent
+// Since 2026/10/19
+func (result *DLQResendResult) Merge(other *DLQResendResult) {
+	if other == nil {
+		return
+	}
+	result.Total += other.Total
+	result.Succeed += other.Succeed
+	for msgId, reason := range other.FailedMsgIds {
+		result.FailedMsgIds[msgId] = reason
+	}
+}
+
+// DLQPurgeResult 死信消息清除结果
+// Author agent
+// Since 2026/10/19
+type DLQPurgeResult struct {
+	Purged     int64           `json:"purged"`     // 本次清除的消息条数
+	QueueInfos []*DLQQueueInfo `json:"queueInfos"` // 清除后的offset范围
+	*protocol.RemotingSerializable
+}
+
+// NewDLQPurgeResult 初始化
+// Author agent
+// Since 2026/10/19
+func NewDLQPurgeResult() *DLQPurgeResult {
+	return &DLQPurgeResult{
+		QueueInfos:           make([]*DLQQueueInfo, 0),
+		RemotingSerializable: new(protocol.RemotingSerializable),
+	}
+}
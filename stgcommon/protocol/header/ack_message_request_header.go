@@ -0,0 +1,27 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AckMessageRequestHeader 确认POP消息请求头，ExtraInfo为消息的ReceiptHandle
+// Author agent
+// Since 2026/10/19
+type AckMessageRequestHeader struct {
+	ConsumerGroup string `json:"consumerGroup"`
+	Topic         string `json:"topic"`
+	QueueId       int32  `json:"queueId"`
+	Offset        int64  `json:"offset"`
+	ExtraInfo     string `json:"extraInfo"`
+}
+
+func (header *AckMessageRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ConsumerGroup) == "" || strings.TrimSpace(header.Topic) == "" {
+		return fmt.Errorf("consumerGroup and topic can not be empty")
+	}
+	if strings.TrimSpace(header.ExtraInfo) == "" {
+		return fmt.Errorf("extraInfo can not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeInvisibleTimeRequestHeader 修改POP消息不可见时间请求头，ExtraInfo为消息的ReceiptHandle
+// Author agent
+// Since 2026/10/19
+type ChangeInvisibleTimeRequestHeader struct {
+	ConsumerGroup string `json:"consumerGroup"`
+	Topic         string `json:"topic"`
+	QueueId       int32  `json:"queueId"`
+	Offset        int64  `json:"offset"`
+	ExtraInfo     string `json:"extraInfo"`
+	InvisibleTime int64  `json:"invisibleTime"`
+}
+
+func (header *ChangeInvisibleTimeRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ConsumerGroup) == "" || strings.TrimSpace(header.Topic) == "" {
+		return fmt.Errorf("consumerGroup and topic can not be empty")
+	}
+	if strings.TrimSpace(header.ExtraInfo) == "" {
+		return fmt.Errorf("extraInfo can not be empty")
+	}
+	if header.InvisibleTime <= 0 {
+		return fmt.Errorf("invisibleTime must be greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package header
+
+// ChangeInvisibleTimeResponseHeader 修改POP消息不可见时间响应头，返回新的ReceiptHandle所需信息
+// Author agent
+// Since 2026/10/19
+type ChangeInvisibleTimeResponseHeader struct {
+	PopTime       int64 `json:"popTime"`
+	InvisibleTime int64 `json:"invisibleTime"`
+}
+
+func (header *ChangeInvisibleTimeResponseHeader) CheckFields() error {
+	return nil
+}
@@ -0,0 +1,21 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeleteQuotaConfigRequestHeader 删除收发配额的请求头
+// Author agent
+// Since 2026/10/19
+type DeleteQuotaConfigRequestHeader struct {
+	ResourceType string
+	ResourceName string
+}
+
+func (header *DeleteQuotaConfigRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ResourceType) == "" || strings.TrimSpace(header.ResourceName) == "" {
+		return fmt.Errorf("resourceType and resourceName can not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryDLQMessageRequestHeader 分页查询死信消息的请求头，QueueId、Offset指定从死信队列的哪个位置开始读取，MaxNums为0时只返回各个队列的offset范围
+// Author agent
+// Since 2026/10/19
+type QueryDLQMessageRequestHeader struct {
+	ConsumerGroup string `json:"consumerGroup"`
+	QueueId       int32  `json:"queueId"`
+	Offset        int64  `json:"offset"`
+	MaxNums       int32  `json:"maxNums"`
+}
+
+func (header *QueryDLQMessageRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ConsumerGroup) == "" {
+		return fmt.Errorf("consumerGroup is empty")
+	}
+	return nil
+}
+
+// ResendDLQMessageRequestHeader 重新投递死信消息的请求头，MsgIds为逗号分隔的消息ID，为空表示重新投递该消费组在此broker上的全部死信消息
+// Author agent
+// Since 2026/10/19
+type ResendDLQMessageRequestHeader struct {
+	ConsumerGroup string `json:"consumerGroup"`
+	MsgIds        string `json:"msgIds"`
+}
+
+func (header *ResendDLQMessageRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ConsumerGroup) == "" {
+		return fmt.Errorf("consumerGroup is empty")
+	}
+	return nil
+}
+
+// PurgeDLQMessageRequestHeader 清除死信消息的请求头，Offset大于等于0时清除队列offset小于Offset的消息，否则Timestamp大于0时清除存储时间早于Timestamp的消息
+// Author agent
+// Since 2026/10/19
+type PurgeDLQMessageRequestHeader struct {
+	ConsumerGroup string `json:"consumerGroup"`
+	Offset        int64  `json:"offset"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+func (header *PurgeDLQMessageRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ConsumerGroup) == "" {
+		return fmt.Errorf("consumerGroup is empty")
+	}
+	if header.Offset < 0 && header.Timestamp <= 0 {
+		return fmt.Errorf("either offset or timestamp must be specified")
+	}
+	return nil
+}
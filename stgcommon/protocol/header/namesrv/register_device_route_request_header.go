@@ -0,0 +1,25 @@
+package namesrv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegisterDeviceRouteRequestHeader 网关节点注册、注销设备连接-请求头
+// Author: agent
+// Since: 2026/10/19
+type RegisterDeviceRouteRequestHeader struct {
+	NodeName    string // 网关节点名称，同时作为推送给该节点的消息tag
+	LeaseMillis int64  // 节点租约时长，超过该时间未续约时删除节点的全部设备路由，0表示使用Namesrv默认值
+	Reset       bool   // 全量同步的第一批：注册前先清除该节点已有的设备路由
+}
+
+func (header *RegisterDeviceRouteRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.NodeName) == "" {
+		return fmt.Errorf("RegisterDeviceRouteRequestHeader.NodeName is empty")
+	}
+	if header.LeaseMillis < 0 {
+		return fmt.Errorf("RegisterDeviceRouteRequestHeader.LeaseMillis is negative")
+	}
+	return nil
+}
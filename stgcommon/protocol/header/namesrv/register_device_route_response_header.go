@@ -0,0 +1,13 @@
+package namesrv
+
+// RegisterDeviceRouteResponseHeader 网关节点注册、注销设备连接-响应头
+// Author: agent
+// Since: 2026/10/19
+type RegisterDeviceRouteResponseHeader struct {
+	NeedFullSync bool  // Namesrv没有该节点的设备路由(Namesrv重启或节点租约已过期)，节点须全量同步
+	DeviceCount  int64 // 注册后该节点的设备数
+}
+
+func (header *RegisterDeviceRouteResponseHeader) CheckFields() error {
+	return nil
+}
@@ -0,0 +1,20 @@
+package namesrv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnRegisterDeviceNodeRequestHeader 网关节点下线-请求头
+// Author: agent
+// Since: 2026/10/19
+type UnRegisterDeviceNodeRequestHeader struct {
+	NodeName string // 网关节点名称
+}
+
+func (header *UnRegisterDeviceNodeRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.NodeName) == "" {
+		return fmt.Errorf("UnRegisterDeviceNodeRequestHeader.NodeName is empty")
+	}
+	return nil
+}
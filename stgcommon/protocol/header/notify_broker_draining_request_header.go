@@ -0,0 +1,21 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotifyBrokerDrainingRequestHeader Broker通知客户端自己正在下线的请求头
+// Author agent
+// Since 2026/10/19
+type NotifyBrokerDrainingRequestHeader struct {
+	BrokerName string `json:"brokerName"`
+	BrokerAddr string `json:"brokerAddr"`
+}
+
+func (header *NotifyBrokerDrainingRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.BrokerName) == "" {
+		return fmt.Errorf("brokerName can not be empty")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PopMessageRequestHeader POP消息请求头，QueueId为-1时由Broker在topic的所有队列中选取
+// Author agent
+// Since 2026/10/19
+type PopMessageRequestHeader struct {
+	ConsumerGroup  string `json:"consumerGroup"`
+	Topic          string `json:"topic"`
+	QueueId        int32  `json:"queueId"`
+	MaxMsgNums     int32  `json:"maxMsgNums"`
+	InvisibleTime  int64  `json:"invisibleTime"` // 消息被POP后的不可见时间(毫秒)，超时未确认则重新投递
+	PollTime       int64  `json:"pollTime"`      // 没有消息时Broker最长等待时间(毫秒)
+	BornTime       int64  `json:"bornTime"`
+	ExpressionType string `json:"expressionType"` // 订阅表达式类型：TAG、SQL92
+	Exp            string `json:"exp"`
+}
+
+func (header *PopMessageRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.ConsumerGroup) == "" || strings.TrimSpace(header.Topic) == "" {
+		return fmt.Errorf("consumerGroup and topic can not be empty")
+	}
+	if header.MaxMsgNums <= 0 {
+		return fmt.Errorf("maxMsgNums must be greater than 0")
+	}
+	if header.InvisibleTime <= 0 {
+		return fmt.Errorf("invisibleTime must be greater than 0")
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package header
+
+// PopMessageResponseHeader POP消息响应头
+// Author agent
+// Since 2026/10/19
+type PopMessageResponseHeader struct {
+	PopTime       int64 `json:"popTime"`
+	InvisibleTime int64 `json:"invisibleTime"`
+}
+
+func (header *PopMessageResponseHeader) CheckFields() error {
+	return nil
+}
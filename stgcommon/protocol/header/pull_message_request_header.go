@@ -14,6 +14,7 @@ type PullMessageRequestHeader struct {
 	SuspendTimeoutMillis int    `json:"suspendTimeoutMillis"`
 	Subscription         string `json:"subscription"`
 	SubVersion           int    `json:"subVersion"`
+	ExpressionType       string `json:"expressionType"` // 订阅表达式类型：TAG、SQL92
 }
 
 func (header *PullMessageRequestHeader) CheckFields() error {
@@ -0,0 +1,27 @@
+package header
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReplyMessageRequestHeader 应答消息的请求头，Consumer发送给Broker与Broker推送给请求方共用
+// Author agent
+// Since 2026/10/19
+type ReplyMessageRequestHeader struct {
+	ProducerGroup  string // 应答方所在的组
+	Topic          string // 请求消息的Topic
+	Flag           int32  // 消息标志
+	Properties     string // 消息属性，包含CORRELATION_ID、REPLY_TO_CLIENT
+	BornHost       string // 应答方地址
+	BornTimestamp  int64  // 应答创建时间
+	StoreHost      string // 转发应答的broker地址
+	StoreTimestamp int64  // broker转发应答的时间
+}
+
+func (header *ReplyMessageRequestHeader) CheckFields() error {
+	if strings.TrimSpace(header.Topic) == "" {
+		return fmt.Errorf("ReplyMessageRequestHeader.Topic is empty")
+	}
+	return nil
+}
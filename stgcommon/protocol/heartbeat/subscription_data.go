@@ -17,6 +17,7 @@ type SubscriptionData struct {
 	TagsSet         set.Set `json:"tagsSet"`
 	CodeSet         set.Set `json:"codeSet"`
 	SubVersion      int     `json:"subVersion"`
+	ExpressionType  string  `json:"expressionType"` // 表达式类型：TAG、SQL92，为空表示TAG
 }
 
 type SubscriptionDataPlus struct {
@@ -27,6 +28,7 @@ type SubscriptionDataPlus struct {
 	TagsSet         []string `json:"tagsSet"`
 	CodeSet         []int32  `json:"codeSet"`
 	SubVersion      int      `json:"subVersion"`
+	ExpressionType  string   `json:"expressionType"` // 表达式类型：TAG、SQL92，为空表示TAG
 }
 
 // ToString 格式化订阅信息结构体的内容
@@ -36,8 +38,8 @@ func (self *SubscriptionData) ToString() string {
 	if self == nil {
 		return "SubscriptionData is nil"
 	}
-	format := "SubscriptionData {topic=%s, subString=%s, tagsSet=%s, codeSet=%s, subVersion=%d, classFilterMode=%t}"
-	return fmt.Sprintf(format, self.Topic, self.SubString, self.TagsSet.String(), self.CodeSet.String(), self.SubVersion, self.ClassFilterMode)
+	format := "SubscriptionData {topic=%s, subString=%s, tagsSet=%s, codeSet=%s, subVersion=%d, classFilterMode=%t, expressionType=%s}"
+	return fmt.Sprintf(format, self.Topic, self.SubString, self.TagsSet.String(), self.CodeSet.String(), self.SubVersion, self.ClassFilterMode, self.ExpressionType)
 }
 
 // ToString 格式化订阅信息结构体的内容
@@ -49,6 +51,6 @@ func (self *SubscriptionDataPlus) ToString() string {
 	}
 
 	tags := strings.Join(self.TagsSet, ",")
-	format := "SubscriptionDataPlus {Topic=%s, SubString=%s, TagsSet=[%s], CodeSet=[%v], SubVersion=%d, ClassFilterMode=%t}"
-	return fmt.Sprintf(format, self.Topic, self.SubString, tags, self.CodeSet, self.SubVersion, self.ClassFilterMode)
+	format := "SubscriptionDataPlus {Topic=%s, SubString=%s, TagsSet=[%s], CodeSet=[%v], SubVersion=%d, ClassFilterMode=%t, ExpressionType=%s}"
+	return fmt.Sprintf(format, self.Topic, self.SubString, tags, self.CodeSet, self.SubVersion, self.ClassFilterMode, self.ExpressionType)
 }
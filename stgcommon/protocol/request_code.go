@@ -86,6 +86,23 @@ const (
 	GET_HAS_UNIT_SUB_UNUNIT_TOPIC_LIST   = 313 // 获取含有单元化订阅组的非单元化 Topic 列表
 	CLONE_GROUP_OFFSET                   = 314 // 克隆某一个组的消费进度到新的组
 	VIEW_BROKER_STATS_DATA               = 315 // 查看Broker上的各种统计信息
+	QUERY_DLQ_MESSAGE                    = 316 // 分页查询消费组的死信消息
+	RESEND_DLQ_MESSAGE                   = 317 // 将死信消息重新投递到原始Topic
+	PURGE_DLQ_MESSAGE                    = 318 // 清除指定offset或时间之前的死信消息
+	SEND_REPLY_MESSAGE                   = 319 // Consumer 发送应答消息给Broker
+	PUSH_REPLY_MESSAGE_TO_CLIENT         = 320 // Broker 将应答消息直接推送给请求方
+	UPDATE_QUOTA_CONFIG                  = 321 // 创建或更新Broker上的收发配额
+	GET_ALL_QUOTA_CONFIG                 = 322 // 获取Broker上所有收发配额
+	DELETE_QUOTA_CONFIG                  = 323 // 删除Broker上的收发配额
+	POP_MESSAGE                          = 324 // Consumer POP方式获取消息，由Broker分配队列并设置不可见时间
+	ACK_MESSAGE                          = 325 // Consumer 确认单条POP消息已消费
+	CHANGE_MESSAGE_INVISIBLETIME         = 326 // Consumer 修改单条POP消息的不可见时间
+	DRAIN_BROKER                         = 327 // 优雅下线Broker：摘除写权限、等待生产者迁移、刷盘后退出
+	NOTIFY_BROKER_DRAINING               = 328 // Broker 通知客户端自己正在下线，需要立即更新路由
+	REGISTER_DEVICE_ROUTE                = 329 // 网关节点注册、注销设备连接，同时续约节点租约
+	UNREGISTER_DEVICE_NODE               = 330 // 网关节点下线，删除该节点的全部设备路由
+	QUERY_DEVICE_ROUTE                   = 331 // 批量查询设备所在的网关节点
+	GET_DEVICE_NODE_LIST                 = 332 // 获取注册到Namesrv的网关节点及设备数
 )
 
 func ParseRequest(requestCode int32) string {
@@ -175,4 +192,21 @@ var requestPattern = map[int32]string{
 	313: "GET_HAS_UNIT_SUB_UNUNIT_TOPIC_LIST",
 	314: "CLONE_GROUP_OFFSET",
 	315: "VIEW_BROKER_STATS_DATA",
+	316: "QUERY_DLQ_MESSAGE",
+	317: "RESEND_DLQ_MESSAGE",
+	318: "PURGE_DLQ_MESSAGE",
+	319: "SEND_REPLY_MESSAGE",
+	320: "PUSH_REPLY_MESSAGE_TO_CLIENT",
+	321: "UPDATE_QUOTA_CONFIG",
+	322: "GET_ALL_QUOTA_CONFIG",
+	323: "DELETE_QUOTA_CONFIG",
+	324: "POP_MESSAGE",
+	325: "ACK_MESSAGE",
+	326: "CHANGE_MESSAGE_INVISIBLETIME",
+	327: "DRAIN_BROKER",
+	328: "NOTIFY_BROKER_DRAINING",
+	329: "REGISTER_DEVICE_ROUTE",
+	330: "UNREGISTER_DEVICE_NODE",
+	331: "QUERY_DEVICE_ROUTE",
+	332: "GET_DEVICE_NODE_LIST",
 }
@@ -30,6 +30,8 @@ const (
 	SUBSCRIPTION_NOT_EXIST        = 24  // Broker 订阅关系不存在
 	SUBSCRIPTION_NOT_LATEST       = 25  // Broker 订阅关系不是最新的
 	SUBSCRIPTION_GROUP_NOT_EXIST  = 26  // Broker 订阅组不存在
+	QUOTA_EXCEEDED                = 27  // Broker 超出收发配额，ExtFields中携带建议的重试间隔
+	INVALID_RECEIPT_HANDLE        = 28  // Broker POP消息的句柄已失效(已确认或已重新投递)
 	TRANSACTION_SHOULD_COMMIT     = 200 // producer 事务应该被提交
 	TRANSACTION_SHOULD_ROLLBACK   = 201 // producer 事务应该被回滚
 	TRANSACTION_STATE_UNKNOW      = 202 // producer 事务状态未知
@@ -71,6 +73,8 @@ var responsePattern = map[int32]string{
 	24:  "SUBSCRIPTION_NOT_EXIST",
 	25:  "SUBSCRIPTION_NOT_LATEST",
 	26:  "SUBSCRIPTION_GROUP_NOT_EXIST",
+	27:  "QUOTA_EXCEEDED",
+	28:  "INVALID_RECEIPT_HANDLE",
 	200: "TRANSACTION_SHOULD_COMMIT",
 	201: "TRANSACTION_SHOULD_ROLLBACK",
 	202: "TRANSACTION_STATE_UNKNOW",
@@ -0,0 +1,87 @@
+package quota
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	RESOURCE_TOPIC          = "TOPIC"          // 按Topic限流
+	RESOURCE_PRODUCER_GROUP = "PRODUCER_GROUP" // 按生产组限流
+	RESOURCE_CONSUMER_GROUP = "CONSUMER_GROUP" // 按消费组限流
+	RESOURCE_CLIENT_ID      = "CLIENT_ID"      // 按客户端ID限流
+
+	RETRY_AFTER_MILLIS = "retryAfterMillis" // 超出配额时，响应ExtFields中建议客户端重试间隔的key
+
+	keySeparator = "@"
+)
+
+var resourceTypes = map[string]bool{
+	RESOURCE_TOPIC:          true,
+	RESOURCE_PRODUCER_GROUP: true,
+	RESOURCE_CONSUMER_GROUP: true,
+	RESOURCE_CLIENT_ID:      true,
+}
+
+// QuotaConfig 某个资源的收发配额，各项取值<=0表示不限制
+// Author agent
+// Since 2026/10/19
+type QuotaConfig struct {
+	ResourceType       string `json:"resourceType"`       // 资源类型：TOPIC、PRODUCER_GROUP、CONSUMER_GROUP、CLIENT_ID
+	ResourceName       string `json:"resourceName"`       // 资源名称
+	SendMsgPerSecond   int64  `json:"sendMsgPerSecond"`   // 每秒允许发送的消息条数
+	SendBytesPerSecond int64  `json:"sendBytesPerSecond"` // 每秒允许发送的消息字节数
+	PullMsgPerSecond   int64  `json:"pullMsgPerSecond"`   // 每秒允许拉取的消息条数
+	PullBytesPerSecond int64  `json:"pullBytesPerSecond"` // 每秒允许拉取的消息字节数
+}
+
+// BuildQuotaKey 构造配额表的key，格式为 resourceType@resourceName
+// Author agent
+// Since 2026/10/19
+func BuildQuotaKey(resourceType, resourceName string) string {
+	return resourceType + keySeparator + resourceName
+}
+
+// Key 配额在配额表中的key
+// Author agent
+// Since 2026/10/19
+func (config *QuotaConfig) Key() string {
+	return BuildQuotaKey(config.ResourceType, config.ResourceName)
+}
+
+// Validate 校验配额配置
+// Author agent
+// Since 2026/10/19
+func (config *QuotaConfig) Validate() error {
+	if config == nil {
+		return fmt.Errorf("quota config is nil")
+	}
+	if !IsValidResourceType(config.ResourceType) {
+		return fmt.Errorf("quota resourceType[%s] invalid", config.ResourceType)
+	}
+	if strings.TrimSpace(config.ResourceName) == "" {
+		return fmt.Errorf("quota resourceName is empty")
+	}
+	return nil
+}
+
+// IsUnlimited 所有配额均未限制
+// Author agent
+// Since 2026/10/19
+func (config *QuotaConfig) IsUnlimited() bool {
+	return config.SendMsgPerSecond <= 0 && config.SendBytesPerSecond <= 0 &&
+		config.PullMsgPerSecond <= 0 && config.PullBytesPerSecond <= 0
+}
+
+func (config *QuotaConfig) ToString() string {
+	format := "QuotaConfig [resourceType=%s, resourceName=%s, sendMsgPerSecond=%d, sendBytesPerSecond=%d, pullMsgPerSecond=%d, pullBytesPerSecond=%d]"
+	return fmt.Sprintf(format, config.ResourceType, config.ResourceName, config.SendMsgPerSecond,
+		config.SendBytesPerSecond, config.PullMsgPerSecond, config.PullBytesPerSecond)
+}
+
+// IsValidResourceType 是否为支持的资源类型
+// Author agent
+// Since 2026/10/19
+func IsValidResourceType(resourceType string) bool {
+	return resourceTypes[resourceType]
+}
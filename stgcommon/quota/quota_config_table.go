@@ -0,0 +1,89 @@
+package quota
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"sync"
+)
+
+// QuotaConfigTable 配额配置表
+// Author agent
+// Since 2026/10/19
+type QuotaConfigTable struct {
+	QuotaConfigTable map[string]*QuotaConfig `json:"quotaConfigTable"` // key: resourceType@resourceName
+	DataVersion      stgcommon.DataVersion   `json:"dataVersion"`
+	sync.RWMutex     `json:"-"`
+}
+
+func NewQuotaConfigTable() *QuotaConfigTable {
+	quotaConfigTable := &QuotaConfigTable{
+		QuotaConfigTable: make(map[string]*QuotaConfig),
+		DataVersion:      *stgcommon.NewDataVersion(),
+	}
+	return quotaConfigTable
+}
+
+func (table *QuotaConfigTable) Size() int {
+	table.RLock()
+	defer table.RUnlock()
+
+	return len(table.QuotaConfigTable)
+}
+
+func (table *QuotaConfigTable) Put(config *QuotaConfig) *QuotaConfig {
+	table.Lock()
+	defer table.Unlock()
+
+	key := config.Key()
+	old := table.QuotaConfigTable[key]
+	table.QuotaConfigTable[key] = config
+	return old
+}
+
+func (table *QuotaConfigTable) Get(resourceType, resourceName string) *QuotaConfig {
+	table.RLock()
+	defer table.RUnlock()
+
+	v, ok := table.QuotaConfigTable[BuildQuotaKey(resourceType, resourceName)]
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+func (table *QuotaConfigTable) Remove(resourceType, resourceName string) *QuotaConfig {
+	table.Lock()
+	defer table.Unlock()
+
+	key := BuildQuotaKey(resourceType, resourceName)
+	v, ok := table.QuotaConfigTable[key]
+	if !ok {
+		return nil
+	}
+
+	delete(table.QuotaConfigTable, key)
+	return v
+}
+
+func (table *QuotaConfigTable) Foreach(fn func(k string, v *QuotaConfig)) {
+	table.RLock()
+	defer table.RUnlock()
+
+	for k, v := range table.QuotaConfigTable {
+		fn(k, v)
+	}
+}
+
+// ClearAndPutAll 清空配额表，再放入全部配额
+// Author agent
+// Since 2026/10/19
+func (table *QuotaConfigTable) ClearAndPutAll(configs map[string]*QuotaConfig) {
+	table.Lock()
+	defer table.Unlock()
+
+	table.QuotaConfigTable = make(map[string]*QuotaConfig, len(configs))
+	for _, config := range configs {
+		if config != nil && config.Validate() == nil {
+			table.QuotaConfigTable[config.Key()] = config
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package quota
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// QuotaExceededError broker返回超出配额时，客户端得到的错误，携带broker建议的重试间隔
+// Author agent
+// Since 2026/10/19
+type QuotaExceededError struct {
+	Remark           string
+	RetryAfterMillis int64
+}
+
+// NewQuotaExceededError 根据响应的remark及ExtFields构造错误
+// Author agent
+// Since 2026/10/19
+func NewQuotaExceededError(remark string, extFields map[string]string) *QuotaExceededError {
+	err := &QuotaExceededError{Remark: remark}
+	if value, ok := extFields[RETRY_AFTER_MILLIS]; ok {
+		if retryAfterMillis, e := strconv.ParseInt(value, 10, 64); e == nil && retryAfterMillis > 0 {
+			err.RetryAfterMillis = retryAfterMillis
+		}
+	}
+	return err
+}
+
+func (err *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded, retry after %dms. %s", err.RetryAfterMillis, err.Remark)
+}
+
+// RetryAfter 建议的重试间隔
+// Author agent
+// Since 2026/10/19
+func (err *QuotaExceededError) RetryAfter() time.Duration {
+	return time.Duration(err.RetryAfterMillis) * time.Millisecond
+}
@@ -0,0 +1,133 @@
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶，按rate每秒匀速补充令牌，桶容量为1秒的令牌数
+// Author agent
+// Since 2026/10/19
+type TokenBucket struct {
+	rate       float64   // 每秒补充的令牌数
+	tokens     float64   // 当前令牌数，允许为负数(透支)
+	lastRefill time.Time // 上次补充令牌的时间
+	lock       sync.Mutex
+}
+
+// NewTokenBucket 创建令牌桶，初始为满桶
+// Author agent
+// Since 2026/10/19
+func NewTokenBucket(rate int64) *TokenBucket {
+	return &TokenBucket{
+		rate:       float64(rate),
+		tokens:     float64(rate),
+		lastRefill: time.Now(),
+	}
+}
+
+// Rate 每秒补充的令牌数
+// Author agent
+// Since 2026/10/19
+func (bucket *TokenBucket) Rate() int64 {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	return int64(bucket.rate)
+}
+
+// SetRate 修改速率，已有令牌数不超过新的桶容量
+// Author agent
+// Since 2026/10/19
+func (bucket *TokenBucket) SetRate(rate int64) {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill(time.Now())
+	bucket.rate = float64(rate)
+	if bucket.tokens > bucket.rate {
+		bucket.tokens = bucket.rate
+	}
+}
+
+// TryAcquire 尝试获取n个令牌，失败时返回建议的等待时长。
+// n大于桶容量时，只要桶是满的就放行，不足部分记为透支，由后续请求偿还
+// Author agent
+// Since 2026/10/19
+func (bucket *TokenBucket) TryAcquire(n int64) (time.Duration, bool) {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill(time.Now())
+	need := float64(n)
+	if need > bucket.rate {
+		need = bucket.rate
+	}
+	if bucket.tokens >= need {
+		bucket.tokens -= float64(n)
+		return 0, true
+	}
+	return bucket.waitFor(need), false
+}
+
+// Consume 强制扣除n个令牌，不足时透支
+// Author agent
+// Since 2026/10/19
+func (bucket *TokenBucket) Consume(n int64) {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill(time.Now())
+	bucket.tokens -= float64(n)
+}
+
+// Refund 归还n个令牌
+// Author agent
+// Since 2026/10/19
+func (bucket *TokenBucket) Refund(n int64) {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.tokens += float64(n)
+	if bucket.tokens > bucket.rate {
+		bucket.tokens = bucket.rate
+	}
+}
+
+// Wait 距离桶内有可用令牌还需等待的时长，0表示当前可用
+// Author agent
+// Since 2026/10/19
+func (bucket *TokenBucket) Wait() time.Duration {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	bucket.refill(time.Now())
+	if bucket.tokens > 0 {
+		return 0
+	}
+	return bucket.waitFor(1)
+}
+
+func (bucket *TokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(bucket.lastRefill).Seconds()
+	bucket.lastRefill = now
+	if elapsed <= 0 {
+		return
+	}
+	bucket.tokens += elapsed * bucket.rate
+	if bucket.tokens > bucket.rate {
+		bucket.tokens = bucket.rate
+	}
+}
+
+// waitFor 令牌数补充到need所需的时长，调用方需持有锁
+func (bucket *TokenBucket) waitFor(need float64) time.Duration {
+	if bucket.rate <= 0 {
+		return time.Second
+	}
+	wait := time.Duration((need - bucket.tokens) / bucket.rate * float64(time.Second))
+	if wait < time.Millisecond {
+		wait = time.Millisecond
+	}
+	return wait
+}
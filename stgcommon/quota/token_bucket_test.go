@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAcquire(t *testing.T) {
+	bucket := NewTokenBucket(10)
+	for i := 0; i < 10; i++ {
+		if _, ok := bucket.TryAcquire(1); !ok {
+			t.Fatalf("acquire %d should pass", i)
+		}
+	}
+
+	wait, ok := bucket.TryAcquire(1)
+	if ok {
+		t.Fatalf("acquire beyond rate should be rejected")
+	}
+	if wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+
+	time.Sleep(wait + 10*time.Millisecond)
+	if _, ok := bucket.TryAcquire(1); !ok {
+		t.Fatalf("acquire after wait should pass")
+	}
+}
+
+func TestTokenBucketOverdraft(t *testing.T) {
+	bucket := NewTokenBucket(100)
+	if _, ok := bucket.TryAcquire(150); !ok {
+		t.Fatalf("a full bucket should admit a request larger than its capacity")
+	}
+	if wait := bucket.Wait(); wait < 400*time.Millisecond {
+		t.Fatalf("overdraft should be paid back first, wait=%v", wait)
+	}
+
+	bucket.Refund(150)
+	if wait := bucket.Wait(); wait != 0 {
+		t.Fatalf("refund should restore tokens, wait=%v", wait)
+	}
+}
+
+func TestTokenBucketSetRate(t *testing.T) {
+	bucket := NewTokenBucket(100)
+	bucket.SetRate(1)
+	if bucket.Rate() != 1 {
+		t.Fatalf("rate not updated")
+	}
+	if _, ok := bucket.TryAcquire(1); !ok {
+		t.Fatalf("first acquire should pass")
+	}
+	if _, ok := bucket.TryAcquire(1); ok {
+		t.Fatalf("tokens should be capped by the new rate")
+	}
+}
+
+func TestQuotaExceededError(t *testing.T) {
+	err := NewQuotaExceededError("topic quota", map[string]string{RETRY_AFTER_MILLIS: "250"})
+	if err.RetryAfter() != 250*time.Millisecond {
+		t.Fatalf("unexpected retry after %v", err.RetryAfter())
+	}
+
+	err = NewQuotaExceededError("topic quota", nil)
+	if err.RetryAfterMillis != 0 {
+		t.Fatalf("missing hint should be 0")
+	}
+}
+
+func TestQuotaConfigTable(t *testing.T) {
+	table := NewQuotaConfigTable()
+	table.Put(&QuotaConfig{ResourceType: RESOURCE_TOPIC, ResourceName: "t1", SendMsgPerSecond: 10})
+	if table.Get(RESOURCE_TOPIC, "t1") == nil || table.Get(RESOURCE_CLIENT_ID, "t1") != nil {
+		t.Fatalf("get by resource type failed")
+	}
+
+	table.ClearAndPutAll(map[string]*QuotaConfig{
+		"x": {ResourceType: RESOURCE_CONSUMER_GROUP, ResourceName: "g1"},
+		"y": {ResourceType: "UNKNOWN", ResourceName: "g2"},
+	})
+	if table.Size() != 1 || table.Get(RESOURCE_CONSUMER_GROUP, "g1") == nil {
+		t.Fatalf("ClearAndPutAll should keep only valid configs")
+	}
+}
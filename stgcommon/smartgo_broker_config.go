@@ -21,6 +21,28 @@ type SmartgoBrokerConfig struct {
 	AutoCreateTopicEnable bool   // 是否允许客户端自动创建Topic
 	StorePathRootDir      string // broker、store等模块的数据存储目录
 	HaMasterAddress       string // 适用场景：HA功能配置(将slave角色的 ha地址，指向master角色)
+	DebugServerEnable     bool   // 是否开启调试HTTP服务
+	DebugServerAddr       string // 调试HTTP服务监听地址，默认127.0.0.1:10915
+	MetricsServerEnable   bool   // 是否开启指标(Prometheus)HTTP服务
+	MetricsServerAddr     string // 指标HTTP服务监听地址，默认0.0.0.0:10916
+	KafkaServerEnable     bool   // 是否开启Kafka协议服务
+	KafkaServerAddr       string // Kafka协议服务监听地址，默认0.0.0.0:9092
+	AclEnable             bool   // 是否开启ACL鉴权
+	AclConfigPath         string // ACL文件路径，默认$SMARTGO_HOME/conf/plain_acl.json
+	AccessKey             string // broker访问namesrv、master时使用的accessKey
+	SecretKey             string // broker访问namesrv、master时使用的secretKey
+	TlsMode               string // 服务端TLS模式：disabled、permissive(同端口兼容明文)、enforcing
+	TlsCertFile           string // TLS证书文件
+	TlsKeyFile            string // TLS私钥文件
+	TlsCAFile             string // TLS CA证书文件
+	TlsClientAuth         bool   // 是否要求客户端证书
+	TlsMinVersion         string // TLS最低版本，默认1.2
+	TlsCipherSuites       string // TLS加密套件，多个以逗号分隔
+	TlsReloadInterval     int    // 证书热加载检查间隔，单位秒
+	TlsClientEnable       bool   // broker访问namesrv、master时是否使用TLS
+
+	BrokerConfigOverrides       map[string]interface{} `toml:"brokerConfig"`       // [brokerConfig]段，动态修改的BrokerConfig配置项，启动时覆盖默认值
+	MessageStoreConfigOverrides map[string]interface{} `toml:"messageStoreConfig"` // [messageStoreConfig]段，动态修改的MessageStoreConfig配置项
 }
 
 // ToString 打印smartgoBroker配置项
@@ -32,9 +54,15 @@ func (self *SmartgoBrokerConfig) ToString() string {
 	}
 
 	format := "SmartgoBrokerConfig [BrokerClusterName=%s, BrokerName=%s, BrokerId=%d, BrokerPort=%d, BrokerIP=%s, DeleteWhen=%d, "
-	format += "FileReservedTime=%d, BrokerRole=%s, FlushDiskType=%s, AutoCreateTopicEnable=%t, StorePathRootDir=%s, HaMasterAddress=%s ]"
+	format += "FileReservedTime=%d, BrokerRole=%s, FlushDiskType=%s, AutoCreateTopicEnable=%t, StorePathRootDir=%s, HaMasterAddress=%s, "
+	format += "DebugServerEnable=%t, DebugServerAddr=%s, MetricsServerEnable=%t, MetricsServerAddr=%s, "
+	format += "KafkaServerEnable=%t, KafkaServerAddr=%s, "
+	format += "AclEnable=%t, AclConfigPath=%s, AccessKey=%s, TlsMode=%s, TlsClientEnable=%t ]"
 	info := fmt.Sprintf(format, self.BrokerClusterName, self.BrokerName, self.BrokerId, self.BrokerPort, self.BrokerIP, self.DeleteWhen,
-		self.FileReservedTime, self.BrokerRole, self.FlushDiskType, self.AutoCreateTopicEnable, self.StorePathRootDir, self.HaMasterAddress)
+		self.FileReservedTime, self.BrokerRole, self.FlushDiskType, self.AutoCreateTopicEnable, self.StorePathRootDir, self.HaMasterAddress,
+		self.DebugServerEnable, self.DebugServerAddr, self.MetricsServerEnable, self.MetricsServerAddr,
+		self.KafkaServerEnable, self.KafkaServerAddr,
+		self.AclEnable, self.AclConfigPath, self.AccessKey, self.TlsMode, self.TlsClientEnable)
 	return info
 }
 
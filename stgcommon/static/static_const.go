@@ -5,7 +5,11 @@ const (
 	REGISTRY_PORT             = 9876            // registry服务端口
 	BROKER_IP                 = "0.0.0.0"       // 不能设置为127.0.0.1,否则别的集群无法访问当前机器的broker服务
 	BROKER_PORT               = 10911           // broker服务端口
+	BROKER_METRICS_ADDR       = "0.0.0.0:10916"   // broker指标(Prometheus)HTTP服务默认地址
+	BROKER_DEBUG_ADDR         = "127.0.0.1:10915" // broker调试HTTP服务默认地址(默认只绑定本机)
+	BROKER_KAFKA_ADDR         = "0.0.0.0:9092"    // broker Kafka协议服务默认地址
 	BROKER_CONFIG_NAME        = "broker-a.toml" // broker启动配置文件
+	ACL_CONFIG_NAME           = "plain_acl.json" // ACL鉴权配置文件
 	BROKER_DATA_ROOT_DIR      = "store"         // broker数据根目录
 	STORE_COMMIT_LOG_ROOT_DIR = "commitlog"  // store存储数据的commitlog目录
 )
@@ -0,0 +1,187 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// 对数-线性(HDR风格)分桶：小于16的值每个值一个桶，之后每个2的幂区间再均分为16个子桶，
+// 桶宽与所在区间成正比，相对误差不超过1/16，覆盖整个int64范围共960个桶
+const (
+	histogramSubBucketBits  = 4
+	histogramSubBucketCount = 1 << histogramSubBucketBits
+	histogramBucketCount    = (64 - histogramSubBucketBits) * histogramSubBucketCount
+)
+
+// Histogram 延迟分布统计，所有操作均为无锁原子操作
+// Author agent
+// Since 2026/10/19
+type Histogram struct {
+	counts [histogramBucketCount]int64
+	count  int64
+	sum    int64
+	max    int64
+}
+
+// NewHistogram 初始化延迟分布统计
+// Author agent
+// Since 2026/10/19
+func NewHistogram() *Histogram {
+	return new(Histogram)
+}
+
+// Record 记录一个值，负数按0处理
+// Author agent
+// Since 2026/10/19
+func (histogram *Histogram) Record(value int64) {
+	if value < 0 {
+		value = 0
+	}
+	atomic.AddInt64(&histogram.counts[histogramBucketIndex(value)], 1)
+	atomic.AddInt64(&histogram.count, 1)
+	atomic.AddInt64(&histogram.sum, value)
+	for {
+		max := atomic.LoadInt64(&histogram.max)
+		if value <= max || atomic.CompareAndSwapInt64(&histogram.max, max, value) {
+			break
+		}
+	}
+}
+
+// Snapshot 获得从创建至今的累计分布
+// Author agent
+// Since 2026/10/19
+func (histogram *Histogram) Snapshot() *HistogramSnapshot {
+	sample := histogram.sample(0)
+	return computeHistogramSnapshot(&HistogramSample{}, sample, atomic.LoadInt64(&histogram.max))
+}
+
+// sample 采样当前累计值，只保留到最后一个非空的桶
+// Author agent
+// Since 2026/10/19
+func (histogram *Histogram) sample(timestamp int64) *HistogramSample {
+	last := -1
+	counts := make([]int64, histogramBucketCount)
+	for i := range counts {
+		counts[i] = atomic.LoadInt64(&histogram.counts[i])
+		if counts[i] > 0 {
+			last = i
+		}
+	}
+	return &HistogramSample{
+		Timestamp: timestamp,
+		Count:     atomic.LoadInt64(&histogram.count),
+		Sum:       atomic.LoadInt64(&histogram.sum),
+		Counts:    counts[:last+1],
+	}
+}
+
+// HistogramSample 某一时刻的累计分布镜像
+// Author agent
+// Since 2026/10/19
+type HistogramSample struct {
+	Timestamp int64   `json:"timestamp"`
+	Count     int64   `json:"count"`
+	Sum       int64   `json:"sum"`
+	Counts    []int64 `json:"counts"`
+}
+
+// HistogramSnapshot 延迟分布快照，百分位取所在桶的上界
+// Author agent
+// Since 2026/10/19
+type HistogramSnapshot struct {
+	Count int64   `json:"count"` // 记录次数
+	Sum   int64   `json:"sum"`   // 记录值之和
+	Tps   float64 `json:"tps"`   // 每秒记录次数
+	Avg   float64 `json:"avg"`   // 平均值
+	P50   int64   `json:"p50"`
+	P75   int64   `json:"p75"`
+	P90   int64   `json:"p90"`
+	P99   int64   `json:"p99"`
+	P999  int64   `json:"p999"`
+	Max   int64   `json:"max"`
+}
+
+// NewHistogramSnapshot 初始化
+// Author agent
+// Since 2026/10/19
+func NewHistogramSnapshot() *HistogramSnapshot {
+	return new(HistogramSnapshot)
+}
+
+// computeHistogramSnapshot 根据首尾两个镜像计算区间内的分布，max为已知的最大值(未知时传math.MaxInt64)
+// Author agent
+// Since 2026/10/19
+func computeHistogramSnapshot(first, last *HistogramSample, max int64) *HistogramSnapshot {
+	snapshot := NewHistogramSnapshot()
+	snapshot.Count = last.Count - first.Count
+	snapshot.Sum = last.Sum - first.Sum
+	if snapshot.Count <= 0 {
+		snapshot.Count = 0
+		snapshot.Sum = 0
+		return snapshot
+	}
+	if last.Timestamp-first.Timestamp > 0 {
+		snapshot.Tps = float64(snapshot.Count) * 1000.0 / float64(last.Timestamp-first.Timestamp)
+	}
+	snapshot.Avg = float64(snapshot.Sum) / float64(snapshot.Count)
+
+	counts := make([]int64, len(last.Counts))
+	total := int64(0)
+	for i := range counts {
+		counts[i] = last.Counts[i]
+		if i < len(first.Counts) {
+			counts[i] -= first.Counts[i]
+		}
+		total += counts[i]
+	}
+
+	percentile := func(quantile float64) int64 {
+		rank := int64(math.Ceil(quantile * float64(total)))
+		if rank < 1 {
+			rank = 1
+		}
+		cumulative := int64(0)
+		for i, count := range counts {
+			cumulative += count
+			if cumulative >= rank {
+				if bound := histogramBucketUpperBound(i); bound < max {
+					return bound
+				}
+				return max
+			}
+		}
+		return 0
+	}
+	snapshot.P50 = percentile(0.5)
+	snapshot.P75 = percentile(0.75)
+	snapshot.P90 = percentile(0.9)
+	snapshot.P99 = percentile(0.99)
+	snapshot.P999 = percentile(0.999)
+	snapshot.Max = percentile(1)
+	return snapshot
+}
+
+// histogramBucketIndex 计算value所在的桶
+// Author agent
+// Since 2026/10/19
+func histogramBucketIndex(value int64) int {
+	if value < histogramSubBucketCount {
+		return int(value)
+	}
+	shift := bits.Len64(uint64(value)) - histogramSubBucketBits - 1
+	return (shift+1)*histogramSubBucketCount + int((value>>uint(shift))&(histogramSubBucketCount-1))
+}
+
+// histogramBucketUpperBound 计算桶能容纳的最大值
+// Author agent
+// Since 2026/10/19
+func histogramBucketUpperBound(index int) int64 {
+	if index < histogramSubBucketCount {
+		return int64(index)
+	}
+	shift := uint(index/histogramSubBucketCount - 1)
+	sub := int64(index % histogramSubBucketCount)
+	return ((histogramSubBucketCount + sub) << shift) + (1 << shift) - 1
+}
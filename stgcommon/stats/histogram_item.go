@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"sync"
+	"sync/atomic"
+)
+
+// HistogramItem 延迟分布统计单元，与StatsItem一样按分钟、小时、天三个滚动窗口采样
+// Author agent
+// Since 2026/10/19
+type HistogramItem struct {
+	sync.RWMutex
+	Histogram    *Histogram         `json:"-"`
+	CsListMinute []*HistogramSample `json:"-"` // 最近一分钟内的镜像，数量6，10秒钟采样一次
+	CsListHour   []*HistogramSample `json:"-"` // 最近一小时内的镜像，数量6，10分钟采样一次
+	CsListDay    []*HistogramSample `json:"-"` // 最近一天内的镜像，数量24，1小时采样一次
+	StatsName    string             `json:"statsName"`
+	StatsKey     string             `json:"statsKey"`
+	lastRecord   int64              // 最近一次记录的时间(毫秒)，用于清理长期空闲的统计单元
+}
+
+// NewHistogramItem 延迟分布统计单元初始化
+// Author agent
+// Since 2026/10/19
+func NewHistogramItem() *HistogramItem {
+	histogramItem := new(HistogramItem)
+	histogramItem.Histogram = NewHistogram()
+	histogramItem.CsListMinute = make([]*HistogramSample, 0, 8)
+	histogramItem.CsListHour = make([]*HistogramSample, 0, 8)
+	histogramItem.CsListDay = make([]*HistogramSample, 0, 26)
+	histogramItem.lastRecord = timeutil.CurrentTimeMillis()
+	return histogramItem
+}
+
+// Record 记录一个值
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) Record(value int64) {
+	histogramItem.Histogram.Record(value)
+	atomic.StoreInt64(&histogramItem.lastRecord, timeutil.CurrentTimeMillis())
+}
+
+// IdleSince 统计单元自nowMillis之前多少毫秒起没有新的记录
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) IdleSince(nowMillis int64) int64 {
+	return nowMillis - atomic.LoadInt64(&histogramItem.lastRecord)
+}
+
+// Snapshot 获得从创建至今的累计分布
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) Snapshot() *HistogramSnapshot {
+	return histogramItem.Histogram.Snapshot()
+}
+
+// GetSnapshotInMinute 获得最近一分钟的分布
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) GetSnapshotInMinute() *HistogramSnapshot {
+	histogramItem.RLock()
+	defer histogramItem.RUnlock()
+	return histogramItem.computeSnapshot(histogramItem.CsListMinute)
+}
+
+// GetSnapshotInHour 获得最近一小时的分布
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) GetSnapshotInHour() *HistogramSnapshot {
+	histogramItem.RLock()
+	defer histogramItem.RUnlock()
+	return histogramItem.computeSnapshot(histogramItem.CsListHour)
+}
+
+// GetSnapshotInDay 获得最近一天的分布
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) GetSnapshotInDay() *HistogramSnapshot {
+	histogramItem.RLock()
+	defer histogramItem.RUnlock()
+	return histogramItem.computeSnapshot(histogramItem.CsListDay)
+}
+
+// computeSnapshot 根据窗口内首尾两个镜像计算分布
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) computeSnapshot(csList []*HistogramSample) *HistogramSnapshot {
+	if len(csList) == 0 {
+		return NewHistogramSnapshot()
+	}
+	max := atomic.LoadInt64(&histogramItem.Histogram.max)
+	return computeHistogramSnapshot(csList[0], csList[len(csList)-1], max)
+}
+
+// SamplingInSeconds 秒统计单元
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) SamplingInSeconds() {
+	histogramItem.sampling(&histogramItem.CsListMinute, 7)
+}
+
+// SamplingInMinutes 分钟统计单元
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) SamplingInMinutes() {
+	histogramItem.sampling(&histogramItem.CsListHour, 7)
+}
+
+// SamplingInHour 小时统计单元
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) SamplingInHour() {
+	histogramItem.sampling(&histogramItem.CsListDay, 25)
+}
+
+// sampling 采样并追加到窗口，超过maxSize时移除最早的镜像
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) sampling(csList *[]*HistogramSample, maxSize int) {
+	defer utils.RecoveredFn()
+
+	sample := histogramItem.Histogram.sample(timeutil.CurrentTimeMillis())
+	histogramItem.Lock()
+	defer histogramItem.Unlock()
+
+	*csList = append(*csList, sample)
+	if len(*csList) > maxSize {
+		*csList = append((*csList)[:0], (*csList)[len(*csList)-maxSize:]...)
+	}
+}
+
+// PrintAtMinutes 输出分钟统计
+// Author agent
+// Since 2026/10/19
+func (histogramItem *HistogramItem) PrintAtMinutes() {
+	ss := histogramItem.GetSnapshotInMinute()
+	logger.Infof("[%s] [%s] Stats In One Minute, COUNT: %d AVG: %.2f P50: %d P90: %d P99: %d P999: %d MAX: %d",
+		histogramItem.StatsName, histogramItem.StatsKey, ss.Count, ss.Avg, ss.P50, ss.P90, ss.P99, ss.P999, ss.Max)
+}
@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"math"
+	"sync"
+	"time"
+)
+
+// 延迟分布统计维度，值的单位均为微秒
+const (
+	TOPIC_PUT_LATENCY     = "TOPIC_PUT_LATENCY"     // key: topic，SendMessageProcessor处理发送请求的耗时
+	GROUP_GET_LATENCY     = "GROUP_GET_LATENCY"     // key: topic@group，PullMessageProcessor从存储拉取消息的耗时
+	COMMITLOG_PUT_LATENCY = "COMMITLOG_PUT_LATENCY" // key: topic，CommitLog.putMessage的耗时(含同步刷盘等待)
+)
+
+// HistogramIdleExpireMillis 统计单元超过一天没有新的记录时清理，此时天窗口内已没有数据；
+// topic@group等key随topic、消费组的增减不断变化，不清理时内存随出现过的key持续增长
+const HistogramIdleExpireMillis = 24 * 60 * 60 * 1000
+
+// HistogramItemSet 延迟分布统计单元集合
+// Author agent
+// Since 2026/10/19
+type HistogramItemSet struct {
+	sync.RWMutex
+	StatsName            string
+	HistogramItemTable   map[string]*HistogramItem // key: statsKey, val:HistogramItem
+	HistogramItemTickers *timeutil.Tickers         // 采样、打印的定时任务
+}
+
+// NewHistogramItemSet 初始化某个统计维度的延迟分布统计单元集合
+// Author agent
+// Since 2026/10/19
+func NewHistogramItemSet(statsName string) *HistogramItemSet {
+	histogramItemSet := new(HistogramItemSet)
+	histogramItemSet.StatsName = statsName
+	histogramItemSet.HistogramItemTable = make(map[string]*HistogramItem, 128)
+	histogramItemSet.HistogramItemTickers = timeutil.NewTickers()
+	histogramItemSet.Init()
+	return histogramItemSet
+}
+
+// GetAndCreateHistogramItem 创建、获得statsKey的延迟分布统计单元
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) GetAndCreateHistogramItem(statsKey string) *HistogramItem {
+	histograms.RLock()
+	histogramItem, ok := histograms.HistogramItemTable[statsKey]
+	histograms.RUnlock()
+	if ok && histogramItem != nil {
+		return histogramItem
+	}
+
+	histograms.Lock()
+	defer histograms.Unlock()
+
+	histogramItem, ok = histograms.HistogramItemTable[statsKey]
+	if !ok || nil == histogramItem {
+		histogramItem = NewHistogramItem()
+		histogramItem.StatsName = histograms.StatsName
+		histogramItem.StatsKey = statsKey
+		histograms.HistogramItemTable[statsKey] = histogramItem
+	}
+	return histogramItem
+}
+
+// Record statsKey的延迟分布记录一个值
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) Record(statsKey string, value int64) {
+	histograms.GetAndCreateHistogramItem(statsKey).Record(value)
+}
+
+// GetHistogramItem 获得statsKey的延迟分布统计单元
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) GetHistogramItem(statsKey string) *HistogramItem {
+	histograms.RLock()
+	defer histograms.RUnlock()
+
+	return histograms.HistogramItemTable[statsKey]
+}
+
+// GetSnapshotInMinute 获得statsKey最近一分钟的分布
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) GetSnapshotInMinute(statsKey string) *HistogramSnapshot {
+	if histogramItem := histograms.GetHistogramItem(statsKey); histogramItem != nil {
+		return histogramItem.GetSnapshotInMinute()
+	}
+	return NewHistogramSnapshot()
+}
+
+// Init 延迟分布统计单元集合初始化
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) Init() {
+	histograms.HistogramItemTickers.Register("histogramItemSet_samplingInSecondsTicker", timeutil.NewTicker(false, 0, 10*time.Second,
+		func() { histograms.foreach((*HistogramItem).SamplingInSeconds) }))
+
+	histograms.HistogramItemTickers.Register("histogramItemSet_samplingInMinutesTicker", timeutil.NewTicker(false, 0, 10*time.Minute,
+		func() { histograms.foreach((*HistogramItem).SamplingInMinutes) }))
+
+	histograms.HistogramItemTickers.Register("histogramItemSet_samplingInHourTicker", timeutil.NewTicker(false, 0, time.Hour,
+		func() { histograms.foreach((*HistogramItem).SamplingInHour) }))
+
+	diffMin := float64(stgcommon.ComputNextMinutesTimeMillis() - timeutil.CurrentTimeMillis())
+	var delayMin int = int(math.Abs(diffMin))
+	histograms.HistogramItemTickers.Register("histogramItemSet_printAtMinutesTicker", timeutil.NewTicker(false, time.Duration(delayMin)*time.Millisecond,
+		time.Minute, func() { histograms.foreach((*HistogramItem).PrintAtMinutes) }))
+
+	histograms.HistogramItemTickers.Register("histogramItemSet_removeIdleTicker", timeutil.NewTicker(false, time.Hour, time.Hour,
+		func() { histograms.RemoveIdle(timeutil.CurrentTimeMillis(), HistogramIdleExpireMillis) }))
+}
+
+// RemoveIdle 清理超过idleMillis没有新记录的统计单元，返回清理的数量
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) RemoveIdle(nowMillis, idleMillis int64) int {
+	histograms.Lock()
+	defer histograms.Unlock()
+
+	removed := 0
+	for statsKey, item := range histograms.HistogramItemTable {
+		if item.IdleSince(nowMillis) > idleMillis {
+			delete(histograms.HistogramItemTable, statsKey)
+			removed++
+		}
+	}
+	if removed > 0 {
+		logger.Infof("[%s] remove %d idle histogram items", histograms.StatsName, removed)
+	}
+	return removed
+}
+
+// foreach 对所有统计单元执行fn
+// Author agent
+// Since 2026/10/19
+func (histograms *HistogramItemSet) foreach(fn func(histogramItem *HistogramItem)) {
+	histograms.RLock()
+	defer histograms.RUnlock()
+
+	for _, item := range histograms.HistogramItemTable {
+		fn(item)
+	}
+}
@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"testing"
+)
+
+func TestHistogramBucket(t *testing.T) {
+	for _, value := range []int64{0, 1, 15, 16, 17, 31, 32, 33, 100, 1000, 123456, 1 << 40, 1<<63 - 1} {
+		index := histogramBucketIndex(value)
+		if index < 0 || index >= histogramBucketCount {
+			t.Fatalf("value %d index %d out of range", value, index)
+		}
+		upper := histogramBucketUpperBound(index)
+		if upper < value {
+			t.Fatalf("value %d upper bound %d", value, upper)
+		}
+		if value >= histogramSubBucketCount && float64(upper-value) > float64(value)/histogramSubBucketCount {
+			t.Fatalf("value %d upper bound %d exceeds relative error", value, upper)
+		}
+		if index > 0 && histogramBucketUpperBound(index-1) >= value {
+			t.Fatalf("value %d should not fit in bucket %d", value, index-1)
+		}
+	}
+}
+
+func TestHistogramSnapshot(t *testing.T) {
+	histogram := NewHistogram()
+	for i := int64(1); i <= 1000; i++ {
+		histogram.Record(i)
+	}
+
+	snapshot := histogram.Snapshot()
+	if snapshot.Count != 1000 || snapshot.Sum != 500500 || snapshot.Max != 1000 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	for _, c := range []struct{ actual, expect int64 }{
+		{snapshot.P50, 500}, {snapshot.P90, 900}, {snapshot.P99, 990}, {snapshot.P999, 999},
+	} {
+		if c.actual < c.expect || float64(c.actual-c.expect) > float64(c.expect)/histogramSubBucketCount {
+			t.Fatalf("percentile %d, expect about %d", c.actual, c.expect)
+		}
+	}
+}
+
+func TestHistogramItemWindow(t *testing.T) {
+	histogramItem := NewHistogramItem()
+	histogramItem.Record(5000)
+	histogramItem.SamplingInSeconds()
+	for i := 0; i < 100; i++ {
+		histogramItem.Record(10)
+	}
+	histogramItem.SamplingInSeconds()
+
+	// 窗口内只统计两次采样之间的记录
+	snapshot := histogramItem.GetSnapshotInMinute()
+	if snapshot.Count != 100 || snapshot.P99 != 10 || snapshot.Max != 10 {
+		t.Fatalf("unexpected window snapshot %+v", snapshot)
+	}
+
+	for i := 0; i < 10; i++ {
+		histogramItem.SamplingInSeconds()
+	}
+	if len(histogramItem.CsListMinute) != 7 {
+		t.Fatalf("minute window size %d", len(histogramItem.CsListMinute))
+	}
+}
+
+func TestHistogramItemSetRemoveIdle(t *testing.T) {
+	histograms := &HistogramItemSet{StatsName: "GROUP_GET_LATENCY", HistogramItemTable: make(map[string]*HistogramItem)}
+	histograms.Record("TopicA@GroupA", 100)
+	histograms.Record("TopicB@GroupB", 200)
+
+	idle := histograms.GetHistogramItem("TopicA@GroupA")
+	now := idle.lastRecord + HistogramIdleExpireMillis + 1
+	histograms.GetHistogramItem("TopicB@GroupB").lastRecord = now
+
+	if removed := histograms.RemoveIdle(now, HistogramIdleExpireMillis); removed != 1 {
+		t.Fatalf("removed %d idle items, expect 1", removed)
+	}
+	if histograms.GetHistogramItem("TopicA@GroupA") != nil || histograms.GetHistogramItem("TopicB@GroupB") == nil {
+		t.Fatalf("unexpected items %v", histograms.HistogramItemTable)
+	}
+}
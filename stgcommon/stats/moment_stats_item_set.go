@@ -59,6 +59,20 @@ func (moment *MomentStatsItemSet) SetValue(statsKey string, value int64) {
 	atomic.AddInt64(&(statsItem.ValueCounter), value)
 }
 
+// Foreach  遍历所有statsKey的当前数值
+// Author agent
+// Since 2026/10/19
+func (moment *MomentStatsItemSet) Foreach(fn func(statsKey string, value int64)) {
+	moment.RLock()
+	defer moment.RUnlock()
+
+	for statsKey, statsItem := range moment.StatsItemTable {
+		if statsItem != nil {
+			fn(statsKey, atomic.LoadInt64(&statsItem.ValueCounter))
+		}
+	}
+}
+
 // init  init
 // Author rongzhihong
 // Since 2017/9/19
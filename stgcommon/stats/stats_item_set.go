@@ -110,6 +110,20 @@ func (stats *StatsItemSet) GetStatsItem(statsKey string) *StatsItem {
 	return statsItem
 }
 
+// Foreach 遍历所有统计单元的累计值(ValueCounter、TimesCounter)
+// Author agent
+// Since 2026/10/19
+func (stats *StatsItemSet) Foreach(fn func(statsKey string, value, times int64)) {
+	stats.RLock()
+	defer stats.RUnlock()
+
+	for statsKey, statsItem := range stats.StatsItemTable {
+		if statsItem != nil {
+			fn(statsKey, atomic.LoadInt64(&statsItem.ValueCounter), atomic.LoadInt64(&statsItem.TimesCounter))
+		}
+	}
+}
+
 // Init 统计单元集合初始化
 // Author rongzhihong
 // Since 2017/9/19
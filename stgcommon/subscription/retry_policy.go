@@ -0,0 +1,136 @@
+package subscription
+
+import (
+	"fmt"
+	"math"
+)
+
+// RetryPolicyType 重试策略类型
+// Author agent
+// Since 2026/10/19
+type RetryPolicyType string
+
+const (
+	RETRY_POLICY_FIXED       RetryPolicyType = "fixed"       // 固定间隔
+	RETRY_POLICY_EXPONENTIAL RetryPolicyType = "exponential" // 指数退避，不超过最大间隔
+	RETRY_POLICY_CUSTOMIZED  RetryPolicyType = "customized"  // 自定义每次重试的间隔
+)
+
+const (
+	defaultRetryMultiplier = 2.0
+)
+
+// RetryPolicy 订阅组的消费重试策略，间隔单位均为毫秒
+//
+// fixed: 每次重试间隔Delay
+// exponential: 第N次重试间隔Delay*Multiplier^N，不超过MaxDelay，Multiplier未设置时为2
+// customized: 第N次重试间隔Delays[N]，重试次数超过Delays长度时使用最后一个间隔
+//
+// 重试次数上限仍由SubscriptionGroupConfig.RetryMaxTimes控制
+// Author agent
+// Since 2026/10/19
+type RetryPolicy struct {
+	Type       RetryPolicyType `json:"type"`       // 策略类型
+	Delay      int64           `json:"delay"`      // fixed的重试间隔，exponential的首次重试间隔
+	Multiplier float64         `json:"multiplier"` // exponential的增长倍数
+	MaxDelay   int64           `json:"maxDelay"`   // exponential的最大重试间隔
+	Delays     []int64         `json:"delays"`     // customized的重试间隔列表
+}
+
+// NewFixedRetryPolicy 固定间隔的重试策略
+// Author agent
+// Since 2026/10/19
+func NewFixedRetryPolicy(delay int64) *RetryPolicy {
+	return &RetryPolicy{Type: RETRY_POLICY_FIXED, Delay: delay}
+}
+
+// NewExponentialRetryPolicy 指数退避的重试策略
+// Author agent
+// Since 2026/10/19
+func NewExponentialRetryPolicy(initialDelay, maxDelay int64, multiplier float64) *RetryPolicy {
+	return &RetryPolicy{Type: RETRY_POLICY_EXPONENTIAL, Delay: initialDelay, MaxDelay: maxDelay, Multiplier: multiplier}
+}
+
+// NewCustomizedRetryPolicy 自定义间隔的重试策略
+// Author agent
+// Since 2026/10/19
+func NewCustomizedRetryPolicy(delays ...int64) *RetryPolicy {
+	return &RetryPolicy{Type: RETRY_POLICY_CUSTOMIZED, Delays: delays}
+}
+
+// Validate 校验重试策略
+// Author agent
+// Since 2026/10/19
+func (self *RetryPolicy) Validate() error {
+	switch self.Type {
+	case RETRY_POLICY_FIXED:
+		if self.Delay <= 0 {
+			return fmt.Errorf("retry policy %s delay[%d] must be greater than 0", self.Type, self.Delay)
+		}
+	case RETRY_POLICY_EXPONENTIAL:
+		if self.Delay <= 0 {
+			return fmt.Errorf("retry policy %s delay[%d] must be greater than 0", self.Type, self.Delay)
+		}
+		if self.MaxDelay < self.Delay {
+			return fmt.Errorf("retry policy %s maxDelay[%d] must not be less than delay[%d]", self.Type, self.MaxDelay, self.Delay)
+		}
+		if self.Multiplier != 0 && self.Multiplier < 1 {
+			return fmt.Errorf("retry policy %s multiplier[%v] must not be less than 1", self.Type, self.Multiplier)
+		}
+	case RETRY_POLICY_CUSTOMIZED:
+		if len(self.Delays) == 0 {
+			return fmt.Errorf("retry policy %s delays is empty", self.Type)
+		}
+		for _, delay := range self.Delays {
+			if delay <= 0 {
+				return fmt.Errorf("retry policy %s delay[%d] must be greater than 0", self.Type, delay)
+			}
+		}
+	default:
+		return fmt.Errorf("retry policy type[%s] unknown", self.Type)
+	}
+	return nil
+}
+
+// NextDelay 已重试reconsumeTimes次后，下一次重试的间隔(毫秒)
+// Author agent
+// Since 2026/10/19
+func (self *RetryPolicy) NextDelay(reconsumeTimes int32) int64 {
+	if reconsumeTimes < 0 {
+		reconsumeTimes = 0
+	}
+
+	switch self.Type {
+	case RETRY_POLICY_EXPONENTIAL:
+		multiplier := self.Multiplier
+		if multiplier == 0 {
+			multiplier = defaultRetryMultiplier
+		}
+		delay := float64(self.Delay) * math.Pow(multiplier, float64(reconsumeTimes))
+		if delay > float64(self.MaxDelay) {
+			return self.MaxDelay
+		}
+		return int64(delay)
+	case RETRY_POLICY_CUSTOMIZED:
+		if len(self.Delays) == 0 {
+			return 0
+		}
+		if int(reconsumeTimes) >= len(self.Delays) {
+			return self.Delays[len(self.Delays)-1]
+		}
+		return self.Delays[reconsumeTimes]
+	default:
+		return self.Delay
+	}
+}
+
+// ToString 打印RetryPolicy结构体数据
+// Author agent
+// Since 2026/10/19
+func (self *RetryPolicy) ToString() string {
+	if self == nil {
+		return "RetryPolicy is nil"
+	}
+	format := "RetryPolicy {type=%s, delay=%d, multiplier=%v, maxDelay=%d, delays=%v}"
+	return fmt.Sprintf(format, self.Type, self.Delay, self.Multiplier, self.MaxDelay, self.Delays)
+}
@@ -0,0 +1,60 @@
+package subscription
+
+import (
+	"testing"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	fixed := NewFixedRetryPolicy(5000)
+	for _, times := range []int32{0, 1, 10} {
+		if delay := fixed.NextDelay(times); delay != 5000 {
+			t.Errorf("fixed policy NextDelay(%d) = %d, want 5000", times, delay)
+		}
+	}
+
+	exponential := NewExponentialRetryPolicy(1000, 10000, 0)
+	expects := []int64{1000, 2000, 4000, 8000, 10000, 10000}
+	for i, expect := range expects {
+		if delay := exponential.NextDelay(int32(i)); delay != expect {
+			t.Errorf("exponential policy NextDelay(%d) = %d, want %d", i, delay, expect)
+		}
+	}
+	if delay := exponential.NextDelay(1000); delay != 10000 {
+		t.Errorf("exponential policy NextDelay(1000) = %d, want 10000", delay)
+	}
+
+	customized := NewCustomizedRetryPolicy(1000, 60000, 3600000)
+	expects = []int64{1000, 60000, 3600000, 3600000}
+	for i, expect := range expects {
+		if delay := customized.NextDelay(int32(i)); delay != expect {
+			t.Errorf("customized policy NextDelay(%d) = %d, want %d", i, delay, expect)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	valids := []*RetryPolicy{
+		NewFixedRetryPolicy(1000),
+		NewExponentialRetryPolicy(1000, 1000, 1.5),
+		NewCustomizedRetryPolicy(1000, 2000),
+	}
+	for _, policy := range valids {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("%s should be valid, err: %s", policy.ToString(), err.Error())
+		}
+	}
+
+	invalids := []*RetryPolicy{
+		{Type: "linear", Delay: 1000},
+		NewFixedRetryPolicy(0),
+		NewExponentialRetryPolicy(1000, 500, 2),
+		NewExponentialRetryPolicy(1000, 5000, 0.5),
+		NewCustomizedRetryPolicy(),
+		NewCustomizedRetryPolicy(1000, -1),
+	}
+	for _, policy := range invalids {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("%s should be invalid", policy.ToString())
+		}
+	}
+}
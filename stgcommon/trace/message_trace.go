@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"sort"
+)
+
+// MessageTrace 一条消息的完整轨迹：生产、存储节点及各消费组的消费节点，均按时间排序
+// Author agent
+// Since 2026/10/19
+type MessageTrace struct {
+	MsgId     string                `json:"msgId"`     // 消息msgId
+	Topic     string                `json:"topic"`     // 消息topic
+	Producers []*TraceRecord        `json:"producers"` // 发送、存储节点
+	Consumers []*ConsumerGroupTrace `json:"consumers"` // 各消费组的消费节点
+}
+
+// ConsumerGroupTrace 一个消费组对消息的消费轨迹
+// Author agent
+// Since 2026/10/19
+type ConsumerGroupTrace struct {
+	ConsumerGroup string         `json:"consumerGroup"` // 消费组
+	Consumed      bool           `json:"consumed"`      // 是否已消费成功
+	RetryTimes    int32          `json:"retryTimes"`    // 最大重试次数
+	Records       []*TraceRecord `json:"records"`       // 消费节点
+}
+
+// BuildMessageTrace 从查询到的轨迹记录中筛选出指定msgId的记录，重建消息的轨迹
+// Author agent
+// Since 2026/10/19
+func BuildMessageTrace(msgId string, records []*TraceRecord) *MessageTrace {
+	messageTrace := &MessageTrace{
+		MsgId:     msgId,
+		Producers: make([]*TraceRecord, 0),
+		Consumers: make([]*ConsumerGroupTrace, 0),
+	}
+
+	groups := make(map[string]*ConsumerGroupTrace)
+	for _, record := range records {
+		if record == nil || record.MsgId != msgId {
+			continue
+		}
+		if messageTrace.Topic == "" {
+			messageTrace.Topic = record.Topic
+		}
+
+		switch record.TraceType {
+		case TRACE_PUB, TRACE_STORE:
+			messageTrace.Producers = append(messageTrace.Producers, record)
+		default:
+			group, ok := groups[record.Group]
+			if !ok {
+				group = &ConsumerGroupTrace{ConsumerGroup: record.Group, Records: make([]*TraceRecord, 0)}
+				groups[record.Group] = group
+				messageTrace.Consumers = append(messageTrace.Consumers, group)
+			}
+			group.Records = append(group.Records, record)
+			if record.RetryTimes > group.RetryTimes {
+				group.RetryTimes = record.RetryTimes
+			}
+			if record.Success && (record.TraceType == TRACE_SUB_AFTER || record.TraceType == TRACE_ACK) {
+				group.Consumed = true
+			}
+		}
+	}
+
+	sortTraceRecords(messageTrace.Producers)
+	for _, group := range messageTrace.Consumers {
+		sortTraceRecords(group.Records)
+	}
+	sort.Slice(messageTrace.Consumers, func(i, j int) bool {
+		return messageTrace.Consumers[i].ConsumerGroup < messageTrace.Consumers[j].ConsumerGroup
+	})
+	return messageTrace
+}
+
+func sortTraceRecords(records []*TraceRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp < records[j].Timestamp
+	})
+}
@@ -0,0 +1,48 @@
+package trace
+
+import (
+	"encoding/json"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"strings"
+)
+
+// maxTraceKeysLength 一条轨迹消息的索引key总长度上限，超出部分不再建立索引
+const maxTraceKeysLength = 16 * 1024
+
+// EncodeTraceRecords 将一批轨迹记录编码为轨迹消息的body
+// Author agent
+// Since 2026/10/19
+func EncodeTraceRecords(records []*TraceRecord) ([]byte, error) {
+	return json.Marshal(records)
+}
+
+// DecodeTraceRecords 解析轨迹消息的body
+// Author agent
+// Since 2026/10/19
+func DecodeTraceRecords(body []byte) ([]*TraceRecord, error) {
+	records := make([]*TraceRecord, 0)
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// BuildTraceKeys 轨迹消息的keys：一批记录中所有msgId及业务key去重后以空格拼接
+// Author agent
+// Since 2026/10/19
+func BuildTraceKeys(records []*TraceRecord) string {
+	keySet := make(map[string]bool)
+	keys := make([]string, 0)
+	length := 0
+	for _, record := range records {
+		for _, key := range record.IndexKeys() {
+			if keySet[key] || length+len(key)+1 > maxTraceKeysLength {
+				continue
+			}
+			keySet[key] = true
+			keys = append(keys, key)
+			length += len(key) + 1
+		}
+	}
+	return strings.Join(keys, message.KEY_SEPARATOR)
+}
@@ -0,0 +1,156 @@
+package trace
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_TRACE_QUEUE_SIZE     = 10000                  // 轨迹记录缓冲队列大小
+	DEFAULT_TRACE_BATCH_SIZE     = 64                     // 一条轨迹消息最多包含的记录数
+	DEFAULT_TRACE_FLUSH_INTERVAL = 500 * time.Millisecond // 缓冲的记录不足一批时，最长等待时间
+)
+
+// TraceSender 将一批轨迹记录写入轨迹topic
+// Author agent
+// Since 2026/10/19
+type TraceSender interface {
+	SendTrace(records []*TraceRecord) error
+}
+
+// TraceDispatcher 异步批量写轨迹：记录先进入缓冲队列，攒够一批或到达刷新间隔后由后台协程写入轨迹topic。
+// 缓冲队列满时直接丢弃记录，保证轨迹不影响正常收发
+// Author agent
+// Since 2026/10/19
+type TraceDispatcher struct {
+	sender        TraceSender
+	queue         chan *TraceRecord
+	batchSize     int
+	flushInterval time.Duration
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	startOnce     sync.Once
+	stopOnce      sync.Once
+	discardCount  int64
+}
+
+// NewTraceDispatcher 初始化，queueSize、batchSize、flushInterval<=0时使用默认值
+// Author agent
+// Since 2026/10/19
+func NewTraceDispatcher(sender TraceSender, queueSize, batchSize int, flushInterval time.Duration) *TraceDispatcher {
+	if queueSize <= 0 {
+		queueSize = DEFAULT_TRACE_QUEUE_SIZE
+	}
+	if batchSize <= 0 {
+		batchSize = DEFAULT_TRACE_BATCH_SIZE
+	}
+	if flushInterval <= 0 {
+		flushInterval = DEFAULT_TRACE_FLUSH_INTERVAL
+	}
+	return &TraceDispatcher{
+		sender:        sender,
+		queue:         make(chan *TraceRecord, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start 启动后台写轨迹协程
+// Author agent
+// Since 2026/10/19
+func (dispatcher *TraceDispatcher) Start() {
+	dispatcher.startOnce.Do(func() {
+		dispatcher.wg.Add(1)
+		go dispatcher.run()
+	})
+}
+
+// Append 追加一条轨迹记录，缓冲队列已满或已关闭时返回false
+// Author agent
+// Since 2026/10/19
+func (dispatcher *TraceDispatcher) Append(record *TraceRecord) bool {
+	select {
+	case <-dispatcher.stopChan:
+		return false
+	default:
+	}
+
+	select {
+	case dispatcher.queue <- record:
+		return true
+	default:
+		if atomic.AddInt64(&dispatcher.discardCount, 1)%1000 == 1 {
+			logger.Warnf("trace queue is full, discard trace record. discardCount=%d", atomic.LoadInt64(&dispatcher.discardCount))
+		}
+		return false
+	}
+}
+
+// DiscardCount 因缓冲队列已满被丢弃的记录数
+// Author agent
+// Since 2026/10/19
+func (dispatcher *TraceDispatcher) DiscardCount() int64 {
+	return atomic.LoadInt64(&dispatcher.discardCount)
+}
+
+// Shutdown 停止接收新记录，并把缓冲队列中剩余的记录写完
+// Author agent
+// Since 2026/10/19
+func (dispatcher *TraceDispatcher) Shutdown() {
+	dispatcher.stopOnce.Do(func() {
+		close(dispatcher.stopChan)
+	})
+	dispatcher.wg.Wait()
+}
+
+func (dispatcher *TraceDispatcher) run() {
+	defer dispatcher.wg.Done()
+
+	ticker := time.NewTicker(dispatcher.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*TraceRecord, 0, dispatcher.batchSize)
+	for {
+		select {
+		case record := <-dispatcher.queue:
+			batch = append(batch, record)
+			if len(batch) >= dispatcher.batchSize {
+				batch = dispatcher.flush(batch)
+			}
+		case <-ticker.C:
+			batch = dispatcher.flush(batch)
+		case <-dispatcher.stopChan:
+			for {
+				select {
+				case record := <-dispatcher.queue:
+					batch = append(batch, record)
+					if len(batch) >= dispatcher.batchSize {
+						batch = dispatcher.flush(batch)
+					}
+				default:
+					dispatcher.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (dispatcher *TraceDispatcher) flush(batch []*TraceRecord) []*TraceRecord {
+	if len(batch) == 0 {
+		return batch
+	}
+	dispatcher.send(batch)
+	return make([]*TraceRecord, 0, dispatcher.batchSize)
+}
+
+func (dispatcher *TraceDispatcher) send(batch []*TraceRecord) {
+	defer utils.RecoveredFn()
+	if err := dispatcher.sender.SendTrace(batch); err != nil {
+		logger.Warnf("send trace records failed, size=%d, err: %s", len(batch), err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"strings"
+)
+
+// TraceType 消息轨迹节点类型
+// Author agent
+// Since 2026/10/19
+type TraceType string
+
+const (
+	TRACE_PUB        TraceType = "Pub"       // 生产者发送消息(客户端)
+	TRACE_STORE      TraceType = "Store"     // broker存储消息
+	TRACE_PULL       TraceType = "Pull"      // 消费者从broker拉取消息
+	TRACE_SUB_BEFORE TraceType = "SubBefore" // 消费者开始消费(客户端)
+	TRACE_SUB_AFTER  TraceType = "SubAfter"  // 消费者消费完成(客户端)
+	TRACE_ACK        TraceType = "Ack"       // 消费者提交消费进度，消息消费成功
+	TRACE_RECONSUME  TraceType = "Reconsume" // 消费者回传消费失败的消息，等待重试
+)
+
+// TraceRecord 消息轨迹中的一个节点，一条消息在生产、存储、消费各环节分别产生一条记录
+// Author agent
+// Since 2026/10/19
+type TraceRecord struct {
+	TraceType   TraceType `json:"traceType"`   // 节点类型
+	Timestamp   int64     `json:"timestamp"`   // 记录产生的时间
+	Group       string    `json:"group"`       // 生产组或消费组
+	Topic       string    `json:"topic"`       // 消息topic
+	MsgId       string    `json:"msgId"`       // 消息msgId，重试消息记录原始msgId
+	Keys        string    `json:"keys"`        // 消息key
+	Tags        string    `json:"tags"`        // 消息tag
+	ClientHost  string    `json:"clientHost"`  // 生产者、消费者地址
+	ClientId    string    `json:"clientId"`    // 生产者、消费者clientId
+	StoreHost   string    `json:"storeHost"`   // 存储消息的broker地址
+	QueueId     int32     `json:"queueId"`     // 队列
+	QueueOffset int64     `json:"queueOffset"` // 队列偏移量
+	StoreTime   int64     `json:"storeTime"`   // 消息存储时间
+	BodyLength  int       `json:"bodyLength"`  // 消息大小
+	CostTime    int64     `json:"costTime"`    // 发送或消费耗时，单位毫秒
+	Success     bool      `json:"success"`     // 发送、消费是否成功
+	Status      string    `json:"status"`      // 发送、消费结果
+	RetryTimes  int32     `json:"retryTimes"`  // 重试次数
+}
+
+// IndexKeys 轨迹消息的索引key：msgId及消息的业务key，查询轨迹时按这些key检索
+// Author agent
+// Since 2026/10/19
+func (record *TraceRecord) IndexKeys() []string {
+	keys := make([]string, 0)
+	if record.MsgId != "" {
+		keys = append(keys, record.MsgId)
+	}
+	for _, key := range strings.Split(record.Keys, message.KEY_SEPARATOR) {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ToString 打印轨迹节点
+// Author agent
+// Since 2026/10/19
+func (record *TraceRecord) ToString() string {
+	format := "TraceRecord [traceType=%s, timestamp=%d, group=%s, topic=%s, msgId=%s, clientHost=%s, storeHost=%s, "
+	format += "queueId=%d, queueOffset=%d, costTime=%d, success=%t, status=%s, retryTimes=%d]"
+	return fmt.Sprintf(format, record.TraceType, record.Timestamp, record.Group, record.Topic, record.MsgId, record.ClientHost, record.StoreHost,
+		record.QueueId, record.QueueOffset, record.CostTime, record.Success, record.Status, record.RetryTimes)
+}
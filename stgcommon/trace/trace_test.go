@@ -0,0 +1,143 @@
+package trace
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockTraceSender struct {
+	lock    sync.Mutex
+	batches [][]*TraceRecord
+}
+
+func (sender *mockTraceSender) SendTrace(records []*TraceRecord) error {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	sender.batches = append(sender.batches, records)
+	return nil
+}
+
+func (sender *mockTraceSender) count() (int, int) {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	total := 0
+	for _, batch := range sender.batches {
+		total += len(batch)
+	}
+	return len(sender.batches), total
+}
+
+func TestTraceCodec(t *testing.T) {
+	records := []*TraceRecord{
+		{TraceType: TRACE_PUB, MsgId: "msg1", Keys: "order1 order2", Topic: "TopicTest"},
+		{TraceType: TRACE_STORE, MsgId: "msg1", Keys: "order1 order2", Topic: "TopicTest"},
+		{TraceType: TRACE_PUB, MsgId: "msg2", Topic: "TopicTest"},
+	}
+
+	body, err := EncodeTraceRecords(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTraceRecords(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[1].TraceType != TRACE_STORE || decoded[0].Keys != "order1 order2" {
+		t.Errorf("decode trace records failed: %v", decoded)
+	}
+
+	keys := BuildTraceKeys(records)
+	if keys != "msg1 order1 order2 msg2" {
+		t.Errorf("build trace keys failed: %s", keys)
+	}
+}
+
+func TestBuildTraceKeysLimit(t *testing.T) {
+	records := make([]*TraceRecord, 0)
+	for i := 0; i < 2000; i++ {
+		records = append(records, &TraceRecord{MsgId: strings.Repeat("A", 31) + string(rune('a'+i%26)) + strings.Repeat("B", i%10)})
+	}
+	if keys := BuildTraceKeys(records); len(keys) > maxTraceKeysLength {
+		t.Errorf("trace keys too long: %d", len(keys))
+	}
+}
+
+func TestBuildMessageTrace(t *testing.T) {
+	records := []*TraceRecord{
+		{TraceType: TRACE_SUB_AFTER, MsgId: "msg1", Group: "groupB", Timestamp: 40, Success: false, RetryTimes: 0},
+		{TraceType: TRACE_STORE, MsgId: "msg1", Topic: "TopicTest", Timestamp: 20},
+		{TraceType: TRACE_PUB, MsgId: "msg1", Topic: "TopicTest", Timestamp: 10},
+		{TraceType: TRACE_SUB_AFTER, MsgId: "msg1", Group: "groupA", Timestamp: 30, Success: true},
+		{TraceType: TRACE_SUB_AFTER, MsgId: "msg1", Group: "groupB", Timestamp: 50, Success: true, RetryTimes: 1},
+		{TraceType: TRACE_PUB, MsgId: "msg2", Topic: "TopicTest", Timestamp: 10},
+	}
+
+	messageTrace := BuildMessageTrace("msg1", records)
+	if messageTrace.Topic != "TopicTest" || len(messageTrace.Producers) != 2 || messageTrace.Producers[0].TraceType != TRACE_PUB {
+		t.Fatalf("build producer trace failed: %v", messageTrace.Producers)
+	}
+	if len(messageTrace.Consumers) != 2 || messageTrace.Consumers[0].ConsumerGroup != "groupA" {
+		t.Fatalf("build consumer trace failed: %v", messageTrace.Consumers)
+	}
+	groupB := messageTrace.Consumers[1]
+	if !groupB.Consumed || groupB.RetryTimes != 1 || len(groupB.Records) != 2 || groupB.Records[0].Timestamp != 40 {
+		t.Errorf("build groupB trace failed: %v", groupB)
+	}
+}
+
+func TestTraceDispatcherBatch(t *testing.T) {
+	sender := &mockTraceSender{}
+	dispatcher := NewTraceDispatcher(sender, 100, 10, time.Hour)
+	dispatcher.Start()
+	for i := 0; i < 25; i++ {
+		if !dispatcher.Append(&TraceRecord{MsgId: "msg"}) {
+			t.Fatalf("append trace record failed")
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if batches, _ := sender.count(); batches >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 关闭时剩余的记录也要写完
+	dispatcher.Shutdown()
+	batches, total := sender.count()
+	if batches != 3 || total != 25 {
+		t.Errorf("dispatch trace records failed, batches=%d, total=%d", batches, total)
+	}
+	if dispatcher.Append(&TraceRecord{MsgId: "msg"}) {
+		t.Errorf("append after shutdown should fail")
+	}
+}
+
+func TestTraceDispatcherFlushInterval(t *testing.T) {
+	sender := &mockTraceSender{}
+	dispatcher := NewTraceDispatcher(sender, 100, 10, 20*time.Millisecond)
+	dispatcher.Start()
+	defer dispatcher.Shutdown()
+
+	dispatcher.Append(&TraceRecord{MsgId: "msg"})
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, total := sender.count(); total == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("trace record not flushed by interval")
+}
+
+func TestTraceDispatcherDiscard(t *testing.T) {
+	dispatcher := NewTraceDispatcher(&mockTraceSender{}, 2, 10, time.Hour)
+	dispatcher.Append(&TraceRecord{})
+	dispatcher.Append(&TraceRecord{})
+	if dispatcher.Append(&TraceRecord{}) || dispatcher.DiscardCount() != 1 {
+		t.Errorf("trace record should be discarded when queue is full")
+	}
+}
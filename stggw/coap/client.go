@@ -0,0 +1,407 @@
+package coap
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	CLIENT_ACK_TIMEOUT     = time.Second // 客户端CON请求的初始重发间隔，每次重发后加倍
+	CLIENT_MAX_RETRANSMIT  = 4
+	CLIENT_MAX_BUFFER_SIZE = 65536
+)
+
+// Client 简单的CoAP客户端，用于测试及联调网关：CON请求超时重发，
+// 请求体超过BlockSize时以Block1分块上传，响应及通知按Block2读取完整负载，收到的CON响应、通知自动回复ACK
+// Author: agent
+// Since: 2026/10/19
+type Client struct {
+	BlockSize    int // 分块上传的块大小，默认1024
+	conn         *net.UDPConn
+	timeout      time.Duration
+	messageIdSeq uint32
+	acks         map[uint16]chan *Message // 消息ID -> 等待的ACK/RST
+	responses    map[string]chan *Message // token -> 等待的单独响应
+	observations map[string]*Observation  // token -> 观察
+	lock         sync.Mutex
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+// Observation 客户端的一次观察，Notifications返回负载完整的通知
+// Author: agent
+// Since: 2026/10/19
+type Observation struct {
+	client        *Client
+	path          string
+	token         []byte
+	raw           chan *Message
+	notifications chan *Message
+	lastSeq       int64
+	cancelOnce    sync.Once
+}
+
+// DialClient 创建连接网关的客户端，timeout为单次请求(含重发)等待响应的超时时间
+// Author: agent
+// Since: 2026/10/19
+func DialClient(addr string, timeout time.Duration) (*Client, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		BlockSize:    1024,
+		conn:         conn,
+		timeout:      timeout,
+		messageIdSeq: uint32(rand.Intn(65536)),
+		acks:         make(map[uint16]chan *Message),
+		responses:    make(map[string]chan *Message),
+		observations: make(map[string]*Observation),
+		done:         make(chan struct{}),
+	}
+	go client.readLoop()
+	return client, nil
+}
+
+// Close 关闭客户端，不取消观察
+func (client *Client) Close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+		client.conn.Close()
+	})
+}
+
+// Post 以CON发送POST请求
+func (client *Client) Post(path string, payload []byte) (*Message, error) {
+	return client.Send(POST, CON, path, payload)
+}
+
+// Put 以CON发送PUT请求
+func (client *Client) Put(path string, payload []byte) (*Message, error) {
+	return client.Send(PUT, CON, path, payload)
+}
+
+// Send 发送POST/PUT请求，请求体超过BlockSize时分块上传，服务端要求更小的块时按其块大小继续
+// Author: agent
+// Since: 2026/10/19
+func (client *Client) Send(method, msgType byte, path string, payload []byte) (*Message, error) {
+	if len(payload) <= client.BlockSize {
+		req := &Message{Type: msgType, Code: method, Payload: payload}
+		req.SetPath(path)
+		return client.Do(req)
+	}
+
+	szx, ok := BlockSzx(client.BlockSize)
+	if !ok {
+		return nil, fmt.Errorf("invalid block size %d", client.BlockSize)
+	}
+	for offset, num := 0, uint32(0); ; {
+		size := 1 << (szx + 4)
+		end := offset + size
+		if end > len(payload) {
+			end = len(payload)
+		}
+		req := &Message{Type: msgType, Code: method, Payload: payload[offset:end]}
+		req.SetPath(path)
+		req.SetBlock(OPTION_BLOCK1, &Block{Num: num, More: end < len(payload), Szx: szx})
+		if num == 0 {
+			req.SetUintOption(OPTION_SIZE1, uint32(len(payload)))
+		}
+		response, err := client.Do(req)
+		if err != nil || end == len(payload) || response.Code != CODE_CONTINUE {
+			return response, err
+		}
+		if block, ok := response.Block(OPTION_BLOCK1); ok && block.Szx < szx {
+			szx = block.Szx
+		}
+		offset = end
+		num = uint32(offset / (1 << (szx + 4)))
+	}
+}
+
+// Get 发送GET请求，按Block2读取完整负载
+func (client *Client) Get(path string) (*Message, error) {
+	req := &Message{Type: CON, Code: GET}
+	req.SetPath(path)
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return client.fetchBlocks(path, response)
+}
+
+// Observe 注册观察，网关不接受时返回error
+// Author: agent
+// Since: 2026/10/19
+func (client *Client) Observe(path string) (*Observation, error) {
+	observation := &Observation{
+		client:        client,
+		path:          path,
+		token:         client.newToken(),
+		raw:           make(chan *Message, 1024),
+		notifications: make(chan *Message, 1024),
+		lastSeq:       -1,
+	}
+	client.lock.Lock()
+	client.observations[string(observation.token)] = observation
+	client.lock.Unlock()
+
+	req := &Message{Type: CON, Code: GET, Token: observation.token}
+	req.SetPath(path)
+	req.SetUintOption(OPTION_OBSERVE, OBSERVE_REGISTER)
+	response, err := client.Do(req)
+	if err == nil && response.Code != CODE_CONTENT {
+		err = fmt.Errorf("observe %s failed, code %s: %s", path, CodeString(response.Code), response.Payload)
+	} else if err == nil {
+		if _, ok := response.UintOption(OPTION_OBSERVE); !ok {
+			err = fmt.Errorf("observe %s not accepted", path)
+		}
+	}
+	if err != nil {
+		client.removeObservation(observation.token)
+		return nil, err
+	}
+	go observation.run()
+	return observation, nil
+}
+
+// Notifications 负载完整的通知，分块的通知已读取全部块并去掉Block2选项
+func (observation *Observation) Notifications() <-chan *Message {
+	return observation.notifications
+}
+
+// Cancel 发送Observe=1的GET取消观察
+func (observation *Observation) Cancel() error {
+	var err error
+	observation.cancelOnce.Do(func() {
+		observation.client.removeObservation(observation.token)
+		close(observation.raw)
+		req := &Message{Type: CON, Code: GET, Token: observation.token}
+		req.SetPath(observation.path)
+		req.SetUintOption(OPTION_OBSERVE, OBSERVE_CANCEL)
+		_, err = observation.client.Do(req)
+	})
+	return err
+}
+
+// run 按序处理通知，跳过重发的通知，分块的通知读取完后再交给调用方
+func (observation *Observation) run() {
+	defer close(observation.notifications)
+	for notification := range observation.raw {
+		seq, ok := notification.UintOption(OPTION_OBSERVE)
+		if !ok || int64(seq) == observation.lastSeq {
+			continue
+		}
+		observation.lastSeq = int64(seq)
+		complete, err := observation.client.fetchBlocks(observation.path, notification)
+		if err != nil {
+			continue
+		}
+		select {
+		case observation.notifications <- complete:
+		default:
+		}
+	}
+}
+
+// fetchBlocks 响应带Block2且还有后续块时依次读取，负载在读取期间变化(ETag不同)时返回error
+func (client *Client) fetchBlocks(path string, first *Message) (*Message, error) {
+	block, ok := first.Block(OPTION_BLOCK2)
+	if !ok {
+		return first, nil
+	}
+	etag, _ := first.Option(OPTION_ETAG)
+	payload := append([]byte(nil), first.Payload...)
+	for block.More {
+		req := &Message{Type: CON, Code: GET}
+		req.SetPath(path)
+		req.SetBlock(OPTION_BLOCK2, &Block{Num: block.Num + 1, Szx: block.Szx})
+		response, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if response.Code != CODE_CONTENT {
+			return nil, fmt.Errorf("fetch block %d of %s failed, code %s", block.Num+1, path, CodeString(response.Code))
+		}
+		if tag, _ := response.Option(OPTION_ETAG); !bytes.Equal(tag, etag) {
+			return nil, fmt.Errorf("representation of %s changed during block-wise transfer", path)
+		}
+		if block, ok = response.Block(OPTION_BLOCK2); !ok {
+			return nil, fmt.Errorf("block2 option missing in response of %s", path)
+		}
+		payload = append(payload, response.Payload...)
+	}
+
+	complete := *first
+	complete.Options = append([]Option(nil), first.Options...)
+	complete.RemoveOption(OPTION_BLOCK2)
+	complete.Payload = payload
+	return &complete, nil
+}
+
+// Do 发送单个请求并等待响应：CON请求未收到ACK时按指数退避重发，ACK为空时继续等待单独响应
+// Author: agent
+// Since: 2026/10/19
+func (client *Client) Do(req *Message) (*Message, error) {
+	req.MessageId = client.nextMessageId()
+	if req.Token == nil {
+		req.Token = client.newToken()
+	}
+	data, err := req.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	ackChan := make(chan *Message, 1)
+	responseChan := make(chan *Message, 1)
+	client.lock.Lock()
+	client.acks[req.MessageId] = ackChan
+	client.responses[string(req.Token)] = responseChan
+	client.lock.Unlock()
+	defer func() {
+		client.lock.Lock()
+		delete(client.acks, req.MessageId)
+		delete(client.responses, string(req.Token))
+		client.lock.Unlock()
+	}()
+
+	if _, err = client.conn.Write(data); err != nil {
+		return nil, err
+	}
+	deadline := time.NewTimer(client.timeout)
+	defer deadline.Stop()
+	var retransmitChan <-chan time.Time
+	retransmitTimeout, retransmit := CLIENT_ACK_TIMEOUT, 0
+	if req.Type == CON {
+		retransmitChan = time.After(retransmitTimeout)
+	}
+
+	for {
+		select {
+		case ack := <-ackChan:
+			if ack.Type == RST {
+				return nil, fmt.Errorf("request %s reset by server", req.Path())
+			}
+			if ack.Code != EMPTY {
+				return ack, nil
+			}
+			retransmitChan = nil
+		case response := <-responseChan:
+			return response, nil
+		case <-retransmitChan:
+			if retransmit++; retransmit > CLIENT_MAX_RETRANSMIT {
+				return nil, fmt.Errorf("request %s not acknowledged after %d retransmissions", req.Path(), CLIENT_MAX_RETRANSMIT)
+			}
+			if _, err = client.conn.Write(data); err != nil {
+				return nil, err
+			}
+			retransmitTimeout *= 2
+			retransmitChan = time.After(retransmitTimeout)
+		case <-deadline.C:
+			return nil, fmt.Errorf("request %s timeout", req.Path())
+		case <-client.done:
+			return nil, fmt.Errorf("client closed")
+		}
+	}
+}
+
+func (client *Client) readLoop() {
+	buf := make([]byte, CLIENT_MAX_BUFFER_SIZE)
+	for {
+		n, err := client.conn.Read(buf)
+		if err != nil {
+			select {
+			case <-client.done:
+				return
+			default:
+			}
+			continue
+		}
+		msg, err := DecodeMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		client.handleMessage(msg)
+	}
+}
+
+func (client *Client) handleMessage(msg *Message) {
+	if msg.Type == ACK || msg.Type == RST {
+		client.lock.Lock()
+		ackChan, ok := client.acks[msg.MessageId]
+		client.lock.Unlock()
+		if ok {
+			select {
+			case ackChan <- msg:
+			default:
+			}
+		}
+		return
+	}
+	if IsRequest(msg.Code) || msg.Code == EMPTY {
+		if msg.Type == CON {
+			client.write(&Message{Type: RST, MessageId: msg.MessageId})
+		}
+		return
+	}
+
+	client.lock.Lock()
+	responseChan, waiting := client.responses[string(msg.Token)]
+	observation, observing := client.observations[string(msg.Token)]
+	// 持有锁时投递，避免与Cancel关闭raw并发
+	if !waiting && observing {
+		select {
+		case observation.raw <- msg:
+		default:
+		}
+	}
+	client.lock.Unlock()
+
+	switch {
+	case waiting:
+		select {
+		case responseChan <- msg:
+		default:
+		}
+	case !observing:
+		// 未知token的响应、通知回复RST，网关据此移除观察者
+		if msg.Type == CON || msg.Type == NON {
+			client.write(&Message{Type: RST, MessageId: msg.MessageId})
+		}
+		return
+	}
+	if msg.Type == CON {
+		client.write(&Message{Type: ACK, MessageId: msg.MessageId})
+	}
+}
+
+func (client *Client) removeObservation(token []byte) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	delete(client.observations, string(token))
+}
+
+func (client *Client) write(msg *Message) {
+	if data, err := msg.Encode(); err == nil {
+		client.conn.Write(data)
+	}
+}
+
+func (client *Client) nextMessageId() uint16 {
+	return uint16(atomic.AddUint32(&client.messageIdSeq, 1))
+}
+
+func (client *Client) newToken() []byte {
+	token := make([]byte, 4)
+	rand.Read(token)
+	return token
+}
@@ -0,0 +1,99 @@
+package coap
+
+import (
+	"fmt"
+
+	"git.oschina.net/cloudzone/smartgo/stggw/mqtt"
+	"github.com/BurntSushi/toml"
+)
+
+// CoapGatewayConfig CoAP网关配置项，[[rule]]与MQTT网关的映射规则一致，过滤器匹配请求的URI路径
+// Author: agent
+// Since: 2026/10/19
+type CoapGatewayConfig struct {
+	ListenHost           string              // 监听地址
+	ListenPort           int                 // UDP监听端口，默认5683
+	NamesrvAddr          string              // namesrv地址，多个以分号分隔
+	ProducerGroup        string              // 内嵌producer的group
+	ConsumerGroup        string              // 内嵌consumer的group，广播消费
+	MaxMessageSize       int                 // 单个UDP报文最大字节数，超过时要求客户端使用块传输
+	BlockSize            int                 // 块传输的块大小，16~1024之间2的幂，客户端使用更大的块时按此协商
+	MaxPayloadSize       int                 // 块传输组装后的请求体及通知负载的最大字节数
+	ExchangeLifetime     int                 // 按消息ID去重、保留未完成块传输的时间，单位秒，默认247(RFC 7252 EXCHANGE_LIFETIME)
+	AckTimeout           int                 // CON通知等待ACK的初始超时时间，单位毫秒，每次重发后加倍
+	MaxRetransmit        int                 // CON通知的最大重发次数，超过后移除观察者
+	ObserveConfirmable   bool                // 通知是否全部以CON发送
+	ObserveCheckInterval int                 // 以NON发送通知时，至少每隔该时间发送一次CON通知确认观察者存活，单位秒
+	ObserveLease         int                 // 观察者租约，超过该时间未重新注册且未确认CON通知时移除观察者，单位秒，以Max-Age告知客户端
+	MaxObservers         int                 // 最大观察者数，超过时观察请求不注册
+	Rules                []*mqtt.MappingRule `toml:"rule"` // [[rule]]段，URI路径到smartgo topic的映射规则
+}
+
+// NewCoapGatewayConfig 创建默认配置
+// Author: agent
+// Since: 2026/10/19
+func NewCoapGatewayConfig() *CoapGatewayConfig {
+	return &CoapGatewayConfig{
+		ListenHost:           "0.0.0.0",
+		ListenPort:           5683,
+		NamesrvAddr:          "127.0.0.1:9876",
+		ProducerGroup:        "PID_COAP_GATEWAY",
+		ConsumerGroup:        "GID_COAP_GATEWAY",
+		MaxMessageSize:       1152,
+		BlockSize:            512,
+		MaxPayloadSize:       1024 * 1024,
+		ExchangeLifetime:     247,
+		AckTimeout:           2000,
+		MaxRetransmit:        4,
+		ObserveCheckInterval: 86400,
+		ObserveLease:         172800,
+		MaxObservers:         100000,
+	}
+}
+
+// LoadCoapGatewayConfig 加载toml配置文件，未配置的项使用默认值
+// Author: agent
+// Since: 2026/10/19
+func LoadCoapGatewayConfig(path string) (*CoapGatewayConfig, error) {
+	cfg := NewCoapGatewayConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("parse coap gateway config %s failed: %s", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate 校验配置项
+// Author: agent
+// Since: 2026/10/19
+func (cfg *CoapGatewayConfig) Validate() error {
+	if cfg.ListenPort < 0 || cfg.ListenPort > 65535 {
+		return fmt.Errorf("invalid listenPort %d", cfg.ListenPort)
+	}
+	if cfg.NamesrvAddr == "" {
+		return fmt.Errorf("namesrvAddr is empty")
+	}
+	if _, ok := BlockSzx(cfg.BlockSize); !ok {
+		return fmt.Errorf("invalid blockSize %d, expect power of 2 between 16 and 1024", cfg.BlockSize)
+	}
+	// 报文头、token及选项最多预留64字节
+	if cfg.MaxMessageSize < cfg.BlockSize+64 || cfg.MaxMessageSize > 65507 {
+		return fmt.Errorf("invalid maxMessageSize %d, must be between blockSize+64 and 65507", cfg.MaxMessageSize)
+	}
+	if cfg.MaxPayloadSize < cfg.BlockSize {
+		return fmt.Errorf("maxPayloadSize must not be less than blockSize")
+	}
+	if cfg.ExchangeLifetime <= 0 || cfg.AckTimeout <= 0 || cfg.MaxRetransmit < 0 || cfg.ObserveCheckInterval <= 0 || cfg.ObserveLease <= 0 || cfg.MaxObservers <= 0 {
+		return fmt.Errorf("exchangeLifetime, ackTimeout, observeCheckInterval, observeLease and maxObservers must be positive, maxRetransmit must not be negative")
+	}
+	_, err := mqtt.NewTopicMapper(cfg.Rules)
+	return err
+}
+
+func (cfg *CoapGatewayConfig) String() string {
+	format := "CoapGatewayConfig [listenHost=%s, listenPort=%d, namesrvAddr=%s, producerGroup=%s, consumerGroup=%s, maxMessageSize=%d, "
+	format += "blockSize=%d, maxPayloadSize=%d, exchangeLifetime=%d, ackTimeout=%d, maxRetransmit=%d, observeConfirmable=%t, "
+	format += "observeCheckInterval=%d, observeLease=%d, maxObservers=%d, rules=%d]"
+	return fmt.Sprintf(format, cfg.ListenHost, cfg.ListenPort, cfg.NamesrvAddr, cfg.ProducerGroup, cfg.ConsumerGroup, cfg.MaxMessageSize,
+		cfg.BlockSize, cfg.MaxPayloadSize, cfg.ExchangeLifetime, cfg.AckTimeout, cfg.MaxRetransmit, cfg.ObserveConfirmable,
+		cfg.ObserveCheckInterval, cfg.ObserveLease, cfg.MaxObservers, len(cfg.Rules))
+}
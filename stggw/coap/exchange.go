@@ -0,0 +1,125 @@
+package coap
+
+import (
+	"sync"
+)
+
+// exchange 按终端地址及消息ID记录的请求，response为nil表示请求仍在处理中
+type exchange struct {
+	response   []byte
+	expireTime int64
+}
+
+// exchangeCache 消息ID去重(RFC 7252 4.5)：EXCHANGE_LIFETIME内重复的CON请求重发缓存的响应，
+// 重复的NON请求及仍在处理中的请求直接忽略
+// Author: agent
+// Since: 2026/10/19
+type exchangeCache struct {
+	exchanges map[string]*exchange
+	lifetime  int64 // 单位毫秒
+	lock      sync.Mutex
+}
+
+func newExchangeCache(lifetime int64) *exchangeCache {
+	return &exchangeCache{exchanges: make(map[string]*exchange), lifetime: lifetime}
+}
+
+// begin 登记请求，已登记过时返回缓存的响应(可能为nil)及true
+func (cache *exchangeCache) begin(key string, now int64) ([]byte, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if ex, ok := cache.exchanges[key]; ok && ex.expireTime > now {
+		return ex.response, true
+	}
+	cache.exchanges[key] = &exchange{expireTime: now + cache.lifetime}
+	return nil, false
+}
+
+// complete 缓存请求的响应，用于重发
+func (cache *exchangeCache) complete(key string, response []byte) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if ex, ok := cache.exchanges[key]; ok {
+		ex.response = response
+	}
+}
+
+func (cache *exchangeCache) expire(now int64) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for key, ex := range cache.exchanges {
+		if ex.expireTime <= now {
+			delete(cache.exchanges, key)
+		}
+	}
+}
+
+func (cache *exchangeCache) size() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return len(cache.exchanges)
+}
+
+// blockTransfer 组装中的Block1请求体
+type blockTransfer struct {
+	payload    []byte
+	expireTime int64
+}
+
+// blockAssembler 按终端地址、方法及URI路径组装Block1分块上传的请求体(RFC 7959 2.5)
+// Author: agent
+// Since: 2026/10/19
+type blockAssembler struct {
+	transfers map[string]*blockTransfer
+	lifetime  int64 // 单位毫秒
+	lock      sync.Mutex
+}
+
+func newBlockAssembler(lifetime int64) *blockAssembler {
+	return &blockAssembler{transfers: make(map[string]*blockTransfer), lifetime: lifetime}
+}
+
+// append 追加一个块，块的起始位置须等于已接收的长度，块序号为0时重新开始；
+// 返回组装后的长度，code非0表示失败的响应码，此时丢弃已接收的内容
+func (assembler *blockAssembler) append(key string, block *Block, data []byte, maxSize int, now int64) (int, byte) {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+
+	transfer, ok := assembler.transfers[key]
+	if block.Num == 0 {
+		transfer = &blockTransfer{}
+		assembler.transfers[key] = transfer
+	} else if !ok || transfer.expireTime <= now || len(transfer.payload) != block.Offset() {
+		delete(assembler.transfers, key)
+		return 0, CODE_REQUEST_ENTITY_INCOMPLETE
+	}
+	if len(transfer.payload)+len(data) > maxSize {
+		delete(assembler.transfers, key)
+		return 0, CODE_REQUEST_ENTITY_TOO_LARGE
+	}
+	transfer.payload = append(transfer.payload, data...)
+	transfer.expireTime = now + assembler.lifetime
+	return len(transfer.payload), 0
+}
+
+// finish 取出组装完成的请求体
+func (assembler *blockAssembler) finish(key string) []byte {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+	transfer, ok := assembler.transfers[key]
+	if !ok {
+		return nil
+	}
+	delete(assembler.transfers, key)
+	return transfer.payload
+}
+
+func (assembler *blockAssembler) expire(now int64) {
+	assembler.lock.Lock()
+	defer assembler.lock.Unlock()
+	for key, transfer := range assembler.transfers {
+		if transfer.expireTime <= now {
+			delete(assembler.transfers, key)
+		}
+	}
+}
@@ -0,0 +1,585 @@
+package coap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/mqtt"
+)
+
+const (
+	MAX_DIAGNOSTIC_SIZE = 256 // 错误响应中诊断信息的最大字节数
+	scanInterval        = 100 * time.Millisecond
+	expireInterval      = time.Second
+)
+
+// CoapGateway CoAP(RFC 7252)网关：设备以CON/NON的POST、PUT请求按映射规则将请求体写入smartgo topic，
+// 响应码由发送结果决定；GET带Observe时注册为观察者，网关以广播模式消费映射的topic，再按URI路径向观察者推送通知；
+// 支持Block1分块上传及Block2分块读取通知负载(RFC 7959)，按终端地址及消息ID去重
+// Author: agent
+// Since: 2026/10/19
+type CoapGateway struct {
+	config       *CoapGatewayConfig
+	mapper       *mqtt.TopicMapper
+	blockSzx     uint8
+	conn         *net.UDPConn
+	producer     gwutil.MessageProducer
+	consumer     gwutil.MessageConsumer
+	exchanges    *exchangeCache
+	blocks       *blockAssembler
+	observers    *observeRegistry
+	messageIdSeq uint32
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	wg           sync.WaitGroup
+}
+
+// NewCoapGateway 创建CoAP网关
+// Author: agent
+// Since: 2026/10/19
+func NewCoapGateway(config *CoapGatewayConfig) (*CoapGateway, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	mapper, err := mqtt.NewTopicMapper(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+	blockSzx, _ := BlockSzx(config.BlockSize)
+	lifetime := int64(config.ExchangeLifetime) * 1000
+
+	gateway := &CoapGateway{
+		config:       config,
+		mapper:       mapper,
+		blockSzx:     blockSzx,
+		exchanges:    newExchangeCache(lifetime),
+		blocks:       newBlockAssembler(lifetime),
+		observers:    newObserveRegistry(),
+		messageIdSeq: uint32(rand.Intn(65536)),
+		stopChan:     make(chan struct{}),
+	}
+
+	producer := process.NewDefaultMQProducer(config.ProducerGroup)
+	producer.SetNamesrvAddr(config.NamesrvAddr)
+	gateway.producer = producer
+
+	pushConsumer := process.NewDefaultMQPushConsumer(config.ConsumerGroup)
+	pushConsumer.SetConsumeFromWhere(heartbeat.CONSUME_FROM_LAST_OFFSET)
+	pushConsumer.SetMessageModel(heartbeat.BROADCASTING)
+	pushConsumer.SetNamesrvAddr(config.NamesrvAddr)
+	for topic, expression := range mapper.SubscribeExpressions() {
+		pushConsumer.Subscribe(topic, expression)
+	}
+	pushConsumer.RegisterMessageListener(&gatewayMessageListener{gateway: gateway})
+	gateway.consumer = pushConsumer
+	return gateway, nil
+}
+
+// Start 启动producer、UDP监听及consumer
+// Author: agent
+// Since: 2026/10/19
+func (gateway *CoapGateway) Start() error {
+	gateway.producer.Start()
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(gateway.config.ListenHost, strconv.Itoa(gateway.config.ListenPort)))
+	if err != nil {
+		return err
+	}
+	if gateway.conn, err = net.ListenUDP("udp", addr); err != nil {
+		return err
+	}
+	gateway.consumer.Start()
+
+	gateway.wg.Add(2)
+	go gateway.readLoop()
+	go gateway.scan()
+	logger.Infof("coap gateway %s start success. %s", gateway.Addr(), gateway.config)
+	return nil
+}
+
+// Shutdown 关闭UDP监听，再关闭consumer、producer
+// Author: agent
+// Since: 2026/10/19
+func (gateway *CoapGateway) Shutdown() {
+	gateway.stopOnce.Do(func() {
+		close(gateway.stopChan)
+		if gateway.conn != nil {
+			gateway.conn.Close()
+		}
+		gateway.wg.Wait()
+		gateway.consumer.Shutdown()
+		gateway.producer.Shutdown()
+		logger.Infof("coap gateway shutdown success")
+	})
+}
+
+// Addr UDP实际监听地址，未启动时为空
+func (gateway *CoapGateway) Addr() string {
+	if gateway.conn == nil {
+		return ""
+	}
+	return gateway.conn.LocalAddr().String()
+}
+
+// ObserverCount 当前观察者数
+func (gateway *CoapGateway) ObserverCount() int {
+	return gateway.observers.size()
+}
+
+func (gateway *CoapGateway) readLoop() {
+	defer gateway.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := gateway.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-gateway.stopChan:
+				return
+			default:
+			}
+			logger.Warnf("coap read from %s failed: %s", gateway.Addr(), err)
+			continue
+		}
+		gateway.handleDatagram(buf[:n], addr)
+	}
+}
+
+// handleDatagram 处理单个UDP报文，buf在返回后会被复用
+func (gateway *CoapGateway) handleDatagram(data []byte, addr *net.UDPAddr) {
+	msg, err := DecodeMessage(data)
+	if err != nil {
+		// 格式错误的CON报文回复RST(RFC 7252 4.2)，其他报文忽略
+		if len(data) >= 4 && data[0]>>6 == VERSION && (data[0]>>4)&0x03 == CON {
+			gateway.send(&Message{Type: RST, MessageId: binary.BigEndian.Uint16(data[2:])}, addr)
+		}
+		logger.Warnf("coap decode message from %s failed: %s", addr, err)
+		return
+	}
+
+	switch msg.Type {
+	case ACK:
+		gateway.observers.onAck(addr.String(), msg.MessageId, timeutil.CurrentTimeMillis())
+		return
+	case RST:
+		if o := gateway.observers.onReset(addr.String(), msg.MessageId); o != nil {
+			logger.Infof("coap observer %s reset by client, removed", o.key)
+		}
+		return
+	}
+
+	// CON空报文为CoAP ping，回复RST；网关不发起请求，收到的响应同样拒绝
+	if !IsRequest(msg.Code) {
+		if msg.Type == CON {
+			gateway.send(&Message{Type: RST, MessageId: msg.MessageId}, addr)
+		}
+		return
+	}
+
+	key := sentKey(addr.String(), msg.MessageId)
+	if response, duplicate := gateway.exchanges.begin(key, timeutil.CurrentTimeMillis()); duplicate {
+		if response != nil && msg.Type == CON {
+			gateway.write(response, addr)
+		}
+		return
+	}
+	go gateway.handleRequest(msg, addr, key, len(data))
+}
+
+// handleRequest CON请求以ACK捎带响应，NON请求以NON响应，响应缓存用于重复请求
+func (gateway *CoapGateway) handleRequest(req *Message, addr *net.UDPAddr, key string, size int) {
+	response := gateway.process(req, addr, size)
+	response.Token = req.Token
+	if req.Type == CON {
+		response.Type = ACK
+		response.MessageId = req.MessageId
+	} else {
+		response.Type = NON
+		response.MessageId = gateway.nextMessageId()
+	}
+
+	data, err := response.Encode()
+	if err != nil {
+		logger.Errorf("coap encode response to %s failed: %s", addr, err)
+		return
+	}
+	gateway.exchanges.complete(key, data)
+	gateway.write(data, addr)
+}
+
+func (gateway *CoapGateway) process(req *Message, addr *net.UDPAddr, size int) *Message {
+	if number := req.UnknownCriticalOption(); number != 0 {
+		return newResponse(CODE_BAD_OPTION, fmt.Sprintf("unrecognized critical option %d", number))
+	}
+	if size > gateway.config.MaxMessageSize {
+		response := newResponse(CODE_REQUEST_ENTITY_TOO_LARGE, "use block-wise transfer")
+		response.SetBlock(OPTION_BLOCK1, &Block{Szx: gateway.blockSzx})
+		return response
+	}
+
+	switch req.Code {
+	case POST, PUT:
+		return gateway.handleSend(req, addr)
+	case GET:
+		return gateway.handleGet(req, addr)
+	default:
+		return newResponse(CODE_METHOD_NOT_ALLOWED, "")
+	}
+}
+
+// handleSend 请求体按映射规则写入smartgo，URI路径作为MQTT主题写入消息属性，与MQTT设备互通
+// Author: agent
+// Since: 2026/10/19
+func (gateway *CoapGateway) handleSend(req *Message, addr *net.UDPAddr) *Message {
+	path := req.Path()
+	if !mqtt.ValidTopicName(path) {
+		return newResponse(CODE_BAD_REQUEST, "invalid uri path")
+	}
+	rule, ok := gateway.mapper.Match(path)
+	if !ok {
+		return newResponse(CODE_NOT_FOUND, "no mapping rule for "+path)
+	}
+
+	payload := req.Payload
+	block, blockwise := req.Block(OPTION_BLOCK1)
+	if blockwise {
+		var response *Message
+		if payload, response = gateway.receiveBlock(req, addr, block); response != nil {
+			return response
+		}
+	}
+	if len(payload) == 0 {
+		return newResponse(CODE_BAD_REQUEST, "empty payload")
+	}
+	if len(payload) > gateway.config.MaxPayloadSize {
+		return newTooLargeResponse(gateway.config.MaxPayloadSize)
+	}
+
+	msg := message.NewMessage(rule.Topic, rule.Tags, payload)
+	if rule.Tags == "" {
+		msg.ClearProperty(message.PROPERTY_TAGS)
+	}
+	msg.PutProperty(message.PROPERTY_MQTT_TOPIC, path)
+	msg.PutProperty(message.PROPERTY_COAP_ENDPOINT, addr.String())
+	if format, ok := req.UintOption(OPTION_CONTENT_FORMAT); ok {
+		msg.PutProperty(message.PROPERTY_COAP_CONTENT_FORMAT, strconv.Itoa(int(format)))
+	}
+
+	result, err := gateway.producer.Send(msg)
+	code := sendResultCode(req.Code, result, err)
+	var diagnostic string
+	if err != nil {
+		logger.Errorf("coap %s send to %s failed: %s", addr, path, err)
+		diagnostic = err.Error()
+	} else if code>>5 != 2 {
+		diagnostic = result.SendStatus.String()
+	}
+	response := newResponse(code, diagnostic)
+	if blockwise {
+		response.SetBlock(OPTION_BLOCK1, &Block{Num: block.Num, Szx: block.Szx})
+	}
+	return response
+}
+
+// receiveBlock 组装Block1分块，最后一块到达时返回完整请求体，否则返回2.31或错误响应
+func (gateway *CoapGateway) receiveBlock(req *Message, addr *net.UDPAddr, block *Block) ([]byte, *Message) {
+	maxSize := gateway.config.MaxPayloadSize
+	if block.Szx > 6 {
+		return nil, newResponse(CODE_BAD_REQUEST, "invalid block size")
+	}
+	if size1, ok := req.UintOption(OPTION_SIZE1); ok && int(size1) > maxSize {
+		return nil, newTooLargeResponse(maxSize)
+	}
+	if block.More && len(req.Payload) != block.Size() {
+		return nil, newResponse(CODE_BAD_REQUEST, "block payload size mismatch")
+	}
+
+	key := addr.String() + " " + CodeString(req.Code) + " " + req.Path()
+	if _, code := gateway.blocks.append(key, block, req.Payload, maxSize, timeutil.CurrentTimeMillis()); code != 0 {
+		if code == CODE_REQUEST_ENTITY_TOO_LARGE {
+			return nil, newTooLargeResponse(maxSize)
+		}
+		return nil, newResponse(code, "")
+	}
+	if !block.More {
+		return gateway.blocks.finish(key), nil
+	}
+
+	// 客户端的块大于配置时协商为配置的块大小，客户端按新的块大小计算后续块的序号
+	szx := block.Szx
+	if gateway.blockSzx < szx {
+		szx = gateway.blockSzx
+	}
+	response := newResponse(CODE_CONTINUE, "")
+	response.SetBlock(OPTION_BLOCK1, &Block{Num: block.Num, More: true, Szx: szx})
+	return nil, response
+}
+
+// handleGet Observe=0注册观察，Observe=1取消观察；不带Observe的GET及Block2后续块读取最近一次通知的负载
+// Author: agent
+// Since: 2026/10/19
+func (gateway *CoapGateway) handleGet(req *Message, addr *net.UDPAddr) *Message {
+	path := req.Path()
+	if !mqtt.ValidTopicFilter(path) {
+		return newResponse(CODE_BAD_REQUEST, "invalid uri path")
+	}
+	block, _ := req.Block(OPTION_BLOCK2)
+	if block != nil && block.Szx > 6 {
+		return newResponse(CODE_BAD_REQUEST, "invalid block size")
+	}
+
+	observe, observing := req.UintOption(OPTION_OBSERVE)
+	if !observing || (block != nil && block.Num > 0) {
+		latest, ok := gateway.observers.latest(addr.String(), path)
+		if !ok {
+			return newResponse(CODE_NOT_FOUND, "no notification of "+path)
+		}
+		response := newResponse(CODE_CONTENT, "")
+		if !gateway.fillRepresentation(response, latest, block) {
+			return newResponse(CODE_BAD_REQUEST, "block out of range")
+		}
+		return response
+	}
+
+	switch observe {
+	case OBSERVE_REGISTER:
+		if _, ok := gateway.mapper.Match(path); !ok && !mqtt.HasWildcard(path) {
+			return newResponse(CODE_NOT_FOUND, "no mapping rule for "+path)
+		}
+		response := newResponse(CODE_CONTENT, "")
+		// 无法注册时按RFC 7641 4.1回复不带Observe选项的响应
+		if seq, ok := gateway.observers.register(addr, path, req.Token, gateway.config.MaxObservers, timeutil.CurrentTimeMillis()); ok {
+			// Max-Age为观察者租约，客户端在此之前没有收到通知时重新注册(RFC 7641 4.3.1)
+			response.SetUintOption(OPTION_OBSERVE, seq)
+			response.SetUintOption(OPTION_MAX_AGE, uint32(gateway.config.ObserveLease))
+		} else {
+			logger.Warnf("coap observers exceed %d, reject %s observe %s", gateway.config.MaxObservers, addr, path)
+		}
+		return response
+	case OBSERVE_CANCEL:
+		gateway.observers.cancel(addr.String(), path)
+		return newResponse(CODE_CONTENT, "")
+	default:
+		return newResponse(CODE_BAD_REQUEST, "invalid observe option")
+	}
+}
+
+// fillRepresentation 写入通知负载，超过块大小时按Block2分块，block为nil时返回第一块；块序号超出范围时返回false
+func (gateway *CoapGateway) fillRepresentation(msg *Message, latest *representation, block *Block) bool {
+	msg.SetOption(OPTION_ETAG, latest.etag)
+	if latest.contentFormat >= 0 {
+		msg.SetUintOption(OPTION_CONTENT_FORMAT, uint32(latest.contentFormat))
+	}
+	if latest.location != "" {
+		for _, segment := range strings.Split(latest.location, "/") {
+			msg.AddOption(OPTION_LOCATION_PATH, []byte(segment))
+		}
+	}
+
+	szx := gateway.blockSzx
+	offset := 0
+	if block != nil {
+		if block.Szx < szx {
+			szx = block.Szx
+		}
+		offset = block.Offset()
+	}
+	size := 1 << (szx + 4)
+	payload := latest.payload
+	if block == nil && len(payload) <= size {
+		msg.Payload = payload
+		return true
+	}
+	if offset > 0 && offset >= len(payload) {
+		return false
+	}
+
+	// 客户端请求的块大于配置时，按配置的块大小返回同一位置的块
+	offset -= offset % size
+	end := offset + size
+	if end > len(payload) {
+		end = len(payload)
+	}
+	msg.Payload = payload[offset:end]
+	msg.SetBlock(OPTION_BLOCK2, &Block{Num: uint32(offset / size), More: end < len(payload), Szx: szx})
+	msg.SetUintOption(OPTION_SIZE2, uint32(len(payload)))
+	return true
+}
+
+// dispatch 向观察路径匹配的观察者推送通知
+// Author: agent
+// Since: 2026/10/19
+func (gateway *CoapGateway) dispatch(msg *message.MessageExt) {
+	path := msg.GetProperty(message.PROPERTY_MQTT_TOPIC)
+	if path == "" {
+		path = gateway.mapper.ResolveMqttTopic(msg.Topic, msg.GetTags())
+	}
+	observers := gateway.observers.match(path)
+	if len(observers) == 0 {
+		return
+	}
+	if len(msg.Body) > gateway.config.MaxPayloadSize {
+		logger.Warnf("coap notification of %s exceeds maxPayloadSize %d, msgId=%s dropped", path, gateway.config.MaxPayloadSize, msg.MsgId)
+		return
+	}
+
+	contentFormat := -1
+	if value := msg.GetProperty(message.PROPERTY_COAP_CONTENT_FORMAT); value != "" {
+		if format, err := strconv.Atoi(value); err == nil && format >= 0 && format <= 65535 {
+			contentFormat = format
+		}
+	}
+	for _, o := range observers {
+		latest := &representation{payload: msg.Body, contentFormat: contentFormat}
+		if mqtt.HasWildcard(o.filter) {
+			latest.location = path
+		}
+		gateway.notify(o, latest)
+	}
+}
+
+func (gateway *CoapGateway) notify(o *observer, latest *representation) {
+	now := timeutil.CurrentTimeMillis()
+	checkInterval := int64(gateway.config.ObserveCheckInterval) * 1000
+	lease := int64(gateway.config.ObserveLease) * 1000
+	token, seq, confirmable, remain, ok := gateway.observers.prepare(o, latest, gateway.config.ObserveConfirmable, checkInterval, lease, now)
+	if !ok {
+		return
+	}
+
+	notification := &Message{Type: NON, Code: CODE_CONTENT, MessageId: gateway.nextMessageId(), Token: token}
+	if confirmable {
+		notification.Type = CON
+	}
+	notification.SetUintOption(OPTION_OBSERVE, seq)
+	notification.SetUintOption(OPTION_MAX_AGE, uint32((remain+999)/1000))
+	gateway.fillRepresentation(notification, latest, nil)
+	data, err := notification.Encode()
+	if err != nil {
+		logger.Errorf("coap encode notification to %s failed: %s", o.key, err)
+		return
+	}
+
+	// 初始重发超时为ackTimeout的1~1.5倍(RFC 7252 4.2 ACK_RANDOM_FACTOR)
+	ackTimeout := int64(gateway.config.AckTimeout)
+	timeout := ackTimeout + rand.Int63n(ackTimeout/2+1)
+	lifetime := int64(gateway.config.ExchangeLifetime) * 1000
+	gateway.observers.track(o, notification.MessageId, data, confirmable, timeout, lifetime, now)
+	gateway.write(data, o.addr)
+}
+
+// scan 重发未确认的CON通知，定期清除过期的去重记录及未完成的块传输
+func (gateway *CoapGateway) scan() {
+	defer gateway.wg.Done()
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+	lastExpire := time.Now()
+	for {
+		select {
+		case <-gateway.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		now := timeutil.CurrentTimeMillis()
+		resend, removed := gateway.observers.retransmit(gateway.config.MaxRetransmit, now)
+		for o, notifications := range resend {
+			for _, data := range notifications {
+				gateway.write(data, o.addr)
+			}
+		}
+		for _, o := range removed {
+			logger.Infof("coap observer %s not acknowledged after %d retransmissions, removed", o.key, gateway.config.MaxRetransmit)
+		}
+
+		if time.Since(lastExpire) >= expireInterval {
+			lastExpire = time.Now()
+			gateway.exchanges.expire(now)
+			gateway.blocks.expire(now)
+			for _, o := range gateway.observers.expire(int64(gateway.config.ObserveLease)*1000, now) {
+				logger.Infof("coap observer %s lease expired, removed", o.key)
+			}
+		}
+	}
+}
+
+func (gateway *CoapGateway) send(msg *Message, addr *net.UDPAddr) {
+	data, err := msg.Encode()
+	if err != nil {
+		logger.Errorf("coap encode message to %s failed: %s", addr, err)
+		return
+	}
+	gateway.write(data, addr)
+}
+
+func (gateway *CoapGateway) write(data []byte, addr *net.UDPAddr) {
+	if _, err := gateway.conn.WriteToUDP(data, addr); err != nil {
+		logger.Warnf("coap write to %s failed: %s", addr, err)
+	}
+}
+
+func (gateway *CoapGateway) nextMessageId() uint16 {
+	return uint16(atomic.AddUint32(&gateway.messageIdSeq, 1))
+}
+
+// sendResultCode 发送结果对应的响应码：写入成功时POST回复2.01、PUT回复2.04；
+// 刷盘或同步slave超时时消息已写入master但未确认持久化，回复5.04；slave不可用及发送失败回复5.03
+func sendResultCode(method byte, result *process.SendResult, err error) byte {
+	if err != nil || result == nil {
+		return CODE_SERVICE_UNAVAILABLE
+	}
+	switch result.SendStatus {
+	case process.SEND_OK:
+		if method == PUT {
+			return CODE_CHANGED
+		}
+		return CODE_CREATED
+	case process.FLUSH_DISK_TIMEOUT, process.FLUSH_SLAVE_TIMEOUT:
+		return CODE_GATEWAY_TIMEOUT
+	default:
+		return CODE_SERVICE_UNAVAILABLE
+	}
+}
+
+// newResponse 创建响应，错误响应可带UTF-8的诊断信息(RFC 7252 5.5.2)
+func newResponse(code byte, diagnostic string) *Message {
+	if len(diagnostic) > MAX_DIAGNOSTIC_SIZE {
+		diagnostic = diagnostic[:MAX_DIAGNOSTIC_SIZE]
+		for !utf8.ValidString(diagnostic) {
+			diagnostic = diagnostic[:len(diagnostic)-1]
+		}
+	}
+	return &Message{Code: code, Payload: []byte(diagnostic)}
+}
+
+// newTooLargeResponse 4.13响应，Size1为可接受的最大请求体
+func newTooLargeResponse(maxSize int) *Message {
+	response := newResponse(CODE_REQUEST_ENTITY_TOO_LARGE, "")
+	response.SetUintOption(OPTION_SIZE1, uint32(maxSize))
+	return response
+}
+
+type gatewayMessageListener struct {
+	gateway *CoapGateway
+}
+
+func (l *gatewayMessageListener) ConsumeMessage(msgs []*message.MessageExt, context *consumer.ConsumeConcurrentlyContext) listener.ConsumeConcurrentlyStatus {
+	for _, msg := range msgs {
+		l.gateway.dispatch(msg)
+	}
+	return listener.CONSUME_SUCCESS
+}
@@ -0,0 +1,440 @@
+package coap
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwtest"
+	"git.oschina.net/cloudzone/smartgo/stggw/mqtt"
+)
+
+// fakeBroker 内存中的broker：记录发送的消息，并以广播方式分发给订阅了该topic的网关；
+// status、err为下一次发送的结果
+type fakeBroker struct {
+	gwtest.Broker
+	gateways []*CoapGateway
+	status   process.SendStatus
+	err      error
+	lock     sync.Mutex
+}
+
+func (b *fakeBroker) Send(msg *message.Message) (*process.SendResult, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	msgExt := b.Record(msg)
+	for _, gateway := range b.gateways {
+		if _, ok := gateway.mapper.SubscribeExpressions()[msg.Topic]; ok {
+			gateway.dispatch(msgExt)
+		}
+	}
+	return &process.SendResult{SendStatus: b.status}, nil
+}
+
+func (b *fakeBroker) setResult(status process.SendStatus, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.status, b.err = status, err
+}
+
+func newTestConfig() *CoapGatewayConfig {
+	cfg := NewCoapGatewayConfig()
+	cfg.ListenHost = "127.0.0.1"
+	cfg.ListenPort = 0
+	cfg.Rules = []*mqtt.MappingRule{
+		{Filter: "devices/+/telemetry", Topic: "DeviceTelemetry", Tags: "telemetry"},
+		{Filter: "devices/#", Topic: "DeviceCommand"},
+	}
+	return cfg
+}
+
+func startTestGateway(t *testing.T, cfg *CoapGatewayConfig, broker *fakeBroker) *CoapGateway {
+	gateway, err := NewCoapGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.producer = broker
+	gateway.consumer = &gwtest.NopConsumer{}
+	broker.lock.Lock()
+	broker.gateways = append(broker.gateways, gateway)
+	broker.lock.Unlock()
+	if err = gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return gateway
+}
+
+func dialTestClient(t *testing.T, gateway *CoapGateway) *Client {
+	client, err := DialClient(gateway.Addr(), 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func expectCode(t *testing.T, response *Message, err error, code byte) {
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != code {
+		t.Fatalf("expect code %s, got %s: %s", CodeString(code), CodeString(response.Code), response.Payload)
+	}
+}
+
+func receiveNotification(t *testing.T, observation *Observation) *Message {
+	select {
+	case notification := <-observation.Notifications():
+		return notification
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait notification timeout")
+		return nil
+	}
+}
+
+func TestCoapSend(t *testing.T) {
+	broker := &fakeBroker{}
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	req := &Message{Type: CON, Code: POST, Payload: []byte(`{"temperature":23}`)}
+	req.SetPath("devices/1/telemetry")
+	req.SetUintOption(OPTION_CONTENT_FORMAT, CONTENT_FORMAT_JSON)
+	response, err := client.Do(req)
+	expectCode(t, response, err, CODE_CREATED)
+	if response.Type != ACK || !bytes.Equal(response.Token, req.Token) {
+		t.Fatalf("expect piggybacked ACK, got %s", response)
+	}
+	msg := broker.Messages()[0]
+	if msg.Topic != "DeviceTelemetry" || msg.GetTags() != "telemetry" || msg.GetProperty(message.PROPERTY_MQTT_TOPIC) != "devices/1/telemetry" ||
+		msg.GetProperty(message.PROPERTY_COAP_CONTENT_FORMAT) != "50" || msg.GetProperty(message.PROPERTY_COAP_ENDPOINT) == "" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	response, err = client.Put("devices/1/config", []byte("interval=60"))
+	expectCode(t, response, err, CODE_CHANGED)
+	if msg = broker.Messages()[1]; msg.Topic != "DeviceCommand" || msg.GetTags() != "" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// NON请求以NON响应
+	response, err = client.Send(POST, NON, "devices/2/telemetry", []byte("1"))
+	expectCode(t, response, err, CODE_CREATED)
+	if response.Type != NON {
+		t.Fatalf("expect NON response, got %s", response)
+	}
+
+	response, err = client.Post("sensors/1", []byte("1"))
+	expectCode(t, response, err, CODE_NOT_FOUND)
+	response, err = client.Post("devices/1/telemetry", nil)
+	expectCode(t, response, err, CODE_BAD_REQUEST)
+	response, err = client.Send(DELETE, CON, "devices/1/telemetry", nil)
+	expectCode(t, response, err, CODE_METHOD_NOT_ALLOWED)
+	req = &Message{Type: CON, Code: POST, Payload: []byte("1")}
+	req.SetPath("devices/1/telemetry")
+	req.AddOption(2049, []byte("x"))
+	response, err = client.Do(req)
+	expectCode(t, response, err, CODE_BAD_OPTION)
+
+	// 发送结果映射为响应码
+	broker.setResult(process.FLUSH_DISK_TIMEOUT, nil)
+	response, err = client.Post("devices/1/telemetry", []byte("1"))
+	expectCode(t, response, err, CODE_GATEWAY_TIMEOUT)
+	broker.setResult(process.SLAVE_NOT_AVAILABLE, nil)
+	response, err = client.Post("devices/1/telemetry", []byte("1"))
+	expectCode(t, response, err, CODE_SERVICE_UNAVAILABLE)
+	broker.setResult(process.SEND_OK, fmt.Errorf("no route info of this topic"))
+	response, err = client.Post("devices/1/telemetry", []byte("1"))
+	expectCode(t, response, err, CODE_SERVICE_UNAVAILABLE)
+	if string(response.Payload) != "no route info of this topic" {
+		t.Fatalf("unexpected diagnostic payload %q", response.Payload)
+	}
+}
+
+func TestCoapDeduplication(t *testing.T) {
+	broker := &fakeBroker{}
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+
+	conn, err := net.Dial("udp", gateway.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	roundTrip := func(msg *Message) *Message {
+		data, _ := msg.Encode()
+		if _, err := conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 2048)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := DecodeMessage(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	// 重发的CON请求只写入一次，回复缓存的响应
+	req := &Message{Type: CON, Code: POST, MessageId: 7, Token: []byte{9}, Payload: []byte("1")}
+	req.SetPath("devices/1/telemetry")
+	first := roundTrip(req)
+	second := roundTrip(req)
+	if first.Code != CODE_CREATED || !bytes.Equal(first.Token, second.Token) || first.MessageId != second.MessageId || second.Code != CODE_CREATED {
+		t.Fatalf("unexpected responses %s %s", first, second)
+	}
+	if count := len(broker.Messages()); count != 1 {
+		t.Fatalf("expect 1 message, got %d", count)
+	}
+
+	// CoAP ping及格式错误的CON报文回复RST
+	if reset := roundTrip(&Message{Type: CON, Code: EMPTY, MessageId: 8}); reset.Type != RST || reset.MessageId != 8 {
+		t.Fatalf("expect RST, got %s", reset)
+	}
+	conn.Write([]byte{0x40, 0x01, 0x00, 0x09, 0xf0})
+	buf := make([]byte, 64)
+	if n, err := conn.Read(buf); err != nil || n != 4 || buf[0]>>4 != 0x4|RST || buf[3] != 9 {
+		t.Fatalf("expect RST of malformed message, got %x %v", buf[:n], err)
+	}
+
+	// 超过单个报文大小上限时要求块传输
+	req = &Message{Type: CON, Code: POST, MessageId: 10, Payload: make([]byte, 2000)}
+	req.SetPath("devices/1/telemetry")
+	if response := roundTrip(req); response.Code != CODE_REQUEST_ENTITY_TOO_LARGE {
+		t.Fatalf("expect 4.13, got %s", response)
+	} else if block, ok := response.Block(OPTION_BLOCK1); !ok || block.Size() != gateway.config.BlockSize {
+		t.Fatalf("expect block1 hint, got %s", response)
+	}
+}
+
+func TestCoapBlockwise(t *testing.T) {
+	broker := &fakeBroker{}
+	cfg := newTestConfig()
+	cfg.MaxPayloadSize = 4096
+	gateway := startTestGateway(t, cfg, broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	// 客户端以1024字节分块，网关协商为512字节
+	payload := make([]byte, 3000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	response, err := client.Post("devices/1/firmware", payload)
+	expectCode(t, response, err, CODE_CREATED)
+	if block, ok := response.Block(OPTION_BLOCK1); !ok || block.More || block.Szx != 5 {
+		t.Fatalf("unexpected block1 in final response %s", response)
+	}
+	messages := broker.Messages()
+	if len(messages) != 1 || !bytes.Equal(messages[0].Body, payload) {
+		t.Fatalf("unexpected assembled message, count %d", len(messages))
+	}
+
+	// 缺少前面的块
+	req := &Message{Type: CON, Code: POST, Payload: make([]byte, 512)}
+	req.SetPath("devices/2/firmware")
+	req.SetBlock(OPTION_BLOCK1, &Block{Num: 3, More: true, Szx: 5})
+	response, err = client.Do(req)
+	expectCode(t, response, err, CODE_REQUEST_ENTITY_INCOMPLETE)
+
+	// 超过maxPayloadSize
+	response, err = client.Post("devices/1/firmware", make([]byte, 5000))
+	expectCode(t, response, err, CODE_REQUEST_ENTITY_TOO_LARGE)
+	if size, _ := response.UintOption(OPTION_SIZE1); size != 4096 {
+		t.Fatalf("expect size1 4096, got %d", size)
+	}
+	if len(broker.Messages()) != 1 {
+		t.Fatal("too large payload should not be sent")
+	}
+}
+
+func TestCoapObserve(t *testing.T) {
+	broker := &fakeBroker{}
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+	device := dialTestClient(t, gateway)
+	defer device.Close()
+
+	observation, err := client.Observe("devices/1/cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor, err := client.Observe("devices/+/telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Observe("sensors/1"); err == nil {
+		t.Fatal("observe path without mapping rule should fail")
+	}
+
+	// 后端应用发送的消息按MQTT主题属性推送，带回Content-Format
+	cmd := message.NewMessage("DeviceCommand", "", []byte("reboot"))
+	cmd.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/1/cmd")
+	cmd.PutProperty(message.PROPERTY_COAP_CONTENT_FORMAT, "0")
+	broker.Send(cmd)
+	notification := receiveNotification(t, observation)
+	if string(notification.Payload) != "reboot" || notification.Code != CODE_CONTENT {
+		t.Fatalf("unexpected notification %s", notification)
+	}
+	if format, ok := notification.UintOption(OPTION_CONTENT_FORMAT); !ok || format != CONTENT_FORMAT_TEXT_PLAIN {
+		t.Fatalf("unexpected content format %d %t", format, ok)
+	}
+
+	// 超过块大小的通知按Block2读取完整负载
+	large := bytes.Repeat([]byte("0123456789"), 150)
+	cmd = message.NewMessage("DeviceCommand", "", large)
+	cmd.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/1/cmd")
+	broker.Send(cmd)
+	if notification = receiveNotification(t, observation); !bytes.Equal(notification.Payload, large) {
+		t.Fatalf("unexpected large notification, length %d", len(notification.Payload))
+	}
+
+	// CoAP设备上报的消息推送给通配符观察者，Location-Path为实际路径
+	response, err := device.Post("devices/7/telemetry", []byte("25"))
+	expectCode(t, response, err, CODE_CREATED)
+	notification = receiveNotification(t, monitor)
+	var location []string
+	for _, segment := range notification.OptionValues(OPTION_LOCATION_PATH) {
+		location = append(location, string(segment))
+	}
+	if string(notification.Payload) != "25" || fmt.Sprint(location) != "[devices 7 telemetry]" {
+		t.Fatalf("unexpected wildcard notification %s, location %v", notification, location)
+	}
+
+	// 取消观察后不再推送
+	if err = observation.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.ObserverCount() != 1 {
+		t.Fatalf("expect 1 observer, got %d", gateway.ObserverCount())
+	}
+	cmd = message.NewMessage("DeviceCommand", "", []byte("ignored"))
+	cmd.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/1/cmd")
+	broker.Send(cmd)
+	if _, ok := <-observation.Notifications(); ok {
+		t.Fatal("notification channel should be closed after cancel")
+	}
+}
+
+func TestCoapObserveConfirmable(t *testing.T) {
+	broker := &fakeBroker{}
+	cfg := newTestConfig()
+	cfg.ObserveConfirmable = true
+	cfg.AckTimeout = 50
+	cfg.MaxRetransmit = 1
+	gateway := startTestGateway(t, cfg, broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+
+	observation, err := client.Observe("devices/1/cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := message.NewMessage("DeviceCommand", "", []byte("on"))
+	cmd.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/1/cmd")
+	broker.Send(cmd)
+	receiveNotification(t, observation)
+	gwtest.WaitFor(t, "notification acknowledged", func() bool {
+		gateway.observers.lock.Lock()
+		defer gateway.observers.lock.Unlock()
+		return len(gateway.observers.sent) == 0
+	})
+
+	// 客户端不再确认时重发超过上限后移除观察者
+	client.Close()
+	broker.Send(cmd)
+	gwtest.WaitFor(t, "observer removed", func() bool {
+		return gateway.ObserverCount() == 0
+	})
+
+	// 客户端以RST拒绝通知时移除观察者
+	client = dialTestClient(t, gateway)
+	defer client.Close()
+	if _, err = client.Observe("devices/2/cmd"); err != nil {
+		t.Fatal(err)
+	}
+	client.removeObservation(client.observationTokens()[0])
+	cmd.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/2/cmd")
+	broker.Send(cmd)
+	gwtest.WaitFor(t, "observer reset", func() bool {
+		return gateway.ObserverCount() == 0
+	})
+}
+
+func TestCoapObserveLease(t *testing.T) {
+	broker := &fakeBroker{}
+	cfg := newTestConfig()
+	cfg.ObserveLease = 1
+	gateway := startTestGateway(t, cfg, broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	observation, err := client.Observe("devices/1/cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 租约过半后的通知以CON发送并带租约剩余时间，确认后续约
+	time.Sleep(600 * time.Millisecond)
+	cmd := message.NewMessage("DeviceCommand", "", []byte("on"))
+	cmd.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/1/cmd")
+	broker.Send(cmd)
+	notification := receiveNotification(t, observation)
+	if maxAge, ok := notification.UintOption(OPTION_MAX_AGE); notification.Type != CON || !ok || maxAge != 1 {
+		t.Fatalf("unexpected notification type %d, max-age %d", notification.Type, maxAge)
+	}
+	time.Sleep(600 * time.Millisecond)
+	if gateway.ObserverCount() != 1 {
+		t.Fatal("observer should be renewed by acknowledged notification")
+	}
+
+	// 超过租约未续约时移除观察者
+	gwtest.WaitFor(t, "observer lease expired", func() bool {
+		return gateway.ObserverCount() == 0
+	})
+}
+
+func (client *Client) observationTokens() [][]byte {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	var tokens [][]byte
+	for _, observation := range client.observations {
+		tokens = append(tokens, observation.token)
+	}
+	return tokens
+}
+
+func TestCoapConfig(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.BlockSize = 1000
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("blockSize not power of 2 should be rejected")
+	}
+	cfg = newTestConfig()
+	cfg.BlockSize, cfg.MaxMessageSize = 1024, 1024
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("maxMessageSize less than blockSize+64 should be rejected")
+	}
+	cfg = newTestConfig()
+	cfg.Rules = nil
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("no mapping rule should be rejected")
+	}
+}
@@ -0,0 +1,432 @@
+package coap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CoAP报文类型(RFC 7252 3)
+const (
+	CON byte = 0 // Confirmable，需要对端回复ACK
+	NON byte = 1 // Non-confirmable
+	ACK byte = 2 // Acknowledgement
+	RST byte = 3 // Reset，对端无法处理报文时回复
+)
+
+// 请求方法，code的class为0
+const (
+	EMPTY  byte = 0x00
+	GET    byte = 0x01
+	POST   byte = 0x02
+	PUT    byte = 0x03
+	DELETE byte = 0x04
+)
+
+// 响应码，高3位为class，低5位为detail，如2.05为(2<<5)|5
+const (
+	CODE_CREATED                    byte = 0x41 // 2.01
+	CODE_DELETED                    byte = 0x42 // 2.02
+	CODE_VALID                      byte = 0x43 // 2.03
+	CODE_CHANGED                    byte = 0x44 // 2.04
+	CODE_CONTENT                    byte = 0x45 // 2.05
+	CODE_CONTINUE                   byte = 0x5f // 2.31，块传输的中间块已接收(RFC 7959)
+	CODE_BAD_REQUEST                byte = 0x80 // 4.00
+	CODE_UNAUTHORIZED               byte = 0x81 // 4.01
+	CODE_BAD_OPTION                 byte = 0x82 // 4.02
+	CODE_FORBIDDEN                  byte = 0x83 // 4.03
+	CODE_NOT_FOUND                  byte = 0x84 // 4.04
+	CODE_METHOD_NOT_ALLOWED         byte = 0x85 // 4.05
+	CODE_NOT_ACCEPTABLE             byte = 0x86 // 4.06
+	CODE_REQUEST_ENTITY_INCOMPLETE  byte = 0x88 // 4.08，块传输缺少前面的块(RFC 7959)
+	CODE_PRECONDITION_FAILED        byte = 0x8c // 4.12
+	CODE_REQUEST_ENTITY_TOO_LARGE   byte = 0x8d // 4.13
+	CODE_UNSUPPORTED_CONTENT_FORMAT byte = 0x8f // 4.15
+	CODE_INTERNAL_SERVER_ERROR      byte = 0xa0 // 5.00
+	CODE_NOT_IMPLEMENTED            byte = 0xa1 // 5.01
+	CODE_BAD_GATEWAY                byte = 0xa2 // 5.02
+	CODE_SERVICE_UNAVAILABLE        byte = 0xa3 // 5.03
+	CODE_GATEWAY_TIMEOUT            byte = 0xa4 // 5.04
+)
+
+// 选项号，奇数为critical选项，不认识时须拒绝请求
+const (
+	OPTION_IF_MATCH       uint16 = 1
+	OPTION_URI_HOST       uint16 = 3
+	OPTION_ETAG           uint16 = 4
+	OPTION_IF_NONE_MATCH  uint16 = 5
+	OPTION_OBSERVE        uint16 = 6 // RFC 7641
+	OPTION_URI_PORT       uint16 = 7
+	OPTION_LOCATION_PATH  uint16 = 8
+	OPTION_URI_PATH       uint16 = 11
+	OPTION_CONTENT_FORMAT uint16 = 12
+	OPTION_MAX_AGE        uint16 = 14
+	OPTION_URI_QUERY      uint16 = 15
+	OPTION_ACCEPT         uint16 = 17
+	OPTION_LOCATION_QUERY uint16 = 20
+	OPTION_BLOCK2         uint16 = 23 // RFC 7959，响应体分块
+	OPTION_BLOCK1         uint16 = 27 // RFC 7959，请求体分块
+	OPTION_SIZE2          uint16 = 28
+	OPTION_PROXY_URI      uint16 = 35
+	OPTION_PROXY_SCHEME   uint16 = 39
+	OPTION_SIZE1          uint16 = 60
+)
+
+// 常用的Content-Format
+const (
+	CONTENT_FORMAT_TEXT_PLAIN   = 0
+	CONTENT_FORMAT_OCTET_STREAM = 42
+	CONTENT_FORMAT_JSON         = 50
+	CONTENT_FORMAT_CBOR         = 60
+)
+
+const (
+	VERSION          = 1
+	PAYLOAD_MARKER   = 0xff
+	MAX_TOKEN_LENGTH = 8
+	OBSERVE_REGISTER = 0 // GET请求的Observe选项值：注册观察
+	OBSERVE_CANCEL   = 1 // GET请求的Observe选项值：取消观察
+	MAX_OBSERVE_SEQ  = 1<<24 - 1
+)
+
+var knownOptions = map[uint16]bool{
+	OPTION_IF_MATCH: true, OPTION_URI_HOST: true, OPTION_ETAG: true, OPTION_IF_NONE_MATCH: true, OPTION_OBSERVE: true,
+	OPTION_URI_PORT: true, OPTION_LOCATION_PATH: true, OPTION_URI_PATH: true, OPTION_CONTENT_FORMAT: true, OPTION_MAX_AGE: true,
+	OPTION_URI_QUERY: true, OPTION_ACCEPT: true, OPTION_LOCATION_QUERY: true, OPTION_BLOCK2: true, OPTION_BLOCK1: true,
+	OPTION_SIZE2: true, OPTION_PROXY_URI: true, OPTION_PROXY_SCHEME: true, OPTION_SIZE1: true,
+}
+
+var typeNames = map[byte]string{CON: "CON", NON: "NON", ACK: "ACK", RST: "RST"}
+
+// TypeName 报文类型名称
+// Author: agent
+// Since: 2026/10/19
+func TypeName(msgType byte) string {
+	if name, ok := typeNames[msgType]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", msgType)
+}
+
+// CodeString 以"class.detail"形式表示code，如2.05
+// Author: agent
+// Since: 2026/10/19
+func CodeString(code byte) string {
+	return fmt.Sprintf("%d.%02d", code>>5, code&0x1f)
+}
+
+// IsRequest code是否为请求方法
+func IsRequest(code byte) bool {
+	return code != EMPTY && code>>5 == 0
+}
+
+// Option 报文选项，值的格式(空、uint、字符串、字节)由选项号决定
+type Option struct {
+	Number uint16
+	Value  []byte
+}
+
+// Message CoAP报文(RFC 7252 3)
+// Author: agent
+// Since: 2026/10/19
+type Message struct {
+	Type      byte
+	Code      byte
+	MessageId uint16
+	Token     []byte
+	Options   []Option // 编码时按选项号升序排列，同一选项号保持添加顺序
+	Payload   []byte
+}
+
+func (msg *Message) String() string {
+	return fmt.Sprintf("Message [type=%s, code=%s, messageId=%d, token=%x, path=%s, payload=%d]",
+		TypeName(msg.Type), CodeString(msg.Code), msg.MessageId, msg.Token, msg.Path(), len(msg.Payload))
+}
+
+// Option 第一个指定选项号的值
+func (msg *Message) Option(number uint16) ([]byte, bool) {
+	for _, option := range msg.Options {
+		if option.Number == number {
+			return option.Value, true
+		}
+	}
+	return nil, false
+}
+
+// OptionValues 指定选项号的全部值，如多段Uri-Path
+func (msg *Message) OptionValues(number uint16) [][]byte {
+	var values [][]byte
+	for _, option := range msg.Options {
+		if option.Number == number {
+			values = append(values, option.Value)
+		}
+	}
+	return values
+}
+
+// AddOption 追加选项，可重复的选项如Uri-Path依次添加
+func (msg *Message) AddOption(number uint16, value []byte) {
+	msg.Options = append(msg.Options, Option{Number: number, Value: value})
+}
+
+// SetOption 替换指定选项号的全部值
+func (msg *Message) SetOption(number uint16, value []byte) {
+	msg.RemoveOption(number)
+	msg.AddOption(number, value)
+}
+
+// RemoveOption 删除指定选项号的全部值
+func (msg *Message) RemoveOption(number uint16) {
+	options := msg.Options[:0]
+	for _, option := range msg.Options {
+		if option.Number != number {
+			options = append(options, option)
+		}
+	}
+	msg.Options = options
+}
+
+// UintOption 读取uint格式的选项
+func (msg *Message) UintOption(number uint16) (uint32, bool) {
+	value, ok := msg.Option(number)
+	if !ok {
+		return 0, false
+	}
+	return decodeUint(value), true
+}
+
+// SetUintOption 以最少字节写入uint格式的选项，0编码为空值
+func (msg *Message) SetUintOption(number uint16, value uint32) {
+	msg.SetOption(number, encodeUint(value))
+}
+
+// Path 以'/'连接的Uri-Path
+func (msg *Message) Path() string {
+	segments := msg.OptionValues(OPTION_URI_PATH)
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		parts[i] = string(segment)
+	}
+	return strings.Join(parts, "/")
+}
+
+// SetPath 按'/'拆分为多段Uri-Path，忽略首尾的'/'
+func (msg *Message) SetPath(path string) {
+	msg.RemoveOption(OPTION_URI_PATH)
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return
+	}
+	for _, segment := range strings.Split(path, "/") {
+		msg.AddOption(OPTION_URI_PATH, []byte(segment))
+	}
+}
+
+// Block 读取Block1或Block2选项
+func (msg *Message) Block(number uint16) (*Block, bool) {
+	value, ok := msg.UintOption(number)
+	if !ok {
+		return nil, false
+	}
+	return &Block{Num: value >> 4, More: value&0x08 != 0, Szx: uint8(value & 0x07)}, true
+}
+
+// SetBlock 写入Block1或Block2选项
+func (msg *Message) SetBlock(number uint16, block *Block) {
+	value := block.Num<<4 | uint32(block.Szx)
+	if block.More {
+		value |= 0x08
+	}
+	msg.SetUintOption(number, value)
+}
+
+// UnknownCriticalOption 请求中不认识的critical选项，没有时返回0
+func (msg *Message) UnknownCriticalOption() uint16 {
+	for _, option := range msg.Options {
+		if option.Number%2 == 1 && !knownOptions[option.Number] {
+			return option.Number
+		}
+	}
+	return 0
+}
+
+// Encode 编码报文
+// Author: agent
+// Since: 2026/10/19
+func (msg *Message) Encode() ([]byte, error) {
+	if len(msg.Token) > MAX_TOKEN_LENGTH {
+		return nil, fmt.Errorf("token length %d exceeds %d", len(msg.Token), MAX_TOKEN_LENGTH)
+	}
+	buf := make([]byte, 4, 4+len(msg.Token)+len(msg.Payload)+16*len(msg.Options)+1)
+	buf[0] = VERSION<<6 | (msg.Type&0x03)<<4 | byte(len(msg.Token))
+	buf[1] = msg.Code
+	binary.BigEndian.PutUint16(buf[2:], msg.MessageId)
+	buf = append(buf, msg.Token...)
+
+	options := make([]Option, len(msg.Options))
+	copy(options, msg.Options)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].Number < options[j].Number })
+	var last uint16
+	for _, option := range options {
+		if len(option.Value) > 65535+269 {
+			return nil, fmt.Errorf("option %d value length %d too large", option.Number, len(option.Value))
+		}
+		delta, deltaExt := encodeOptionNibble(int(option.Number - last))
+		length, lengthExt := encodeOptionNibble(len(option.Value))
+		buf = append(buf, delta<<4|length)
+		buf = append(buf, deltaExt...)
+		buf = append(buf, lengthExt...)
+		buf = append(buf, option.Value...)
+		last = option.Number
+	}
+
+	if len(msg.Payload) > 0 {
+		buf = append(buf, PAYLOAD_MARKER)
+		buf = append(buf, msg.Payload...)
+	}
+	return buf, nil
+}
+
+// DecodeMessage 解码报文，格式错误时返回error
+// Author: agent
+// Since: 2026/10/19
+func DecodeMessage(data []byte) (*Message, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("message too short: %d bytes", len(data))
+	}
+	if version := data[0] >> 6; version != VERSION {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	tokenLength := int(data[0] & 0x0f)
+	if tokenLength > MAX_TOKEN_LENGTH {
+		return nil, fmt.Errorf("invalid token length %d", tokenLength)
+	}
+	if len(data) < 4+tokenLength {
+		return nil, fmt.Errorf("message truncated in token")
+	}
+
+	msg := &Message{
+		Type:      (data[0] >> 4) & 0x03,
+		Code:      data[1],
+		MessageId: binary.BigEndian.Uint16(data[2:]),
+	}
+	if tokenLength > 0 {
+		msg.Token = append([]byte(nil), data[4:4+tokenLength]...)
+	}
+
+	pos := 4 + tokenLength
+	var number int
+	for pos < len(data) {
+		if data[pos] == PAYLOAD_MARKER {
+			if pos+1 == len(data) {
+				return nil, fmt.Errorf("payload marker followed by empty payload")
+			}
+			msg.Payload = append([]byte(nil), data[pos+1:]...)
+			break
+		}
+		delta, n, err := decodeOptionNibble(data, pos+1, data[pos]>>4)
+		if err != nil {
+			return nil, err
+		}
+		length, m, err := decodeOptionNibble(data, pos+1+n, data[pos]&0x0f)
+		if err != nil {
+			return nil, err
+		}
+		pos += 1 + n + m
+		if pos+length > len(data) {
+			return nil, fmt.Errorf("message truncated in option value")
+		}
+		number += delta
+		if number > 65535 {
+			return nil, fmt.Errorf("invalid option number %d", number)
+		}
+		msg.Options = append(msg.Options, Option{Number: uint16(number), Value: append([]byte{}, data[pos:pos+length]...)})
+		pos += length
+	}
+
+	if msg.Code == EMPTY && (tokenLength > 0 || len(msg.Options) > 0 || len(msg.Payload) > 0) {
+		return nil, fmt.Errorf("empty message with token, options or payload")
+	}
+	return msg, nil
+}
+
+// encodeOptionNibble 选项delta、长度的4位编码及扩展字节
+func encodeOptionNibble(value int) (byte, []byte) {
+	switch {
+	case value < 13:
+		return byte(value), nil
+	case value < 269:
+		return 13, []byte{byte(value - 13)}
+	default:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(value-269))
+		return 14, ext
+	}
+}
+
+// decodeOptionNibble 返回解码后的值及使用的扩展字节数
+func decodeOptionNibble(data []byte, pos int, nibble byte) (int, int, error) {
+	switch nibble {
+	case 13:
+		if pos >= len(data) {
+			return 0, 0, fmt.Errorf("message truncated in option header")
+		}
+		return int(data[pos]) + 13, 1, nil
+	case 14:
+		if pos+1 >= len(data) {
+			return 0, 0, fmt.Errorf("message truncated in option header")
+		}
+		return int(binary.BigEndian.Uint16(data[pos:])) + 269, 2, nil
+	case 15:
+		return 0, 0, fmt.Errorf("reserved option nibble 15")
+	default:
+		return int(nibble), 0, nil
+	}
+}
+
+func encodeUint(value uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, value)
+	for len(buf) > 0 && buf[0] == 0 {
+		buf = buf[1:]
+	}
+	return buf
+}
+
+func decodeUint(value []byte) uint32 {
+	var result uint32
+	for _, b := range value {
+		result = result<<8 | uint32(b)
+	}
+	return result
+}
+
+// Block Block1/Block2选项(RFC 7959 2.2)：块序号、是否还有后续块及块大小指数
+// Author: agent
+// Since: 2026/10/19
+type Block struct {
+	Num  uint32
+	More bool
+	Szx  uint8 // 块大小为2^(szx+4)，7保留
+}
+
+// Size 块大小，单位字节
+func (block *Block) Size() int {
+	return 1 << (block.Szx + 4)
+}
+
+// Offset 块在完整负载中的起始位置
+func (block *Block) Offset() int {
+	return int(block.Num) * block.Size()
+}
+
+// BlockSzx 块大小对应的szx，块大小须为16~1024之间2的幂
+func BlockSzx(size int) (uint8, bool) {
+	for szx := uint8(0); szx <= 6; szx++ {
+		if 1<<(szx+4) == size {
+			return szx, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,80 @@
+package coap
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMessageEncodeDecode(t *testing.T) {
+	msg := &Message{Type: CON, Code: POST, MessageId: 0x1234, Token: []byte{1, 2, 3, 4}, Payload: []byte("23.5")}
+	msg.SetPath("/devices/1/telemetry/")
+	msg.SetUintOption(OPTION_CONTENT_FORMAT, CONTENT_FORMAT_JSON)
+	msg.SetBlock(OPTION_BLOCK1, &Block{Num: 300, More: true, Szx: 6})
+	msg.AddOption(OPTION_URI_QUERY, bytes.Repeat([]byte("q"), 300))
+	msg.AddOption(OPTION_SIZE1, encodeUint(70000))
+
+	data, err := msg.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Path() != "devices/1/telemetry" || !bytes.Equal(decoded.Token, msg.Token) || string(decoded.Payload) != "23.5" {
+		t.Fatalf("unexpected decoded message %s", decoded)
+	}
+	if format, _ := decoded.UintOption(OPTION_CONTENT_FORMAT); format != CONTENT_FORMAT_JSON {
+		t.Fatalf("unexpected content format %d", format)
+	}
+	if block, _ := decoded.Block(OPTION_BLOCK1); !reflect.DeepEqual(block, &Block{Num: 300, More: true, Szx: 6}) || block.Offset() != 300*1024 {
+		t.Fatalf("unexpected block %+v", block)
+	}
+	if query, _ := decoded.Option(OPTION_URI_QUERY); len(query) != 300 {
+		t.Fatalf("unexpected query length %d", len(query))
+	}
+	if size, _ := decoded.UintOption(OPTION_SIZE1); size != 70000 {
+		t.Fatalf("unexpected size1 %d", size)
+	}
+
+	// 选项按选项号升序编码，同一选项号保持添加顺序
+	for i := 1; i < len(decoded.Options); i++ {
+		if decoded.Options[i].Number < decoded.Options[i-1].Number {
+			t.Fatalf("options not sorted %+v", decoded.Options)
+		}
+	}
+	if msg.UnknownCriticalOption() != 0 {
+		t.Fatal("unexpected unknown critical option")
+	}
+	msg.AddOption(2049, nil)
+	if msg.UnknownCriticalOption() != 2049 {
+		t.Fatal("option 2049 should be unknown critical option")
+	}
+}
+
+func TestMessageDecodeError(t *testing.T) {
+	invalids := [][]byte{
+		{0x40, 0x01},                        // 不足4字节
+		{0x80, 0x01, 0x00, 0x01},            // 版本2
+		{0x49, 0x01, 0x00, 0x01},            // token长度9
+		{0x44, 0x01, 0x00, 0x01, 0x01},      // token不完整
+		{0x40, 0x01, 0x00, 0x01, 0xff},      // 负载标记后没有负载
+		{0x40, 0x01, 0x00, 0x01, 0xb5, 'a'}, // 选项值不完整
+		{0x40, 0x01, 0x00, 0x01, 0xf0},      // 保留的delta 15
+		{0x40, 0x00, 0x00, 0x01, 0xff, 'a'}, // 空报文带负载
+	}
+	for _, data := range invalids {
+		if msg, err := DecodeMessage(data); err == nil {
+			t.Fatalf("decode %x should fail, got %s", data, msg)
+		}
+	}
+
+	ping, err := DecodeMessage([]byte{0x40, 0x00, 0x12, 0x34})
+	if err != nil || ping.Type != CON || ping.Code != EMPTY || ping.MessageId != 0x1234 {
+		t.Fatalf("unexpected ping %v %v", ping, err)
+	}
+	if CodeString(CODE_CONTENT) != "2.05" || CodeString(CODE_REQUEST_ENTITY_TOO_LARGE) != "4.13" || CodeString(CODE_CONTINUE) != "2.31" {
+		t.Fatal("unexpected code string")
+	}
+}
@@ -0,0 +1,259 @@
+package coap
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"git.oschina.net/cloudzone/smartgo/stggw/mqtt"
+)
+
+// representation 最近一次通知的完整负载，负载超过块大小时通知只带第一块，后续块由客户端按Block2读取
+type representation struct {
+	payload       []byte
+	contentFormat int    // 小于0表示不带Content-Format
+	etag          []byte // 区分不同的通知，客户端据此判断分块读取期间负载是否变化
+	location      string // 通配符观察时通知对应的实际路径，以Location-Path返回
+}
+
+// observer 按终端地址及观察路径区分的观察者(RFC 7641 4.1)，同一终端重复观察同一路径时更新token
+type observer struct {
+	key             string
+	addr            *net.UDPAddr
+	filter          string // 观察的路径，可包含'+'、'#'
+	token           []byte
+	seq             uint32 // Observe序号，24位循环递增
+	lastConfirmable int64  // 上次发送CON通知的时间，NON通知按间隔改为CON以确认观察者存活
+	leaseTime       int64  // 最近一次注册或确认CON通知的时间，超过租约未更新时移除观察者
+	latest          *representation
+}
+
+// sentNotification 已发送的通知，RST时移除观察者；CON通知未收到ACK时按指数退避重发
+type sentNotification struct {
+	observer    *observer
+	data        []byte // 仅CON通知保存，用于重发
+	confirmable bool
+	retransmit  int
+	timeout     int64
+	nextTime    int64
+	expireTime  int64
+}
+
+// observeRegistry 观察者列表，精确路径按路径索引，含通配符的路径逐个匹配
+// Author: agent
+// Since: 2026/10/19
+type observeRegistry struct {
+	observers map[string]*observer
+	exact     map[string]map[*observer]bool
+	wildcards map[*observer]bool
+	sent      map[string]*sentNotification // 终端地址#消息ID -> 已发送的通知
+	lock      sync.Mutex
+}
+
+func newObserveRegistry() *observeRegistry {
+	return &observeRegistry{
+		observers: make(map[string]*observer),
+		exact:     make(map[string]map[*observer]bool),
+		wildcards: make(map[*observer]bool),
+		sent:      make(map[string]*sentNotification),
+	}
+}
+
+func observerKey(addr string, filter string) string {
+	return addr + " " + filter
+}
+
+func sentKey(addr string, messageId uint16) string {
+	return addr + "#" + strconv.Itoa(int(messageId))
+}
+
+// register 注册或更新观察者，返回注册响应使用的Observe序号；超过maxObservers时不注册，返回false
+func (registry *observeRegistry) register(addr *net.UDPAddr, filter string, token []byte, maxObservers int, now int64) (uint32, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	key := observerKey(addr.String(), filter)
+	o, ok := registry.observers[key]
+	if !ok {
+		if len(registry.observers) >= maxObservers {
+			return 0, false
+		}
+		o = &observer{key: key, addr: addr, filter: filter, lastConfirmable: now}
+		registry.observers[key] = o
+		if mqtt.HasWildcard(filter) {
+			registry.wildcards[o] = true
+		} else {
+			if registry.exact[filter] == nil {
+				registry.exact[filter] = make(map[*observer]bool)
+			}
+			registry.exact[filter][o] = true
+		}
+	}
+	o.token = append([]byte(nil), token...)
+	o.seq = (o.seq + 1) & MAX_OBSERVE_SEQ
+	o.leaseTime = now
+	return o.seq, true
+}
+
+// cancel 取消观察
+func (registry *observeRegistry) cancel(addr string, filter string) bool {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	o, ok := registry.observers[observerKey(addr, filter)]
+	if ok {
+		registry.remove(o)
+	}
+	return ok
+}
+
+func (registry *observeRegistry) remove(o *observer) {
+	if registry.observers[o.key] != o {
+		return
+	}
+	delete(registry.observers, o.key)
+	delete(registry.wildcards, o)
+	if observers, ok := registry.exact[o.filter]; ok {
+		delete(observers, o)
+		if len(observers) == 0 {
+			delete(registry.exact, o.filter)
+		}
+	}
+}
+
+// latest 终端观察的路径最近一次通知的负载
+func (registry *observeRegistry) latest(addr string, filter string) (*representation, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	o, ok := registry.observers[observerKey(addr, filter)]
+	if !ok || o.latest == nil {
+		return nil, false
+	}
+	return o.latest, true
+}
+
+// match 观察路径匹配path的全部观察者
+func (registry *observeRegistry) match(path string) []*observer {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	var result []*observer
+	for o := range registry.exact[path] {
+		result = append(result, o)
+	}
+	for o := range registry.wildcards {
+		if mqtt.MatchTopic(o.filter, path) {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// prepare 为通知分配Observe序号并记录负载，返回token、序号、是否以CON发送及租约剩余时间；观察者已移除时返回false。
+// 租约已过半时以CON发送，确认后续约
+func (registry *observeRegistry) prepare(o *observer, latest *representation, confirmAll bool, checkInterval, lease int64, now int64) ([]byte, uint32, bool, int64, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if registry.observers[o.key] != o {
+		return nil, 0, false, 0, false
+	}
+	o.seq = (o.seq + 1) & MAX_OBSERVE_SEQ
+	latest.etag = encodeUint(o.seq)
+	o.latest = latest
+	confirmable := confirmAll || now-o.lastConfirmable >= checkInterval || now-o.leaseTime >= lease/2
+	if confirmable {
+		o.lastConfirmable = now
+	}
+	return o.token, o.seq, confirmable, o.leaseTime + lease - now, true
+}
+
+// track 记录已发送的通知，CON通知的重发超时为timeout，之后每次加倍
+func (registry *observeRegistry) track(o *observer, messageId uint16, data []byte, confirmable bool, timeout, lifetime, now int64) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	notification := &sentNotification{observer: o, confirmable: confirmable, expireTime: now + lifetime}
+	if confirmable {
+		notification.data = data
+		notification.timeout = timeout
+		notification.nextTime = now + timeout
+	}
+	registry.sent[sentKey(o.addr.String(), messageId)] = notification
+}
+
+// onAck CON通知已确认，观察者续约
+func (registry *observeRegistry) onAck(addr string, messageId uint16, now int64) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	key := sentKey(addr, messageId)
+	if notification, ok := registry.sent[key]; ok && notification.confirmable {
+		delete(registry.sent, key)
+		notification.observer.leaseTime = now
+	}
+}
+
+// onReset 客户端以RST拒绝通知时移除观察者(RFC 7641 3.6)，返回被移除的观察者
+func (registry *observeRegistry) onReset(addr string, messageId uint16) *observer {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	key := sentKey(addr, messageId)
+	notification, ok := registry.sent[key]
+	if !ok {
+		return nil
+	}
+	delete(registry.sent, key)
+	registry.remove(notification.observer)
+	return notification.observer
+}
+
+// retransmit 返回需要重发的CON通知，重发次数超过maxRetransmit时移除观察者
+func (registry *observeRegistry) retransmit(maxRetransmit int, now int64) (map[*observer][][]byte, []*observer) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	resend := make(map[*observer][][]byte)
+	var removed []*observer
+	for key, notification := range registry.sent {
+		o := notification.observer
+		if registry.observers[o.key] != o {
+			delete(registry.sent, key)
+			continue
+		}
+		if !notification.confirmable || notification.nextTime > now {
+			continue
+		}
+		if notification.retransmit >= maxRetransmit {
+			delete(registry.sent, key)
+			registry.remove(o)
+			removed = append(removed, o)
+			continue
+		}
+		notification.retransmit++
+		notification.timeout *= 2
+		notification.nextTime = now + notification.timeout
+		resend[o] = append(resend[o], notification.data)
+	}
+	return resend, removed
+}
+
+// expire 清除超过生命周期的NON通知记录，移除超过租约未重新注册、未确认CON通知的观察者，返回被移除的观察者
+func (registry *observeRegistry) expire(lease int64, now int64) []*observer {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	for key, notification := range registry.sent {
+		if !notification.confirmable && notification.expireTime <= now {
+			delete(registry.sent, key)
+		}
+	}
+
+	var removed []*observer
+	for _, o := range registry.observers {
+		if now-o.leaseTime >= lease {
+			registry.remove(o)
+			removed = append(removed, o)
+		}
+	}
+	return removed
+}
+
+func (registry *observeRegistry) size() int {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	return len(registry.observers)
+}
@@ -0,0 +1,59 @@
+// Package gwtest coap、rest、stomp网关测试共用的替身及等待函数
+package gwtest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// Broker 内存broker的公共部分：Start、Shutdown不做任何事，按发送顺序记录消息并生成msg-N的消息ID；
+// 各网关测试的broker嵌入Broker，再实现各自的投递方式
+// Author: agent
+// Since: 2026/10/19
+type Broker struct {
+	sent []*message.Message
+	lock sync.Mutex
+}
+
+func (b *Broker) Start()    {}
+func (b *Broker) Shutdown() {}
+
+// Record 记录发送的消息，返回带消息ID的MessageExt
+func (b *Broker) Record(msg *message.Message) *message.MessageExt {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.sent = append(b.sent, msg)
+	return &message.MessageExt{Message: *msg, MsgId: fmt.Sprintf("msg-%d", len(b.sent))}
+}
+
+// Messages 按发送顺序返回已记录的消息
+func (b *Broker) Messages() []*message.Message {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]*message.Message(nil), b.sent...)
+}
+
+// NopConsumer Start、Shutdown不做任何事的consumer
+// Author: agent
+// Since: 2026/10/19
+type NopConsumer struct{}
+
+func (c *NopConsumer) Start()    {}
+func (c *NopConsumer) Shutdown() {}
+
+// WaitFor 每10毫秒检查一次条件，3秒内不成立时测试失败
+// Author: agent
+// Since: 2026/10/19
+func WaitFor(t testing.TB, desc string, condition func() bool) {
+	for i := 0; i < 300; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("wait for %s timeout", desc)
+}
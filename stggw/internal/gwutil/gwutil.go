@@ -0,0 +1,35 @@
+// Package gwutil 各协议网关共用的producer、consumer接口及工具函数
+package gwutil
+
+import (
+	"fmt"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// MessageProducer 网关内嵌的producer，便于测试时替换
+// Author: agent
+// Since: 2026/10/19
+type MessageProducer interface {
+	Start()
+	Shutdown()
+	Send(msg *message.Message) (*process.SendResult, error)
+}
+
+// MessageConsumer 网关内嵌的consumer，便于测试时替换
+// Author: agent
+// Since: 2026/10/19
+type MessageConsumer interface {
+	Start()
+	Shutdown()
+}
+
+// RecoverError 以defer调用，将客户端API的panic转为err返回
+// Author: agent
+// Since: 2026/10/19
+func RecoverError(err *error) {
+	if e := recover(); e != nil {
+		*err = fmt.Errorf("%v", e)
+	}
+}
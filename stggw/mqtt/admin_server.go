@@ -0,0 +1,163 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+// GatewayAdminServer MQTT网关管理HTTP服务：查询会话列表、踢出会话、查询保留消息
+//
+// 注意：默认只绑定本机地址
+//
+// Author: agent
+// Since: 2026/10/19
+type GatewayAdminServer struct {
+	gateway  *MqttGateway
+	addr     string
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewGatewayAdminServer 初始化管理HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func NewGatewayAdminServer(gateway *MqttGateway, addr string) *GatewayAdminServer {
+	return &GatewayAdminServer{gateway: gateway, addr: addr}
+}
+
+// Start 启动管理HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func (self *GatewayAdminServer) Start() error {
+	listener, err := net.Listen("tcp", self.addr)
+	if err != nil {
+		return err
+	}
+	self.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sessions", self.serveSessions)
+	mux.HandleFunc("/sessions/kick", self.serveKick)
+	mux.HandleFunc("/retained", self.serveRetained)
+	self.server = &http.Server{Handler: mux}
+
+	go func() {
+		defer utils.RecoveredFn()
+		if err := self.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("mqtt admin server serve err: %s", err.Error())
+		}
+	}()
+
+	logger.Infof("mqtt admin server start successful, listen %s", listener.Addr().String())
+	return nil
+}
+
+// Shutdown 关闭管理HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func (self *GatewayAdminServer) Shutdown() {
+	if self.server != nil {
+		self.server.Close()
+		logger.Info("mqtt admin server shutdown successful")
+	}
+}
+
+// Addr 管理HTTP服务实际监听的地址
+// Author: agent
+// Since: 2026/10/19
+func (self *GatewayAdminServer) Addr() string {
+	if self.listener != nil {
+		return self.listener.Addr().String()
+	}
+	return self.addr
+}
+
+// serveSessions GET /sessions 列出全部会话
+func (self *GatewayAdminServer) serveSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	self.writeJSON(w, r, http.StatusOK, self.gateway.ListSessions())
+}
+
+// serveKick POST /sessions/kick?clientId=xxx&discard=true 踢出会话，discard为true时同时清除持久会话
+func (self *GatewayAdminServer) serveKick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	clientId := query.Get("clientId")
+	if clientId == "" {
+		http.Error(w, "clientId is empty", http.StatusBadRequest)
+		return
+	}
+	discard := false
+	if value := query.Get("discard"); value != "" {
+		var err error
+		if discard, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "invalid discard "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := self.gateway.KickSession(clientId, discard); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logger.Infof("mqtt admin kick session %s, discard=%t", clientId, discard)
+	self.writeJSON(w, r, http.StatusOK, map[string]interface{}{"clientId": clientId, "discard": discard})
+}
+
+// serveRetained GET /retained?filter=devices/# 查询匹配过滤器的保留消息，默认查询全部
+func (self *GatewayAdminServer) serveRetained(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		filter = "#"
+	}
+	if !ValidTopicFilter(filter) {
+		http.Error(w, "invalid filter "+filter, http.StatusBadRequest)
+		return
+	}
+	messages := self.gateway.retain.Match(filter)
+	if messages == nil {
+		messages = []*RetainedMessage{}
+	}
+	self.writeJSON(w, r, http.StatusOK, messages)
+}
+
+// writeJSON 以JSON格式输出，携带pretty参数时格式化输出
+func (self *GatewayAdminServer) writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	var (
+		content []byte
+		err     error
+	)
+	if _, pretty := r.URL.Query()["pretty"]; pretty {
+		content, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		content, err = json.Marshal(data)
+	}
+	if err != nil {
+		logger.Errorf("mqtt admin server encode %s err: %s", r.URL.Path, err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(content)
+}
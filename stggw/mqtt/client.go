@@ -0,0 +1,315 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client 简单的MQTT 3.1.1客户端，用于测试及联调网关，收到的QoS1/QoS2消息自动确认
+// Author: agent
+// Since: 2026/10/19
+type Client struct {
+	conn           net.Conn
+	timeout        time.Duration
+	messages       chan *PublishPacket
+	pending        map[uint16]chan Packet // 报文标识 -> 等待的确认报文
+	received       map[uint16]bool        // 已回复PUBREC、等待PUBREL的QoS2报文标识
+	pingChan       chan struct{}
+	connack        chan *ConnackPacket
+	sessionPresent bool
+	nextId         uint16
+	lock           sync.Mutex
+	done           chan struct{}
+}
+
+// DialClient 连接网关并完成CONNECT，timeout同时作为后续请求等待确认的超时时间
+// Author: agent
+// Since: 2026/10/19
+func DialClient(addr string, connect *ConnectPacket, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, connect, timeout)
+}
+
+// DialWebSocketClient 通过WebSocket(ws://或wss://)连接网关并完成CONNECT，origin为空时不发送Origin头部
+// Author: agent
+// Since: 2026/10/19
+func DialWebSocketClient(rawurl, origin string, tlsConfig *tls.Config, connect *ConnectPacket, timeout time.Duration) (*Client, error) {
+	conn, err := DialWebSocket(rawurl, origin, tlsConfig, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, connect, timeout)
+}
+
+// NewClient 在已建立的连接上完成CONNECT，失败时关闭连接
+// Author: agent
+// Since: 2026/10/19
+func NewClient(conn net.Conn, connect *ConnectPacket, timeout time.Duration) (*Client, error) {
+	client := &Client{
+		conn:     conn,
+		timeout:  timeout,
+		messages: make(chan *PublishPacket, 1024),
+		pending:  make(map[uint16]chan Packet),
+		received: make(map[uint16]bool),
+		pingChan: make(chan struct{}, 1),
+		connack:  make(chan *ConnackPacket, 1),
+		done:     make(chan struct{}),
+	}
+	go client.readLoop()
+
+	if err := client.write(connect); err != nil {
+		client.Close()
+		return nil, err
+	}
+	select {
+	case ack := <-client.connack:
+		if ack.ReturnCode != CONNACK_ACCEPTED {
+			client.Close()
+			return nil, fmt.Errorf("connection refused, return code %d", ack.ReturnCode)
+		}
+		client.sessionPresent = ack.SessionPresent
+		return client, nil
+	case <-client.done:
+		return nil, fmt.Errorf("connection closed before CONNACK")
+	case <-time.After(timeout):
+		client.Close()
+		return nil, fmt.Errorf("wait CONNACK timeout")
+	}
+}
+
+// Messages 收到的PUBLISH报文
+func (client *Client) Messages() <-chan *PublishPacket {
+	return client.messages
+}
+
+// SessionPresent CONNACK中网关是否恢复了已有的持久会话
+func (client *Client) SessionPresent() bool {
+	return client.sessionPresent
+}
+
+// Done 连接关闭时关闭
+func (client *Client) Done() <-chan struct{} {
+	return client.done
+}
+
+// Publish 发布消息，QoS1时等待PUBACK，QoS2时依次等待PUBREC、PUBCOMP
+func (client *Client) Publish(topic string, qos byte, payload []byte) error {
+	return client.publish(&PublishPacket{Qos: qos, TopicName: topic, Payload: payload})
+}
+
+// PublishRetain 发布保留消息，payload为空时清除主题上的保留消息
+func (client *Client) PublishRetain(topic string, qos byte, payload []byte) error {
+	return client.publish(&PublishPacket{Qos: qos, Retain: true, TopicName: topic, Payload: payload})
+}
+
+func (client *Client) publish(packet *PublishPacket) error {
+	qos := packet.Qos
+	if qos == 0 {
+		return client.write(packet)
+	}
+
+	id := client.allocate()
+	defer client.release(id)
+	packet.PacketId = id
+	ack, err := client.exchange(id, packet)
+	if err != nil || qos == 1 {
+		return err
+	}
+	if _, ok := ack.(*PubrecPacket); !ok {
+		return fmt.Errorf("unexpected publish ack %s", PacketName(ack.Type()))
+	}
+	_, err = client.exchange(id, &PubrelPacket{PacketId: id})
+	return err
+}
+
+// Subscribe 订阅，返回授予的QoS(订阅失败为0x80)
+func (client *Client) Subscribe(filter string, qos byte) (byte, error) {
+	ack, err := client.request(func(id uint16) Packet {
+		return &SubscribePacket{PacketId: id, Subscriptions: []Subscription{{Filter: filter, Qos: qos}}}
+	})
+	if err != nil {
+		return 0, err
+	}
+	suback, ok := ack.(*SubackPacket)
+	if !ok || len(suback.ReturnCodes) != 1 {
+		return 0, fmt.Errorf("unexpected subscribe ack %s", PacketName(ack.Type()))
+	}
+	return suback.ReturnCodes[0], nil
+}
+
+// Unsubscribe 取消订阅
+func (client *Client) Unsubscribe(filter string) error {
+	_, err := client.request(func(id uint16) Packet {
+		return &UnsubscribePacket{PacketId: id, Filters: []string{filter}}
+	})
+	return err
+}
+
+// Ping 发送心跳并等待响应
+func (client *Client) Ping() error {
+	if err := client.write(&PingreqPacket{}); err != nil {
+		return err
+	}
+	select {
+	case <-client.pingChan:
+		return nil
+	case <-client.done:
+		return fmt.Errorf("connection closed")
+	case <-time.After(client.timeout):
+		return fmt.Errorf("wait PINGRESP timeout")
+	}
+}
+
+// Disconnect 发送DISCONNECT后关闭连接
+func (client *Client) Disconnect() {
+	client.write(&DisconnectPacket{})
+	client.Close()
+}
+
+// Close 直接关闭连接
+func (client *Client) Close() {
+	client.conn.Close()
+}
+
+func (client *Client) request(build func(id uint16) Packet) (Packet, error) {
+	id := client.allocate()
+	defer client.release(id)
+	return client.exchange(id, build(id))
+}
+
+// allocate 分配报文标识并登记等待确认
+func (client *Client) allocate() uint16 {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.nextId++
+	if client.nextId == 0 {
+		client.nextId = 1
+	}
+	client.pending[client.nextId] = make(chan Packet, 1)
+	return client.nextId
+}
+
+func (client *Client) release(id uint16) {
+	client.lock.Lock()
+	delete(client.pending, id)
+	client.lock.Unlock()
+}
+
+// exchange 发送报文并等待同一报文标识的确认报文
+func (client *Client) exchange(id uint16, packet Packet) (Packet, error) {
+	client.lock.Lock()
+	ackChan := client.pending[id]
+	client.lock.Unlock()
+
+	if err := client.write(packet); err != nil {
+		return nil, err
+	}
+	select {
+	case ack := <-ackChan:
+		return ack, nil
+	case <-client.done:
+		return nil, fmt.Errorf("connection closed")
+	case <-time.After(client.timeout):
+		return nil, fmt.Errorf("wait ack of %s timeout", PacketName(packet.Type()))
+	}
+}
+
+func (client *Client) write(packet Packet) error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	_, err := client.conn.Write(packet.Encode())
+	return err
+}
+
+func (client *Client) readLoop() {
+	defer close(client.done)
+
+	var buffer []byte
+	b := make([]byte, 4096)
+	for {
+		n, err := client.conn.Read(b)
+		if err != nil {
+			return
+		}
+		buffer = append(buffer, b[:n]...)
+		for {
+			packet, size, err := DecodePacket(buffer, 0)
+			if err != nil {
+				client.conn.Close()
+				return
+			}
+			if packet == nil {
+				break
+			}
+			buffer = buffer[size:]
+			client.onPacket(packet)
+		}
+	}
+}
+
+func (client *Client) onPacket(packet Packet) {
+	switch p := packet.(type) {
+	case *ConnackPacket:
+		select {
+		case client.connack <- p:
+		default:
+		}
+	case *PublishPacket:
+		switch p.Qos {
+		case 1:
+			client.write(&PubackPacket{PacketId: p.PacketId})
+		case 2:
+			// 重发的QoS2报文只回复PUBREC，不重复投递
+			client.lock.Lock()
+			duplicate := client.received[p.PacketId]
+			client.received[p.PacketId] = true
+			client.lock.Unlock()
+			client.write(&PubrecPacket{PacketId: p.PacketId})
+			if duplicate {
+				return
+			}
+		}
+		select {
+		case client.messages <- p:
+		default:
+		}
+	case *PingrespPacket:
+		select {
+		case client.pingChan <- struct{}{}:
+		default:
+		}
+	case *PubrelPacket:
+		client.lock.Lock()
+		delete(client.received, p.PacketId)
+		client.lock.Unlock()
+		client.write(&PubcompPacket{PacketId: p.PacketId})
+	case *PubackPacket:
+		client.ack(p.PacketId, p)
+	case *PubrecPacket:
+		client.ack(p.PacketId, p)
+	case *PubcompPacket:
+		client.ack(p.PacketId, p)
+	case *SubackPacket:
+		client.ack(p.PacketId, p)
+	case *UnsubackPacket:
+		client.ack(p.PacketId, p)
+	}
+}
+
+func (client *Client) ack(id uint16, packet Packet) {
+	client.lock.Lock()
+	ackChan, ok := client.pending[id]
+	client.lock.Unlock()
+	if ok {
+		select {
+		case ackChan <- packet:
+		default:
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mqtt
+
+import (
+	"sync"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// compactedTopic 以smartgo topic保存按key覆盖的状态，多个网关节点共享：
+// 写入时发送一条以key为Keys的消息，启动时从头读取全部消息，之后定时读取其他节点写入的消息；
+// 同一key的多条消息由apply按新旧合并(读取时压缩)
+// Author: agent
+// Since: 2026/10/19
+type compactedTopic struct {
+	topic        string
+	producer     messageProducer
+	reader       messageReader
+	syncInterval time.Duration
+	apply        func(body []byte) error
+	offsets      map[string]*QueueOffset // 各队列已读取的位置
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+func newCompactedTopic(topic string, producer messageProducer, reader messageReader, syncInterval time.Duration, apply func(body []byte) error) *compactedTopic {
+	return &compactedTopic{
+		topic:        topic,
+		producer:     producer,
+		reader:       reader,
+		syncInterval: syncInterval,
+		apply:        apply,
+		offsets:      make(map[string]*QueueOffset),
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// start 读取topic中的全部消息后启动后台同步
+func (log *compactedTopic) start() error {
+	if err := log.sync(); err != nil {
+		return err
+	}
+
+	log.wg.Add(1)
+	go func() {
+		defer log.wg.Done()
+		ticker := time.NewTicker(log.syncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-log.stopChan:
+				return
+			case <-ticker.C:
+				if err := log.sync(); err != nil {
+					logger.Warnf("sync mqtt state from topic %s failed: %s", log.topic, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (log *compactedTopic) shutdown() {
+	close(log.stopChan)
+	log.wg.Wait()
+}
+
+// send 写入一条状态消息
+func (log *compactedTopic) send(key string, body []byte) error {
+	msg := message.NewMessage(log.topic, "", body)
+	msg.ClearProperty(message.PROPERTY_TAGS)
+	msg.SetKeys(key)
+	_, err := log.producer.Send(msg)
+	return err
+}
+
+// sync 读取各队列中新写入的消息，新增的队列从头读取
+func (log *compactedTopic) sync() error {
+	mqs, err := log.reader.FetchMessageQueues(log.topic)
+	if err != nil {
+		return err
+	}
+	for _, mq := range mqs {
+		position, ok := log.offsets[queueKey(mq)]
+		if !ok {
+			position = &QueueOffset{Topic: mq.Topic, BrokerName: mq.BrokerName, QueueId: mq.QueueId}
+			log.offsets[queueKey(mq)] = position
+		}
+		next, err := readQueue(log.reader, mq, "*", position.Offset, -1, func(msg *message.MessageExt) {
+			if err := log.apply(msg.Body); err != nil {
+				logger.Warnf("skip invalid mqtt state at %s offset %d: %s", queueKey(mq), msg.QueueOffset, err)
+			}
+		})
+		position.Offset = next
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"github.com/BurntSushi/toml"
+)
+
+// GatewayConfig MQTT网关配置项
+// Author: agent
+// Since: 2026/10/19
+type GatewayConfig struct {
+	ListenHost        string         // 监听地址
+	ListenPort        int            // 监听端口，默认1883
+	NamesrvAddr       string         // namesrv地址，多个以分号分隔
+	ProducerGroup     string         // 内嵌producer的group
+	ConsumerGroup     string         // 内嵌consumer的group，广播消费
+	MaxPacketSize     int            // 单个报文最大字节数
+	ConnectTimeout    int            // 建立连接后等待CONNECT报文的超时时间，单位秒
+	RetryInterval     int            // QoS1/QoS2下行消息未收到确认时的重发间隔，单位秒
+	MaxInflight       int            // 每个会话最多未确认的QoS1/QoS2下行消息数
+	MaxQueuedMessages int            // 每个会话超过inflight上限后排队的消息数，超出时丢弃最早的消息
+	Rules             []*MappingRule `toml:"rule"` // [[rule]]段，MQTT主题到smartgo topic的映射规则
+
+	GatewayName               string // 网关节点名称，多节点时须唯一，默认为主机名:监听端口
+	SessionStore              string // 持久会话存储：topic(保存在smartgo topic中，多节点共享)或memory(仅本节点)
+	SessionTopic              string // 保存持久会话快照的smartgo topic
+	SessionExpiryInterval     int    // 持久会话断开后的保留时间，单位秒
+	SessionCheckpointInterval int    // 在线持久会话状态变化后写入快照的间隔，单位秒
+	SessionRefreshInterval    int    // 在线持久会话状态无变化时刷新快照的间隔，单位秒，超过3个间隔未刷新视为持有节点已退出
+	SessionSyncInterval       int    // 从会话topic同步其他节点快照的间隔，单位毫秒
+	RetainStore               string // 保留消息存储：topic(保存在smartgo topic中，多节点共享)或memory(仅本节点)
+	RetainTopic               string // 保存保留消息的smartgo topic
+	RetainRefreshInterval     int    // 保留消息超过该时间未写入时重写，须小于broker消息保留时间，单位秒
+	AdminServerEnable         bool   // 是否启动管理HTTP服务
+	AdminServerAddr           string // 管理HTTP服务监听地址
+
+	IdleTimeout      int      // 连接读写空闲超过该时间后关闭，TCP与WebSocket连接一致处理，0表示不检测，单位秒
+	WsEnable         bool     // 是否启动MQTT over WebSocket监听
+	WsListenPort     int      // WebSocket监听端口，监听地址同listenHost，默认8083
+	WsPath           string   // WebSocket握手路径
+	WsAllowedOrigins []string // 允许的浏览器来源，如https://console.example.com，"*"表示任意来源，为空时只允许与Host相同的来源
+	WsTlsEnable      bool     // WebSocket监听是否使用TLS(wss://)
+	WsTlsCertFile    string   // 服务端证书
+	WsTlsKeyFile     string   // 服务端私钥
+	WsTlsCAFile      string   // 校验客户端证书的CA
+	WsTlsClientAuth  bool     // 是否要求客户端证书
+
+	DeviceRouteEnable       bool   // 是否向namesrv注册本节点的设备连接，接收点对点推送给设备的消息
+	DeviceTopic             string // 点对点推送消息的smartgo topic，tag为设备所在的网关节点名称
+	DevicePushTopic         string // 推送给设备的MQTT主题，{clientId}替换为设备ID，消息带有MQTT主题属性时以消息为准
+	DeviceRouteLease        int    // 网关节点在namesrv上的租约，超过该时间未续约时删除节点的全部设备路由，单位秒
+	DeviceRouteHeartbeat    int    // 没有设备上下线时续约的间隔，须小于租约，单位秒
+	DeviceRouteSyncInterval int    // 批量注册、注销设备连接的间隔，单位毫秒
+
+	ShadowEnable          bool   // 是否启用设备影子
+	ShadowTopic           string // 保存影子文档的smartgo topic，以设备ID为消息key
+	ShadowMaxSize         int    // 单个影子文档的最大字节数
+	ShadowRefreshInterval int    // 影子文档超过该时间未写入时重写，须小于broker消息保留时间，单位秒
+	ShadowCacheSize       int    // 每个节点缓存的影子文档数量上限，超过时淘汰最久未访问的文档
+	ShadowServerAddr      string // 影子HTTP接口监听地址，为空时不启动
+}
+
+// 会话、保留消息的存储方式
+const (
+	SESSION_STORE_TOPIC  = "topic"
+	SESSION_STORE_MEMORY = "memory"
+)
+
+// NewGatewayConfig 创建默认配置
+// Author: agent
+// Since: 2026/10/19
+func NewGatewayConfig() *GatewayConfig {
+	return &GatewayConfig{
+		ListenHost:        "0.0.0.0",
+		ListenPort:        1883,
+		NamesrvAddr:       "127.0.0.1:9876",
+		ProducerGroup:     "PID_MQTT_GATEWAY",
+		ConsumerGroup:     "GID_MQTT_GATEWAY",
+		MaxPacketSize:     1024 * 128,
+		ConnectTimeout:    10,
+		RetryInterval:     20,
+		MaxInflight:       32,
+		MaxQueuedMessages: 1000,
+
+		SessionStore:              SESSION_STORE_TOPIC,
+		SessionTopic:              "MQTT_SESSION",
+		SessionExpiryInterval:     7200,
+		SessionCheckpointInterval: 5,
+		SessionRefreshInterval:    60,
+		SessionSyncInterval:       1000,
+		RetainStore:               SESSION_STORE_TOPIC,
+		RetainTopic:               "MQTT_RETAIN",
+		RetainRefreshInterval:     86400,
+		AdminServerAddr:           "127.0.0.1:11883",
+		WsListenPort:              8083,
+		WsPath:                    "/mqtt",
+		DeviceTopic:               stgcommon.DEVICE_MESSAGE_TOPIC,
+		DevicePushTopic:           "devices/" + DEVICE_ID_PLACEHOLDER + "/push",
+		DeviceRouteLease:          120,
+		DeviceRouteHeartbeat:      30,
+		DeviceRouteSyncInterval:   200,
+		ShadowTopic:               "MQTT_SHADOW",
+		ShadowMaxSize:             1024 * 8,
+		ShadowRefreshInterval:     86400,
+		ShadowCacheSize:           100000,
+		ShadowServerAddr:          "127.0.0.1:11884",
+	}
+}
+
+// LoadGatewayConfig 加载toml配置文件，未配置的项使用默认值
+// Author: agent
+// Since: 2026/10/19
+func LoadGatewayConfig(path string) (*GatewayConfig, error) {
+	cfg := NewGatewayConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("parse gateway config %s failed: %s", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate 校验配置项
+// Author: agent
+// Since: 2026/10/19
+func (cfg *GatewayConfig) Validate() error {
+	if cfg.ListenPort < 0 || cfg.ListenPort > 65535 {
+		return fmt.Errorf("invalid listenPort %d", cfg.ListenPort)
+	}
+	if cfg.NamesrvAddr == "" {
+		return fmt.Errorf("namesrvAddr is empty")
+	}
+	if cfg.MaxPacketSize <= 0 || cfg.MaxPacketSize > MAX_REMAINING_SIZE {
+		return fmt.Errorf("invalid maxPacketSize %d", cfg.MaxPacketSize)
+	}
+	if cfg.ConnectTimeout <= 0 || cfg.RetryInterval <= 0 || cfg.MaxInflight <= 0 || cfg.MaxInflight > 65535 || cfg.MaxQueuedMessages < 0 {
+		return fmt.Errorf("connectTimeout, retryInterval and maxInflight must be positive, maxQueuedMessages must not be negative")
+	}
+	if cfg.SessionStore != SESSION_STORE_TOPIC && cfg.SessionStore != SESSION_STORE_MEMORY {
+		return fmt.Errorf("invalid sessionStore %s, expect %s or %s", cfg.SessionStore, SESSION_STORE_TOPIC, SESSION_STORE_MEMORY)
+	}
+	if cfg.SessionStore == SESSION_STORE_TOPIC && cfg.SessionTopic == "" {
+		return fmt.Errorf("sessionTopic is empty")
+	}
+	if cfg.RetainStore != SESSION_STORE_TOPIC && cfg.RetainStore != SESSION_STORE_MEMORY {
+		return fmt.Errorf("invalid retainStore %s, expect %s or %s", cfg.RetainStore, SESSION_STORE_TOPIC, SESSION_STORE_MEMORY)
+	}
+	if cfg.RetainStore == SESSION_STORE_TOPIC && (cfg.RetainTopic == "" || cfg.RetainTopic == cfg.SessionTopic) {
+		return fmt.Errorf("retainTopic is empty or same as sessionTopic")
+	}
+	if cfg.RetainRefreshInterval <= 0 {
+		return fmt.Errorf("retainRefreshInterval must be positive")
+	}
+	if cfg.SessionExpiryInterval <= 0 || cfg.SessionCheckpointInterval <= 0 || cfg.SessionRefreshInterval <= 0 || cfg.SessionSyncInterval <= 0 {
+		return fmt.Errorf("sessionExpiryInterval, sessionCheckpointInterval, sessionRefreshInterval and sessionSyncInterval must be positive")
+	}
+	if cfg.AdminServerEnable && cfg.AdminServerAddr == "" {
+		return fmt.Errorf("adminServerAddr is empty")
+	}
+	if cfg.IdleTimeout < 0 {
+		return fmt.Errorf("idleTimeout must not be negative")
+	}
+	if err := cfg.validateWebSocket(); err != nil {
+		return err
+	}
+	if err := cfg.validateDeviceRoute(); err != nil {
+		return err
+	}
+	if err := cfg.validateShadow(); err != nil {
+		return err
+	}
+	_, err := NewTopicMapper(cfg.Rules)
+	return err
+}
+
+func (cfg *GatewayConfig) validateWebSocket() error {
+	if !cfg.WsEnable {
+		return nil
+	}
+	if cfg.WsListenPort < 0 || cfg.WsListenPort > 65535 || (cfg.WsListenPort != 0 && cfg.WsListenPort == cfg.ListenPort) {
+		return fmt.Errorf("invalid wsListenPort %d", cfg.WsListenPort)
+	}
+	if !strings.HasPrefix(cfg.WsPath, "/") {
+		return fmt.Errorf("invalid wsPath %q", cfg.WsPath)
+	}
+	if !cfg.WsTlsEnable {
+		return nil
+	}
+	if cfg.WsTlsCertFile == "" || cfg.WsTlsKeyFile == "" {
+		return fmt.Errorf("wsTlsCertFile and wsTlsKeyFile are required when wsTlsEnable")
+	}
+	if cfg.WsTlsClientAuth && cfg.WsTlsCAFile == "" {
+		return fmt.Errorf("wsTlsCAFile is required when wsTlsClientAuth")
+	}
+	return nil
+}
+
+func (cfg *GatewayConfig) validateDeviceRoute() error {
+	if !cfg.DeviceRouteEnable {
+		return nil
+	}
+	if strings.TrimSpace(cfg.DeviceTopic) == "" {
+		return fmt.Errorf("deviceTopic is empty")
+	}
+	if !ValidTopicName(strings.Replace(cfg.DevicePushTopic, DEVICE_ID_PLACEHOLDER, "device", -1)) {
+		return fmt.Errorf("invalid devicePushTopic %q", cfg.DevicePushTopic)
+	}
+	if cfg.GatewayName == stgcommon.DEVICE_OFFLINE_TAG || strings.Contains(cfg.GatewayName, "||") {
+		return fmt.Errorf("gatewayName %q can not be used as device message tag", cfg.GatewayName)
+	}
+	if cfg.DeviceRouteSyncInterval <= 0 || cfg.DeviceRouteHeartbeat <= 0 || cfg.DeviceRouteHeartbeat >= cfg.DeviceRouteLease {
+		return fmt.Errorf("deviceRouteSyncInterval and deviceRouteHeartbeat must be positive, deviceRouteHeartbeat must be less than deviceRouteLease")
+	}
+	return nil
+}
+
+func (cfg *GatewayConfig) validateShadow() error {
+	if !cfg.ShadowEnable {
+		return nil
+	}
+	if strings.TrimSpace(cfg.ShadowTopic) == "" {
+		return fmt.Errorf("shadowTopic is empty")
+	}
+	if cfg.SessionStore == SESSION_STORE_TOPIC && cfg.ShadowTopic == cfg.SessionTopic ||
+		cfg.RetainStore == SESSION_STORE_TOPIC && cfg.ShadowTopic == cfg.RetainTopic {
+		return fmt.Errorf("shadowTopic %s is same as sessionTopic or retainTopic", cfg.ShadowTopic)
+	}
+	if cfg.ShadowMaxSize <= 0 || cfg.ShadowMaxSize > cfg.MaxPacketSize {
+		return fmt.Errorf("shadowMaxSize must be positive and not greater than maxPacketSize")
+	}
+	if cfg.ShadowRefreshInterval <= 0 {
+		return fmt.Errorf("shadowRefreshInterval must be positive")
+	}
+	if cfg.ShadowCacheSize <= 0 {
+		return fmt.Errorf("shadowCacheSize must be positive")
+	}
+	return nil
+}
+
+// WsTLSOptions WebSocket监听的TLS配置，未启用时返回nil
+func (cfg *GatewayConfig) WsTLSOptions() *netm.TLSOptions {
+	if !cfg.WsTlsEnable {
+		return nil
+	}
+	return &netm.TLSOptions{
+		Mode:       netm.TLS_MODE_ENFORCING,
+		CertFile:   cfg.WsTlsCertFile,
+		KeyFile:    cfg.WsTlsKeyFile,
+		CAFile:     cfg.WsTlsCAFile,
+		ClientAuth: cfg.WsTlsClientAuth,
+	}
+}
+
+func (cfg *GatewayConfig) String() string {
+	format := "GatewayConfig [listenHost=%s, listenPort=%d, namesrvAddr=%s, producerGroup=%s, consumerGroup=%s, maxPacketSize=%d, "
+	format += "connectTimeout=%d, retryInterval=%d, maxInflight=%d, maxQueuedMessages=%d, rules=%d, gatewayName=%s, sessionStore=%s, "
+	format += "sessionTopic=%s, sessionExpiryInterval=%d, sessionCheckpointInterval=%d, sessionRefreshInterval=%d, sessionSyncInterval=%d, "
+	format += "retainStore=%s, retainTopic=%s, retainRefreshInterval=%d, adminServerEnable=%t, adminServerAddr=%s, idleTimeout=%d, "
+	format += "wsEnable=%t, wsListenPort=%d, wsPath=%s, wsAllowedOrigins=%v, wsTlsEnable=%t, deviceRouteEnable=%t, deviceTopic=%s, "
+	format += "devicePushTopic=%s, deviceRouteLease=%d, deviceRouteHeartbeat=%d, deviceRouteSyncInterval=%d, shadowEnable=%t, "
+	format += "shadowTopic=%s, shadowMaxSize=%d, shadowRefreshInterval=%d, shadowCacheSize=%d, shadowServerAddr=%s]"
+	return fmt.Sprintf(format, cfg.ListenHost, cfg.ListenPort, cfg.NamesrvAddr, cfg.ProducerGroup, cfg.ConsumerGroup, cfg.MaxPacketSize,
+		cfg.ConnectTimeout, cfg.RetryInterval, cfg.MaxInflight, cfg.MaxQueuedMessages, len(cfg.Rules), cfg.GatewayName, cfg.SessionStore,
+		cfg.SessionTopic, cfg.SessionExpiryInterval, cfg.SessionCheckpointInterval, cfg.SessionRefreshInterval, cfg.SessionSyncInterval,
+		cfg.RetainStore, cfg.RetainTopic, cfg.RetainRefreshInterval, cfg.AdminServerEnable, cfg.AdminServerAddr, cfg.IdleTimeout,
+		cfg.WsEnable, cfg.WsListenPort, cfg.WsPath, cfg.WsAllowedOrigins, cfg.WsTlsEnable, cfg.DeviceRouteEnable, cfg.DeviceTopic,
+		cfg.DevicePushTopic, cfg.DeviceRouteLease, cfg.DeviceRouteHeartbeat, cfg.DeviceRouteSyncInterval, cfg.ShadowEnable,
+		cfg.ShadowTopic, cfg.ShadowMaxSize, cfg.ShadowRefreshInterval, cfg.ShadowCacheSize, cfg.ShadowServerAddr)
+}
@@ -0,0 +1,459 @@
+package mqtt
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header/namesrv"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+)
+
+const (
+	DEVICE_ID_PLACEHOLDER = "{clientId}" // devicePushTopic中的设备ID占位符
+
+	deviceRouteBatchSize   = 10000 // 单次注册请求最多携带的设备数
+	deviceMaxHops          = 2     // 设备迁移时网关节点间最多转发的次数
+	deviceMaxReconsume     = 3     // 设备会话在其他节点在线但路由尚未注册时，最多重新消费的次数
+	deviceRouteTimeoutMill = 3000
+)
+
+var deviceGroupPattern = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// deviceRegistry 设备路由注册中心，默认为namesrv，便于测试时替换
+type deviceRegistry interface {
+	NamesrvAddrs() []string
+	Register(namesrvAddr string, requestHeader *namesrv.RegisterDeviceRouteRequestHeader, registerBody *body.DeviceRouteRegisterBody) (*namesrv.RegisterDeviceRouteResponseHeader, error)
+	UnregisterNode(namesrvAddr, nodeName string) error
+	Query(deviceIds []string) (map[string]string, error)
+}
+
+// namesrvDeviceRegistry 使用网关内嵌producer的客户端实例访问namesrv
+type namesrvDeviceRegistry struct {
+	producer *process.DefaultMQProducer
+}
+
+func (registry *namesrvDeviceRegistry) api() (*process.MQClientAPIImpl, error) {
+	return producerClientAPI(registry.producer)
+}
+
+// producerClientAPI 网关producer所在客户端实例的API，producer启动后才可用
+func producerClientAPI(producer *process.DefaultMQProducer) (*process.MQClientAPIImpl, error) {
+	factory := producer.DefaultMQProducerImpl.MQClientFactory
+	if factory == nil || factory.MQClientAPIImpl == nil {
+		return nil, fmt.Errorf("the gateway producer is not started")
+	}
+	return factory.MQClientAPIImpl, nil
+}
+
+func (registry *namesrvDeviceRegistry) NamesrvAddrs() []string {
+	api, err := registry.api()
+	if err != nil {
+		return nil
+	}
+	return api.GetNameServerAddressList()
+}
+
+func (registry *namesrvDeviceRegistry) Register(namesrvAddr string, requestHeader *namesrv.RegisterDeviceRouteRequestHeader, registerBody *body.DeviceRouteRegisterBody) (*namesrv.RegisterDeviceRouteResponseHeader, error) {
+	api, err := registry.api()
+	if err != nil {
+		return nil, err
+	}
+	return api.RegisterDeviceRoute(namesrvAddr, requestHeader, registerBody, deviceRouteTimeoutMill)
+}
+
+func (registry *namesrvDeviceRegistry) UnregisterNode(namesrvAddr, nodeName string) error {
+	api, err := registry.api()
+	if err != nil {
+		return err
+	}
+	return api.UnRegisterDeviceNode(namesrvAddr, nodeName, deviceRouteTimeoutMill)
+}
+
+func (registry *namesrvDeviceRegistry) Query(deviceIds []string) (map[string]string, error) {
+	api, err := registry.api()
+	if err != nil {
+		return nil, err
+	}
+	return api.QueryDeviceRoute(deviceIds, deviceRouteTimeoutMill)
+}
+
+// deviceRouter 点对点推送：向每个namesrv批量注册本节点的设备连接并续约，
+// 消费以本节点名称为tag的设备消息写入设备会话；设备已迁移时转发给新节点，设备不在线时写入持久会话，
+// 不在线设备的消息(DEVICE_OFFLINE_TAG)由全部网关节点以集群方式共同消费
+// Author: agent
+// Since: 2026/10/19
+type deviceRouter struct {
+	gateway         *MqttGateway
+	registry        deviceRegistry
+	nodeConsumer    gwutil.MessageConsumer
+	offlineConsumer gwutil.MessageConsumer
+
+	register      map[string]bool // 待注册的设备
+	unregister    map[string]bool // 待注销的设备
+	fullSync      map[string]bool // 需要全量同步的namesrv地址
+	lastHeartbeat time.Time
+	lock          sync.Mutex
+	offlineLock   sync.Mutex // 本节点的多个消费线程串行写入离线设备的会话
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+}
+
+func newDeviceRouter(gateway *MqttGateway, registry deviceRegistry) *deviceRouter {
+	config := gateway.config
+	router := &deviceRouter{
+		gateway:    gateway,
+		registry:   registry,
+		register:   make(map[string]bool),
+		unregister: make(map[string]bool),
+		fullSync:   make(map[string]bool),
+		stopChan:   make(chan struct{}),
+	}
+
+	nodeGroup := config.ConsumerGroup + "_DEVICE_" + deviceGroupPattern.ReplaceAllString(gateway.name, "_")
+	router.nodeConsumer = newDeviceConsumer(nodeGroup, config.NamesrvAddr, config.DeviceTopic, gateway.name, router)
+	offlineGroup := config.ConsumerGroup + "_DEVICE_OFFLINE"
+	router.offlineConsumer = newDeviceConsumer(offlineGroup, config.NamesrvAddr, config.DeviceTopic, stgcommon.DEVICE_OFFLINE_TAG, router)
+	return router
+}
+
+func newDeviceConsumer(group, namesrvAddr, topic, tag string, router *deviceRouter) gwutil.MessageConsumer {
+	pushConsumer := process.NewDefaultMQPushConsumer(group)
+	pushConsumer.SetConsumeFromWhere(heartbeat.CONSUME_FROM_LAST_OFFSET)
+	pushConsumer.SetMessageModel(heartbeat.CLUSTERING)
+	pushConsumer.SetNamesrvAddr(namesrvAddr)
+	pushConsumer.Subscribe(topic, tag)
+	pushConsumer.RegisterMessageListener(&deviceMessageListener{router: router})
+	return pushConsumer
+}
+
+// start 全量注册本节点的设备连接后开始消费设备消息
+func (router *deviceRouter) start() {
+	router.lock.Lock()
+	for _, addr := range router.registry.NamesrvAddrs() {
+		router.fullSync[addr] = true
+	}
+	router.lock.Unlock()
+	router.flush()
+
+	router.nodeConsumer.Start()
+	router.offlineConsumer.Start()
+	router.wg.Add(1)
+	go router.run()
+}
+
+// shutdown 停止消费并从全部namesrv注销本节点，设备路由立即失效
+func (router *deviceRouter) shutdown() {
+	close(router.stopChan)
+	router.wg.Wait()
+	router.nodeConsumer.Shutdown()
+	router.offlineConsumer.Shutdown()
+	for _, addr := range router.registry.NamesrvAddrs() {
+		if err := router.registry.UnregisterNode(addr, router.gateway.name); err != nil {
+			logger.Warnf("mqtt unregister device node %s from %s failed: %s", router.gateway.name, addr, err)
+		}
+	}
+}
+
+// online 设备连接成功，等待下次批量注册
+func (router *deviceRouter) online(clientId string) {
+	router.lock.Lock()
+	delete(router.unregister, clientId)
+	router.register[clientId] = true
+	router.lock.Unlock()
+}
+
+// offline 设备连接断开，等待下次批量注销；namesrv只删除仍指向本节点的路由
+func (router *deviceRouter) offline(clientId string) {
+	router.lock.Lock()
+	delete(router.register, clientId)
+	router.unregister[clientId] = true
+	router.lock.Unlock()
+}
+
+func (router *deviceRouter) run() {
+	defer router.wg.Done()
+	ticker := time.NewTicker(time.Duration(router.gateway.config.DeviceRouteSyncInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-router.stopChan:
+			return
+		case <-ticker.C:
+			router.flush()
+		}
+	}
+}
+
+// flush 向每个namesrv提交本批设备上下线；没有变化时按心跳间隔续约，namesrv要求全量同步或上次提交失败时全量同步
+// Author: agent
+// Since: 2026/10/19
+func (router *deviceRouter) flush() {
+	heartbeatInterval := time.Duration(router.gateway.config.DeviceRouteHeartbeat) * time.Second
+	router.lock.Lock()
+	register := sortedKeys(router.register)
+	unregister := sortedKeys(router.unregister)
+	router.register = make(map[string]bool)
+	router.unregister = make(map[string]bool)
+	fullSync := router.fullSync
+	router.fullSync = make(map[string]bool)
+	heartbeatDue := time.Since(router.lastHeartbeat) >= heartbeatInterval
+	router.lock.Unlock()
+
+	if len(register) == 0 && len(unregister) == 0 && len(fullSync) == 0 && !heartbeatDue {
+		return
+	}
+
+	failed := make(map[string]bool)
+	for _, addr := range router.registry.NamesrvAddrs() {
+		needFullSync := fullSync[addr]
+		if !needFullSync {
+			var err error
+			needFullSync, err = router.send(addr, false, register, unregister)
+			if err != nil {
+				logger.Warnf("mqtt register devices of node %s to %s failed: %s", router.gateway.name, addr, err)
+				failed[addr] = true
+				continue
+			}
+		}
+		if needFullSync {
+			// 全量同步取当前的连接，本批变化已包含在内
+			clientIds := router.gateway.connectedClientIds()
+			if _, err := router.send(addr, true, clientIds, nil); err != nil {
+				logger.Warnf("mqtt sync %d devices of node %s to %s failed: %s", len(clientIds), router.gateway.name, addr, err)
+				failed[addr] = true
+				continue
+			}
+			logger.Infof("mqtt sync %d devices of node %s to %s", len(clientIds), router.gateway.name, addr)
+		}
+	}
+
+	router.lock.Lock()
+	for addr := range failed {
+		router.fullSync[addr] = true
+	}
+	router.lastHeartbeat = time.Now()
+	router.lock.Unlock()
+}
+
+// send 分批提交，reset只用于第一批；返回第一批请求时namesrv是否要求全量同步
+func (router *deviceRouter) send(addr string, reset bool, register, unregister []string) (needFullSync bool, err error) {
+	leaseMillis := int64(router.gateway.config.DeviceRouteLease) * 1000
+	for first := true; first || len(register) > 0; first = false {
+		batch := register
+		if len(batch) > deviceRouteBatchSize {
+			batch = register[:deviceRouteBatchSize]
+		}
+		register = register[len(batch):]
+
+		requestHeader := &namesrv.RegisterDeviceRouteRequestHeader{NodeName: router.gateway.name, LeaseMillis: leaseMillis, Reset: reset && first}
+		registerBody := body.NewDeviceRouteRegisterBody(batch, nil)
+		if first {
+			registerBody.Unregister = unregister
+		}
+		responseHeader, err := router.registry.Register(addr, requestHeader, registerBody)
+		if err != nil {
+			return false, err
+		}
+		if first {
+			needFullSync = responseHeader.NeedFullSync
+		}
+	}
+	return needFullSync, nil
+}
+
+// consume 投递一条设备消息，mq为消息所在的队列，返回false表示稍后重新消费
+// Author: agent
+// Since: 2026/10/19
+func (router *deviceRouter) consume(msg *message.MessageExt, mq *message.MessageQueue) bool {
+	deviceId := msg.GetProperty(message.PROPERTY_DEVICE_ID)
+	if deviceId == "" {
+		logger.Warnf("mqtt device message %s without deviceId, dropped", msg.MsgId)
+		return true
+	}
+	packet := &PublishPacket{Qos: messageQos(msg), TopicName: router.pushTopic(deviceId, msg), Payload: msg.Body}
+	if session := router.gateway.connectedSession(deviceId); session != nil {
+		session.deliver(packet, mq, msg.QueueOffset)
+		return true
+	}
+
+	// 设备不在本节点：已迁移到其他节点时转发，否则写入持久会话
+	hops, _ := strconv.Atoi(msg.GetProperty(message.PROPERTY_DEVICE_HOPS))
+	routes, err := router.registry.Query([]string{deviceId})
+	if err != nil {
+		logger.Warnf("mqtt query route of device %s failed: %s", deviceId, err)
+		return false
+	}
+	if nodeName, ok := routes[deviceId]; ok && nodeName != router.gateway.name {
+		if hops < deviceMaxHops {
+			return router.forward(msg, nodeName, hops+1)
+		}
+		logger.Warnf("mqtt device message %s to %s exceeds %d hops, stop forwarding", msg.MsgId, deviceId, deviceMaxHops)
+	}
+	return router.storeOffline(deviceId, packet, msg, mq)
+}
+
+func (router *deviceRouter) pushTopic(deviceId string, msg *message.MessageExt) string {
+	if mqttTopic := msg.GetProperty(message.PROPERTY_MQTT_TOPIC); ValidTopicName(mqttTopic) {
+		return mqttTopic
+	}
+	return strings.Replace(router.gateway.config.DevicePushTopic, DEVICE_ID_PLACEHOLDER, deviceId, -1)
+}
+
+// forward 以新节点名称为tag重新发送
+func (router *deviceRouter) forward(msg *message.MessageExt, nodeName string, hops int) bool {
+	forwarded := router.copyMessage(msg, nodeName)
+	forwarded.PutProperty(message.PROPERTY_DEVICE_HOPS, strconv.Itoa(hops))
+	if _, err := router.gateway.producer.Send(forwarded); err != nil {
+		logger.Warnf("mqtt forward device message %s to node %s failed: %s", msg.MsgId, nodeName, err)
+		return false
+	}
+	logger.Infof("mqtt forward device message %s of %s to node %s", msg.MsgId, msg.GetProperty(message.PROPERTY_DEVICE_ID), nodeName)
+	return true
+}
+
+// requeueOffline 发往本节点的消息到达时设备已离线，以DEVICE_OFFLINE_TAG按设备ID选择队列重新发送，
+// 与DeviceMessageProducer发送的离线消息由同一节点写入设备的会话
+func (router *deviceRouter) requeueOffline(deviceId string, msg *message.MessageExt) bool {
+	requeued := router.copyMessage(msg, stgcommon.DEVICE_OFFLINE_TAG)
+	requeued.ClearProperty(message.PROPERTY_DEVICE_HOPS)
+	if _, err := router.gateway.producer.SendByShardingKey(requeued, deviceId); err != nil {
+		logger.Warnf("mqtt requeue message %s to offline device %s failed: %s", msg.MsgId, deviceId, err)
+		return false
+	}
+	logger.Infof("mqtt requeue message %s to offline device %s", msg.MsgId, deviceId)
+	return true
+}
+
+// copyMessage 复制设备消息并以tag重新发送到设备Topic
+func (router *deviceRouter) copyMessage(msg *message.MessageExt, tag string) *message.Message {
+	copied := &message.Message{Topic: router.gateway.config.DeviceTopic, Flag: msg.Flag, Body: msg.Body}
+	for name, value := range msg.Properties {
+		copied.PutProperty(name, value)
+	}
+	copied.SetTags(tag)
+	return copied
+}
+
+// storeOffline 设备不在线时将QoS1/QoS2消息在设备Topic中的位置追加到持久会话的排队消息中，设备重连后读取消息并下发。
+// 同一设备的离线消息按设备ID发送到同一队列，只由集群消费中持有该队列的节点写入会话，发往本节点的消息先重新发送到该队列；
+// 重复消费的消息按队列位置去重。
+//
+// 注意：QoS0消息，以及没有持久会话(以clean session连接或会话已过期)的设备的消息直接丢弃
+//
+// Author: agent
+// Since: 2026/10/19
+func (router *deviceRouter) storeOffline(deviceId string, packet *PublishPacket, msg *message.MessageExt, mq *message.MessageQueue) bool {
+	router.offlineLock.Lock()
+	defer router.offlineLock.Unlock()
+
+	gateway := router.gateway
+	now := timeutil.CurrentTimeMillis()
+	state, ok := gateway.store.Get(deviceId)
+	if !ok || state.Deleted || state.Expired(now, gateway.expiryMillis(), gateway.staleMillis()) || packet.Qos == 0 {
+		logger.Infof("mqtt device %s is offline without persistent session, drop message %s", deviceId, msg.MsgId)
+		return true
+	}
+	if state.Connected && now-state.UpdateTime <= gateway.staleMillis() {
+		// 会话在其他节点在线，但设备路由尚未注册
+		if msg.ReconsumeTimes < deviceMaxReconsume {
+			return false
+		}
+		logger.Warnf("mqtt device %s is online without route, drop message %s", deviceId, msg.MsgId)
+		return true
+	}
+
+	if msg.GetTags() != stgcommon.DEVICE_OFFLINE_TAG {
+		return router.requeueOffline(deviceId, msg)
+	}
+
+	inflight := &InflightState{Qos: packet.Qos, Topic: packet.TopicName, Source: messageSource(mq, msg.QueueOffset)}
+	if inflight.Source == nil {
+		inflight.Payload = packet.Payload
+	}
+	for _, queued := range state.Inflight {
+		if inflight.Source != nil && queued.Source != nil && *queued.Source == *inflight.Source {
+			logger.Infof("mqtt message %s already queued to offline device %s", msg.MsgId, deviceId)
+			return true
+		}
+	}
+
+	queued := *state
+	queued.Owner = gateway.name
+	queued.Version = state.Version + 1
+	queued.UpdateTime = now
+	queued.Inflight = append(make([]*InflightState, 0, len(state.Inflight)+1), state.Inflight...)
+	queued.Inflight = append(queued.Inflight, inflight)
+	queued.Inflight = trimQueued(queued.Inflight, gateway.config.MaxQueuedMessages)
+	if err := gateway.store.Save(&queued); err != nil {
+		logger.Warnf("mqtt queue message %s to offline device %s failed: %s", msg.MsgId, deviceId, err)
+		return false
+	}
+	logger.Infof("mqtt queue message %s to offline device %s", msg.MsgId, deviceId)
+	return true
+}
+
+// trimQueued 排队中的消息(PacketId为0)超过上限时丢弃最早的消息，已下发未确认的消息保留
+func trimQueued(inflight []*InflightState, maxQueued int) []*InflightState {
+	queued := 0
+	for _, state := range inflight {
+		if state.PacketId == 0 {
+			queued++
+		}
+	}
+	if queued <= maxQueued {
+		return inflight
+	}
+
+	drop := queued - maxQueued
+	trimmed := make([]*InflightState, 0, len(inflight)-drop)
+	for _, state := range inflight {
+		if state.PacketId == 0 && drop > 0 {
+			drop--
+			continue
+		}
+		trimmed = append(trimmed, state)
+	}
+	return trimmed
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deviceMessageListener 设备消息的监听，任一消息需要重新消费时整批重新消费
+type deviceMessageListener struct {
+	router *deviceRouter
+}
+
+func (l *deviceMessageListener) ConsumeMessage(msgs []*message.MessageExt, context *consumer.ConsumeConcurrentlyContext) listener.ConsumeConcurrentlyStatus {
+	var mq *message.MessageQueue
+	if context != nil {
+		mq = context.MessageQueue
+	}
+	status := listener.CONSUME_SUCCESS
+	for _, msg := range msgs {
+		if !l.router.consume(msg, mq) {
+			status = listener.RECONSUME_LATER
+		}
+	}
+	return status
+}
@@ -0,0 +1,244 @@
+package mqtt
+
+import (
+	"sync"
+	"testing"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header/namesrv"
+)
+
+// fakeDeviceRegistry 内存中的单个namesrv设备路由，语义与DeviceRouteManager一致
+type fakeDeviceRegistry struct {
+	nodes  map[string]bool
+	routes map[string]string
+	lock   sync.Mutex
+}
+
+func newFakeDeviceRegistry() *fakeDeviceRegistry {
+	return &fakeDeviceRegistry{nodes: make(map[string]bool), routes: make(map[string]string)}
+}
+
+func (r *fakeDeviceRegistry) NamesrvAddrs() []string {
+	return []string{"fake-namesrv"}
+}
+
+func (r *fakeDeviceRegistry) Register(namesrvAddr string, requestHeader *namesrv.RegisterDeviceRouteRequestHeader, registerBody *body.DeviceRouteRegisterBody) (*namesrv.RegisterDeviceRouteResponseHeader, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	nodeName := requestHeader.NodeName
+	responseHeader := &namesrv.RegisterDeviceRouteResponseHeader{NeedFullSync: !r.nodes[nodeName] && !requestHeader.Reset}
+	if requestHeader.Reset {
+		r.removeNode(nodeName)
+	}
+	r.nodes[nodeName] = true
+	for _, deviceId := range registerBody.Unregister {
+		if r.routes[deviceId] == nodeName {
+			delete(r.routes, deviceId)
+		}
+	}
+	for _, deviceId := range registerBody.Register {
+		r.routes[deviceId] = nodeName
+	}
+	return responseHeader, nil
+}
+
+func (r *fakeDeviceRegistry) UnregisterNode(namesrvAddr, nodeName string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.removeNode(nodeName)
+	delete(r.nodes, nodeName)
+	return nil
+}
+
+func (r *fakeDeviceRegistry) Query(deviceIds []string) (map[string]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	routes := make(map[string]string)
+	for _, deviceId := range deviceIds {
+		if nodeName, ok := r.routes[deviceId]; ok {
+			routes[deviceId] = nodeName
+		}
+	}
+	return routes, nil
+}
+
+func (r *fakeDeviceRegistry) removeNode(nodeName string) {
+	for deviceId, node := range r.routes {
+		if node == nodeName {
+			delete(r.routes, deviceId)
+		}
+	}
+}
+
+// restart 模拟namesrv重启，丢失全部设备路由
+func (r *fakeDeviceRegistry) restart() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.nodes = make(map[string]bool)
+	r.routes = make(map[string]string)
+}
+
+func (r *fakeDeviceRegistry) route(deviceId string) string {
+	routes, _ := r.Query([]string{deviceId})
+	return routes[deviceId]
+}
+
+func newDeviceGatewayConfig() *GatewayConfig {
+	cfg := NewGatewayConfig()
+	cfg.DeviceRouteEnable = true
+	cfg.DeviceRouteSyncInterval = 20
+	cfg.DeviceRouteHeartbeat = 1
+	return cfg
+}
+
+// sendToDevice 与DeviceMessageProducer.SendToDevice一致：按路由设置tag，不在线时使用DEVICE_OFFLINE_TAG
+func sendToDevice(t *testing.T, broker *fakeBroker, deviceId, tag string, payload []byte) {
+	if tag == "" {
+		if tag = broker.devices.route(deviceId); tag == "" {
+			tag = stgcommon.DEVICE_OFFLINE_TAG
+		}
+	}
+	msg := message.NewMessage(stgcommon.DEVICE_MESSAGE_TOPIC, tag, payload)
+	msg.SetKeys(deviceId)
+	msg.PutProperty(message.PROPERTY_DEVICE_ID, deviceId)
+	if _, err := broker.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceRoutePush(t *testing.T) {
+	broker := newFakeBroker()
+	gatewayA, addrA := startTestGateway(t, newDeviceGatewayConfig(), broker)
+	defer gatewayA.Shutdown()
+	gatewayB, addrB := startTestGateway(t, newDeviceGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+
+	device := dialTestClient(t, addrB, "device-1", 60)
+	waitFor(t, "device-1 registered on gateway B", func() bool {
+		return broker.devices.route("device-1") == gatewayB.Name()
+	})
+	sendToDevice(t, broker, "device-1", "", []byte("reboot"))
+	if p := receive(t, device); p.TopicName != "devices/device-1/push" || string(p.Payload) != "reboot" || p.Qos != 1 {
+		t.Fatalf("unexpected pushed message %+v", p)
+	}
+
+	// 设备迁移到A节点，按旧路由发往B节点的消息由B节点转发
+	device.Disconnect()
+	device = dialTestClient(t, addrA, "device-1", 60)
+	defer device.Disconnect()
+	waitFor(t, "device-1 moved to gateway A", func() bool {
+		return broker.devices.route("device-1") == gatewayA.Name()
+	})
+	sendToDevice(t, broker, "device-1", gatewayB.Name(), []byte("moved"))
+	if p := receive(t, device); string(p.Payload) != "moved" {
+		t.Fatalf("unexpected pushed message %+v", p)
+	}
+	if forwarded := broker.lastSent(); forwarded.GetTags() != gatewayA.Name() || forwarded.GetProperty(message.PROPERTY_DEVICE_HOPS) != "1" {
+		t.Fatalf("unexpected forwarded message %+v", forwarded)
+	}
+
+	// namesrv重启后节点续约时全量同步
+	broker.devices.restart()
+	waitFor(t, "device-1 synced after namesrv restart", func() bool {
+		return broker.devices.route("device-1") == gatewayA.Name()
+	})
+
+	// 节点关闭时注销全部设备路由
+	gatewayA.Shutdown()
+	if route := broker.devices.route("device-1"); route != "" {
+		t.Fatalf("device route should be removed after shutdown, got %s", route)
+	}
+}
+
+func TestDeviceRouteOfflineQueue(t *testing.T) {
+	broker := newFakeBroker()
+	cfg := newDeviceGatewayConfig()
+	cfg.MaxQueuedMessages = 2
+	gatewayA, addrA := startTestGateway(t, cfg, broker)
+	defer gatewayA.Shutdown()
+	gatewayB, addrB := startTestGateway(t, newDeviceGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+
+	device := dialPersistentClient(t, addrA, "device-2")
+	waitFor(t, "device-2 registered", func() bool {
+		return broker.devices.route("device-2") == gatewayA.Name()
+	})
+	device.Disconnect()
+	waitOffline(t, gatewayA, "device-2")
+	waitFor(t, "device-2 unregistered", func() bool {
+		return broker.devices.route("device-2") == ""
+	})
+
+	// 不在线时将消息位置写入持久会话，超过排队上限时丢弃最早的消息；按旧路由发往A节点的消息重新发送到离线队列
+	for i, payload := range []string{"cmd-1", "cmd-2", "cmd-3"} {
+		tag := ""
+		if i == 2 {
+			tag = gatewayA.Name()
+		}
+		sendToDevice(t, broker, "device-2", tag, []byte(payload))
+		waitFor(t, payload+" queued", func() bool {
+			state, ok := gatewayB.store.Get("device-2")
+			if !ok || len(state.Inflight) == 0 {
+				return false
+			}
+			last := state.Inflight[len(state.Inflight)-1]
+			msg := broker.messageAt(last.Source)
+			return last.Topic == "devices/device-2/push" && last.Payload == nil && msg != nil &&
+				string(msg.Body) == payload && msg.GetTags() == stgcommon.DEVICE_OFFLINE_TAG
+		})
+	}
+	state, _ := gatewayB.store.Get("device-2")
+	if len(state.Inflight) != 2 {
+		t.Fatalf("expect 2 queued messages, got %d", len(state.Inflight))
+	}
+
+	// 重复消费的消息不重复排队
+	last := state.Inflight[len(state.Inflight)-1]
+	if !gatewayA.router.consume(broker.messageAt(last.Source), last.Source.MessageQueue()) {
+		t.Fatal("consume redelivered message failed")
+	}
+	if state, _ := gatewayA.store.Get("device-2"); len(state.Inflight) != 2 {
+		t.Fatalf("redelivered message queued twice: %d", len(state.Inflight))
+	}
+
+	// 在其他节点重连后下发排队的消息
+	device = dialPersistentClient(t, addrB, "device-2")
+	defer device.Disconnect()
+	for _, expect := range []string{"cmd-2", "cmd-3"} {
+		if p := receive(t, device); string(p.Payload) != expect {
+			t.Fatalf("expect %s, got %+v", expect, p)
+		}
+	}
+
+	// 没有持久会话的设备不在线时消息丢弃
+	sendToDevice(t, broker, "device-3", "", []byte("dropped"))
+	if _, ok := gatewayA.store.Get("device-3"); ok {
+		t.Fatal("no session should be created for unknown device")
+	}
+}
+
+func TestDeviceRouteConfig(t *testing.T) {
+	cfg := newDeviceGatewayConfig()
+	cfg.DevicePushTopic = "devices/+/push"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("wildcard devicePushTopic should be rejected")
+	}
+	cfg = newDeviceGatewayConfig()
+	cfg.GatewayName = stgcommon.DEVICE_OFFLINE_TAG
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("offline tag as gatewayName should be rejected")
+	}
+	cfg = newDeviceGatewayConfig()
+	cfg.DeviceRouteHeartbeat = cfg.DeviceRouteLease
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("heartbeat not less than lease should be rejected")
+	}
+
+	inflight := []*InflightState{{PacketId: 1}, {Topic: "a"}, {PacketId: 2}, {Topic: "b"}, {Topic: "c"}}
+	if trimmed := trimQueued(inflight, 1); len(trimmed) != 3 || trimmed[0].PacketId != 1 || trimmed[1].PacketId != 2 || trimmed[2].Topic != "c" {
+		t.Fatalf("unexpected trimmed %+v", trimmed)
+	}
+}
@@ -0,0 +1,114 @@
+package mqtt
+
+import (
+	"sync"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// queueProgress 单个队列的分发进度
+type queueProgress struct {
+	mq      *message.MessageQueue
+	next    int64           // 已分发消息的最大offset+1
+	pending map[int64]int32 // 正在分发的offset
+}
+
+// dispatchProgress 记录网关consumer在各队列上的分发进度；
+// 持久会话断开时以此作为补发起点，consumer并发消费时取正在分发的最小offset，宁可重复不丢消息
+// Author: agent
+// Since: 2026/10/19
+type dispatchProgress struct {
+	queues map[string]*queueProgress
+	lock   sync.Mutex
+}
+
+func newDispatchProgress() *dispatchProgress {
+	return &dispatchProgress{queues: make(map[string]*queueProgress)}
+}
+
+func (progress *dispatchProgress) queue(mq *message.MessageQueue, offset int64) *queueProgress {
+	key := queueKey(mq)
+	queue, ok := progress.queues[key]
+	if !ok {
+		queue = &queueProgress{
+			mq:      &message.MessageQueue{Topic: mq.Topic, BrokerName: mq.BrokerName, QueueId: mq.QueueId},
+			next:    offset,
+			pending: make(map[int64]int32),
+		}
+		progress.queues[key] = queue
+	}
+	return queue
+}
+
+// known 队列是否已有分发进度
+func (progress *dispatchProgress) known(mq *message.MessageQueue) bool {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	_, ok := progress.queues[queueKey(mq)]
+	return ok
+}
+
+// init 以队列当前最大offset初始化尚无进度的队列
+func (progress *dispatchProgress) init(mq *message.MessageQueue, offset int64) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	progress.queue(mq, offset)
+}
+
+// begin 消息开始分发
+func (progress *dispatchProgress) begin(mq *message.MessageQueue, offset int64) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	queue := progress.queue(mq, offset)
+	queue.pending[offset]++
+}
+
+// end 消息分发完成
+func (progress *dispatchProgress) end(mq *message.MessageQueue, offset int64) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	queue := progress.queue(mq, offset)
+	if queue.pending[offset]--; queue.pending[offset] <= 0 {
+		delete(queue.pending, offset)
+	}
+	if offset+1 > queue.next {
+		queue.next = offset + 1
+	}
+}
+
+// offset 队列上已完整分发的位置
+func (progress *dispatchProgress) offset(mq *message.MessageQueue) (int64, bool) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	queue, ok := progress.queues[queueKey(mq)]
+	if !ok {
+		return 0, false
+	}
+	return queue.safeOffset(), true
+}
+
+// snapshot 全部队列已完整分发的位置
+func (progress *dispatchProgress) snapshot() []*QueueOffset {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	offsets := make([]*QueueOffset, 0, len(progress.queues))
+	for _, queue := range progress.queues {
+		offsets = append(offsets, &QueueOffset{
+			Topic:      queue.mq.Topic,
+			BrokerName: queue.mq.BrokerName,
+			QueueId:    queue.mq.QueueId,
+			Offset:     queue.safeOffset(),
+		})
+	}
+	return offsets
+}
+
+func (queue *queueProgress) safeOffset() int64 {
+	offset := queue.next
+	for pending := range queue.pending {
+		if pending < offset {
+			offset = pending
+		}
+	}
+	return offset
+}
@@ -0,0 +1,1126 @@
+package mqtt
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+)
+
+// messageProducer 网关内嵌的producer，在各网关共用的接口上增加按shardingKey发送及request、应答，便于测试时替换
+type messageProducer interface {
+	gwutil.MessageProducer
+	SendByShardingKey(msg *message.Message, shardingKey string) (*process.SendResult, error)
+	SendOneWay(msg *message.Message) error
+	RequestByShardingKey(msg *message.Message, shardingKey string, timeout int64) (*message.MessageExt, error)
+	SendReply(requestMsg *message.MessageExt, body []byte, timeout int64) error
+}
+
+// MqttGateway MQTT 3.1.1网关：设备发布的消息按映射规则写入smartgo topic，
+// 同时以广播模式消费映射的topic，再按MQTT订阅分发给本网关上的会话；
+// 持久会话的快照保存在会话存储中，可由任一网关节点恢复，离线期间的消息按记录的队列位置从smartgo补发；
+// 保留消息保存在保留消息存储中，各节点共享；启用WebSocket时浏览器及移动端的连接与TCP连接由同一bootstrap管理；
+// 启用设备路由时向namesrv注册本节点的设备连接，接收点对点推送给设备的消息；
+// 启用设备影子时处理$shadow/保留主题的请求，并提供影子HTTP服务
+// Author: agent
+// Since: 2026/10/19
+type MqttGateway struct {
+	config        *GatewayConfig
+	name          string // 网关节点名称，写入会话快照的Owner
+	mapper        *TopicMapper
+	bootstrap     *netm.Bootstrap
+	producer      messageProducer
+	consumer      gwutil.MessageConsumer
+	reader        messageReader
+	store         SessionStore
+	retain        RetainStore
+	progress      *dispatchProgress
+	adminServer   *GatewayAdminServer
+	wsServer      *WebSocketServer
+	router        *deviceRouter  // 点对点推送，未启用时为nil
+	shadow        *shadowService // 设备影子，未启用时为nil
+	shadowServer  *ShadowServer
+	subscriptions *subscriptionTree
+	sessions      map[string]*Session // 连接地址 -> 会话
+	clients       map[string]*Session // clientId -> 已连接的会话
+	lock          sync.RWMutex
+	clientIdSeq   int64
+	stopChan      chan struct{}
+	stopOnce      sync.Once
+}
+
+// NewMqttGateway 创建MQTT网关
+// Author: agent
+// Since: 2026/10/19
+func NewMqttGateway(config *GatewayConfig) (*MqttGateway, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	mapper, err := NewTopicMapper(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := &MqttGateway{
+		config:        config,
+		name:          config.GatewayName,
+		mapper:        mapper,
+		progress:      newDispatchProgress(),
+		subscriptions: newSubscriptionTree(),
+		sessions:      make(map[string]*Session),
+		clients:       make(map[string]*Session),
+		stopChan:      make(chan struct{}),
+	}
+	if gateway.name == "" {
+		hostname, _ := os.Hostname()
+		gateway.name = net.JoinHostPort(hostname, strconv.Itoa(config.ListenPort))
+	}
+
+	producer := process.NewDefaultMQProducer(config.ProducerGroup)
+	producer.SetNamesrvAddr(config.NamesrvAddr)
+	gateway.producer = producer
+
+	pushConsumer := process.NewDefaultMQPushConsumer(config.ConsumerGroup)
+	pushConsumer.SetConsumeFromWhere(heartbeat.CONSUME_FROM_LAST_OFFSET)
+	pushConsumer.SetMessageModel(heartbeat.BROADCASTING)
+	pushConsumer.SetNamesrvAddr(config.NamesrvAddr)
+	for topic, expression := range mapper.SubscribeExpressions() {
+		pushConsumer.Subscribe(topic, expression)
+	}
+	pushConsumer.RegisterMessageListener(&gatewayMessageListener{gateway: gateway})
+	gateway.consumer = pushConsumer
+
+	gateway.reader = newPullMessageReader(config.ConsumerGroup+"_READER", config.NamesrvAddr)
+	if config.SessionStore == SESSION_STORE_MEMORY {
+		gateway.store = NewMemorySessionStore()
+	} else {
+		syncInterval := time.Duration(config.SessionSyncInterval) * time.Millisecond
+		gateway.store = newTopicSessionStore(config.SessionTopic, producer, gateway.reader, syncInterval)
+	}
+	if config.RetainStore == SESSION_STORE_MEMORY {
+		gateway.retain = NewMemoryRetainStore()
+	} else {
+		syncInterval := time.Duration(config.SessionSyncInterval) * time.Millisecond
+		gateway.retain = newTopicRetainStore(config.RetainTopic, producer, gateway.reader, syncInterval)
+	}
+	if config.DeviceRouteEnable {
+		gateway.router = newDeviceRouter(gateway, &namesrvDeviceRegistry{producer: producer})
+	}
+	if config.ShadowEnable {
+		gateway.shadow = newShadowService(gateway, &producerMessageQuerier{producer: producer})
+		if config.ShadowServerAddr != "" {
+			gateway.shadowServer = NewShadowServer(gateway, config.ShadowServerAddr)
+		}
+	}
+	if config.AdminServerEnable {
+		gateway.adminServer = NewGatewayAdminServer(gateway, config.AdminServerAddr)
+	}
+
+	gateway.bootstrap = netm.NewBootstrap().Bind(config.ListenHost, config.ListenPort).
+		RegisterHandler(gateway.handleData).RegisterContextListener(gateway).SetKeepAlive(true)
+	if config.WsEnable {
+		wsAddr := net.JoinHostPort(config.ListenHost, strconv.Itoa(config.WsListenPort))
+		gateway.wsServer = NewWebSocketServer(gateway.bootstrap, wsAddr, config.WsPath, config.WsAllowedOrigins, config.WsTLSOptions())
+	}
+	return gateway, nil
+}
+
+// Start 启动producer、会话存储、consumer及MQTT监听(TCP、WebSocket)
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) Start() error {
+	gateway.producer.Start()
+	gateway.reader.Start()
+	gateway.store.AddListener(gateway.onSessionUpdate)
+	if err := gateway.store.Start(); err != nil {
+		return err
+	}
+	if err := gateway.retain.Start(); err != nil {
+		return err
+	}
+	gateway.initProgress()
+	gateway.consumer.Start()
+	if gateway.router != nil {
+		gateway.router.start()
+	}
+	if gateway.shadow != nil {
+		gateway.shadow.start()
+	}
+	if gateway.shadowServer != nil {
+		if err := gateway.shadowServer.Start(); err != nil {
+			return err
+		}
+	}
+	if gateway.adminServer != nil {
+		if err := gateway.adminServer.Start(); err != nil {
+			return err
+		}
+	}
+
+	if gateway.config.IdleTimeout > 0 {
+		gateway.bootstrap.SetIdle(gateway.config.IdleTimeout)
+	}
+	// 先启动WebSocket监听，建立TCP连接时可据其监听端口区分接入方式
+	if gateway.wsServer != nil {
+		if err := gateway.wsServer.Start(); err != nil {
+			return err
+		}
+	}
+	go gateway.bootstrap.Sync()
+	go gateway.scanSessions()
+	go gateway.maintainSessions()
+	logger.Infof("mqtt gateway %s start success. %s", gateway.name, gateway.config)
+	return nil
+}
+
+// Shutdown 关闭监听及全部连接(持久会话写入断开快照)，再关闭consumer、producer
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) Shutdown() {
+	gateway.stopOnce.Do(func() {
+		close(gateway.stopChan)
+		if gateway.adminServer != nil {
+			gateway.adminServer.Shutdown()
+		}
+		if gateway.shadowServer != nil {
+			gateway.shadowServer.Shutdown()
+		}
+		if gateway.wsServer != nil {
+			gateway.wsServer.Shutdown()
+		}
+		gateway.bootstrap.Shutdown()
+		if gateway.router != nil {
+			gateway.router.shutdown()
+		}
+		if gateway.shadow != nil {
+			gateway.shadow.shutdown()
+		}
+		gateway.store.Shutdown()
+		gateway.retain.Shutdown()
+		gateway.consumer.Shutdown()
+		gateway.reader.Shutdown()
+		gateway.producer.Shutdown()
+		logger.Infof("mqtt gateway %s shutdown success", gateway.name)
+	})
+}
+
+// Name 网关节点名称
+func (gateway *MqttGateway) Name() string {
+	return gateway.name
+}
+
+// SessionCount 当前连接数
+func (gateway *MqttGateway) SessionCount() int {
+	gateway.lock.RLock()
+	defer gateway.lock.RUnlock()
+	return len(gateway.sessions)
+}
+
+// ConnectionCount bootstrap管理的连接数，包括TCP及WebSocket连接
+func (gateway *MqttGateway) ConnectionCount() int {
+	return gateway.bootstrap.Size()
+}
+
+// WebSocketAddr WebSocket实际监听地址，未启用时为空
+func (gateway *MqttGateway) WebSocketAddr() string {
+	if gateway.wsServer == nil {
+		return ""
+	}
+	return gateway.wsServer.Addr()
+}
+
+// transportOf 按连接的本地端口区分WebSocket与TCP连接
+func (gateway *MqttGateway) transportOf(ctx netm.Context) string {
+	if gateway.wsServer == nil || gateway.wsServer.listener == nil {
+		return TRANSPORT_TCP
+	}
+	local, ok := ctx.LocalAddr().(*net.TCPAddr)
+	listen, _ := gateway.wsServer.listener.Addr().(*net.TCPAddr)
+	if ok && listen != nil && local.Port == listen.Port {
+		return TRANSPORT_WEBSOCKET
+	}
+	return TRANSPORT_TCP
+}
+
+// handleData 同一连接的数据由同一协程按序回调，buffer在回调返回后会被复用
+func (gateway *MqttGateway) handleData(buffer []byte, ctx netm.Context) {
+	if ctx.IsClosed() {
+		return
+	}
+	session := gateway.sessionOf(ctx)
+	session.buffer = append(session.buffer, buffer...)
+
+	for len(session.buffer) > 0 {
+		packet, n, err := DecodePacket(session.buffer, gateway.config.MaxPacketSize)
+		if err != nil {
+			logger.Warnf("mqtt decode packet from %s failed, close connection: %s", ctx.Addr(), err)
+			session.close()
+			return
+		}
+		if packet == nil {
+			break
+		}
+		session.buffer = session.buffer[n:]
+		session.touch()
+		if !gateway.handlePacket(session, packet) {
+			session.close()
+			return
+		}
+	}
+
+	// 报文处理完后释放缓冲区，避免大量空闲连接占用内存
+	if len(session.buffer) == 0 {
+		session.buffer = nil
+	}
+}
+
+// handlePacket 处理单个报文，返回false表示需要关闭连接
+func (gateway *MqttGateway) handlePacket(session *Session, packet Packet) bool {
+	if packet.Type() != CONNECT && !session.isConnected() {
+		logger.Warnf("mqtt %s received before CONNECT from %s", PacketName(packet.Type()), session.Addr())
+		return false
+	}
+
+	switch p := packet.(type) {
+	case *ConnectPacket:
+		if !gateway.handleConnect(session, p) {
+			return false
+		}
+		if gateway.router != nil {
+			gateway.router.online(session.ClientId())
+		}
+		if gateway.shadow != nil {
+			gateway.shadow.onConnect(session.ClientId())
+		}
+		return true
+	case *PublishPacket:
+		return gateway.handlePublish(session, p)
+	case *PubackPacket:
+		session.onPuback(p.PacketId)
+		return true
+	case *PubrecPacket:
+		session.onPubrec(p.PacketId)
+		return true
+	case *PubrelPacket:
+		session.onPubrel(p.PacketId)
+		return true
+	case *PubcompPacket:
+		session.onPubcomp(p.PacketId)
+		return true
+	case *SubscribePacket:
+		gateway.handleSubscribe(session, p)
+		return true
+	case *UnsubscribePacket:
+		gateway.handleUnsubscribe(session, p)
+		return true
+	case *PingreqPacket:
+		session.write(&PingrespPacket{})
+		return true
+	case *DisconnectPacket:
+		session.clearWill()
+		return false
+	default:
+		logger.Warnf("mqtt unexpected %s from %s", PacketName(packet.Type()), session.Addr())
+		return false
+	}
+}
+
+// handleConnect 建立会话：clean session时清除已保存的会话，否则恢复本节点或会话存储中的会话
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) handleConnect(session *Session, p *ConnectPacket) bool {
+	if session.isConnected() {
+		logger.Warnf("mqtt duplicate CONNECT from %s", session.Addr())
+		return false
+	}
+	if p.ProtocolName != PROTOCOL_NAME {
+		logger.Warnf("mqtt unsupported protocol name %s from %s", p.ProtocolName, session.Addr())
+		return false
+	}
+	if p.ProtocolLevel != PROTOCOL_LEVEL {
+		session.write(&ConnackPacket{ReturnCode: CONNACK_UNACCEPTABLE_PROTOCOL_VERSION})
+		return false
+	}
+	if p.WillFlag && (p.WillQos > 2 || !ValidTopicName(p.WillTopic)) || !p.WillFlag && (p.WillQos != 0 || p.WillRetain) {
+		logger.Warnf("mqtt invalid will from %s, willTopic=%s, willQos=%d", session.Addr(), p.WillTopic, p.WillQos)
+		return false
+	}
+
+	clientId := p.ClientId
+	if clientId == "" {
+		if !p.CleanSession {
+			session.write(&ConnackPacket{ReturnCode: CONNACK_IDENTIFIER_REJECTED})
+			return false
+		}
+		clientId = fmt.Sprintf("smartgo-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&gateway.clientIdSeq, 1))
+	}
+
+	// 相同clientId的旧连接被新连接接管，旧的持久会话直接取内存中的最新状态
+	gateway.lock.Lock()
+	old := gateway.clients[clientId]
+	gateway.clients[clientId] = session
+	gateway.lock.Unlock()
+
+	var resume *SessionState
+	if old != nil && old != session {
+		logger.Infof("mqtt client %s reconnect from %s, close old connection %s", clientId, session.Addr(), old.Addr())
+		if old.isPersistent() {
+			resume, _ = old.snapshot(gateway.name, false, gateway.sessionOffsets(old))
+		}
+		old.takeOver()
+	}
+
+	stored, exist := gateway.store.Get(clientId)
+	session.lock.Lock()
+	session.clientId = clientId
+	session.keepAlive = time.Duration(p.KeepAlive) * time.Second
+	session.persistent = !p.CleanSession
+	if p.WillFlag {
+		session.will = &PublishPacket{Qos: p.WillQos, Retain: p.WillRetain, TopicName: p.WillTopic, Payload: p.WillMessage}
+	}
+	session.lock.Unlock()
+
+	if p.CleanSession {
+		if exist && !stored.Deleted {
+			if err := gateway.store.Save(newTombstone(stored, gateway.name)); err != nil {
+				logger.Errorf("mqtt discard session %s failed: %s", clientId, err)
+			}
+		}
+		session.lock.Lock()
+		session.connected = true
+		session.lock.Unlock()
+		session.write(&ConnackPacket{ReturnCode: CONNACK_ACCEPTED})
+		logger.Infof("mqtt client %s connected from %s, keepAlive=%ds", clientId, session.Addr(), p.KeepAlive)
+		return true
+	}
+
+	if exist && !stored.Deleted && !stored.Expired(timeutil.CurrentTimeMillis(), gateway.expiryMillis(), gateway.staleMillis()) && stored.NewerThan(resume) {
+		resume = gateway.loadPayloads(stored)
+	}
+	if resume != nil {
+		session.restore(resume)
+	} else if exist {
+		// 继承已删除或已过期快照的版本号，保证新快照覆盖旧快照
+		session.saved(stored, 0)
+	}
+
+	state, changes := session.snapshot(gateway.name, true, gateway.sessionOffsets(session))
+	if err := gateway.store.Save(state); err != nil {
+		logger.Errorf("mqtt save session %s failed: %s", clientId, err)
+		session.write(&ConnackPacket{ReturnCode: CONNACK_SERVER_UNAVAILABLE})
+		return false
+	}
+	session.saved(state, changes)
+
+	session.lock.Lock()
+	session.connected = true
+	subscriptions := make(map[string]byte, len(session.subscriptions))
+	for filter, qos := range session.subscriptions {
+		subscriptions[filter] = qos
+	}
+	session.lock.Unlock()
+	for filter, qos := range subscriptions {
+		gateway.subscriptions.Subscribe(filter, session, qos)
+	}
+
+	session.write(&ConnackPacket{SessionPresent: resume != nil, ReturnCode: CONNACK_ACCEPTED})
+	logger.Infof("mqtt client %s connected from %s, keepAlive=%ds, persistent session present=%t", clientId, session.Addr(), p.KeepAlive, resume != nil)
+
+	if resume != nil {
+		session.resendInflight()
+		session.drainQueue()
+		go gateway.replayOffline(session, resume.Offsets)
+	}
+	return true
+}
+
+func (gateway *MqttGateway) handlePublish(session *Session, p *PublishPacket) bool {
+	if !ValidTopicName(p.TopicName) {
+		logger.Warnf("mqtt client %s publish to invalid topic %s", session.ClientId(), p.TopicName)
+		return false
+	}
+
+	// QoS2重复报文已写入smartgo，只需再次回复PUBREC
+	if p.Qos == 2 && !session.isNewQos2(p.PacketId) {
+		session.write(&PubrecPacket{PacketId: p.PacketId})
+		return true
+	}
+
+	// 影子请求由网关处理，不写入映射的topic；处理结果以应答主题返回，请求本身总是确认
+	if gateway.shadow != nil && strings.HasPrefix(p.TopicName, SHADOW_TOPIC_PREFIX) {
+		gateway.shadow.handleRequest(session, p)
+		gateway.acknowledgePublish(session, p)
+		return true
+	}
+
+	// QoS1/QoS2在消息写入broker后才确认，写入失败时不确认并关闭连接，由客户端重连后重发；QoS0直接丢弃
+	if err := gateway.publish(session.ClientId(), p); err != nil {
+		logger.Errorf("mqtt client %s publish to %s failed: %s", session.ClientId(), p.TopicName, err)
+		return p.Qos == 0
+	}
+	gateway.acknowledgePublish(session, p)
+	return true
+}
+
+// publish 保存保留消息，并按映射规则写入smartgo，QoS0以oneway方式发送；客户端发布的消息及遗嘱消息均由此写入
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) publish(clientId string, p *PublishPacket) error {
+	if p.Retain {
+		retained := &RetainedMessage{Topic: p.TopicName, Qos: p.Qos, Payload: p.Payload, Owner: gateway.name, UpdateTime: timeutil.CurrentTimeMillis()}
+		if err := gateway.retain.Save(retained); err != nil {
+			return fmt.Errorf("save retained message failed: %s", err)
+		}
+		// 空消息只用于清除保留消息，smartgo不接受空消息体
+		if len(p.Payload) == 0 {
+			return nil
+		}
+	}
+
+	rule, ok := gateway.mapper.Match(p.TopicName)
+	if !ok {
+		logger.Warnf("mqtt client %s publish to topic %s without mapping rule, message dropped", clientId, p.TopicName)
+		return nil
+	}
+
+	msg := message.NewMessage(rule.Topic, rule.Tags, p.Payload)
+	if rule.Tags == "" {
+		msg.ClearProperty(message.PROPERTY_TAGS)
+	}
+	msg.PutProperty(message.PROPERTY_MQTT_TOPIC, p.TopicName)
+	msg.PutProperty(message.PROPERTY_MQTT_CLIENT_ID, clientId)
+	msg.PutProperty(message.PROPERTY_MQTT_QOS, strconv.Itoa(int(p.Qos)))
+	if p.Retain {
+		msg.PutProperty(message.PROPERTY_MQTT_RETAIN, "true")
+	}
+
+	if p.Qos == 0 {
+		return gateway.producer.SendOneWay(msg)
+	}
+	_, err := gateway.producer.Send(msg)
+	return err
+}
+
+// acknowledgePublish QoS1回复PUBACK，QoS2记录报文标识后回复PUBREC
+func (gateway *MqttGateway) acknowledgePublish(session *Session, p *PublishPacket) {
+	switch p.Qos {
+	case 1:
+		session.write(&PubackPacket{PacketId: p.PacketId})
+	case 2:
+		session.markReceived(p.PacketId)
+		session.write(&PubrecPacket{PacketId: p.PacketId})
+	}
+}
+
+// handleSubscribe 订阅成功后下发匹配的保留消息，同一主题匹配多个过滤器时只下发一次，QoS取最大值
+func (gateway *MqttGateway) handleSubscribe(session *Session, p *SubscribePacket) {
+	returnCodes := make([]byte, len(p.Subscriptions))
+	retained := make(map[string]*PublishPacket)
+	for i, sub := range p.Subscriptions {
+		if !ValidTopicFilter(sub.Filter) {
+			returnCodes[i] = SUBACK_FAILURE
+			continue
+		}
+		session.addSubscription(sub.Filter, sub.Qos)
+		gateway.subscriptions.Subscribe(sub.Filter, session, sub.Qos)
+		returnCodes[i] = sub.Qos
+
+		for _, msg := range gateway.retain.Match(sub.Filter) {
+			qos := msg.Qos
+			if sub.Qos < qos {
+				qos = sub.Qos
+			}
+			if packet, ok := retained[msg.Topic]; !ok || packet.Qos < qos {
+				retained[msg.Topic] = &PublishPacket{Qos: qos, Retain: true, TopicName: msg.Topic, Payload: msg.Payload}
+			}
+		}
+	}
+	gateway.saveSession(session)
+	session.write(&SubackPacket{PacketId: p.PacketId, ReturnCodes: returnCodes})
+
+	topics := make([]string, 0, len(retained))
+	for topic := range retained {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		session.enqueue(retained[topic], nil)
+	}
+}
+
+func (gateway *MqttGateway) handleUnsubscribe(session *Session, p *UnsubscribePacket) {
+	for _, filter := range p.Filters {
+		if session.removeSubscription(filter) {
+			gateway.subscriptions.Unsubscribe(filter, session)
+		}
+	}
+	gateway.saveSession(session)
+	session.write(&UnsubackPacket{PacketId: p.PacketId})
+}
+
+// dispatch 将消费到的消息分发给订阅了对应MQTT主题的会话
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) dispatch(msg *message.MessageExt, mq *message.MessageQueue) {
+	if mq != nil {
+		gateway.progress.begin(mq, msg.QueueOffset)
+		defer gateway.progress.end(mq, msg.QueueOffset)
+	}
+
+	mqttTopic := gateway.resolveMqttTopic(msg)
+	msgQos := messageQos(msg)
+	for session, subQos := range gateway.subscriptions.Match(mqttTopic) {
+		qos := subQos
+		if msgQos < qos {
+			qos = msgQos
+		}
+		session.deliver(&PublishPacket{Qos: qos, TopicName: mqttTopic, Payload: msg.Body}, mq, msg.QueueOffset)
+	}
+}
+
+// loadPayloads 会话存储中的快照只保存消息在smartgo队列中的位置，恢复会话前按位置重新读取消息内容，
+// 已无法读取的消息(如超过broker保留时间)丢弃；返回的快照为副本，不修改会话存储中的快照
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) loadPayloads(state *SessionState) *SessionState {
+	loaded := *state
+	loaded.Inflight = make([]*InflightState, 0, len(state.Inflight))
+	for _, inflight := range state.Inflight {
+		if inflight.Source == nil || inflight.Payload != nil {
+			loaded.Inflight = append(loaded.Inflight, inflight)
+			continue
+		}
+		msg, err := gateway.readMessage(inflight.Source)
+		if err != nil {
+			logger.Warnf("mqtt session %s drop undelivered message of topic %s: %s", state.ClientId, inflight.Topic, err)
+			continue
+		}
+		copied := *inflight
+		copied.Payload = msg.Body
+		loaded.Inflight = append(loaded.Inflight, &copied)
+	}
+	return &loaded
+}
+
+// readMessage 读取队列中指定位置的消息
+func (gateway *MqttGateway) readMessage(source *QueueOffset) (*message.MessageExt, error) {
+	mq := source.MessageQueue()
+	result, err := gateway.reader.Pull(mq, "*", source.Offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil || result.PullStatus != consumer.FOUND {
+		return nil, fmt.Errorf("message at %s offset %d not found", queueKey(mq), source.Offset)
+	}
+	for _, msg := range result.MsgFoundList {
+		if msg.QueueOffset == source.Offset {
+			return msg, nil
+		}
+	}
+	return nil, fmt.Errorf("message at %s offset %d not found", queueKey(mq), source.Offset)
+}
+
+// replayOffline 从断开时记录的队列位置补发离线期间的QoS1/QoS2消息，截止到当前的分发进度
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) replayOffline(session *Session, offsets []*QueueOffset) {
+	ends := make(map[string]int64, len(offsets))
+	expressions := gateway.mapper.SubscribeExpressions()
+	replayed := 0
+
+	for _, from := range offsets {
+		expression, ok := expressions[from.Topic]
+		if !ok {
+			continue
+		}
+		mq := from.MessageQueue()
+		end, ok := gateway.progress.offset(mq)
+		if !ok {
+			maxOffset, err := gateway.reader.MaxOffset(mq)
+			if err != nil {
+				logger.Warnf("mqtt replay session %s skip %s: %s", session.ClientId(), queueKey(mq), err)
+				continue
+			}
+			gateway.progress.init(mq, maxOffset)
+			end = maxOffset
+		}
+		ends[queueKey(mq)] = end
+		if from.Offset >= end {
+			continue
+		}
+
+		_, err := readQueue(gateway.reader, mq, expression, from.Offset, end, func(msg *message.MessageExt) {
+			mqttTopic := gateway.resolveMqttTopic(msg)
+			qos, ok := session.matchQos(mqttTopic)
+			if !ok {
+				return
+			}
+			if msgQos := messageQos(msg); msgQos < qos {
+				qos = msgQos
+			}
+			if qos > 0 {
+				session.enqueue(&PublishPacket{Qos: qos, TopicName: mqttTopic, Payload: msg.Body}, messageSource(mq, msg.QueueOffset))
+				replayed++
+			}
+		})
+		if err != nil {
+			logger.Warnf("mqtt replay session %s from %s failed: %s", session.ClientId(), queueKey(mq), err)
+		}
+	}
+
+	session.finishReplay(ends)
+	if replayed > 0 {
+		logger.Infof("mqtt replay %d offline messages to session %s", replayed, session.ClientId())
+	}
+}
+
+func (gateway *MqttGateway) resolveMqttTopic(msg *message.MessageExt) string {
+	if mqttTopic := msg.GetProperty(message.PROPERTY_MQTT_TOPIC); mqttTopic != "" {
+		return mqttTopic
+	}
+	return gateway.mapper.ResolveMqttTopic(msg.Topic, msg.GetTags())
+}
+
+// messageQos 消息的QoS，后端应用发送的消息未设置时按QoS1投递
+func messageQos(msg *message.MessageExt) byte {
+	switch msg.GetProperty(message.PROPERTY_MQTT_QOS) {
+	case "0":
+		return 0
+	case "2":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// initProgress 为尚无分发进度的队列记录当前最大offset，作为持久会话断开时的补发起点
+func (gateway *MqttGateway) initProgress() {
+	for topic := range gateway.mapper.SubscribeExpressions() {
+		mqs, err := gateway.reader.FetchMessageQueues(topic)
+		if err != nil {
+			logger.Warnf("mqtt fetch message queues of %s failed: %s", topic, err)
+			continue
+		}
+		for _, mq := range mqs {
+			if gateway.progress.known(mq) {
+				continue
+			}
+			maxOffset, err := gateway.reader.MaxOffset(mq)
+			if err != nil {
+				logger.Warnf("mqtt query max offset of %s failed: %s", queueKey(mq), err)
+				continue
+			}
+			gateway.progress.init(mq, maxOffset)
+		}
+	}
+}
+
+// sessionOffsets 会话快照中记录的补发起点：补发未完成时保留原起点，否则取当前分发进度
+func (gateway *MqttGateway) sessionOffsets(session *Session) []*QueueOffset {
+	if offsets, replaying := session.replayOffsets(); replaying {
+		return offsets
+	}
+	return gateway.progress.snapshot()
+}
+
+// saveSession 持久会话状态变化后写入快照
+func (gateway *MqttGateway) saveSession(session *Session) {
+	if !session.isPersistent() {
+		return
+	}
+	state, changes := session.snapshot(gateway.name, true, gateway.sessionOffsets(session))
+	if err := gateway.store.Save(state); err != nil {
+		logger.Errorf("mqtt save session %s failed: %s", state.ClientId, err)
+		return
+	}
+	session.saved(state, changes)
+}
+
+// onSessionUpdate 其他节点写入了更新的快照(接管、踢出或清除)时，关闭本节点上的连接
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) onSessionUpdate(state *SessionState) {
+	if state.Owner == gateway.name {
+		return
+	}
+	gateway.lock.RLock()
+	session := gateway.clients[state.ClientId]
+	gateway.lock.RUnlock()
+	if session == nil || !session.isConnected() {
+		return
+	}
+
+	if session.isPersistent() {
+		if !state.NewerThan(session.lastSaved()) {
+			return
+		}
+	} else if !(state.Connected || state.Deleted) || state.UpdateTime < session.createTime.UnixNano()/int64(time.Millisecond) {
+		return
+	}
+	logger.Infof("mqtt session %s taken over by %s, close connection %s", state.ClientId, state.Owner, session.Addr())
+	session.takeOver()
+}
+
+// sessionOf 查找连接对应的会话，数据可能先于连接通知到达
+func (gateway *MqttGateway) sessionOf(ctx netm.Context) *Session {
+	gateway.lock.RLock()
+	session, ok := gateway.sessions[ctx.Addr()]
+	gateway.lock.RUnlock()
+	if ok {
+		return session
+	}
+
+	gateway.lock.Lock()
+	defer gateway.lock.Unlock()
+	if session, ok = gateway.sessions[ctx.Addr()]; !ok {
+		session = newSession(gateway, ctx)
+		gateway.sessions[ctx.Addr()] = session
+	}
+	return session
+}
+
+// connectedSession 本节点上已连接的会话
+func (gateway *MqttGateway) connectedSession(clientId string) *Session {
+	gateway.lock.RLock()
+	session := gateway.clients[clientId]
+	gateway.lock.RUnlock()
+	if session == nil || !session.isConnected() {
+		return nil
+	}
+	return session
+}
+
+// connectedClientIds 本节点上已连接的全部clientId
+func (gateway *MqttGateway) connectedClientIds() []string {
+	gateway.lock.RLock()
+	sessions := make([]*Session, 0, len(gateway.clients))
+	for _, session := range gateway.clients {
+		sessions = append(sessions, session)
+	}
+	gateway.lock.RUnlock()
+
+	clientIds := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		if session.isConnected() {
+			clientIds = append(clientIds, session.ClientId())
+		}
+	}
+	return clientIds
+}
+
+// removeSession 连接关闭后清理会话及其订阅，持久会话写入断开快照
+func (gateway *MqttGateway) removeSession(ctx netm.Context) {
+	gateway.lock.Lock()
+	session, ok := gateway.sessions[ctx.Addr()]
+	if !ok || session.ctx != ctx {
+		gateway.lock.Unlock()
+		return
+	}
+	delete(gateway.sessions, ctx.Addr())
+	clientId := session.ClientId()
+	removed := clientId != "" && gateway.clients[clientId] == session
+	if removed {
+		delete(gateway.clients, clientId)
+	}
+	gateway.lock.Unlock()
+	if removed && gateway.router != nil {
+		gateway.router.offline(clientId)
+	}
+
+	session.lock.Lock()
+	save := session.persistent && session.connected && !session.takenOver
+	var will *PublishPacket
+	if session.connected && !session.takenOver {
+		will = session.will
+	}
+	session.will = nil
+	session.lock.Unlock()
+
+	// 未收到DISCONNECT的连接关闭(keep-alive超时、网络异常、协议错误、被踢出)时发布遗嘱消息，会话被接管时不发布
+	if will != nil {
+		if err := gateway.publish(clientId, will); err != nil {
+			logger.Errorf("mqtt publish will of %s to %s failed: %s", clientId, will.TopicName, err)
+		} else {
+			logger.Infof("mqtt publish will of %s to %s", clientId, will.TopicName)
+		}
+	}
+
+	var state *SessionState
+	if save {
+		state, _ = session.snapshot(gateway.name, false, gateway.sessionOffsets(session))
+	}
+	for _, filter := range session.cleanup() {
+		gateway.subscriptions.Unsubscribe(filter, session)
+	}
+
+	if state != nil {
+		// 其他节点已写入更新的快照时说明会话已被接管，不再覆盖
+		if current, ok := gateway.store.Get(clientId); ok && current.Owner != gateway.name && current.NewerThan(session.lastSaved()) {
+			state = nil
+		}
+	}
+	if state != nil {
+		if err := gateway.store.Save(state); err != nil {
+			logger.Errorf("mqtt save session %s on disconnect failed: %s", clientId, err)
+		}
+	}
+	if clientId != "" {
+		logger.Infof("mqtt client %s disconnected from %s", clientId, ctx.Addr())
+	}
+}
+
+// scanSessions 每秒检查一次：已关闭但未清理的连接、CONNECT超时、keep-alive超时(1.5倍)及未确认消息重发
+func (gateway *MqttGateway) scanSessions() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	connectTimeout := time.Duration(gateway.config.ConnectTimeout) * time.Second
+	retryInterval := time.Duration(gateway.config.RetryInterval) * time.Second
+	for {
+		select {
+		case <-gateway.stopChan:
+			return
+		case now := <-ticker.C:
+			for _, session := range gateway.localSessions() {
+				if session.ctx.IsClosed() {
+					gateway.removeSession(session.ctx)
+					continue
+				}
+				session.lock.Lock()
+				connected, keepAlive := session.connected, session.keepAlive
+				session.lock.Unlock()
+
+				if !connected {
+					if now.Sub(session.createTime) > connectTimeout {
+						logger.Warnf("mqtt connection %s send no CONNECT in %s, close it", session.Addr(), connectTimeout)
+						session.close()
+					}
+					continue
+				}
+				if keepAlive > 0 && session.idle(now) > keepAlive*3/2 {
+					logger.Warnf("mqtt client %s keep alive timeout, close it", session.ClientId())
+					session.close()
+					continue
+				}
+				session.retryInflight(now, retryInterval)
+			}
+		}
+	}
+}
+
+// maintainSessions 按checkpoint间隔写入持久会话快照、清理过期会话及保留消息墓碑、重写长期未写入的保留消息，
+// 并为新增的队列初始化分发进度
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) maintainSessions() {
+	ticker := time.NewTicker(time.Duration(gateway.config.SessionCheckpointInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gateway.stopChan:
+			return
+		case <-ticker.C:
+			gateway.initProgress()
+			gateway.checkpointSessions()
+			gateway.expireSessions()
+			now := timeutil.CurrentTimeMillis()
+			gateway.retain.Refresh(now - int64(gateway.config.RetainRefreshInterval)*1000)
+			gateway.retain.Prune(now - gateway.expiryMillis())
+			if gateway.shadow != nil {
+				gateway.shadow.refresh(now - int64(gateway.config.ShadowRefreshInterval)*1000)
+				gateway.shadow.prune(now - gateway.expiryMillis())
+			}
+		}
+	}
+}
+
+// checkpointSessions 状态有变化或超过刷新间隔的在线持久会话写入快照
+func (gateway *MqttGateway) checkpointSessions() {
+	refreshMillis := int64(gateway.config.SessionRefreshInterval) * 1000
+	now := timeutil.CurrentTimeMillis()
+	for _, session := range gateway.localSessions() {
+		if !session.isConnected() || !session.isPersistent() {
+			continue
+		}
+		saved := session.lastSaved()
+		if session.isDirty() || saved == nil || now-saved.UpdateTime >= refreshMillis {
+			gateway.saveSession(session)
+		}
+	}
+}
+
+// expireSessions 删除超过过期时间的离线会话，并清理过期的墓碑
+func (gateway *MqttGateway) expireSessions() {
+	now := timeutil.CurrentTimeMillis()
+	for _, state := range gateway.store.List() {
+		if !state.Expired(now, gateway.expiryMillis(), gateway.staleMillis()) {
+			continue
+		}
+		gateway.lock.RLock()
+		_, online := gateway.clients[state.ClientId]
+		gateway.lock.RUnlock()
+		if online {
+			continue
+		}
+		if err := gateway.store.Save(newTombstone(state, gateway.name)); err != nil {
+			logger.Warnf("mqtt expire session %s failed: %s", state.ClientId, err)
+			continue
+		}
+		logger.Infof("mqtt session %s expired, last owner %s", state.ClientId, state.Owner)
+	}
+	gateway.store.Prune(now - gateway.expiryMillis())
+}
+
+// ListSessions 列出全部会话：本节点的在线会话及会话存储中的持久会话
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) ListSessions() []*SessionView {
+	views := make(map[string]*SessionView)
+	now := timeutil.CurrentTimeMillis()
+	for _, state := range gateway.store.List() {
+		view := &SessionView{
+			ClientId:       state.ClientId,
+			Owner:          state.Owner,
+			Online:         state.Connected && now-state.UpdateTime <= gateway.staleMillis(),
+			Persistent:     true,
+			Subscriptions:  make([]string, 0, len(state.Subscriptions)),
+			Inflight:       len(state.Inflight),
+			UpdateTime:     state.UpdateTime,
+			DisconnectTime: state.DisconnectTime,
+		}
+		for filter := range state.Subscriptions {
+			view.Subscriptions = append(view.Subscriptions, filter)
+		}
+		sort.Strings(view.Subscriptions)
+		views[state.ClientId] = view
+	}
+	for _, session := range gateway.localSessions() {
+		if session.isConnected() {
+			views[session.ClientId()] = session.view(gateway.name)
+		}
+	}
+
+	result := make([]*SessionView, 0, len(views))
+	for _, view := range views {
+		result = append(result, view)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ClientId < result[j].ClientId })
+	return result
+}
+
+// KickSession 踢出会话：在线会话断开连接(在其他节点时通过写入更新的快照通知持有节点)，
+// discard为true时同时清除持久会话
+// Author: agent
+// Since: 2026/10/19
+func (gateway *MqttGateway) KickSession(clientId string, discard bool) error {
+	gateway.lock.RLock()
+	session := gateway.clients[clientId]
+	gateway.lock.RUnlock()
+	stored, exist := gateway.store.Get(clientId)
+	exist = exist && !stored.Deleted
+
+	if session != nil {
+		if !discard {
+			session.close()
+			return nil
+		}
+		session.takeOver()
+	} else if !exist {
+		return fmt.Errorf("mqtt session %s not found", clientId)
+	}
+
+	if !exist {
+		return nil
+	}
+	if discard {
+		return gateway.store.Save(newTombstone(stored, gateway.name))
+	}
+	if !stored.Connected {
+		return fmt.Errorf("mqtt session %s is offline", clientId)
+	}
+
+	kicked := *stored
+	kicked.Owner = gateway.name
+	kicked.Version++
+	kicked.UpdateTime = timeutil.CurrentTimeMillis()
+	kicked.Connected = false
+	kicked.DisconnectTime = kicked.UpdateTime
+	return gateway.store.Save(&kicked)
+}
+
+func (gateway *MqttGateway) localSessions() []*Session {
+	gateway.lock.RLock()
+	defer gateway.lock.RUnlock()
+	sessions := make([]*Session, 0, len(gateway.sessions))
+	for _, session := range gateway.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func (gateway *MqttGateway) expiryMillis() int64 {
+	return int64(gateway.config.SessionExpiryInterval) * 1000
+}
+
+// staleMillis 在线会话超过3个刷新间隔未更新快照时，认为持有节点已异常退出
+func (gateway *MqttGateway) staleMillis() int64 {
+	return int64(gateway.config.SessionRefreshInterval) * 1000 * 3
+}
+
+func newTombstone(state *SessionState, owner string) *SessionState {
+	return &SessionState{
+		ClientId:   state.ClientId,
+		Owner:      owner,
+		Version:    state.Version + 1,
+		UpdateTime: timeutil.CurrentTimeMillis(),
+		Deleted:    true,
+	}
+}
+
+func (gateway *MqttGateway) OnContextConnect(ctx netm.Context) {
+	if !ctx.IsClosed() {
+		gateway.sessionOf(ctx)
+	}
+}
+
+func (gateway *MqttGateway) OnContextClose(ctx netm.Context) {
+	gateway.removeSession(ctx)
+}
+
+func (gateway *MqttGateway) OnContextError(ctx netm.Context) {
+	gateway.removeSession(ctx)
+}
+
+// OnContextIdle bootstrap只移除空闲连接，由网关关闭
+func (gateway *MqttGateway) OnContextIdle(ctx netm.Context) {
+	logger.Warnf("mqtt connection %s idle timeout, close it", ctx.Addr())
+	ctx.Close()
+	gateway.removeSession(ctx)
+}
+
+// gatewayMessageListener 网关consumer的消息监听
+type gatewayMessageListener struct {
+	gateway *MqttGateway
+}
+
+func (l *gatewayMessageListener) ConsumeMessage(msgs []*message.MessageExt, context *consumer.ConsumeConcurrentlyContext) listener.ConsumeConcurrentlyStatus {
+	for _, msg := range msgs {
+		l.gateway.dispatch(msg, context.MessageQueue)
+	}
+	return listener.CONSUME_SUCCESS
+}
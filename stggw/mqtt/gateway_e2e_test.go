@@ -0,0 +1,130 @@
+package mqtt
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgbroker"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/constant"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+	"git.oschina.net/cloudzone/smartgo/stgregistry/registry"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog"
+)
+
+// waitListen 等待地址可以建立TCP连接
+func waitListen(t *testing.T, addr string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("wait %s listen timeout", addr)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// startLocalCluster 在临时目录中启动namesrv及单个broker，并创建topics，返回namesrv地址及关闭函数；
+// namesrv没有提供关闭方法，随测试进程退出
+func startLocalCluster(t *testing.T, topics ...string) (string, func()) {
+	dir, err := ioutil.TempDir("", "smartgo-mqtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+
+	registryPort := freePort(t)
+	registry.Startup(make(chan bool, 1), registryPort)
+	namesrvAddr := net.JoinHostPort("127.0.0.1", strconv.Itoa(registryPort))
+	waitListen(t, namesrvAddr)
+
+	brokerConfig := stgcommon.NewBrokerConfig("broker-mqtt", "cluster-mqtt")
+	brokerConfig.StorePathRootDir = dir
+	brokerConfig.BrokerIP1 = "127.0.0.1"
+	brokerConfig.BrokerPort = freePort(t) - 1 // HA端口为broker端口+1
+	brokerConfig.NamesrvAddr = namesrvAddr
+	messageStoreConfig := stgstorelog.NewMessageStoreConfig()
+	messageStoreConfig.MapedFileSizeCommitLog = 1024 * 1024 * 4
+	controller := stgbroker.NewBrokerController(brokerConfig, messageStoreConfig, remoting.NewDefalutRemotingClient())
+	if !controller.Initialize() {
+		t.Fatal("initialize broker controller failed")
+	}
+	for _, topic := range topics {
+		controller.TopicConfigManager.UpdateTopicConfig(stgcommon.NewDefaultTopicConfig(topic, 1, 1, constant.PERM_READ|constant.PERM_WRITE, stgcommon.SINGLE_TAG))
+	}
+	controller.Start()
+	waitListen(t, net.JoinHostPort("127.0.0.1", strconv.Itoa(brokerConfig.BrokerPort)))
+
+	return namesrvAddr, func() {
+		controller.Shutdown()
+		os.Setenv("HOME", home)
+		os.RemoveAll(dir)
+	}
+}
+
+// TestGatewayLocalCluster 网关使用真实的producer及push consumer连接本地namesrv、broker，
+// MQTT客户端发布的消息经broker存储后推送给订阅者
+func TestGatewayLocalCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("start local namesrv and broker")
+	}
+	namesrvAddr, shutdown := startLocalCluster(t, "DeviceTelemetry", "DeviceCommand")
+	defer shutdown()
+
+	cfg := NewGatewayConfig()
+	cfg.ListenHost = "127.0.0.1"
+	cfg.ListenPort = freePort(t)
+	cfg.NamesrvAddr = namesrvAddr
+	cfg.SessionStore = SESSION_STORE_MEMORY
+	cfg.RetainStore = SESSION_STORE_MEMORY
+	cfg.Rules = []*MappingRule{
+		{Filter: "devices/+/telemetry", Topic: "DeviceTelemetry", Tags: "telemetry"},
+		{Filter: "devices/#", Topic: "DeviceCommand"},
+	}
+	gateway, err := NewMqttGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Shutdown()
+	addr := net.JoinHostPort(cfg.ListenHost, strconv.Itoa(cfg.ListenPort))
+	waitListen(t, addr)
+
+	sub := dialTestClient(t, addr, "subscriber", 60)
+	defer sub.Disconnect()
+	pub := dialTestClient(t, addr, "publisher", 60)
+	defer pub.Disconnect()
+	if qos, err := sub.Subscribe("devices/+/telemetry", 1); err != nil || qos != 1 {
+		t.Fatalf("subscribe granted qos %d, err %v", qos, err)
+	}
+
+	// push consumer从最新位点开始广播消费，分配到队列之前发布的消息不会推送，因此重复发布直到收到
+	deadline := time.Now().Add(60 * time.Second)
+	for i := 0; ; i++ {
+		payload := "23." + strconv.Itoa(i)
+		if err := pub.Publish("devices/42/telemetry", 1, []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case p := <-sub.Messages():
+			if p.TopicName != "devices/42/telemetry" || p.Qos != 1 || len(p.Payload) == 0 {
+				t.Fatalf("unexpected delivered message %+v", p)
+			}
+			return
+		case <-time.After(time.Second):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("wait message from local broker timeout")
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package mqtt
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+)
+
+// fakeBroker 内存中的broker：每个topic一个队列，发送的消息按offset追加，
+// 并以广播方式分发给订阅了该topic的网关；设备消息按tag交给对应节点的设备路由消费；
+// 影子记录异步分发给各网关，影子写入请求按shardingKey异步交给固定的一个网关处理；
+// 同时作为网关读取队列的messageReader，devices作为各网关共用的设备路由注册中心
+type fakeBroker struct {
+	logs     map[string][]*message.MessageExt
+	sent     []*message.Message
+	gateways []*MqttGateway
+	devices  *fakeDeviceRegistry
+	replies  map[string]chan *message.MessageExt // correlationId -> 等待应答的request
+	requests int64                               // 已发送的request数，用于生成correlationId
+	sendErr  error                               // 不为nil时发送失败
+	lock     sync.Mutex
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		logs:    make(map[string][]*message.MessageExt),
+		devices: newFakeDeviceRegistry(),
+		replies: make(map[string]chan *message.MessageExt),
+	}
+}
+
+func (b *fakeBroker) Start()    {}
+func (b *fakeBroker) Shutdown() {}
+
+func (b *fakeBroker) Send(msg *message.Message) (*process.SendResult, error) {
+	return b.send(msg, "")
+}
+
+func (b *fakeBroker) send(msg *message.Message, shardingKey string) (*process.SendResult, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.sendErr != nil {
+		return nil, b.sendErr
+	}
+	msgExt := &message.MessageExt{Message: *msg, QueueOffset: int64(len(b.logs[msg.Topic]))}
+	b.logs[msg.Topic] = append(b.logs[msg.Topic], msgExt)
+	b.sent = append(b.sent, msg)
+
+	mq := fakeQueue(msg.Topic)
+	for _, gateway := range b.gateways {
+		if _, ok := gateway.mapper.SubscribeExpressions()[msg.Topic]; ok {
+			gateway.dispatch(msgExt, mq)
+		}
+	}
+	if router := b.deviceRouterOf(msg); router != nil {
+		go consumeDeviceMessage(router, *msgExt, mq)
+	}
+	for _, gateway := range b.gateways {
+		if gateway.shadow != nil && msg.Topic == gateway.config.ShadowTopic && msg.GetTags() == shadowRecordTag {
+			go gateway.shadow.consume(msgExt)
+		}
+	}
+	if owner := b.shadowOwnerOf(msg, shardingKey); owner != nil {
+		go owner.shadow.handleWrite(msgExt)
+	}
+	return &process.SendResult{SendStatus: process.SEND_OK}, nil
+}
+
+// deviceRouterOf 设备消息由tag对应的节点消费，不在线设备的消息由第一个启用设备路由的节点消费
+func (b *fakeBroker) deviceRouterOf(msg *message.Message) *deviceRouter {
+	for _, gateway := range b.gateways {
+		router := gateway.router
+		if router == nil || msg.Topic != gateway.config.DeviceTopic {
+			continue
+		}
+		if msg.GetTags() == stgcommon.DEVICE_OFFLINE_TAG || msg.GetTags() == gateway.name {
+			return router
+		}
+	}
+	return nil
+}
+
+// consumeDeviceMessage 需要重新消费时稍后重试，与集群消费的重试一致
+func consumeDeviceMessage(router *deviceRouter, msg message.MessageExt, mq *message.MessageQueue) {
+	for !router.consume(&msg, mq) {
+		msg.ReconsumeTimes++
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// shadowOwnerOf 影子写入请求按shardingKey固定由一个启用设备影子的网关处理，与集群消费同一队列一致
+func (b *fakeBroker) shadowOwnerOf(msg *message.Message, shardingKey string) *MqttGateway {
+	if msg.GetTags() != shadowRequestTag || shardingKey == "" {
+		return nil
+	}
+	var owners []*MqttGateway
+	for _, gateway := range b.gateways {
+		if gateway.shadow != nil && msg.Topic == gateway.config.ShadowTopic {
+			owners = append(owners, gateway)
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	return owners[crc32.ChecksumIEEE([]byte(shardingKey))%uint32(len(owners))]
+}
+
+// SendByShardingKey 每个topic只有一个队列，shardingKey只用于选择处理影子写入请求的网关
+func (b *fakeBroker) SendByShardingKey(msg *message.Message, shardingKey string) (*process.SendResult, error) {
+	return b.send(msg, shardingKey)
+}
+
+func (b *fakeBroker) RequestByShardingKey(msg *message.Message, shardingKey string, timeout int64) (*message.MessageExt, error) {
+	reply := make(chan *message.MessageExt, 1)
+	b.lock.Lock()
+	b.requests++
+	correlationId := strconv.FormatInt(b.requests, 10)
+	b.replies[correlationId] = reply
+	b.lock.Unlock()
+	msg.PutProperty(message.PROPERTY_CORRELATION_ID, correlationId)
+	msg.PutProperty(message.PROPERTY_REQUEST_DEADLINE, strconv.FormatInt(timeutil.CurrentTimeMillis()+timeout, 10))
+	defer func() {
+		b.lock.Lock()
+		delete(b.replies, correlationId)
+		b.lock.Unlock()
+	}()
+
+	if _, err := b.send(msg, shardingKey); err != nil {
+		return nil, err
+	}
+	select {
+	case replyMsg := <-reply:
+		return replyMsg, nil
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		return nil, fmt.Errorf("wait reply of %s timeout", correlationId)
+	}
+}
+
+func (b *fakeBroker) SendReply(requestMsg *message.MessageExt, body []byte, timeout int64) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	reply, ok := b.replies[requestMsg.GetProperty(message.PROPERTY_CORRELATION_ID)]
+	if !ok {
+		return fmt.Errorf("request %s not found", requestMsg.GetProperty(message.PROPERTY_CORRELATION_ID))
+	}
+	reply <- &message.MessageExt{Message: message.Message{Body: body}}
+	return nil
+}
+
+func (b *fakeBroker) SendOneWay(msg *message.Message) error {
+	_, err := b.Send(msg)
+	return err
+}
+
+func (b *fakeBroker) FetchMessageQueues(topic string) ([]*message.MessageQueue, error) {
+	return []*message.MessageQueue{fakeQueue(topic)}, nil
+}
+
+func (b *fakeBroker) MaxOffset(mq *message.MessageQueue) (int64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return int64(len(b.logs[mq.Topic])), nil
+}
+
+func (b *fakeBroker) Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (*consumer.PullResult, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	log := b.logs[mq.Topic]
+	result := &consumer.PullResult{NextBeginOffset: offset, MaxOffset: int64(len(log))}
+	switch {
+	case offset > int64(len(log)):
+		result.PullStatus = consumer.OFFSET_ILLEGAL
+		result.NextBeginOffset = int64(len(log))
+	case offset == int64(len(log)):
+		result.PullStatus = consumer.NO_NEW_MSG
+	default:
+		end := offset + int64(maxNums)
+		if end > int64(len(log)) {
+			end = int64(len(log))
+		}
+		result.PullStatus = consumer.FOUND
+		result.MsgFoundList = append(result.MsgFoundList, log[offset:end]...)
+		result.NextBeginOffset = end
+	}
+	return result, nil
+}
+
+// QueryMessage 与broker的消息索引一致，按key从新到旧查询topic中的消息
+func (b *fakeBroker) QueryMessage(topic, key string, maxNum int, begin, end int64) ([]*message.MessageExt, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var msgs []*message.MessageExt
+	log := b.logs[topic]
+	for i := len(log) - 1; i >= 0 && len(msgs) < maxNum; i-- {
+		if log[i].GetKeys() == key {
+			msgs = append(msgs, log[i])
+		}
+	}
+	return msgs, nil
+}
+
+// messageAt 读取队列位置上的消息
+func (b *fakeBroker) messageAt(source *QueueOffset) *message.MessageExt {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if source == nil {
+		return nil
+	}
+	log := b.logs[source.Topic]
+	if source.Offset < 0 || source.Offset >= int64(len(log)) {
+		return nil
+	}
+	return log[source.Offset]
+}
+
+func (b *fakeBroker) lastSent() *message.Message {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.sent) == 0 {
+		return nil
+	}
+	return b.sent[len(b.sent)-1]
+}
+
+func (b *fakeBroker) count(topic string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.logs[topic])
+}
+
+// countTag topic中指定tag的消息数
+func (b *fakeBroker) countTag(topic, tag string) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	count := 0
+	for _, msg := range b.logs[topic] {
+		if msg.GetTags() == tag {
+			count++
+		}
+	}
+	return count
+}
+
+func fakeQueue(topic string) *message.MessageQueue {
+	return &message.MessageQueue{Topic: topic, BrokerName: "fake-broker", QueueId: 0}
+}
+
+type nopConsumer struct{}
+
+func (c *nopConsumer) Start()    {}
+func (c *nopConsumer) Shutdown() {}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func startTestGateway(t *testing.T, cfg *GatewayConfig, broker *fakeBroker) (*MqttGateway, string) {
+	cfg.ListenHost = "127.0.0.1"
+	cfg.ListenPort = freePort(t)
+	cfg.SessionSyncInterval = 20
+	if len(cfg.Rules) == 0 {
+		cfg.Rules = []*MappingRule{
+			{Filter: "devices/+/telemetry", Topic: "DeviceTelemetry", Tags: "telemetry"},
+			{Filter: "devices/#", Topic: "DeviceCommand"},
+		}
+	}
+
+	gateway, err := NewMqttGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.producer = broker
+	gateway.reader = broker
+	gateway.consumer = &nopConsumer{}
+	if gateway.router != nil {
+		gateway.router.registry = broker.devices
+		gateway.router.nodeConsumer = &nopConsumer{}
+		gateway.router.offlineConsumer = &nopConsumer{}
+	}
+	if gateway.shadow != nil {
+		gateway.shadow.querier = broker
+		gateway.shadow.consumer = &nopConsumer{}
+		gateway.shadow.ownerConsumer = &nopConsumer{}
+	}
+	syncInterval := time.Duration(cfg.SessionSyncInterval) * time.Millisecond
+	if cfg.SessionStore == SESSION_STORE_TOPIC {
+		gateway.store = newTopicSessionStore(cfg.SessionTopic, broker, broker, syncInterval)
+	}
+	if cfg.RetainStore == SESSION_STORE_TOPIC {
+		gateway.retain = newTopicRetainStore(cfg.RetainTopic, broker, broker, syncInterval)
+	}
+	broker.lock.Lock()
+	broker.gateways = append(broker.gateways, gateway)
+	broker.lock.Unlock()
+	if err = gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := net.JoinHostPort(cfg.ListenHost, strconv.Itoa(cfg.ListenPort))
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return gateway, addr
+}
+
+func dialTestClient(t *testing.T, addr, clientId string, keepAlive uint16) *Client {
+	client, err := DialClient(addr, &ConnectPacket{CleanSession: true, ClientId: clientId, KeepAlive: keepAlive}, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func receive(t *testing.T, client *Client) *PublishPacket {
+	select {
+	case p := <-client.Messages():
+		return p
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait message timeout")
+	}
+	return nil
+}
+
+func TestGatewayPublishSubscribe(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gateway.Shutdown()
+
+	sub := dialTestClient(t, addr, "subscriber", 60)
+	defer sub.Disconnect()
+	pub := dialTestClient(t, addr, "publisher", 60)
+	defer pub.Disconnect()
+
+	if qos, err := sub.Subscribe("devices/+/telemetry", 2); err != nil || qos != 2 {
+		t.Fatalf("subscribe granted qos %d, err %v", qos, err)
+	}
+	if qos, err := sub.Subscribe("devices/#/x", 0); err != nil || qos != SUBACK_FAILURE {
+		t.Fatalf("invalid filter granted qos %d, err %v", qos, err)
+	}
+
+	if err := pub.Publish("devices/42/telemetry", 1, []byte("23.5")); err != nil {
+		t.Fatal(err)
+	}
+	msg := broker.lastSent()
+	if msg.Topic != "DeviceTelemetry" || msg.GetTags() != "telemetry" ||
+		msg.GetProperty(message.PROPERTY_MQTT_CLIENT_ID) != "publisher" ||
+		msg.GetProperty(message.PROPERTY_MQTT_TOPIC) != "devices/42/telemetry" ||
+		msg.GetProperty(message.PROPERTY_MQTT_QOS) != "1" {
+		t.Fatalf("unexpected smartgo message %+v", msg)
+	}
+	if p := receive(t, sub); p.TopicName != "devices/42/telemetry" || string(p.Payload) != "23.5" || p.Qos != 1 {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+
+	if err := pub.Publish("devices/42/telemetry", 0, []byte("24.0")); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, sub); string(p.Payload) != "24.0" || p.Qos != 0 {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+
+	if err := sub.Unsubscribe("devices/+/telemetry"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Publish("devices/42/telemetry", 1, []byte("25.0")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-sub.Messages():
+		t.Fatalf("unexpected message after unsubscribe %+v", p)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := pub.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGatewayPublishFailed(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gateway.Shutdown()
+
+	broker.lock.Lock()
+	broker.sendErr = fmt.Errorf("broker unavailable")
+	broker.lock.Unlock()
+
+	// QoS0写入失败时丢弃，连接保持
+	pub := dialTestClient(t, addr, "publisher", 60)
+	if err := pub.Publish("devices/42/telemetry", 0, []byte("23.5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// QoS1写入失败时不确认并关闭连接，由客户端重发
+	if err := pub.Publish("devices/42/telemetry", 1, []byte("23.5")); err == nil {
+		t.Fatal("expect publish failed")
+	}
+	select {
+	case <-pub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("connection not closed after publish failed")
+	}
+
+	broker.lock.Lock()
+	broker.sendErr = nil
+	broker.lock.Unlock()
+	pub = dialTestClient(t, addr, "publisher", 60)
+	defer pub.Disconnect()
+	if err := pub.Publish("devices/42/telemetry", 1, []byte("23.5")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := broker.lastSent(); msg == nil || string(msg.Body) != "23.5" {
+		t.Fatalf("unexpected smartgo message %+v", msg)
+	}
+}
+
+func TestGatewayDispatchWithoutMqttTopic(t *testing.T) {
+	cfg := NewGatewayConfig()
+	cfg.Rules = []*MappingRule{{Filter: "devices/broadcast", Topic: "DeviceCommand"}}
+	gateway, addr := startTestGateway(t, cfg, newFakeBroker())
+	defer gateway.Shutdown()
+
+	client := dialTestClient(t, addr, "device-1", 60)
+	defer client.Disconnect()
+	if _, err := client.Subscribe("devices/broadcast", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// 后端应用直接发往smartgo topic的消息，按规则推导MQTT主题
+	gateway.dispatch(&message.MessageExt{Message: *message.NewMessage("DeviceCommand", "", []byte("reboot"))}, nil)
+	if p := receive(t, client); p.TopicName != "devices/broadcast" || string(p.Payload) != "reboot" {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+}
+
+func TestGatewayInflightWindow(t *testing.T) {
+	cfg := NewGatewayConfig()
+	cfg.MaxInflight = 2
+	cfg.MaxQueuedMessages = 2
+	gateway, addr := startTestGateway(t, cfg, newFakeBroker())
+	defer gateway.Shutdown()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write((&ConnectPacket{CleanSession: true, ClientId: "slow-device"}).Encode())
+	conn.Write((&SubscribePacket{PacketId: 1, Subscriptions: []Subscription{{Filter: "devices/#", Qos: 1}}}).Encode())
+	readPackets(t, conn, 2) // CONNACK、SUBACK
+
+	for i := 0; i < 5; i++ {
+		msg := message.NewMessage("DeviceCommand", "", []byte(fmt.Sprintf("cmd-%d", i)))
+		msg.PutProperty(message.PROPERTY_MQTT_TOPIC, "devices/1/cmd")
+		gateway.dispatch(&message.MessageExt{Message: *msg}, nil)
+	}
+
+	// 窗口为2：未确认前只下发2条，排队上限为2，最早排队的cmd-2被丢弃
+	packets := readPackets(t, conn, 2)
+	if string(packets[0].(*PublishPacket).Payload) != "cmd-0" || string(packets[1].(*PublishPacket).Payload) != "cmd-1" {
+		t.Fatalf("unexpected inflight messages %+v", packets)
+	}
+	conn.Write((&PubackPacket{PacketId: packets[0].(*PublishPacket).PacketId}).Encode())
+	conn.Write((&PubackPacket{PacketId: packets[1].(*PublishPacket).PacketId}).Encode())
+	packets = readPackets(t, conn, 2)
+	if string(packets[0].(*PublishPacket).Payload) != "cmd-3" || string(packets[1].(*PublishPacket).Payload) != "cmd-4" {
+		t.Fatalf("unexpected queued messages %+v", packets)
+	}
+}
+
+func TestGatewaySessionTakeoverAndKeepAlive(t *testing.T) {
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), newFakeBroker())
+	defer gateway.Shutdown()
+
+	old := dialTestClient(t, addr, "device-1", 0)
+	newer := dialTestClient(t, addr, "device-1", 1)
+	select {
+	case <-old.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("old connection should be closed by takeover")
+	}
+
+	// keepAlive为1秒，1.5秒内没有任何报文时连接被关闭
+	select {
+	case <-newer.Done():
+	case <-time.After(4 * time.Second):
+		t.Fatalf("connection should be closed after keep alive timeout")
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write((&PingreqPacket{}).Encode())
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("packet before CONNECT should close the connection")
+	}
+}
+
+func readPackets(t *testing.T, conn net.Conn, count int) []Packet {
+	var packets []Packet
+	var buffer []byte
+	b := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for len(packets) < count {
+		packet, n, err := DecodePacket(buffer, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packet != nil {
+			buffer = buffer[n:]
+			packets = append(packets, packet)
+			continue
+		}
+		n, err = conn.Read(b)
+		if err != nil {
+			t.Fatalf("read packets failed: %s", err)
+		}
+		buffer = append(buffer, b[:n]...)
+	}
+	return packets
+}
@@ -0,0 +1,116 @@
+package mqtt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MappingRule MQTT主题过滤器到smartgo topic、tags的映射规则
+// Author: agent
+// Since: 2026/10/19
+type MappingRule struct {
+	Filter string `toml:"filter"` // MQTT主题过滤器，支持'+'、'#'
+	Topic  string `toml:"topic"`  // smartgo topic
+	Tags   string `toml:"tags"`   // smartgo tags，为空表示不设置tags
+}
+
+func (rule *MappingRule) String() string {
+	return fmt.Sprintf("MappingRule [filter=%s, topic=%s, tags=%s]", rule.Filter, rule.Topic, rule.Tags)
+}
+
+// TopicMapper 按配置顺序匹配映射规则，先配置的规则优先
+// Author: agent
+// Since: 2026/10/19
+type TopicMapper struct {
+	rules []*MappingRule
+}
+
+// NewTopicMapper 校验并创建映射规则
+// Author: agent
+// Since: 2026/10/19
+func NewTopicMapper(rules []*MappingRule) (*TopicMapper, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no mapping rule configured")
+	}
+	for _, rule := range rules {
+		if !ValidTopicFilter(rule.Filter) {
+			return nil, fmt.Errorf("invalid mqtt topic filter in %s", rule)
+		}
+		if strings.TrimSpace(rule.Topic) == "" {
+			return nil, fmt.Errorf("empty smartgo topic in %s", rule)
+		}
+		if strings.Contains(rule.Tags, "||") || rule.Tags == "*" {
+			return nil, fmt.Errorf("tags must be a single tag in %s", rule)
+		}
+	}
+	return &TopicMapper{rules: rules}, nil
+}
+
+// Rules 全部映射规则
+func (mapper *TopicMapper) Rules() []*MappingRule {
+	return mapper.rules
+}
+
+// Match 查找MQTT主题对应的第一条映射规则
+// Author: agent
+// Since: 2026/10/19
+func (mapper *TopicMapper) Match(mqttTopic string) (*MappingRule, bool) {
+	for _, rule := range mapper.rules {
+		if MatchTopic(rule.Filter, mqttTopic) {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// SubscribeExpressions 网关需要消费的smartgo topic及订阅表达式；
+// 同一topic下任一规则未设置tags时订阅全部消息，否则订阅各规则tags的并集
+// Author: agent
+// Since: 2026/10/19
+func (mapper *TopicMapper) SubscribeExpressions() map[string]string {
+	tagsTable := make(map[string]map[string]bool)
+	for _, rule := range mapper.rules {
+		tags, ok := tagsTable[rule.Topic]
+		if !ok {
+			tags = make(map[string]bool)
+			tagsTable[rule.Topic] = tags
+		}
+		if rule.Tags == "" {
+			tags["*"] = true
+		} else {
+			tags[rule.Tags] = true
+		}
+	}
+
+	expressions := make(map[string]string, len(tagsTable))
+	for topic, tags := range tagsTable {
+		if tags["*"] {
+			expressions[topic] = "*"
+			continue
+		}
+		list := make([]string, 0, len(tags))
+		for tag := range tags {
+			list = append(list, tag)
+		}
+		sort.Strings(list)
+		expressions[topic] = strings.Join(list, " || ")
+	}
+	return expressions
+}
+
+// ResolveMqttTopic 消息未携带MQTT主题属性时推导投递的MQTT主题：
+// 优先取与topic、tags匹配且不含通配符的规则过滤器，否则直接使用smartgo topic
+// Author: agent
+// Since: 2026/10/19
+func (mapper *TopicMapper) ResolveMqttTopic(topic, tags string) string {
+	for _, rule := range mapper.rules {
+		if rule.Topic != topic || HasWildcard(rule.Filter) {
+			continue
+		}
+		if rule.Tags == "" || rule.Tags == tags {
+			return rule.Filter
+		}
+	}
+	return topic
+}
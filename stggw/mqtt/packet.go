@@ -0,0 +1,582 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MQTT 3.1.1控制报文类型
+const (
+	CONNECT     byte = 1
+	CONNACK     byte = 2
+	PUBLISH     byte = 3
+	PUBACK      byte = 4
+	PUBREC      byte = 5
+	PUBREL      byte = 6
+	PUBCOMP     byte = 7
+	SUBSCRIBE   byte = 8
+	SUBACK      byte = 9
+	UNSUBSCRIBE byte = 10
+	UNSUBACK    byte = 11
+	PINGREQ     byte = 12
+	PINGRESP    byte = 13
+	DISCONNECT  byte = 14
+)
+
+// CONNACK返回码
+const (
+	CONNACK_ACCEPTED                      byte = 0x00
+	CONNACK_UNACCEPTABLE_PROTOCOL_VERSION byte = 0x01
+	CONNACK_IDENTIFIER_REJECTED           byte = 0x02
+	CONNACK_SERVER_UNAVAILABLE            byte = 0x03
+	CONNACK_BAD_USERNAME_OR_PASSWORD      byte = 0x04
+	CONNACK_NOT_AUTHORIZED                byte = 0x05
+)
+
+const (
+	PROTOCOL_NAME      = "MQTT"
+	PROTOCOL_LEVEL     = 4    // MQTT 3.1.1
+	SUBACK_FAILURE     = 0x80 // SUBACK中表示订阅失败的返回码
+	MAX_REMAINING_SIZE = 268435455
+)
+
+var packetNames = map[byte]string{
+	CONNECT:     "CONNECT",
+	CONNACK:     "CONNACK",
+	PUBLISH:     "PUBLISH",
+	PUBACK:      "PUBACK",
+	PUBREC:      "PUBREC",
+	PUBREL:      "PUBREL",
+	PUBCOMP:     "PUBCOMP",
+	SUBSCRIBE:   "SUBSCRIBE",
+	SUBACK:      "SUBACK",
+	UNSUBSCRIBE: "UNSUBSCRIBE",
+	UNSUBACK:    "UNSUBACK",
+	PINGREQ:     "PINGREQ",
+	PINGRESP:    "PINGRESP",
+	DISCONNECT:  "DISCONNECT",
+}
+
+// PacketName 报文类型名称
+// Author: agent
+// Since: 2026/10/19
+func PacketName(packetType byte) string {
+	if name, ok := packetNames[packetType]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", packetType)
+}
+
+// Packet MQTT控制报文
+// Author: agent
+// Since: 2026/10/19
+type Packet interface {
+	Type() byte
+	Encode() []byte
+}
+
+// ConnectPacket 客户端请求连接
+type ConnectPacket struct {
+	ProtocolName  string
+	ProtocolLevel byte
+	CleanSession  bool
+	WillFlag      bool
+	WillQos       byte
+	WillRetain    bool
+	UsernameFlag  bool
+	PasswordFlag  bool
+	KeepAlive     uint16 // 单位秒，0表示不检查
+	ClientId      string
+	WillTopic     string
+	WillMessage   []byte
+	Username      string
+	Password      []byte
+}
+
+// ConnackPacket 连接确认
+type ConnackPacket struct {
+	SessionPresent bool
+	ReturnCode     byte
+}
+
+// PublishPacket 发布消息
+type PublishPacket struct {
+	Dup       bool
+	Qos       byte
+	Retain    bool
+	TopicName string
+	PacketId  uint16 // 仅QoS>0时有效
+	Payload   []byte
+}
+
+// PubackPacket QoS1发布确认
+type PubackPacket struct {
+	PacketId uint16
+}
+
+// PubrecPacket QoS2发布收到(第一步确认)
+type PubrecPacket struct {
+	PacketId uint16
+}
+
+// PubrelPacket QoS2发布释放(第二步)
+type PubrelPacket struct {
+	PacketId uint16
+}
+
+// PubcompPacket QoS2发布完成(第三步)
+type PubcompPacket struct {
+	PacketId uint16
+}
+
+// Subscription 订阅项
+type Subscription struct {
+	Filter string
+	Qos    byte
+}
+
+// SubscribePacket 订阅请求
+type SubscribePacket struct {
+	PacketId      uint16
+	Subscriptions []Subscription
+}
+
+// SubackPacket 订阅确认
+type SubackPacket struct {
+	PacketId    uint16
+	ReturnCodes []byte
+}
+
+// UnsubscribePacket 取消订阅请求
+type UnsubscribePacket struct {
+	PacketId uint16
+	Filters  []string
+}
+
+// UnsubackPacket 取消订阅确认
+type UnsubackPacket struct {
+	PacketId uint16
+}
+
+// PingreqPacket 心跳请求
+type PingreqPacket struct{}
+
+// PingrespPacket 心跳响应
+type PingrespPacket struct{}
+
+// DisconnectPacket 客户端断开连接
+type DisconnectPacket struct{}
+
+func (p *ConnectPacket) Type() byte     { return CONNECT }
+func (p *ConnackPacket) Type() byte     { return CONNACK }
+func (p *PublishPacket) Type() byte     { return PUBLISH }
+func (p *PubackPacket) Type() byte      { return PUBACK }
+func (p *PubrecPacket) Type() byte      { return PUBREC }
+func (p *PubrelPacket) Type() byte      { return PUBREL }
+func (p *PubcompPacket) Type() byte     { return PUBCOMP }
+func (p *SubscribePacket) Type() byte   { return SUBSCRIBE }
+func (p *SubackPacket) Type() byte      { return SUBACK }
+func (p *UnsubscribePacket) Type() byte { return UNSUBSCRIBE }
+func (p *UnsubackPacket) Type() byte    { return UNSUBACK }
+func (p *PingreqPacket) Type() byte     { return PINGREQ }
+func (p *PingrespPacket) Type() byte    { return PINGRESP }
+func (p *DisconnectPacket) Type() byte  { return DISCONNECT }
+
+func (p *ConnectPacket) Encode() []byte {
+	var flags byte
+	if p.CleanSession {
+		flags |= 0x02
+	}
+	if p.WillFlag {
+		flags |= 0x04 | (p.WillQos&0x03)<<3
+		if p.WillRetain {
+			flags |= 0x20
+		}
+	}
+	if p.PasswordFlag {
+		flags |= 0x40
+	}
+	if p.UsernameFlag {
+		flags |= 0x80
+	}
+
+	protocolName := p.ProtocolName
+	if protocolName == "" {
+		protocolName = PROTOCOL_NAME
+	}
+	protocolLevel := p.ProtocolLevel
+	if protocolLevel == 0 {
+		protocolLevel = PROTOCOL_LEVEL
+	}
+
+	body := appendString(nil, protocolName)
+	body = append(body, protocolLevel, flags)
+	body = appendUint16(body, p.KeepAlive)
+	body = appendString(body, p.ClientId)
+	if p.WillFlag {
+		body = appendString(body, p.WillTopic)
+		body = appendBytes(body, p.WillMessage)
+	}
+	if p.UsernameFlag {
+		body = appendString(body, p.Username)
+	}
+	if p.PasswordFlag {
+		body = appendBytes(body, p.Password)
+	}
+	return encodeFixedHeader(CONNECT<<4, body)
+}
+
+func (p *ConnackPacket) Encode() []byte {
+	var ack byte
+	if p.SessionPresent {
+		ack = 0x01
+	}
+	return encodeFixedHeader(CONNACK<<4, []byte{ack, p.ReturnCode})
+}
+
+func (p *PublishPacket) Encode() []byte {
+	header := PUBLISH<<4 | (p.Qos&0x03)<<1
+	if p.Dup {
+		header |= 0x08
+	}
+	if p.Retain {
+		header |= 0x01
+	}
+
+	body := appendString(make([]byte, 0, len(p.TopicName)+len(p.Payload)+4), p.TopicName)
+	if p.Qos > 0 {
+		body = appendUint16(body, p.PacketId)
+	}
+	body = append(body, p.Payload...)
+	return encodeFixedHeader(header, body)
+}
+
+func (p *PubackPacket) Encode() []byte {
+	return encodeFixedHeader(PUBACK<<4, appendUint16(nil, p.PacketId))
+}
+
+func (p *PubrecPacket) Encode() []byte {
+	return encodeFixedHeader(PUBREC<<4, appendUint16(nil, p.PacketId))
+}
+
+func (p *PubrelPacket) Encode() []byte {
+	return encodeFixedHeader(PUBREL<<4|0x02, appendUint16(nil, p.PacketId))
+}
+
+func (p *PubcompPacket) Encode() []byte {
+	return encodeFixedHeader(PUBCOMP<<4, appendUint16(nil, p.PacketId))
+}
+
+func (p *SubscribePacket) Encode() []byte {
+	body := appendUint16(nil, p.PacketId)
+	for _, sub := range p.Subscriptions {
+		body = appendString(body, sub.Filter)
+		body = append(body, sub.Qos)
+	}
+	return encodeFixedHeader(SUBSCRIBE<<4|0x02, body)
+}
+
+func (p *SubackPacket) Encode() []byte {
+	body := appendUint16(nil, p.PacketId)
+	body = append(body, p.ReturnCodes...)
+	return encodeFixedHeader(SUBACK<<4, body)
+}
+
+func (p *UnsubscribePacket) Encode() []byte {
+	body := appendUint16(nil, p.PacketId)
+	for _, filter := range p.Filters {
+		body = appendString(body, filter)
+	}
+	return encodeFixedHeader(UNSUBSCRIBE<<4|0x02, body)
+}
+
+func (p *UnsubackPacket) Encode() []byte {
+	return encodeFixedHeader(UNSUBACK<<4, appendUint16(nil, p.PacketId))
+}
+
+func (p *PingreqPacket) Encode() []byte {
+	return []byte{PINGREQ << 4, 0}
+}
+
+func (p *PingrespPacket) Encode() []byte {
+	return []byte{PINGRESP << 4, 0}
+}
+
+func (p *DisconnectPacket) Encode() []byte {
+	return []byte{DISCONNECT << 4, 0}
+}
+
+// DecodePacket 从buf头部解码一个完整报文，返回报文及其占用的字节数；
+// 数据不足一个报文时返回(nil, 0, nil)，调用方需继续累积数据
+// Author: agent
+// Since: 2026/10/19
+func DecodePacket(buf []byte, maxPacketSize int) (Packet, int, error) {
+	if len(buf) < 2 {
+		return nil, 0, nil
+	}
+
+	remaining, lenBytes, err := decodeRemainingLength(buf[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if lenBytes == 0 {
+		return nil, 0, nil
+	}
+	if maxPacketSize > 0 && remaining > maxPacketSize {
+		return nil, 0, fmt.Errorf("packet size %d exceeds limit %d", remaining, maxPacketSize)
+	}
+
+	total := 1 + lenBytes + remaining
+	if len(buf) < total {
+		return nil, 0, nil
+	}
+
+	header := buf[0]
+	body := buf[1+lenBytes : total]
+	packet, err := decodeBody(header>>4, header&0x0F, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return packet, total, nil
+}
+
+func decodeBody(packetType, flags byte, body []byte) (Packet, error) {
+	r := &packetReader{buf: body}
+	switch packetType {
+	case CONNECT:
+		return decodeConnect(r)
+	case CONNACK:
+		if len(body) != 2 {
+			return nil, fmt.Errorf("malformed CONNACK")
+		}
+		return &ConnackPacket{SessionPresent: body[0]&0x01 == 0x01, ReturnCode: body[1]}, nil
+	case PUBLISH:
+		p := &PublishPacket{Dup: flags&0x08 != 0, Qos: (flags >> 1) & 0x03, Retain: flags&0x01 != 0}
+		if p.Qos == 3 {
+			return nil, fmt.Errorf("malformed PUBLISH qos %d", p.Qos)
+		}
+		p.TopicName = r.readString()
+		if p.Qos > 0 {
+			p.PacketId = r.readUint16()
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("malformed PUBLISH: %s", r.err)
+		}
+		p.Payload = append([]byte(nil), r.rest()...)
+		return p, nil
+	case PUBACK:
+		id, err := decodePacketId(r)
+		return &PubackPacket{PacketId: id}, err
+	case PUBREC:
+		id, err := decodePacketId(r)
+		return &PubrecPacket{PacketId: id}, err
+	case PUBREL:
+		if flags != 0x02 {
+			return nil, fmt.Errorf("malformed PUBREL flags %d", flags)
+		}
+		id, err := decodePacketId(r)
+		return &PubrelPacket{PacketId: id}, err
+	case PUBCOMP:
+		id, err := decodePacketId(r)
+		return &PubcompPacket{PacketId: id}, err
+	case SUBSCRIBE:
+		if flags != 0x02 {
+			return nil, fmt.Errorf("malformed SUBSCRIBE flags %d", flags)
+		}
+		p := &SubscribePacket{PacketId: r.readUint16()}
+		for r.err == nil && r.remaining() > 0 {
+			filter := r.readString()
+			qos := r.readByte()
+			if qos > 2 {
+				return nil, fmt.Errorf("malformed SUBSCRIBE qos %d", qos)
+			}
+			p.Subscriptions = append(p.Subscriptions, Subscription{Filter: filter, Qos: qos})
+		}
+		if r.err != nil || len(p.Subscriptions) == 0 {
+			return nil, fmt.Errorf("malformed SUBSCRIBE")
+		}
+		return p, nil
+	case SUBACK:
+		p := &SubackPacket{PacketId: r.readUint16()}
+		if r.err != nil {
+			return nil, fmt.Errorf("malformed SUBACK")
+		}
+		p.ReturnCodes = append([]byte(nil), r.rest()...)
+		return p, nil
+	case UNSUBSCRIBE:
+		if flags != 0x02 {
+			return nil, fmt.Errorf("malformed UNSUBSCRIBE flags %d", flags)
+		}
+		p := &UnsubscribePacket{PacketId: r.readUint16()}
+		for r.err == nil && r.remaining() > 0 {
+			p.Filters = append(p.Filters, r.readString())
+		}
+		if r.err != nil || len(p.Filters) == 0 {
+			return nil, fmt.Errorf("malformed UNSUBSCRIBE")
+		}
+		return p, nil
+	case UNSUBACK:
+		id, err := decodePacketId(r)
+		return &UnsubackPacket{PacketId: id}, err
+	case PINGREQ:
+		return &PingreqPacket{}, nil
+	case PINGRESP:
+		return &PingrespPacket{}, nil
+	case DISCONNECT:
+		return &DisconnectPacket{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported packet type %s", PacketName(packetType))
+	}
+}
+
+func decodeConnect(r *packetReader) (Packet, error) {
+	p := &ConnectPacket{}
+	p.ProtocolName = r.readString()
+	p.ProtocolLevel = r.readByte()
+	flags := r.readByte()
+	p.KeepAlive = r.readUint16()
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed CONNECT: %s", r.err)
+	}
+	if flags&0x01 != 0 {
+		return nil, fmt.Errorf("malformed CONNECT: reserved flag set")
+	}
+
+	p.CleanSession = flags&0x02 != 0
+	p.WillFlag = flags&0x04 != 0
+	p.WillQos = (flags >> 3) & 0x03
+	p.WillRetain = flags&0x20 != 0
+	p.PasswordFlag = flags&0x40 != 0
+	p.UsernameFlag = flags&0x80 != 0
+
+	p.ClientId = r.readString()
+	if p.WillFlag {
+		p.WillTopic = r.readString()
+		p.WillMessage = r.readBytes()
+	}
+	if p.UsernameFlag {
+		p.Username = r.readString()
+	}
+	if p.PasswordFlag {
+		p.Password = r.readBytes()
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed CONNECT: %s", r.err)
+	}
+	return p, nil
+}
+
+func decodePacketId(r *packetReader) (uint16, error) {
+	id := r.readUint16()
+	if r.err != nil || r.remaining() != 0 {
+		return 0, fmt.Errorf("malformed packet identifier")
+	}
+	return id, nil
+}
+
+// decodeRemainingLength 解析剩余长度，返回长度及其占用的字节数(字节不足时为0)
+func decodeRemainingLength(buf []byte) (int, int, error) {
+	value, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		if i >= len(buf) {
+			return 0, 0, nil
+		}
+		value += int(buf[i]&0x7F) * multiplier
+		if buf[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+		multiplier *= 128
+	}
+	return 0, 0, fmt.Errorf("malformed remaining length")
+}
+
+func encodeFixedHeader(header byte, body []byte) []byte {
+	buf := make([]byte, 0, len(body)+5)
+	buf = append(buf, header)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(buf, body...)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = appendUint16(buf, uint16(len(b)))
+	return append(buf, b...)
+}
+
+// packetReader 顺序读取报文可变头与载荷，出错后后续读取均返回零值
+type packetReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *packetReader) remaining() int {
+	return len(r.buf) - r.pos
+}
+
+func (r *packetReader) rest() []byte {
+	return r.buf[r.pos:]
+}
+
+func (r *packetReader) readByte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.remaining() < 1 {
+		r.err = fmt.Errorf("unexpected end of packet")
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *packetReader) readUint16() uint16 {
+	if r.err != nil {
+		return 0
+	}
+	if r.remaining() < 2 {
+		r.err = fmt.Errorf("unexpected end of packet")
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.buf[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *packetReader) readBytes() []byte {
+	n := int(r.readUint16())
+	if r.err != nil {
+		return nil
+	}
+	if r.remaining() < n {
+		r.err = fmt.Errorf("unexpected end of packet")
+		return nil
+	}
+	b := append([]byte(nil), r.buf[r.pos:r.pos+n]...)
+	r.pos += n
+	return b
+}
+
+func (r *packetReader) readString() string {
+	return string(r.readBytes())
+}
@@ -0,0 +1,89 @@
+package mqtt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPacketEncodeDecode(t *testing.T) {
+	packets := []Packet{
+		&ConnectPacket{ProtocolName: PROTOCOL_NAME, ProtocolLevel: PROTOCOL_LEVEL, CleanSession: true, KeepAlive: 60, ClientId: "device-1",
+			WillFlag: true, WillQos: 1, WillRetain: true, WillTopic: "devices/1/status", WillMessage: []byte("offline"),
+			UsernameFlag: true, Username: "user", PasswordFlag: true, Password: []byte("secret")},
+		&ConnackPacket{SessionPresent: true, ReturnCode: CONNACK_NOT_AUTHORIZED},
+		&PublishPacket{Qos: 1, Dup: true, Retain: true, TopicName: "devices/1/telemetry", PacketId: 7, Payload: []byte("23.5")},
+		&PublishPacket{TopicName: "devices/1/telemetry"},
+		&PubackPacket{PacketId: 7},
+		&PubrecPacket{PacketId: 10},
+		&PubrelPacket{PacketId: 10},
+		&PubcompPacket{PacketId: 10},
+		&SubscribePacket{PacketId: 8, Subscriptions: []Subscription{{Filter: "devices/+/cmd", Qos: 1}, {Filter: "#", Qos: 0}}},
+		&SubackPacket{PacketId: 8, ReturnCodes: []byte{1, SUBACK_FAILURE}},
+		&UnsubscribePacket{PacketId: 9, Filters: []string{"devices/+/cmd"}},
+		&UnsubackPacket{PacketId: 9},
+		&PingreqPacket{},
+		&PingrespPacket{},
+		&DisconnectPacket{},
+	}
+
+	for _, packet := range packets {
+		buf := packet.Encode()
+		decoded, n, err := DecodePacket(buf, 0)
+		if err != nil {
+			t.Fatalf("decode %s failed: %s", PacketName(packet.Type()), err)
+		}
+		if n != len(buf) {
+			t.Fatalf("decode %s consumed %d bytes, expect %d", PacketName(packet.Type()), n, len(buf))
+		}
+		if !reflect.DeepEqual(decoded, packet) {
+			t.Fatalf("decode %s got %+v, expect %+v", PacketName(packet.Type()), decoded, packet)
+		}
+	}
+}
+
+func TestDecodePartialPacket(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 300) // 剩余长度占用2个字节
+	first := (&PublishPacket{TopicName: "a/b", Payload: payload}).Encode()
+	second := (&PingreqPacket{}).Encode()
+	stream := append(append([]byte(nil), first...), second...)
+
+	for i := 0; i < len(first); i++ {
+		packet, n, err := DecodePacket(stream[:i], 0)
+		if packet != nil || n != 0 || err != nil {
+			t.Fatalf("decode %d of %d bytes should wait for more data", i, len(first))
+		}
+	}
+
+	packet, n, err := DecodePacket(stream, 0)
+	if err != nil || n != len(first) || packet.Type() != PUBLISH {
+		t.Fatalf("decode first packet failed: %v %d %v", packet, n, err)
+	}
+	packet, n, err = DecodePacket(stream[n:], 0)
+	if err != nil || n != len(second) || packet.Type() != PINGREQ {
+		t.Fatalf("decode second packet failed: %v %d %v", packet, n, err)
+	}
+}
+
+func TestDecodeMalformedPacket(t *testing.T) {
+	cases := map[string][]byte{
+		"remaining length":  {PUBLISH << 4, 0xFF, 0xFF, 0xFF, 0xFF, 0x01},
+		"subscribe flags":   {SUBSCRIBE << 4, 5, 0, 1, 0, 1, 'a'},
+		"publish qos 3":     {PUBLISH<<4 | 0x06, 3, 0, 1, 'a'},
+		"truncated topic":   {PUBLISH << 4, 2, 0, 5},
+		"connect reserved":  {CONNECT << 4, 12, 0, 4, 'M', 'Q', 'T', 'T', 4, 0x01, 0, 0, 0, 0},
+		"unsupported type":  {0xF0, 0},
+		"pubrel flags":      {PUBREL << 4, 2, 0, 1},
+		"empty unsubscribe": {UNSUBSCRIBE<<4 | 0x02, 2, 0, 1},
+	}
+	for name, buf := range cases {
+		if _, _, err := DecodePacket(buf, 0); err == nil {
+			t.Fatalf("decode %s should fail", name)
+		}
+	}
+
+	big := (&PublishPacket{TopicName: "a", Payload: make([]byte, 1024)}).Encode()
+	if _, _, err := DecodePacket(big, 512); err == nil {
+		t.Fatalf("decode packet over max size should fail")
+	}
+}
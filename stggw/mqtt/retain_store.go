@@ -0,0 +1,232 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+)
+
+// RetainedMessage MQTT主题上保留的最后一条消息，Payload为空表示清除(墓碑)
+//
+// 注意：多个网关节点并发写入同一主题时以UpdateTime、Owner依次比较确定最新消息
+//
+// Author: agent
+// Since: 2026/10/19
+type RetainedMessage struct {
+	Topic      string `json:"topic"`
+	Qos        byte   `json:"qos"`
+	Payload    []byte `json:"payload,omitempty"`
+	Owner      string `json:"owner"` // 写入保留消息的网关节点
+	UpdateTime int64  `json:"updateTime"`
+}
+
+// NewerThan 判断保留消息是否比另一条新
+func (msg *RetainedMessage) NewerThan(other *RetainedMessage) bool {
+	if other == nil {
+		return true
+	}
+	if msg.UpdateTime != other.UpdateTime {
+		return msg.UpdateTime > other.UpdateTime
+	}
+	return msg.Owner > other.Owner
+}
+
+// Deleted 是否为清除保留消息的墓碑
+func (msg *RetainedMessage) Deleted() bool {
+	return len(msg.Payload) == 0
+}
+
+// RetainStore 保留消息存储，多个网关节点共享
+// Author: agent
+// Since: 2026/10/19
+type RetainStore interface {
+	Start() error
+	Shutdown()
+	Get(topic string) (*RetainedMessage, bool) // 返回最新的保留消息，可能是墓碑
+	Save(msg *RetainedMessage) error
+	Match(filter string) []*RetainedMessage // 主题匹配过滤器的全部保留消息，按主题排序
+	Refresh(beforeMillis int64)             // 重写最近一次写入早于指定时间的保留消息，避免被broker过期删除
+	Prune(beforeMillis int64)               // 清理早于指定时间的墓碑
+}
+
+// retainEntry 保留消息及其最近一次写入会话topic的时间
+type retainEntry struct {
+	msg       *RetainedMessage
+	writeTime int64
+}
+
+// retainTable 按MQTT主题保存最新的保留消息
+type retainTable struct {
+	entries map[string]*retainEntry
+	lock    sync.RWMutex
+}
+
+func newRetainTable() *retainTable {
+	return &retainTable{entries: make(map[string]*retainEntry)}
+}
+
+func (table *retainTable) Get(topic string) (*RetainedMessage, bool) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	entry, ok := table.entries[topic]
+	if !ok {
+		return nil, false
+	}
+	return entry.msg, true
+}
+
+func (table *retainTable) size() int {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	return len(table.entries)
+}
+
+func (table *retainTable) Match(filter string) []*RetainedMessage {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	var messages []*RetainedMessage
+	for topic, entry := range table.entries {
+		if !entry.msg.Deleted() && MatchTopic(filter, topic) {
+			messages = append(messages, entry.msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Topic < messages[j].Topic })
+	return messages
+}
+
+func (table *retainTable) Prune(beforeMillis int64) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	for topic, entry := range table.entries {
+		if entry.msg.Deleted() && entry.msg.UpdateTime < beforeMillis {
+			delete(table.entries, topic)
+		}
+	}
+}
+
+// apply 保留消息比现有消息新时替换，相同消息的重写只更新写入时间
+func (table *retainTable) apply(msg *RetainedMessage, writeTime int64) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	entry, ok := table.entries[msg.Topic]
+	if ok && !msg.NewerThan(entry.msg) {
+		if msg.UpdateTime == entry.msg.UpdateTime && msg.Owner == entry.msg.Owner && writeTime > entry.writeTime {
+			entry.writeTime = writeTime
+		}
+		return
+	}
+	table.entries[msg.Topic] = &retainEntry{msg: msg, writeTime: writeTime}
+}
+
+// memoryRetainStore 进程内保留消息存储，网关重启后丢失
+type memoryRetainStore struct {
+	*retainTable
+}
+
+// NewMemoryRetainStore 创建进程内保留消息存储
+// Author: agent
+// Since: 2026/10/19
+func NewMemoryRetainStore() RetainStore {
+	return &memoryRetainStore{retainTable: newRetainTable()}
+}
+
+func (store *memoryRetainStore) Start() error {
+	return nil
+}
+
+func (store *memoryRetainStore) Shutdown() {
+}
+
+func (store *memoryRetainStore) Save(msg *RetainedMessage) error {
+	store.apply(msg, msg.UpdateTime)
+	return nil
+}
+
+func (store *memoryRetainStore) Refresh(beforeMillis int64) {
+}
+
+// retainRecord 写入保留消息topic的记录，WriteTime为本次写入时间
+type retainRecord struct {
+	RetainedMessage
+	WriteTime int64 `json:"writeTime"`
+}
+
+// topicRetainStore 以smartgo topic保存保留消息，以MQTT主题为key，读取时按主题压缩；
+// 长期未更新的保留消息由任一节点定期重写，保证不会被broker按保留时间删除
+type topicRetainStore struct {
+	*retainTable
+	log *compactedTopic
+}
+
+func newTopicRetainStore(topic string, producer messageProducer, reader messageReader, syncInterval time.Duration) *topicRetainStore {
+	store := &topicRetainStore{retainTable: newRetainTable()}
+	store.log = newCompactedTopic(topic, producer, reader, syncInterval, func(body []byte) error {
+		record := &retainRecord{}
+		if err := json.Unmarshal(body, record); err != nil {
+			return err
+		}
+		store.apply(&record.RetainedMessage, record.WriteTime)
+		return nil
+	})
+	return store
+}
+
+// Start 加载保留消息topic中的全部消息后启动后台同步
+func (store *topicRetainStore) Start() error {
+	if err := store.log.start(); err != nil {
+		return fmt.Errorf("load mqtt retained messages from topic %s failed: %s", store.log.topic, err)
+	}
+	logger.Infof("load %d mqtt retained topics from topic %s", store.size(), store.log.topic)
+	return nil
+}
+
+func (store *topicRetainStore) Shutdown() {
+	store.log.shutdown()
+}
+
+// Save 发送保留消息，发送成功后立即在本地生效
+func (store *topicRetainStore) Save(msg *RetainedMessage) error {
+	writeTime := timeutil.CurrentTimeMillis()
+	if err := store.write(msg, writeTime); err != nil {
+		return err
+	}
+	store.apply(msg, writeTime)
+	return nil
+}
+
+func (store *topicRetainStore) write(msg *RetainedMessage, writeTime int64) error {
+	body, err := json.Marshal(&retainRecord{RetainedMessage: *msg, WriteTime: writeTime})
+	if err != nil {
+		return err
+	}
+	return store.log.send(msg.Topic, body)
+}
+
+// Refresh 重写长期未写入的保留消息，其他节点同步到重写记录后不再重复重写
+func (store *topicRetainStore) Refresh(beforeMillis int64) {
+	store.lock.RLock()
+	var messages []*RetainedMessage
+	for _, entry := range store.entries {
+		if !entry.msg.Deleted() && entry.writeTime < beforeMillis {
+			messages = append(messages, entry.msg)
+		}
+	}
+	store.lock.RUnlock()
+
+	for _, msg := range messages {
+		writeTime := timeutil.CurrentTimeMillis()
+		if err := store.write(msg, writeTime); err != nil {
+			logger.Warnf("refresh mqtt retained message of %s failed: %s", msg.Topic, err)
+			return
+		}
+		store.apply(msg, writeTime)
+	}
+	if len(messages) > 0 {
+		logger.Infof("refresh %d mqtt retained messages", len(messages))
+	}
+}
@@ -0,0 +1,218 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+)
+
+func expectNoMessage(t *testing.T, client *Client) {
+	select {
+	case p := <-client.Messages():
+		t.Fatalf("unexpected message %+v", p)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestRetainedMessages(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gateway.Shutdown()
+
+	device := dialTestClient(t, addr, "device-1", 60)
+	defer device.Disconnect()
+	if err := device.PublishRetain("devices/1/status", 1, []byte("online")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := broker.lastSent(); msg.GetProperty(message.PROPERTY_MQTT_RETAIN) != "true" || string(msg.Body) != "online" {
+		t.Fatalf("unexpected smartgo message %+v", msg)
+	}
+	device.PublishRetain("devices/2/status", 0, []byte("offline"))
+	device.PublishRetain("$SYS/gateway/status", 1, []byte("running"))
+
+	// 订阅时按主题顺序下发匹配的保留消息，QoS取较小值，通配符不匹配$开头的主题
+	dashboard := dialTestClient(t, addr, "dashboard", 60)
+	defer dashboard.Disconnect()
+	if _, err := dashboard.Subscribe("devices/+/status", 1); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, dashboard); !p.Retain || p.TopicName != "devices/1/status" || string(p.Payload) != "online" || p.Qos != 1 {
+		t.Fatalf("unexpected retained message %+v", p)
+	}
+	if p := receive(t, dashboard); !p.Retain || p.TopicName != "devices/2/status" || string(p.Payload) != "offline" || p.Qos != 0 {
+		t.Fatalf("unexpected retained message %+v", p)
+	}
+	if _, err := dashboard.Subscribe("#", 1); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, dashboard)
+	receive(t, dashboard)
+	expectNoMessage(t, dashboard)
+
+	// 已订阅的会话收到的实时消息不带retain标记
+	device.PublishRetain("devices/1/status", 1, []byte("busy"))
+	if p := receive(t, dashboard); p.Retain || string(p.Payload) != "busy" {
+		t.Fatalf("unexpected live message %+v", p)
+	}
+
+	// 空消息清除保留消息，且不写入smartgo
+	sent := broker.count("DeviceCommand")
+	if err := device.PublishRetain("devices/2/status", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if count := broker.count("DeviceCommand"); count != sent {
+		t.Fatalf("empty retained message should not be sent to smartgo")
+	}
+	late := dialTestClient(t, addr, "late-dashboard", 60)
+	defer late.Disconnect()
+	late.Subscribe("devices/#", 2)
+	if p := receive(t, late); p.TopicName != "devices/1/status" || string(p.Payload) != "busy" {
+		t.Fatalf("unexpected retained message %+v", p)
+	}
+	expectNoMessage(t, late)
+}
+
+func TestRetainedMessagesSharedAcrossGateways(t *testing.T) {
+	broker := newFakeBroker()
+	gatewayA, addrA := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gatewayA.Shutdown()
+	gatewayB, addrB := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+
+	device := dialTestClient(t, addrA, "device-1", 60)
+	defer device.Disconnect()
+	device.PublishRetain("devices/1/status", 1, []byte("online"))
+	waitFor(t, "retained message synced to gateway B", func() bool {
+		_, ok := gatewayB.retain.Get("devices/1/status")
+		return ok
+	})
+	dashboard := dialTestClient(t, addrB, "dashboard", 60)
+	defer dashboard.Disconnect()
+	dashboard.Subscribe("devices/1/status", 1)
+	if p := receive(t, dashboard); !p.Retain || string(p.Payload) != "online" {
+		t.Fatalf("unexpected retained message %+v", p)
+	}
+
+	// 新启动的节点从保留消息topic加载
+	gatewayC, _ := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gatewayC.Shutdown()
+	if msg, ok := gatewayC.retain.Get("devices/1/status"); !ok || string(msg.Payload) != "online" {
+		t.Fatalf("retained message should be loaded on start, got %+v", msg)
+	}
+}
+
+func TestWillMessage(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gateway.Shutdown()
+
+	watcher := dialTestClient(t, addr, "watcher", 60)
+	defer watcher.Disconnect()
+	watcher.Subscribe("devices/+/status", 1)
+
+	will := func(clientId string, keepAlive uint16) *ConnectPacket {
+		return &ConnectPacket{CleanSession: true, ClientId: clientId, KeepAlive: keepAlive,
+			WillFlag: true, WillQos: 1, WillRetain: true, WillTopic: "devices/" + clientId + "/status", WillMessage: []byte("offline")}
+	}
+
+	// 正常DISCONNECT不发布遗嘱
+	device, err := DialClient(addr, will("d1", 60), 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.Disconnect()
+	expectNoMessage(t, watcher)
+
+	// 被相同clientId的新连接接管时不发布遗嘱
+	device, _ = DialClient(addr, will("d1", 60), 3*time.Second)
+	newer, _ := DialClient(addr, will("d1", 60), 3*time.Second)
+	<-device.Done()
+	expectNoMessage(t, watcher)
+	newer.Disconnect()
+
+	// 网络断开时发布遗嘱，遗嘱消息同时作为保留消息
+	conn := dialRaw(t, addr, will("d2", 60))
+	readPackets(t, conn, 1)
+	conn.Close()
+	if p := receive(t, watcher); p.TopicName != "devices/d2/status" || string(p.Payload) != "offline" || p.Qos != 1 {
+		t.Fatalf("unexpected will message %+v", p)
+	}
+	if msg, ok := gateway.retain.Get("devices/d2/status"); !ok || string(msg.Payload) != "offline" {
+		t.Fatalf("will message should be retained, got %+v", msg)
+	}
+	if msg := broker.lastSent(); msg.GetProperty(message.PROPERTY_MQTT_CLIENT_ID) != "d2" || msg.GetProperty(message.PROPERTY_MQTT_RETAIN) != "true" {
+		t.Fatalf("unexpected smartgo message %+v", msg)
+	}
+
+	// keep-alive超时发布遗嘱
+	conn = dialRaw(t, addr, will("d3", 1))
+	defer conn.Close()
+	readPackets(t, conn, 1)
+	if p := receive(t, watcher); p.TopicName != "devices/d3/status" {
+		t.Fatalf("unexpected will message %+v", p)
+	}
+
+	// 遗嘱主题不合法时拒绝连接
+	invalid := will("d4", 60)
+	invalid.WillTopic = "devices/+/status"
+	conn = dialRaw(t, addr, invalid)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("connection with invalid will topic should be closed")
+	}
+}
+
+func TestTopicRetainStoreRefresh(t *testing.T) {
+	broker := newFakeBroker()
+	store1 := newTopicRetainStore("MQTT_RETAIN", broker, broker, 10*time.Millisecond)
+	store2 := newTopicRetainStore("MQTT_RETAIN", broker, broker, 10*time.Millisecond)
+	for _, store := range []*topicRetainStore{store1, store2} {
+		if err := store.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer store.Shutdown()
+	}
+
+	msg := &RetainedMessage{Topic: "devices/1/status", Qos: 1, Payload: []byte("online"), Owner: "a", UpdateTime: 100}
+	store1.Save(msg)
+	store1.Save(&RetainedMessage{Topic: "devices/1/status", Payload: []byte("stale"), Owner: "b", UpdateTime: 99})
+	waitFor(t, "retained message synced", func() bool {
+		_, ok := store2.Get("devices/1/status")
+		return ok
+	})
+	if current, _ := store2.Get("devices/1/status"); string(current.Payload) != "online" {
+		t.Fatalf("older retained message should be ignored, got %+v", current)
+	}
+
+	// 重写后其他节点同步到新的写入时间，不再重复重写
+	time.Sleep(5 * time.Millisecond)
+	before := timeutil.CurrentTimeMillis()
+	store1.Refresh(before)
+	if count := broker.count("MQTT_RETAIN"); count != 3 {
+		t.Fatalf("expect 3 records after refresh, got %d", count)
+	}
+	waitFor(t, "refresh synced", func() bool {
+		store2.lock.RLock()
+		defer store2.lock.RUnlock()
+		return store2.entries["devices/1/status"].writeTime >= before
+	})
+	store2.Refresh(before)
+	if count := broker.count("MQTT_RETAIN"); count != 3 {
+		t.Fatalf("refreshed message should not be written again, got %d records", count)
+	}
+	if current, _ := store2.Get("devices/1/status"); current.UpdateTime != 100 {
+		t.Fatalf("refresh should keep update time, got %+v", current)
+	}
+
+	store1.Save(&RetainedMessage{Topic: "devices/1/status", Owner: "a", UpdateTime: 200})
+	store1.Prune(200)
+	if _, ok := store1.Get("devices/1/status"); !ok {
+		t.Fatalf("tombstone should be kept until expiry")
+	}
+	store1.Prune(201)
+	if _, ok := store1.Get("devices/1/status"); ok || len(store1.Match("#")) != 0 {
+		t.Fatalf("tombstone should be pruned")
+	}
+}
@@ -0,0 +1,587 @@
+package mqtt
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+)
+
+// inflightMessage 已下发、等待确认的QoS1/QoS2消息，排队中的消息尚未分配报文标识
+type inflightMessage struct {
+	packet   *PublishPacket
+	source   *QueueOffset // 消息在smartgo队列中的位置，快照中只保存该位置
+	released bool         // QoS2已收到PUBREC并发出PUBREL，等待PUBCOMP
+	sendTime time.Time
+	seq      uint64 // 下发顺序，恢复会话时按此顺序重发
+}
+
+// replayPending 补发离线消息期间到达的实时消息
+type replayPending struct {
+	packet *PublishPacket
+	mq     *message.MessageQueue
+	offset int64
+}
+
+// 会话的接入方式
+const (
+	TRANSPORT_TCP       = "tcp"
+	TRANSPORT_WEBSOCKET = "websocket"
+)
+
+// Session 一个MQTT连接对应的会话；clean session=false时为持久会话，断开后状态保存在会话存储中
+// Author: agent
+// Since: 2026/10/19
+type Session struct {
+	gateway    *MqttGateway
+	ctx        netm.Context
+	transport  string // 接入方式：tcp或websocket
+	createTime time.Time
+	lastActive int64 // 最近一次收到报文的时间(纳秒)
+	buffer     []byte
+
+	clientId   string
+	keepAlive  time.Duration
+	connected  bool
+	persistent bool
+	will       *PublishPacket // 遗嘱消息，连接非正常关闭时发布，收到DISCONNECT时清除
+
+	subscriptions map[string]byte // 主题过滤器 -> 授予的QoS
+	inflight      map[uint16]*inflightMessage
+	queue         []*inflightMessage
+	received      map[uint16]bool // 上行QoS2已发出PUBREC、等待PUBREL的报文标识
+	nextPacketId  uint16
+	sendSeq       uint64
+	closed        bool
+
+	replaying    bool // 正在补发离线消息，实时消息暂存于replayBuffer
+	replayBuffer []*replayPending
+	replayFrom   []*QueueOffset // 补发的起点，补发完成前断开时仍从该位置补发
+
+	savedState   *SessionState // 最近一次写入会话存储的快照
+	changes      uint64        // 影响快照的状态变化次数
+	savedChanges uint64        // 最近一次写入的快照对应的变化次数
+	takenOver    bool          // 已被其他连接接管或被踢出，断开时不再写入快照
+
+	lock      sync.Mutex
+	writeLock sync.Mutex
+}
+
+func newSession(gateway *MqttGateway, ctx netm.Context) *Session {
+	now := time.Now()
+	return &Session{
+		gateway:       gateway,
+		ctx:           ctx,
+		transport:     gateway.transportOf(ctx),
+		createTime:    now,
+		lastActive:    now.UnixNano(),
+		subscriptions: make(map[string]byte),
+		inflight:      make(map[uint16]*inflightMessage),
+		received:      make(map[uint16]bool),
+	}
+}
+
+// ClientId MQTT客户端ID，CONNECT之前为空
+func (session *Session) ClientId() string {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return session.clientId
+}
+
+// Addr 客户端连接地址
+func (session *Session) Addr() string {
+	return session.ctx.Addr()
+}
+
+func (session *Session) touch() {
+	atomic.StoreInt64(&session.lastActive, time.Now().UnixNano())
+}
+
+func (session *Session) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, atomic.LoadInt64(&session.lastActive)))
+}
+
+func (session *Session) isConnected() bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return session.connected
+}
+
+func (session *Session) isPersistent() bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return session.persistent
+}
+
+// write 发送报文，多个协程可能同时向同一连接写入
+func (session *Session) write(packet Packet) error {
+	session.writeLock.Lock()
+	defer session.writeLock.Unlock()
+
+	if session.ctx.IsClosed() {
+		return nil
+	}
+	_, err := session.ctx.Write(packet.Encode())
+	if err != nil {
+		logger.Warnf("mqtt write %s to %s failed: %s", PacketName(packet.Type()), session.ctx.Addr(), err)
+	}
+	return err
+}
+
+// close 关闭连接，会话的清理在连接关闭回调中完成
+func (session *Session) close() {
+	session.ctx.Close()
+}
+
+// takeOver 会话被其他节点接管或被踢出，关闭连接且不再写入快照
+func (session *Session) takeOver() {
+	session.lock.Lock()
+	session.takenOver = true
+	session.lock.Unlock()
+	session.close()
+}
+
+// clearWill 正常断开，不再发布遗嘱消息
+func (session *Session) clearWill() {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	session.will = nil
+}
+
+// addSubscription 记录订阅，重复订阅时覆盖QoS
+func (session *Session) addSubscription(filter string, qos byte) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	session.subscriptions[filter] = qos
+}
+
+// removeSubscription 删除订阅，返回该过滤器此前是否已订阅
+func (session *Session) removeSubscription(filter string) bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	_, ok := session.subscriptions[filter]
+	delete(session.subscriptions, filter)
+	return ok
+}
+
+// matchQos 会话订阅中匹配主题的最大QoS
+func (session *Session) matchQos(topic string) (byte, bool) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	var qos byte
+	matched := false
+	for filter, subQos := range session.subscriptions {
+		if MatchTopic(filter, topic) && (!matched || subQos > qos) {
+			qos, matched = subQos, true
+		}
+	}
+	return qos, matched
+}
+
+// deliver 下发实时消息，补发离线消息期间先暂存，补发完成后按offset去重再下发
+// Author: agent
+// Since: 2026/10/19
+func (session *Session) deliver(packet *PublishPacket, mq *message.MessageQueue, offset int64) {
+	session.lock.Lock()
+	if session.replaying {
+		session.replayBuffer = append(session.replayBuffer, &replayPending{packet: packet, mq: mq, offset: offset})
+		if len(session.replayBuffer) > session.gateway.config.MaxQueuedMessages {
+			dropped := session.replayBuffer[0]
+			session.replayBuffer = session.replayBuffer[1:]
+			logger.Warnf("mqtt session %s replay buffer full, drop message of topic %s", session.clientId, dropped.packet.TopicName)
+		}
+		session.lock.Unlock()
+		return
+	}
+	session.lock.Unlock()
+
+	session.enqueue(packet, messageSource(mq, offset))
+}
+
+// messageSource 消息在smartgo队列中的位置，mq为nil表示消息不来自队列
+func messageSource(mq *message.MessageQueue, offset int64) *QueueOffset {
+	if mq == nil {
+		return nil
+	}
+	return &QueueOffset{Topic: mq.Topic, BrokerName: mq.BrokerName, QueueId: mq.QueueId, Offset: offset}
+}
+
+// finishReplay 离线消息补发完成，下发暂存的实时消息，ends为各队列补发的截止位置
+func (session *Session) finishReplay(ends map[string]int64) {
+	session.lock.Lock()
+	pending := session.replayBuffer
+	session.replayBuffer = nil
+	session.replaying = false
+	session.replayFrom = nil
+	session.lock.Unlock()
+
+	for _, p := range pending {
+		if p.mq != nil {
+			if end, ok := ends[queueKey(p.mq)]; ok && p.offset < end {
+				continue
+			}
+		}
+		session.enqueue(p.packet, messageSource(p.mq, p.offset))
+	}
+}
+
+// enqueue 下发消息：QoS0直接写出，QoS1/QoS2进入inflight窗口，窗口已满时排队；source为消息在smartgo队列中的位置
+// Author: agent
+// Since: 2026/10/19
+func (session *Session) enqueue(packet *PublishPacket, source *QueueOffset) {
+	if packet.Qos == 0 {
+		session.write(packet)
+		return
+	}
+
+	session.lock.Lock()
+	if session.closed {
+		session.lock.Unlock()
+		return
+	}
+	session.changes++
+	msg := &inflightMessage{packet: packet, source: source}
+	if len(session.inflight) >= session.gateway.config.MaxInflight {
+		session.queue = append(session.queue, msg)
+		if len(session.queue) > session.gateway.config.MaxQueuedMessages {
+			dropped := session.queue[0]
+			session.queue = session.queue[1:]
+			logger.Warnf("mqtt session %s queue full, drop message of topic %s", session.clientId, dropped.packet.TopicName)
+		}
+		session.lock.Unlock()
+		return
+	}
+	session.addInflight(msg)
+	session.lock.Unlock()
+
+	session.write(packet)
+}
+
+// addInflight 分配报文标识并放入inflight窗口，调用方需持有session.lock
+func (session *Session) addInflight(msg *inflightMessage) {
+	for {
+		session.nextPacketId++
+		if session.nextPacketId == 0 {
+			session.nextPacketId = 1
+		}
+		if _, ok := session.inflight[session.nextPacketId]; !ok {
+			break
+		}
+	}
+	msg.packet.PacketId = session.nextPacketId
+	msg.sendTime = time.Now()
+	session.sendSeq++
+	msg.seq = session.sendSeq
+	session.inflight[msg.packet.PacketId] = msg
+}
+
+// onPuback 客户端确认QoS1消息
+func (session *Session) onPuback(packetId uint16) {
+	session.complete(packetId, 1)
+}
+
+// onPubrec 客户端收到QoS2消息，回复PUBREL
+func (session *Session) onPubrec(packetId uint16) {
+	session.lock.Lock()
+	if msg, ok := session.inflight[packetId]; ok && msg.packet.Qos == 2 {
+		msg.released = true
+		msg.sendTime = time.Now()
+		session.changes++
+	}
+	session.lock.Unlock()
+
+	session.write(&PubrelPacket{PacketId: packetId})
+}
+
+// onPubcomp 客户端完成QoS2消息
+func (session *Session) onPubcomp(packetId uint16) {
+	session.complete(packetId, 2)
+}
+
+// complete 释放inflight窗口并下发排队的消息
+// Author: agent
+// Since: 2026/10/19
+func (session *Session) complete(packetId uint16, qos byte) {
+	session.lock.Lock()
+	msg, ok := session.inflight[packetId]
+	if !ok || msg.packet.Qos != qos {
+		session.lock.Unlock()
+		return
+	}
+	delete(session.inflight, packetId)
+	session.changes++
+
+	var next *inflightMessage
+	if len(session.queue) > 0 && !session.closed {
+		next = session.queue[0]
+		session.queue[0] = nil
+		session.queue = session.queue[1:]
+		session.addInflight(next)
+	}
+	session.lock.Unlock()
+
+	if next != nil {
+		session.write(next.packet)
+	}
+}
+
+// isNewQos2 上行QoS2报文是否为新报文，false表示重复报文(已写入smartgo，只需再次回复PUBREC)
+func (session *Session) isNewQos2(packetId uint16) bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return !session.received[packetId]
+}
+
+// markReceived 上行QoS2报文已写入smartgo，等待PUBREL
+func (session *Session) markReceived(packetId uint16) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	session.received[packetId] = true
+	session.changes++
+}
+
+// onPubrel 客户端释放上行QoS2报文，回复PUBCOMP
+func (session *Session) onPubrel(packetId uint16) {
+	session.lock.Lock()
+	if session.received[packetId] {
+		delete(session.received, packetId)
+		session.changes++
+	}
+	session.lock.Unlock()
+
+	session.write(&PubcompPacket{PacketId: packetId})
+}
+
+// retryInflight 重发超过重发间隔仍未确认的消息：未收到PUBREC/PUBACK时重发PUBLISH，否则重发PUBREL
+// Author: agent
+// Since: 2026/10/19
+func (session *Session) retryInflight(now time.Time, interval time.Duration) {
+	var packets []Packet
+	session.lock.Lock()
+	for _, msg := range session.inflight {
+		if now.Sub(msg.sendTime) < interval {
+			continue
+		}
+		msg.sendTime = now
+		packets = append(packets, session.retryPacket(msg))
+	}
+	session.lock.Unlock()
+
+	for _, packet := range packets {
+		session.write(packet)
+	}
+}
+
+// resendInflight 恢复持久会话后按原下发顺序重发全部未确认的消息
+func (session *Session) resendInflight() {
+	session.lock.Lock()
+	messages := make([]*inflightMessage, 0, len(session.inflight))
+	for _, msg := range session.inflight {
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].seq < messages[j].seq })
+
+	now := time.Now()
+	packets := make([]Packet, 0, len(messages))
+	for _, msg := range messages {
+		msg.sendTime = now
+		packets = append(packets, session.retryPacket(msg))
+	}
+	session.lock.Unlock()
+
+	for _, packet := range packets {
+		session.write(packet)
+	}
+}
+
+// retryPacket 生成重发报文，调用方需持有session.lock
+func (session *Session) retryPacket(msg *inflightMessage) Packet {
+	if msg.released {
+		return &PubrelPacket{PacketId: msg.packet.PacketId}
+	}
+	dup := *msg.packet
+	dup.Dup = true
+	msg.packet = &dup
+	return &dup
+}
+
+// snapshot 生成会话快照，同时返回快照对应的变化次数
+// Author: agent
+// Since: 2026/10/19
+func (session *Session) snapshot(owner string, connected bool, offsets []*QueueOffset) (*SessionState, uint64) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	state := &SessionState{
+		ClientId:      session.clientId,
+		Owner:         owner,
+		UpdateTime:    now,
+		Connected:     connected,
+		Subscriptions: make(map[string]byte, len(session.subscriptions)),
+		Offsets:       offsets,
+		NextPacketId:  session.nextPacketId,
+	}
+	if session.savedState != nil {
+		state.Version = session.savedState.Version + 1
+	}
+	if !connected {
+		state.DisconnectTime = now
+	}
+	for filter, qos := range session.subscriptions {
+		state.Subscriptions[filter] = qos
+	}
+
+	messages := make([]*inflightMessage, 0, len(session.inflight))
+	for _, msg := range session.inflight {
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].seq < messages[j].seq })
+	for _, msg := range messages {
+		state.Inflight = append(state.Inflight, &InflightState{
+			PacketId: msg.packet.PacketId,
+			Qos:      msg.packet.Qos,
+			Topic:    msg.packet.TopicName,
+			Payload:  msg.packet.Payload,
+			Source:   msg.source,
+			Retain:   msg.packet.Retain,
+			Released: msg.released,
+		})
+	}
+	for _, msg := range session.queue {
+		packet := msg.packet
+		state.Inflight = append(state.Inflight, &InflightState{Qos: packet.Qos, Topic: packet.TopicName, Payload: packet.Payload, Source: msg.source, Retain: packet.Retain})
+	}
+	for packetId := range session.received {
+		state.Received = append(state.Received, packetId)
+	}
+	sort.Slice(state.Received, func(i, j int) bool { return state.Received[i] < state.Received[j] })
+	return state, session.changes
+}
+
+// saved 快照写入成功
+func (session *Session) saved(state *SessionState, changes uint64) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	if session.savedState == nil || state.NewerThan(session.savedState) {
+		session.savedState = state
+	}
+	if changes > session.savedChanges {
+		session.savedChanges = changes
+	}
+}
+
+// isDirty 最近一次写入快照后状态是否有变化
+func (session *Session) isDirty() bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return session.changes != session.savedChanges
+}
+
+// lastSaved 最近一次写入的快照，用于判断其他节点的快照是否更新
+func (session *Session) lastSaved() *SessionState {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return session.savedState
+}
+
+// restore 从快照恢复订阅、未确认消息及上行QoS2状态，并进入离线消息补发状态
+// Author: agent
+// Since: 2026/10/19
+func (session *Session) restore(state *SessionState) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	for filter, qos := range state.Subscriptions {
+		session.subscriptions[filter] = qos
+	}
+	session.nextPacketId = state.NextPacketId
+	for _, inflight := range state.Inflight {
+		packet := &PublishPacket{Qos: inflight.Qos, Retain: inflight.Retain, TopicName: inflight.Topic, PacketId: inflight.PacketId, Payload: inflight.Payload}
+		msg := &inflightMessage{packet: packet, source: inflight.Source, released: inflight.Released}
+		if inflight.PacketId == 0 {
+			session.queue = append(session.queue, msg)
+			continue
+		}
+		session.sendSeq++
+		msg.seq = session.sendSeq
+		session.inflight[inflight.PacketId] = msg
+	}
+	for _, packetId := range state.Received {
+		session.received[packetId] = true
+	}
+	session.savedState = state
+	session.replaying = true
+	session.replayFrom = state.Offsets
+}
+
+// replayOffsets 正在补发离线消息时返回补发起点
+func (session *Session) replayOffsets() ([]*QueueOffset, bool) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return session.replayFrom, session.replaying
+}
+
+// drainQueue 窗口有空闲时下发排队的消息，恢复会话后调用
+func (session *Session) drainQueue() {
+	var packets []*PublishPacket
+	session.lock.Lock()
+	for len(session.queue) > 0 && len(session.inflight) < session.gateway.config.MaxInflight {
+		next := session.queue[0]
+		session.queue[0] = nil
+		session.queue = session.queue[1:]
+		session.addInflight(next)
+		packets = append(packets, next.packet)
+	}
+	session.lock.Unlock()
+
+	for _, packet := range packets {
+		session.write(packet)
+	}
+}
+
+// cleanup 连接关闭后释放会话状态，返回会话的全部订阅
+func (session *Session) cleanup() []string {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	session.closed = true
+	filters := make([]string, 0, len(session.subscriptions))
+	for filter := range session.subscriptions {
+		filters = append(filters, filter)
+	}
+	session.subscriptions = make(map[string]byte)
+	session.inflight = make(map[uint16]*inflightMessage)
+	session.queue = nil
+	session.replayBuffer = nil
+	return filters
+}
+
+// view 会话概要
+func (session *Session) view(owner string) *SessionView {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	view := &SessionView{
+		ClientId:      session.clientId,
+		Owner:         owner,
+		Online:        true,
+		Persistent:    session.persistent,
+		RemoteAddr:    session.ctx.Addr(),
+		Transport:     session.transport,
+		Subscriptions: make([]string, 0, len(session.subscriptions)),
+		Inflight:      len(session.inflight) + len(session.queue),
+	}
+	for filter := range session.subscriptions {
+		view.Subscriptions = append(view.Subscriptions, filter)
+	}
+	sort.Strings(view.Subscriptions)
+	if session.savedState != nil {
+		view.UpdateTime = session.savedState.UpdateTime
+	}
+	return view
+}
@@ -0,0 +1,174 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+// QueueOffset 会话在某个smartgo队列上的投递位置，离线期间的消息从该位置补发
+// Author: agent
+// Since: 2026/10/19
+type QueueOffset struct {
+	Topic      string `json:"topic"`
+	BrokerName string `json:"brokerName"`
+	QueueId    int    `json:"queueId"`
+	Offset     int64  `json:"offset"`
+}
+
+// MessageQueue 转换为消息队列
+func (qo *QueueOffset) MessageQueue() *message.MessageQueue {
+	return &message.MessageQueue{Topic: qo.Topic, BrokerName: qo.BrokerName, QueueId: qo.QueueId}
+}
+
+func queueKey(mq *message.MessageQueue) string {
+	return fmt.Sprintf("%s@%s@%d", mq.Topic, mq.BrokerName, mq.QueueId)
+}
+
+// InflightState 未完成确认的下行消息；来自smartgo队列的消息写入会话存储时只保存Source，恢复会话时按Source重新读取消息内容
+// Author: agent
+// Since: 2026/10/19
+type InflightState struct {
+	PacketId uint16       `json:"packetId"` // 0表示排队中尚未下发
+	Qos      byte         `json:"qos"`
+	Topic    string       `json:"topic"`
+	Payload  []byte       `json:"payload,omitempty"`
+	Source   *QueueOffset `json:"source,omitempty"` // 消息在smartgo队列中的位置，保留消息等不来自队列的消息为nil
+	Retain   bool         `json:"retain,omitempty"` // 订阅时下发的保留消息
+	Released bool         `json:"released"`         // QoS2已收到PUBREC并发出PUBREL，等待PUBCOMP
+}
+
+// SessionState 持久会话快照，clean session=false的会话在订阅变更、定时checkpoint及断开时写入会话存储
+//
+// 注意：Version每次写入递增，多个网关节点并发写入时以Version、UpdateTime、Owner依次比较确定最新快照
+//
+// Author: agent
+// Since: 2026/10/19
+type SessionState struct {
+	ClientId       string           `json:"clientId"`
+	Owner          string           `json:"owner"`   // 最近一次写入快照的网关节点
+	Version        int64            `json:"version"` // 快照版本
+	UpdateTime     int64            `json:"updateTime"`
+	Connected      bool             `json:"connected"`
+	DisconnectTime int64            `json:"disconnectTime,omitempty"`
+	Deleted        bool             `json:"deleted,omitempty"` // 墓碑：会话已过期、被清除或以clean session重连
+	Subscriptions  map[string]byte  `json:"subscriptions,omitempty"`
+	Inflight       []*InflightState `json:"inflight,omitempty"`
+	Received       []uint16         `json:"received,omitempty"` // 上行QoS2已发出PUBREC、等待PUBREL的报文标识
+	Offsets        []*QueueOffset   `json:"offsets,omitempty"`
+	NextPacketId   uint16           `json:"nextPacketId"`
+}
+
+// NewerThan 判断快照是否比另一个快照新
+// Author: agent
+// Since: 2026/10/19
+func (state *SessionState) NewerThan(other *SessionState) bool {
+	if other == nil {
+		return true
+	}
+	if state.Version != other.Version {
+		return state.Version > other.Version
+	}
+	if state.UpdateTime != other.UpdateTime {
+		return state.UpdateTime > other.UpdateTime
+	}
+	return state.Owner > other.Owner
+}
+
+// Expired 离线会话是否已过期；持有节点异常退出时会话仍标记为在线，超过staleMillis未更新同样视为离线
+// Author: agent
+// Since: 2026/10/19
+func (state *SessionState) Expired(nowMillis, expiryMillis, staleMillis int64) bool {
+	if state.Deleted {
+		return false
+	}
+	if state.Connected {
+		return nowMillis-state.UpdateTime > staleMillis+expiryMillis
+	}
+	return nowMillis-state.DisconnectTime > expiryMillis
+}
+
+// SessionView 会话概要，用于管理接口输出
+type SessionView struct {
+	ClientId       string   `json:"clientId"`
+	Owner          string   `json:"owner"`
+	Online         bool     `json:"online"`
+	Persistent     bool     `json:"persistent"`
+	RemoteAddr     string   `json:"remoteAddr,omitempty"`
+	Transport      string   `json:"transport,omitempty"`
+	Subscriptions  []string `json:"subscriptions"`
+	Inflight       int      `json:"inflight"`
+	UpdateTime     int64    `json:"updateTime,omitempty"`
+	DisconnectTime int64    `json:"disconnectTime,omitempty"`
+}
+
+// maxSessionStateSize 编码后快照的大小上限，须小于producer的最大消息大小(128K)
+const maxSessionStateSize = 1024 * 100
+
+// encodeSessionState 编码会话快照：来自smartgo队列的消息只保存队列位置，
+// 编码后仍超过大小上限时依次丢弃最早排队的消息、最早下发的消息
+// Author: agent
+// Since: 2026/10/19
+func encodeSessionState(state *SessionState) ([]byte, error) {
+	encoded := *state
+	encoded.Inflight = make([]*InflightState, 0, len(state.Inflight))
+	for _, inflight := range state.Inflight {
+		if inflight.Source != nil && inflight.Payload != nil {
+			stripped := *inflight
+			stripped.Payload = nil
+			inflight = &stripped
+		}
+		encoded.Inflight = append(encoded.Inflight, inflight)
+	}
+
+	body, err := json.Marshal(&encoded)
+	if err != nil || len(body) <= maxSessionStateSize {
+		return body, err
+	}
+
+	// 按单条消息编码后的大小估算需要丢弃的消息，排队中的消息优先丢弃
+	size := len(body)
+	dropped := make(map[*InflightState]bool)
+	for _, queued := range []bool{true, false} {
+		for _, inflight := range encoded.Inflight {
+			if size <= maxSessionStateSize {
+				break
+			}
+			if (inflight.PacketId == 0) != queued {
+				continue
+			}
+			buf, err := json.Marshal(inflight)
+			if err != nil {
+				return nil, err
+			}
+			size -= len(buf) + 1
+			dropped[inflight] = true
+		}
+	}
+	kept := make([]*InflightState, 0, len(encoded.Inflight)-len(dropped))
+	for _, inflight := range encoded.Inflight {
+		if !dropped[inflight] {
+			kept = append(kept, inflight)
+		}
+	}
+	logger.Warnf("mqtt session %s state too large (%d bytes), drop %d undelivered messages", state.ClientId, len(body), len(dropped))
+	encoded.Inflight = kept
+
+	if body, err = json.Marshal(&encoded); err == nil && len(body) > maxSessionStateSize {
+		return nil, fmt.Errorf("mqtt session %s state too large: %d bytes", state.ClientId, len(body))
+	}
+	return body, err
+}
+
+func decodeSessionState(body []byte) (*SessionState, error) {
+	state := &SessionState{}
+	if err := json.Unmarshal(body, state); err != nil {
+		return nil, err
+	}
+	if state.ClientId == "" {
+		return nil, fmt.Errorf("session state without clientId")
+	}
+	return state, nil
+}
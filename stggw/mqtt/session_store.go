@@ -0,0 +1,245 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+)
+
+// SessionStore 持久会话存储，多个网关节点共享同一份会话快照
+// Author: agent
+// Since: 2026/10/19
+type SessionStore interface {
+	Start() error
+	Shutdown()
+	Get(clientId string) (*SessionState, bool) // 返回最新快照，可能是墓碑
+	Save(state *SessionState) error
+	List() []*SessionState                          // 全部未删除的快照
+	AddListener(listener func(state *SessionState)) // 快照被更新(含其他节点写入)时回调
+	Prune(beforeMillis int64)                       // 清理早于指定时间的墓碑
+}
+
+// sessionTable 按clientId保存最新快照，新快照覆盖旧快照
+type sessionTable struct {
+	states    map[string]*SessionState
+	listeners []func(state *SessionState)
+	lock      sync.RWMutex
+}
+
+func newSessionTable() *sessionTable {
+	return &sessionTable{states: make(map[string]*SessionState)}
+}
+
+func (table *sessionTable) Get(clientId string) (*SessionState, bool) {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	state, ok := table.states[clientId]
+	return state, ok
+}
+
+func (table *sessionTable) List() []*SessionState {
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	states := make([]*SessionState, 0, len(table.states))
+	for _, state := range table.states {
+		if !state.Deleted {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+func (table *sessionTable) AddListener(listener func(state *SessionState)) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	table.listeners = append(table.listeners, listener)
+}
+
+func (table *sessionTable) Prune(beforeMillis int64) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	for clientId, state := range table.states {
+		if state.Deleted && state.UpdateTime < beforeMillis {
+			delete(table.states, clientId)
+		}
+	}
+}
+
+// apply 快照比现有快照新时替换并通知监听者，返回是否替换
+func (table *sessionTable) apply(state *SessionState) bool {
+	table.lock.Lock()
+	if old, ok := table.states[state.ClientId]; ok && !state.NewerThan(old) {
+		table.lock.Unlock()
+		return false
+	}
+	table.states[state.ClientId] = state
+	listeners := table.listeners
+	table.lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(state)
+	}
+	return true
+}
+
+// memorySessionStore 进程内会话存储，适用于单节点部署及测试，网关重启后会话丢失
+type memorySessionStore struct {
+	*sessionTable
+}
+
+// NewMemorySessionStore 创建进程内会话存储
+// Author: agent
+// Since: 2026/10/19
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessionTable: newSessionTable()}
+}
+
+func (store *memorySessionStore) Start() error {
+	return nil
+}
+
+func (store *memorySessionStore) Shutdown() {
+}
+
+func (store *memorySessionStore) Save(state *SessionState) error {
+	store.apply(state)
+	return nil
+}
+
+// topicSessionStore 以smartgo topic保存会话快照：每次写入发送一条以clientId为key的消息，
+// 启动时从头读取会话topic，按clientId只保留最新快照(读取时压缩)，之后持续读取其他节点写入的快照；
+// 离线会话的快照须在broker消息保留时间内过期，在线会话由定时checkpoint重写
+type topicSessionStore struct {
+	*sessionTable
+	log *compactedTopic
+}
+
+func newTopicSessionStore(topic string, producer messageProducer, reader messageReader, syncInterval time.Duration) *topicSessionStore {
+	store := &topicSessionStore{sessionTable: newSessionTable()}
+	store.log = newCompactedTopic(topic, producer, reader, syncInterval, func(body []byte) error {
+		state, err := decodeSessionState(body)
+		if err != nil {
+			return err
+		}
+		store.apply(state)
+		return nil
+	})
+	return store
+}
+
+// Start 加载会话topic中的全部快照后启动后台同步
+func (store *topicSessionStore) Start() error {
+	if err := store.log.start(); err != nil {
+		return fmt.Errorf("load mqtt sessions from topic %s failed: %s", store.log.topic, err)
+	}
+	logger.Infof("load %d mqtt sessions from topic %s", len(store.List()), store.log.topic)
+	return nil
+}
+
+func (store *topicSessionStore) Shutdown() {
+	store.log.shutdown()
+}
+
+// Save 发送快照消息，发送成功后立即在本地生效
+func (store *topicSessionStore) Save(state *SessionState) error {
+	body, err := encodeSessionState(state)
+	if err != nil {
+		return err
+	}
+	if err = store.log.send(state.ClientId, body); err != nil {
+		return err
+	}
+	store.apply(state)
+	return nil
+}
+
+// messageReader 按队列读取smartgo消息，用于加载会话topic及补发离线消息
+type messageReader interface {
+	Start()
+	Shutdown()
+	FetchMessageQueues(topic string) ([]*message.MessageQueue, error)
+	MaxOffset(mq *message.MessageQueue) (int64, error)
+	Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (*consumer.PullResult, error)
+}
+
+// pullMessageReader 基于DefaultMQPullConsumer实现，将客户端的panic转换为error
+type pullMessageReader struct {
+	consumer *process.DefaultMQPullConsumer
+}
+
+func newPullMessageReader(consumerGroup, namesrvAddr string) *pullMessageReader {
+	pullConsumer := process.NewDefaultMQPullConsumer(consumerGroup)
+	pullConsumer.SetNamesrvAddr(namesrvAddr)
+	return &pullMessageReader{consumer: pullConsumer}
+}
+
+func (reader *pullMessageReader) Start() {
+	reader.consumer.Start()
+}
+
+func (reader *pullMessageReader) Shutdown() {
+	reader.consumer.Shutdown()
+}
+
+func (reader *pullMessageReader) FetchMessageQueues(topic string) (mqs []*message.MessageQueue, err error) {
+	defer gwutil.RecoverError(&err)
+	return reader.consumer.FetchSubscribeMessageQueues(topic), nil
+}
+
+func (reader *pullMessageReader) MaxOffset(mq *message.MessageQueue) (offset int64, err error) {
+	defer gwutil.RecoverError(&err)
+	offset = reader.consumer.MaxOffset(mq)
+	if offset < 0 {
+		return 0, fmt.Errorf("query max offset of %s failed", queueKey(mq))
+	}
+	return offset, nil
+}
+
+func (reader *pullMessageReader) Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (result *consumer.PullResult, err error) {
+	defer gwutil.RecoverError(&err)
+	return reader.consumer.Pull(mq, subExpression, offset, maxNums)
+}
+
+const readBatchSize = 32
+
+// readQueue 从offset开始读取队列直到end(-1表示读到最新)，返回下一次读取的位置
+func readQueue(reader messageReader, mq *message.MessageQueue, subExpression string, offset, end int64, fn func(msg *message.MessageExt)) (int64, error) {
+	for end < 0 || offset < end {
+		result, err := reader.Pull(mq, subExpression, offset, readBatchSize)
+		if err != nil {
+			return offset, err
+		}
+		if result == nil {
+			return offset, fmt.Errorf("pull %s return nil", queueKey(mq))
+		}
+
+		switch result.PullStatus {
+		case consumer.FOUND:
+			for _, msg := range result.MsgFoundList {
+				if end >= 0 && msg.QueueOffset >= end {
+					return end, nil
+				}
+				fn(msg)
+			}
+		case consumer.NO_NEW_MSG:
+			return offset, nil
+		case consumer.OFFSET_ILLEGAL:
+			if result.NextBeginOffset < offset {
+				return result.NextBeginOffset, nil
+			}
+		}
+
+		// 过滤后无匹配消息或offset非法时按broker返回的位置修正
+		if result.NextBeginOffset <= offset {
+			return offset, nil
+		}
+		offset = result.NextBeginOffset
+	}
+	return offset, nil
+}
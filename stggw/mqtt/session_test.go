@@ -0,0 +1,460 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+func dialPersistentClient(t *testing.T, addr, clientId string) *Client {
+	client, err := DialClient(addr, &ConnectPacket{ClientId: clientId, KeepAlive: 60}, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func dialRaw(t *testing.T, addr string, connect *ConnectPacket) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(connect.Encode())
+	return conn
+}
+
+func waitFor(t *testing.T, desc string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("wait %s timeout", desc)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func waitOffline(t *testing.T, gateway *MqttGateway, clientId string) {
+	waitFor(t, clientId+" offline", func() bool {
+		state, ok := gateway.store.Get(clientId)
+		return ok && !state.Connected && !state.Deleted
+	})
+}
+
+func TestPersistentSessionOfflineReplay(t *testing.T) {
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), newFakeBroker())
+	defer gateway.Shutdown()
+
+	device := dialPersistentClient(t, addr, "device-1")
+	if device.SessionPresent() {
+		t.Fatalf("new session should not be present")
+	}
+	if _, err := device.Subscribe("devices/1/cmd", 1); err != nil {
+		t.Fatal(err)
+	}
+	device.Disconnect()
+	waitOffline(t, gateway, "device-1")
+
+	backend := dialTestClient(t, addr, "backend", 60)
+	defer backend.Disconnect()
+	for _, payload := range []string{"cmd-0", "cmd-1", "cmd-2"} {
+		if err := backend.Publish("devices/1/cmd", 1, []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backend.Publish("devices/1/other", 1, []byte("ignored"))
+	backend.Publish("devices/1/cmd", 0, []byte("qos0 is not stored"))
+	backend.Ping() // 同一连接的报文按序处理，确保QoS0消息已分发
+
+	device = dialPersistentClient(t, addr, "device-1")
+	defer device.Disconnect()
+	if !device.SessionPresent() {
+		t.Fatalf("persistent session should be present")
+	}
+	for _, payload := range []string{"cmd-0", "cmd-1", "cmd-2"} {
+		if p := receive(t, device); string(p.Payload) != payload || p.TopicName != "devices/1/cmd" {
+			t.Fatalf("expect replayed %s, got %+v", payload, p)
+		}
+	}
+
+	// 恢复后无需重新订阅，实时消息正常下发且不与补发的消息重复
+	if err := backend.Publish("devices/1/cmd", 1, []byte("cmd-3")); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, device); string(p.Payload) != "cmd-3" {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+	select {
+	case p := <-device.Messages():
+		t.Fatalf("unexpected duplicate message %+v", p)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// clean session重连清除持久会话
+	device.Disconnect()
+	waitOffline(t, gateway, "device-1")
+	dialTestClient(t, addr, "device-1", 60).Disconnect()
+	if state, _ := gateway.store.Get("device-1"); !state.Deleted {
+		t.Fatalf("clean session should discard stored session, got %+v", state)
+	}
+}
+
+func TestPersistentSessionQos2AndInflight(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, addr := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gateway.Shutdown()
+	backend := dialTestClient(t, addr, "backend", 60)
+	defer backend.Disconnect()
+
+	conn := dialRaw(t, addr, &ConnectPacket{ClientId: "device-2"})
+	conn.Write((&SubscribePacket{PacketId: 1, Subscriptions: []Subscription{{Filter: "devices/2/#", Qos: 2}}}).Encode())
+	if suback := readPackets(t, conn, 2)[1].(*SubackPacket); suback.ReturnCodes[0] != 2 {
+		t.Fatalf("subscribe granted qos %d", suback.ReturnCodes[0])
+	}
+
+	// 上行QoS2：回复PUBREC后等待PUBREL
+	upstream := &PublishPacket{Qos: 2, PacketId: 7, TopicName: "devices/3/status", Payload: []byte("online")}
+	conn.Write(upstream.Encode())
+	if rec, ok := readPackets(t, conn, 1)[0].(*PubrecPacket); !ok || rec.PacketId != 7 {
+		t.Fatalf("expect PUBREC 7, got %+v", rec)
+	}
+
+	// 下行QoS2收到PUBREC、发出PUBREL后断开；下行QoS1未确认
+	if err := backend.Publish("devices/2/cmd", 2, []byte("open")); err != nil {
+		t.Fatal(err)
+	}
+	qos2 := readPackets(t, conn, 1)[0].(*PublishPacket)
+	if qos2.Qos != 2 || string(qos2.Payload) != "open" {
+		t.Fatalf("unexpected qos2 message %+v", qos2)
+	}
+	conn.Write((&PubrecPacket{PacketId: qos2.PacketId}).Encode())
+	if rel, ok := readPackets(t, conn, 1)[0].(*PubrelPacket); !ok || rel.PacketId != qos2.PacketId {
+		t.Fatalf("expect PUBREL %d, got %+v", qos2.PacketId, rel)
+	}
+	if err := backend.Publish("devices/2/light", 1, []byte("on")); err != nil {
+		t.Fatal(err)
+	}
+	qos1 := readPackets(t, conn, 1)[0].(*PublishPacket)
+	conn.Close()
+	waitOffline(t, gateway, "device-2")
+	sent := broker.count("DeviceCommand")
+
+	conn = dialRaw(t, addr, &ConnectPacket{ClientId: "device-2"})
+	defer conn.Close()
+	packets := readPackets(t, conn, 3)
+	if connack := packets[0].(*ConnackPacket); !connack.SessionPresent {
+		t.Fatalf("persistent session should be present")
+	}
+	if rel, ok := packets[1].(*PubrelPacket); !ok || rel.PacketId != qos2.PacketId {
+		t.Fatalf("expect resent PUBREL %d, got %+v", qos2.PacketId, packets[1])
+	}
+	if dup, ok := packets[2].(*PublishPacket); !ok || !dup.Dup || dup.PacketId != qos1.PacketId || string(dup.Payload) != "on" {
+		t.Fatalf("expect resent PUBLISH %d, got %+v", qos1.PacketId, packets[2])
+	}
+
+	// 重发的上行QoS2报文只回复PUBREC，不再写入smartgo
+	dup := *upstream
+	dup.Dup = true
+	conn.Write(dup.Encode())
+	if rec, ok := readPackets(t, conn, 1)[0].(*PubrecPacket); !ok || rec.PacketId != 7 {
+		t.Fatalf("expect PUBREC 7, got %+v", rec)
+	}
+	if count := broker.count("DeviceCommand"); count != sent {
+		t.Fatalf("duplicate qos2 message should not be sent again, %d != %d", count, sent)
+	}
+	conn.Write((&PubrelPacket{PacketId: 7}).Encode())
+	if comp, ok := readPackets(t, conn, 1)[0].(*PubcompPacket); !ok || comp.PacketId != 7 {
+		t.Fatalf("expect PUBCOMP 7, got %+v", comp)
+	}
+
+	conn.Write((&PubcompPacket{PacketId: qos2.PacketId}).Encode())
+	conn.Write((&PubackPacket{PacketId: qos1.PacketId}).Encode())
+	waitFor(t, "inflight acknowledged", func() bool {
+		sessions := gateway.ListSessions()
+		for _, view := range sessions {
+			if view.ClientId == "device-2" {
+				return view.Inflight == 0
+			}
+		}
+		return false
+	})
+}
+
+func TestPersistentSessionTakeoverAcrossGateways(t *testing.T) {
+	broker := newFakeBroker()
+	gatewayA, addrA := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gatewayA.Shutdown()
+	gatewayB, addrB := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+	backend := dialTestClient(t, addrA, "backend", 60)
+	defer backend.Disconnect()
+
+	device := dialPersistentClient(t, addrA, "device-3")
+	if _, err := device.Subscribe("devices/3/cmd", 1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "session synced to gateway B", func() bool {
+		state, ok := gatewayB.store.Get("device-3")
+		return ok && state.Subscriptions["devices/3/cmd"] == 1
+	})
+
+	// 连接到B节点后恢复A节点上的会话，A节点同步到更新的快照后关闭旧连接
+	resumed := dialPersistentClient(t, addrB, "device-3")
+	if !resumed.SessionPresent() {
+		t.Fatalf("session should be resumed on gateway B")
+	}
+	select {
+	case <-device.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("connection on gateway A should be closed after takeover")
+	}
+	if err := backend.Publish("devices/3/cmd", 1, []byte("on-b")); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, resumed); string(p.Payload) != "on-b" {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+
+	// B节点上断开后回到A节点，补发离线期间的消息
+	resumed.Disconnect()
+	waitFor(t, "disconnect synced to gateway A", func() bool {
+		state, ok := gatewayA.store.Get("device-3")
+		return ok && !state.Connected && state.Owner == gatewayB.Name()
+	})
+	if err := backend.Publish("devices/3/cmd", 1, []byte("offline")); err != nil {
+		t.Fatal(err)
+	}
+	device = dialPersistentClient(t, addrA, "device-3")
+	defer device.Disconnect()
+	if p := receive(t, device); string(p.Payload) != "offline" {
+		t.Fatalf("unexpected replayed message %+v", p)
+	}
+}
+
+func TestPersistentSessionExpiry(t *testing.T) {
+	cfg := NewGatewayConfig()
+	cfg.SessionStore = SESSION_STORE_MEMORY
+	cfg.SessionExpiryInterval = 1
+	cfg.SessionCheckpointInterval = 1
+	gateway, addr := startTestGateway(t, cfg, newFakeBroker())
+	defer gateway.Shutdown()
+
+	device := dialPersistentClient(t, addr, "device-4")
+	device.Subscribe("devices/4/cmd", 1)
+	device.Disconnect()
+	waitOffline(t, gateway, "device-4")
+
+	waitFor(t, "session expired", func() bool {
+		state, ok := gateway.store.Get("device-4")
+		return !ok || state.Deleted
+	})
+	device = dialPersistentClient(t, addr, "device-4")
+	defer device.Disconnect()
+	if device.SessionPresent() {
+		t.Fatalf("expired session should not be present")
+	}
+}
+
+func TestGatewayAdminServer(t *testing.T) {
+	cfg := NewGatewayConfig()
+	cfg.AdminServerEnable = true
+	cfg.AdminServerAddr = "127.0.0.1:0"
+	gateway, addr := startTestGateway(t, cfg, newFakeBroker())
+	defer gateway.Shutdown()
+	adminUrl := "http://" + gateway.adminServer.Addr()
+
+	persistent := dialPersistentClient(t, addr, "device-5")
+	persistent.Subscribe("devices/5/#", 1)
+	clean := dialTestClient(t, addr, "device-6", 60)
+
+	resp, err := http.Get(adminUrl + "/sessions?pretty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var views []*SessionView
+	err = json.NewDecoder(resp.Body).Decode(&views)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 2 || views[0].ClientId != "device-5" || !views[0].Persistent || !views[0].Online ||
+		len(views[0].Subscriptions) != 1 || views[1].ClientId != "device-6" || views[1].Persistent {
+		t.Fatalf("unexpected sessions %+v", views)
+	}
+
+	kick := func(query string) int {
+		resp, err := http.Post(adminUrl+"/sessions/kick?"+query, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := kick("clientId=device-6"); status != http.StatusOK {
+		t.Fatalf("kick clean session return %d", status)
+	}
+	<-clean.Done()
+	if status := kick("clientId=device-5&discard=true"); status != http.StatusOK {
+		t.Fatalf("kick persistent session return %d", status)
+	}
+	<-persistent.Done()
+	if state, _ := gateway.store.Get("device-5"); !state.Deleted {
+		t.Fatalf("discarded session should be deleted, got %+v", state)
+	}
+	if status := kick("clientId=unknown"); status != http.StatusNotFound {
+		t.Fatalf("kick unknown session return %d", status)
+	}
+	if resp, err = http.Get(adminUrl + "/sessions/kick?clientId=device-5"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("kick by GET should not be allowed, %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestSessionStoreNewerWins(t *testing.T) {
+	store := NewMemorySessionStore()
+	var notified []int64
+	store.AddListener(func(state *SessionState) { notified = append(notified, state.Version) })
+
+	store.Save(&SessionState{ClientId: "c1", Version: 2, UpdateTime: 100})
+	store.Save(&SessionState{ClientId: "c1", Version: 1, UpdateTime: 200})
+	store.Save(&SessionState{ClientId: "c1", Version: 2, UpdateTime: 100, Owner: "b"})
+	if state, _ := store.Get("c1"); state.Owner != "b" || len(notified) != 2 {
+		t.Fatalf("unexpected state %+v, notified %v", state, notified)
+	}
+
+	store.Save(&SessionState{ClientId: "c1", Version: 3, UpdateTime: 300, Deleted: true})
+	if len(store.List()) != 0 {
+		t.Fatalf("tombstone should not be listed")
+	}
+	store.Prune(300)
+	if _, ok := store.Get("c1"); !ok {
+		t.Fatalf("tombstone should be kept until expiry")
+	}
+	store.Prune(301)
+	if _, ok := store.Get("c1"); ok {
+		t.Fatalf("tombstone should be pruned")
+	}
+}
+
+func TestTopicSessionStoreSync(t *testing.T) {
+	broker := newFakeBroker()
+	store1 := newTopicSessionStore("MQTT_SESSION", broker, broker, 10*time.Millisecond)
+	store2 := newTopicSessionStore("MQTT_SESSION", broker, broker, 10*time.Millisecond)
+	for _, store := range []*topicSessionStore{store1, store2} {
+		if err := store.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer store.Shutdown()
+	}
+	updated := make(chan *SessionState, 4)
+	store2.AddListener(func(state *SessionState) { updated <- state })
+
+	store1.Save(&SessionState{ClientId: "c1", Owner: "a", Version: 1, Subscriptions: map[string]byte{"a/#": 1}})
+	store1.Save(&SessionState{ClientId: "c1", Owner: "a", Version: 2, Subscriptions: map[string]byte{"a/#": 2}})
+	for version := int64(1); version <= 2; version++ {
+		select {
+		case state := <-updated:
+			if state.Version != version {
+				t.Fatalf("unexpected synced state %+v", state)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait session sync timeout")
+		}
+	}
+
+	// 新启动的节点从头读取会话topic，只保留最新快照
+	store3 := newTopicSessionStore("MQTT_SESSION", broker, broker, time.Second)
+	if err := store3.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer store3.Shutdown()
+	if state, ok := store3.Get("c1"); !ok || state.Version != 2 || state.Subscriptions["a/#"] != 2 {
+		t.Fatalf("unexpected loaded state %+v", state)
+	}
+}
+
+func TestSessionStateExpired(t *testing.T) {
+	cases := []struct {
+		state   SessionState
+		expired bool
+	}{
+		{SessionState{Connected: true, UpdateTime: 1000}, false},
+		{SessionState{Connected: true, UpdateTime: 100}, true},
+		{SessionState{DisconnectTime: 1000}, false},
+		{SessionState{DisconnectTime: 800}, true},
+		{SessionState{DisconnectTime: 100, Deleted: true}, false},
+	}
+	for i, c := range cases {
+		if expired := c.state.Expired(1200, 300, 500); expired != c.expired {
+			t.Errorf("case %d: expect expired=%t, got %t", i, c.expired, expired)
+		}
+	}
+}
+
+func TestEncodeSessionStateLimit(t *testing.T) {
+	// 来自smartgo队列的消息只保存队列位置
+	source := &QueueOffset{Topic: "DeviceCommand", BrokerName: "fake-broker", Offset: 3}
+	payload := make([]byte, 1024)
+	state := &SessionState{ClientId: "c1", Inflight: []*InflightState{
+		{PacketId: 1, Qos: 1, Topic: "devices/1/cmd", Payload: payload, Source: source},
+		{Qos: 1, Topic: "devices/1/cmd", Payload: payload},
+	}}
+	body, err := encodeSessionState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeSessionState(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Inflight[0].Payload != nil || *decoded.Inflight[0].Source != *source || len(decoded.Inflight[1].Payload) != len(payload) {
+		t.Fatalf("unexpected decoded inflight %+v %+v", decoded.Inflight[0], decoded.Inflight[1])
+	}
+	if state.Inflight[0].Payload == nil {
+		t.Fatalf("encode should not modify session state")
+	}
+
+	// 超过大小上限时优先丢弃最早排队的消息
+	state.Inflight = state.Inflight[:1]
+	for i := 0; i < 200; i++ {
+		state.Inflight = append(state.Inflight, &InflightState{Qos: 1, Topic: "devices/1/cmd/" + strconv.Itoa(i), Payload: payload})
+	}
+	if body, err = encodeSessionState(state); err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > maxSessionStateSize {
+		t.Fatalf("encoded state too large: %d", len(body))
+	}
+	if decoded, err = decodeSessionState(body); err != nil {
+		t.Fatal(err)
+	}
+	last := decoded.Inflight[len(decoded.Inflight)-1]
+	if decoded.Inflight[0].PacketId != 1 || len(decoded.Inflight) >= 201 || last.Topic != "devices/1/cmd/199" {
+		t.Fatalf("unexpected trimmed inflight %d, first %+v, last %+v", len(decoded.Inflight), decoded.Inflight[0], last)
+	}
+}
+
+func TestLoadSessionPayloads(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, _ := startTestGateway(t, NewGatewayConfig(), broker)
+	defer gateway.Shutdown()
+	broker.Send(message.NewMessage("DeviceCommand", "", []byte("cmd-0")))
+
+	mq := fakeQueue("DeviceCommand")
+	state := &SessionState{ClientId: "c1", Inflight: []*InflightState{
+		{PacketId: 1, Qos: 1, Topic: "devices/1/cmd", Source: messageSource(mq, 0)},
+		{Qos: 1, Topic: "devices/1/cmd", Source: messageSource(mq, 5)},
+		{Qos: 1, Topic: "devices/1/retain", Payload: []byte("retained")},
+	}}
+	loaded := gateway.loadPayloads(state)
+	if len(loaded.Inflight) != 2 || string(loaded.Inflight[0].Payload) != "cmd-0" || string(loaded.Inflight[1].Payload) != "retained" {
+		t.Fatalf("unexpected loaded inflight %+v", loaded.Inflight)
+	}
+	if state.Inflight[0].Payload != nil || len(state.Inflight) != 3 {
+		t.Fatalf("load payloads should not modify stored state")
+	}
+}
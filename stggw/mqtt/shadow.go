@@ -0,0 +1,282 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	SHADOW_TOPIC_PREFIX = "$shadow/" // 设备影子的MQTT保留主题前缀：$shadow/{deviceId}/{update|get|delete}
+
+	SHADOW_OP_UPDATE = "update"
+	SHADOW_OP_GET    = "get"
+	SHADOW_OP_DELETE = "delete"
+
+	shadowMaxDepth = 8 // 状态对象的最大嵌套层数
+)
+
+// ShadowState 影子文档的期望状态(由应用设置)与上报状态(由设备设置)
+type ShadowState struct {
+	Desired  map[string]interface{} `json:"desired,omitempty"`
+	Reported map[string]interface{} `json:"reported,omitempty"`
+}
+
+// ShadowDocument 设备影子文档，以设备ID为key保存在影子topic中，Version每次写入加1
+//
+// 注意：多个网关节点并发写入同一设备时以Version、UpdateTime、Owner依次比较确定最新文档
+//
+// Author: agent
+// Since: 2026/10/19
+type ShadowDocument struct {
+	DeviceId   string      `json:"deviceId"`
+	State      ShadowState `json:"state"`
+	Version    int64       `json:"version"`
+	Owner      string      `json:"owner"` // 写入文档的网关节点
+	UpdateTime int64       `json:"updateTime"`
+	Deleted    bool        `json:"deleted,omitempty"` // 删除影子的墓碑，保留版本号
+}
+
+// NewerThan 判断文档是否比另一个新
+func (doc *ShadowDocument) NewerThan(other *ShadowDocument) bool {
+	if other == nil {
+		return true
+	}
+	if doc.Version != other.Version {
+		return doc.Version > other.Version
+	}
+	if doc.UpdateTime != other.UpdateTime {
+		return doc.UpdateTime > other.UpdateTime
+	}
+	return doc.Owner > other.Owner
+}
+
+// Delta 期望状态中与上报状态不一致的部分，嵌套对象逐层比较，一致时返回nil
+func (doc *ShadowDocument) Delta() map[string]interface{} {
+	return computeDelta(doc.State.Desired, doc.State.Reported)
+}
+
+func computeDelta(desired, reported map[string]interface{}) map[string]interface{} {
+	var delta map[string]interface{}
+	for key, value := range desired {
+		current, ok := reported[key]
+		if desiredObject, isObject := value.(map[string]interface{}); isObject && ok {
+			if reportedObject, isObject := current.(map[string]interface{}); isObject {
+				if nested := computeDelta(desiredObject, reportedObject); nested != nil {
+					if delta == nil {
+						delta = make(map[string]interface{})
+					}
+					delta[key] = nested
+				}
+				continue
+			}
+		}
+		if !ok || !reflect.DeepEqual(value, current) {
+			if delta == nil {
+				delta = make(map[string]interface{})
+			}
+			delta[key] = value
+		}
+	}
+	return delta
+}
+
+// ShadowView 影子文档对外的视图，get、update的应答及HTTP接口返回
+type ShadowView struct {
+	State       ShadowViewState `json:"state"`
+	Version     int64           `json:"version"`
+	Timestamp   int64           `json:"timestamp"`
+	ClientToken string          `json:"clientToken,omitempty"`
+}
+
+// ShadowViewState 文档视图的状态，delta为期望状态中尚未被设备上报的部分
+type ShadowViewState struct {
+	Desired  map[string]interface{} `json:"desired,omitempty"`
+	Reported map[string]interface{} `json:"reported,omitempty"`
+	Delta    map[string]interface{} `json:"delta,omitempty"`
+}
+
+func newShadowView(doc *ShadowDocument, clientToken string) *ShadowView {
+	return &ShadowView{
+		State:       ShadowViewState{Desired: doc.State.Desired, Reported: doc.State.Reported, Delta: doc.Delta()},
+		Version:     doc.Version,
+		Timestamp:   doc.UpdateTime,
+		ClientToken: clientToken,
+	}
+}
+
+// ShadowDelta 期望状态变化后通知设备的消息，主题为$shadow/{deviceId}/update/delta
+type ShadowDelta struct {
+	State     map[string]interface{} `json:"state"`
+	Version   int64                  `json:"version"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// ShadowError 影子操作的错误，Code取HTTP状态码，MQTT的rejected应答及HTTP接口返回
+type ShadowError struct {
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	ClientToken string `json:"clientToken,omitempty"`
+}
+
+func (e *ShadowError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+func newShadowError(code int, format string, args ...interface{}) *ShadowError {
+	return &ShadowError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ShadowUpdateRequest 更新影子的请求：state中的desired、reported按key合并到文档，值为null时删除该key，
+// desired或reported为null时清空该部分；version不为空时须与当前版本一致(乐观锁)
+type ShadowUpdateRequest struct {
+	State       map[string]interface{} `json:"state"`
+	Version     *int64                 `json:"version,omitempty"`
+	ClientToken string                 `json:"clientToken,omitempty"`
+}
+
+// ShadowRequest get、delete请求，请求体可为空
+type ShadowRequest struct {
+	Version     *int64 `json:"version,omitempty"`
+	ClientToken string `json:"clientToken,omitempty"`
+}
+
+// decodeShadowUpdate 解析并校验更新请求
+func decodeShadowUpdate(data []byte) (*ShadowUpdateRequest, *ShadowError) {
+	request := &ShadowUpdateRequest{}
+	if err := json.Unmarshal(data, request); err != nil {
+		return nil, newShadowError(http.StatusBadRequest, "invalid json: %s", err)
+	}
+	if len(request.State) == 0 {
+		return request, newShadowError(http.StatusBadRequest, "state is empty")
+	}
+	for section, value := range request.State {
+		if section != "desired" && section != "reported" {
+			return request, newShadowError(http.StatusBadRequest, "unknown state section %q", section)
+		}
+		if value == nil {
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return request, newShadowError(http.StatusBadRequest, "state.%s must be an object or null", section)
+		}
+		if depth(object) > shadowMaxDepth {
+			return request, newShadowError(http.StatusBadRequest, "state.%s exceeds max depth %d", section, shadowMaxDepth)
+		}
+	}
+	return request, nil
+}
+
+// decodeShadowRequest 解析get、delete请求，空请求体合法
+func decodeShadowRequest(data []byte) (*ShadowRequest, *ShadowError) {
+	request := &ShadowRequest{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return request, nil
+	}
+	if err := json.Unmarshal(data, request); err != nil {
+		return nil, newShadowError(http.StatusBadRequest, "invalid json: %s", err)
+	}
+	return request, nil
+}
+
+func depth(object map[string]interface{}) int {
+	max := 0
+	for _, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if d := depth(nested); d > max {
+				max = d
+			}
+		}
+	}
+	return max + 1
+}
+
+// mergeState 将更新合并到状态中，返回新的状态对象，不修改原对象；合并后为空时返回nil
+func mergeState(current, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(update))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range update {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			existing, _ := merged[key].(map[string]interface{})
+			if result := mergeState(existing, nested); result != nil {
+				merged[key] = result
+			} else {
+				delete(merged, key)
+			}
+			continue
+		}
+		merged[key] = value
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// applyUpdate 在当前文档(可能为nil或墓碑)上应用更新，返回新文档及期望状态是否变化
+func applyUpdate(current *ShadowDocument, request *ShadowUpdateRequest) (*ShadowDocument, bool) {
+	doc := &ShadowDocument{}
+	if current != nil {
+		doc.DeviceId = current.DeviceId
+		doc.Version = current.Version
+		if !current.Deleted {
+			doc.State = current.State
+		}
+	}
+	previous := doc.State.Desired
+
+	for section, value := range request.State {
+		object, _ := value.(map[string]interface{})
+		switch section {
+		case "desired":
+			if value == nil {
+				doc.State.Desired = nil
+			} else {
+				doc.State.Desired = mergeState(doc.State.Desired, object)
+			}
+		case "reported":
+			if value == nil {
+				doc.State.Reported = nil
+			} else {
+				doc.State.Reported = mergeState(doc.State.Reported, object)
+			}
+		}
+	}
+	doc.Version++
+	return doc, !reflect.DeepEqual(previous, doc.State.Desired)
+}
+
+// parseShadowTopic 解析影子保留主题，返回设备ID及操作，不是影子请求主题时ok为false
+func parseShadowTopic(topic string) (deviceId, op string, ok bool) {
+	if !strings.HasPrefix(topic, SHADOW_TOPIC_PREFIX) {
+		return "", "", false
+	}
+	levels := strings.Split(topic[len(SHADOW_TOPIC_PREFIX):], "/")
+	if len(levels) != 2 || !validShadowDeviceId(levels[0]) {
+		return "", "", false
+	}
+	switch levels[1] {
+	case SHADOW_OP_UPDATE, SHADOW_OP_GET, SHADOW_OP_DELETE:
+		return levels[0], levels[1], true
+	}
+	return "", "", false
+}
+
+// validShadowDeviceId 设备ID作为消息key建立索引，不能包含空白字符
+func validShadowDeviceId(deviceId string) bool {
+	return deviceId != "" && !strings.ContainsAny(deviceId, " \t\r\n/+#\x00")
+}
+
+// shadowReplyTopic 应答主题，如$shadow/{deviceId}/update/accepted
+func shadowReplyTopic(deviceId, op, result string) string {
+	return SHADOW_TOPIC_PREFIX + deviceId + "/" + op + "/" + result
+}
@@ -0,0 +1,116 @@
+package mqtt
+
+import (
+	"container/list"
+	"sync"
+)
+
+// shadowEntry 缓存的最新影子文档
+type shadowEntry struct {
+	doc       *ShadowDocument
+	writeTime int64 // 最近一次写入影子topic的时间
+	checkTime int64 // 最近一次由本节点写入或从消息索引确认的时间，消费到的记录不更新
+}
+
+// shadowCache 影子文档缓存，超过容量时淘汰最久未访问的文档
+// Author: agent
+// Since: 2026/10/19
+type shadowCache struct {
+	capacity int
+	entries  map[string]*list.Element // deviceId -> *shadowEntry
+	order    *list.List               // 最近访问的在前
+	lock     sync.Mutex
+}
+
+func newShadowCache(capacity int) *shadowCache {
+	return &shadowCache{capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// get 返回不早于minCheckTime确认过的文档
+func (cache *shadowCache) get(deviceId string, minCheckTime int64) (*ShadowDocument, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	element, ok := cache.entries[deviceId]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*shadowEntry)
+	if entry.checkTime < minCheckTime {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return entry.doc, true
+}
+
+// writeTime 缓存文档最近一次写入的时间，没有缓存时返回0
+func (cache *shadowCache) writeTime(deviceId string) int64 {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if element, ok := cache.entries[deviceId]; ok {
+		return element.Value.(*shadowEntry).writeTime
+	}
+	return 0
+}
+
+// put 文档比缓存新时替换，相同文档只更新写入及确认时间；insert为false时只更新已缓存的文档。
+// 返回缓存中最新的文档，以及传入的文档是否不旧于缓存
+func (cache *shadowCache) put(doc *ShadowDocument, writeTime, checkTime int64, insert bool) (*ShadowDocument, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	element, ok := cache.entries[doc.DeviceId]
+	if !ok {
+		if !insert {
+			return doc, true
+		}
+		cache.entries[doc.DeviceId] = cache.order.PushFront(&shadowEntry{doc: doc, writeTime: writeTime, checkTime: checkTime})
+		for cache.order.Len() > cache.capacity {
+			oldest := cache.order.Back()
+			cache.order.Remove(oldest)
+			delete(cache.entries, oldest.Value.(*shadowEntry).doc.DeviceId)
+		}
+		return doc, true
+	}
+
+	entry := element.Value.(*shadowEntry)
+	cache.order.MoveToFront(element)
+	if entry.doc.NewerThan(doc) {
+		return entry.doc, false
+	}
+	if doc.NewerThan(entry.doc) {
+		entry.doc = doc
+	}
+	if writeTime > entry.writeTime {
+		entry.writeTime = writeTime
+	}
+	if checkTime > entry.checkTime {
+		entry.checkTime = checkTime
+	}
+	return entry.doc, true
+}
+
+// stale 由owner最近写入且早于beforeMillis写入的未删除文档
+func (cache *shadowCache) stale(owner string, beforeMillis int64) []*ShadowDocument {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	var docs []*ShadowDocument
+	for _, element := range cache.entries {
+		entry := element.Value.(*shadowEntry)
+		if entry.doc.Owner == owner && !entry.doc.Deleted && entry.writeTime < beforeMillis {
+			docs = append(docs, entry.doc)
+		}
+	}
+	return docs
+}
+
+// prune 清理早于指定时间的墓碑
+func (cache *shadowCache) prune(beforeMillis int64) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for deviceId, element := range cache.entries {
+		entry := element.Value.(*shadowEntry)
+		if entry.doc.Deleted && entry.doc.UpdateTime < beforeMillis {
+			cache.order.Remove(element)
+			delete(cache.entries, deviceId)
+		}
+	}
+}
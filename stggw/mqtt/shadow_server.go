@@ -0,0 +1,185 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+// ShadowServer 设备影子HTTP服务，供应用查询、更新、删除设备影子：
+// GET /shadows/{deviceId}、POST /shadows/{deviceId}、DELETE /shadows/{deviceId}?version=N
+//
+// 注意：请求体及返回与MQTT保留主题一致，错误以ShadowError返回
+//
+// Author: agent
+// Since: 2026/10/19
+type ShadowServer struct {
+	gateway  *MqttGateway
+	addr     string
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewShadowServer 初始化设备影子HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func NewShadowServer(gateway *MqttGateway, addr string) *ShadowServer {
+	return &ShadowServer{gateway: gateway, addr: addr}
+}
+
+// Start 启动设备影子HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func (self *ShadowServer) Start() error {
+	listener, err := net.Listen("tcp", self.addr)
+	if err != nil {
+		return err
+	}
+	self.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shadows/", self.serveShadow)
+	self.server = &http.Server{Handler: mux}
+
+	go func() {
+		defer utils.RecoveredFn()
+		if err := self.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("mqtt shadow server serve err: %s", err.Error())
+		}
+	}()
+
+	logger.Infof("mqtt shadow server start successful, listen %s", listener.Addr().String())
+	return nil
+}
+
+// Shutdown 关闭设备影子HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func (self *ShadowServer) Shutdown() {
+	if self.server != nil {
+		self.server.Close()
+		logger.Info("mqtt shadow server shutdown successful")
+	}
+}
+
+// Addr 设备影子HTTP服务实际监听的地址
+// Author: agent
+// Since: 2026/10/19
+func (self *ShadowServer) Addr() string {
+	if self.listener != nil {
+		return self.listener.Addr().String()
+	}
+	return self.addr
+}
+
+// serveShadow /shadows/{deviceId} 按请求方法分别查询、更新、删除
+func (self *ShadowServer) serveShadow(w http.ResponseWriter, r *http.Request) {
+	deviceId := strings.TrimPrefix(r.URL.Path, "/shadows/")
+	if !validShadowDeviceId(deviceId) {
+		self.writeError(w, r, newShadowError(http.StatusBadRequest, "invalid deviceId %q", deviceId))
+		return
+	}
+
+	service := self.gateway.shadow
+	switch r.Method {
+	case http.MethodGet:
+		doc, shadowErr := service.Get(deviceId)
+		if shadowErr != nil {
+			self.writeError(w, r, shadowErr)
+			return
+		}
+		self.writeJSON(w, r, http.StatusOK, newShadowView(doc, ""))
+	case http.MethodPost:
+		body, shadowErr := self.readBody(w, r)
+		if shadowErr != nil {
+			self.writeError(w, r, shadowErr)
+			return
+		}
+		request, shadowErr := decodeShadowUpdate(body)
+		if shadowErr == nil {
+			var doc *ShadowDocument
+			if doc, shadowErr = service.Update(deviceId, request); shadowErr == nil {
+				self.writeJSON(w, r, http.StatusOK, newShadowView(doc, request.ClientToken))
+				return
+			}
+		}
+		if request != nil {
+			shadowErr.ClientToken = request.ClientToken
+		}
+		self.writeError(w, r, shadowErr)
+	case http.MethodDelete:
+		body, shadowErr := self.readBody(w, r)
+		if shadowErr != nil {
+			self.writeError(w, r, shadowErr)
+			return
+		}
+		request, shadowErr := decodeShadowRequest(body)
+		if shadowErr != nil {
+			self.writeError(w, r, shadowErr)
+			return
+		}
+		if value := r.URL.Query().Get("version"); value != "" {
+			version, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				self.writeError(w, r, newShadowError(http.StatusBadRequest, "invalid version %s", value))
+				return
+			}
+			request.Version = &version
+		}
+		doc, shadowErr := service.Delete(deviceId, request)
+		if shadowErr != nil {
+			shadowErr.ClientToken = request.ClientToken
+			self.writeError(w, r, shadowErr)
+			return
+		}
+		self.writeJSON(w, r, http.StatusOK, &ShadowView{Version: doc.Version, Timestamp: doc.UpdateTime, ClientToken: request.ClientToken})
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		self.writeError(w, r, newShadowError(http.StatusMethodNotAllowed, "method not allowed"))
+	}
+}
+
+// readBody 读取请求体，超过影子文档大小上限时返回413
+func (self *ShadowServer) readBody(w http.ResponseWriter, r *http.Request) ([]byte, *ShadowError) {
+	maxSize := self.gateway.config.ShadowMaxSize
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxSize)))
+	if err != nil {
+		if strings.Contains(err.Error(), "too large") {
+			return nil, newShadowError(http.StatusRequestEntityTooLarge, "request size exceeds %d", maxSize)
+		}
+		return nil, newShadowError(http.StatusBadRequest, "read request failed: %s", err)
+	}
+	return body, nil
+}
+
+func (self *ShadowServer) writeError(w http.ResponseWriter, r *http.Request, shadowErr *ShadowError) {
+	self.writeJSON(w, r, shadowErr.Code, shadowErr)
+}
+
+// writeJSON 以JSON格式输出，携带pretty参数时格式化输出
+func (self *ShadowServer) writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	var (
+		content []byte
+		err     error
+	)
+	if _, pretty := r.URL.Query()["pretty"]; pretty {
+		content, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		content, err = json.Marshal(data)
+	}
+	if err != nil {
+		logger.Errorf("mqtt shadow server encode %s err: %s", r.URL.Path, err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(content)
+}
@@ -0,0 +1,530 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/header"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+)
+
+const (
+	shadowQueryMaxNum        = 32 // 按设备ID查询影子文档时每个broker最多返回的消息数
+	shadowQueryTimeoutMill   = 3000
+	shadowRequestTimeoutMill = 3000
+	shadowCacheTTLMill       = 60 * 1000 // 缓存的文档超过该时间未确认时重新查询消息索引
+	shadowLockStripes        = 64
+	shadowRecordTag          = "SHADOW_RECORD"  // 影子文档记录，以设备ID为消息key
+	shadowRequestTag         = "SHADOW_REQUEST" // 写入请求，以设备ID为shardingKey
+	shadowOpRefresh          = "refresh"
+)
+
+// messageQuerier 按消息key查询smartgo消息，用于查找本节点尚未缓存的影子文档，便于测试时替换
+type messageQuerier interface {
+	QueryMessage(topic, key string, maxNum int, begin, end int64) ([]*message.MessageExt, error)
+}
+
+// producerMessageQuerier 使用网关producer的客户端实例，从namesrv查询topic路由后向各broker按key查询消息索引；
+// 任一broker查询失败时返回错误，避免以旧文档为基础写入新版本
+type producerMessageQuerier struct {
+	producer *process.DefaultMQProducer
+}
+
+func (querier *producerMessageQuerier) QueryMessage(topic, key string, maxNum int, begin, end int64) ([]*message.MessageExt, error) {
+	api, err := producerClientAPI(querier.producer)
+	if err != nil {
+		return nil, err
+	}
+	routeData, err := api.GetTopicRouteInfoFromNameServer(topic, shadowQueryTimeoutMill)
+	if err != nil {
+		return nil, err
+	}
+
+	requestHeader := &header.QueryMessageRequestHeader{Topic: topic, Key: key, MaxNum: int32(maxNum), BeginTimestamp: begin, EndTimestamp: end}
+	var messages []*message.MessageExt
+	for _, brokerData := range routeData.BrokerDatas {
+		brokerAddr := brokerData.SelectBrokerAddr()
+		if brokerAddr == "" {
+			continue
+		}
+		result, err := api.QueryMessage(brokerAddr, requestHeader, shadowQueryTimeoutMill)
+		if err != nil {
+			return nil, fmt.Errorf("query key %s of topic %s from broker %s failed: %s", key, topic, brokerAddr, err)
+		}
+		messages = append(messages, result.MessageList...)
+	}
+	return messages, nil
+}
+
+// shadowRecord 写入影子topic的记录，WriteTime为本次写入时间，Notify表示期望状态有变化，需要通知在线的设备
+type shadowRecord struct {
+	ShadowDocument
+	WriteTime int64 `json:"writeTime"`
+	Notify    bool  `json:"notify,omitempty"`
+}
+
+// shadowWriteRequest 更新、删除及重写影子的请求，以设备ID为shardingKey发送到影子topic的同一队列，
+// 由集群消费该队列的节点串行处理，同一设备的版本比较只在一个节点上进行
+type shadowWriteRequest struct {
+	Op       string               `json:"op"`
+	DeviceId string               `json:"deviceId"`
+	Update   *ShadowUpdateRequest `json:"update,omitempty"`
+	Version  *int64               `json:"version,omitempty"`
+}
+
+// shadowWriteReply 处理写入请求的节点返回的结果
+type shadowWriteReply struct {
+	Document  *ShadowDocument `json:"document,omitempty"`
+	WriteTime int64           `json:"writeTime,omitempty"`
+	Error     *ShadowError    `json:"error,omitempty"`
+}
+
+// shadowService 设备影子：文档以设备ID为key写入影子topic，各节点以广播模式消费该topic维护缓存，
+// 缓存中没有或长时间未确认的文档按key查询消息索引取版本最新的一条；更新、删除以设备ID为shardingKey
+// 发送请求，由集群消费对应队列的节点比较版本后写入；期望状态变化时通知在线的设备，设备连接时若期望状态
+// 与上报状态不一致也发送一次delta
+// Author: agent
+// Since: 2026/10/19
+type shadowService struct {
+	gateway       *MqttGateway
+	querier       messageQuerier
+	consumer      gwutil.MessageConsumer // 广播消费影子记录
+	ownerConsumer gwutil.MessageConsumer // 集群消费写入请求
+	cache         *shadowCache
+	stripes       [shadowLockStripes]sync.Mutex // 本节点对同一设备的写入串行执行
+}
+
+func newShadowService(gateway *MqttGateway, querier messageQuerier) *shadowService {
+	config := gateway.config
+	service := &shadowService{
+		gateway: gateway,
+		querier: querier,
+		cache:   newShadowCache(config.ShadowCacheSize),
+	}
+
+	pushConsumer := process.NewDefaultMQPushConsumer(config.ConsumerGroup + "_SHADOW")
+	pushConsumer.SetConsumeFromWhere(heartbeat.CONSUME_FROM_LAST_OFFSET)
+	pushConsumer.SetMessageModel(heartbeat.BROADCASTING)
+	pushConsumer.SetNamesrvAddr(config.NamesrvAddr)
+	pushConsumer.Subscribe(config.ShadowTopic, shadowRecordTag)
+	pushConsumer.RegisterMessageListener(&shadowMessageListener{service: service})
+	service.consumer = pushConsumer
+
+	ownerConsumer := process.NewDefaultMQPushConsumer(config.ConsumerGroup + "_SHADOW_OWNER")
+	ownerConsumer.SetConsumeFromWhere(heartbeat.CONSUME_FROM_LAST_OFFSET)
+	ownerConsumer.SetMessageModel(heartbeat.CLUSTERING)
+	ownerConsumer.SetNamesrvAddr(config.NamesrvAddr)
+	ownerConsumer.Subscribe(config.ShadowTopic, shadowRequestTag)
+	ownerConsumer.RegisterMessageListener(&shadowRequestListener{service: service})
+	service.ownerConsumer = ownerConsumer
+	return service
+}
+
+func (service *shadowService) start() {
+	service.consumer.Start()
+	service.ownerConsumer.Start()
+}
+
+func (service *shadowService) shutdown() {
+	service.ownerConsumer.Shutdown()
+	service.consumer.Shutdown()
+}
+
+func (service *shadowService) topic() string {
+	return service.gateway.config.ShadowTopic
+}
+
+func (service *shadowService) stripe(deviceId string) *sync.Mutex {
+	return &service.stripes[crc32.ChecksumIEEE([]byte(deviceId))%shadowLockStripes]
+}
+
+// Get 查询影子文档，不存在或已删除时返回404
+// Author: agent
+// Since: 2026/10/19
+func (service *shadowService) Get(deviceId string) (*ShadowDocument, *ShadowError) {
+	if !validShadowDeviceId(deviceId) {
+		return nil, newShadowError(http.StatusBadRequest, "invalid deviceId %q", deviceId)
+	}
+	doc, err := service.load(deviceId, false)
+	if err != nil {
+		return nil, newShadowError(http.StatusServiceUnavailable, "load shadow failed: %s", err)
+	}
+	if doc == nil || doc.Deleted {
+		return nil, newShadowError(http.StatusNotFound, "shadow of %s not found", deviceId)
+	}
+	return doc, nil
+}
+
+// Update 合并更新影子文档，请求带version时须与当前版本一致，不存在的文档当前版本为0
+// Author: agent
+// Since: 2026/10/19
+func (service *shadowService) Update(deviceId string, request *ShadowUpdateRequest) (*ShadowDocument, *ShadowError) {
+	if !validShadowDeviceId(deviceId) {
+		return nil, newShadowError(http.StatusBadRequest, "invalid deviceId %q", deviceId)
+	}
+	return service.request(&shadowWriteRequest{Op: SHADOW_OP_UPDATE, DeviceId: deviceId, Update: request})
+}
+
+// Delete 删除影子文档：写入保留版本号的墓碑，之后的更新从墓碑的版本继续递增
+// Author: agent
+// Since: 2026/10/19
+func (service *shadowService) Delete(deviceId string, request *ShadowRequest) (*ShadowDocument, *ShadowError) {
+	if !validShadowDeviceId(deviceId) {
+		return nil, newShadowError(http.StatusBadRequest, "invalid deviceId %q", deviceId)
+	}
+	return service.request(&shadowWriteRequest{Op: SHADOW_OP_DELETE, DeviceId: deviceId, Version: request.Version})
+}
+
+// request 将写入请求发送给处理该设备的节点并等待结果，写入成功的文档在本节点立即生效
+func (service *shadowService) request(request *shadowWriteRequest) (*ShadowDocument, *ShadowError) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, newShadowError(http.StatusBadRequest, "encode shadow request failed: %s", err)
+	}
+	msg := message.NewMessage(service.topic(), shadowRequestTag, body)
+	replyMsg, err := service.gateway.producer.RequestByShardingKey(msg, request.DeviceId, shadowRequestTimeoutMill)
+	if err != nil {
+		logger.Errorf("mqtt request shadow %s of %s failed: %s", request.Op, request.DeviceId, err)
+		return nil, newShadowError(http.StatusServiceUnavailable, "write shadow failed: %s", err)
+	}
+
+	reply := &shadowWriteReply{}
+	if err := json.Unmarshal(replyMsg.Body, reply); err != nil {
+		return nil, newShadowError(http.StatusServiceUnavailable, "decode shadow reply failed: %s", err)
+	}
+	if reply.Error != nil {
+		return nil, reply.Error
+	}
+	if reply.Document == nil || reply.Document.DeviceId != request.DeviceId {
+		return nil, newShadowError(http.StatusServiceUnavailable, "invalid shadow reply of %s", request.DeviceId)
+	}
+	service.cache.put(reply.Document, reply.WriteTime, 0, true)
+	return reply.Document, nil
+}
+
+// handleWrite 处理消费到的写入请求，带应答地址的请求将结果应答给请求方；请求方已超时放弃的请求不再执行
+func (service *shadowService) handleWrite(msg *message.MessageExt) {
+	if deadline, err := strconv.ParseInt(msg.GetProperty(message.PROPERTY_REQUEST_DEADLINE), 10, 64); err == nil && timeutil.CurrentTimeMillis() > deadline {
+		logger.Warnf("mqtt skip expired shadow request %s", msg.MsgId)
+		return
+	}
+	request := &shadowWriteRequest{}
+	if err := json.Unmarshal(msg.Body, request); err != nil || !validShadowDeviceId(request.DeviceId) {
+		logger.Warnf("mqtt skip invalid shadow request %s: %v", msg.MsgId, err)
+		return
+	}
+
+	reply := &shadowWriteReply{}
+	reply.Document, reply.WriteTime, reply.Error = service.execute(request)
+	if msg.GetProperty(message.PROPERTY_CORRELATION_ID) == "" {
+		return
+	}
+	body, err := json.Marshal(reply)
+	if err != nil {
+		logger.Errorf("mqtt encode shadow reply of %s failed: %s", request.DeviceId, err)
+		return
+	}
+	if err := service.gateway.producer.SendReply(msg, body, shadowRequestTimeoutMill); err != nil {
+		logger.Warnf("mqtt reply shadow request %s of %s failed: %s", msg.MsgId, request.DeviceId, err)
+	}
+}
+
+// execute 比较版本后写入影子topic，返回写入的文档及写入时间
+func (service *shadowService) execute(request *shadowWriteRequest) (*ShadowDocument, int64, *ShadowError) {
+	stripe := service.stripe(request.DeviceId)
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	current, err := service.load(request.DeviceId, true)
+	if err != nil {
+		return nil, 0, newShadowError(http.StatusServiceUnavailable, "load shadow failed: %s", err)
+	}
+	var (
+		doc    *ShadowDocument
+		notify bool
+	)
+	switch request.Op {
+	case SHADOW_OP_UPDATE:
+		if request.Update == nil {
+			return nil, 0, newShadowError(http.StatusBadRequest, "update of %s is empty", request.DeviceId)
+		}
+		var currentVersion int64
+		if current != nil {
+			currentVersion = current.Version
+		}
+		if request.Update.Version != nil && *request.Update.Version != currentVersion {
+			return nil, 0, newShadowError(http.StatusConflict, "version conflict, current version is %d", currentVersion)
+		}
+		doc, notify = applyUpdate(current, request.Update)
+		doc.DeviceId = request.DeviceId
+		doc.Owner = service.gateway.name
+		doc.UpdateTime = timeutil.CurrentTimeMillis()
+	case SHADOW_OP_DELETE:
+		if current == nil || current.Deleted {
+			return nil, 0, newShadowError(http.StatusNotFound, "shadow of %s not found", request.DeviceId)
+		}
+		if request.Version != nil && *request.Version != current.Version {
+			return nil, 0, newShadowError(http.StatusConflict, "version conflict, current version is %d", current.Version)
+		}
+		doc = &ShadowDocument{DeviceId: request.DeviceId, Version: current.Version + 1, Owner: service.gateway.name, UpdateTime: timeutil.CurrentTimeMillis(), Deleted: true}
+	case shadowOpRefresh:
+		// 其他节点读取到长期未写入的文档，尚未重写时原样重写
+		if current == nil || current.Deleted || service.cache.writeTime(request.DeviceId) >= service.refreshBefore() {
+			return current, 0, nil
+		}
+		doc = current
+	default:
+		return nil, 0, newShadowError(http.StatusBadRequest, "unknown shadow op %s", request.Op)
+	}
+
+	writeTime, shadowErr := service.write(doc, notify)
+	if shadowErr != nil {
+		return nil, 0, shadowErr
+	}
+	return doc, writeTime, nil
+}
+
+// write 写入影子topic，发送成功后立即在本地生效
+func (service *shadowService) write(doc *ShadowDocument, notify bool) (int64, *ShadowError) {
+	writeTime := timeutil.CurrentTimeMillis()
+	body, err := json.Marshal(&shadowRecord{ShadowDocument: *doc, WriteTime: writeTime, Notify: notify})
+	if err != nil {
+		return 0, newShadowError(http.StatusBadRequest, "encode shadow failed: %s", err)
+	}
+	if len(body) > service.gateway.config.ShadowMaxSize {
+		return 0, newShadowError(http.StatusRequestEntityTooLarge, "shadow size %d exceeds %d", len(body), service.gateway.config.ShadowMaxSize)
+	}
+
+	msg := message.NewMessage(service.topic(), shadowRecordTag, body)
+	msg.SetKeys(doc.DeviceId)
+	if _, err := service.gateway.producer.Send(msg); err != nil {
+		logger.Errorf("mqtt write shadow of %s failed: %s", doc.DeviceId, err)
+		return 0, newShadowError(http.StatusServiceUnavailable, "write shadow failed: %s", err)
+	}
+	service.cache.put(doc, writeTime, writeTime, true)
+	return writeTime, nil
+}
+
+// load 取缓存中的文档，缓存中没有或超过shadowCacheTTLMill未确认时按设备ID查询影子topic的消息索引，返回版本最新的文档
+// (可能是墓碑)，不存在时返回nil；forWrite为true时只信任本节点写入的文档，避免以其他节点写入后尚未消费到的旧文档为基础写入
+func (service *shadowService) load(deviceId string, forWrite bool) (*ShadowDocument, error) {
+	now := timeutil.CurrentTimeMillis()
+	if doc, ok := service.cache.get(deviceId, now-shadowCacheTTLMill); ok && (!forWrite || doc.Owner == service.gateway.name) {
+		return doc, nil
+	}
+
+	msgs, err := service.querier.QueryMessage(service.topic(), deviceId, shadowQueryMaxNum, 0, now)
+	if err != nil {
+		return nil, err
+	}
+	var latest *shadowRecord
+	for _, msg := range msgs {
+		if msg.Topic != service.topic() || msg.GetTags() != shadowRecordTag {
+			continue
+		}
+		record := &shadowRecord{}
+		if err := json.Unmarshal(msg.Body, record); err != nil || record.DeviceId != deviceId {
+			continue
+		}
+		if latest == nil || record.NewerThan(&latest.ShadowDocument) {
+			latest = record
+		}
+	}
+	if latest == nil {
+		// 查询期间可能已写入或消费到文档
+		doc, _ := service.cache.get(deviceId, 0)
+		return doc, nil
+	}
+	if !forWrite && !latest.Deleted && latest.WriteTime < service.refreshBefore() {
+		go service.requestRefresh(deviceId)
+	}
+
+	// 查询期间可能已消费到更新的文档，以缓存中最新的为准
+	doc, _ := service.cache.put(&latest.ShadowDocument, latest.WriteTime, now, true)
+	return doc, nil
+}
+
+// refreshBefore 早于该时间写入的文档需要重写
+func (service *shadowService) refreshBefore() int64 {
+	return timeutil.CurrentTimeMillis() - int64(service.gateway.config.ShadowRefreshInterval)*1000
+}
+
+// requestRefresh 请求处理该设备的节点重写文档，用于最近写入文档的节点已下线或已淘汰该文档的情况
+func (service *shadowService) requestRefresh(deviceId string) {
+	body, err := json.Marshal(&shadowWriteRequest{Op: shadowOpRefresh, DeviceId: deviceId})
+	if err != nil {
+		return
+	}
+	msg := message.NewMessage(service.topic(), shadowRequestTag, body)
+	if _, err := service.gateway.producer.SendByShardingKey(msg, deviceId); err != nil {
+		logger.Warnf("mqtt request refresh shadow of %s failed: %s", deviceId, err)
+	}
+}
+
+// consume 消费影子topic中的记录：更新已缓存的文档，期望状态有变化且不旧于缓存的文档通知在本节点在线的设备
+func (service *shadowService) consume(msg *message.MessageExt) {
+	record := &shadowRecord{}
+	if err := json.Unmarshal(msg.Body, record); err != nil || !validShadowDeviceId(record.DeviceId) {
+		logger.Warnf("mqtt skip invalid shadow record %s: %v", msg.MsgId, err)
+		return
+	}
+	doc := &record.ShadowDocument
+	if _, latest := service.cache.put(doc, record.WriteTime, 0, false); latest && record.Notify && !doc.Deleted {
+		service.notifyDelta(doc)
+	}
+}
+
+// onConnect 设备连接后查询其影子，期望状态与上报状态不一致时发送delta
+func (service *shadowService) onConnect(deviceId string) {
+	if !validShadowDeviceId(deviceId) {
+		return
+	}
+	go func() {
+		doc, err := service.load(deviceId, false)
+		if err != nil {
+			logger.Warnf("mqtt load shadow of %s on connect failed: %s", deviceId, err)
+			return
+		}
+		if doc != nil && !doc.Deleted {
+			service.notifyDelta(doc)
+		}
+	}()
+}
+
+// notifyDelta 以QoS1向本节点在线的设备发送delta，与设备是否订阅无关
+func (service *shadowService) notifyDelta(doc *ShadowDocument) {
+	session := service.gateway.connectedSession(doc.DeviceId)
+	if session == nil {
+		return
+	}
+	delta := doc.Delta()
+	if delta == nil {
+		return
+	}
+	payload, err := json.Marshal(&ShadowDelta{State: delta, Version: doc.Version, Timestamp: doc.UpdateTime})
+	if err != nil {
+		logger.Errorf("mqtt encode shadow delta of %s failed: %s", doc.DeviceId, err)
+		return
+	}
+	topic := shadowReplyTopic(doc.DeviceId, SHADOW_OP_UPDATE, "delta")
+	session.deliver(&PublishPacket{Qos: 1, TopicName: topic, Payload: payload}, nil, 0)
+}
+
+// handleRequest 处理设备发布到$shadow/{deviceId}/{op}的请求，应答发布到.../{op}/accepted或.../{op}/rejected；
+// 设备只能操作自己的影子
+// Author: agent
+// Since: 2026/10/19
+func (service *shadowService) handleRequest(session *Session, p *PublishPacket) {
+	deviceId, op, ok := parseShadowTopic(p.TopicName)
+	if !ok {
+		logger.Warnf("mqtt client %s publish to unknown shadow topic %s, dropped", session.ClientId(), p.TopicName)
+		return
+	}
+
+	var (
+		doc         *ShadowDocument
+		shadowErr   *ShadowError
+		clientToken string
+	)
+	if deviceId != session.ClientId() {
+		shadowErr = newShadowError(http.StatusForbidden, "client %s can not access shadow of %s", session.ClientId(), deviceId)
+	} else if len(p.Payload) > service.gateway.config.ShadowMaxSize {
+		shadowErr = newShadowError(http.StatusRequestEntityTooLarge, "request size %d exceeds %d", len(p.Payload), service.gateway.config.ShadowMaxSize)
+	} else if op == SHADOW_OP_UPDATE {
+		var request *ShadowUpdateRequest
+		if request, shadowErr = decodeShadowUpdate(p.Payload); request != nil {
+			clientToken = request.ClientToken
+		}
+		if shadowErr == nil {
+			doc, shadowErr = service.Update(deviceId, request)
+		}
+	} else {
+		var request *ShadowRequest
+		if request, shadowErr = decodeShadowRequest(p.Payload); request != nil {
+			clientToken = request.ClientToken
+		}
+		if shadowErr == nil && op == SHADOW_OP_GET {
+			doc, shadowErr = service.Get(deviceId)
+		} else if shadowErr == nil {
+			doc, shadowErr = service.Delete(deviceId, request)
+		}
+	}
+
+	var (
+		reply interface{}
+		topic string
+	)
+	if shadowErr != nil {
+		shadowErr.ClientToken = clientToken
+		reply, topic = shadowErr, shadowReplyTopic(deviceId, op, "rejected")
+	} else if doc.Deleted {
+		reply, topic = &ShadowView{Version: doc.Version, Timestamp: doc.UpdateTime, ClientToken: clientToken}, shadowReplyTopic(deviceId, op, "accepted")
+	} else {
+		reply, topic = newShadowView(doc, clientToken), shadowReplyTopic(deviceId, op, "accepted")
+	}
+	payload, err := json.Marshal(reply)
+	if err != nil {
+		logger.Errorf("mqtt encode shadow reply of %s failed: %s", deviceId, err)
+		return
+	}
+
+	qos := p.Qos
+	if qos > 1 {
+		qos = 1
+	}
+	session.deliver(&PublishPacket{Qos: qos, TopicName: topic, Payload: payload}, nil, 0)
+}
+
+// refresh 重写本节点最近写入且长期未写入的影子文档，避免被broker按保留时间删除；其他节点写入后由其负责重写
+func (service *shadowService) refresh(beforeMillis int64) {
+	docs := service.cache.stale(service.gateway.name, beforeMillis)
+	for _, doc := range docs {
+		if _, err := service.write(doc, false); err != nil {
+			logger.Warnf("mqtt refresh shadow of %s failed: %s", doc.DeviceId, err)
+			return
+		}
+	}
+	if len(docs) > 0 {
+		logger.Infof("mqtt refresh %d shadow documents", len(docs))
+	}
+}
+
+// prune 清理缓存中早于指定时间的墓碑
+func (service *shadowService) prune(beforeMillis int64) {
+	service.cache.prune(beforeMillis)
+}
+
+// shadowMessageListener 影子topic的广播消费监听
+type shadowMessageListener struct {
+	service *shadowService
+}
+
+func (l *shadowMessageListener) ConsumeMessage(msgs []*message.MessageExt, context *consumer.ConsumeConcurrentlyContext) listener.ConsumeConcurrentlyStatus {
+	for _, msg := range msgs {
+		l.service.consume(msg)
+	}
+	return listener.CONSUME_SUCCESS
+}
+
+// shadowRequestListener 影子写入请求的集群消费监听
+type shadowRequestListener struct {
+	service *shadowService
+}
+
+func (l *shadowRequestListener) ConsumeMessage(msgs []*message.MessageExt, context *consumer.ConsumeConcurrentlyContext) listener.ConsumeConcurrentlyStatus {
+	for _, msg := range msgs {
+		l.service.handleWrite(msg)
+	}
+	return listener.CONSUME_SUCCESS
+}
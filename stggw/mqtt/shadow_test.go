@@ -0,0 +1,338 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+)
+
+func newShadowGatewayConfig() *GatewayConfig {
+	cfg := NewGatewayConfig()
+	cfg.ShadowEnable = true
+	cfg.ShadowServerAddr = "127.0.0.1:0"
+	return cfg
+}
+
+// shadowRequest 发送影子HTTP请求，返回状态码及解析后的应答
+func shadowRequest(t *testing.T, gateway *MqttGateway, method, deviceId, query, body string) (int, map[string]interface{}) {
+	url := "http://" + gateway.shadowServer.Addr() + "/shadows/" + deviceId + query
+	request, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	result := make(map[string]interface{})
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, result
+}
+
+// receiveShadow 接收应答并校验主题
+func receiveShadow(t *testing.T, client *Client, topic string) map[string]interface{} {
+	p := receive(t, client)
+	if p.TopicName != topic {
+		t.Fatalf("expect %s, got %s %s", topic, p.TopicName, p.Payload)
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal(p.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestShadowApplyUpdate(t *testing.T) {
+	request, shadowErr := decodeShadowUpdate([]byte(`{"state":{"desired":{"led":"on","config":{"interval":10,"mode":"eco"}},"reported":{"led":"off"}}}`))
+	if shadowErr != nil {
+		t.Fatal(shadowErr)
+	}
+	doc, desiredChanged := applyUpdate(nil, request)
+	if doc.Version != 1 || !desiredChanged {
+		t.Fatalf("unexpected doc %+v, desiredChanged=%t", doc, desiredChanged)
+	}
+	expect := map[string]interface{}{"led": "on", "config": map[string]interface{}{"interval": float64(10), "mode": "eco"}}
+	if delta := doc.Delta(); !reflect.DeepEqual(delta, expect) {
+		t.Fatalf("unexpected delta %v", delta)
+	}
+
+	// 嵌套对象逐层合并，null删除key，嵌套对象逐层比较差异
+	request, _ = decodeShadowUpdate([]byte(`{"state":{"reported":{"led":"on","config":{"interval":10,"mode":"eco"}},"desired":{"config":{"mode":null}}}}`))
+	doc, desiredChanged = applyUpdate(doc, request)
+	if doc.Version != 2 || !desiredChanged || doc.Delta() != nil {
+		t.Fatalf("unexpected doc %+v, delta %v", doc, doc.Delta())
+	}
+	if expect := map[string]interface{}{"led": "on", "config": map[string]interface{}{"interval": float64(10)}}; !reflect.DeepEqual(doc.State.Desired, expect) {
+		t.Fatalf("unexpected desired %v", doc.State.Desired)
+	}
+
+	// 墓碑上的更新从空状态开始，版本继续递增
+	request, _ = decodeShadowUpdate([]byte(`{"state":{"reported":{"led":"off"}}}`))
+	doc, desiredChanged = applyUpdate(&ShadowDocument{Version: 5, Deleted: true, State: doc.State}, request)
+	if doc.Version != 6 || desiredChanged || doc.State.Desired != nil || doc.Deleted {
+		t.Fatalf("unexpected doc %+v", doc)
+	}
+
+	for _, data := range []string{`{}`, `{"state":{"unknown":{}}}`, `{"state":{"desired":1}}`, `{"state":`} {
+		if _, shadowErr := decodeShadowUpdate([]byte(data)); shadowErr == nil || shadowErr.Code != http.StatusBadRequest {
+			t.Fatalf("expect bad request for %s, got %v", data, shadowErr)
+		}
+	}
+}
+
+func TestShadowTopic(t *testing.T) {
+	if deviceId, op, ok := parseShadowTopic("$shadow/device-1/update"); !ok || deviceId != "device-1" || op != SHADOW_OP_UPDATE {
+		t.Fatalf("unexpected parse result %s %s %t", deviceId, op, ok)
+	}
+	for _, topic := range []string{"$shadow/device-1", "$shadow/device-1/update/accepted", "$shadow//get", "$shadow/device-1/put", "shadow/device-1/get"} {
+		if _, _, ok := parseShadowTopic(topic); ok {
+			t.Fatalf("expect invalid shadow topic %s", topic)
+		}
+	}
+}
+
+func TestShadowOverMqtt(t *testing.T) {
+	broker := newFakeBroker()
+	gateway, addr := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gateway.Shutdown()
+
+	device := dialTestClient(t, addr, "device-1", 60)
+	defer device.Disconnect()
+	if err := device.Publish("$shadow/device-1/get", 1, []byte(`{"clientToken":"t0"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if reply := receiveShadow(t, device, "$shadow/device-1/get/rejected"); reply["code"] != float64(http.StatusNotFound) || reply["clientToken"] != "t0" {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+
+	if err := device.Publish("$shadow/device-1/update", 1, []byte(`{"state":{"reported":{"led":"off"}},"version":0,"clientToken":"t1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if reply := receiveShadow(t, device, "$shadow/device-1/update/accepted"); reply["version"] != float64(1) || reply["clientToken"] != "t1" {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+	if broker.countTag(gateway.config.ShadowTopic, shadowRecordTag) != 1 || broker.lastSent().GetKeys() != "device-1" {
+		t.Fatalf("shadow not written to %s", gateway.config.ShadowTopic)
+	}
+
+	// 版本不一致时拒绝更新
+	if err := device.Publish("$shadow/device-1/update", 1, []byte(`{"state":{"reported":{"led":"on"}},"version":0}`)); err != nil {
+		t.Fatal(err)
+	}
+	if reply := receiveShadow(t, device, "$shadow/device-1/update/rejected"); reply["code"] != float64(http.StatusConflict) {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+
+	// 设备只能操作自己的影子
+	if err := device.Publish("$shadow/device-2/update", 1, []byte(`{"state":{"reported":{"led":"on"}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if reply := receiveShadow(t, device, "$shadow/device-2/update/rejected"); reply["code"] != float64(http.StatusForbidden) {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+
+	// 应用修改期望状态后，在线设备收到delta
+	status, view := shadowRequest(t, gateway, http.MethodPost, "device-1", "", `{"state":{"desired":{"led":"on"}},"version":1}`)
+	if status != http.StatusOK || view["version"] != float64(2) {
+		t.Fatalf("unexpected update response %d %v", status, view)
+	}
+	delta := receiveShadow(t, device, "$shadow/device-1/update/delta")
+	if !reflect.DeepEqual(delta["state"], map[string]interface{}{"led": "on"}) || delta["version"] != float64(2) {
+		t.Fatalf("unexpected delta %v", delta)
+	}
+
+	// 设备上报后期望状态与上报状态一致，不再有delta
+	if err := device.Publish("$shadow/device-1/update", 1, []byte(`{"state":{"reported":{"led":"on"}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	reply := receiveShadow(t, device, "$shadow/device-1/update/accepted")
+	if state := reply["state"].(map[string]interface{}); state["delta"] != nil || reply["version"] != float64(3) {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+	expectNoMessage(t, device)
+
+	if err := device.Publish("$shadow/device-1/delete", 1, []byte(`{"version":3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if reply := receiveShadow(t, device, "$shadow/device-1/delete/accepted"); reply["version"] != float64(4) {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+	if status, result := shadowRequest(t, gateway, http.MethodGet, "device-1", "", ""); status != http.StatusNotFound {
+		t.Fatalf("unexpected get response %d %v", status, result)
+	}
+}
+
+func TestShadowDeltaOnReconnect(t *testing.T) {
+	broker := newFakeBroker()
+	gatewayA, addrA := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gatewayA.Shutdown()
+
+	device := dialTestClient(t, addrA, "device-1", 60)
+	if err := device.Publish("$shadow/device-1/update", 1, []byte(`{"state":{"reported":{"led":"off","interval":10}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	receiveShadow(t, device, "$shadow/device-1/update/accepted")
+	device.Disconnect()
+
+	// 设备离线期间修改期望状态，新启动的节点从影子topic按key查询文档
+	status, view := shadowRequest(t, gatewayA, http.MethodPost, "device-1", "", `{"state":{"desired":{"led":"on","interval":10}}}`)
+	if status != http.StatusOK || view["version"] != float64(2) {
+		t.Fatalf("unexpected update response %d %v", status, view)
+	}
+	gatewayB, addrB := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+	if status, view := shadowRequest(t, gatewayB, http.MethodGet, "device-1", "?pretty", ""); status != http.StatusOK || view["version"] != float64(2) {
+		t.Fatalf("unexpected get response %d %v", status, view)
+	}
+
+	device = dialTestClient(t, addrB, "device-1", 60)
+	defer device.Disconnect()
+	delta := receiveShadow(t, device, "$shadow/device-1/update/delta")
+	if !reflect.DeepEqual(delta["state"], map[string]interface{}{"led": "on"}) || delta["version"] != float64(2) {
+		t.Fatalf("unexpected delta %v", delta)
+	}
+
+	// 两个节点的缓存一致，旧版本的删除被拒绝
+	if status, result := shadowRequest(t, gatewayA, http.MethodDelete, "device-1", "?version=1", ""); status != http.StatusConflict {
+		t.Fatalf("unexpected delete response %d %v", status, result)
+	}
+	if status, result := shadowRequest(t, gatewayA, http.MethodDelete, "device-1", "?version=2", ""); status != http.StatusOK || result["version"] != float64(3) {
+		t.Fatalf("unexpected delete response %d %v", status, result)
+	}
+	if status, result := shadowRequest(t, gatewayB, http.MethodPost, "device-1", "", `{"state":{"reported":{"led":"on"}},"version":3}`); status != http.StatusOK || result["version"] != float64(4) {
+		t.Fatalf("unexpected update response %d %v", status, result)
+	}
+}
+
+func TestShadowConcurrentUpdate(t *testing.T) {
+	broker := newFakeBroker()
+	gatewayA, _ := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gatewayA.Shutdown()
+	gatewayB, _ := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+	if _, shadowErr := gatewayA.shadow.Update("device-1", &ShadowUpdateRequest{State: map[string]interface{}{"reported": map[string]interface{}{"led": "off"}}}); shadowErr != nil {
+		t.Fatal(shadowErr)
+	}
+
+	// 两个节点以相同版本同时更新，由同一个节点比较版本，只有一个请求成功
+	var (
+		wg        sync.WaitGroup
+		lock      sync.Mutex
+		accepted  int
+		conflicts int
+	)
+	version := int64(1)
+	for i := 0; i < 10; i++ {
+		gateway := gatewayA
+		if i%2 == 1 {
+			gateway = gatewayB
+		}
+		wg.Add(1)
+		go func(gateway *MqttGateway, i int) {
+			defer wg.Done()
+			request := &ShadowUpdateRequest{State: map[string]interface{}{"desired": map[string]interface{}{"led": i}}, Version: &version}
+			_, shadowErr := gateway.shadow.Update("device-1", request)
+			lock.Lock()
+			defer lock.Unlock()
+			if shadowErr == nil {
+				accepted++
+			} else if shadowErr.Code == http.StatusConflict {
+				conflicts++
+			}
+		}(gateway, i)
+	}
+	wg.Wait()
+	if accepted != 1 || conflicts != 9 {
+		t.Fatalf("expect 1 accepted and 9 conflicts, got %d and %d", accepted, conflicts)
+	}
+	if count := broker.countTag(gatewayA.config.ShadowTopic, shadowRecordTag); count != 2 {
+		t.Fatalf("expect 2 shadow records, got %d", count)
+	}
+	for _, gateway := range []*MqttGateway{gatewayA, gatewayB} {
+		if doc, shadowErr := gateway.shadow.Get("device-1"); shadowErr != nil || doc.Version != 2 {
+			t.Fatalf("unexpected shadow %v %v", doc, shadowErr)
+		}
+	}
+}
+
+func TestShadowCache(t *testing.T) {
+	cache := newShadowCache(2)
+	for i, deviceId := range []string{"device-1", "device-2"} {
+		cache.put(&ShadowDocument{DeviceId: deviceId, Version: 1, Owner: "node-a"}, int64(i), 100, true)
+	}
+	// 访问device-1后插入device-3，淘汰最久未访问的device-2
+	if _, ok := cache.get("device-1", 0); !ok {
+		t.Fatal("device-1 not cached")
+	}
+	cache.put(&ShadowDocument{DeviceId: "device-3", Version: 1, Owner: "node-b"}, 10, 100, true)
+	if _, ok := cache.get("device-2", 0); ok {
+		t.Fatal("expect device-2 evicted")
+	}
+	if _, ok := cache.get("device-1", 101); ok {
+		t.Fatal("expect device-1 not confirmed after 101")
+	}
+
+	// 消费到的记录只更新已缓存的文档，不更新确认时间
+	if _, latest := cache.put(&ShadowDocument{DeviceId: "device-4", Version: 1}, 20, 0, false); !latest {
+		t.Fatal("expect uncached record to be latest")
+	}
+	if _, ok := cache.get("device-4", 0); ok {
+		t.Fatal("expect device-4 not cached")
+	}
+	doc, latest := cache.put(&ShadowDocument{DeviceId: "device-1", Version: 2, Owner: "node-b"}, 30, 0, false)
+	if !latest || doc.Version != 2 {
+		t.Fatalf("unexpected put result %v %t", doc, latest)
+	}
+	if _, latest := cache.put(&ShadowDocument{DeviceId: "device-1", Version: 1, Owner: "node-a"}, 40, 0, false); latest {
+		t.Fatal("expect older record not latest")
+	}
+	if doc, ok := cache.get("device-1", 100); !ok || doc.Version != 2 {
+		t.Fatalf("unexpected cached shadow %v", doc)
+	}
+
+	// 只重写本节点最近写入的文档
+	if docs := cache.stale("node-b", 31); len(docs) != 2 {
+		t.Fatalf("expect 2 stale shadows of node-b, got %d", len(docs))
+	}
+	if docs := cache.stale("node-a", 31); len(docs) != 0 {
+		t.Fatalf("expect no stale shadows of node-a, got %d", len(docs))
+	}
+}
+
+func TestShadowRefreshByOwner(t *testing.T) {
+	broker := newFakeBroker()
+	gatewayA, _ := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gatewayA.Shutdown()
+	gatewayB, _ := startTestGateway(t, newShadowGatewayConfig(), broker)
+	defer gatewayB.Shutdown()
+	doc, shadowErr := gatewayA.shadow.Update("device-1", &ShadowUpdateRequest{State: map[string]interface{}{"reported": map[string]interface{}{"led": "off"}}})
+	if shadowErr != nil {
+		t.Fatal(shadowErr)
+	}
+	if _, shadowErr := gatewayB.shadow.Get("device-1"); shadowErr != nil {
+		t.Fatal(shadowErr)
+	}
+
+	// 两个节点都缓存了文档，只有写入文档的节点重写
+	writer, other := gatewayA, gatewayB
+	if doc.Owner == gatewayB.name {
+		writer, other = gatewayB, gatewayA
+	}
+	records := broker.countTag(writer.config.ShadowTopic, shadowRecordTag)
+	other.shadow.refresh(timeutil.CurrentTimeMillis() + 1)
+	if count := broker.countTag(writer.config.ShadowTopic, shadowRecordTag); count != records {
+		t.Fatalf("expect no refresh from other node, got %d records", count)
+	}
+	writer.shadow.refresh(timeutil.CurrentTimeMillis() + 1)
+	if count := broker.countTag(writer.config.ShadowTopic, shadowRecordTag); count != records+1 {
+		t.Fatalf("expect refresh from writer, got %d records", count)
+	}
+}
@@ -0,0 +1,175 @@
+package mqtt
+
+import (
+	"strings"
+	"sync"
+)
+
+// ValidTopicName 校验发布使用的主题名：非空且不含通配符
+// Author: agent
+// Since: 2026/10/19
+func ValidTopicName(topic string) bool {
+	return topic != "" && !strings.ContainsAny(topic, "+#\x00")
+}
+
+// ValidTopicFilter 校验订阅使用的主题过滤器：'+'必须独占一级，'#'必须独占最后一级
+// Author: agent
+// Since: 2026/10/19
+func ValidTopicFilter(filter string) bool {
+	if filter == "" || strings.Contains(filter, "\x00") {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return false
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return false
+		}
+	}
+	return true
+}
+
+// HasWildcard 主题过滤器是否包含通配符
+// Author: agent
+// Since: 2026/10/19
+func HasWildcard(filter string) bool {
+	return strings.ContainsAny(filter, "+#")
+}
+
+// MatchTopic 判断主题名是否匹配主题过滤器，以'$'开头的主题不被首级通配符匹配
+// Author: agent
+// Since: 2026/10/19
+func MatchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// subscriptionTree 按主题层级组织的订阅树，匹配时只遍历与主题相关的分支
+// Author: agent
+// Since: 2026/10/19
+type subscriptionTree struct {
+	root *subscriptionNode
+	lock sync.RWMutex
+}
+
+type subscriptionNode struct {
+	children    map[string]*subscriptionNode
+	subscribers map[*Session]byte // 订阅者 -> 授予的QoS
+}
+
+func newSubscriptionNode() *subscriptionNode {
+	return &subscriptionNode{
+		children:    make(map[string]*subscriptionNode),
+		subscribers: make(map[*Session]byte),
+	}
+}
+
+func newSubscriptionTree() *subscriptionTree {
+	return &subscriptionTree{root: newSubscriptionNode()}
+}
+
+// Subscribe 添加订阅，重复订阅同一过滤器时覆盖QoS
+func (tree *subscriptionTree) Subscribe(filter string, session *Session, qos byte) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	node := tree.root
+	for _, level := range strings.Split(filter, "/") {
+		child, ok := node.children[level]
+		if !ok {
+			child = newSubscriptionNode()
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.subscribers[session] = qos
+}
+
+// Unsubscribe 删除订阅，并清理不再使用的分支
+func (tree *subscriptionTree) Unsubscribe(filter string, session *Session) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	levels := strings.Split(filter, "/")
+	path := make([]*subscriptionNode, 0, len(levels)+1)
+	node := tree.root
+	path = append(path, node)
+	for _, level := range levels {
+		child, ok := node.children[level]
+		if !ok {
+			return
+		}
+		node = child
+		path = append(path, node)
+	}
+	delete(node.subscribers, session)
+
+	for i := len(levels) - 1; i >= 0; i-- {
+		child := path[i+1]
+		if len(child.subscribers) > 0 || len(child.children) > 0 {
+			break
+		}
+		delete(path[i].children, levels[i])
+	}
+}
+
+// Match 查找匹配主题的订阅者，同一订阅者匹配多个过滤器时取最大QoS
+func (tree *subscriptionTree) Match(topic string) map[*Session]byte {
+	tree.lock.RLock()
+	defer tree.lock.RUnlock()
+
+	result := make(map[*Session]byte)
+	levels := strings.Split(topic, "/")
+	tree.match(tree.root, levels, 0, strings.HasPrefix(topic, "$"), result)
+	return result
+}
+
+func (tree *subscriptionTree) match(node *subscriptionNode, levels []string, index int, system bool, result map[*Session]byte) {
+	wildcardAllowed := !(system && index == 0)
+
+	if wildcardAllowed {
+		if child, ok := node.children["#"]; ok {
+			collectSubscribers(child, result)
+		}
+	}
+	if index == len(levels) {
+		collectSubscribers(node, result)
+		return
+	}
+
+	if child, ok := node.children[levels[index]]; ok {
+		tree.match(child, levels, index+1, system, result)
+	}
+	if wildcardAllowed {
+		if child, ok := node.children["+"]; ok {
+			tree.match(child, levels, index+1, system, result)
+		}
+	}
+}
+
+func collectSubscribers(node *subscriptionNode, result map[*Session]byte) {
+	for session, qos := range node.subscribers {
+		if old, ok := result[session]; !ok || qos > old {
+			result[session] = qos
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package mqtt
+
+import (
+	"testing"
+)
+
+func TestValidTopic(t *testing.T) {
+	validFilters := []string{"#", "+", "a/b", "a/+/c", "a/#", "+/+", "/", "a//b"}
+	invalidFilters := []string{"", "a#", "a/#/b", "a/b+", "a/+b/c", "#/a"}
+	for _, filter := range validFilters {
+		if !ValidTopicFilter(filter) {
+			t.Fatalf("filter %q should be valid", filter)
+		}
+	}
+	for _, filter := range invalidFilters {
+		if ValidTopicFilter(filter) {
+			t.Fatalf("filter %q should be invalid", filter)
+		}
+	}
+
+	if !ValidTopicName("a/b") || ValidTopicName("a/+") || ValidTopicName("a/#") || ValidTopicName("") {
+		t.Fatalf("unexpected topic name validation")
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"sport/tennis/player1", "sport/tennis/player1", true},
+		{"sport/tennis/player1", "sport/tennis/player2", false},
+		{"sport/#", "sport", true},
+		{"sport/#", "sport/tennis/player1", true},
+		{"sport/tennis/+", "sport/tennis/player1", true},
+		{"sport/tennis/+", "sport/tennis/player1/ranking", false},
+		{"sport/+", "sport", false},
+		{"sport/+", "sport/", true},
+		{"+/+", "/finance", true},
+		{"/+", "/finance", true},
+		{"+", "/finance", false},
+		{"#", "a/b/c", true},
+		{"#", "$SYS/broker", false},
+		{"+/broker", "$SYS/broker", false},
+		{"$SYS/#", "$SYS/broker", true},
+	}
+
+	tree := newSubscriptionTree()
+	for _, c := range cases {
+		if MatchTopic(c.filter, c.topic) != c.match {
+			t.Fatalf("MatchTopic(%q, %q) expect %t", c.filter, c.topic, c.match)
+		}
+
+		session := &Session{}
+		tree.Subscribe(c.filter, session, 1)
+		_, ok := tree.Match(c.topic)[session]
+		if ok != c.match {
+			t.Fatalf("subscriptionTree filter %q topic %q expect %t", c.filter, c.topic, c.match)
+		}
+		tree.Unsubscribe(c.filter, session)
+	}
+	if len(tree.root.children) != 0 {
+		t.Fatalf("subscriptionTree should be empty after unsubscribe, got %d children", len(tree.root.children))
+	}
+}
+
+func TestSubscriptionTreeMaxQos(t *testing.T) {
+	tree := newSubscriptionTree()
+	s1, s2 := &Session{}, &Session{}
+	tree.Subscribe("devices/+/telemetry", s1, 0)
+	tree.Subscribe("devices/#", s1, 1)
+	tree.Subscribe("devices/42/telemetry", s2, 0)
+
+	result := tree.Match("devices/42/telemetry")
+	if len(result) != 2 || result[s1] != 1 || result[s2] != 0 {
+		t.Fatalf("unexpected match result %v", result)
+	}
+
+	tree.Unsubscribe("devices/#", s1)
+	if result = tree.Match("devices/42/telemetry"); result[s1] != 0 {
+		t.Fatalf("s1 qos should fall back to 0, got %d", result[s1])
+	}
+	if result = tree.Match("devices/42/cmd"); len(result) != 0 {
+		t.Fatalf("unexpected match result %v", result)
+	}
+}
+
+func TestTopicMapper(t *testing.T) {
+	mapper, err := NewTopicMapper([]*MappingRule{
+		{Filter: "devices/+/telemetry", Topic: "DeviceTelemetry", Tags: "telemetry"},
+		{Filter: "devices/+/event", Topic: "DeviceTelemetry", Tags: "event"},
+		{Filter: "devices/broadcast", Topic: "DeviceCommand"},
+		{Filter: "devices/#", Topic: "DeviceCommand"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, ok := mapper.Match("devices/42/telemetry")
+	if !ok || rule.Topic != "DeviceTelemetry" || rule.Tags != "telemetry" {
+		t.Fatalf("unexpected rule %v", rule)
+	}
+	if rule, ok = mapper.Match("devices/42/cmd"); !ok || rule.Topic != "DeviceCommand" {
+		t.Fatalf("unexpected rule %v", rule)
+	}
+	if _, ok = mapper.Match("others/42"); ok {
+		t.Fatalf("others/42 should not match any rule")
+	}
+
+	expressions := mapper.SubscribeExpressions()
+	if expressions["DeviceTelemetry"] != "event || telemetry" || expressions["DeviceCommand"] != "*" || len(expressions) != 2 {
+		t.Fatalf("unexpected subscribe expressions %v", expressions)
+	}
+
+	if topic := mapper.ResolveMqttTopic("DeviceCommand", ""); topic != "devices/broadcast" {
+		t.Fatalf("unexpected resolved topic %s", topic)
+	}
+	if topic := mapper.ResolveMqttTopic("DeviceTelemetry", "telemetry"); topic != "DeviceTelemetry" {
+		t.Fatalf("unexpected resolved topic %s", topic)
+	}
+
+	if _, err = NewTopicMapper([]*MappingRule{{Filter: "a/#/b", Topic: "T"}}); err == nil {
+		t.Fatalf("invalid filter should be rejected")
+	}
+	if _, err = NewTopicMapper([]*MappingRule{{Filter: "a", Topic: "T", Tags: "x || y"}}); err == nil {
+		t.Fatalf("multiple tags should be rejected")
+	}
+}
@@ -0,0 +1,410 @@
+package mqtt
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket(RFC 6455)相关常量，MQTT over WebSocket使用mqtt子协议及二进制帧
+const (
+	WS_SUBPROTOCOL = "mqtt"
+	wsAcceptGUID   = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsVersion      = "13"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsMaxControlPayload = 125
+
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseUnsupported   = 1003
+)
+
+// wsConn 将WebSocket连接适配为net.Conn：二进制帧(含分片)的负载按字节流读取，每次写入封装为一个二进制帧，
+// 自动回复ping，收到close帧时回复close并返回io.EOF。MQTT报文可跨帧，也可一帧多个报文
+// Author: agent
+// Since: 2026/10/19
+type wsConn struct {
+	net.Conn
+	reader     *bufio.Reader // 握手时可能已预读了帧数据
+	client     bool          // 客户端发送的帧须加掩码，服务端发送的帧不加掩码
+	remaining  int64         // 当前数据帧未读的负载字节数，负载不整体缓存，报文大小由MQTT解码限制
+	mask       [4]byte
+	masked     bool
+	maskPos    int
+	fragmented bool // 分片消息未结束，后续须为continuation帧
+	readErr    error
+	writeLock  sync.Mutex
+	closeOnce  sync.Once
+}
+
+func newWsConn(conn net.Conn, reader *bufio.Reader, client bool) *wsConn {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+	return &wsConn{Conn: conn, reader: reader, client: client}
+}
+
+// Read 读取数据帧负载，控制帧在读取时处理
+func (c *wsConn) Read(b []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		if err := c.nextFrame(); err != nil {
+			c.readErr = err
+			return 0, err
+		}
+	}
+
+	if int64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+	n, err := c.reader.Read(b)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			b[i] ^= c.mask[c.maskPos&3]
+			c.maskPos++
+		}
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame 读取下一个帧头，数据帧设置待读负载，控制帧直接处理
+func (c *wsConn) nextFrame() error {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	if header[0]&0x70 != 0 {
+		return c.fail(wsCloseProtocolError, "reserved bits set")
+	}
+	if masked == c.client {
+		return c.fail(wsCloseProtocolError, "invalid frame mask")
+	}
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return c.fail(wsCloseProtocolError, "invalid payload length")
+		}
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return err
+		}
+	}
+
+	if opcode >= wsOpClose {
+		if !fin || length > wsMaxControlPayload {
+			return c.fail(wsCloseProtocolError, "invalid control frame")
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i&3]
+			}
+		}
+		return c.handleControl(opcode, payload)
+	}
+
+	switch opcode {
+	case wsOpBinary:
+		if c.fragmented {
+			return c.fail(wsCloseProtocolError, "expect continuation frame")
+		}
+	case wsOpContinuation:
+		if !c.fragmented {
+			return c.fail(wsCloseProtocolError, "unexpected continuation frame")
+		}
+	case wsOpText:
+		return c.fail(wsCloseUnsupported, "text frame not supported")
+	default:
+		return c.fail(wsCloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+	}
+	c.fragmented = !fin
+	c.remaining = length
+	c.mask = mask
+	c.masked = masked
+	c.maskPos = 0
+	return nil
+}
+
+func (c *wsConn) handleControl(opcode byte, payload []byte) error {
+	switch opcode {
+	case wsOpPing:
+		return c.writeFrame(wsOpPong, payload)
+	case wsOpPong:
+		return nil
+	case wsOpClose:
+		// 回复对端的状态码后关闭
+		reply := payload
+		if len(reply) > 2 {
+			reply = reply[:2]
+		}
+		c.closeOnce.Do(func() {
+			c.writeFrame(wsOpClose, reply)
+		})
+		return io.EOF
+	default:
+		return c.fail(wsCloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+	}
+}
+
+// fail 协议错误时发送close帧，返回的错误使读取结束
+func (c *wsConn) fail(code uint16, reason string) error {
+	c.closeOnce.Do(func() {
+		c.writeFrame(wsOpClose, closePayload(code, reason))
+	})
+	return fmt.Errorf("websocket %s", reason)
+}
+
+// Write 写入一个二进制帧
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close 发送close帧后关闭底层连接
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeFrame(wsOpClose, closePayload(wsCloseNormal, ""))
+	})
+	return c.Conn.Close()
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126, byte(length>>8), byte(length))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(length))
+		frame = append(frame, maskBit|127)
+		frame = append(frame, ext[:]...)
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i&3])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+func closePayload(code uint16, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	return append(payload, reason...)
+}
+
+// wsAcceptKey 握手应答的Sec-WebSocket-Accept
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken 逗号分隔的头部值中是否包含token，忽略大小写
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket 校验握手请求并升级为WebSocket连接，客户端须声明mqtt子协议
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("websocket handshake method %s", r.Method)
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket handshake without upgrade header")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != wsVersion {
+		w.Header().Set("Sec-WebSocket-Version", wsVersion)
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("websocket handshake version %s", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket handshake key %q invalid", key)
+	}
+	if !headerContainsToken(r.Header, "Sec-WebSocket-Protocol", WS_SUBPROTOCOL) {
+		http.Error(w, "subprotocol mqtt required", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket handshake subprotocol %q not supported", r.Header.Get("Sec-WebSocket-Protocol"))
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("http connection can not be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\nSec-WebSocket-Protocol: " + WS_SUBPROTOCOL + "\r\n\r\n"
+	conn.SetDeadline(time.Time{})
+	if _, err = conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newWsConn(conn, rw.Reader, false), nil
+}
+
+// DialWebSocket 以mqtt子协议建立WebSocket连接，rawurl为ws://或wss://地址，origin为空时不发送Origin头部
+// Author: agent
+// Since: 2026/10/19
+func DialWebSocket(rawurl, origin string, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = dialer.Dial("tcp", host)
+	case "wss":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = u.Hostname()
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [16]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", wsVersion)
+	request.Header.Set("Sec-WebSocket-Protocol", WS_SUBPROTOCOL)
+	if origin != "" {
+		request.Header.Set("Origin", origin)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err = request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %s", response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) || response.Header.Get("Sec-WebSocket-Protocol") != WS_SUBPROTOCOL {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake response invalid")
+	}
+	conn.SetDeadline(time.Time{})
+	return newWsConn(conn, reader, true), nil
+}
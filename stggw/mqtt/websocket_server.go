@@ -0,0 +1,127 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+)
+
+// WebSocketServer MQTT over WebSocket监听，供浏览器及移动端接入。
+// 握手完成后连接交给网关的netm.Bootstrap管理，与TCP连接共用报文解析、会话、空闲检测及连接统计
+// Author: agent
+// Since: 2026/10/19
+type WebSocketServer struct {
+	bootstrap      *netm.Bootstrap
+	addr           string
+	path           string
+	allowedOrigins []string
+	tlsOptions     *netm.TLSOptions
+	listener       net.Listener
+	server         *http.Server
+	stopReload     func()
+}
+
+// NewWebSocketServer 创建WebSocket监听，tlsOptions为nil时使用明文(ws://)
+// Author: agent
+// Since: 2026/10/19
+func NewWebSocketServer(bootstrap *netm.Bootstrap, addr, path string, allowedOrigins []string, tlsOptions *netm.TLSOptions) *WebSocketServer {
+	return &WebSocketServer{
+		bootstrap:      bootstrap,
+		addr:           addr,
+		path:           path,
+		allowedOrigins: allowedOrigins,
+		tlsOptions:     tlsOptions,
+	}
+}
+
+// Start 开始监听
+// Author: agent
+// Since: 2026/10/19
+func (ws *WebSocketServer) Start() error {
+	listener, err := net.Listen("tcp", ws.addr)
+	if err != nil {
+		return err
+	}
+	if ws.tlsOptions != nil {
+		config, stop, err := netm.NewServerTLSConfig(ws.tlsOptions, func(err error) {
+			logger.Errorf("mqtt websocket reload tls certificate error: %s", err)
+		})
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		ws.stopReload = stop
+		listener = tls.NewListener(listener, config)
+	}
+	ws.listener = listener
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(ws.path, ws.handleUpgrade)
+	ws.server = &http.Server{Handler: mux}
+	go func() {
+		if err := ws.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("mqtt websocket server stopped: %s", err)
+		}
+	}()
+	logger.Infof("mqtt websocket listening on %s%s, tls=%t", listener.Addr(), ws.path, ws.tlsOptions != nil)
+	return nil
+}
+
+// Shutdown 停止监听，已升级的连接由bootstrap关闭
+// Author: agent
+// Since: 2026/10/19
+func (ws *WebSocketServer) Shutdown() {
+	if ws.server != nil {
+		ws.server.Close()
+	}
+	if ws.stopReload != nil {
+		ws.stopReload()
+	}
+}
+
+// Addr 实际监听地址，未启动时返回配置的地址
+func (ws *WebSocketServer) Addr() string {
+	if ws.listener != nil {
+		return ws.listener.Addr().String()
+	}
+	return ws.addr
+}
+
+func (ws *WebSocketServer) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	if !ws.checkOrigin(r) {
+		logger.Warnf("mqtt websocket reject origin %q from %s", r.Header.Get("Origin"), r.RemoteAddr)
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		logger.Warnf("mqtt websocket handshake from %s failed: %s", r.RemoteAddr, err)
+		return
+	}
+	ws.bootstrap.ServeConn(conn)
+}
+
+// checkOrigin 未携带Origin的请求(非浏览器客户端)直接放行；未配置允许列表时只接受与Host相同的来源，
+// 允许列表中的"*"表示接受任意来源
+func (ws *WebSocketServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(ws.allowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range ws.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
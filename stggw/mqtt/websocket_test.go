@@ -0,0 +1,297 @@
+package mqtt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startWebSocketGateway 启动同时监听TCP与WebSocket的网关，返回TCP地址及ws://地址
+func startWebSocketGateway(t *testing.T, cfg *GatewayConfig) (*MqttGateway, string, string) {
+	cfg.WsEnable = true
+	cfg.WsListenPort = 0
+	gateway, addr := startTestGateway(t, cfg, newFakeBroker())
+	scheme := "ws://"
+	if cfg.WsTlsEnable {
+		scheme = "wss://"
+	}
+	return gateway, addr, scheme + gateway.WebSocketAddr() + cfg.WsPath
+}
+
+func dialWebSocketClient(t *testing.T, wsUrl, clientId string, keepAlive uint16) *Client {
+	client, err := DialWebSocketClient(wsUrl, "", nil, &ConnectPacket{CleanSession: true, ClientId: clientId, KeepAlive: keepAlive}, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// 生成自签名证书，返回证书、私钥文件路径及信任该证书的CertPool
+func writeTestCert(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smartgo"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err = ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPem)
+	return certFile, keyFile, pool
+}
+
+func TestWebSocketPublishSubscribe(t *testing.T) {
+	gateway, addr, wsUrl := startWebSocketGateway(t, NewGatewayConfig())
+	defer gateway.Shutdown()
+
+	browser := dialWebSocketClient(t, wsUrl, "browser", 60)
+	defer browser.Disconnect()
+	device := dialTestClient(t, addr, "device-1", 60)
+	defer device.Disconnect()
+
+	if _, err := browser.Subscribe("devices/+/telemetry", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// TCP设备发布，WebSocket客户端收到；负载超过64KB，下行帧使用64位长度
+	large := bytes.Repeat([]byte("x"), 70000)
+	if err := device.Publish("devices/1/telemetry", 1, large); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, browser); p.TopicName != "devices/1/telemetry" || !bytes.Equal(p.Payload, large) {
+		t.Fatalf("unexpected delivered message %s, %d bytes", p.TopicName, len(p.Payload))
+	}
+
+	// WebSocket客户端发布，经同一映射规则写入smartgo
+	if _, err := device.Subscribe("devices/+/telemetry", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := browser.Publish("devices/2/telemetry", 2, []byte("21.0")); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, device); p.TopicName != "devices/2/telemetry" || string(p.Payload) != "21.0" {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+	if p := receive(t, browser); string(p.Payload) != "21.0" {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+
+	// 两种接入方式共用会话与连接统计
+	if gateway.SessionCount() != 2 || gateway.ConnectionCount() != 2 {
+		t.Fatalf("expect 2 sessions and connections, got %d and %d", gateway.SessionCount(), gateway.ConnectionCount())
+	}
+	transports := make(map[string]string)
+	for _, view := range gateway.ListSessions() {
+		transports[view.ClientId] = view.Transport
+	}
+	if transports["browser"] != TRANSPORT_WEBSOCKET || transports["device-1"] != TRANSPORT_TCP {
+		t.Fatalf("unexpected transports %v", transports)
+	}
+
+	browser.Disconnect()
+	waitFor(t, "websocket session removed", func() bool {
+		return gateway.SessionCount() == 1 && gateway.ConnectionCount() == 1
+	})
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	cfg := NewGatewayConfig()
+	cfg.WsAllowedOrigins = []string{"https://console.example.com"}
+	gateway, _, wsUrl := startWebSocketGateway(t, cfg)
+	defer gateway.Shutdown()
+
+	connect := &ConnectPacket{CleanSession: true, ClientId: "browser", KeepAlive: 60}
+	if _, err := DialWebSocketClient(wsUrl, "https://evil.example.com", nil, connect, 3*time.Second); err == nil {
+		t.Fatal("origin not in allowed list should be rejected")
+	}
+	client, err := DialWebSocketClient(wsUrl, "https://console.example.com", nil, connect, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Disconnect()
+
+	// 未声明mqtt子协议
+	httpUrl := "http://" + gateway.WebSocketAddr() + cfg.WsPath
+	request, _ := http.NewRequest(http.MethodGet, httpUrl, nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	request.Header.Set("Sec-WebSocket-Protocol", "chat")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expect 400 without mqtt subprotocol, got %d", response.StatusCode)
+	}
+
+	response, err = http.Get("http://" + gateway.WebSocketAddr() + "/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expect 404 for other path, got %d", response.StatusCode)
+	}
+}
+
+func TestWebSocketTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mqtt_ws_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, pool := writeTestCert(t, dir)
+
+	cfg := NewGatewayConfig()
+	cfg.WsTlsEnable = true
+	cfg.WsTlsCertFile = certFile
+	cfg.WsTlsKeyFile = keyFile
+	gateway, _, wssUrl := startWebSocketGateway(t, cfg)
+	defer gateway.Shutdown()
+
+	connect := &ConnectPacket{CleanSession: true, ClientId: "mobile", KeepAlive: 60}
+	client, err := DialWebSocketClient(wssUrl, "", &tls.Config{RootCAs: pool}, connect, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	if _, err = client.Subscribe("devices/+/telemetry", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Publish("devices/3/telemetry", 1, []byte("19.5")); err != nil {
+		t.Fatal(err)
+	}
+	if p := receive(t, client); string(p.Payload) != "19.5" {
+		t.Fatalf("unexpected delivered message %+v", p)
+	}
+
+	// 不信任服务端证书的客户端握手失败
+	if _, err = DialWebSocketClient(wssUrl, "", &tls.Config{}, connect, 3*time.Second); err == nil {
+		t.Fatal("untrusted certificate should fail")
+	}
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	cfg := NewGatewayConfig()
+	cfg.IdleTimeout = 1
+	gateway, addr, wsUrl := startWebSocketGateway(t, cfg)
+	defer gateway.Shutdown()
+
+	// keep-alive为0的客户端由空闲检测关闭，两种接入方式一致
+	browser := dialWebSocketClient(t, wsUrl, "browser", 0)
+	device := dialTestClient(t, addr, "device-1", 0)
+	for _, client := range []*Client{browser, device} {
+		select {
+		case <-client.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("idle connection not closed")
+		}
+	}
+	waitFor(t, "idle sessions removed", func() bool {
+		return gateway.SessionCount() == 0 && gateway.ConnectionCount() == 0
+	})
+}
+
+// 客户端发往服务端的带掩码帧
+func maskedFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i&3])
+	}
+	return frame
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	peer, conn := net.Pipe()
+	defer peer.Close()
+	server := newWsConn(conn, nil, false)
+	defer server.Close()
+
+	// 分片数据帧之间穿插ping
+	go func() {
+		peer.Write(maskedFrame(false, wsOpBinary, []byte("hel")))
+		peer.Write(maskedFrame(true, wsOpPing, []byte("p")))
+		peer.Write(maskedFrame(true, wsOpContinuation, []byte("lo")))
+	}()
+	pong := make([]byte, 3)
+	received := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 5)
+		n, _ := io.ReadFull(server, buf)
+		received <- buf[:n]
+	}()
+	if _, err := io.ReadFull(peer, pong); err != nil {
+		t.Fatal(err)
+	}
+	if pong[0] != 0x80|wsOpPong || pong[1] != 1 || pong[2] != 'p' {
+		t.Fatalf("unexpected pong %v", pong)
+	}
+	if data := <-received; string(data) != "hello" {
+		t.Fatalf("unexpected payload %q", data)
+	}
+
+	// 文本帧不支持，服务端以1003关闭
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := server.Read(make([]byte, 8))
+		errChan <- err
+	}()
+	peer.Write(maskedFrame(true, wsOpText, []byte("hi")))
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(peer, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x80|wsOpClose || binary.BigEndian.Uint16(header[2:]) != wsCloseUnsupported {
+		t.Fatalf("unexpected close frame %v", header)
+	}
+	io.CopyN(ioutil.Discard, peer, int64(header[1]&0x7F)-2)
+	if err := <-errChan; err == nil {
+		t.Fatal("text frame should fail")
+	}
+}
@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/static"
+	"github.com/BurntSushi/toml"
+)
+
+// RestGatewayConfig HTTP/REST网关配置项
+// Author: agent
+// Since: 2026/10/19
+type RestGatewayConfig struct {
+	ListenHost           string // 监听地址
+	ListenPort           int    // HTTP监听端口，默认8080
+	NamesrvAddr          string // namesrv地址，多个以分号分隔
+	ProducerGroup        string // 内嵌producer的group
+	AccessKey            string // 网关访问broker使用的账号，broker开启ACL时配置
+	SecretKey            string // 网关访问broker使用的密钥
+	AclEnable            bool   // 是否校验HTTP请求的AccessKey、Signature，规则与broker一致
+	AclConfigPath        string // ACL文件路径，默认$SMARTGO_HOME/conf/plain_acl.json
+	MaxBodySize          int    // 请求体最大字节数
+	MaxBatchSize         int    // 批量发送的最大消息数
+	MaxPollWait          int    // 长轮询最大等待时间，单位秒
+	PollInterval         int    // 长轮询及SSE检查新消息的间隔，单位毫秒
+	MaxPollMessages      int    // 单次拉取返回的最大消息数
+	InvisibleTime        int    // 已投递未确认的消息重新投递前的时间，单位秒
+	MaxInflight          int    // 每个group、topic已拉取未确认的最大消息数
+	SubscriptionIdle     int    // group、topic超过该时间无请求时释放，未确认的消息由下次请求重新拉取，单位秒
+	SseHeartbeatInterval int    // SSE心跳注释的发送间隔，单位秒
+}
+
+// NewRestGatewayConfig 创建默认配置
+// Author: agent
+// Since: 2026/10/19
+func NewRestGatewayConfig() *RestGatewayConfig {
+	return &RestGatewayConfig{
+		ListenHost:           "0.0.0.0",
+		ListenPort:           8080,
+		NamesrvAddr:          "127.0.0.1:9876",
+		ProducerGroup:        "PID_REST_GATEWAY",
+		AclConfigPath:        filepath.Join(os.Getenv(stgcommon.SMARTGO_HOME_ENV), "conf", static.ACL_CONFIG_NAME),
+		MaxBodySize:          4 * 1024 * 1024,
+		MaxBatchSize:         64,
+		MaxPollWait:          30,
+		PollInterval:         200,
+		MaxPollMessages:      32,
+		InvisibleTime:        30,
+		MaxInflight:          1024,
+		SubscriptionIdle:     300,
+		SseHeartbeatInterval: 15,
+	}
+}
+
+// LoadRestGatewayConfig 加载toml配置文件，未配置的项使用默认值
+// Author: agent
+// Since: 2026/10/19
+func LoadRestGatewayConfig(path string) (*RestGatewayConfig, error) {
+	cfg := NewRestGatewayConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("parse rest gateway config %s failed: %s", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate 校验配置项
+// Author: agent
+// Since: 2026/10/19
+func (cfg *RestGatewayConfig) Validate() error {
+	if cfg.ListenPort < 0 || cfg.ListenPort > 65535 {
+		return fmt.Errorf("invalid listenPort %d", cfg.ListenPort)
+	}
+	if cfg.NamesrvAddr == "" {
+		return fmt.Errorf("namesrvAddr is empty")
+	}
+	if (cfg.AccessKey == "") != (cfg.SecretKey == "") {
+		return fmt.Errorf("accessKey and secretKey must be configured together")
+	}
+	if cfg.MaxBodySize <= 0 || cfg.MaxBatchSize <= 0 || cfg.MaxPollMessages <= 0 || cfg.MaxInflight <= 0 {
+		return fmt.Errorf("maxBodySize, maxBatchSize, maxPollMessages and maxInflight must be positive")
+	}
+	if cfg.MaxPollWait < 0 || cfg.PollInterval <= 0 || cfg.InvisibleTime <= 0 || cfg.SubscriptionIdle <= 0 || cfg.SseHeartbeatInterval <= 0 {
+		return fmt.Errorf("pollInterval, invisibleTime, subscriptionIdle and sseHeartbeatInterval must be positive, maxPollWait must not be negative")
+	}
+	// 释放group、topic前未确认的消息须已超过不可见时间，否则重新拉取后会与仍在处理的消息重复
+	if cfg.SubscriptionIdle < cfg.InvisibleTime {
+		return fmt.Errorf("subscriptionIdle must not be less than invisibleTime")
+	}
+	return nil
+}
+
+func (cfg *RestGatewayConfig) String() string {
+	format := "RestGatewayConfig [listenHost=%s, listenPort=%d, namesrvAddr=%s, producerGroup=%s, accessKey=%s, aclEnable=%t, "
+	format += "aclConfigPath=%s, maxBodySize=%d, maxBatchSize=%d, maxPollWait=%d, pollInterval=%d, maxPollMessages=%d, "
+	format += "invisibleTime=%d, maxInflight=%d, subscriptionIdle=%d, sseHeartbeatInterval=%d]"
+	return fmt.Sprintf(format, cfg.ListenHost, cfg.ListenPort, cfg.NamesrvAddr, cfg.ProducerGroup, cfg.AccessKey, cfg.AclEnable,
+		cfg.AclConfigPath, cfg.MaxBodySize, cfg.MaxBatchSize, cfg.MaxPollWait, cfg.PollInterval, cfg.MaxPollMessages,
+		cfg.InvisibleTime, cfg.MaxInflight, cfg.SubscriptionIdle, cfg.SseHeartbeatInterval)
+}
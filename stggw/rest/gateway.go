@@ -0,0 +1,624 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/acl"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+)
+
+const scanInterval = time.Second
+
+// validName topic、group名称规则，与客户端的校验一致
+var validName = regexp.MustCompile(process.VALID_PATTERN_STR)
+
+// accessValidator HTTP请求鉴权，便于测试时替换
+type accessValidator interface {
+	Start()
+	Shutdown()
+	ValidateContent(remoteAddr, accessKey, signature string, content []byte, accessResource *acl.AccessResource) error
+}
+
+// pullerRef 消费组的puller及引用它的订阅数
+type pullerRef struct {
+	puller        groupPuller
+	subscriptions int
+	starting      chan struct{} // 不为nil表示正在锁外启动，启动完成后关闭
+}
+
+// RestGateway HTTP/REST网关：不使用Go客户端即可发送及消费消息
+//
+//	POST /topics/{topic}/messages                  发送单条消息，请求体为数组时批量发送
+//	GET  /groups/{group}/topics/{topic}/messages   长轮询拉取消息，wait为最长等待秒数，max为最大条数，tags为过滤表达式
+//	POST /groups/{group}/topics/{topic}/ack        确认消息，确认后提交消费进度
+//	GET  /groups/{group}/topics/{topic}/stream     以Server-Sent Events持续推送消息，autoAck为true时推送后自动确认
+//
+// 开启ACL时请求头须携带AccessKey、Signature，签名内容见acl.CombineHttpRequestContent，账号与权限与broker共用plain_acl.json
+// Author: agent
+// Since: 2026/10/19
+type RestGateway struct {
+	config        *RestGatewayConfig
+	producer      gwutil.MessageProducer
+	validator     accessValidator
+	newPuller     func(group string) groupPuller
+	pullers       map[string]*pullerRef
+	subscriptions map[string]*subscription // group@topic
+	lock          sync.Mutex
+	listener      net.Listener
+	server        *http.Server
+	stopChan      chan struct{}
+	stopOnce      sync.Once
+	wg            sync.WaitGroup
+}
+
+// NewRestGateway 创建HTTP/REST网关
+// Author: agent
+// Since: 2026/10/19
+func NewRestGateway(config *RestGatewayConfig) (*RestGateway, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	gateway := &RestGateway{
+		config:        config,
+		pullers:       make(map[string]*pullerRef),
+		subscriptions: make(map[string]*subscription),
+		stopChan:      make(chan struct{}),
+	}
+	if config.AclEnable {
+		validator, err := acl.NewPlainAccessValidator(config.AclConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		gateway.validator = validator
+	}
+
+	var rpcHook remoting.RPCHook
+	if config.AccessKey != "" {
+		rpcHook = acl.NewAclClientRPCHook(config.AccessKey, config.SecretKey)
+	}
+	producer := process.NewCustomMQProducer(config.ProducerGroup, rpcHook)
+	producer.SetNamesrvAddr(config.NamesrvAddr)
+	gateway.producer = producer
+	gateway.newPuller = func(group string) groupPuller {
+		return newPullConsumerPuller(group, config.NamesrvAddr, rpcHook)
+	}
+	return gateway, nil
+}
+
+// Start 启动producer及HTTP服务
+// Author: agent
+// Since: 2026/10/19
+func (gateway *RestGateway) Start() error {
+	gateway.producer.Start()
+	if gateway.validator != nil {
+		gateway.validator.Start()
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(gateway.config.ListenHost, strconv.Itoa(gateway.config.ListenPort)))
+	if err != nil {
+		return err
+	}
+	gateway.listener = listener
+	gateway.server = &http.Server{Handler: http.HandlerFunc(gateway.serveHTTP)}
+
+	gateway.wg.Add(1)
+	go gateway.scan()
+	go func() {
+		defer utils.RecoveredFn()
+		if err := gateway.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("rest gateway serve err: %s", err.Error())
+		}
+	}()
+	logger.Infof("rest gateway %s start success. %s", gateway.Addr(), gateway.config)
+	return nil
+}
+
+// Shutdown 关闭HTTP服务，结束长轮询及SSE连接，再关闭puller、producer
+// Author: agent
+// Since: 2026/10/19
+func (gateway *RestGateway) Shutdown() {
+	gateway.stopOnce.Do(func() {
+		close(gateway.stopChan)
+		if gateway.server != nil {
+			gateway.server.Close()
+		}
+		gateway.wg.Wait()
+
+		var pullers []groupPuller
+		gateway.lock.Lock()
+		for _, ref := range gateway.pullers {
+			// 正在启动的puller由启动它的订阅请求关闭
+			if ref.starting == nil {
+				pullers = append(pullers, ref.puller)
+			}
+		}
+		gateway.pullers = make(map[string]*pullerRef)
+		gateway.subscriptions = make(map[string]*subscription)
+		gateway.lock.Unlock()
+		for _, puller := range pullers {
+			puller.Shutdown()
+		}
+
+		gateway.producer.Shutdown()
+		if gateway.validator != nil {
+			gateway.validator.Shutdown()
+		}
+		logger.Infof("rest gateway shutdown success")
+	})
+}
+
+// Addr HTTP服务实际监听的地址，未启动时为空
+func (gateway *RestGateway) Addr() string {
+	if gateway.listener == nil {
+		return ""
+	}
+	return gateway.listener.Addr().String()
+}
+
+// SubscriptionCount 当前的group、topic订阅数
+func (gateway *RestGateway) SubscriptionCount() int {
+	gateway.lock.Lock()
+	defer gateway.lock.Unlock()
+	return len(gateway.subscriptions)
+}
+
+func (gateway *RestGateway) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "topics" && parts[2] == "messages":
+		if allowMethod(w, r, http.MethodPost) {
+			gateway.serveSend(w, r, parts[1])
+		}
+	case len(parts) == 5 && parts[0] == "groups" && parts[2] == "topics" && parts[4] == "messages":
+		if allowMethod(w, r, http.MethodGet) {
+			gateway.servePoll(w, r, parts[1], parts[3])
+		}
+	case len(parts) == 5 && parts[0] == "groups" && parts[2] == "topics" && parts[4] == "ack":
+		if allowMethod(w, r, http.MethodPost) {
+			gateway.serveAck(w, r, parts[1], parts[3])
+		}
+	case len(parts) == 5 && parts[0] == "groups" && parts[2] == "topics" && parts[4] == "stream":
+		if allowMethod(w, r, http.MethodGet) {
+			gateway.serveStream(w, r, parts[1], parts[3])
+		}
+	default:
+		writeError(w, http.StatusNotFound, "no such resource "+r.URL.Path)
+	}
+}
+
+// serveSend POST /topics/{topic}/messages，单条发送失败时返回503，批量发送逐条返回结果，不保证原子性
+func (gateway *RestGateway) serveSend(w http.ResponseWriter, r *http.Request, topic string) {
+	body, ok := gateway.readBody(w, r)
+	if !ok || !gateway.authorize(w, r, body, &acl.AccessResource{Topic: topic, TopicPerm: acl.PUB}) || !checkName(w, "topic", topic) {
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	var requests []*SendMessageRequest
+	if batch {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		if len(requests) == 0 || len(requests) > gateway.config.MaxBatchSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("batch size must be between 1 and %d", gateway.config.MaxBatchSize))
+			return
+		}
+	} else {
+		request := new(SendMessageRequest)
+		if err := json.Unmarshal(body, request); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		requests = append(requests, request)
+	}
+
+	now := timeutil.CurrentTimeMillis()
+	msgs := make([]*message.Message, 0, len(requests))
+	for i, request := range requests {
+		if request == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("message %d is null", i))
+			return
+		}
+		msg, err := request.toMessage(topic, now)
+		if err != nil {
+			if batch {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("message %d: %s", i, err))
+			} else {
+				writeError(w, http.StatusBadRequest, err.Error())
+			}
+			return
+		}
+		msgs = append(msgs, msg)
+	}
+
+	results := make([]*SendMessageResult, 0, len(msgs))
+	for _, msg := range msgs {
+		results = append(results, newSendMessageResult(gateway.send(msg)))
+	}
+	if batch {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+		return
+	}
+	if results[0].Error != "" {
+		logger.Warnf("rest gateway send message to topic %s failed: %s", topic, results[0].Error)
+		writeJSON(w, http.StatusServiceUnavailable, results[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, results[0])
+}
+
+// send 发送消息，将客户端校验消息时的panic转换为error
+func (gateway *RestGateway) send(msg *message.Message) (result *process.SendResult, err error) {
+	defer gwutil.RecoverError(&err)
+	return gateway.producer.Send(msg)
+}
+
+// servePoll GET /groups/{group}/topics/{topic}/messages?wait=10&max=16&tags=a||b，没有消息时最多等待wait秒
+func (gateway *RestGateway) servePoll(w http.ResponseWriter, r *http.Request, group, topic string) {
+	query := r.URL.Query()
+	wait, err := intParam(query.Get("wait"), gateway.config.MaxPollWait, 0, gateway.config.MaxPollWait)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid wait: "+err.Error())
+		return
+	}
+	maxNums, err := intParam(query.Get("max"), gateway.config.MaxPollMessages, 1, gateway.config.MaxPollMessages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid max: "+err.Error())
+		return
+	}
+	sub, ok := gateway.consumeSubscription(w, r, group, topic)
+	if !ok {
+		return
+	}
+
+	deadline := time.Now().Add(time.Duration(wait) * time.Second)
+	interval := time.Duration(gateway.config.PollInterval) * time.Millisecond
+	invisibleTime := int64(gateway.config.InvisibleTime) * 1000
+	for {
+		msgs, err := sub.poll(maxNums, gateway.config.MaxInflight, invisibleTime, timeutil.CurrentTimeMillis())
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if len(msgs) > 0 || !time.Now().Add(interval).Before(deadline) {
+			if msgs == nil {
+				msgs = []*ConsumedMessage{}
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"messages": msgs})
+			return
+		}
+		if !gateway.sleep(r, interval) {
+			return
+		}
+	}
+}
+
+// serveAck POST /groups/{group}/topics/{topic}/ack，请求体为{"receipts": [...]}
+func (gateway *RestGateway) serveAck(w http.ResponseWriter, r *http.Request, group, topic string) {
+	body, ok := gateway.readBody(w, r)
+	if !ok || !gateway.authorize(w, r, body, consumeResource(group, topic)) {
+		return
+	}
+	request := new(AckRequest)
+	if err := json.Unmarshal(body, request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(request.Receipts) == 0 {
+		writeError(w, http.StatusBadRequest, "receipts is empty")
+		return
+	}
+
+	gateway.lock.Lock()
+	sub, ok := gateway.subscriptions[subscriptionKey(group, topic)]
+	gateway.lock.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusOK, &AckResult{Invalid: request.Receipts})
+		return
+	}
+	writeJSON(w, http.StatusOK, sub.ack(request.Receipts, timeutil.CurrentTimeMillis()))
+}
+
+// serveStream GET /groups/{group}/topics/{topic}/stream?tags=a||b&autoAck=true，
+// 每条消息为一个message事件，事件id为确认句柄；未开启autoAck时须调用ack接口确认，否则超过不可见时间后重新推送
+func (gateway *RestGateway) serveStream(w http.ResponseWriter, r *http.Request, group, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	autoAck := false
+	if value := r.URL.Query().Get("autoAck"); value != "" {
+		var err error
+		if autoAck, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid autoAck "+value)
+			return
+		}
+	}
+	sub, ok := gateway.consumeSubscription(w, r, group, topic)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	interval := time.Duration(gateway.config.PollInterval) * time.Millisecond
+	heartbeat := time.Duration(gateway.config.SseHeartbeatInterval) * time.Second
+	invisibleTime := int64(gateway.config.InvisibleTime) * 1000
+	lastWrite := time.Now()
+	for {
+		msgs, err := sub.poll(gateway.config.MaxPollMessages, gateway.config.MaxInflight, invisibleTime, timeutil.CurrentTimeMillis())
+		if err != nil {
+			data, _ := json.Marshal(map[string]string{"error": err.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+
+		receipts := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				logger.Errorf("rest gateway encode message %s err: %s", msg.MsgId, err)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "id: %s\nevent: message\ndata: %s\n\n", msg.Receipt, data); err != nil {
+				return
+			}
+			receipts = append(receipts, msg.Receipt)
+		}
+		if len(receipts) > 0 {
+			flusher.Flush()
+			lastWrite = time.Now()
+			if autoAck {
+				sub.ack(receipts, timeutil.CurrentTimeMillis())
+			}
+			continue
+		}
+
+		if time.Since(lastWrite) >= heartbeat {
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			lastWrite = time.Now()
+		}
+		if !gateway.sleep(r, interval) {
+			return
+		}
+	}
+}
+
+// consumeSubscription 校验消费权限并返回group对topic的订阅，失败时已写入响应
+func (gateway *RestGateway) consumeSubscription(w http.ResponseWriter, r *http.Request, group, topic string) (*subscription, bool) {
+	if !gateway.authorize(w, r, nil, consumeResource(group, topic)) || !checkName(w, "group", group) || !checkName(w, "topic", topic) {
+		return nil, false
+	}
+	expression := r.URL.Query().Get("tags")
+	if expression == "" {
+		expression = "*"
+	}
+	sub, status, err := gateway.subscribe(group, topic, expression)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return nil, false
+	}
+	return sub, true
+}
+
+// subscribe 返回group对topic的订阅，不存在时创建；同一group、topic只能使用一个过滤表达式
+func (gateway *RestGateway) subscribe(group, topic, expression string) (*subscription, int, error) {
+	key := subscriptionKey(group, topic)
+	gateway.lock.Lock()
+	defer gateway.lock.Unlock()
+	for {
+		select {
+		case <-gateway.stopChan:
+			return nil, http.StatusServiceUnavailable, fmt.Errorf("rest gateway is shutting down")
+		default:
+		}
+
+		if sub, ok := gateway.subscriptions[key]; ok {
+			if sub.expression != expression {
+				return nil, http.StatusConflict, fmt.Errorf("group %s already subscribes topic %s with tags %s", group, topic, sub.expression)
+			}
+			return sub, http.StatusOK, nil
+		}
+
+		ref, ok := gateway.pullers[group]
+		if ok && ref.starting != nil {
+			// 等待同一消费组的puller启动完成后重新检查
+			starting := ref.starting
+			gateway.lock.Unlock()
+			<-starting
+			gateway.lock.Lock()
+			continue
+		}
+		if !ok {
+			// 启动puller需要与namesrv、broker通信，在锁外进行，不阻塞其他请求
+			ref = &pullerRef{puller: gateway.newPuller(group), starting: make(chan struct{})}
+			gateway.pullers[group] = ref
+			gateway.lock.Unlock()
+			err := ref.puller.Start()
+			gateway.lock.Lock()
+			close(ref.starting)
+			ref.starting = nil
+			if gateway.pullers[group] != ref {
+				// 启动期间网关已关闭
+				gateway.lock.Unlock()
+				ref.puller.Shutdown()
+				gateway.lock.Lock()
+				continue
+			}
+			if err != nil {
+				delete(gateway.pullers, group)
+				return nil, http.StatusServiceUnavailable, fmt.Errorf("start puller of group %s failed: %s", group, err)
+			}
+		}
+		ref.subscriptions++
+		sub := newSubscription(group, topic, expression, ref.puller, timeutil.CurrentTimeMillis())
+		gateway.subscriptions[key] = sub
+		logger.Infof("rest gateway group %s subscribe topic %s, tags=%s", group, topic, expression)
+		return sub, http.StatusOK, nil
+	}
+}
+
+// scan 定时释放空闲的订阅，消费组没有订阅时关闭其puller
+func (gateway *RestGateway) scan() {
+	defer gateway.wg.Done()
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+	idleTime := int64(gateway.config.SubscriptionIdle) * 1000
+	for {
+		select {
+		case <-gateway.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		now := timeutil.CurrentTimeMillis()
+		var idlePullers []groupPuller
+		gateway.lock.Lock()
+		for key, sub := range gateway.subscriptions {
+			if !sub.idle(now, idleTime) {
+				continue
+			}
+			delete(gateway.subscriptions, key)
+			logger.Infof("rest gateway release idle subscription, group=%s, topic=%s", sub.group, sub.topic)
+			if ref := gateway.pullers[sub.group]; ref != nil {
+				if ref.subscriptions--; ref.subscriptions <= 0 {
+					delete(gateway.pullers, sub.group)
+					idlePullers = append(idlePullers, ref.puller)
+				}
+			}
+		}
+		gateway.lock.Unlock()
+		for _, puller := range idlePullers {
+			puller.Shutdown()
+		}
+	}
+}
+
+// authorize 开启ACL时校验请求，body为nil表示请求不带请求体；失败时已写入403响应
+func (gateway *RestGateway) authorize(w http.ResponseWriter, r *http.Request, body []byte, resource *acl.AccessResource) bool {
+	if gateway.validator == nil {
+		return true
+	}
+	remoteHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteHost = r.RemoteAddr
+	}
+	content := acl.CombineHttpRequestContent(r.Method, r.URL.Path, r.URL.Query(), body)
+	accessKey, signature := r.Header.Get(protocol.ACL_ACCESS_KEY), r.Header.Get(protocol.ACL_SIGNATURE)
+	if err := gateway.validator.ValidateContent(remoteHost, accessKey, signature, content, resource); err != nil {
+		logger.Warnf("rest gateway reject %s %s from %s: %s", r.Method, r.URL.Path, r.RemoteAddr, err)
+		writeError(w, http.StatusForbidden, err.Error())
+		return false
+	}
+	return true
+}
+
+// readBody 读取请求体，超过maxBodySize时返回413
+func (gateway *RestGateway) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(gateway.config.MaxBodySize)))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("read request body failed: %s", err))
+		return nil, false
+	}
+	return body, true
+}
+
+// sleep 等待interval，客户端断开或网关关闭时返回false
+func (gateway *RestGateway) sleep(r *http.Request, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-gateway.stopChan:
+		return false
+	}
+}
+
+func consumeResource(group, topic string) *acl.AccessResource {
+	return &acl.AccessResource{Topic: topic, TopicPerm: acl.SUB, Group: group, GroupPerm: acl.SUB}
+}
+
+func subscriptionKey(group, topic string) string {
+	return group + "@" + topic
+}
+
+func checkName(w http.ResponseWriter, kind, name string) bool {
+	if len(name) > process.CHARACTER_MAX_LENGTH || !validName.MatchString(name) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s %q, allowing only %s", kind, name, process.VALID_PATTERN_STR))
+		return false
+	}
+	return true
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+// intParam 解析整数参数，为空时返回defaultValue，超出范围时取边界值
+func intParam(value string, defaultValue, min, max int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < min {
+		n = min
+	}
+	if n > max {
+		n = max
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf("rest gateway encode response err: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
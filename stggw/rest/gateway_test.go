@@ -0,0 +1,593 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/acl"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwtest"
+)
+
+const testBrokerName = "broker-a"
+
+// fakeBroker 内存中的broker：每个topic两个队列，发送的消息轮流写入，消费进度按group记录；
+// 同时实现gwutil.MessageProducer及groupPuller
+type fakeBroker struct {
+	gwtest.Broker
+	queues    map[string][][]*message.MessageExt // topic -> queueId -> 消息
+	committed map[string]int64                   // group@topic@queueId -> 消费进度
+	blocked   map[string]chan struct{}           // group -> 关闭前阻塞该group的Start、Pull
+	sendErr   error
+	lock      sync.Mutex
+}
+
+func newFakeBroker(topics ...string) *fakeBroker {
+	broker := &fakeBroker{queues: make(map[string][][]*message.MessageExt), committed: make(map[string]int64), blocked: make(map[string]chan struct{})}
+	for _, topic := range topics {
+		broker.queues[topic] = make([][]*message.MessageExt, 2)
+	}
+	return broker
+}
+
+func (b *fakeBroker) Send(msg *message.Message) (*process.SendResult, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.sendErr != nil {
+		return nil, b.sendErr
+	}
+	queues, ok := b.queues[msg.Topic]
+	if !ok {
+		return nil, fmt.Errorf("topic %s not exist", msg.Topic)
+	}
+	queueId := (len(queues[0]) + len(queues[1])) % 2
+	msgExt := b.Record(msg)
+	msgExt.QueueId, msgExt.QueueOffset = int32(queueId), int64(len(queues[queueId]))
+	queues[queueId] = append(queues[queueId], msgExt)
+	mq := &message.MessageQueue{Topic: msg.Topic, BrokerName: testBrokerName, QueueId: queueId}
+	return &process.SendResult{SendStatus: process.SEND_OK, MsgId: msgExt.MsgId, MessageQueue: mq, QueueOffset: msgExt.QueueOffset}, nil
+}
+
+func (b *fakeBroker) puller(group string) groupPuller {
+	return &fakePuller{broker: b, group: group}
+}
+
+// block 阻塞group的Start、Pull，直到返回的函数被调用
+func (b *fakeBroker) block(group string) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	gate := make(chan struct{})
+	b.blocked[group] = gate
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.blocked, group)
+		close(gate)
+	}
+}
+
+func (b *fakeBroker) wait(group string) {
+	b.lock.Lock()
+	gate := b.blocked[group]
+	b.lock.Unlock()
+	if gate != nil {
+		<-gate
+	}
+}
+
+func (b *fakeBroker) committedOffset(group, topic string, queueId int) int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	offset, ok := b.committed[fmt.Sprintf("%s@%s@%d", group, topic, queueId)]
+	if !ok {
+		return -1
+	}
+	return offset
+}
+
+type fakePuller struct {
+	broker *fakeBroker
+	group  string
+}
+
+func (p *fakePuller) Start() error {
+	p.broker.wait(p.group)
+	return nil
+}
+
+func (p *fakePuller) Shutdown() {}
+
+func (p *fakePuller) FetchMessageQueues(topic string) ([]*message.MessageQueue, error) {
+	p.broker.lock.Lock()
+	defer p.broker.lock.Unlock()
+	if _, ok := p.broker.queues[topic]; !ok {
+		return nil, fmt.Errorf("topic %s not exist", topic)
+	}
+	return []*message.MessageQueue{{Topic: topic, BrokerName: testBrokerName, QueueId: 0}, {Topic: topic, BrokerName: testBrokerName, QueueId: 1}}, nil
+}
+
+func (p *fakePuller) MaxOffset(mq *message.MessageQueue) (int64, error) {
+	p.broker.lock.Lock()
+	defer p.broker.lock.Unlock()
+	return int64(len(p.broker.queues[mq.Topic][mq.QueueId])), nil
+}
+
+func (p *fakePuller) Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (*consumer.PullResult, error) {
+	p.broker.wait(p.group)
+	p.broker.lock.Lock()
+	defer p.broker.lock.Unlock()
+	msgs := p.broker.queues[mq.Topic][mq.QueueId]
+	if offset > int64(len(msgs)) {
+		return &consumer.PullResult{PullStatus: consumer.OFFSET_ILLEGAL, NextBeginOffset: int64(len(msgs))}, nil
+	}
+	if offset == int64(len(msgs)) {
+		return &consumer.PullResult{PullStatus: consumer.NO_NEW_MSG, NextBeginOffset: offset}, nil
+	}
+	result := &consumer.PullResult{PullStatus: consumer.FOUND, NextBeginOffset: offset}
+	for ; result.NextBeginOffset < int64(len(msgs)) && len(result.MsgFoundList) < maxNums; result.NextBeginOffset++ {
+		msg := msgs[result.NextBeginOffset]
+		if subExpression == "*" || strings.Contains("||"+strings.Replace(subExpression, " ", "", -1)+"||", "||"+msg.GetTags()+"||") {
+			result.MsgFoundList = append(result.MsgFoundList, msg)
+		}
+	}
+	if len(result.MsgFoundList) == 0 {
+		result.PullStatus = consumer.NO_MATCHED_MSG
+	}
+	return result, nil
+}
+
+func (p *fakePuller) FetchConsumeOffset(mq *message.MessageQueue) (int64, error) {
+	return p.broker.committedOffset(p.group, mq.Topic, mq.QueueId), nil
+}
+
+func (p *fakePuller) UpdateConsumeOffset(mq *message.MessageQueue, offset int64) error {
+	p.broker.lock.Lock()
+	defer p.broker.lock.Unlock()
+	p.broker.committed[fmt.Sprintf("%s@%s@%d", p.group, mq.Topic, mq.QueueId)] = offset
+	return nil
+}
+
+func newTestConfig() *RestGatewayConfig {
+	cfg := NewRestGatewayConfig()
+	cfg.ListenHost = "127.0.0.1"
+	cfg.ListenPort = 0
+	cfg.PollInterval = 20
+	cfg.InvisibleTime = 1
+	return cfg
+}
+
+// staticValidator 不定时重新加载ACL文件的鉴权
+type staticValidator struct {
+	*acl.PlainAccessValidator
+}
+
+func (v *staticValidator) Start()    {}
+func (v *staticValidator) Shutdown() {}
+
+func startTestGateway(t *testing.T, cfg *RestGatewayConfig, broker *fakeBroker) (*RestGateway, string) {
+	gateway, err := NewRestGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.producer = broker
+	gateway.newPuller = broker.puller
+	if validator, ok := gateway.validator.(*acl.PlainAccessValidator); ok {
+		gateway.validator = &staticValidator{validator}
+	}
+	if err := gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return gateway, "http://" + gateway.Addr()
+}
+
+func doRequest(t *testing.T, method, url, body string, result interface{}) int {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doHttpRequest(t, request, result)
+}
+
+func doHttpRequest(t *testing.T, request *http.Request, result interface{}) int {
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil && response.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(data, result); err != nil {
+			t.Fatalf("decode %s failed: %s", data, err)
+		}
+	}
+	return response.StatusCode
+}
+
+type pollResult struct {
+	Messages []*ConsumedMessage `json:"messages"`
+}
+
+func TestRestSend(t *testing.T) {
+	broker := newFakeBroker("Orders")
+	gateway, url := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+
+	single := `{"body": "hello", "tags": "created", "keys": ["order-1", "user-1"], "properties": {"source": "web"}, "delay": 60000}`
+	result := new(SendMessageResult)
+	if status := doRequest(t, "POST", url+"/topics/Orders/messages", single, result); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if result.MsgId != "msg-1" || result.Status != "SEND_OK" || result.BrokerName != testBrokerName {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	msg := broker.queues["Orders"][0][0]
+	if string(msg.Body) != "hello" || msg.GetTags() != "created" || msg.GetKeys() != "order-1 user-1" || msg.GetProperty("source") != "web" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if deliverTime := msg.GetStartDeliverTime(); deliverTime < timeutil.CurrentTimeMillis()+50000 {
+		t.Fatalf("unexpected deliver time %d", deliverTime)
+	}
+
+	batch := `[{"body": "aGk=", "encoding": "base64", "delayLevel": 3}, {"body": "second"}]`
+	var batchResult struct {
+		Results []*SendMessageResult `json:"results"`
+	}
+	if status := doRequest(t, "POST", url+"/topics/Orders/messages", batch, &batchResult); status != http.StatusOK || len(batchResult.Results) != 2 {
+		t.Fatalf("unexpected batch status %d %+v", status, batchResult)
+	}
+	if msg := broker.queues["Orders"][1][0]; string(msg.Body) != "hi" || msg.GetDelayTimeLevel() != 3 || batchResult.Results[1].MsgId != "msg-3" {
+		t.Fatalf("unexpected batch message %+v", msg)
+	}
+
+	invalids := []string{
+		`{"body": ""}`,
+		`{"body": "a", "properties": {"TAGS": "x"}}`,
+		`{"body": "a", "delay": 1000, "delayLevel": 2}`,
+		`{"body": "!", "encoding": "base64"}`,
+		`[]`,
+		`[{"body": "a"}, {"body": "a", "encoding": "gzip"}]`,
+		`{invalid`,
+	}
+	for _, body := range invalids {
+		if status := doRequest(t, "POST", url+"/topics/Orders/messages", body, nil); status != http.StatusBadRequest {
+			t.Fatalf("expect 400 for %s, got %d", body, status)
+		}
+	}
+	if status := doRequest(t, "POST", url+"/topics/Ord$ers/messages", `{"body": "a"}`, nil); status != http.StatusBadRequest {
+		t.Fatalf("expect 400 for invalid topic, got %d", status)
+	}
+	if status := doRequest(t, "GET", url+"/topics/Orders/messages", "", nil); status != http.StatusMethodNotAllowed {
+		t.Fatalf("expect 405, got %d", status)
+	}
+
+	broker.lock.Lock()
+	broker.sendErr = fmt.Errorf("no route")
+	broker.lock.Unlock()
+	if status := doRequest(t, "POST", url+"/topics/Orders/messages", `{"body": "a"}`, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503, got %d", status)
+	}
+}
+
+func TestRestPollAck(t *testing.T) {
+	broker := newFakeBroker("Orders")
+	gateway, url := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+
+	// 写入订阅前的消息，新的消费组从最大位置开始消费
+	broker.Send(message.NewMessage("Orders", "", []byte("before")))
+	pollURL := url + "/groups/GID_REST/topics/Orders/messages?wait=0"
+	result := new(pollResult)
+	if status := doRequest(t, "GET", pollURL, "", result); status != http.StatusOK || len(result.Messages) != 0 {
+		t.Fatalf("unexpected poll %d %+v", status, result)
+	}
+	if offset := broker.committedOffset("GID_REST", "Orders", 0); offset != 1 {
+		t.Fatalf("initial offset should be committed, got %d", offset)
+	}
+
+	for i := 0; i < 4; i++ {
+		broker.Send(message.NewMessage("Orders", "", []byte(fmt.Sprintf("m%d", i))))
+	}
+	first, last := new(pollResult), new(pollResult)
+	if status := doRequest(t, "GET", pollURL+"&max=3", "", first); status != http.StatusOK || len(first.Messages) != 3 {
+		t.Fatalf("unexpected poll %d %d", status, len(first.Messages))
+	}
+	if status := doRequest(t, "GET", pollURL, "", last); status != http.StatusOK || len(last.Messages) != 1 {
+		t.Fatalf("unexpected poll %d %d", status, len(last.Messages))
+	}
+
+	// 确认queue1的消息后，queue1的消费进度推进；queue0的消息未确认，消费进度不变
+	var receipts []string
+	for _, msg := range append(first.Messages, last.Messages...) {
+		if msg.QueueId == 1 {
+			receipts = append(receipts, msg.Receipt)
+		}
+	}
+	ackBody, _ := json.Marshal(&AckRequest{Receipts: append(receipts, "invalid")})
+	ackResult := new(AckResult)
+	if status := doRequest(t, "POST", url+"/groups/GID_REST/topics/Orders/ack", string(ackBody), ackResult); status != http.StatusOK {
+		t.Fatalf("unexpected ack status %d", status)
+	}
+	if ackResult.Acked != 2 || len(ackResult.Invalid) != 1 {
+		t.Fatalf("unexpected ack result %+v", ackResult)
+	}
+	if offset := broker.committedOffset("GID_REST", "Orders", 1); offset != 2 {
+		t.Fatalf("queue1 offset should be 2, got %d", offset)
+	}
+	if offset := broker.committedOffset("GID_REST", "Orders", 0); offset != 1 {
+		t.Fatalf("queue0 offset should stay 1, got %d", offset)
+	}
+
+	// 超过不可见时间未确认的消息重新投递
+	time.Sleep(1100 * time.Millisecond)
+	result = new(pollResult)
+	if status := doRequest(t, "GET", pollURL, "", result); status != http.StatusOK || len(result.Messages) != 2 {
+		t.Fatalf("unexpected redelivery %d %d", status, len(result.Messages))
+	}
+	// 重新投递后第一次投递的句柄失效
+	var staleReceipts []string
+	for _, msg := range append(first.Messages, last.Messages...) {
+		if msg.QueueId == 0 {
+			staleReceipts = append(staleReceipts, msg.Receipt)
+		}
+	}
+	ackBody, _ = json.Marshal(&AckRequest{Receipts: staleReceipts})
+	ackResult = new(AckResult)
+	doRequest(t, "POST", url+"/groups/GID_REST/topics/Orders/ack", string(ackBody), ackResult)
+	if ackResult.Acked != 0 || len(ackResult.Invalid) != 2 {
+		t.Fatalf("stale receipts should be rejected, got %+v", ackResult)
+	}
+	receipts = nil
+	for _, msg := range result.Messages {
+		if msg.QueueId != 0 || msg.DeliveryCount != 2 {
+			t.Fatalf("unexpected redelivered message %+v", msg)
+		}
+		receipts = append(receipts, msg.Receipt)
+	}
+	ackBody, _ = json.Marshal(&AckRequest{Receipts: receipts})
+	doRequest(t, "POST", url+"/groups/GID_REST/topics/Orders/ack", string(ackBody), ackResult)
+	if offset := broker.committedOffset("GID_REST", "Orders", 0); offset != 3 {
+		t.Fatalf("queue0 offset should be 3, got %d", offset)
+	}
+
+	// 长轮询等待新消息
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		broker.Send(message.NewMessage("Orders", "", []byte("late")))
+	}()
+	start := time.Now()
+	result = new(pollResult)
+	if status := doRequest(t, "GET", url+"/groups/GID_REST/topics/Orders/messages?wait=5", "", result); status != http.StatusOK || len(result.Messages) != 1 {
+		t.Fatalf("unexpected long poll %d %d", status, len(result.Messages))
+	}
+	if result.Messages[0].Body != "late" || time.Since(start) > 3*time.Second {
+		t.Fatalf("unexpected long poll message %+v after %s", result.Messages[0], time.Since(start))
+	}
+}
+
+// TestRestSlowPuller 启动puller及拉取消息较慢时不阻塞其他消费组及同一订阅的确认
+func TestRestSlowPuller(t *testing.T) {
+	broker := newFakeBroker("Orders")
+	gateway, url := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+
+	request := func(method, url, body string, result interface{}) chan int {
+		done := make(chan int, 1)
+		go func() {
+			done <- doRequest(t, method, url, body, result)
+		}()
+		return done
+	}
+	expect := func(done chan int, name string) {
+		select {
+		case status := <-done:
+			if status != http.StatusOK {
+				t.Fatalf("unexpected %s status %d", name, status)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%s is blocked", name)
+		}
+	}
+
+	slowURL := url + "/groups/GID_SLOW/topics/Orders/messages?wait=0"
+	unblock := broker.block("GID_SLOW")
+	starting := request("GET", slowURL, "", new(pollResult))
+	time.Sleep(100 * time.Millisecond)
+	expect(request("GET", url+"/groups/GID_FAST/topics/Orders/messages?wait=0", "", new(pollResult)), "poll of other group")
+	select {
+	case <-starting:
+		t.Fatal("poll should wait for the puller to start")
+	default:
+	}
+	unblock()
+	expect(starting, "poll after puller started")
+
+	broker.Send(message.NewMessage("Orders", "", []byte("m0")))
+	result := new(pollResult)
+	if status := doRequest(t, "GET", slowURL, "", result); status != http.StatusOK || len(result.Messages) != 1 {
+		t.Fatalf("unexpected poll %d %+v", status, result)
+	}
+
+	unblock = broker.block("GID_SLOW")
+	pulling := request("GET", slowURL, "", new(pollResult))
+	time.Sleep(100 * time.Millisecond)
+	ackBody, _ := json.Marshal(&AckRequest{Receipts: []string{result.Messages[0].Receipt}})
+	ackResult := new(AckResult)
+	expect(request("POST", url+"/groups/GID_SLOW/topics/Orders/ack", string(ackBody), ackResult), "ack during pull")
+	if ackResult.Acked != 1 {
+		t.Fatalf("unexpected ack result %+v", ackResult)
+	}
+	unblock()
+	expect(pulling, "poll after pull")
+}
+
+func TestRestTagsFilter(t *testing.T) {
+	broker := newFakeBroker("Orders")
+	gateway, url := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+
+	pollURL := url + "/groups/GID_PAID/topics/Orders/messages?wait=0&tags=paid"
+	result := new(pollResult)
+	doRequest(t, "GET", pollURL, "", result)
+	broker.Send(message.NewMessage("Orders", "created", []byte("a")))
+	broker.Send(message.NewMessage("Orders", "created", []byte("b")))
+	broker.Send(message.NewMessage("Orders", "paid", []byte("c")))
+	if status := doRequest(t, "GET", pollURL, "", result); status != http.StatusOK || len(result.Messages) != 1 || result.Messages[0].Tags != "paid" {
+		t.Fatalf("unexpected poll %d %+v", status, result.Messages)
+	}
+	// 过滤掉的消息不需要确认，消费进度直接推进
+	if offset := broker.committedOffset("GID_PAID", "Orders", 1); offset != 1 {
+		t.Fatalf("filtered queue offset should be 1, got %d", offset)
+	}
+	if status := doRequest(t, "GET", url+"/groups/GID_PAID/topics/Orders/messages?wait=0&tags=created", "", nil); status != http.StatusConflict {
+		t.Fatalf("expect 409 for different tags, got %d", status)
+	}
+	if status := doRequest(t, "GET", url+"/groups/GID_PAID/topics/NotExist/messages?wait=0", "", nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 for topic without route, got %d", status)
+	}
+}
+
+func TestRestStream(t *testing.T) {
+	broker := newFakeBroker("Orders")
+	gateway, url := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+
+	response, err := http.Get(url + "/groups/GID_SSE/topics/Orders/stream?autoAck=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || !strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("unexpected response %d %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+
+	for gateway.SubscriptionCount() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	broker.Send(message.NewMessage("Orders", "", []byte("s1")))
+	broker.Send(message.NewMessage("Orders", "", []byte{0xff, 0xfe}))
+
+	reader := bufio.NewReader(response.Body)
+	var events []*ConsumedMessage
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			msg := new(ConsumedMessage)
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), msg); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, msg)
+		}
+	}
+	if events[0].Body != "s1" || events[1].Encoding != ENCODING_BASE64 || events[1].Body != "//4=" {
+		t.Fatalf("unexpected events %+v %+v", events[0], events[1])
+	}
+
+	// autoAck推送后即提交消费进度
+	deadline := time.Now().Add(2 * time.Second)
+	for broker.committedOffset("GID_SSE", "Orders", 0) != 1 || broker.committedOffset("GID_SSE", "Orders", 1) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("offset not committed after auto ack")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestAcl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	aclConfig := `{"accounts": [{"accessKey": "app", "secretKey": "app123", "topicPerms": ["Orders=PUB|SUB"], "groupPerms": ["GID_APP=SUB"]}]}`
+	aclPath := filepath.Join(dir, "plain_acl.json")
+	if err := ioutil.WriteFile(aclPath, []byte(aclConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newTestConfig()
+	cfg.AclEnable = true
+	cfg.AclConfigPath = aclPath
+	broker := newFakeBroker("Orders", "Payments")
+	gateway, url := startTestGateway(t, cfg, broker)
+	defer gateway.Shutdown()
+
+	newSignedRequest := func(method, path, body, secretKey string) *http.Request {
+		request, _ := http.NewRequest(method, url+path, bytes.NewBufferString(body))
+		acl.SignHttpRequest(request, []byte(body), "app", secretKey)
+		return request
+	}
+
+	body := `{"body": "hello"}`
+	if status := doHttpRequest(t, newSignedRequest("POST", "/topics/Orders/messages", body, "app123"), nil); status != http.StatusOK {
+		t.Fatalf("expect signed send pass, got %d", status)
+	}
+	if status := doRequest(t, "POST", url+"/topics/Orders/messages", body, nil); status != http.StatusForbidden {
+		t.Fatalf("expect 403 for unsigned request, got %d", status)
+	}
+	if status := doHttpRequest(t, newSignedRequest("POST", "/topics/Orders/messages", body, "wrong"), nil); status != http.StatusForbidden {
+		t.Fatalf("expect 403 for wrong secretKey, got %d", status)
+	}
+	if status := doHttpRequest(t, newSignedRequest("POST", "/topics/Payments/messages", body, "app123"), nil); status != http.StatusForbidden {
+		t.Fatalf("expect 403 for topic without permission, got %d", status)
+	}
+
+	// 篡改查询参数后签名失效
+	request := newSignedRequest("GET", "/groups/GID_APP/topics/Orders/messages?wait=0", "", "app123")
+	if status := doHttpRequest(t, request, nil); status != http.StatusOK {
+		t.Fatalf("expect signed poll pass, got %d", status)
+	}
+	request.URL.RawQuery = "wait=1"
+	if status := doHttpRequest(t, request, nil); status != http.StatusForbidden {
+		t.Fatalf("expect 403 for tampered query, got %d", status)
+	}
+	if status := doHttpRequest(t, newSignedRequest("GET", "/groups/GID_OTHER/topics/Orders/messages?wait=0", "", "app123"), nil); status != http.StatusForbidden {
+		t.Fatalf("expect 403 for group without permission, got %d", status)
+	}
+}
+
+func TestRestConfig(t *testing.T) {
+	if err := NewRestGatewayConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := NewRestGatewayConfig()
+	cfg.AccessKey = "gateway"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("accessKey without secretKey should be rejected")
+	}
+	cfg = NewRestGatewayConfig()
+	cfg.SubscriptionIdle = 10
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("subscriptionIdle less than invisibleTime should be rejected")
+	}
+
+	handle := &receipt{brokerName: "broker@a", queueId: 3, offset: 42, delivery: 2}
+	if decoded, err := decodeReceipt(handle.encode()); err != nil || *decoded != *handle {
+		t.Fatalf("unexpected receipt %+v %v", decoded, err)
+	}
+	if _, err := decodeReceipt("broker-a@1@x@1"); err == nil {
+		t.Fatal("invalid receipt should be rejected")
+	}
+	if _, err := decodeReceipt("broker-a@1@1"); err == nil {
+		t.Fatal("receipt without delivery should be rejected")
+	}
+}
@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+const (
+	ENCODING_TEXT   = "text"   // 消息体为UTF-8文本
+	ENCODING_BASE64 = "base64" // 消息体为base64编码的二进制
+)
+
+// SendMessageRequest POST /topics/{topic}/messages 的请求体，批量发送时为数组
+// Author: agent
+// Since: 2026/10/19
+type SendMessageRequest struct {
+	Body       string            `json:"body"`
+	Encoding   string            `json:"encoding,omitempty"` // text(默认)或base64
+	Tags       string            `json:"tags,omitempty"`
+	Keys       []string          `json:"keys,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"` // 用户属性，不能使用系统属性名
+	DelayLevel int               `json:"delayLevel,omitempty"` // 延时级别，与broker的messageDelayLevel对应
+	Delay      int64             `json:"delay,omitempty"`      // 延时投递的毫秒数，与delayLevel不能同时设置
+}
+
+// toMessage 转换为smartgo消息，now为当前毫秒时间戳
+func (request *SendMessageRequest) toMessage(topic string, now int64) (*message.Message, error) {
+	body, err := decodeBody(request.Body, request.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("body is empty")
+	}
+	if request.DelayLevel < 0 || request.Delay < 0 {
+		return nil, fmt.Errorf("delayLevel and delay must not be negative")
+	}
+	if request.DelayLevel > 0 && request.Delay > 0 {
+		return nil, fmt.Errorf("delayLevel and delay can not be set together")
+	}
+
+	msg := message.NewMessage(topic, request.Tags, body)
+	for name, value := range request.Properties {
+		if name == "" || message.SYSTEM_PROPERTIES[name] {
+			return nil, fmt.Errorf("invalid property name %q", name)
+		}
+		msg.PutProperty(name, value)
+	}
+	if len(request.Keys) > 0 {
+		msg.SetKeys(strings.Join(request.Keys, message.KEY_SEPARATOR))
+	}
+	if request.DelayLevel > 0 {
+		msg.SetDelayTimeLevel(request.DelayLevel)
+	}
+	if request.Delay > 0 {
+		msg.SetStartDeliverTime(now + request.Delay)
+	}
+	return msg, nil
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", ENCODING_TEXT:
+		return []byte(body), nil
+	case ENCODING_BASE64:
+		data, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode base64 body failed: %s", err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("invalid encoding %q, expect text or base64", encoding)
+}
+
+// SendMessageResult 单条消息的发送结果，Error非空表示发送失败
+// Author: agent
+// Since: 2026/10/19
+type SendMessageResult struct {
+	MsgId       string `json:"msgId,omitempty"`
+	Status      string `json:"status,omitempty"`
+	BrokerName  string `json:"brokerName,omitempty"`
+	QueueId     int    `json:"queueId"`
+	QueueOffset int64  `json:"queueOffset"`
+	Error       string `json:"error,omitempty"`
+}
+
+func newSendMessageResult(result *process.SendResult, err error) *SendMessageResult {
+	if err != nil {
+		return &SendMessageResult{Error: err.Error()}
+	}
+	if result == nil {
+		return &SendMessageResult{Error: "send result is nil"}
+	}
+	sendResult := &SendMessageResult{MsgId: result.MsgId, Status: result.SendStatus.String(), QueueOffset: result.QueueOffset}
+	if result.MessageQueue != nil {
+		sendResult.BrokerName = result.MessageQueue.BrokerName
+		sendResult.QueueId = result.MessageQueue.QueueId
+	}
+	return sendResult
+}
+
+// ConsumedMessage 拉取到的消息，确认时提交Receipt
+// Author: agent
+// Since: 2026/10/19
+type ConsumedMessage struct {
+	Receipt        string            `json:"receipt"`
+	MsgId          string            `json:"msgId"`
+	Topic          string            `json:"topic"`
+	Tags           string            `json:"tags,omitempty"`
+	Keys           []string          `json:"keys,omitempty"`
+	Properties     map[string]string `json:"properties,omitempty"`
+	Body           string            `json:"body"`
+	Encoding       string            `json:"encoding"`
+	QueueId        int32             `json:"queueId"`
+	QueueOffset    int64             `json:"queueOffset"`
+	BornTimestamp  int64             `json:"bornTimestamp"`
+	StoreTimestamp int64             `json:"storeTimestamp"`
+	DeliveryCount  int               `json:"deliveryCount"` // 网关投递的次数，超过1表示未在不可见时间内确认而重新投递
+}
+
+func newConsumedMessage(msg *message.MessageExt, brokerName string, deliveryCount int) *ConsumedMessage {
+	consumed := &ConsumedMessage{
+		Receipt:        (&receipt{brokerName: brokerName, queueId: int(msg.QueueId), offset: msg.QueueOffset, delivery: deliveryCount}).encode(),
+		MsgId:          msg.MsgId,
+		Topic:          msg.Topic,
+		QueueId:        msg.QueueId,
+		QueueOffset:    msg.QueueOffset,
+		BornTimestamp:  msg.BornTimestamp,
+		StoreTimestamp: msg.StoreTimestamp,
+		DeliveryCount:  deliveryCount,
+	}
+	for name, value := range msg.Properties {
+		switch name {
+		case message.PROPERTY_TAGS:
+			consumed.Tags = value
+		case message.PROPERTY_KEYS:
+			consumed.Keys = strings.Fields(value)
+		default:
+			if consumed.Properties == nil {
+				consumed.Properties = make(map[string]string)
+			}
+			consumed.Properties[name] = value
+		}
+	}
+	if utf8.Valid(msg.Body) {
+		consumed.Body, consumed.Encoding = string(msg.Body), ENCODING_TEXT
+	} else {
+		consumed.Body, consumed.Encoding = base64.StdEncoding.EncodeToString(msg.Body), ENCODING_BASE64
+	}
+	return consumed
+}
+
+// receipt 消息的确认句柄：brokerName@queueId@queueOffset@delivery，delivery为网关投递的次数，
+// 消息重新投递后之前投递的句柄失效
+type receipt struct {
+	brokerName string
+	queueId    int
+	offset     int64
+	delivery   int
+}
+
+func (r *receipt) encode() string {
+	return fmt.Sprintf("%s@%d@%d@%d", r.brokerName, r.queueId, r.offset, r.delivery)
+}
+
+func decodeReceipt(value string) (*receipt, error) {
+	items := strings.Split(value, "@")
+	n := len(items)
+	if n < 4 {
+		return nil, fmt.Errorf("invalid receipt %q", value)
+	}
+	queueId, err := strconv.Atoi(items[n-3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt %q", value)
+	}
+	offset, err := strconv.ParseInt(items[n-2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt %q", value)
+	}
+	delivery, err := strconv.Atoi(items[n-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid receipt %q", value)
+	}
+	return &receipt{brokerName: strings.Join(items[:n-3], "@"), queueId: queueId, offset: offset, delivery: delivery}, nil
+}
+
+// AckRequest POST /groups/{group}/topics/{topic}/ack 的请求体
+// Author: agent
+// Since: 2026/10/19
+type AckRequest struct {
+	Receipts []string `json:"receipts"`
+}
+
+// AckResult 确认结果，Invalid为格式错误、已确认、已重新投递或所属group、topic已释放的句柄
+// Author: agent
+// Since: 2026/10/19
+type AckResult struct {
+	Acked   int      `json:"acked"`
+	Invalid []string `json:"invalid,omitempty"`
+}
@@ -0,0 +1,384 @@
+package rest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+)
+
+const queueRefreshInterval = 30 * 1000 // 重新查询topic队列列表的间隔，单位毫秒
+
+// groupPuller 按消费组拉取消息并提交消费进度，便于测试时替换
+type groupPuller interface {
+	Start() error
+	Shutdown()
+	FetchMessageQueues(topic string) ([]*message.MessageQueue, error)
+	MaxOffset(mq *message.MessageQueue) (int64, error)
+	Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (*consumer.PullResult, error)
+	FetchConsumeOffset(mq *message.MessageQueue) (int64, error)
+	UpdateConsumeOffset(mq *message.MessageQueue, offset int64) error
+}
+
+// pullConsumerPuller 基于DefaultMQPullConsumer实现，将客户端的panic转换为error
+type pullConsumerPuller struct {
+	consumer *process.DefaultMQPullConsumer
+}
+
+func newPullConsumerPuller(group, namesrvAddr string, rpcHook remoting.RPCHook) *pullConsumerPuller {
+	pullConsumer := process.NewCustomMQPullConsumer(group, rpcHook)
+	pullConsumer.SetNamesrvAddr(namesrvAddr)
+	return &pullConsumerPuller{consumer: pullConsumer}
+}
+
+func (puller *pullConsumerPuller) Start() (err error) {
+	defer gwutil.RecoverError(&err)
+	puller.consumer.Start()
+	return nil
+}
+
+func (puller *pullConsumerPuller) Shutdown() {
+	defer gwutil.RecoverError(new(error))
+	puller.consumer.Shutdown()
+}
+
+func (puller *pullConsumerPuller) FetchMessageQueues(topic string) (mqs []*message.MessageQueue, err error) {
+	defer gwutil.RecoverError(&err)
+	return puller.consumer.FetchSubscribeMessageQueues(topic), nil
+}
+
+func (puller *pullConsumerPuller) MaxOffset(mq *message.MessageQueue) (offset int64, err error) {
+	defer gwutil.RecoverError(&err)
+	if offset = puller.consumer.MaxOffset(mq); offset < 0 {
+		return 0, fmt.Errorf("query max offset of %s failed", queueKey(mq.BrokerName, mq.QueueId))
+	}
+	return offset, nil
+}
+
+func (puller *pullConsumerPuller) Pull(mq *message.MessageQueue, subExpression string, offset int64, maxNums int) (result *consumer.PullResult, err error) {
+	defer gwutil.RecoverError(&err)
+	return puller.consumer.Pull(mq, subExpression, offset, maxNums)
+}
+
+func (puller *pullConsumerPuller) FetchConsumeOffset(mq *message.MessageQueue) (offset int64, err error) {
+	defer gwutil.RecoverError(&err)
+	return puller.consumer.FetchConsumeOffset(mq, true), nil
+}
+
+func (puller *pullConsumerPuller) UpdateConsumeOffset(mq *message.MessageQueue, offset int64) (err error) {
+	defer gwutil.RecoverError(&err)
+	puller.consumer.UpdateConsumeOffset(mq, offset)
+	return nil
+}
+
+func queueKey(brokerName string, queueId int) string {
+	return fmt.Sprintf("%s@%d", brokerName, queueId)
+}
+
+// inflightMessage 已拉取未确认的消息
+type inflightMessage struct {
+	msg           *message.MessageExt
+	deliveryCount int
+	visibleTime   int64 // 可再次投递的时间，0表示尚未投递
+}
+
+// queueState 单个队列的拉取位置与消费进度，消费进度为最小的未确认位置
+type queueState struct {
+	mq         *message.MessageQueue
+	nextOffset int64 // 下一次拉取的位置
+	committed  int64 // 已提交到broker的消费进度
+	inflight   map[int64]*inflightMessage
+	pulling    bool // 正在拉取，拉取在锁外进行，同一队列同时只有一个拉取
+}
+
+// subscription 消费组对一个topic的订阅：按队列拉取消息，投递后在不可见时间内未确认的消息重新投递，
+// 确认后将各队列的消费进度推进到最小的未确认位置并提交到broker
+// Author: agent
+// Since: 2026/10/19
+type subscription struct {
+	group       string
+	topic       string
+	expression  string
+	puller      groupPuller
+	queues      map[string]*queueState
+	inflight    int
+	pullIndex   int // 轮流从不同的队列开始拉取，避免消息多的队列占满每次的返回
+	lastRefresh int64
+	refreshing  bool // 正在锁外查询队列列表
+	lastAccess  int64
+	lock        sync.Mutex
+}
+
+func newSubscription(group, topic, expression string, puller groupPuller, now int64) *subscription {
+	return &subscription{
+		group:      group,
+		topic:      topic,
+		expression: expression,
+		puller:     puller,
+		queues:     make(map[string]*queueState),
+		lastAccess: now,
+	}
+}
+
+// poll 返回最多maxNums条消息：先投递超过不可见时间未确认的消息，再从各队列拉取新消息，
+// 已拉取未确认的消息达到maxInflight时不再拉取。拉取在锁外进行，不阻塞同一订阅的确认及其他拉取
+func (sub *subscription) poll(maxNums, maxInflight int, invisibleTime, now int64) ([]*ConsumedMessage, error) {
+	if err := sub.refreshQueues(now); err != nil {
+		return nil, err
+	}
+
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	sub.lastAccess = now
+
+	var result []*ConsumedMessage
+	for _, key := range sub.queueKeys() {
+		queue := sub.queues[key]
+		for _, offset := range sortedOffsets(queue.inflight) {
+			if len(result) >= maxNums {
+				return result, nil
+			}
+			inflight := queue.inflight[offset]
+			if inflight.visibleTime <= now {
+				result = append(result, sub.deliver(queue, inflight, invisibleTime, now))
+			}
+		}
+	}
+
+	keys := sub.queueKeys()
+	sub.pullIndex++
+	start := sub.pullIndex
+	for i := range keys {
+		if len(result) >= maxNums || sub.inflight >= maxInflight {
+			break
+		}
+		queue, ok := sub.queues[keys[(start+i)%len(keys)]]
+		if !ok || queue.pulling {
+			continue
+		}
+		pullNums := maxNums - len(result)
+		if room := maxInflight - sub.inflight; room < pullNums {
+			pullNums = room
+		}
+		offset := queue.nextOffset
+		queue.pulling = true
+		sub.lock.Unlock()
+		pullResult, err := sub.puller.Pull(queue.mq, sub.expression, offset, pullNums)
+		sub.lock.Lock()
+		queue.pulling = false
+		if err == nil && pullResult == nil {
+			err = fmt.Errorf("pull result is nil")
+		}
+		if err != nil {
+			logger.Warnf("rest gateway group %s pull %s failed: %s", sub.group, queueKey(queue.mq.BrokerName, queue.mq.QueueId), err)
+			continue
+		}
+		for _, inflight := range sub.applyPull(queue, pullResult) {
+			result = append(result, sub.deliver(queue, inflight, invisibleTime, now))
+		}
+	}
+	return result, nil
+}
+
+func (sub *subscription) deliver(queue *queueState, inflight *inflightMessage, invisibleTime, now int64) *ConsumedMessage {
+	inflight.deliveryCount++
+	inflight.visibleTime = now + invisibleTime
+	return newConsumedMessage(inflight.msg, queue.mq.BrokerName, inflight.deliveryCount)
+}
+
+// applyPull 将拉取到的消息登记为未确认并更新拉取位置，没有新消息时推进消费进度
+func (sub *subscription) applyPull(queue *queueState, result *consumer.PullResult) []*inflightMessage {
+	var msgs []*inflightMessage
+	switch result.PullStatus {
+	case consumer.FOUND:
+		for _, msg := range result.MsgFoundList {
+			if msg.QueueOffset < queue.nextOffset || queue.inflight[msg.QueueOffset] != nil {
+				continue
+			}
+			inflight := &inflightMessage{msg: msg}
+			queue.inflight[msg.QueueOffset] = inflight
+			sub.inflight++
+			msgs = append(msgs, inflight)
+		}
+	case consumer.OFFSET_ILLEGAL:
+		logger.Warnf("rest gateway group %s pull %s offset %d illegal, correct to %d", sub.group,
+			queueKey(queue.mq.BrokerName, queue.mq.QueueId), queue.nextOffset, result.NextBeginOffset)
+	}
+	if result.NextBeginOffset > queue.nextOffset || result.PullStatus == consumer.OFFSET_ILLEGAL {
+		queue.nextOffset = result.NextBeginOffset
+	}
+	// 过滤掉的消息不需要确认，消费进度直接推进
+	if len(msgs) == 0 {
+		sub.commit(queue)
+	}
+	return msgs
+}
+
+// ack 确认消息，返回确认的条数及无效的句柄
+func (sub *subscription) ack(receipts []string, now int64) *AckResult {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	sub.lastAccess = now
+
+	result := &AckResult{}
+	acked := make(map[*queueState]bool)
+	for _, receipt := range receipts {
+		handle, err := decodeReceipt(receipt)
+		if err != nil {
+			result.Invalid = append(result.Invalid, receipt)
+			continue
+		}
+		// 重新投递后之前投递的句柄失效，避免迟到的确认删除其他客户端正在处理的消息
+		queue, ok := sub.queues[queueKey(handle.brokerName, handle.queueId)]
+		if !ok || queue.inflight[handle.offset] == nil || queue.inflight[handle.offset].deliveryCount != handle.delivery {
+			result.Invalid = append(result.Invalid, receipt)
+			continue
+		}
+		delete(queue.inflight, handle.offset)
+		sub.inflight--
+		acked[queue] = true
+		result.Acked++
+	}
+	for queue := range acked {
+		sub.commit(queue)
+	}
+	return result
+}
+
+// commit 消费进度推进到最小的未确认位置，没有未确认的消息时推进到拉取位置
+func (sub *subscription) commit(queue *queueState) {
+	offset := queue.nextOffset
+	for inflightOffset := range queue.inflight {
+		if inflightOffset < offset {
+			offset = inflightOffset
+		}
+	}
+	if offset <= queue.committed {
+		return
+	}
+	if err := sub.puller.UpdateConsumeOffset(queue.mq, offset); err != nil {
+		logger.Warnf("rest gateway group %s commit %s offset %d failed: %s", sub.group, queueKey(queue.mq.BrokerName, queue.mq.QueueId), offset, err)
+		return
+	}
+	queue.committed = offset
+}
+
+// refreshQueues 定时重新查询topic的队列列表，新增队列从broker上的消费进度开始拉取，
+// 没有消费进度时从队列的最大位置开始，与CONSUME_FROM_LAST_OFFSET一致。查询在锁外进行，同时只有一个查询
+func (sub *subscription) refreshQueues(now int64) error {
+	sub.lock.Lock()
+	if sub.refreshing || len(sub.queues) > 0 && now-sub.lastRefresh < queueRefreshInterval {
+		sub.lock.Unlock()
+		return nil
+	}
+	sub.refreshing = true
+	known := make(map[string]bool, len(sub.queues))
+	for key := range sub.queues {
+		known[key] = true
+	}
+	sub.lock.Unlock()
+
+	mqs, err := sub.puller.FetchMessageQueues(sub.topic)
+	if err == nil && len(mqs) == 0 {
+		err = fmt.Errorf("topic %s has no message queue", sub.topic)
+	}
+	offsets := make(map[string]int64)
+	if err == nil {
+		for _, mq := range mqs {
+			key := queueKey(mq.BrokerName, mq.QueueId)
+			if known[key] {
+				continue
+			}
+			offset, err := sub.initialOffset(mq)
+			if err != nil {
+				logger.Warnf("rest gateway group %s init offset of %s failed: %s", sub.group, key, err)
+				continue
+			}
+			offsets[key] = offset
+		}
+	}
+
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	sub.refreshing = false
+	if err != nil {
+		if len(sub.queues) > 0 {
+			logger.Warnf("rest gateway refresh queues of topic %s failed: %s", sub.topic, err)
+			return nil
+		}
+		return err
+	}
+	sub.lastRefresh = now
+
+	keys := make(map[string]bool, len(mqs))
+	for _, mq := range mqs {
+		key := queueKey(mq.BrokerName, mq.QueueId)
+		keys[key] = true
+		if _, ok := sub.queues[key]; ok {
+			continue
+		}
+		if offset, ok := offsets[key]; ok {
+			sub.queues[key] = &queueState{mq: mq, nextOffset: offset, committed: offset, inflight: make(map[int64]*inflightMessage)}
+		}
+	}
+	for key, queue := range sub.queues {
+		if !keys[key] && len(queue.inflight) == 0 && !queue.pulling {
+			delete(sub.queues, key)
+		}
+	}
+	return nil
+}
+
+func (sub *subscription) initialOffset(mq *message.MessageQueue) (int64, error) {
+	offset, err := sub.puller.FetchConsumeOffset(mq)
+	if err != nil {
+		return 0, err
+	}
+	if offset >= 0 {
+		return offset, nil
+	}
+	if offset, err = sub.puller.MaxOffset(mq); err != nil {
+		return 0, err
+	}
+	// 立即提交初始位置，避免网关重启后从新的最大位置开始而遗漏消息
+	if err = sub.puller.UpdateConsumeOffset(mq, offset); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+func (sub *subscription) queueKeys() []string {
+	keys := make([]string, 0, len(sub.queues))
+	for key := range sub.queues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (sub *subscription) idle(now, idleTime int64) bool {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	return now-sub.lastAccess >= idleTime
+}
+
+func (sub *subscription) touch(now int64) {
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	sub.lastAccess = now
+}
+
+func sortedOffsets(inflight map[int64]*inflightMessage) []int64 {
+	offsets := make([]int64, 0, len(inflight))
+	for offset := range inflight {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets
+}
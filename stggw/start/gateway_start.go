@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"syscall"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/mqversion"
+	"git.oschina.net/cloudzone/smartgo/stggw/coap"
+	"git.oschina.net/cloudzone/smartgo/stggw/mqtt"
+	"git.oschina.net/cloudzone/smartgo/stggw/rest"
+	"git.oschina.net/cloudzone/smartgo/stggw/stomp"
+	"github.com/toolkits/file"
+)
+
+func main() {
+	debug.SetMaxThreads(100000)
+
+	c := flag.String("c", "", "MQTT gateway config *.toml file, default $SMARTGO_HOME/conf/gateway.toml")
+	coapPath := flag.String("coap", "", "CoAP gateway config *.toml file, default $SMARTGO_HOME/conf/coap_gateway.toml, not started if not exist")
+	restPath := flag.String("rest", "", "HTTP/REST gateway config *.toml file, default $SMARTGO_HOME/conf/rest_gateway.toml, not started if not exist")
+	stompPath := flag.String("stomp", "", "STOMP gateway config *.toml file, default $SMARTGO_HOME/conf/stomp_gateway.toml, not started if not exist")
+	h := flag.Bool("h", false, "help")
+	v := flag.Bool("v", false, "version")
+
+	flag.Parse()
+
+	if *h {
+		flag.Usage()
+		os.Exit(0)
+	}
+
+	if *v {
+		fmt.Println(mqversion.GetCurrentDesc())
+		os.Exit(0)
+	}
+
+	cfgPath := *c
+	if cfgPath == "" {
+		cfgPath = filepath.Join(stgcommon.GetSmartGoHome(), "conf", "gateway.toml")
+	}
+	if !file.IsExist(cfgPath) {
+		fmt.Println("use -c to valid gateway config path. eg: -c /home/smartgo-bin/conf/gateway.toml")
+		os.Exit(0)
+	}
+
+	cfg, err := mqtt.LoadGatewayConfig(cfgPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	gateway, err := mqtt.NewMqttGateway(cfg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := gateway.Start(); err != nil {
+		fmt.Println(err)
+		gateway.Shutdown()
+		os.Exit(1)
+	}
+
+	// CoAP网关可选，配置文件不存在时不启动
+	var coapGateway *coap.CoapGateway
+	if *coapPath == "" {
+		*coapPath = filepath.Join(stgcommon.GetSmartGoHome(), "conf", "coap_gateway.toml")
+	}
+	if file.IsExist(*coapPath) {
+		coapCfg, err := coap.LoadCoapGatewayConfig(*coapPath)
+		if err != nil {
+			fmt.Println(err)
+			gateway.Shutdown()
+			os.Exit(1)
+		}
+		if coapGateway, err = coap.NewCoapGateway(coapCfg); err == nil {
+			err = coapGateway.Start()
+		}
+		if err != nil {
+			fmt.Println(err)
+			if coapGateway != nil {
+				coapGateway.Shutdown()
+			}
+			gateway.Shutdown()
+			os.Exit(1)
+		}
+	}
+
+	// HTTP/REST网关可选，配置文件不存在时不启动
+	var restGateway *rest.RestGateway
+	if *restPath == "" {
+		*restPath = filepath.Join(stgcommon.GetSmartGoHome(), "conf", "rest_gateway.toml")
+	}
+	if file.IsExist(*restPath) {
+		restCfg, err := rest.LoadRestGatewayConfig(*restPath)
+		if err == nil {
+			if restGateway, err = rest.NewRestGateway(restCfg); err == nil {
+				err = restGateway.Start()
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+			if restGateway != nil {
+				restGateway.Shutdown()
+			}
+			if coapGateway != nil {
+				coapGateway.Shutdown()
+			}
+			gateway.Shutdown()
+			os.Exit(1)
+		}
+	}
+
+	// STOMP网关可选，配置文件不存在时不启动
+	var stompGateway *stomp.StompGateway
+	if *stompPath == "" {
+		*stompPath = filepath.Join(stgcommon.GetSmartGoHome(), "conf", "stomp_gateway.toml")
+	}
+	if file.IsExist(*stompPath) {
+		stompCfg, err := stomp.LoadStompGatewayConfig(*stompPath)
+		if err == nil {
+			if stompGateway, err = stomp.NewStompGateway(stompCfg); err == nil {
+				err = stompGateway.Start()
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+			if stompGateway != nil {
+				stompGateway.Shutdown()
+			}
+			if restGateway != nil {
+				restGateway.Shutdown()
+			}
+			if coapGateway != nil {
+				coapGateway.Shutdown()
+			}
+			gateway.Shutdown()
+			os.Exit(1)
+		}
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	<-signalChan
+	signal.Stop(signalChan)
+
+	if stompGateway != nil {
+		stompGateway.Shutdown()
+	}
+	if restGateway != nil {
+		restGateway.Shutdown()
+	}
+	if coapGateway != nil {
+		coapGateway.Shutdown()
+	}
+	gateway.Shutdown()
+	logger.Flush()
+}
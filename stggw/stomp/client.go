@@ -0,0 +1,246 @@
+package stomp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client 简单的STOMP 1.2客户端，用于测试及联调网关：带receipt的帧等待RECEIPT，
+// MESSAGE帧按subscription分发，收到ERROR帧或连接断开后全部等待返回error
+// Author: agent
+// Since: 2026/10/19
+type Client struct {
+	conn            net.Conn
+	reader          *FrameReader
+	timeout         time.Duration
+	writeLock       sync.Mutex
+	lock            sync.Mutex
+	receiptSeq      int
+	subscriptionSeq int
+	receipts        map[string]chan *Frame
+	subscriptions   map[string]*ClientSubscription
+	err             error
+	closeChan       chan struct{}
+}
+
+// ClientSubscription 客户端的一个订阅，Messages返回收到的MESSAGE帧
+// Author: agent
+// Since: 2026/10/19
+type ClientSubscription struct {
+	client   *Client
+	id       string
+	messages chan *Frame
+}
+
+// DialClient 连接网关并完成CONNECT，headers为CONNECT帧附加的key、value，timeout为等待应答的超时时间
+// Author: agent
+// Since: 2026/10/19
+func DialClient(addr string, timeout time.Duration, headers ...string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	connect := NewFrame(CMD_CONNECT, HDR_ACCEPT_VERSION, PROTOCOL_VERSION, HDR_HOST, host)
+	for i := 0; i+1 < len(headers); i += 2 {
+		connect.SetHeader(headers[i], headers[i+1])
+	}
+
+	client := &Client{
+		conn:          conn,
+		reader:        NewFrameReader(conn, 64*1024*1024, 1024),
+		timeout:       timeout,
+		receipts:      make(map[string]chan *Frame),
+		subscriptions: make(map[string]*ClientSubscription),
+		closeChan:     make(chan struct{}),
+	}
+	if err := client.write(connect); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	frame, err := client.readFrame()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	if frame.Command != CMD_CONNECTED {
+		conn.Close()
+		return nil, fmt.Errorf("connect failed: %s %s", frame.HeaderValue(HDR_MESSAGE), frame.Body)
+	}
+	go client.run()
+	return client, nil
+}
+
+// Close 直接关闭连接
+func (client *Client) Close() {
+	client.conn.Close()
+}
+
+// Disconnect 发送带receipt的DISCONNECT，收到RECEIPT后关闭连接
+func (client *Client) Disconnect() error {
+	err := client.Request(NewFrame(CMD_DISCONNECT))
+	client.Close()
+	return err
+}
+
+// Send 发送消息并等待RECEIPT，headers为附加的key、value
+func (client *Client) Send(destination string, body []byte, headers ...string) error {
+	frame := NewFrame(CMD_SEND, HDR_DESTINATION, destination)
+	for i := 0; i+1 < len(headers); i += 2 {
+		frame.AddHeader(headers[i], headers[i+1])
+	}
+	frame.Body = body
+	return client.Request(frame)
+}
+
+// Subscribe 订阅destination并等待RECEIPT，ack为auto、client或client-individual
+func (client *Client) Subscribe(destination, ack string) (*ClientSubscription, error) {
+	client.lock.Lock()
+	client.subscriptionSeq++
+	sub := &ClientSubscription{client: client, id: "sub-" + strconv.Itoa(client.subscriptionSeq), messages: make(chan *Frame, 1024)}
+	client.subscriptions[sub.id] = sub
+	client.lock.Unlock()
+
+	if err := client.Request(NewFrame(CMD_SUBSCRIBE, HDR_ID, sub.id, HDR_DESTINATION, destination, HDR_ACK, ack)); err != nil {
+		client.lock.Lock()
+		delete(client.subscriptions, sub.id)
+		client.lock.Unlock()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Request 为帧添加receipt后发送，并等待对应的RECEIPT
+// Author: agent
+// Since: 2026/10/19
+func (client *Client) Request(frame *Frame) error {
+	client.lock.Lock()
+	if client.err != nil {
+		client.lock.Unlock()
+		return client.err
+	}
+	client.receiptSeq++
+	receipt := "receipt-" + strconv.Itoa(client.receiptSeq)
+	ch := make(chan *Frame, 1)
+	client.receipts[receipt] = ch
+	client.lock.Unlock()
+
+	frame.SetHeader(HDR_RECEIPT, receipt)
+	if err := client.write(frame); err != nil {
+		return err
+	}
+	timer := time.NewTimer(client.timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return nil
+	case <-client.closeChan:
+		// DISCONNECT等帧的RECEIPT之后网关会关闭连接
+		select {
+		case <-ch:
+			return nil
+		default:
+			return client.Err()
+		}
+	case <-timer.C:
+		client.lock.Lock()
+		delete(client.receipts, receipt)
+		client.lock.Unlock()
+		return fmt.Errorf("wait receipt of %s timeout", frame.Command)
+	}
+}
+
+// Err 连接断开或收到ERROR帧的原因
+func (client *Client) Err() error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	return client.err
+}
+
+// Ack 确认MESSAGE帧
+func (client *Client) Ack(frame *Frame, headers ...string) error {
+	return client.Request(NewFrame(CMD_ACK, append([]string{HDR_ID, frame.HeaderValue(HDR_ACK)}, headers...)...))
+}
+
+// Nack 拒绝MESSAGE帧，网关重新消费
+func (client *Client) Nack(frame *Frame, headers ...string) error {
+	return client.Request(NewFrame(CMD_NACK, append([]string{HDR_ID, frame.HeaderValue(HDR_ACK)}, headers...)...))
+}
+
+// Messages 收到的MESSAGE帧，缓冲已满时丢弃，连接断开后关闭
+func (sub *ClientSubscription) Messages() <-chan *Frame {
+	return sub.messages
+}
+
+// Unsubscribe 取消订阅并等待RECEIPT
+func (sub *ClientSubscription) Unsubscribe() error {
+	err := sub.client.Request(NewFrame(CMD_UNSUBSCRIBE, HDR_ID, sub.id))
+	sub.client.lock.Lock()
+	delete(sub.client.subscriptions, sub.id)
+	sub.client.lock.Unlock()
+	return err
+}
+
+func (client *Client) write(frame *Frame) error {
+	client.writeLock.Lock()
+	defer client.writeLock.Unlock()
+	client.conn.SetWriteDeadline(time.Now().Add(client.timeout))
+	_, err := client.conn.Write(frame.Encode())
+	return err
+}
+
+// readFrame 读取一帧，跳过心跳
+func (client *Client) readFrame() (*Frame, error) {
+	for {
+		frame, err := client.reader.ReadFrame()
+		if err != errHeartBeat {
+			return frame, err
+		}
+	}
+}
+
+func (client *Client) run() {
+	var err error
+	for {
+		var frame *Frame
+		if frame, err = client.readFrame(); err != nil {
+			break
+		}
+		if frame.Command == CMD_ERROR {
+			err = fmt.Errorf("%s: %s", frame.HeaderValue(HDR_MESSAGE), frame.Body)
+			break
+		}
+
+		client.lock.Lock()
+		switch frame.Command {
+		case CMD_RECEIPT:
+			if ch, ok := client.receipts[frame.HeaderValue(HDR_RECEIPT_ID)]; ok {
+				delete(client.receipts, frame.HeaderValue(HDR_RECEIPT_ID))
+				ch <- frame
+			}
+		case CMD_MESSAGE:
+			if sub, ok := client.subscriptions[frame.HeaderValue(HDR_SUBSCRIPTION)]; ok {
+				select {
+				case sub.messages <- frame:
+				default:
+				}
+			}
+		}
+		client.lock.Unlock()
+	}
+
+	client.conn.Close()
+	client.lock.Lock()
+	client.err = err
+	for _, sub := range client.subscriptions {
+		close(sub.messages)
+	}
+	client.subscriptions = make(map[string]*ClientSubscription)
+	client.lock.Unlock()
+	close(client.closeChan)
+}
@@ -0,0 +1,101 @@
+package stomp
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// StompGatewayConfig STOMP网关配置项
+// Author: agent
+// Since: 2026/10/19
+type StompGatewayConfig struct {
+	ListenHost                string // 监听地址
+	ListenPort                int    // STOMP监听端口，默认61613
+	NamesrvAddr               string // namesrv地址，多个以分号分隔
+	ProducerGroup             string // 内嵌producer的group
+	QueueGroupPrefix          string // /queue/{topic}集群消费使用的consumer group前缀，group为前缀+topic
+	TopicGroupPrefix          string // /topic/{topic}广播消费使用的consumer group前缀，group为前缀+topic
+	AccessKey                 string // 网关访问broker使用的账号，broker开启ACL时配置
+	SecretKey                 string // 网关访问broker使用的密钥
+	HeartBeatSend             int    // 网关发送心跳的最小间隔，单位毫秒，0表示不发送
+	HeartBeatReceive          int    // 网关期望接收心跳的间隔，单位毫秒，0表示不检查
+	ConnectTimeout            int    // 建立连接后等待CONNECT帧的时间，单位秒
+	AckTimeout                int    // client、client-individual模式下等待ACK的时间，超时按NACK处理，单位秒
+	MaxPendingPerSubscription int    // 每个订阅已投递未确认的最大消息数，超过后不再向该订阅分发
+	MaxFrameSize              int    // 帧的最大字节数
+	MaxHeaders                int    // 帧的最大header数
+}
+
+// NewStompGatewayConfig 创建默认配置
+// Author: agent
+// Since: 2026/10/19
+func NewStompGatewayConfig() *StompGatewayConfig {
+	return &StompGatewayConfig{
+		ListenHost:                "0.0.0.0",
+		ListenPort:                61613,
+		NamesrvAddr:               "127.0.0.1:9876",
+		ProducerGroup:             "PID_STOMP_GATEWAY",
+		QueueGroupPrefix:          "GID_STOMP_QUEUE_",
+		TopicGroupPrefix:          "GID_STOMP_TOPIC_",
+		HeartBeatSend:             10000,
+		HeartBeatReceive:          10000,
+		ConnectTimeout:            10,
+		AckTimeout:                60,
+		MaxPendingPerSubscription: 1000,
+		MaxFrameSize:              4 * 1024 * 1024,
+		MaxHeaders:                128,
+	}
+}
+
+// LoadStompGatewayConfig 加载toml配置文件，未配置的项使用默认值
+// Author: agent
+// Since: 2026/10/19
+func LoadStompGatewayConfig(path string) (*StompGatewayConfig, error) {
+	cfg := NewStompGatewayConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, fmt.Errorf("parse stomp gateway config %s failed: %s", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate 校验配置项
+// Author: agent
+// Since: 2026/10/19
+func (cfg *StompGatewayConfig) Validate() error {
+	if cfg.ListenPort < 0 || cfg.ListenPort > 65535 {
+		return fmt.Errorf("invalid listenPort %d", cfg.ListenPort)
+	}
+	if cfg.NamesrvAddr == "" {
+		return fmt.Errorf("namesrvAddr is empty")
+	}
+	if cfg.ProducerGroup == "" || cfg.QueueGroupPrefix == "" || cfg.TopicGroupPrefix == "" {
+		return fmt.Errorf("producerGroup, queueGroupPrefix and topicGroupPrefix must not be empty")
+	}
+	// 两种消费模式的group不能相同，否则集群与广播消费会混用同一group
+	if cfg.QueueGroupPrefix == cfg.TopicGroupPrefix {
+		return fmt.Errorf("queueGroupPrefix and topicGroupPrefix must be different")
+	}
+	if (cfg.AccessKey == "") != (cfg.SecretKey == "") {
+		return fmt.Errorf("accessKey and secretKey must be configured together")
+	}
+	if cfg.HeartBeatSend < 0 || cfg.HeartBeatReceive < 0 {
+		return fmt.Errorf("heartBeatSend and heartBeatReceive must not be negative")
+	}
+	if cfg.ConnectTimeout <= 0 || cfg.AckTimeout <= 0 {
+		return fmt.Errorf("connectTimeout and ackTimeout must be positive")
+	}
+	if cfg.MaxPendingPerSubscription <= 0 || cfg.MaxFrameSize <= 0 || cfg.MaxHeaders <= 0 {
+		return fmt.Errorf("maxPendingPerSubscription, maxFrameSize and maxHeaders must be positive")
+	}
+	return nil
+}
+
+func (cfg *StompGatewayConfig) String() string {
+	format := "StompGatewayConfig [listenHost=%s, listenPort=%d, namesrvAddr=%s, producerGroup=%s, queueGroupPrefix=%s, "
+	format += "topicGroupPrefix=%s, accessKey=%s, heartBeatSend=%d, heartBeatReceive=%d, connectTimeout=%d, ackTimeout=%d, "
+	format += "maxPendingPerSubscription=%d, maxFrameSize=%d, maxHeaders=%d]"
+	return fmt.Sprintf(format, cfg.ListenHost, cfg.ListenPort, cfg.NamesrvAddr, cfg.ProducerGroup, cfg.QueueGroupPrefix,
+		cfg.TopicGroupPrefix, cfg.AccessKey, cfg.HeartBeatSend, cfg.HeartBeatReceive, cfg.ConnectTimeout, cfg.AckTimeout,
+		cfg.MaxPendingPerSubscription, cfg.MaxFrameSize, cfg.MaxHeaders)
+}
@@ -0,0 +1,137 @@
+package stomp
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer"
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+)
+
+// dispatchRetryInterval /queue消息暂无可分发的订阅时，重新选择订阅的间隔
+const dispatchRetryInterval = 100 * time.Millisecond
+
+// destination 本网关上同一destination的全部订阅，共用一个push consumer
+type destination struct {
+	gateway       *StompGateway
+	name          string
+	topic         string
+	group         string
+	model         heartbeat.MessageModel
+	consumer      gwutil.MessageConsumer
+	subscriptions []*subscription
+	next          int
+	lock          sync.Mutex
+	closeChan     chan struct{}
+	starting      chan struct{} // 不为nil表示consumer正在锁外启动，由网关锁保护
+}
+
+func newDestination(gateway *StompGateway, name string) (*destination, error) {
+	topic, model, err := parseDestination(name)
+	if err != nil {
+		return nil, err
+	}
+	dest := &destination{gateway: gateway, name: name, topic: topic, model: model, closeChan: make(chan struct{})}
+	if model == heartbeat.CLUSTERING {
+		dest.group = gateway.config.QueueGroupPrefix + topic
+	} else {
+		dest.group = gateway.config.TopicGroupPrefix + topic
+	}
+	return dest, nil
+}
+
+func (dest *destination) add(sub *subscription) {
+	dest.lock.Lock()
+	dest.subscriptions = append(dest.subscriptions, sub)
+	dest.lock.Unlock()
+}
+
+// remove 移除订阅，返回剩余的订阅数
+func (dest *destination) remove(sub *subscription) int {
+	dest.lock.Lock()
+	defer dest.lock.Unlock()
+	for i, s := range dest.subscriptions {
+		if s == sub {
+			dest.subscriptions = append(dest.subscriptions[:i], dest.subscriptions[i+1:]...)
+			break
+		}
+	}
+	return len(dest.subscriptions)
+}
+
+// close 结束等待分发的消费，在关闭consumer前调用
+func (dest *destination) close() {
+	close(dest.closeChan)
+}
+
+// pick 轮询选择未确认消息数未达上限的订阅
+func (dest *destination) pick() *subscription {
+	dest.lock.Lock()
+	defer dest.lock.Unlock()
+	maxPending := int32(dest.gateway.config.MaxPendingPerSubscription)
+	for i := 0; i < len(dest.subscriptions); i++ {
+		sub := dest.subscriptions[(dest.next+i)%len(dest.subscriptions)]
+		if atomic.LoadInt32(&sub.inflight) < maxPending {
+			dest.next = (dest.next + i + 1) % len(dest.subscriptions)
+			return sub
+		}
+	}
+	return nil
+}
+
+func (dest *destination) snapshot() []*subscription {
+	dest.lock.Lock()
+	defer dest.lock.Unlock()
+	return append([]*subscription(nil), dest.subscriptions...)
+}
+
+// ConsumeMessage push consumer的监听，任一消息需要重新消费时整批重新消费
+func (dest *destination) ConsumeMessage(msgs []*message.MessageExt, context *consumer.ConsumeConcurrentlyContext) listener.ConsumeConcurrentlyStatus {
+	for _, msg := range msgs {
+		if !dest.consume(msg) {
+			return listener.RECONSUME_LATER
+		}
+	}
+	return listener.CONSUME_SUCCESS
+}
+
+// consume 广播消费时投递给全部订阅，不等待确认；
+// 集群消费时投递给一个订阅，auto模式写出即成功，其余模式等待ACK，NACK、超时或连接断开时返回false重新消费
+func (dest *destination) consume(msg *message.MessageExt) bool {
+	if dest.model == heartbeat.BROADCASTING {
+		for _, sub := range dest.snapshot() {
+			sub.session.deliver(sub, msg)
+		}
+		return true
+	}
+
+	deadline := time.Now().Add(time.Duration(dest.gateway.config.AckTimeout) * time.Second)
+	for {
+		if sub := dest.pick(); sub != nil {
+			if d := sub.session.deliver(sub, msg); d != nil {
+				if d.result == nil {
+					return true
+				}
+				select {
+				case ok := <-d.result:
+					return ok
+				case <-dest.closeChan:
+					return false
+				}
+			}
+			// 连接已断开，订阅移除后选择其他订阅
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-dest.closeChan:
+			return false
+		case <-time.After(dispatchRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package stomp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// STOMP 1.2的帧命令
+const (
+	CMD_CONNECT     = "CONNECT"
+	CMD_STOMP       = "STOMP"
+	CMD_CONNECTED   = "CONNECTED"
+	CMD_SEND        = "SEND"
+	CMD_SUBSCRIBE   = "SUBSCRIBE"
+	CMD_UNSUBSCRIBE = "UNSUBSCRIBE"
+	CMD_ACK         = "ACK"
+	CMD_NACK        = "NACK"
+	CMD_BEGIN       = "BEGIN"
+	CMD_COMMIT      = "COMMIT"
+	CMD_ABORT       = "ABORT"
+	CMD_DISCONNECT  = "DISCONNECT"
+	CMD_MESSAGE     = "MESSAGE"
+	CMD_RECEIPT     = "RECEIPT"
+	CMD_ERROR       = "ERROR"
+)
+
+// STOMP 1.2的标准header
+const (
+	HDR_ACCEPT_VERSION = "accept-version"
+	HDR_VERSION        = "version"
+	HDR_HOST           = "host"
+	HDR_LOGIN          = "login"
+	HDR_PASSCODE       = "passcode"
+	HDR_HEART_BEAT     = "heart-beat"
+	HDR_SESSION        = "session"
+	HDR_SERVER         = "server"
+	HDR_DESTINATION    = "destination"
+	HDR_CONTENT_TYPE   = "content-type"
+	HDR_CONTENT_LENGTH = "content-length"
+	HDR_RECEIPT        = "receipt"
+	HDR_RECEIPT_ID     = "receipt-id"
+	HDR_ID             = "id"
+	HDR_ACK            = "ack"
+	HDR_SUBSCRIPTION   = "subscription"
+	HDR_MESSAGE_ID     = "message-id"
+	HDR_TRANSACTION    = "transaction"
+	HDR_MESSAGE        = "message"
+
+	// smartgo扩展header，与消息的TAGS、KEYS属性对应
+	HDR_TAGS = "tags"
+	HDR_KEYS = "keys"
+)
+
+// 订阅的ack模式
+const (
+	ACK_AUTO              = "auto"
+	ACK_CLIENT            = "client"
+	ACK_CLIENT_INDIVIDUAL = "client-individual"
+)
+
+// errHeartBeat 读到的是心跳换行而非帧
+var errHeartBeat = errors.New("stomp heart-beat")
+
+// Header 帧的header，同名header以第一个为准
+// Author: agent
+// Since: 2026/10/19
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Frame STOMP帧
+// Author: agent
+// Since: 2026/10/19
+type Frame struct {
+	Command string
+	Headers []Header
+	Body    []byte
+}
+
+// NewFrame 创建帧，headers为依次排列的key、value
+// Author: agent
+// Since: 2026/10/19
+func NewFrame(command string, headers ...string) *Frame {
+	frame := &Frame{Command: command}
+	for i := 0; i+1 < len(headers); i += 2 {
+		frame.AddHeader(headers[i], headers[i+1])
+	}
+	return frame
+}
+
+// Header 获取header的值，同名header返回第一个
+func (frame *Frame) Header(key string) (string, bool) {
+	for _, header := range frame.Headers {
+		if header.Key == key {
+			return header.Value, true
+		}
+	}
+	return "", false
+}
+
+// HeaderValue 获取header的值，不存在时为空
+func (frame *Frame) HeaderValue(key string) string {
+	value, _ := frame.Header(key)
+	return value
+}
+
+// AddHeader 追加header
+func (frame *Frame) AddHeader(key, value string) {
+	frame.Headers = append(frame.Headers, Header{Key: key, Value: value})
+}
+
+// SetHeader 替换同名的第一个header，不存在时追加
+func (frame *Frame) SetHeader(key, value string) {
+	for i := range frame.Headers {
+		if frame.Headers[i].Key == key {
+			frame.Headers[i].Value = value
+			return
+		}
+	}
+	frame.AddHeader(key, value)
+}
+
+// DelHeader 删除全部同名header
+func (frame *Frame) DelHeader(key string) {
+	headers := frame.Headers[:0]
+	for _, header := range frame.Headers {
+		if header.Key != key {
+			headers = append(headers, header)
+		}
+	}
+	frame.Headers = headers
+}
+
+func (frame *Frame) String() string {
+	return fmt.Sprintf("Frame [command=%s, headers=%v, bodyLength=%d]", frame.Command, frame.Headers, len(frame.Body))
+}
+
+// escapeHeaders CONNECT、CONNECTED帧的header不转义，兼容1.0客户端
+func escapeHeaders(command string) bool {
+	return command != CMD_CONNECT && command != CMD_CONNECTED
+}
+
+// Encode 编码帧，消息体非空且未设置content-length时自动补充
+// Author: agent
+// Since: 2026/10/19
+func (frame *Frame) Encode() []byte {
+	escape := escapeHeaders(frame.Command)
+	buf := bytes.NewBuffer(make([]byte, 0, 64+len(frame.Body)))
+	buf.WriteString(frame.Command)
+	buf.WriteByte('\n')
+	hasLength := false
+	for _, header := range frame.Headers {
+		if header.Key == HDR_CONTENT_LENGTH {
+			hasLength = true
+		}
+		if escape {
+			buf.WriteString(encodeHeaderValue(header.Key))
+			buf.WriteByte(':')
+			buf.WriteString(encodeHeaderValue(header.Value))
+		} else {
+			buf.WriteString(header.Key)
+			buf.WriteByte(':')
+			buf.WriteString(header.Value)
+		}
+		buf.WriteByte('\n')
+	}
+	if !hasLength && len(frame.Body) > 0 {
+		buf.WriteString(HDR_CONTENT_LENGTH + ":" + strconv.Itoa(len(frame.Body)) + "\n")
+	}
+	buf.WriteByte('\n')
+	buf.Write(frame.Body)
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+var headerEscaper = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+
+func encodeHeaderValue(value string) string {
+	return headerEscaper.Replace(value)
+}
+
+// decodeHeaderValue 还原转义，未定义的转义序列按协议视为错误
+func decodeHeaderValue(value string) (string, error) {
+	if strings.IndexByte(value, '\\') < 0 {
+		return value, nil
+	}
+	buf := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			buf = append(buf, value[i])
+			continue
+		}
+		if i+1 == len(value) {
+			return "", fmt.Errorf("invalid escape sequence in header %q", value)
+		}
+		i++
+		switch value[i] {
+		case 'r':
+			buf = append(buf, '\r')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'c':
+			buf = append(buf, ':')
+		case '\\':
+			buf = append(buf, '\\')
+		default:
+			return "", fmt.Errorf("invalid escape sequence in header %q", value)
+		}
+	}
+	return string(buf), nil
+}
+
+// FrameReader 从连接读取帧，限制帧大小及header数
+// Author: agent
+// Since: 2026/10/19
+type FrameReader struct {
+	reader       *bufio.Reader
+	maxFrameSize int
+	maxHeaders   int
+}
+
+// NewFrameReader 创建帧读取器
+// Author: agent
+// Since: 2026/10/19
+func NewFrameReader(reader io.Reader, maxFrameSize, maxHeaders int) *FrameReader {
+	return &FrameReader{reader: bufio.NewReader(reader), maxFrameSize: maxFrameSize, maxHeaders: maxHeaders}
+}
+
+// ReadFrame 读取一帧；帧之间的换行为心跳，返回errHeartBeat
+// Author: agent
+// Since: 2026/10/19
+func (fr *FrameReader) ReadFrame() (*Frame, error) {
+	remain := fr.maxFrameSize
+	line, err := fr.readLine(&remain)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errHeartBeat
+	}
+
+	frame := &Frame{Command: line}
+	escape := escapeHeaders(frame.Command)
+	for {
+		line, err = fr.readLine(&remain)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		if len(frame.Headers) >= fr.maxHeaders {
+			return nil, fmt.Errorf("too many headers, max %d", fr.maxHeaders)
+		}
+		index := strings.IndexByte(line, ':')
+		if index <= 0 {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		key, value := line[:index], line[index+1:]
+		if escape {
+			if key, err = decodeHeaderValue(key); err != nil {
+				return nil, err
+			}
+			if value, err = decodeHeaderValue(value); err != nil {
+				return nil, err
+			}
+		}
+		frame.AddHeader(key, value)
+	}
+
+	if value, ok := frame.Header(HDR_CONTENT_LENGTH); ok {
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid content-length %q", value)
+		}
+		// 先比较再加1，避免长度为int最大值时溢出
+		if length >= remain {
+			return nil, fmt.Errorf("frame too large, max %d bytes", fr.maxFrameSize)
+		}
+		body := make([]byte, length+1)
+		if _, err := io.ReadFull(fr.reader, body); err != nil {
+			return nil, err
+		}
+		if body[length] != 0 {
+			return nil, fmt.Errorf("frame body not terminated by NULL")
+		}
+		frame.Body = body[:length]
+		return frame, nil
+	}
+
+	var body []byte
+	for {
+		b, err := fr.reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			break
+		}
+		if remain--; remain <= 0 {
+			return nil, fmt.Errorf("frame too large, max %d bytes", fr.maxFrameSize)
+		}
+		body = append(body, b)
+	}
+	frame.Body = body
+	return frame, nil
+}
+
+// readLine 读取一行，去掉结尾的\n或\r\n
+func (fr *FrameReader) readLine(remain *int) (string, error) {
+	var line []byte
+	for {
+		fragment, err := fr.reader.ReadSlice('\n')
+		if len(fragment) > *remain {
+			return "", fmt.Errorf("frame too large, max %d bytes", fr.maxFrameSize)
+		}
+		*remain -= len(fragment)
+		line = append(line, fragment...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return string(line), nil
+}
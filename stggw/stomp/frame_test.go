@@ -0,0 +1,73 @@
+package stomp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFrameEncodeDecode(t *testing.T) {
+	frame := NewFrame(CMD_SEND, HDR_DESTINATION, "/queue/a", "key:with\\colon", "line1\nline2\r")
+	frame.Body = []byte("hello\x00world")
+	data := frame.Encode()
+	if !bytes.Contains(data, []byte("key\\cwith\\\\colon:line1\\nline2\\r\n")) {
+		t.Fatalf("header not escaped: %q", data)
+	}
+	if !bytes.Contains(data, []byte("content-length:11\n")) {
+		t.Fatalf("content-length not added: %q", data)
+	}
+
+	// 帧之后的换行为心跳
+	reader := NewFrameReader(bytes.NewReader(append(append([]byte("\r\n"), data...), '\n')), 1024, 16)
+	if _, err := reader.ReadFrame(); err != errHeartBeat {
+		t.Fatalf("expect heart-beat, got %v", err)
+	}
+	decoded, err := reader.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Command != CMD_SEND || decoded.HeaderValue("key:with\\colon") != "line1\nline2\r" {
+		t.Fatalf("unexpected frame %s", decoded)
+	}
+	if string(decoded.Body) != "hello\x00world" {
+		t.Fatalf("unexpected body %q", decoded.Body)
+	}
+	if _, err := reader.ReadFrame(); err != errHeartBeat {
+		t.Fatalf("expect heart-beat, got %v", err)
+	}
+}
+
+func TestFrameDecode(t *testing.T) {
+	// 无content-length时读到NULL为止，同名header以第一个为准，CONNECT帧不还原转义
+	input := "MESSAGE\r\nfoo:1\r\nfoo:2\r\n\r\nbody\x00CONNECT\naccept-version:1.2\nlogin:a\\cb\n\n\x00"
+	reader := NewFrameReader(strings.NewReader(input), 1024, 16)
+	frame, err := reader.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.HeaderValue("foo") != "1" || string(frame.Body) != "body" {
+		t.Fatalf("unexpected frame %s", frame)
+	}
+	frame, err = reader.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.HeaderValue(HDR_LOGIN) != "a\\cb" {
+		t.Fatalf("CONNECT header should not be unescaped: %s", frame.HeaderValue(HDR_LOGIN))
+	}
+
+	invalid := []string{
+		"SEND\nfoo:a\\tb\n\n\x00",                          // 未定义的转义
+		"SEND\nfoo\n\n\x00",                                // 缺少冒号
+		"SEND\ncontent-length:3\n\nabcd\x00",               // 消息体之后不是NULL
+		"SEND\ncontent-length:-1\n\n\x00",                  // 非法长度
+		"SEND\ncontent-length:9223372036854775807\n\n\x00", // 长度加1溢出
+		"SEND\n\n" + strings.Repeat("a", 2048) + "\x00",    // 超过最大帧
+		"SEND\nh1:1\nh2:2\nh3:3\n\n\x00",                   // header过多
+	}
+	for _, data := range invalid {
+		if frame, err := NewFrameReader(strings.NewReader(data), 1024, 2).ReadFrame(); err == nil {
+			t.Fatalf("expect error for %q, got %s", data, frame)
+		}
+	}
+}
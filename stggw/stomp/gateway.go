@@ -0,0 +1,434 @@
+package stomp
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/acl"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
+)
+
+const (
+	QUEUE_PREFIX = "/queue/" // 集群消费，每条消息只投递给一个订阅
+	TOPIC_PREFIX = "/topic/" // 广播消费，每条消息投递给全部订阅
+
+	acceptRetryInterval = 100 * time.Millisecond
+)
+
+// validName topic名称规则，与客户端的校验一致
+var validName = regexp.MustCompile(process.VALID_PATTERN_STR)
+
+// StompGateway STOMP 1.2网关，供只支持STOMP的存量系统接入：
+// SEND写入destination对应的topic；SUBSCRIBE时网关为每个destination创建一个push consumer，
+// /queue/{topic}为集群消费，client、client-individual模式下客户端ACK后才消费成功，NACK或超时未确认时重新消费；
+// /topic/{topic}为广播消费，投递给本网关上该destination的全部订阅
+// Author: agent
+// Since: 2026/10/19
+type StompGateway struct {
+	config       *StompGatewayConfig
+	producer     gwutil.MessageProducer
+	newConsumer  func(dest *destination) gwutil.MessageConsumer
+	destinations map[string]*destination
+	stopping     map[string]chan struct{} // group -> 正在锁外关闭的consumer，关闭完成后关闭chan
+	sessions     map[*session]bool
+	lock         sync.Mutex
+	listener     net.Listener
+	sessionSeq   uint64
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	wg           sync.WaitGroup
+}
+
+// NewStompGateway 创建STOMP网关
+// Author: agent
+// Since: 2026/10/19
+func NewStompGateway(config *StompGatewayConfig) (*StompGateway, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	gateway := &StompGateway{
+		config:       config,
+		destinations: make(map[string]*destination),
+		stopping:     make(map[string]chan struct{}),
+		sessions:     make(map[*session]bool),
+		stopChan:     make(chan struct{}),
+	}
+
+	var rpcHook remoting.RPCHook
+	if config.AccessKey != "" {
+		rpcHook = acl.NewAclClientRPCHook(config.AccessKey, config.SecretKey)
+	}
+	producer := process.NewCustomMQProducer(config.ProducerGroup, rpcHook)
+	producer.SetNamesrvAddr(config.NamesrvAddr)
+	gateway.producer = producer
+	gateway.newConsumer = func(dest *destination) gwutil.MessageConsumer {
+		pushConsumer := process.NewCustomMQPushConsumer(dest.group, rpcHook)
+		pushConsumer.SetConsumeFromWhere(heartbeat.CONSUME_FROM_LAST_OFFSET)
+		pushConsumer.SetMessageModel(dest.model)
+		pushConsumer.SetNamesrvAddr(config.NamesrvAddr)
+		pushConsumer.Subscribe(dest.topic, "*")
+		pushConsumer.RegisterMessageListener(dest)
+		return pushConsumer
+	}
+	return gateway, nil
+}
+
+// Start 启动producer及TCP监听
+// Author: agent
+// Since: 2026/10/19
+func (gateway *StompGateway) Start() error {
+	gateway.producer.Start()
+	listener, err := net.Listen("tcp", net.JoinHostPort(gateway.config.ListenHost, strconv.Itoa(gateway.config.ListenPort)))
+	if err != nil {
+		return err
+	}
+	gateway.listener = listener
+
+	gateway.wg.Add(1)
+	go gateway.accept()
+	logger.Infof("stomp gateway %s start success. %s", gateway.Addr(), gateway.config)
+	return nil
+}
+
+// Shutdown 关闭监听及全部连接，未确认的消息重新消费，再关闭consumer、producer
+// Author: agent
+// Since: 2026/10/19
+func (gateway *StompGateway) Shutdown() {
+	gateway.stopOnce.Do(func() {
+		close(gateway.stopChan)
+		if gateway.listener != nil {
+			gateway.listener.Close()
+		}
+		gateway.lock.Lock()
+		sessions := make([]*session, 0, len(gateway.sessions))
+		for s := range gateway.sessions {
+			sessions = append(sessions, s)
+		}
+		gateway.lock.Unlock()
+		for _, s := range sessions {
+			s.close()
+		}
+		gateway.wg.Wait()
+
+		// 等待正在启动及正在关闭的consumer，正在启动的consumer启动完成后由订阅请求关闭
+		for {
+			var stops []func()
+			var waits []chan struct{}
+			gateway.lock.Lock()
+			for _, dest := range gateway.destinations {
+				if dest.starting != nil {
+					waits = append(waits, dest.starting)
+				} else {
+					stops = append(stops, gateway.retireLocked(dest))
+				}
+			}
+			for _, stopped := range gateway.stopping {
+				waits = append(waits, stopped)
+			}
+			gateway.lock.Unlock()
+			if len(stops) == 0 && len(waits) == 0 {
+				break
+			}
+			for _, stop := range stops {
+				stop()
+			}
+			for _, wait := range waits {
+				<-wait
+			}
+		}
+
+		gateway.producer.Shutdown()
+		logger.Infof("stomp gateway shutdown success")
+	})
+}
+
+// Addr 实际监听的地址，未启动时为空
+func (gateway *StompGateway) Addr() string {
+	if gateway.listener == nil {
+		return ""
+	}
+	return gateway.listener.Addr().String()
+}
+
+// SessionCount 当前的连接数
+func (gateway *StompGateway) SessionCount() int {
+	gateway.lock.Lock()
+	defer gateway.lock.Unlock()
+	return len(gateway.sessions)
+}
+
+// DestinationCount 当前有订阅的destination数，即运行中的push consumer数
+func (gateway *StompGateway) DestinationCount() int {
+	gateway.lock.Lock()
+	defer gateway.lock.Unlock()
+	return len(gateway.destinations)
+}
+
+func (gateway *StompGateway) accept() {
+	defer gateway.wg.Done()
+	for {
+		conn, err := gateway.listener.Accept()
+		if err != nil {
+			select {
+			case <-gateway.stopChan:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				logger.Warnf("stomp gateway accept err: %s", err)
+				time.Sleep(acceptRetryInterval)
+				continue
+			}
+			logger.Errorf("stomp gateway accept stopped: %s", err)
+			return
+		}
+
+		id := fmt.Sprintf("stomp-%d", atomic.AddUint64(&gateway.sessionSeq, 1))
+		s := newSession(gateway, conn, id)
+		gateway.lock.Lock()
+		select {
+		case <-gateway.stopChan:
+			gateway.lock.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		gateway.sessions[s] = true
+		gateway.wg.Add(1)
+		gateway.lock.Unlock()
+		go func() {
+			defer gateway.wg.Done()
+			defer utils.RecoveredFn()
+			s.serve()
+		}()
+	}
+}
+
+func (gateway *StompGateway) removeSession(s *session) {
+	gateway.lock.Lock()
+	delete(gateway.sessions, s)
+	gateway.lock.Unlock()
+}
+
+// send 发送SEND帧对应的消息
+func (gateway *StompGateway) send(frame *Frame) (err error) {
+	msg, err := toMessage(frame)
+	if err != nil {
+		return err
+	}
+	defer gwutil.RecoverError(&err)
+	_, err = gateway.producer.Send(msg)
+	return err
+}
+
+// subscribe 订阅加入destination，destination尚无订阅时创建并启动push consumer；
+// consumer在锁外启动，同一destination的其他订阅等待启动完成，同一group的旧consumer关闭完成后才启动新的consumer
+func (gateway *StompGateway) subscribe(sub *subscription) error {
+	gateway.lock.Lock()
+	defer gateway.lock.Unlock()
+	for {
+		select {
+		case <-gateway.stopChan:
+			return fmt.Errorf("stomp gateway is shutting down")
+		default:
+		}
+
+		if dest, ok := gateway.destinations[sub.destination]; ok {
+			if dest.starting != nil {
+				gateway.waitLocked(dest.starting)
+				continue
+			}
+			sub.dest = dest
+			dest.add(sub)
+			return nil
+		}
+
+		dest, err := newDestination(gateway, sub.destination)
+		if err != nil {
+			return err
+		}
+		if stopped, ok := gateway.stopping[dest.group]; ok {
+			gateway.waitLocked(stopped)
+			continue
+		}
+		dest.consumer = gateway.newConsumer(dest)
+		dest.starting = make(chan struct{})
+		gateway.destinations[dest.name] = dest
+		gateway.lock.Unlock()
+		err = startConsumer(dest.consumer)
+		gateway.lock.Lock()
+		close(dest.starting)
+		dest.starting = nil
+		if err != nil {
+			delete(gateway.destinations, dest.name)
+			logger.Errorf("stomp start consumer %s for %s failed: %s", dest.group, dest.name, err)
+			return fmt.Errorf("subscribe %s failed: %s", dest.name, err)
+		}
+		logger.Infof("stomp destination %s start consuming, group=%s", dest.name, dest.group)
+		select {
+		case <-gateway.stopChan:
+			// 启动期间网关已关闭
+			stop := gateway.retireLocked(dest)
+			gateway.lock.Unlock()
+			stop()
+			gateway.lock.Lock()
+			continue
+		default:
+		}
+		sub.dest = dest
+		dest.add(sub)
+		return nil
+	}
+}
+
+// unsubscribe 订阅离开destination，最后一个订阅离开时在新的goroutine中关闭push consumer：
+// 投递写出失败时由consumer自身的消费goroutine关闭连接，同步关闭会等待自身结束
+func (gateway *StompGateway) unsubscribe(sub *subscription) {
+	gateway.lock.Lock()
+	dest := sub.dest
+	if dest == nil || dest.remove(sub) > 0 || gateway.destinations[dest.name] != dest {
+		gateway.lock.Unlock()
+		return
+	}
+	stop := gateway.retireLocked(dest)
+	gateway.lock.Unlock()
+	go stop()
+}
+
+// retireLocked 将destination移出网关并登记其consumer正在关闭，调用方持有网关锁，返回在锁外关闭consumer的函数
+func (gateway *StompGateway) retireLocked(dest *destination) func() {
+	delete(gateway.destinations, dest.name)
+	stopped := make(chan struct{})
+	gateway.stopping[dest.group] = stopped
+	return func() {
+		dest.close()
+		shutdownConsumer(dest.consumer)
+		gateway.lock.Lock()
+		if gateway.stopping[dest.group] == stopped {
+			delete(gateway.stopping, dest.group)
+		}
+		gateway.lock.Unlock()
+		close(stopped)
+		logger.Infof("stomp destination %s stop consuming, group=%s", dest.name, dest.group)
+	}
+}
+
+// waitLocked 释放网关锁等待done关闭，返回前重新持有锁
+func (gateway *StompGateway) waitLocked(done chan struct{}) {
+	gateway.lock.Unlock()
+	<-done
+	gateway.lock.Lock()
+}
+
+func startConsumer(consumer gwutil.MessageConsumer) (err error) {
+	defer gwutil.RecoverError(&err)
+	consumer.Start()
+	return nil
+}
+
+func shutdownConsumer(consumer gwutil.MessageConsumer) {
+	defer gwutil.RecoverError(new(error))
+	consumer.Shutdown()
+}
+
+// parseDestination 解析destination，返回topic及消费模式
+func parseDestination(name string) (string, heartbeat.MessageModel, error) {
+	var topic string
+	var model heartbeat.MessageModel
+	switch {
+	case strings.HasPrefix(name, QUEUE_PREFIX):
+		topic, model = name[len(QUEUE_PREFIX):], heartbeat.CLUSTERING
+	case strings.HasPrefix(name, TOPIC_PREFIX):
+		topic, model = name[len(TOPIC_PREFIX):], heartbeat.BROADCASTING
+	default:
+		return "", model, fmt.Errorf("invalid destination %q, expect %s{topic} or %s{topic}", name, QUEUE_PREFIX, TOPIC_PREFIX)
+	}
+	if !validName.MatchString(topic) || len(topic) > process.CHARACTER_MAX_LENGTH {
+		return "", model, fmt.Errorf("invalid destination %q, topic must match %s", name, process.VALID_PATTERN_STR)
+	}
+	return topic, model, nil
+}
+
+// toMessage SEND帧转换为消息：tags、keys对应消息的TAGS、KEYS，content-type写入STOMP_CONTENT_TYPE，
+// 其余自定义header作为消息属性，不能使用系统属性名
+func toMessage(frame *Frame) (*message.Message, error) {
+	dest, ok := frame.Header(HDR_DESTINATION)
+	if !ok {
+		return nil, fmt.Errorf("missing destination header")
+	}
+	topic, _, err := parseDestination(dest)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame.Body) == 0 {
+		return nil, fmt.Errorf("body is empty")
+	}
+
+	msg := message.NewMessage(topic, frame.HeaderValue(HDR_TAGS), frame.Body)
+	seen := make(map[string]bool, len(frame.Headers))
+	for _, header := range frame.Headers {
+		if seen[header.Key] {
+			continue
+		}
+		seen[header.Key] = true
+		switch header.Key {
+		case HDR_DESTINATION, HDR_CONTENT_LENGTH, HDR_RECEIPT, HDR_TRANSACTION, HDR_TAGS:
+		case HDR_KEYS:
+			if keys := strings.Fields(header.Value); len(keys) > 0 {
+				msg.SetKeys(strings.Join(keys, message.KEY_SEPARATOR))
+			}
+		case HDR_CONTENT_TYPE:
+			msg.PutProperty(message.PROPERTY_STOMP_CONTENT_TYPE, header.Value)
+		default:
+			if header.Key == "" || message.SYSTEM_PROPERTIES[header.Key] || header.Key == message.PROPERTY_STOMP_CONTENT_TYPE {
+				return nil, fmt.Errorf("invalid header name %q", header.Key)
+			}
+			msg.PutProperty(header.Key, header.Value)
+		}
+	}
+	return msg, nil
+}
+
+// newMessageFrame 消息转换为MESSAGE帧，ackId为空表示auto模式
+func newMessageFrame(sub *subscription, msg *message.MessageExt, ackId string) *Frame {
+	frame := NewFrame(CMD_MESSAGE, HDR_SUBSCRIPTION, sub.id, HDR_MESSAGE_ID, msg.MsgId, HDR_DESTINATION, sub.destination)
+	if ackId != "" {
+		frame.AddHeader(HDR_ACK, ackId)
+	}
+
+	names := make([]string, 0, len(msg.Properties))
+	for name := range msg.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := msg.Properties[name]
+		switch name {
+		case message.PROPERTY_STOMP_CONTENT_TYPE:
+			frame.AddHeader(HDR_CONTENT_TYPE, value)
+		case message.PROPERTY_TAGS:
+			frame.AddHeader(HDR_TAGS, value)
+		case message.PROPERTY_KEYS:
+			frame.AddHeader(HDR_KEYS, value)
+		default:
+			if !message.SYSTEM_PROPERTIES[name] {
+				frame.AddHeader(name, value)
+			}
+		}
+	}
+	frame.Body = msg.Body
+	return frame
+}
@@ -0,0 +1,550 @@
+package stomp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgclient/consumer/listener"
+	"git.oschina.net/cloudzone/smartgo/stgclient/process"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwtest"
+	"git.oschina.net/cloudzone/smartgo/stggw/internal/gwutil"
+)
+
+// fakeBroker 内存中的broker：发送的消息交给同topic的consumer，集群消费返回RECONSUME_LATER时重新投递
+type fakeBroker struct {
+	gwtest.Broker
+	consumers []*fakeConsumer
+	blocked   map[string]chan struct{}                        // topic -> 关闭前阻塞该topic的consumer启动
+	results   map[string][]listener.ConsumeConcurrentlyStatus // msgId -> 每次消费的结果
+	lock      sync.Mutex
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{results: make(map[string][]listener.ConsumeConcurrentlyStatus), blocked: make(map[string]chan struct{})}
+}
+
+func (b *fakeBroker) Send(msg *message.Message) (*process.SendResult, error) {
+	b.lock.Lock()
+	msgExt := b.Record(msg)
+	// 持有锁登记消费，关闭的consumer不再收到消息
+	for _, c := range b.consumers {
+		if c.dest.topic == msg.Topic {
+			c.active.Add(1)
+			go c.consume(msgExt)
+		}
+	}
+	b.lock.Unlock()
+	return &process.SendResult{SendStatus: process.SEND_OK, MsgId: msgExt.MsgId}, nil
+}
+
+func (b *fakeBroker) newConsumer(dest *destination) gwutil.MessageConsumer {
+	return &fakeConsumer{broker: b, dest: dest}
+}
+
+// block 阻塞topic的consumer启动，直到返回的函数被调用
+func (b *fakeBroker) block(topic string) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	gate := make(chan struct{})
+	b.blocked[topic] = gate
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.blocked, topic)
+		close(gate)
+	}
+}
+
+func (b *fakeBroker) consumeResults(msgId string) []listener.ConsumeConcurrentlyStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]listener.ConsumeConcurrentlyStatus(nil), b.results[msgId]...)
+}
+
+func (b *fakeBroker) consumerCount() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.consumers)
+}
+
+// fakeConsumer 与push consumer一致，关闭时等待正在进行的消费结束
+type fakeConsumer struct {
+	broker *fakeBroker
+	dest   *destination
+	active sync.WaitGroup
+}
+
+func (c *fakeConsumer) Start() {
+	c.broker.lock.Lock()
+	gate := c.broker.blocked[c.dest.topic]
+	c.broker.lock.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	c.broker.lock.Lock()
+	c.broker.consumers = append(c.broker.consumers, c)
+	c.broker.lock.Unlock()
+}
+
+func (c *fakeConsumer) Shutdown() {
+	c.broker.lock.Lock()
+	for i, consumer := range c.broker.consumers {
+		if consumer == c {
+			c.broker.consumers = append(c.broker.consumers[:i], c.broker.consumers[i+1:]...)
+			break
+		}
+	}
+	c.broker.lock.Unlock()
+	c.active.Wait()
+}
+
+func (c *fakeConsumer) consume(msg *message.MessageExt) {
+	defer c.active.Done()
+	for i := 0; i < 5; i++ {
+		status := c.dest.ConsumeMessage([]*message.MessageExt{msg}, nil)
+		c.broker.lock.Lock()
+		c.broker.results[msg.MsgId] = append(c.broker.results[msg.MsgId], status)
+		c.broker.lock.Unlock()
+		if status == listener.CONSUME_SUCCESS || c.dest.model == heartbeat.BROADCASTING {
+			return
+		}
+	}
+}
+
+func newTestConfig() *StompGatewayConfig {
+	cfg := NewStompGatewayConfig()
+	cfg.ListenHost = "127.0.0.1"
+	cfg.ListenPort = 0
+	cfg.AckTimeout = 1
+	return cfg
+}
+
+func startTestGateway(t *testing.T, cfg *StompGatewayConfig, broker *fakeBroker) *StompGateway {
+	gateway, err := NewStompGateway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.producer = broker
+	gateway.newConsumer = broker.newConsumer
+	if err = gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return gateway
+}
+
+func dialTestClient(t *testing.T, gateway *StompGateway) *Client {
+	client, err := DialClient(gateway.Addr(), 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func subscribe(t *testing.T, client *Client, destination, ack string) *ClientSubscription {
+	sub, err := client.Subscribe(destination, ack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func receive(t *testing.T, sub *ClientSubscription) *Frame {
+	select {
+	case frame := <-sub.Messages():
+		if frame == nil {
+			t.Fatal("subscription closed")
+		}
+		return frame
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait message timeout")
+		return nil
+	}
+}
+
+func expectNoMessage(t *testing.T, sub *ClientSubscription, wait time.Duration) {
+	select {
+	case frame := <-sub.Messages():
+		t.Fatalf("unexpected message %s", frame)
+	case <-time.After(wait):
+	}
+}
+
+func expectResults(t *testing.T, broker *fakeBroker, msgId string, expect ...listener.ConsumeConcurrentlyStatus) {
+	gwtest.WaitFor(t, "consume results of "+msgId, func() bool { return len(broker.consumeResults(msgId)) >= len(expect) })
+	results := broker.consumeResults(msgId)
+	if fmt.Sprint(results) != fmt.Sprint(expect) {
+		t.Fatalf("expect consume results %v of %s, got %v", expect, msgId, results)
+	}
+}
+
+func TestStompSendAndAutoAck(t *testing.T) {
+	broker := newFakeBroker()
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	sub := subscribe(t, client, "/queue/Orders", ACK_AUTO)
+	err := client.Send("/queue/Orders", []byte("order-1"), HDR_TAGS, "created", HDR_KEYS, "k1 k2",
+		HDR_CONTENT_TYPE, "text/plain", "region", "east")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := broker.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("expect 1 message sent, got %d", len(sent))
+	}
+	msg := sent[0]
+	if msg.Topic != "Orders" || msg.GetTags() != "created" || msg.GetKeys() != "k1 k2" || msg.GetProperty("region") != "east" ||
+		msg.GetProperty(message.PROPERTY_STOMP_CONTENT_TYPE) != "text/plain" {
+		t.Fatalf("unexpected message %v", msg)
+	}
+	if _, ok := msg.Properties[HDR_RECEIPT]; ok {
+		t.Fatal("receipt header should not be a property")
+	}
+
+	frame := receive(t, sub)
+	if frame.HeaderValue(HDR_SUBSCRIPTION) != sub.id || frame.HeaderValue(HDR_DESTINATION) != "/queue/Orders" ||
+		frame.HeaderValue(HDR_MESSAGE_ID) != "msg-1" || frame.HeaderValue(HDR_TAGS) != "created" ||
+		frame.HeaderValue(HDR_CONTENT_TYPE) != "text/plain" || frame.HeaderValue("region") != "east" {
+		t.Fatalf("unexpected frame %s", frame)
+	}
+	if _, ok := frame.Header(HDR_ACK); ok {
+		t.Fatal("auto mode message should not carry ack header")
+	}
+	if string(frame.Body) != "order-1" {
+		t.Fatalf("unexpected body %q", frame.Body)
+	}
+	expectResults(t, broker, "msg-1", listener.CONSUME_SUCCESS)
+
+	// 系统属性不能作为自定义header
+	if err := client.Send("/queue/Orders", []byte("x"), message.PROPERTY_DELAY_TIME_LEVEL, "3"); err == nil {
+		t.Fatal("system property header should be rejected")
+	}
+}
+
+func TestStompClientIndividualAck(t *testing.T) {
+	broker := newFakeBroker()
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	sub := subscribe(t, client, "/queue/Orders", ACK_CLIENT_INDIVIDUAL)
+	if err := client.Send("/queue/Orders", []byte("order-1")); err != nil {
+		t.Fatal(err)
+	}
+	frame := receive(t, sub)
+	if frame.HeaderValue(HDR_ACK) == "" {
+		t.Fatalf("missing ack header %s", frame)
+	}
+	if err := client.Nack(frame); err != nil {
+		t.Fatal(err)
+	}
+	expectResults(t, broker, "msg-1", listener.RECONSUME_LATER)
+
+	// 重新消费后再次投递
+	frame = receive(t, sub)
+	if frame.HeaderValue(HDR_MESSAGE_ID) != "msg-1" {
+		t.Fatalf("expect redelivery of msg-1, got %s", frame)
+	}
+	if err := client.Ack(frame); err != nil {
+		t.Fatal(err)
+	}
+	expectResults(t, broker, "msg-1", listener.RECONSUME_LATER, listener.CONSUME_SUCCESS)
+
+	// 未确认超时后重新消费
+	if err := client.Send("/queue/Orders", []byte("order-2")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, sub)
+	frame = receive(t, sub)
+	if frame.HeaderValue(HDR_MESSAGE_ID) != "msg-2" {
+		t.Fatalf("expect redelivery of msg-2, got %s", frame)
+	}
+	if err := client.Ack(frame); err != nil {
+		t.Fatal(err)
+	}
+	expectResults(t, broker, "msg-2", listener.RECONSUME_LATER, listener.CONSUME_SUCCESS)
+}
+
+func TestStompClientCumulativeAck(t *testing.T) {
+	broker := newFakeBroker()
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	sub := subscribe(t, client, "/queue/Orders", ACK_CLIENT)
+	for i := 1; i <= 3; i++ {
+		if err := client.Send("/queue/Orders", []byte(fmt.Sprintf("order-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	frames := make(map[string]*Frame)
+	var order []string
+	for i := 0; i < 3; i++ {
+		frame := receive(t, sub)
+		frames[frame.HeaderValue(HDR_MESSAGE_ID)] = frame
+		order = append(order, frame.HeaderValue(HDR_MESSAGE_ID))
+	}
+
+	// 确认第二条时累积确认之前投递的消息
+	if err := client.Ack(frames[order[1]]); err != nil {
+		t.Fatal(err)
+	}
+	expectResults(t, broker, order[0], listener.CONSUME_SUCCESS)
+	expectResults(t, broker, order[1], listener.CONSUME_SUCCESS)
+	if results := broker.consumeResults(order[2]); len(results) != 0 {
+		t.Fatalf("message %s should still be pending, got %v", order[2], results)
+	}
+
+	// 断开连接后未确认的消息重新消费
+	if err := client.Disconnect(); err != nil {
+		t.Fatal(err)
+	}
+	gwtest.WaitFor(t, "pending message reconsumed", func() bool { return len(broker.consumeResults(order[2])) > 0 })
+	if results := broker.consumeResults(order[2]); results[0] != listener.RECONSUME_LATER {
+		t.Fatalf("expect %s reconsumed, got %v", order[2], results)
+	}
+	gwtest.WaitFor(t, "destination released", func() bool { return gateway.DestinationCount() == 0 && broker.consumerCount() == 0 })
+}
+
+func TestStompQueueAndTopic(t *testing.T) {
+	broker := newFakeBroker()
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client1 := dialTestClient(t, gateway)
+	defer client1.Close()
+	client2 := dialTestClient(t, gateway)
+	defer client2.Close()
+
+	queue1 := subscribe(t, client1, "/queue/Orders", ACK_AUTO)
+	queue2 := subscribe(t, client2, "/queue/Orders", ACK_AUTO)
+	topic1 := subscribe(t, client1, "/topic/Orders", ACK_AUTO)
+	topic2 := subscribe(t, client2, "/topic/Orders", ACK_AUTO)
+	if gateway.DestinationCount() != 2 {
+		t.Fatalf("expect 2 destinations, got %d", gateway.DestinationCount())
+	}
+	groups := make(map[string]heartbeat.MessageModel)
+	gateway.lock.Lock()
+	for _, dest := range gateway.destinations {
+		groups[dest.group] = dest.model
+	}
+	gateway.lock.Unlock()
+	if groups["GID_STOMP_QUEUE_Orders"] != heartbeat.CLUSTERING || groups["GID_STOMP_TOPIC_Orders"] != heartbeat.BROADCASTING {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := client1.Send("/topic/Orders", []byte(fmt.Sprintf("order-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 集群消费轮流投递，广播消费投递给全部订阅
+	for _, sub := range []*ClientSubscription{queue1, queue2} {
+		receive(t, sub)
+		receive(t, sub)
+		expectNoMessage(t, sub, 100*time.Millisecond)
+	}
+	for _, sub := range []*ClientSubscription{topic1, topic2} {
+		for i := 0; i < 4; i++ {
+			receive(t, sub)
+		}
+	}
+
+	if err := topic1.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.DestinationCount() != 2 {
+		t.Fatal("destination should be kept while other subscriptions exist")
+	}
+	if err := topic2.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.DestinationCount() != 1 || broker.consumerCount() != 1 {
+		t.Fatalf("topic destination should be released, got %d destinations", gateway.DestinationCount())
+	}
+}
+
+func TestStompTransaction(t *testing.T) {
+	broker := newFakeBroker()
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+
+	if err := client.Request(NewFrame(CMD_BEGIN, HDR_TRANSACTION, "tx1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send("/queue/Orders", []byte("order-1"), HDR_TRANSACTION, "tx1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.Messages()) != 0 {
+		t.Fatal("message should not be sent before commit")
+	}
+	if err := client.Request(NewFrame(CMD_COMMIT, HDR_TRANSACTION, "tx1")); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.Messages()) != 1 {
+		t.Fatal("message should be sent after commit")
+	}
+
+	if err := client.Request(NewFrame(CMD_BEGIN, HDR_TRANSACTION, "tx2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send("/queue/Orders", []byte("order-2"), HDR_TRANSACTION, "tx2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Request(NewFrame(CMD_ABORT, HDR_TRANSACTION, "tx2")); err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.Messages()) != 1 {
+		t.Fatal("aborted message should not be sent")
+	}
+
+	// 未开始的事务返回ERROR并关闭连接
+	if err := client.Send("/queue/Orders", []byte("order-3"), HDR_TRANSACTION, "tx3"); err == nil ||
+		!strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expect transaction not found error, got %v", err)
+	}
+	gwtest.WaitFor(t, "session closed", func() bool { return gateway.SessionCount() == 0 })
+}
+
+// TestStompConsumerLifecycle consumer在网关锁外启动及关闭，投递失败时由消费goroutine关闭连接不会死锁
+func TestStompConsumerLifecycle(t *testing.T) {
+	broker := newFakeBroker()
+	gateway := startTestGateway(t, newTestConfig(), broker)
+	defer gateway.Shutdown()
+	client1 := dialTestClient(t, gateway)
+	defer client1.Close()
+	client2 := dialTestClient(t, gateway)
+	defer client2.Close()
+
+	unblock := broker.block("Slow")
+	subscribed := make(chan error, 1)
+	go func() {
+		_, err := client1.Subscribe("/queue/Slow", ACK_AUTO)
+		subscribed <- err
+	}()
+	gwtest.WaitFor(t, "slow destination starting", func() bool { return gateway.DestinationCount() == 1 })
+	fast := make(chan error, 1)
+	go func() {
+		_, err := client2.Subscribe("/queue/Fast", ACK_AUTO)
+		fast <- err
+	}()
+	select {
+	case err := <-fast:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("subscribe of other destination is blocked by a starting consumer")
+	}
+	unblock()
+	if err := <-subscribed; err != nil {
+		t.Fatal(err)
+	}
+	gwtest.WaitFor(t, "consumers started", func() bool { return broker.consumerCount() == 2 })
+
+	// 连接的对端已关闭，消费goroutine投递失败后关闭连接，并关闭正在等待自身结束的consumer
+	conn, peer := net.Pipe()
+	s := newSession(gateway, conn, "stomp-pipe")
+	if err := s.handleSubscribe(NewFrame(CMD_SUBSCRIBE, HDR_ID, "0", HDR_DESTINATION, "/queue/Pipe", HDR_ACK, ACK_AUTO)); err != nil {
+		t.Fatal(err)
+	}
+	peer.Close()
+	if err := client1.Send("/queue/Pipe", []byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	gwtest.WaitFor(t, "pipe consumer shutdown", func() bool { return broker.consumerCount() == 2 && gateway.DestinationCount() == 2 })
+	if err := client1.Send("/queue/Slow", []byte("alive")); err != nil {
+		t.Fatalf("gateway should not be blocked: %s", err)
+	}
+}
+
+func TestStompConnect(t *testing.T) {
+	broker := newFakeBroker()
+	cfg := newTestConfig()
+	cfg.HeartBeatSend, cfg.HeartBeatReceive = 50, 100
+	gateway := startTestGateway(t, cfg, broker)
+	defer gateway.Shutdown()
+
+	if _, err := DialClient(gateway.Addr(), 3*time.Second, HDR_ACCEPT_VERSION, "1.0,1.1"); err == nil ||
+		!strings.Contains(err.Error(), "supported protocol versions") {
+		t.Fatalf("expect version error, got %v", err)
+	}
+
+	// 网关按max(50,100)发送心跳，客户端声明100毫秒发送心跳却未发送时连接关闭
+	conn, err := net.Dial("tcp", gateway.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	connect := NewFrame(CMD_STOMP, HDR_ACCEPT_VERSION, "1.1,1.2", HDR_HOST, "localhost", HDR_HEART_BEAT, "100,100")
+	if _, err := conn.Write(connect.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	reader := NewFrameReader(bufio.NewReader(conn), 4096, 16)
+	frame, err := reader.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Command != CMD_CONNECTED || frame.HeaderValue(HDR_VERSION) != PROTOCOL_VERSION || frame.HeaderValue(HDR_HEART_BEAT) != "50,100" {
+		t.Fatalf("unexpected frame %s", frame)
+	}
+	heartBeats := 0
+	start := time.Now()
+	for {
+		_, err := reader.ReadFrame()
+		if err == errHeartBeat {
+			heartBeats++
+			continue
+		}
+		if err == nil {
+			t.Fatal("unexpected frame")
+		}
+		break
+	}
+	if heartBeats == 0 {
+		t.Fatal("expect heart-beats from gateway")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("connection should be closed after heart-beat timeout, elapsed %s", elapsed)
+	}
+	gwtest.WaitFor(t, "session closed", func() bool { return gateway.SessionCount() == 0 })
+
+	// 非法destination返回ERROR
+	client := dialTestClient(t, gateway)
+	defer client.Close()
+	if _, err := client.Subscribe("/exchange/Orders", ACK_AUTO); err == nil || !strings.Contains(err.Error(), "invalid destination") {
+		t.Fatalf("expect invalid destination error, got %v", err)
+	}
+}
+
+func TestStompConfig(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.TopicGroupPrefix = cfg.QueueGroupPrefix
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("same group prefix should be rejected")
+	}
+	cfg = newTestConfig()
+	cfg.AckTimeout = 0
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("zero ackTimeout should be rejected")
+	}
+	cfg = newTestConfig()
+	cfg.AccessKey = "ak"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("accessKey without secretKey should be rejected")
+	}
+}
@@ -0,0 +1,555 @@
+package stomp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/timeutil"
+)
+
+const (
+	PROTOCOL_VERSION = "1.2"
+	SERVER_NAME      = "smartgo-stomp-gateway/" + PROTOCOL_VERSION
+
+	maxTransactionFrames = 10000       // 单个事务最多缓存的帧数
+	maxTickInterval      = time.Second // 发送心跳、检查确认超时的最大间隔
+	minTickInterval      = 10 * time.Millisecond
+	writeTimeout         = 10 * time.Second
+)
+
+// subscription 连接上的一个订阅
+type subscription struct {
+	id          string
+	destination string
+	ackMode     string
+	session     *session
+	dest        *destination
+	pending     []*delivery // 已投递未确认的消息，按投递顺序，client模式按此顺序累积确认
+	inflight    int32
+	removed     bool
+}
+
+// delivery 一次MESSAGE投递，result非空表示集群消费在等待确认结果
+type delivery struct {
+	ackId    string
+	sub      *subscription
+	deadline int64
+	result   chan bool
+}
+
+// session 一个STOMP连接：读协程处理客户端帧，tick协程发送心跳并将超时未确认的消息按NACK处理
+type session struct {
+	gateway       *StompGateway
+	conn          net.Conn
+	id            string
+	reader        *FrameReader
+	writeLock     sync.Mutex
+	lastWrite     int64
+	sendInterval  int64 // 发送心跳的间隔，单位毫秒，0表示不发送
+	readTimeout   time.Duration
+	lock          sync.Mutex
+	connected     bool
+	closed        bool
+	subscriptions map[string]*subscription
+	pending       map[string]*delivery
+	transactions  map[string][]*Frame
+	deliverySeq   uint64
+	closeChan     chan struct{}
+	closeOnce     sync.Once
+}
+
+func newSession(gateway *StompGateway, conn net.Conn, id string) *session {
+	return &session{
+		gateway:       gateway,
+		conn:          conn,
+		id:            id,
+		reader:        NewFrameReader(conn, gateway.config.MaxFrameSize, gateway.config.MaxHeaders),
+		subscriptions: make(map[string]*subscription),
+		pending:       make(map[string]*delivery),
+		transactions:  make(map[string][]*Frame),
+		closeChan:     make(chan struct{}),
+	}
+}
+
+// serve 读取并处理帧直到连接断开、DISCONNECT或出现协议错误
+func (s *session) serve() {
+	defer s.close()
+	for {
+		s.lock.Lock()
+		connected := s.connected
+		s.lock.Unlock()
+		if !connected {
+			s.conn.SetReadDeadline(time.Now().Add(time.Duration(s.gateway.config.ConnectTimeout) * time.Second))
+		} else if s.readTimeout > 0 {
+			s.conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+		} else {
+			s.conn.SetReadDeadline(time.Time{})
+		}
+
+		frame, err := s.reader.ReadFrame()
+		if err == errHeartBeat {
+			continue
+		}
+		if err != nil {
+			if _, ok := err.(net.Error); ok || err == io.EOF || err == io.ErrUnexpectedEOF {
+				logger.Infof("stomp session %s from %s closed: %s", s.id, s.conn.RemoteAddr(), err)
+				return
+			}
+			s.sendError(err.Error(), nil)
+			return
+		}
+
+		done, err := s.handle(frame)
+		if err != nil {
+			logger.Warnf("stomp session %s from %s handle %s failed: %s", s.id, s.conn.RemoteAddr(), frame.Command, err)
+			s.sendError(err.Error(), frame)
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// handle 处理一帧，返回true表示连接应关闭
+func (s *session) handle(frame *Frame) (bool, error) {
+	s.lock.Lock()
+	connected := s.connected
+	s.lock.Unlock()
+	if !connected {
+		if frame.Command != CMD_CONNECT && frame.Command != CMD_STOMP {
+			return false, fmt.Errorf("expect CONNECT frame, but got %s", frame.Command)
+		}
+		return false, s.handleConnect(frame)
+	}
+
+	var err error
+	switch frame.Command {
+	case CMD_CONNECT, CMD_STOMP:
+		err = fmt.Errorf("already connected")
+	case CMD_SEND, CMD_ACK, CMD_NACK:
+		if tx, ok := frame.Header(HDR_TRANSACTION); ok {
+			err = s.addToTransaction(tx, frame)
+		} else {
+			err = s.execute(frame)
+		}
+	case CMD_SUBSCRIBE:
+		err = s.handleSubscribe(frame)
+	case CMD_UNSUBSCRIBE:
+		err = s.handleUnsubscribe(frame)
+	case CMD_BEGIN:
+		err = s.handleBegin(frame)
+	case CMD_COMMIT:
+		err = s.handleCommit(frame)
+	case CMD_ABORT:
+		err = s.handleAbort(frame)
+	case CMD_DISCONNECT:
+		s.sendReceipt(frame)
+		return true, nil
+	default:
+		err = fmt.Errorf("unknown command %q", frame.Command)
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, s.sendReceipt(frame)
+}
+
+// handleConnect 协商版本及心跳，只支持1.2
+func (s *session) handleConnect(frame *Frame) error {
+	versions := strings.Split(frame.HeaderValue(HDR_ACCEPT_VERSION), ",")
+	supported := false
+	for _, version := range versions {
+		if strings.TrimSpace(version) == PROTOCOL_VERSION {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("supported protocol versions are %s", PROTOCOL_VERSION)
+	}
+
+	cx, cy := 0, 0
+	if value, ok := frame.Header(HDR_HEART_BEAT); ok {
+		var err error
+		if cx, cy, err = parseHeartBeat(value); err != nil {
+			return err
+		}
+	}
+	sx, sy := s.gateway.config.HeartBeatSend, s.gateway.config.HeartBeatReceive
+	if sx > 0 && cy > 0 {
+		s.sendInterval = int64(maxInt(sx, cy))
+	}
+	if cx > 0 && sy > 0 {
+		// 允许心跳有一倍的延迟
+		s.readTimeout = 2 * time.Duration(maxInt(cx, sy)) * time.Millisecond
+	}
+
+	s.lock.Lock()
+	s.connected = true
+	s.lock.Unlock()
+	connected := NewFrame(CMD_CONNECTED, HDR_VERSION, PROTOCOL_VERSION, HDR_HEART_BEAT, fmt.Sprintf("%d,%d", sx, sy),
+		HDR_SESSION, s.id, HDR_SERVER, SERVER_NAME)
+	if err := s.writeFrame(connected); err != nil {
+		return err
+	}
+
+	s.gateway.wg.Add(1)
+	go s.tick()
+	logger.Infof("stomp session %s from %s connected, login=%s, heart-beat=%d,%d", s.id, s.conn.RemoteAddr(),
+		frame.HeaderValue(HDR_LOGIN), cx, cy)
+	return nil
+}
+
+func parseHeartBeat(value string) (int, int, error) {
+	items := strings.Split(value, ",")
+	if len(items) != 2 {
+		return 0, 0, fmt.Errorf("invalid heart-beat %q", value)
+	}
+	cx, err1 := strconv.Atoi(strings.TrimSpace(items[0]))
+	cy, err2 := strconv.Atoi(strings.TrimSpace(items[1]))
+	if err1 != nil || err2 != nil || cx < 0 || cy < 0 {
+		return 0, 0, fmt.Errorf("invalid heart-beat %q", value)
+	}
+	return cx, cy, nil
+}
+
+// execute 执行SEND、ACK、NACK，事务提交时依次执行缓存的帧
+func (s *session) execute(frame *Frame) error {
+	switch frame.Command {
+	case CMD_SEND:
+		return s.gateway.send(frame)
+	case CMD_ACK:
+		return s.handleAck(frame, true)
+	case CMD_NACK:
+		return s.handleAck(frame, false)
+	}
+	return fmt.Errorf("command %s can not be executed", frame.Command)
+}
+
+func (s *session) handleSubscribe(frame *Frame) error {
+	id, ok := frame.Header(HDR_ID)
+	if !ok || id == "" {
+		return fmt.Errorf("missing id header")
+	}
+	dest, ok := frame.Header(HDR_DESTINATION)
+	if !ok {
+		return fmt.Errorf("missing destination header")
+	}
+	if _, _, err := parseDestination(dest); err != nil {
+		return err
+	}
+	ackMode := ACK_AUTO
+	if value, ok := frame.Header(HDR_ACK); ok {
+		ackMode = value
+	}
+	if ackMode != ACK_AUTO && ackMode != ACK_CLIENT && ackMode != ACK_CLIENT_INDIVIDUAL {
+		return fmt.Errorf("invalid ack mode %q", ackMode)
+	}
+
+	sub := &subscription{id: id, destination: dest, ackMode: ackMode, session: s}
+	s.lock.Lock()
+	if _, exist := s.subscriptions[id]; exist {
+		s.lock.Unlock()
+		return fmt.Errorf("subscription id %q already exists", id)
+	}
+	s.subscriptions[id] = sub
+	s.lock.Unlock()
+
+	if err := s.gateway.subscribe(sub); err != nil {
+		s.lock.Lock()
+		delete(s.subscriptions, id)
+		s.lock.Unlock()
+		return err
+	}
+	// 加入destination期间连接已关闭
+	s.lock.Lock()
+	removed := sub.removed
+	s.lock.Unlock()
+	if removed {
+		s.gateway.unsubscribe(sub)
+		return fmt.Errorf("session closed")
+	}
+	logger.Infof("stomp session %s subscribe %s, id=%s, ack=%s", s.id, dest, id, ackMode)
+	return nil
+}
+
+func (s *session) handleUnsubscribe(frame *Frame) error {
+	id, ok := frame.Header(HDR_ID)
+	if !ok {
+		return fmt.Errorf("missing id header")
+	}
+	s.lock.Lock()
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		s.lock.Unlock()
+		return fmt.Errorf("subscription id %q not found", id)
+	}
+	s.removeSubscriptionLocked(sub)
+	s.lock.Unlock()
+
+	s.gateway.unsubscribe(sub)
+	logger.Infof("stomp session %s unsubscribe %s, id=%s", s.id, sub.destination, id)
+	return nil
+}
+
+// removeSubscriptionLocked 移除订阅，未确认的消息重新消费
+func (s *session) removeSubscriptionLocked(sub *subscription) {
+	delete(s.subscriptions, sub.id)
+	sub.removed = true
+	for len(sub.pending) > 0 {
+		s.resolveLocked(sub.pending[0], false)
+	}
+}
+
+// handleAck 确认或拒绝消息，client模式累积处理该订阅在此之前投递的消息；
+// 已超时按NACK处理的确认忽略，避免迟到的ACK断开连接
+func (s *session) handleAck(frame *Frame, ok bool) error {
+	id, exist := frame.Header(HDR_ID)
+	if !exist {
+		return fmt.Errorf("missing id header")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	d, exist := s.pending[id]
+	if !exist {
+		logger.Warnf("stomp session %s %s unknown or expired ack id %s", s.id, frame.Command, id)
+		return nil
+	}
+	sub := d.sub
+	if sub.ackMode == ACK_CLIENT {
+		for len(sub.pending) > 0 {
+			first := sub.pending[0]
+			s.resolveLocked(first, ok)
+			if first == d {
+				break
+			}
+		}
+		return nil
+	}
+	s.resolveLocked(d, ok)
+	return nil
+}
+
+// resolveLocked 结束等待确认的投递，集群消费的监听据此返回消费结果
+func (s *session) resolveLocked(d *delivery, ok bool) {
+	delete(s.pending, d.ackId)
+	sub := d.sub
+	for i, p := range sub.pending {
+		if p == d {
+			sub.pending = append(sub.pending[:i], sub.pending[i+1:]...)
+			atomic.AddInt32(&sub.inflight, -1)
+			break
+		}
+	}
+	if d.result != nil {
+		d.result <- ok
+	}
+}
+
+func (s *session) handleBegin(frame *Frame) error {
+	tx, ok := frame.Header(HDR_TRANSACTION)
+	if !ok || tx == "" {
+		return fmt.Errorf("missing transaction header")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, exist := s.transactions[tx]; exist {
+		return fmt.Errorf("transaction %q already exists", tx)
+	}
+	s.transactions[tx] = make([]*Frame, 0)
+	return nil
+}
+
+func (s *session) addToTransaction(tx string, frame *Frame) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	frames, ok := s.transactions[tx]
+	if !ok {
+		return fmt.Errorf("transaction %q not found", tx)
+	}
+	if len(frames) >= maxTransactionFrames {
+		return fmt.Errorf("too many frames in transaction %q, max %d", tx, maxTransactionFrames)
+	}
+	s.transactions[tx] = append(frames, frame)
+	return nil
+}
+
+// handleCommit 依次执行事务中的帧；smartgo无跨消息的事务，执行失败时已执行的帧不回滚
+func (s *session) handleCommit(frame *Frame) error {
+	frames, err := s.takeTransaction(frame)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if err := s.execute(f); err != nil {
+			return fmt.Errorf("commit transaction %q failed: %s", frame.HeaderValue(HDR_TRANSACTION), err)
+		}
+	}
+	return nil
+}
+
+func (s *session) handleAbort(frame *Frame) error {
+	_, err := s.takeTransaction(frame)
+	return err
+}
+
+func (s *session) takeTransaction(frame *Frame) ([]*Frame, error) {
+	tx, ok := frame.Header(HDR_TRANSACTION)
+	if !ok {
+		return nil, fmt.Errorf("missing transaction header")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	frames, ok := s.transactions[tx]
+	if !ok {
+		return nil, fmt.Errorf("transaction %q not found", tx)
+	}
+	delete(s.transactions, tx)
+	return frames, nil
+}
+
+// deliver 向订阅投递消息，连接已断开或订阅已移除时返回nil
+func (s *session) deliver(sub *subscription, msg *message.MessageExt) *delivery {
+	s.lock.Lock()
+	if s.closed || sub.removed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.deliverySeq++
+	d := &delivery{sub: sub}
+	if sub.ackMode != ACK_AUTO {
+		d.ackId = s.id + "-" + strconv.FormatUint(s.deliverySeq, 10)
+		d.deadline = timeutil.CurrentTimeMillis() + int64(s.gateway.config.AckTimeout)*1000
+		if sub.dest.model == heartbeat.CLUSTERING {
+			d.result = make(chan bool, 1)
+		}
+		s.pending[d.ackId] = d
+		sub.pending = append(sub.pending, d)
+		atomic.AddInt32(&sub.inflight, 1)
+	}
+	s.lock.Unlock()
+
+	if err := s.writeFrame(newMessageFrame(sub, msg, d.ackId)); err != nil {
+		logger.Warnf("stomp session %s deliver message %s failed: %s", s.id, msg.MsgId, err)
+		s.close()
+		return nil
+	}
+	return d
+}
+
+// tick 发送心跳，并将超时未确认的消息按NACK处理
+func (s *session) tick() {
+	defer s.gateway.wg.Done()
+	interval := maxTickInterval
+	if s.sendInterval > 0 {
+		if half := time.Duration(s.sendInterval) * time.Millisecond / 2; half < interval {
+			interval = half
+		}
+		if interval < minTickInterval {
+			interval = minTickInterval
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closeChan:
+			return
+		case <-ticker.C:
+		}
+
+		now := timeutil.CurrentTimeMillis()
+		if s.sendInterval > 0 && now-atomic.LoadInt64(&s.lastWrite) >= s.sendInterval-int64(interval/time.Millisecond) {
+			if err := s.write([]byte{'\n'}); err != nil {
+				s.close()
+				return
+			}
+		}
+
+		s.lock.Lock()
+		for _, d := range s.pending {
+			if d.deadline <= now {
+				logger.Warnf("stomp session %s ack %s timeout, subscription=%s", s.id, d.ackId, d.sub.id)
+				s.resolveLocked(d, false)
+			}
+		}
+		s.lock.Unlock()
+	}
+}
+
+func (s *session) sendReceipt(frame *Frame) error {
+	receipt, ok := frame.Header(HDR_RECEIPT)
+	if !ok {
+		return nil
+	}
+	return s.writeFrame(NewFrame(CMD_RECEIPT, HDR_RECEIPT_ID, receipt))
+}
+
+// sendError 发送ERROR帧，之后连接关闭
+func (s *session) sendError(msg string, frame *Frame) {
+	errFrame := NewFrame(CMD_ERROR, HDR_MESSAGE, msg, HDR_CONTENT_TYPE, "text/plain")
+	if frame != nil {
+		if receipt, ok := frame.Header(HDR_RECEIPT); ok {
+			errFrame.AddHeader(HDR_RECEIPT_ID, receipt)
+		}
+		if frame.Command == CMD_CONNECT || frame.Command == CMD_STOMP {
+			errFrame.AddHeader(HDR_VERSION, PROTOCOL_VERSION)
+		}
+		errFrame.Body = []byte(fmt.Sprintf("%s\n%s", msg, frame))
+	}
+	s.writeFrame(errFrame)
+}
+
+func (s *session) writeFrame(frame *Frame) error {
+	return s.write(frame.Encode())
+}
+
+func (s *session) write(data []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := s.conn.Write(data); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&s.lastWrite, timeutil.CurrentTimeMillis())
+	return nil
+}
+
+// close 关闭连接，移除全部订阅并放弃未提交的事务，未确认的消息重新消费
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+		s.conn.Close()
+
+		s.lock.Lock()
+		s.closed = true
+		subs := make([]*subscription, 0, len(s.subscriptions))
+		for _, sub := range s.subscriptions {
+			subs = append(subs, sub)
+			s.removeSubscriptionLocked(sub)
+		}
+		s.transactions = make(map[string][]*Frame)
+		s.lock.Unlock()
+
+		for _, sub := range subs {
+			s.gateway.unsubscribe(sub)
+		}
+		s.gateway.removeSession(s)
+	})
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
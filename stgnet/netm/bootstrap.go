@@ -1,6 +1,7 @@
 package netm
 
 import (
+	"crypto/tls"
 	"net"
 	"strconv"
 	"sync"
@@ -22,7 +23,10 @@ type Bootstrap struct {
 	keepalive         bool
 	mu                sync.Mutex
 	running           bool
+	stopped           bool // Shutdown后不再接管外部连接
 	grRunning         bool
+	tlsConfig         *tls.Config
+	certReloader      *certReloader
 }
 
 // NewBootstrap 创建启动器
@@ -114,6 +118,9 @@ func (bootstrap *Bootstrap) Sync() {
 			continue
 		}
 
+		// 按TLS模式包装连接，握手在读协程中进行
+		conn = bootstrap.wrapServerConn(conn)
+
 		// 以客户端ip,port管理连接
 		remoteAddr := conn.RemoteAddr().String()
 		ctx := newDefaultContext(remoteAddr, conn, bootstrap)
@@ -135,6 +142,38 @@ func (bootstrap *Bootstrap) Sync() {
 	//bootstrap.Noticef("Bootstrap Exiting..")
 }
 
+// ServeConn 接管由其他监听(如WebSocket)接受的连接，与Sync接受的连接一样管理：
+// 按客户端ip,port登记、执行读取回调、连接监听通知、空闲检测及Size统计。
+// 连接需已完成握手，不再按TLS配置包装；Shutdown之后调用直接关闭连接并返回nil
+func (bootstrap *Bootstrap) ServeConn(conn net.Conn) Context {
+	remoteAddr := conn.RemoteAddr().String()
+	ctx := newDefaultContext(remoteAddr, conn, bootstrap)
+
+	// 在连接表锁内检查，保证Shutdown要么关闭该连接，要么拒绝登记
+	bootstrap.contextTableLock.Lock()
+	bootstrap.mu.Lock()
+	stopped := bootstrap.stopped
+	bootstrap.mu.Unlock()
+	if stopped {
+		bootstrap.contextTableLock.Unlock()
+		conn.Close()
+		return nil
+	}
+	bootstrap.contextTable[remoteAddr] = ctx
+	bootstrap.contextTableLock.Unlock()
+
+	bootstrap.startGoRoutine(func() {
+		bootstrap.handleConn(ctx)
+	})
+
+	// 通知连接创建
+	bootstrap.startGoRoutine(func() {
+		bootstrap.onContextConnect(ctx)
+	})
+
+	return ctx
+}
+
 // Connect 连接指定地址、端口(服务器地址管理连接)
 func (bootstrap *Bootstrap) Connect(host string, port int) error {
 	addr := net.JoinHostPort(host, strconv.Itoa(port))
@@ -212,7 +251,13 @@ func (bootstrap *Bootstrap) connect(sraddr, sladdr string) (Context, error) {
 		return nil, e
 	}
 
-	ctx := newDefaultContext(sraddr, conn, bootstrap)
+	nconn, e := bootstrap.wrapClientConn(conn, sraddr)
+	if e != nil {
+		conn.Close()
+		return nil, e
+	}
+
+	ctx := newDefaultContext(sraddr, nconn, bootstrap)
 	return ctx, nil
 }
 
@@ -252,6 +297,7 @@ func (bootstrap *Bootstrap) remove(ctx Context) {
 func (bootstrap *Bootstrap) Shutdown() {
 	bootstrap.mu.Lock()
 	bootstrap.running = false
+	bootstrap.stopped = true
 	bootstrap.mu.Unlock()
 
 	// 关闭listener
@@ -277,6 +323,11 @@ func (bootstrap *Bootstrap) Shutdown() {
 		bootstrap.checkCtxIdleTimer.Stop()
 		bootstrap.checkCtxIdleTimer = nil
 	}
+
+	// 停止证书热加载
+	if bootstrap.certReloader != nil {
+		bootstrap.certReloader.stop()
+	}
 }
 
 // Write 发送消息
@@ -402,6 +453,81 @@ func (bootstrap *Bootstrap) SetKeepAlive(keepalive bool) *Bootstrap {
 	return bootstrap
 }
 
+// SetTLSOptions 配置TLS，需在Sync或Connect之前调用。
+// 作为服务端时permissive模式同一端口同时接受TLS与明文连接，enforcing模式只接受TLS连接；
+// 作为客户端时permissive、enforcing模式均使用TLS连接
+func (bootstrap *Bootstrap) SetTLSOptions(tlsOpts *TLSOptions) error {
+	var (
+		config   *tls.Config
+		reloader *certReloader
+	)
+	if tlsOpts.Enabled() {
+		var err error
+		config, reloader, err = newTLSConfig(tlsOpts)
+		if err != nil {
+			return err
+		}
+	}
+
+	bootstrap.optsMu.Lock()
+	bootstrap.opts.TLS = tlsOpts
+	bootstrap.optsMu.Unlock()
+
+	if bootstrap.certReloader != nil {
+		bootstrap.certReloader.stop()
+	}
+	bootstrap.tlsConfig = config
+	bootstrap.certReloader = reloader
+	if reloader != nil && tlsOpts.ReloadInterval > 0 {
+		reloader.start(time.Duration(tlsOpts.ReloadInterval)*time.Second, func(err error) {
+			bootstrap.Errorf("reload tls certificate error: %v", err)
+		})
+	}
+
+	return nil
+}
+
+// 服务端按TLS模式包装连接
+func (bootstrap *Bootstrap) wrapServerConn(conn net.Conn) net.Conn {
+	opts := bootstrap.getOpts()
+	if !opts.TLS.Enabled() {
+		return conn
+	}
+
+	if opts.TLS.Permissive() {
+		return newSniffConn(conn, bootstrap.tlsConfig)
+	}
+
+	return tls.Server(conn, bootstrap.tlsConfig)
+}
+
+// 客户端按TLS模式包装连接并完成握手
+func (bootstrap *Bootstrap) wrapClientConn(conn net.Conn, addr string) (net.Conn, error) {
+	opts := bootstrap.getOpts()
+	if !opts.TLS.Enabled() {
+		return conn, nil
+	}
+
+	config := bootstrap.tlsConfig
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, errors.Wrap(err, 0)
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
 // 配置连接
 func (bootstrap *Bootstrap) setConnect(conn net.Conn) error {
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
@@ -487,17 +613,18 @@ func (bootstrap *Bootstrap) startScheduledCheckContextIdle() {
 		return
 	}
 
-	// 开启定时器检查
+	// 开启定时器检查，定时器在协程外创建，避免与Shutdown并发访问
+	interval := idle / 2
+	if interval == 0 {
+		interval = idle
+	}
+	timer := time.NewTimer(time.Duration(interval) * time.Second)
+	bootstrap.checkCtxIdleTimer = timer
 	bootstrap.startGoRoutine(func() {
-		interval := idle / 2
-		if interval == 0 {
-			interval = idle
-		}
-		bootstrap.checkCtxIdleTimer = time.NewTimer(time.Duration(interval) * time.Second)
 		for {
-			<-bootstrap.checkCtxIdleTimer.C
+			<-timer.C
 			bootstrap.scanIdleContextTable(idle)
-			bootstrap.checkCtxIdleTimer.Reset(time.Duration(interval) * time.Second)
+			timer.Reset(time.Duration(interval) * time.Second)
 		}
 	})
 }
@@ -1 +1,71 @@
 package netm
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServeConn(t *testing.T) {
+	server := NewBootstrap()
+	server.RegisterHandler(func(buffer []byte, ctx Context) {
+		ctx.Write(append([]byte(nil), buffer...))
+	})
+
+	// 由外部监听接受连接后交给bootstrap管理
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ctx := server.ServeConn(<-accepted); ctx == nil {
+		t.Fatal("expect context")
+	}
+	if server.Size() != 1 {
+		t.Fatalf("expect 1 connection, got %d", server.Size())
+	}
+
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err = conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("echo failed: %q, %v", buf[:n], err)
+	}
+
+	// Shutdown关闭接管的连接，之后接管的连接直接关闭
+	server.Shutdown()
+	if _, err = conn.Read(buf); err == nil {
+		t.Fatal("connection should be closed by shutdown")
+	}
+
+	late, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	if ctx := server.ServeConn(<-accepted); ctx != nil {
+		t.Fatal("expect nil context after shutdown")
+	}
+	if server.Size() != 0 {
+		t.Fatalf("expect 0 connection, got %d", server.Size())
+	}
+}
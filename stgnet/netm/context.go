@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,8 +27,8 @@ type DefaultContext struct {
 	addr        string
 	conn        net.Conn
 	bootstrap   *Bootstrap
-	lastOptTime time.Time
-	isClosed    bool
+	lastOptTime int64 // 最近一次读写时间(纳秒)，读写协程与空闲检查并发访问
+	isClosed    int32 // 1表示已关闭，保证关闭通知只发送一次
 }
 
 // 创建一个连接context
@@ -45,7 +46,7 @@ func (ctx *DefaultContext) Read(b []byte) (n int, e error) {
 	if e != nil {
 		ctx.onError(e)
 	}
-	ctx.lastOptTime = time.Now()
+	atomic.StoreInt64(&ctx.lastOptTime, time.Now().UnixNano())
 
 	return
 }
@@ -56,7 +57,7 @@ func (ctx *DefaultContext) Write(b []byte) (n int, e error) {
 	if e != nil {
 		ctx.onError(e)
 	}
-	ctx.lastOptTime = time.Now()
+	atomic.StoreInt64(&ctx.lastOptTime, time.Now().UnixNano())
 
 	return
 }
@@ -72,15 +73,12 @@ func (ctx *DefaultContext) WriteSerialObject(s Serializable) (n int, e error) {
 
 // Close 关闭连接
 func (ctx *DefaultContext) Close() error {
-	if ctx.isClosed {
+	if !atomic.CompareAndSwapInt32(&ctx.isClosed, 0, 1) {
 		return nil
 	}
 
 	ctx.bootstrap.onContextClose(ctx)
-	e := ctx.conn.Close()
-	ctx.isClosed = true
-
-	return e
+	return ctx.conn.Close()
 }
 
 // LocalAddr 本地连接地址
@@ -100,22 +98,23 @@ func (ctx *DefaultContext) Addr() string {
 
 // IsClosed 返回索引地址
 func (ctx *DefaultContext) IsClosed() bool {
-	return ctx.isClosed
+	return atomic.LoadInt32(&ctx.isClosed) == 1
 }
 
 // Idle 返回空闲时间
 func (ctx *DefaultContext) Idle() time.Duration {
-	return time.Since(ctx.lastOptTime)
+	return time.Since(time.Unix(0, atomic.LoadInt64(&ctx.lastOptTime)))
 }
 
 // onError 错误通知
 func (ctx *DefaultContext) onError(e error) {
-	if e == io.EOF {
-		ctx.bootstrap.onContextClose(ctx)
-	} else {
-		ctx.bootstrap.onContextError(ctx)
+	if atomic.CompareAndSwapInt32(&ctx.isClosed, 0, 1) {
+		if e == io.EOF {
+			ctx.bootstrap.onContextClose(ctx)
+		} else {
+			ctx.bootstrap.onContextError(ctx)
+		}
 	}
-	ctx.isClosed = true
 
 	ctx.conn.Close()
 }
@@ -127,5 +126,5 @@ func (ctx *DefaultContext) ToString() string {
 	}
 
 	format := "net.conn [localAddr=%s, remoteAddr=%s, addr=%s, isClosed=%t]"
-	return fmt.Sprintf(format, ctx.conn.LocalAddr().String(), ctx.conn.RemoteAddr().String(), ctx.addr, ctx.isClosed)
+	return fmt.Sprintf(format, ctx.conn.LocalAddr().String(), ctx.conn.RemoteAddr().String(), ctx.addr, ctx.IsClosed())
 }
@@ -1,7 +1,8 @@
 package netm
 
 type Options struct {
-	Host string `json:"addr"`
-	Port int    `json:"port"`
-	Idle int    `json:"idle"`
+	Host string      `json:"addr"`
+	Port int         `json:"port"`
+	Idle int         `json:"idle"`
+	TLS  *TLSOptions `json:"tls"`
 }
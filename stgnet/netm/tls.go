@@ -0,0 +1,358 @@
+package netm
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-errors/errors"
+)
+
+const (
+	TLS_MODE_DISABLED   = "disabled"   // 不启用TLS
+	TLS_MODE_PERMISSIVE = "permissive" // 混合模式：同一端口同时接受TLS与明文连接，用于迁移期间
+	TLS_MODE_ENFORCING  = "enforcing"  // 强制TLS
+
+	tlsRecordTypeHandshake = 0x16 // TLS握手记录的首字节
+	tlsHandshakeTimeout    = 10 * time.Second
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+	}
+	tlsCipherSuites = map[string]uint16{
+		"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+		"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	}
+)
+
+// TLSOptions TLS配置
+type TLSOptions struct {
+	Mode               string   `json:"mode"`               // disabled、permissive、enforcing
+	CertFile           string   `json:"certFile"`           // 证书文件(PEM)
+	KeyFile            string   `json:"keyFile"`            // 私钥文件(PEM)
+	CAFile             string   `json:"caFile"`             // CA证书，服务端用于校验客户端证书，客户端用于校验服务端证书
+	ClientAuth         bool     `json:"clientAuth"`         // 服务端是否要求并校验客户端证书
+	InsecureSkipVerify bool     `json:"insecureSkipVerify"` // 客户端是否跳过服务端证书校验
+	ServerName         string   `json:"serverName"`         // 客户端校验的服务端名称，为空时使用连接地址的host
+	MinVersion         string   `json:"minVersion"`         // 最低版本：1.0、1.1、1.2，默认1.2
+	CipherSuites       []string `json:"cipherSuites"`       // 加密套件名称，为空时使用go默认值
+	ReloadInterval     int      `json:"reloadInterval"`     // 证书热加载检查间隔，单位秒，<=0不检查
+}
+
+// LoadTLSOptions 从json文件加载TLS配置
+func LoadTLSOptions(path string) (*TLSOptions, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	opts := &TLSOptions{}
+	if err = json.Unmarshal(buf, opts); err != nil {
+		return nil, errors.Wrap(err, 0)
+	}
+
+	return opts, nil
+}
+
+// Enabled 是否启用TLS
+func (opts *TLSOptions) Enabled() bool {
+	return opts != nil && opts.Mode != "" && opts.Mode != TLS_MODE_DISABLED
+}
+
+// Permissive 是否为混合模式
+func (opts *TLSOptions) Permissive() bool {
+	return opts != nil && opts.Mode == TLS_MODE_PERMISSIVE
+}
+
+// SplitCipherSuites 将逗号分隔的加密套件名称转换为列表，便于从toml等扁平配置中读取
+func SplitCipherSuites(names string) []string {
+	var suites []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			suites = append(suites, name)
+		}
+	}
+	return suites
+}
+
+// 根据TLS配置创建tls.Config，同一配置既可用于服务端也可用于客户端
+func newTLSConfig(opts *TLSOptions) (*tls.Config, *certReloader, error) {
+	switch opts.Mode {
+	case TLS_MODE_PERMISSIVE, TLS_MODE_ENFORCING:
+	default:
+		return nil, nil, errors.Errorf("unknown tls mode: %s", opts.Mode)
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.MinVersion != "" {
+		version, ok := tlsVersions[opts.MinVersion]
+		if !ok {
+			return nil, nil, errors.Errorf("unknown tls min version: %s", opts.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	for _, name := range opts.CipherSuites {
+		suite, ok := tlsCipherSuites[name]
+		if !ok {
+			return nil, nil, errors.Errorf("unknown tls cipher suite: %s", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, suite)
+	}
+
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, 0)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, errors.Errorf("no certificate found in ca file: %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+		config.ClientCAs = pool
+	}
+
+	if opts.ClientAuth {
+		if config.ClientCAs == nil {
+			return nil, nil, errors.Errorf("tls client auth requires ca file")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	config.InsecureSkipVerify = opts.InsecureSkipVerify
+	config.ServerName = opts.ServerName
+
+	var reloader *certReloader
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		var err error
+		reloader, err = newCertReloader(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.GetCertificate = reloader.getCertificate
+		config.GetClientCertificate = reloader.getClientCertificate
+	}
+
+	return config, reloader, nil
+}
+
+// NewServerTLSConfig 按TLS配置创建服务端tls.Config，供netm之外的监听(如WebSocket)使用。
+// 配置了热加载间隔时启动证书热加载，返回的stop用于停止热加载
+func NewServerTLSConfig(opts *TLSOptions, onReloadError func(err error)) (config *tls.Config, stop func(), err error) {
+	config, reloader, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stop = func() {}
+	if reloader != nil && opts.ReloadInterval > 0 {
+		reloader.start(time.Duration(opts.ReloadInterval)*time.Second, onReloadError)
+		stop = reloader.stop
+	}
+	return config, stop, nil
+}
+
+// certReloader 证书热加载，证书或私钥文件修改后重新加载，新建连接使用新证书
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time
+	mu       sync.RWMutex
+	ticker   *time.Ticker
+	stopChan chan struct{}
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// 加载证书
+func (reloader *certReloader) reload() error {
+	modTime, err := reloader.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return errors.Wrap(err, 0)
+	}
+
+	reloader.mu.Lock()
+	reloader.cert = &cert
+	reloader.modTime = modTime
+	reloader.mu.Unlock()
+	return nil
+}
+
+// 证书与私钥文件最后修改时间
+func (reloader *certReloader) lastModified() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{reloader.certFile, reloader.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTime, errors.Wrap(err, 0)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// 检查文件是否修改，修改则重新加载。加载失败时继续使用旧证书
+func (reloader *certReloader) maybeReload() error {
+	modTime, err := reloader.lastModified()
+	if err != nil {
+		return err
+	}
+
+	reloader.mu.RLock()
+	changed := modTime.After(reloader.modTime)
+	reloader.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	return reloader.reload()
+}
+
+func (reloader *certReloader) start(interval time.Duration, onError func(err error)) {
+	reloader.ticker = time.NewTicker(interval)
+	reloader.stopChan = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-reloader.ticker.C:
+				if err := reloader.maybeReload(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-reloader.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (reloader *certReloader) stop() {
+	if reloader.ticker != nil {
+		reloader.ticker.Stop()
+		close(reloader.stopChan)
+		reloader.ticker = nil
+	}
+}
+
+func (reloader *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, nil
+}
+
+func (reloader *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, nil
+}
+
+// sniffConn 混合模式下的连接，首次读写时根据客户端发送的首字节判断是否为TLS握手，
+// 是则按TLS处理，否则按明文处理。判断在连接的读协程中进行，不阻塞accept
+type sniffConn struct {
+	net.Conn
+	config *tls.Config
+	once   sync.Once
+	conn   net.Conn
+	err    error
+	mu     sync.Mutex
+}
+
+func newSniffConn(conn net.Conn, config *tls.Config) *sniffConn {
+	return &sniffConn{Conn: conn, config: config}
+}
+
+func (c *sniffConn) sniff() {
+	reader := bufio.NewReader(c.Conn)
+	head, err := reader.Peek(1)
+	if err != nil {
+		c.err = err
+		return
+	}
+
+	var conn net.Conn = &peekedConn{Conn: c.Conn, reader: reader}
+	if head[0] == tlsRecordTypeHandshake {
+		conn = tls.Server(conn, c.config)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+}
+
+// IsTLS 是否为TLS连接，尚未判断时返回false
+func (c *sniffConn) IsTLS() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.conn.(*tls.Conn)
+	return ok
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	c.once.Do(c.sniff)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.conn.Read(b)
+}
+
+func (c *sniffConn) Write(b []byte) (int, error) {
+	c.once.Do(c.sniff)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.conn.Write(b)
+}
+
+func (c *sniffConn) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn != nil {
+		return conn.Close()
+	}
+	return c.Conn.Close()
+}
+
+// peekedConn 读取时先读取已预读的数据
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
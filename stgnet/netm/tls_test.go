@@ -0,0 +1,198 @@
+package netm
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 生成自签名证书，返回证书与私钥文件路径
+func writeSelfSignedCert(t *testing.T, dir string, serial int64) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "smartgo"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err = ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// 启动回显服务端，返回监听端口
+func startEchoServer(t *testing.T, tlsOpts *TLSOptions) (*Bootstrap, int) {
+	server := NewBootstrap()
+	if err := server.SetTLSOptions(tlsOpts); err != nil {
+		t.Fatal(err)
+	}
+	server.RegisterHandler(func(buffer []byte, ctx Context) {
+		ctx.Write(append([]byte(nil), buffer...))
+	})
+	go server.Bind("127.0.0.1", 0).Sync()
+
+	for i := 0; i < 100; i++ {
+		if server.isRunning() {
+			return server, server.getOpts().Port
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server not running")
+	return nil, 0
+}
+
+func TestTLSPermissiveMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netm_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeSelfSignedCert(t, dir, 1)
+
+	server, port := startEchoServer(t, &TLSOptions{Mode: TLS_MODE_PERMISSIVE, CertFile: certFile, KeyFile: keyFile})
+	defer server.Shutdown()
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	// 明文连接
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	if _, err = conn.Write([]byte("plain")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "plain" {
+		t.Fatalf("plain echo failed: %q, %v", buf[:n], err)
+	}
+
+	// TLS连接
+	received := make(chan string, 1)
+	client := NewBootstrap()
+	defer client.Shutdown()
+	client.RegisterHandler(func(buffer []byte, ctx Context) {
+		received <- string(buffer)
+	})
+	if err = client.SetTLSOptions(&TLSOptions{Mode: TLS_MODE_ENFORCING, CAFile: certFile}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := client.ConnectJoinAddrAndReturn(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ctx.Write([]byte("secure")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if msg != "secure" {
+			t.Fatalf("tls echo failed: %q", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("tls echo timeout")
+	}
+}
+
+func TestTLSEnforcingRejectPlain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netm_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeSelfSignedCert(t, dir, 1)
+
+	server, port := startEchoServer(t, &TLSOptions{Mode: TLS_MODE_ENFORCING, CertFile: certFile, KeyFile: keyFile})
+	defer server.Shutdown()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+	conn.Write([]byte("plain"))
+	buf := make([]byte, 16)
+	if n, err := conn.Read(buf); err == nil && string(buf[:n]) == "plain" {
+		t.Fatal("enforcing mode should reject plain connection")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netm_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeSelfSignedCert(t, dir, 1)
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := reloader.getCertificate(nil)
+
+	writeSelfSignedCert(t, dir, 2)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if err = reloader.maybeReload(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := reloader.getCertificate(nil)
+	if before == after {
+		t.Fatal("certificate not reloaded")
+	}
+
+	leaf, err := x509.ParseCertificate(after.Certificate[0])
+	if err != nil || leaf.SerialNumber.Int64() != 2 {
+		t.Fatalf("unexpected reloaded certificate: %v", err)
+	}
+}
+
+func TestNewTLSConfigInvalid(t *testing.T) {
+	cases := []*TLSOptions{
+		{Mode: "unknown"},
+		{Mode: TLS_MODE_ENFORCING, MinVersion: "0.9"},
+		{Mode: TLS_MODE_ENFORCING, CipherSuites: []string{"NOT_A_SUITE"}},
+		{Mode: TLS_MODE_ENFORCING, ClientAuth: true},
+	}
+	for _, opts := range cases {
+		if _, _, err := newTLSConfig(opts); err == nil {
+			t.Fatalf("expect error for %+v", opts)
+		}
+	}
+}
@@ -15,6 +15,12 @@ type CommandCustomHeader interface {
 	CheckFields() error
 }
 
+// ACL签名字段，由RPCHook写入ExtFields，不属于任何CommandCustomHeader
+const (
+	ACL_ACCESS_KEY = "AccessKey"
+	ACL_SIGNATURE  = "Signature"
+)
+
 // DecodeCommandCustomHeader 将extFields转为struct
 // Author: jerrylou, <gunsluo@gmail.com>
 // Since: 2017-08-24
@@ -22,6 +28,9 @@ func decodeCommandCustomHeader(extFields map[string]string, commandCustomHeader
 	structValue := reflect.ValueOf(commandCustomHeader).Elem()
 
 	for k, v := range extFields {
+		if k == ACL_ACCESS_KEY || k == ACL_SIGNATURE {
+			continue
+		}
 		err := reflectSturctSetField(structValue, firstLetterToUpper(k), v)
 		if err != nil {
 			return err
@@ -168,6 +168,11 @@ func (rc *RemotingCommand) buildHeader() []byte {
 	return buf
 }
 
+// MakeCustomHeaderToNet 将CustomHeader写入ExtFields，供编码前需要读取完整ExtFields的RPCHook(如签名)使用
+func (rc *RemotingCommand) MakeCustomHeaderToNet() {
+	rc.makeCustomHeaderToNet()
+}
+
 func (rc *RemotingCommand) makeCustomHeaderToNet() {
 	if rc.CustomHeader == nil {
 		return
@@ -0,0 +1,11 @@
+package remoting
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgnet/protocol"
+)
+
+// AccessValidator 服务端请求鉴权，在分发给处理器之前调用，返回error表示拒绝该请求
+type AccessValidator interface {
+	Validate(ctx netm.Context, request *protocol.RemotingCommand) error
+}
@@ -20,6 +20,7 @@ type BaseRemotingAchieve struct {
 	responseTable           map[int32]*ResponseFuture
 	responseTableLock       sync.RWMutex
 	rpcHook                 RPCHook
+	accessValidator         AccessValidator
 	defaultRequestProcessor RequestProcessor
 	processorTable          map[int32]RequestProcessor // 注册的处理器
 	processorTableLock      sync.RWMutex
@@ -50,6 +51,11 @@ func (ra *BaseRemotingAchieve) RegisterRPCHook(rpcHook RPCHook) {
 	ra.rpcHook = rpcHook
 }
 
+// RegisterAccessValidator 注册请求鉴权
+func (ra *BaseRemotingAchieve) RegisterAccessValidator(accessValidator AccessValidator) {
+	ra.accessValidator = accessValidator
+}
+
 func (ra *BaseRemotingAchieve) processReceived(buffer []byte, ctx netm.Context) {
 	if ctx == nil {
 		logger.Fatalf("processReceived context is nil")
@@ -130,6 +136,19 @@ func (ra *BaseRemotingAchieve) processRequestCommand(ctx netm.Context, remotingC
 		return
 	}
 
+	// 请求鉴权，不通过直接拒绝
+	if ra.accessValidator != nil {
+		if err := ra.accessValidator.Validate(ctx, remotingCommand); err != nil {
+			logger.Warnf("processRequestCommand addr[%s] code[%d] access denied: %v", ctx.Addr(), remotingCommand.Code, err)
+			if !remotingCommand.IsOnewayRPC() {
+				response := protocol.CreateResponseCommand(protocol.NO_PERMISSION, err.Error())
+				response.Opaque = remotingCommand.Opaque
+				ra.sendResponse(response, ctx)
+			}
+			return
+		}
+	}
+
 	// rpc hook before
 	if ra.rpcHook != nil {
 		ra.rpcHook.DoBeforeRequest(ctx, remotingCommand)
@@ -50,6 +50,11 @@ func (rc *DefalutRemotingClient) Shutdown() {
 	rc.isRunning = false
 }
 
+// SetTLSOptions 配置TLS，需在建立连接之前调用
+func (rc *DefalutRemotingClient) SetTLSOptions(tlsOpts *netm.TLSOptions) error {
+	return rc.bootstrap.SetTLSOptions(tlsOpts)
+}
+
 // GetNameServerAddressList return nameserver addr list
 func (rc *DefalutRemotingClient) GetNameServerAddressList() []string {
 	rc.namesrvAddrListLock.RLock()
@@ -89,3 +89,15 @@ func (rs *DefalutRemotingServer) Port() int32 {
 func (rs *DefalutRemotingServer) RegisterContextListener(contextListener netm.ContextListener) {
 	rs.bootstrap.RegisterContextListener(contextListener)
 }
+
+// ConnectionCount 当前连接数
+// Author agent
+// Since 2026/10/19
+func (rs *DefalutRemotingServer) ConnectionCount() int {
+	return rs.bootstrap.Size()
+}
+
+// SetTLSOptions 配置TLS，需在Start之前调用
+func (rs *DefalutRemotingServer) SetTLSOptions(tlsOpts *netm.TLSOptions) error {
+	return rs.bootstrap.SetTLSOptions(tlsOpts)
+}
@@ -15,6 +15,7 @@ type RemotingClient interface {
 	GetNameServerAddressList() []string
 	UpdateNameServerAddressList(addrs []string)
 	RegisterContextListener(contextListener netm.ContextListener)
+	SetTLSOptions(tlsOpts *netm.TLSOptions) error
 	Start()
 	Shutdown()
 }
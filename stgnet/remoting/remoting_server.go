@@ -13,7 +13,9 @@ type RemotingServer interface {
 	RegisterProcessor(requestCode int32, processor RequestProcessor)
 	RegisterDefaultProcessor(processor RequestProcessor)
 	RegisterRPCHook(rpcHook RPCHook)
+	RegisterAccessValidator(accessValidator AccessValidator)
 	RegisterContextListener(contextListener netm.ContextListener)
+	SetTLSOptions(tlsOpts *netm.TLSOptions) error
 	Start()
 	Shutdown()
 }
@@ -9,6 +9,7 @@ import (
 //
 // (1)ContextListener是smartnet模块封装接口
 // (2)NameSrv监测Broker的死亡：当Broker和NameSrv之间的长连接断掉之后，回调ContextListener对应的函数，从而触发NameServer的路由信息更新
+// (3)网关节点的连接断掉之后，同样删除该节点注册的设备路由
 //
 // Author: tianyuliang
 // Since: 2017/9/6
@@ -42,6 +43,7 @@ func (self *BrokerHousekeepingService) OnContextClose(ctx netm.Context) {
 	}
 	logger.Warn("BrokerHousekeepingService.OnContextClose() handle request. %s", ctx.ToString())
 	self.NamesrvController.RouteInfoManager.onChannelDestroy(ctx.RemoteAddr().String(), ctx)
+	self.NamesrvController.DeviceRouteManager.onChannelDestroy(ctx.RemoteAddr().String(), ctx)
 }
 
 // OnContextError Channel出现异常,通知Topic路由管理器，清除无效Broker
@@ -54,6 +56,7 @@ func (self *BrokerHousekeepingService) OnContextError(ctx netm.Context) {
 	}
 	logger.Warn("BrokerHousekeepingService.OnContextError() handle request. %s", ctx.ToString())
 	self.NamesrvController.RouteInfoManager.onChannelDestroy(ctx.RemoteAddr().String(), ctx)
+	self.NamesrvController.DeviceRouteManager.onChannelDestroy(ctx.RemoteAddr().String(), ctx)
 }
 
 // OnContextIdle Channe的Idle时间超时,通知Topic路由管理器，清除无效Brokers
@@ -66,4 +69,5 @@ func (self *BrokerHousekeepingService) OnContextIdle(ctx netm.Context) {
 	}
 	logger.Warn("BrokerHousekeepingService.OnContextIdle() handle request. %s", ctx.ToString())
 	self.NamesrvController.RouteInfoManager.onChannelDestroy(ctx.RemoteAddr().String(), ctx)
+	self.NamesrvController.DeviceRouteManager.onChannelDestroy(ctx.RemoteAddr().String(), ctx)
 }
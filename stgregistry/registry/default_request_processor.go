@@ -83,6 +83,14 @@ func (self *DefaultRequestProcessor) ProcessRequest(ctx netm.Context, request *p
 		return self.getHasUnitSubTopicList(ctx, request) // code=312, 获取含有单元化订阅组的 Topic 列表
 	case code.GET_HAS_UNIT_SUB_UNUNIT_TOPIC_LIST:
 		return self.getHasUnitSubUnUnitTopicList(ctx, request) // code=313, 获取含有单元化订阅组的非单元化 Topic 列表
+	case code.REGISTER_DEVICE_ROUTE:
+		return self.registerDeviceRoute(ctx, request) // code=329, 网关节点注册、注销设备连接
+	case code.UNREGISTER_DEVICE_NODE:
+		return self.unRegisterDeviceNode(ctx, request) // code=330, 网关节点下线
+	case code.QUERY_DEVICE_ROUTE:
+		return self.queryDeviceRoute(ctx, request) // code=331, 批量查询设备所在的网关节点
+	case code.GET_DEVICE_NODE_LIST:
+		return self.getDeviceNodeList(ctx, request) // code=332, 获取网关节点列表
 	default:
 		logger.Warn("invalid request. %s,  %s", ctx.ToString(), request.ToString())
 	}
@@ -543,3 +551,96 @@ func (self *DefaultRequestProcessor) getHasUnitSubUnUnitTopicList(ctx netm.Conte
 	response.Remark = ""
 	return response, nil
 }
+
+// registerDeviceRoute 网关节点注册、注销设备连接并续约节点租约
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultRequestProcessor) registerDeviceRoute(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	defer utils.RecoveredFn()
+	response := protocol.CreateDefaultResponseCommand(&namesrv.RegisterDeviceRouteResponseHeader{})
+	requestHeader := &namesrv.RegisterDeviceRouteRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		logger.Error("registerDeviceRoute.DecodeCommandCustomHeader() error: %s", err.Error())
+		return response, err
+	}
+
+	registerBody := body.NewDeviceRouteRegisterBody(nil, nil)
+	if request.Body != nil && len(request.Body) > 0 {
+		err = registerBody.CustomDecode(request.Body, registerBody)
+		if err != nil {
+			logger.Error("deviceRouteRegisterBody.Decode() err: %s", err.Error())
+			return response, err
+		}
+	}
+
+	needFullSync, deviceCount := self.NamesrvController.DeviceRouteManager.registerDevice(
+		requestHeader.NodeName,
+		requestHeader.LeaseMillis,
+		requestHeader.Reset,
+		registerBody.Register,
+		registerBody.Unregister,
+		ctx,
+	)
+
+	response.CustomHeader = &namesrv.RegisterDeviceRouteResponseHeader{NeedFullSync: needFullSync, DeviceCount: deviceCount}
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// unRegisterDeviceNode 网关节点下线，删除该节点的全部设备路由
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultRequestProcessor) unRegisterDeviceNode(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	defer utils.RecoveredFn()
+	response := protocol.CreateDefaultResponseCommand()
+	requestHeader := &namesrv.UnRegisterDeviceNodeRequestHeader{}
+	err := request.DecodeCommandCustomHeader(requestHeader)
+	if err != nil {
+		logger.Error("unRegisterDeviceNode.DecodeCommandCustomHeader() error: %s", err.Error())
+		return response, err
+	}
+
+	self.NamesrvController.DeviceRouteManager.unRegisterDeviceNode(requestHeader.NodeName)
+	response.Code = code.SUCCESS
+	response.Remark = ""
+	return response, nil
+}
+
+// queryDeviceRoute 批量查询设备所在的网关节点，未注册的设备不在结果中
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultRequestProcessor) queryDeviceRoute(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	defer utils.RecoveredFn()
+	response := protocol.CreateDefaultResponseCommand()
+	queryBody := body.NewDeviceRouteQueryBody(nil)
+	if request.Body != nil && len(request.Body) > 0 {
+		err := queryBody.CustomDecode(request.Body, queryBody)
+		if err != nil {
+			logger.Error("deviceRouteQueryBody.Decode() err: %s", err.Error())
+			return response, err
+		}
+	}
+
+	routeTable := body.NewDeviceRouteTable()
+	routeTable.Routes = self.NamesrvController.DeviceRouteManager.queryDeviceRoute(queryBody.DeviceIds)
+	response.Code = code.SUCCESS
+	response.Body = routeTable.CustomEncode(routeTable)
+	response.Remark = ""
+	return response, nil
+}
+
+// getDeviceNodeList 获取注册到Namesrv的网关节点及设备数
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultRequestProcessor) getDeviceNodeList(ctx netm.Context, request *protocol.RemotingCommand) (*protocol.RemotingCommand, error) {
+	defer utils.RecoveredFn()
+	response := protocol.CreateDefaultResponseCommand()
+	nodeList := body.NewDeviceNodeList()
+	nodeList.Nodes = self.NamesrvController.DeviceRouteManager.getDeviceNodeList()
+	response.Code = code.SUCCESS
+	response.Body = nodeList.CustomEncode(nodeList)
+	response.Remark = ""
+	return response, nil
+}
@@ -0,0 +1,293 @@
+package registry
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
+	"git.oschina.net/cloudzone/smartgo/stgregistry/logger"
+)
+
+const (
+	deviceRouteShardCount      = 256               // 设备路由分片数，分片各自加锁
+	deviceNodeDefaultLease     = 1000 * 60 * 2     // 网关节点默认租约两分钟，与Broker Channel过期时间一致
+	deviceRouteShardInitialCap = 1024              // 每个分片的初始容量
+	deviceNodeIndexMask        = uint32(1<<31 - 1) // 节点序号的取值范围
+)
+
+// deviceShard 设备路由分片：key为设备ID的64位FNV-1a哈希，value为节点序号。
+// 不保存设备ID原文，单条路由约占20字节，千万级设备只需数百MB内存；
+// 两个设备ID哈希冲突的概率约为n²/2^65，冲突时后注册的设备覆盖先注册的设备
+type deviceShard struct {
+	table map[uint64]uint32
+	lock  sync.RWMutex
+}
+
+// deviceNodeInfo 网关节点：设备路由只记录节点序号，节点重新全量同步时分配新序号，旧序号的路由由定时任务清理
+type deviceNodeInfo struct {
+	name                string
+	index               uint32
+	remoteAddr          string
+	ctx                 netm.Context
+	deviceCount         int64
+	leaseMillis         int64
+	lastUpdateTimestamp int64
+}
+
+// DeviceRouteManager 设备路由管理器：网关节点注册持有连接的设备ID，按设备ID查询所在的网关节点。
+// 节点以租约保活，租约过期或连接断开时删除节点的全部设备路由，与RouteInfoManager管理broker的方式一致
+// Author: agent
+// Since: 2026/10/19
+type DeviceRouteManager struct {
+	shards      [deviceRouteShardCount]*deviceShard
+	nodeTable   map[string]*deviceNodeInfo // nodeName[deviceNodeInfo]
+	nodeIndex   map[uint32]*deviceNodeInfo // index[deviceNodeInfo]
+	deadIndexes map[uint32]bool            // 已失效、路由尚未清理的节点序号
+	nextIndex   uint32
+	nodeLock    sync.RWMutex
+}
+
+// NewDeviceRouteManager 初始化设备路由管理器
+// Author: agent
+// Since: 2026/10/19
+func NewDeviceRouteManager() *DeviceRouteManager {
+	manager := &DeviceRouteManager{
+		nodeTable:   make(map[string]*deviceNodeInfo, 64),
+		nodeIndex:   make(map[uint32]*deviceNodeInfo, 64),
+		deadIndexes: make(map[uint32]bool),
+	}
+	for i := range manager.shards {
+		manager.shards[i] = &deviceShard{table: make(map[uint64]uint32, deviceRouteShardInitialCap)}
+	}
+	return manager
+}
+
+func deviceHash(deviceId string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(deviceId))
+	return h.Sum64()
+}
+
+func (self *DeviceRouteManager) shardOf(hash uint64) *deviceShard {
+	return self.shards[hash%deviceRouteShardCount]
+}
+
+// registerDevice 网关节点注册、注销设备并续约，reset为true时先清除该节点已有的设备路由。
+// 节点未注册(Namesrv重启或租约已过期)且不是全量同步时返回needFullSync，由节点全量同步
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) registerDevice(nodeName string, leaseMillis int64, reset bool, register, unregister []string, ctx netm.Context) (needFullSync bool, deviceCount int64) {
+	defer utils.RecoveredFn()
+	if leaseMillis <= 0 {
+		leaseMillis = deviceNodeDefaultLease
+	}
+
+	self.nodeLock.Lock()
+	node, ok := self.nodeTable[nodeName]
+	if !ok || reset {
+		if ok {
+			self.retireNode(node)
+		}
+		node = self.newNode(nodeName)
+		needFullSync = !reset
+		logger.Info("device node[%s] registered, index=%d, reset=%t", nodeName, node.index, reset)
+	}
+	node.leaseMillis = leaseMillis
+	node.lastUpdateTimestamp = stgcommon.GetCurrentTimeMillis()
+	if ctx != nil {
+		node.ctx = ctx
+		node.remoteAddr = ctx.RemoteAddr().String()
+	}
+	index := node.index
+	self.nodeLock.Unlock()
+
+	// 先注销再注册：同一批中设备断开后又重新连接时以注册为准；各节点设备数的变化最后一次性更新
+	deltas := make(map[uint32]int64)
+	for _, deviceId := range unregister {
+		hash := deviceHash(deviceId)
+		shard := self.shardOf(hash)
+		shard.lock.Lock()
+		if current, ok := shard.table[hash]; ok && current == index {
+			delete(shard.table, hash)
+			deltas[index]--
+		}
+		shard.lock.Unlock()
+	}
+	for _, deviceId := range register {
+		hash := deviceHash(deviceId)
+		shard := self.shardOf(hash)
+		shard.lock.Lock()
+		current, ok := shard.table[hash]
+		if !ok || current != index {
+			shard.table[hash] = index
+			deltas[index]++
+			if ok {
+				// 设备已迁移到当前节点
+				deltas[current]--
+			}
+		}
+		shard.lock.Unlock()
+	}
+
+	self.nodeLock.Lock()
+	for i, delta := range deltas {
+		if n, ok := self.nodeIndex[i]; ok {
+			n.deviceCount += delta
+		}
+	}
+	deviceCount = node.deviceCount
+	self.nodeLock.Unlock()
+	return needFullSync, deviceCount
+}
+
+// newNode 创建节点并分配新序号，调用方须持有nodeLock写锁
+func (self *DeviceRouteManager) newNode(nodeName string) *deviceNodeInfo {
+	for {
+		self.nextIndex = (self.nextIndex + 1) & deviceNodeIndexMask
+		if _, used := self.nodeIndex[self.nextIndex]; !used && !self.deadIndexes[self.nextIndex] && self.nextIndex != 0 {
+			break
+		}
+	}
+	node := &deviceNodeInfo{name: nodeName, index: self.nextIndex}
+	self.nodeTable[nodeName] = node
+	self.nodeIndex[node.index] = node
+	return node
+}
+
+// retireNode 节点失效，其设备路由立即不可查询，由scanNotActiveDeviceNode清理，调用方须持有nodeLock写锁。
+// 失效时可能仍有并发的注册写入旧序号，因此不论设备数是否为0都需要清理
+func (self *DeviceRouteManager) retireNode(node *deviceNodeInfo) {
+	delete(self.nodeTable, node.name)
+	delete(self.nodeIndex, node.index)
+	self.deadIndexes[node.index] = true
+}
+
+// unRegisterDeviceNode 网关节点下线，删除其全部设备路由
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) unRegisterDeviceNode(nodeName string) {
+	self.nodeLock.Lock()
+	defer self.nodeLock.Unlock()
+	if node, ok := self.nodeTable[nodeName]; ok {
+		self.retireNode(node)
+		logger.Info("device node[%s] unregistered, deviceCount=%d", nodeName, node.deviceCount)
+	}
+}
+
+// queryDeviceRoute 批量查询设备所在的网关节点，未注册或节点已失效的设备不在结果中
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) queryDeviceRoute(deviceIds []string) map[string]string {
+	routes := make(map[string]string, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		hash := deviceHash(deviceId)
+		shard := self.shardOf(hash)
+		shard.lock.RLock()
+		index, ok := shard.table[hash]
+		shard.lock.RUnlock()
+		if !ok {
+			continue
+		}
+
+		self.nodeLock.RLock()
+		node, ok := self.nodeIndex[index]
+		self.nodeLock.RUnlock()
+		if ok {
+			routes[deviceId] = node.name
+		}
+	}
+	return routes
+}
+
+// getDeviceNodeList 获取全部网关节点，按节点名称排序
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) getDeviceNodeList() []*body.DeviceNodeInfo {
+	self.nodeLock.RLock()
+	defer self.nodeLock.RUnlock()
+
+	nodes := make([]*body.DeviceNodeInfo, 0, len(self.nodeTable))
+	for _, node := range self.nodeTable {
+		nodes = append(nodes, &body.DeviceNodeInfo{
+			NodeName:            node.name,
+			RemoteAddr:          node.remoteAddr,
+			DeviceCount:         node.deviceCount,
+			LeaseMillis:         node.leaseMillis,
+			LastUpdateTimestamp: node.lastUpdateTimestamp,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeName < nodes[j].NodeName })
+	return nodes
+}
+
+// deviceCount 全部在线节点的设备数
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) deviceCount() int64 {
+	self.nodeLock.RLock()
+	defer self.nodeLock.RUnlock()
+	var count int64
+	for _, node := range self.nodeTable {
+		count += node.deviceCount
+	}
+	return count
+}
+
+// scanNotActiveDeviceNode 删除租约过期的网关节点，并清理已失效节点遗留的设备路由
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) scanNotActiveDeviceNode() {
+	defer utils.RecoveredFn()
+	currentTime := stgcommon.GetCurrentTimeMillis()
+
+	self.nodeLock.Lock()
+	for nodeName, node := range self.nodeTable {
+		if node.lastUpdateTimestamp+node.leaseMillis < currentTime {
+			self.retireNode(node)
+			format := "The device node lease expired, nodeName[%s], deviceCount[%d], lastUpdateTimestamp[%dms], leaseMillis[%dms]"
+			logger.Info(format, nodeName, node.deviceCount, node.lastUpdateTimestamp, node.leaseMillis)
+		}
+	}
+	deadIndexes := self.deadIndexes
+	if len(deadIndexes) > 0 {
+		self.deadIndexes = make(map[uint32]bool)
+	}
+	self.nodeLock.Unlock()
+
+	if len(deadIndexes) == 0 {
+		return
+	}
+
+	// 逐个分片清理，避免长时间持有锁
+	var removed int
+	for _, shard := range self.shards {
+		shard.lock.Lock()
+		for hash, index := range shard.table {
+			if deadIndexes[index] {
+				delete(shard.table, hash)
+				removed++
+			}
+		}
+		shard.lock.Unlock()
+	}
+	logger.Info("remove %d device routes of %d expired device nodes", removed, len(deadIndexes))
+}
+
+// onChannelDestroy 网关节点的连接关闭、异常或空闲超时，删除使用该连接注册的节点
+// Author: agent
+// Since: 2026/10/19
+func (self *DeviceRouteManager) onChannelDestroy(remoteAddr string, ctx netm.Context) {
+	defer utils.RecoveredFn()
+	self.nodeLock.Lock()
+	defer self.nodeLock.Unlock()
+	for nodeName, node := range self.nodeTable {
+		if node.remoteAddr == remoteAddr || (ctx != nil && node.ctx == ctx) {
+			self.retireNode(node)
+			logger.Info("the device node channel destroyed, nodeName[%s], remoteAddr[%s], deviceCount[%d]", nodeName, remoteAddr, node.deviceCount)
+		}
+	}
+}
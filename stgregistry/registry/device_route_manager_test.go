@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+)
+
+func assertRoutes(t *testing.T, manager *DeviceRouteManager, expect map[string]string, deviceIds ...string) {
+	routes := manager.queryDeviceRoute(deviceIds)
+	if len(routes) != len(expect) {
+		t.Fatalf("expect routes %v, got %v", expect, routes)
+	}
+	for deviceId, nodeName := range expect {
+		if routes[deviceId] != nodeName {
+			t.Fatalf("expect routes %v, got %v", expect, routes)
+		}
+	}
+}
+
+func TestDeviceRouteRegister(t *testing.T) {
+	manager := NewDeviceRouteManager()
+
+	// 未注册过的节点增量注册时要求全量同步，本批设备照常注册
+	needFullSync, count := manager.registerDevice("gw-1", 0, false, []string{"d1", "d2"}, nil, nil)
+	if !needFullSync || count != 2 {
+		t.Fatalf("expect needFullSync and 2 devices, got %t %d", needFullSync, count)
+	}
+	needFullSync, count = manager.registerDevice("gw-2", 0, true, []string{"d3"}, nil, nil)
+	if needFullSync || count != 1 {
+		t.Fatalf("expect 1 device without full sync, got %t %d", needFullSync, count)
+	}
+	assertRoutes(t, manager, map[string]string{"d1": "gw-1", "d2": "gw-1", "d3": "gw-2"}, "d1", "d2", "d3", "d4")
+
+	// 设备迁移到gw-2后，gw-1迟到的注销不影响新路由
+	if _, count = manager.registerDevice("gw-2", 0, false, []string{"d1"}, nil, nil); count != 2 {
+		t.Fatalf("expect 2 devices on gw-2, got %d", count)
+	}
+	if _, count = manager.registerDevice("gw-1", 0, false, nil, []string{"d1", "d2"}, nil); count != 0 {
+		t.Fatalf("expect 0 devices on gw-1, got %d", count)
+	}
+	assertRoutes(t, manager, map[string]string{"d1": "gw-2", "d3": "gw-2"}, "d1", "d2", "d3")
+	if manager.deviceCount() != 2 {
+		t.Fatalf("expect 2 devices, got %d", manager.deviceCount())
+	}
+
+	nodes := manager.getDeviceNodeList()
+	if len(nodes) != 2 || nodes[0].NodeName != "gw-1" || nodes[1].NodeName != "gw-2" || nodes[1].DeviceCount != 2 {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+}
+
+func TestDeviceRouteReset(t *testing.T) {
+	manager := NewDeviceRouteManager()
+	manager.registerDevice("gw-1", 0, true, []string{"d1", "d2"}, nil, nil)
+
+	// 全量同步的第一批先清除旧路由，未在本次同步中的设备不可查询
+	needFullSync, count := manager.registerDevice("gw-1", 0, true, []string{"d2"}, nil, nil)
+	if needFullSync || count != 1 {
+		t.Fatalf("expect 1 device without full sync, got %t %d", needFullSync, count)
+	}
+	manager.registerDevice("gw-1", 0, false, []string{"d3"}, nil, nil)
+	assertRoutes(t, manager, map[string]string{"d2": "gw-1", "d3": "gw-1"}, "d1", "d2", "d3")
+
+	// 旧序号的路由由定时任务清理
+	manager.scanNotActiveDeviceNode()
+	total := 0
+	for _, shard := range manager.shards {
+		total += len(shard.table)
+	}
+	if total != 2 {
+		t.Fatalf("expect 2 routes after purge, got %d", total)
+	}
+}
+
+func TestDeviceRouteExpire(t *testing.T) {
+	manager := NewDeviceRouteManager()
+	deviceIds := make([]string, 1000)
+	for i := range deviceIds {
+		deviceIds[i] = fmt.Sprintf("device-%d", i)
+	}
+	manager.registerDevice("gw-1", 1, true, deviceIds, nil, nil)
+	manager.registerDevice("gw-2", 0, true, []string{"d1"}, nil, nil)
+
+	// gw-1的租约为1毫秒，扫描后节点与路由全部删除
+	manager.nodeTable["gw-1"].lastUpdateTimestamp -= 10
+	manager.scanNotActiveDeviceNode()
+	assertRoutes(t, manager, map[string]string{"d1": "gw-2"}, append(deviceIds, "d1")...)
+	total := 0
+	for _, shard := range manager.shards {
+		total += len(shard.table)
+	}
+	if total != 1 || len(manager.deadIndexes) != 0 {
+		t.Fatalf("expect 1 route and no dead index, got %d %d", total, len(manager.deadIndexes))
+	}
+
+	// 节点下线后再次注册需要全量同步
+	manager.unRegisterDeviceNode("gw-2")
+	if needFullSync, _ := manager.registerDevice("gw-2", 0, false, nil, nil, nil); !needFullSync {
+		t.Fatal("expect full sync after node unregistered")
+	}
+	if len(manager.queryDeviceRoute([]string{"d1"})) != 0 {
+		t.Fatal("routes of unregistered node should not be queried")
+	}
+}
@@ -2,6 +2,8 @@ package registry
 
 import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/acl"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/metrics"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/namesrv"
 	"git.oschina.net/cloudzone/smartgo/stgnet/netm"
 	"git.oschina.net/cloudzone/smartgo/stgnet/remoting"
@@ -18,10 +20,13 @@ type DefaultNamesrvController struct {
 	NamesrvConfig             *namesrv.NamesrvConfig          // namesrv配置项
 	RemotingServer            *remoting.DefalutRemotingServer // 远程请求server端
 	RouteInfoManager          *RouteInfoManager               // topic路由管理器
+	DeviceRouteManager        *DeviceRouteManager             // 设备路由管理器
 	KvConfigManager           *KVConfigManager                // kv管理器
 	BrokerHousekeepingService netm.ContextListener            // 扫描不活跃broker
 	ScheduledExecutorService  *NamesrvControllerTask          // Namesrv定时器服务
 	RequestProcessor          remoting.RequestProcessor       // 默认请求处理器
+	MetricsServer             *metrics.Server                 // Prometheus指标服务
+	AccessValidator           *acl.PlainAccessValidator       // ACL鉴权，未开启时为nil
 }
 
 // NewNamesrvController 初始化默认的NamesrvController
@@ -29,9 +34,10 @@ type DefaultNamesrvController struct {
 // Since: 2017/9/12
 func NewNamesrvController(namesrvConfig *namesrv.NamesrvConfig, remotingServer *remoting.DefalutRemotingServer) *DefaultNamesrvController {
 	controller := &DefaultNamesrvController{
-		NamesrvConfig:    namesrvConfig,
-		RemotingServer:   remotingServer,
-		RouteInfoManager: NewRouteInfoManager(),
+		NamesrvConfig:      namesrvConfig,
+		RemotingServer:     remotingServer,
+		RouteInfoManager:   NewRouteInfoManager(),
+		DeviceRouteManager: NewDeviceRouteManager(),
 	}
 	controller.ScheduledExecutorService = NewNamesrvControllerTask(controller)
 	controller.KvConfigManager = NewKVConfigManager(controller)
@@ -53,12 +59,27 @@ func (self *DefaultNamesrvController) initialize() bool {
 	// (2)注册默认DefaultRequestProcessor，只要start启动就开始处理请求
 	self.registerProcessor()
 
+	// 配置了ACL文件，则注册请求鉴权，保护broker注册与KV配置等操作
+	if err := self.registerAccessValidator(); err != nil {
+		logger.Error("%s", err.Error())
+		return false
+	}
+
+	// 配置了TLS文件，则按配置的模式开启TLS
+	if err := self.registerTLSOptions(); err != nil {
+		logger.Error("%s", err.Error())
+		return false
+	}
+
 	// (3)注册broker连接的监听器
 	self.registerContextListener()
 
 	// (4)启动ScheduledExecutorService任务
 	self.startScheduledExecutorService()
 
+	// (5)配置了指标服务监听地址，则启动指标服务
+	self.startMetricsServer()
+
 	return true
 }
 
@@ -71,10 +92,22 @@ func (self *DefaultNamesrvController) shutdown() {
 		self.ScheduledExecutorService.scanBrokerTask.Stop()
 		logger.Info("stop scanBrokerTask ok")
 	}
+	if self.ScheduledExecutorService.scanDeviceNodeTask != nil {
+		self.ScheduledExecutorService.scanDeviceNodeTask.Stop()
+		logger.Info("stop scanDeviceNodeTask ok")
+	}
 	if self.ScheduledExecutorService.printNamesrvTask != nil {
 		self.ScheduledExecutorService.printNamesrvTask.Stop()
 		logger.Info("stop printNamesrvTask ok")
 	}
+	if self.MetricsServer != nil {
+		self.MetricsServer.Shutdown()
+		logger.Info("shutdown metricsServer successful")
+	}
+	if self.AccessValidator != nil {
+		self.AccessValidator.Shutdown()
+		logger.Info("shutdown accessValidator successful")
+	}
 	if self.RemotingServer != nil {
 		self.RemotingServer.Shutdown()
 		logger.Info("shutdown remotingServer successful")
@@ -101,6 +134,44 @@ func (self *DefaultNamesrvController) registerProcessor() error {
 	return nil
 }
 
+// registerAccessValidator 注册ACL鉴权，ACL文件变化后自动重新加载
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultNamesrvController) registerAccessValidator() error {
+	aclConfig := self.NamesrvConfig.GetAclConfig()
+	if aclConfig == "" {
+		return nil
+	}
+	accessValidator, err := acl.NewPlainAccessValidator(aclConfig)
+	if err != nil {
+		return err
+	}
+	accessValidator.Start()
+	self.AccessValidator = accessValidator
+	self.RemotingServer.RegisterAccessValidator(accessValidator)
+	logger.Info("register accessValidator ok, aclConfig=%s", aclConfig)
+	return nil
+}
+
+// registerTLSOptions 从TLS配置文件加载TLS配置，必须在RemotingServer启动之前调用
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultNamesrvController) registerTLSOptions() error {
+	tlsConfig := self.NamesrvConfig.GetTlsConfig()
+	if tlsConfig == "" {
+		return nil
+	}
+	tlsOptions, err := netm.LoadTLSOptions(tlsConfig)
+	if err != nil {
+		return err
+	}
+	if err := self.RemotingServer.SetTLSOptions(tlsOptions); err != nil {
+		return err
+	}
+	logger.Info("register tls ok, tlsConfig=%s, mode=%s", tlsConfig, tlsOptions.Mode)
+	return nil
+}
+
 // startScheduledExecutorService 启动ScheduledExecutorService任务
 // Author: tianyuliang
 // Since: 2017/9/14
@@ -110,12 +181,33 @@ func (self *DefaultNamesrvController) startScheduledExecutorService() {
 		self.ScheduledExecutorService.scanBrokerTask.Start()
 		logger.Info("start scanBrokerTask ok")
 
+		// 每隔10秒扫描租约过期的网关节点，并清理已失效节点的设备路由
+		self.ScheduledExecutorService.scanDeviceNodeTask.Start()
+		logger.Info("start scanDeviceNodeTask ok")
+
 		// 启动(延迟1分钟执行)第二个定时任务：每隔10分钟打印NameServer全局配置,即KVConfigManager.configTable变量的内容
 		self.ScheduledExecutorService.printNamesrvTask.Start()
 		logger.Info("start printNamesrvTask ok")
 	}()
 }
 
+// startMetricsServer 启动Prometheus指标服务
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultNamesrvController) startMetricsServer() {
+	metricsAddr := self.NamesrvConfig.GetMetricsAddr()
+	if metricsAddr == "" {
+		return
+	}
+	self.MetricsServer = metrics.NewServer(metricsAddr, NewNamesrvMetricsCollector(self))
+	if err := self.MetricsServer.Start(); err != nil {
+		logger.Error("start metricsServer err: %s", err.Error())
+		self.MetricsServer = nil
+		return
+	}
+	logger.Info("start metricsServer ok, listen %s", self.MetricsServer.Addr())
+}
+
 // registerContextListener 注册监听器，监听broker对应的net.conn连接的Close()、Idel()、Error()等状态变化
 // Author: tianyuliang
 // Since: 2017/9/18
@@ -6,15 +6,17 @@ import (
 )
 
 type NamesrvControllerTask struct {
-	NamesrvController *DefaultNamesrvController
-	scanBrokerTask    *timeutil.Ticker // 扫描2分钟不活跃broker的定时器
-	printNamesrvTask  *timeutil.Ticker // 周期性打印namesrv数据的定时器
+	NamesrvController  *DefaultNamesrvController
+	scanBrokerTask     *timeutil.Ticker // 扫描2分钟不活跃broker的定时器
+	scanDeviceNodeTask *timeutil.Ticker // 扫描租约过期网关节点的定时器
+	printNamesrvTask   *timeutil.Ticker // 周期性打印namesrv数据的定时器
 }
 
 func NewNamesrvControllerTask(controller *DefaultNamesrvController) *NamesrvControllerTask {
 	controllerTask := &NamesrvControllerTask{}
 	controllerTask.NamesrvController = controller
 	controllerTask.newScanBrokerTask()
+	controllerTask.newScanDeviceNodeTask()
 	controllerTask.newPrintNamesrvTask()
 	return controllerTask
 }
@@ -28,6 +30,15 @@ func (self *NamesrvControllerTask) newScanBrokerTask() {
 	})
 }
 
+// newScanDeviceNodeTask 初始化ScanDeviceNodeTask任务
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvControllerTask) newScanDeviceNodeTask() {
+	self.scanDeviceNodeTask = timeutil.NewTicker(false, 5*time.Second, 10*time.Second, func() {
+		self.NamesrvController.DeviceRouteManager.scanNotActiveDeviceNode()
+	})
+}
+
 // newPrintNamesrvTask 初始化PrintNamesrvTask任务
 // Author: tianyuliang
 // Since: 2017/10/11
@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/metrics"
+	"strconv"
+)
+
+// NamesrvMetricsCollector namesrv对外暴露的Prometheus指标
+//
+// 指标名称与label一经发布不再修改：
+//
+//	smartgo_namesrv_topics                                                       gauge  路由表中的topic数量
+//	smartgo_namesrv_topic_queue_datas{topic}                                     gauge  topic在各个broker上的QueueData数量
+//	smartgo_namesrv_clusters                                                     gauge  集群数量
+//	smartgo_namesrv_cluster_brokers{cluster}                                     gauge  集群内的brokerName数量
+//	smartgo_namesrv_broker_live{cluster,broker,broker_id,broker_addr}            gauge  broker是否存活(1存活，0已过期)
+//	smartgo_namesrv_broker_last_update_seconds{cluster,broker,broker_id,broker_addr} gauge 距离broker最近一次注册的秒数
+//	smartgo_namesrv_device_node_devices{node}                                    gauge  网关节点注册的设备数
+//
+// Author: agent
+// Since: 2026/10/19
+type NamesrvMetricsCollector struct {
+	controller *DefaultNamesrvController
+}
+
+// NewNamesrvMetricsCollector 初始化namesrv指标收集器
+// Author: agent
+// Since: 2026/10/19
+func NewNamesrvMetricsCollector(controller *DefaultNamesrvController) *NamesrvMetricsCollector {
+	return &NamesrvMetricsCollector{controller: controller}
+}
+
+// Collect 收集namesrv路由表与broker存活指标
+// Author: agent
+// Since: 2026/10/19
+func (self *NamesrvMetricsCollector) Collect() []*metrics.Family {
+	routeInfoManager := self.controller.RouteInfoManager
+	routeInfoManager.ReadWriteLock.RLock()
+	defer routeInfoManager.ReadWriteLock.RUnlock()
+
+	topics := metrics.NewFamily("smartgo_namesrv_topics", "Topics in the route table.", metrics.GAUGE)
+	topics.Add(float64(len(routeInfoManager.TopicQueueTable)))
+
+	queueDatas := metrics.NewFamily("smartgo_namesrv_topic_queue_datas", "Brokers serving the topic.", metrics.GAUGE)
+	for topic, queueDataList := range routeInfoManager.TopicQueueTable {
+		queueDatas.Add(float64(len(queueDataList)), "topic", topic)
+	}
+
+	clusters := metrics.NewFamily("smartgo_namesrv_clusters", "Clusters in the route table.", metrics.GAUGE)
+	clusters.Add(float64(len(routeInfoManager.ClusterAddrTable)))
+
+	clusterBrokers := metrics.NewFamily("smartgo_namesrv_cluster_brokers", "Broker names registered in the cluster.", metrics.GAUGE)
+	brokerClusters := make(map[string]string, len(routeInfoManager.BrokerAddrTable))
+	for clusterName, brokerNameSet := range routeInfoManager.ClusterAddrTable {
+		if brokerNameSet == nil {
+			continue
+		}
+		clusterBrokers.Add(float64(brokerNameSet.Cardinality()), "cluster", clusterName)
+		for value := range brokerNameSet.Iter() {
+			if brokerName, ok := value.(string); ok {
+				brokerClusters[brokerName] = clusterName
+			}
+		}
+	}
+
+	live := metrics.NewFamily("smartgo_namesrv_broker_live", "Whether the broker registration is alive (1) or expired (0).", metrics.GAUGE)
+	lastUpdate := metrics.NewFamily("smartgo_namesrv_broker_last_update_seconds", "Seconds since the broker last registered.", metrics.GAUGE)
+	now := stgcommon.GetCurrentTimeMillis()
+	for brokerName, brokerData := range routeInfoManager.BrokerAddrTable {
+		if brokerData == nil {
+			continue
+		}
+		for brokerId, brokerAddr := range brokerData.BrokerAddrs {
+			labels := []string{"cluster", brokerClusters[brokerName], "broker", brokerName, "broker_id", strconv.Itoa(brokerId), "broker_addr", brokerAddr}
+			brokerLiveInfo, ok := routeInfoManager.BrokerLiveTable[brokerAddr]
+			if !ok || brokerLiveInfo == nil {
+				live.Add(0, labels...)
+				continue
+			}
+
+			elapsed := now - brokerLiveInfo.LastUpdateTimestamp
+			if elapsed > brokerChannelExpiredTime {
+				live.Add(0, labels...)
+			} else {
+				live.Add(1, labels...)
+			}
+			lastUpdate.Add(float64(elapsed)/1000, labels...)
+		}
+	}
+
+	deviceNodes := metrics.NewFamily("smartgo_namesrv_device_node_devices", "Devices registered by the gateway node.", metrics.GAUGE)
+	for _, node := range self.controller.DeviceRouteManager.getDeviceNodeList() {
+		deviceNodes.Add(float64(node.DeviceCount), "node", node.NodeName)
+	}
+
+	return []*metrics.Family{topics, queueDatas, clusters, clusterBrokers, live, lastUpdate, deviceNodes}
+}
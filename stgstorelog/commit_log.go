@@ -11,6 +11,7 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/sysflag"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
 )
@@ -63,9 +64,18 @@ func (self *CommitLog) Load() bool {
 }
 
 func (self *CommitLog) putMessage(msg *MessageExtBrokerInner) *PutMessageResult {
+	beginTime := time.Now()
 	msg.StoreTimestamp = time.Now().UnixNano() / 1000000
 	msg.BodyCRC, _ = stgcommon.Crc32(msg.Body)
 
+	// 定时消息处理：非事务消息或事务提交消息，先写入SCHEDULE_TOPIC，到期后再投递到真实的topic
+	tranType := sysflag.GetTransactionValue(int(msg.SysFlag))
+	if sysflag.TransactionNotType == tranType || sysflag.TransactionCommitType == tranType {
+		if self.DefaultMessageStore.ScheduleMessageService != nil {
+			self.DefaultMessageStore.ScheduleMessageService.wrapScheduleMessage(msg)
+		}
+	}
+
 	// TODO 事务消息处理
 	self.mutex.Lock()
 	beginLockTimestamp := time.Now().UnixNano() / 1000000
@@ -160,6 +170,10 @@ func (self *CommitLog) putMessage(msg *MessageExtBrokerInner) *PutMessageResult
 		// TODO
 	}
 
+	if self.DefaultMessageStore.BrokerStatsManager != nil {
+		self.DefaultMessageStore.BrokerStatsManager.RecordCommitLogPutLatency(msg.Topic, int64(time.Since(beginTime)/time.Microsecond))
+	}
+
 	return putMessageResult
 }
 
@@ -357,7 +371,11 @@ func (self *CommitLog) checkMessageAndReturnSize(mappedByteBuffer *MappedByteBuf
 			}
 
 			if delayLevel > 0 {
-				tagsCode = self.DefaultMessageStore.ScheduleMessageService.computeDeliverTimestamp(delayLevel, storeTimestamp)
+				startDeliverTime, _ := strconv.ParseInt(propertiesMap[message.PROPERTY_START_DELIVER_TIME], 10, 64)
+				if startDeliverTime <= storeTimestamp {
+					startDeliverTime = 0
+				}
+				tagsCode = self.DefaultMessageStore.ScheduleMessageService.computeScheduleDeliverTimestamp(delayLevel, storeTimestamp, startDeliverTime)
 			}
 		}
 	}
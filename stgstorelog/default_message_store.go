@@ -13,7 +13,9 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -382,6 +384,33 @@ func (self *DefaultMessageStore) Shutdown() {
 	}
 }
 
+// FlushAll 等待分发队列处理完毕后，把CommitLog、ConsumeQueue以及StoreCheckpoint全部刷盘；
+// timeoutMills内分发未完成或CommitLog刷盘未完成时返回false
+// Author agent
+// Since 2026/10/19
+func (self *DefaultMessageStore) FlushAll(timeoutMills int64) bool {
+	deadline := timeutil.CurrentTimeMillis() + timeoutMills
+	for len(self.DispatchMessageService.requestsChan) > 0 && timeutil.CurrentTimeMillis() < deadline {
+		time.Sleep(10 * time.Millisecond)
+	}
+	dispatched := len(self.DispatchMessageService.requestsChan) == 0
+
+	committed := false
+	for i := 0; i < FlushRetryTimesOver && !committed; i++ {
+		committed = self.CommitLog.MapedFileQueue.commit(0)
+	}
+
+	if self.FlushConsumeQueueService != nil {
+		// 重试次数为RetryTimesOver时会全量刷盘并刷新StoreCheckpoint
+		self.FlushConsumeQueueService.doFlush(RetryTimesOver)
+	} else {
+		self.StoreCheckpoint.flush()
+	}
+
+	logger.Infof("message store flush all, dispatched=%t, committed=%t, maxPhyOffset=%d", dispatched, committed, self.GetMaxPhyOffset())
+	return dispatched && committed
+}
+
 func (self *DefaultMessageStore) Destroy() {
 	self.destroyLogics()
 	self.CommitLog.destroy()
@@ -447,20 +476,62 @@ func (self *DefaultMessageStore) PutMessage(msg *MessageExtBrokerInner) *PutMess
 	return result
 }
 
+// QueryMessage 通过消息key查询消息，哈希冲突的消息会被过滤掉
+// Author agent
+// Since 2026/10/19
 func (self *DefaultMessageStore) QueryMessage(topic string, key string, maxNum int32, begin int64, end int64) *QueryMessageResult {
 	queryMessageResult := new(QueryMessageResult)
 
 	lastQueryMsgTime := end
 	for i := 0; i < 3; i++ {
 		queryOffsetResult := self.IndexService.queryOffset(topic, key, maxNum, begin, lastQueryMsgTime)
-		if queryOffsetResult != nil {
+		if queryOffsetResult == nil || len(queryOffsetResult.PhyOffsets) == 0 {
+			break
+		}
+
+		queryMessageResult.IndexLastUpdatePhyoffset = queryOffsetResult.IndexLastUpdatePhyoffset
+		queryMessageResult.IndexLastUpdateTimestamp = queryOffsetResult.IndexLastUpdateTimestamp
+
+		phyOffsets := queryOffsetResult.PhyOffsets
+		sort.Slice(phyOffsets, func(i, j int) bool { return phyOffsets[i] < phyOffsets[j] })
+		for m, offset := range phyOffsets {
+			if m > 0 && offset == phyOffsets[m-1] {
+				continue
+			}
+			msg := self.LookMessageByOffset(offset)
+			if msg == nil {
+				continue
+			}
+			if 0 == m {
+				lastQueryMsgTime = msg.StoreTimestamp
+			}
+			if msg.Topic != topic || !containsKey(msg.GetKeys(), key) {
+				continue
+			}
+
+			if result := self.SelectOneMessageByOffset(offset); result != nil {
+				queryMessageResult.AddMessage(result)
+			}
+		}
 
+		if queryMessageResult.BufferTotalSize > 0 || lastQueryMsgTime < begin {
+			break
 		}
 	}
 
 	return queryMessageResult
 }
 
+// containsKey 消息的keys中是否包含key
+func containsKey(keys, key string) bool {
+	for _, k := range strings.Split(keys, message.KEY_SEPARATOR) {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 func (self *DefaultMessageStore) GetMessage(group string, topic string, queueId int32, offset int64, maxMsgNums int32, subscriptionData *heartbeat.SubscriptionData) *GetMessageResult {
 	if self.ShutdownFlag {
 		logger.Warn("message store has shutdown, so getMessage is forbidden")
@@ -549,6 +620,15 @@ func (self *DefaultMessageStore) GetMessage(group string, topic string, queueId
 					if self.MessageFilter.IsMessageMatched(subscriptionData, tagsCode) {
 						selectResult := self.CommitLog.getMessage(offsetPy, sizePy)
 
+						// 按消息属性过滤，未匹配的消息跳过，nextBeginOffset仍会越过该消息
+						if selectResult != nil && !self.MessageFilter.IsMatchedByCommitLog(subscriptionData, selectResult.MappedByteBuffer.Bytes()) {
+							selectResult.Release()
+							if getResult.BufferTotalSize == 0 {
+								status = NO_MATCHED_MESSAGE
+							}
+							continue
+						}
+
 						if selectResult != nil {
 							atomic.AddInt64(&self.StoreStatsService.getMessageTransferedMsgCount, 1)
 							getResult.addMessage(selectResult)
@@ -556,7 +636,7 @@ func (self *DefaultMessageStore) GetMessage(group string, topic string, queueId
 							nextPhyFileStartOffset = int64(LongMinValue)
 
 							// 统计读取磁盘落后情况
-							if diskFallRecorded {
+							if !diskFallRecorded {
 								diskFallRecorded = true
 								fallBehind := consumeQueue.maxPhysicOffset - offsetPy
 								self.BrokerStatsManager.RecordDiskFallBehind(group, topic, queueId, fallBehind)
@@ -741,6 +821,22 @@ func (self *DefaultMessageStore) GetMinOffsetInQueue(topic string, queueId int32
 	return -1
 }
 
+// GetCommitLogOffsetInQueue 获取消费队列中offset位置的消息对应的物理偏移量，找不到返回-1
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultMessageStore) GetCommitLogOffsetInQueue(topic string, queueId int32, consumeQueueOffset int64) int64 {
+	consumeQueue := self.findConsumeQueue(topic, queueId)
+	if consumeQueue != nil {
+		bufferConsumeQueue := consumeQueue.getIndexBuffer(consumeQueueOffset)
+		if bufferConsumeQueue != nil {
+			defer bufferConsumeQueue.Release()
+			return bufferConsumeQueue.MappedByteBuffer.ReadInt64()
+		}
+	}
+
+	return -1
+}
+
 // CheckInDiskByConsumeOffset 判断消息是否在磁盘
 // Author: zhoufei
 // Since: 2017/9/20
@@ -4,8 +4,9 @@ import (
 	"time"
 
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
-	"math"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils/byteutil"
 	"hash/fnv"
+	"math"
 )
 
 var (
@@ -36,7 +37,9 @@ func NewIndexFile(fileName string, hashSlotNum, indexNum int32, endPhyOffset, en
 	indexFile.hashSlotNum = hashSlotNum
 	indexFile.indexNum = indexNum
 
-	indexFile.indexHeader = NewIndexHeader(NewMappedByteBuffer(make([]byte, indexFile.mapedFile.fileSize)))
+	// 索引头与索引数据保存在同一个文件中，重启后可以从文件头恢复
+	indexFile.indexHeader = NewIndexHeader(NewMappedByteBuffer(indexFile.mappedByteBuffer.MMapBuf))
+	indexFile.indexHeader.load()
 
 	if endPhyOffset > 0 {
 		indexFile.indexHeader.setBeginPhyOffset(endPhyOffset)
@@ -111,22 +114,20 @@ func (self *IndexFile) putKey(key string, phyOffset int64, storeTimestamp int64)
 		self.mappedByteBuffer.WriteInt32(int32(timeDiff))
 		self.mappedByteBuffer.WriteInt32(slotValue)
 
-		// 更新哈希槽
-		currentWritePos := self.mappedByteBuffer.WritePos
-		self.mappedByteBuffer.WritePos = int(absIndexPos)
+		// 更新哈希槽，指向最新写入的索引
+		self.mappedByteBuffer.WritePos = int(absSlotPos)
 		self.mappedByteBuffer.WriteInt32(self.indexHeader.indexCount)
-		self.mappedByteBuffer.WritePos = currentWritePos
 
 		// 第一次写入
 		if self.indexHeader.indexCount <= 1 {
-			self.indexHeader.beginPhyOffset = phyOffset
-			self.indexHeader.beginTimestamp = storeTimestamp
+			self.indexHeader.setBeginPhyOffset(phyOffset)
+			self.indexHeader.setBeginTimestamp(storeTimestamp)
 		}
 
 		self.indexHeader.incHashSlotCount()
 		self.indexHeader.incIndexCount()
-		self.indexHeader.endPhyOffset = phyOffset
-		self.indexHeader.endTimestamp = storeTimestamp
+		self.indexHeader.setEndPhyOffset(phyOffset)
+		self.indexHeader.setEndTimestamp(storeTimestamp)
 
 		return true
 	}
@@ -134,6 +135,75 @@ func (self *IndexFile) putKey(key string, phyOffset int64, storeTimestamp int64)
 	return false
 }
 
+// isTimeMatched 索引文件的时间范围与查询的时间范围是否有交集
+// Author agent
+// Since 2026/10/19
+func (self *IndexFile) isTimeMatched(begin, end int64) bool {
+	beginTimestamp := self.indexHeader.getBeginTimestamp()
+	endTimestamp := self.indexHeader.getEndTimestamp()
+	if begin < beginTimestamp && end > endTimestamp {
+		return true
+	}
+	if begin >= beginTimestamp && begin <= endTimestamp {
+		return true
+	}
+	return end >= beginTimestamp && end <= endTimestamp
+}
+
+// selectPhyOffset 沿着哈希槽上的索引链表，查找key在时间范围内对应的消息物理偏移量
+// Author agent
+// Since 2026/10/19
+func (self *IndexFile) selectPhyOffset(phyOffsets []int64, key string, maxNum int32, begin, end int64) []int64 {
+	if !self.mapedFile.hold() {
+		return phyOffsets
+	}
+	defer self.mapedFile.release()
+
+	indexCount := self.indexHeader.getIndexCount()
+	beginTimestamp := self.indexHeader.getBeginTimestamp()
+	keyHash := self.indexKeyHashMethod(key)
+	slotPos := keyHash % self.hashSlotNum
+	absSlotPos := INDEX_HEADER_SIZE + slotPos*HASH_SLOT_SIZE
+
+	slotValue := self.readInt32(absSlotPos)
+	if slotValue <= INVALID_INDEX || slotValue > indexCount || indexCount <= 1 {
+		return phyOffsets
+	}
+
+	for nextIndexToRead := slotValue; int32(len(phyOffsets)) < maxNum; {
+		absIndexPos := INDEX_HEADER_SIZE + self.hashSlotNum*HASH_SLOT_SIZE + nextIndexToRead*INDEX_SIZE
+		keyHashRead := self.readInt32(absIndexPos)
+		phyOffsetRead := self.readInt64(absIndexPos + 4)
+		timeDiff := int64(self.readInt32(absIndexPos + 12))
+		prevIndexRead := self.readInt32(absIndexPos + 16)
+		if timeDiff < 0 {
+			break
+		}
+
+		// 时间差按秒存储，查询时间范围放宽1秒
+		timeRead := beginTimestamp + timeDiff*1000
+		if keyHash == keyHashRead && timeRead >= begin-1000 && timeRead <= end {
+			phyOffsets = append(phyOffsets, phyOffsetRead)
+		}
+
+		if prevIndexRead <= INVALID_INDEX || prevIndexRead > indexCount || prevIndexRead == nextIndexToRead || timeRead < begin-1000 {
+			break
+		}
+		nextIndexToRead = prevIndexRead
+	}
+	return phyOffsets
+}
+
+// readInt32 按绝对位置读取，不改变读写位置，可与写索引并发执行
+func (self *IndexFile) readInt32(pos int32) int32 {
+	return byteutil.BytesToInt32(self.mappedByteBuffer.MMapBuf[pos : pos+4])
+}
+
+// readInt64 按绝对位置读取，不改变读写位置，可与写索引并发执行
+func (self *IndexFile) readInt64(pos int32) int64 {
+	return byteutil.BytesToInt64(self.mappedByteBuffer.MMapBuf[pos : pos+8])
+}
+
 func (self *IndexFile) indexKeyHashMethod(key string) int32 {
 	keyHash := self.indexKeyHashCode(key)
 	keyHashPositive := math.Abs(float64(keyHash))
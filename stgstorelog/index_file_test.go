@@ -0,0 +1,53 @@
+package stgstorelog
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIndexFile_SelectPhyOffset(t *testing.T) {
+	fileName := "./unit_test_store/IndexFileTest/20171204000000000"
+	defer os.RemoveAll("./unit_test_store/IndexFileTest")
+
+	// 哈希槽很少，保证多个key落在同一个槽上
+	indexFile := NewIndexFile(fileName, 4, 100, 0, 0)
+	storeTimestamp := int64(1512316800000)
+	for i := 0; i < 20; i++ {
+		key := "TopicTest#key"
+		if i%2 == 1 {
+			key = "TopicTest#other"
+		}
+		if !indexFile.putKey(key, int64(i*100), storeTimestamp+int64(i*1000)) {
+			t.Fatalf("put key %d failed", i)
+		}
+	}
+
+	phyOffsets := indexFile.selectPhyOffset(make([]int64, 0), "TopicTest#key", 64, 0, storeTimestamp+100000)
+	if len(phyOffsets) != 10 {
+		t.Fatalf("select phyOffsets failed: %v", phyOffsets)
+	}
+	for _, offset := range phyOffsets {
+		if offset%200 != 0 {
+			t.Errorf("phyOffset %d is not matched", offset)
+		}
+	}
+
+	phyOffsets = indexFile.selectPhyOffset(make([]int64, 0), "TopicTest#key", 3, 0, storeTimestamp+100000)
+	if len(phyOffsets) != 3 || phyOffsets[0] != 1800 {
+		t.Errorf("select phyOffsets with maxNum failed: %v", phyOffsets)
+	}
+
+	phyOffsets = indexFile.selectPhyOffset(make([]int64, 0), "TopicTest#key", 64, storeTimestamp+10000, storeTimestamp+100000)
+	if len(phyOffsets) != 5 {
+		t.Errorf("select phyOffsets with time range failed: %v", phyOffsets)
+	}
+
+	// 索引头写在文件中，重新加载后可以继续查询
+	indexFile.mappedByteBuffer.flush()
+	reloaded := NewIndexFile(fileName, 4, 100, 0, 0)
+	reloaded.load()
+	phyOffsets = reloaded.selectPhyOffset(make([]int64, 0), "TopicTest#other", 64, 0, storeTimestamp+100000)
+	if len(phyOffsets) != 10 || !reloaded.isTimeMatched(storeTimestamp, storeTimestamp+1000) {
+		t.Errorf("select phyOffsets after reload failed: %v", phyOffsets)
+	}
+}
@@ -267,9 +267,33 @@ func (self *IndexService) deleteExpiredFiles(files *list.List) {
 	}
 }
 
-func (self *IndexService) queryOffset(topic, key string, maxNum int32, begin, end int64) *QueryMessageResult {
-	// TODO
-	return nil
+// queryOffset 从最新的索引文件开始，查找topic、key在时间范围内对应的消息物理偏移量
+// Author agent
+// Since 2026/10/19
+func (self *IndexService) queryOffset(topic, key string, maxNum int32, begin, end int64) *QueryOffsetResult {
+	if batchNum := self.defaultMessageStore.MessageStoreConfig.MaxMsgsNumBatch; maxNum > batchNum {
+		maxNum = batchNum
+	}
+
+	result := NewQueryOffsetResult()
+	self.readWriteLock.RLock()
+	defer self.readWriteLock.RUnlock()
+
+	for element := self.indexFileList.Back(); element != nil; element = element.Prev() {
+		indexFile := element.Value.(*IndexFile)
+		if element == self.indexFileList.Back() {
+			result.IndexLastUpdateTimestamp = indexFile.getEndTimestamp()
+			result.IndexLastUpdatePhyoffset = indexFile.getEndPhyOffset()
+		}
+
+		if indexFile.isTimeMatched(begin, end) {
+			result.PhyOffsets = indexFile.selectPhyOffset(result.PhyOffsets, self.buildKey(topic, key), maxNum, begin, end)
+		}
+		if indexFile.indexHeader.getBeginTimestamp() < begin || int32(len(result.PhyOffsets)) >= maxNum {
+			break
+		}
+	}
+	return result
 }
 
 func (self *IndexService) putRequest(request interface{}) {
@@ -1,6 +1,9 @@
 package stgstorelog
 
 import (
+	"git.oschina.net/cloudzone/smartgo/stgcommon/filter"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/heartbeat"
 )
 
@@ -13,6 +16,7 @@ const (
 // Since 2017/9/6
 type MessageFilter interface {
 	IsMessageMatched(subscriptionData *heartbeat.SubscriptionData, tagsCode int64) bool
+	IsMatchedByCommitLog(subscriptionData *heartbeat.SubscriptionData, msgBuffer []byte) bool
 }
 
 // DefaultMessageFilter 消息过滤规则实现
@@ -30,9 +34,37 @@ func (df *DefaultMessageFilter) IsMessageMatched(subscriptionData *heartbeat.Sub
 		return true
 	}
 
+	// SQL92表达式需要消息属性，在IsMatchedByCommitLog中过滤
+	if filter.IsSql92Type(subscriptionData.ExpressionType) {
+		return true
+	}
+
 	if subscriptionData.SubString == SUBSCRIPTION_ALL {
 		return true
 	}
 
 	return subscriptionData.CodeSet.Contains(tagsCode)
 }
+
+// IsMatchedByCommitLog 根据commitlog中的消息属性过滤，仅用于SQL92表达式
+// Author agent
+// Since 2026/10/19
+func (df *DefaultMessageFilter) IsMatchedByCommitLog(subscriptionData *heartbeat.SubscriptionData, msgBuffer []byte) bool {
+	if nil == subscriptionData || !filter.IsSql92Type(subscriptionData.ExpressionType) {
+		return true
+	}
+
+	expression, err := filter.Compile(subscriptionData.SubString)
+	if err != nil {
+		logger.Warnf("compile sql92 expression failed, topic: %s, %s", subscriptionData.Topic, err.Error())
+		return false
+	}
+
+	msgExt, err := message.DecodeMessageExt(msgBuffer, false, false)
+	if err != nil {
+		logger.Warnf("decode message properties failed, topic: %s, %s", subscriptionData.Topic, err.Error())
+		return false
+	}
+
+	return expression.Evaluate(msgExt.Properties)
+}
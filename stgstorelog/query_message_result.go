@@ -17,4 +17,28 @@ func NewQueryMessageResult() *QueryMessageResult {
 
 func (qmr *QueryMessageResult) AddMessage(mapedBuffer *SelectMapedBufferResult) {
 	qmr.MessageMapedList = append(qmr.MessageMapedList, mapedBuffer)
+	qmr.MessageBufferList = append(qmr.MessageBufferList, mapedBuffer.MappedByteBuffer)
+	qmr.BufferTotalSize += mapedBuffer.Size
+}
+
+// Release 释放查询到的消息
+// Author agent
+// Since 2026/10/19
+func (qmr *QueryMessageResult) Release() {
+	for _, mapedBuffer := range qmr.MessageMapedList {
+		mapedBuffer.Release()
+	}
+}
+
+// QueryOffsetResult 通过Key查询索引，返回消息的物理偏移量
+// Author agent
+// Since 2026/10/19
+type QueryOffsetResult struct {
+	PhyOffsets               []int64
+	IndexLastUpdateTimestamp int64
+	IndexLastUpdatePhyoffset int64
+}
+
+func NewQueryOffsetResult() *QueryOffsetResult {
+	return &QueryOffsetResult{PhyOffsets: make([]int64, 0)}
 }
@@ -1,11 +1,17 @@
 package stgstorelog
 
 import (
-	"time"
+	"encoding/json"
 	"fmt"
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
-	"encoding/json"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+	"git.oschina.net/cloudzone/smartgo/stgstorelog/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -15,12 +21,22 @@ const (
 	DELAY_FOR_A_PERIOD = int64(10000)
 )
 
+// ScheduleMessageService 定时消息服务
+//
+// 按延时级别投递的消息写入SCHEDULE_TOPIC中对应级别的队列，到期后再写回真实的topic。
+// 指定了开始投递时间(PROPERTY_START_DELIVER_TIME)的消息不受延时级别的限制：每次选取不超过剩余时间的最大级别分段调度，
+// 到期写回时若仍未到开始投递时间则再次进入定时队列，直到投递时间到达
+// Author: agent
+// Since: 2026/10/19
 type ScheduleMessageService struct {
 	delayLevelTable     map[int32]int64      // 每个level对应的延时时间
 	offsetTable         map[int32]int64      // 延时计算到了哪里
+	offsetTableLock     sync.RWMutex         // offsetTable读写锁
 	ticker              *time.Ticker         // 定时器
 	defaultMessageStore *DefaultMessageStore // 存储顶层对象
 	maxDelayLevel       int32                // 最大值
+	stopChan            chan struct{}        // 停止投递
+	stopOnce            sync.Once            // 保证只关闭一次stopChan
 }
 
 func NewScheduleMessageService(defaultMessageStore *DefaultMessageStore) *ScheduleMessageService {
@@ -28,6 +44,7 @@ func NewScheduleMessageService(defaultMessageStore *DefaultMessageStore) *Schedu
 		delayLevelTable:     make(map[int32]int64, 32),
 		offsetTable:         make(map[int32]int64, 32),
 		defaultMessageStore: defaultMessageStore,
+		stopChan:            make(chan struct{}),
 	}
 	return service
 }
@@ -37,6 +54,9 @@ func delayLevel2QueueId(delayLevel int32) int32 {
 }
 
 func (self *ScheduleMessageService) buildRunningStats(stats map[string]string) {
+	self.offsetTableLock.RLock()
+	defer self.offsetTableLock.RUnlock()
+
 	for key, value := range self.offsetTable {
 		queueId := delayLevel2QueueId(key)
 		delayOffset := value
@@ -48,6 +68,9 @@ func (self *ScheduleMessageService) buildRunningStats(stats map[string]string) {
 }
 
 func (self *ScheduleMessageService) encodeOffsetTable() string {
+	self.offsetTableLock.RLock()
+	defer self.offsetTableLock.RUnlock()
+
 	result, err := json.Marshal(self.offsetTable)
 	if err != nil {
 		logger.Info("schedule message service offset table to json error:", err.Error())
@@ -66,22 +89,270 @@ func (self *ScheduleMessageService) computeDeliverTimestamp(delayLevel int32, st
 	return storeTimestamp + 1000
 }
 
+// computeScheduleDeliverTimestamp 计算定时队列中消息的到期时间，指定了开始投递时间的消息不晚于开始投递时间到期
+func (self *ScheduleMessageService) computeScheduleDeliverTimestamp(delayLevel int32, storeTimestamp, startDeliverTime int64) int64 {
+	deliverTimestamp := self.computeDeliverTimestamp(delayLevel, storeTimestamp)
+	if startDeliverTime > 0 && startDeliverTime < deliverTimestamp {
+		return startDeliverTime
+	}
+
+	return deliverTimestamp
+}
+
+// hopDelayLevel 选取不超过剩余时间的最大延时级别，剩余时间小于最小级别时取最小级别
+func (self *ScheduleMessageService) hopDelayLevel(remaining int64) int32 {
+	hopLevel := int32(1)
+	for level := int32(1); level <= self.maxDelayLevel; level++ {
+		if delay, ok := self.delayLevelTable[level]; ok && delay <= remaining && delay >= self.delayLevelTable[hopLevel] {
+			hopLevel = level
+		}
+	}
+
+	return hopLevel
+}
+
+// wrapScheduleMessage 定时消息改写到SCHEDULE_TOPIC，真实的topic、queueId保存在消息属性中，返回是否为定时消息
+func (self *ScheduleMessageService) wrapScheduleMessage(msg *MessageExtBrokerInner) bool {
+	delayLevel := int32(msg.GetDelayTimeLevel())
+	startDeliverTime := msg.GetStartDeliverTime()
+	if startDeliverTime > msg.StoreTimestamp {
+		delayLevel = self.hopDelayLevel(startDeliverTime - msg.StoreTimestamp)
+	} else if delayLevel <= 0 {
+		return false
+	} else {
+		startDeliverTime = 0
+	}
+
+	if delayLevel > self.maxDelayLevel {
+		delayLevel = self.maxDelayLevel
+	}
+
+	msg.PutProperty(message.PROPERTY_REAL_TOPIC, msg.Topic)
+	msg.PutProperty(message.PROPERTY_REAL_QUEUE_ID, strconv.Itoa(int(msg.QueueId)))
+	msg.SetDelayTimeLevel(int(delayLevel))
+	msg.PropertiesString = message.MessageProperties2String(msg.Properties)
+
+	msg.Topic = SCHEDULE_TOPIC
+	msg.QueueId = delayLevel2QueueId(delayLevel)
+	msg.TagsCode = self.computeScheduleDeliverTimestamp(delayLevel, msg.StoreTimestamp, startDeliverTime)
+	return true
+}
+
 func (self *ScheduleMessageService) Encode() string {
 	return self.encodeOffsetTable()
 }
 
 func (self *ScheduleMessageService) Load() bool {
-	// TODO
+	if !self.parseDelayLevel() {
+		return false
+	}
+
+	configFilePath := config.GetDelayOffsetStorePath(self.defaultMessageStore.MessageStoreConfig.StorePathRootDir)
+	content, err := stgcommon.File2String(configFilePath)
+	if err != nil || content == "" {
+		logger.Infof("schedule message service load %s empty, start from zero", configFilePath)
+		return true
+	}
+
+	offsetTable := make(map[int32]int64, 32)
+	if err := json.Unmarshal([]byte(content), &offsetTable); err != nil {
+		logger.Errorf("schedule message service decode %s error: %s", configFilePath, err.Error())
+		return false
+	}
+
+	self.offsetTableLock.Lock()
+	self.offsetTable = offsetTable
+	self.offsetTableLock.Unlock()
+
+	logger.Infof("schedule message service load %s ok", configFilePath)
+	return true
+}
+
+// parseDelayLevel 解析延时级别配置，例如"1s 5s 10s 30s 1m 2m 1h 1d"
+func (self *ScheduleMessageService) parseDelayLevel() bool {
+	timeUnitTable := map[string]int64{
+		"s": 1000,
+		"m": 1000 * 60,
+		"h": 1000 * 60 * 60,
+		"d": 1000 * 60 * 60 * 24,
+	}
+
+	levelString := self.defaultMessageStore.MessageStoreConfig.MessageDelayLevel
+	for i, value := range strings.Fields(levelString) {
+		unit, ok := timeUnitTable[value[len(value)-1:]]
+		if !ok {
+			logger.Errorf("parse delay level %s error, unknown time unit", levelString)
+			return false
+		}
+
+		num, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		if err != nil || num <= 0 {
+			logger.Errorf("parse delay level %s error, invalid value %s", levelString, value)
+			return false
+		}
+
+		level := int32(i + 1)
+		if level > self.maxDelayLevel {
+			self.maxDelayLevel = level
+		}
+		self.delayLevelTable[level] = num * unit
+	}
+
 	return true
 }
 
 func (self *ScheduleMessageService) Start() {
-	// TODO
+	for level := range self.delayLevelTable {
+		go self.deliverDelayedMessage(level)
+	}
+
+	self.ticker = time.NewTicker(time.Duration(DELAY_FOR_A_PERIOD) * time.Millisecond)
+	go func() {
+		for {
+			select {
+			case <-self.stopChan:
+				return
+			case <-self.ticker.C:
+				self.persist()
+			}
+		}
+	}()
 }
 
+// Shutdown 停止投递并持久化投递进度，重复调用时只执行一次
+// Author: agent
+// Since: 2026/10/19
 func (self *ScheduleMessageService) Shutdown() {
-	if self.ticker != nil {
-		self.ticker.Stop()
+	self.stopOnce.Do(func() {
+		close(self.stopChan)
+		if self.ticker != nil {
+			self.ticker.Stop()
+			self.persist()
+		}
+		logger.Info("shutdown schedule message service")
+	})
+}
+
+func (self *ScheduleMessageService) persist() {
+	content := self.Encode()
+	if content == "" {
+		return
+	}
+	configFilePath := config.GetDelayOffsetStorePath(self.defaultMessageStore.MessageStoreConfig.StorePathRootDir)
+	stgcommon.String2File([]byte(content), configFilePath)
+}
+
+func (self *ScheduleMessageService) getOffset(delayLevel int32) int64 {
+	self.offsetTableLock.RLock()
+	defer self.offsetTableLock.RUnlock()
+	return self.offsetTable[delayLevel]
+}
+
+func (self *ScheduleMessageService) updateOffset(delayLevel int32, offset int64) {
+	self.offsetTableLock.Lock()
+	defer self.offsetTableLock.Unlock()
+	self.offsetTable[delayLevel] = offset
+}
+
+// deliverDelayedMessage 按延时级别循环投递到期的消息
+func (self *ScheduleMessageService) deliverDelayedMessage(delayLevel int32) {
+	timer := time.NewTimer(time.Duration(FIRST_DELAY_TIME) * time.Millisecond)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-self.stopChan:
+			return
+		case <-timer.C:
+			timer.Reset(time.Duration(self.executeOnTimeup(delayLevel)) * time.Millisecond)
+		}
 	}
-	logger.Info("shutdown schedule message service")
+}
+
+// executeOnTimeup 投递延时级别队列中已到期的消息，返回下一次检查的间隔(毫秒)
+func (self *ScheduleMessageService) executeOnTimeup(delayLevel int32) (nextDelay int64) {
+	nextDelay = DELAY_FOR_A_PERIOD
+	defer utils.RecoveredFn()
+
+	offset := self.getOffset(delayLevel)
+	consumeQueue := self.defaultMessageStore.findConsumeQueue(SCHEDULE_TOPIC, delayLevel2QueueId(delayLevel))
+	if consumeQueue == nil {
+		return DELAY_FOR_A_WHILE
+	}
+
+	bufferConsumeQueue := consumeQueue.getIndexBuffer(offset)
+	if bufferConsumeQueue == nil {
+		// 过期文件已经被删除，从最小位置继续投递
+		if minOffset := consumeQueue.getMinOffsetInQueue(); offset < minOffset {
+			logger.Warnf("schedule message service delay level %d offset %d less than min offset %d, correct it", delayLevel, offset, minOffset)
+			self.updateOffset(delayLevel, minOffset)
+		}
+		return DELAY_FOR_A_WHILE
+	}
+	defer bufferConsumeQueue.Release()
+
+	nextOffset := offset
+	for i := int32(0); i < bufferConsumeQueue.Size; i += CQStoreUnitSize {
+		offsetPy := bufferConsumeQueue.MappedByteBuffer.ReadInt64()
+		sizePy := bufferConsumeQueue.MappedByteBuffer.ReadInt32()
+		tagsCode := bufferConsumeQueue.MappedByteBuffer.ReadInt64()
+
+		now := time.Now().UnixNano() / 1000000
+		deliverTimestamp := self.correctDeliverTimestamp(now, delayLevel, tagsCode)
+		nextOffset = offset + int64(i/CQStoreUnitSize)
+		if countdown := deliverTimestamp - now; countdown > 0 {
+			self.updateOffset(delayLevel, nextOffset)
+			return countdown
+		}
+
+		msgExt := self.defaultMessageStore.lookMessageByOffset(offsetPy, sizePy)
+		if msgExt == nil {
+			continue
+		}
+
+		msgInner := self.messageTimeup(msgExt)
+		putMessageResult := self.defaultMessageStore.PutMessage(msgInner)
+		if putMessageResult == nil || putMessageResult.PutMessageStatus != PUTMESSAGE_PUT_OK {
+			logger.Errorf("schedule message service put message to %s error, msgId: %s", msgInner.Topic, msgExt.MsgId)
+			self.updateOffset(delayLevel, nextOffset)
+			return DELAY_FOR_A_PERIOD
+		}
+	}
+
+	self.updateOffset(delayLevel, offset+int64(bufferConsumeQueue.Size/CQStoreUnitSize))
+	return DELAY_FOR_A_WHILE
+}
+
+// correctDeliverTimestamp 到期时间超出延时级别的范围(如系统时间被调小)时立即投递
+func (self *ScheduleMessageService) correctDeliverTimestamp(now int64, delayLevel int32, deliverTimestamp int64) int64 {
+	if maxTimestamp := now + self.delayLevelTable[delayLevel]; deliverTimestamp > maxTimestamp {
+		return now
+	}
+
+	return deliverTimestamp
+}
+
+// messageTimeup 还原定时消息的真实topic、queueId
+func (self *ScheduleMessageService) messageTimeup(msgExt *message.MessageExt) *MessageExtBrokerInner {
+	msgInner := new(MessageExtBrokerInner)
+	msgInner.Body = msgExt.Body
+	msgInner.Flag = msgExt.Flag
+	message.SetPropertiesMap(&msgInner.Message, msgExt.Properties)
+	msgInner.SysFlag = msgExt.SysFlag
+	msgInner.BornTimestamp = msgExt.BornTimestamp
+	msgInner.BornHost = msgExt.BornHost
+	msgInner.StoreHost = msgExt.StoreHost
+	msgInner.ReconsumeTimes = msgExt.ReconsumeTimes
+	msgInner.TagsCode = TagsString2tagsCode(stgcommon.SINGLE_TAG, msgInner.GetTags())
+	msgInner.SetWaitStoreMsgOK(false)
+
+	msgInner.Topic = msgInner.GetProperty(message.PROPERTY_REAL_TOPIC)
+	queueId, _ := strconv.Atoi(msgInner.GetProperty(message.PROPERTY_REAL_QUEUE_ID))
+	msgInner.QueueId = int32(queueId)
+
+	msgInner.ClearProperty(message.PROPERTY_DELAY_TIME_LEVEL)
+	msgInner.ClearProperty(message.PROPERTY_REAL_TOPIC)
+	msgInner.ClearProperty(message.PROPERTY_REAL_QUEUE_ID)
+	msgInner.PropertiesString = message.MessageProperties2String(msgInner.Properties)
+	return msgInner
 }
@@ -0,0 +1,109 @@
+package stgstorelog
+
+import (
+	"testing"
+
+	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+)
+
+func newTestScheduleMessageService(t *testing.T) *ScheduleMessageService {
+	messageStore := &DefaultMessageStore{MessageStoreConfig: &MessageStoreConfig{MessageDelayLevel: "1s 5s 10s 30s 1m 1h 1d"}}
+	service := NewScheduleMessageService(messageStore)
+	if !service.parseDelayLevel() {
+		t.Fatalf("parse delay level failed")
+	}
+	return service
+}
+
+func TestScheduleMessageServiceParseDelayLevel(t *testing.T) {
+	service := newTestScheduleMessageService(t)
+	if service.maxDelayLevel != 7 {
+		t.Errorf("maxDelayLevel = %d, want 7", service.maxDelayLevel)
+	}
+	if service.delayLevelTable[5] != 60*1000 || service.delayLevelTable[7] != 24*3600*1000 {
+		t.Errorf("delayLevelTable = %v", service.delayLevelTable)
+	}
+
+	messageStore := &DefaultMessageStore{MessageStoreConfig: &MessageStoreConfig{MessageDelayLevel: "1s 5x"}}
+	if NewScheduleMessageService(messageStore).parseDelayLevel() {
+		t.Errorf("parse delay level with unknown unit should fail")
+	}
+}
+
+func TestScheduleMessageServiceHopDelayLevel(t *testing.T) {
+	service := newTestScheduleMessageService(t)
+	cases := map[int64]int32{
+		300:            1,
+		1000:           1,
+		7000:           2,
+		59999:          4,
+		2 * 3600000:    6,
+		3 * 86400000:   7,
+		86400000 - 500: 6,
+	}
+	for remaining, expect := range cases {
+		if level := service.hopDelayLevel(remaining); level != expect {
+			t.Errorf("hopDelayLevel(%d) = %d, want %d", remaining, level, expect)
+		}
+	}
+}
+
+func TestScheduleMessageServiceWrapScheduleMessage(t *testing.T) {
+	service := newTestScheduleMessageService(t)
+
+	msg := new(MessageExtBrokerInner)
+	msg.Topic = "TestTopic"
+	msg.QueueId = 3
+	msg.StoreTimestamp = 100000
+	if service.wrapScheduleMessage(msg) || msg.Topic != "TestTopic" {
+		t.Fatalf("message without delay should not be scheduled")
+	}
+
+	msg.SetDelayTimeLevel(2)
+	if !service.wrapScheduleMessage(msg) {
+		t.Fatalf("message with delay level should be scheduled")
+	}
+	if msg.Topic != SCHEDULE_TOPIC || msg.QueueId != 1 || msg.TagsCode != 105000 {
+		t.Errorf("topic=%s queueId=%d tagsCode=%d", msg.Topic, msg.QueueId, msg.TagsCode)
+	}
+	if msg.GetProperty(message.PROPERTY_REAL_TOPIC) != "TestTopic" || msg.GetProperty(message.PROPERTY_REAL_QUEUE_ID) != "3" {
+		t.Errorf("real topic or queueId not kept, properties=%v", msg.Properties)
+	}
+
+	// 指定开始投递时间：选取不超过剩余时间的最大级别，到期时间不晚于开始投递时间
+	msg = new(MessageExtBrokerInner)
+	msg.Topic = "TestTopic"
+	msg.StoreTimestamp = 100000
+	msg.SetStartDeliverTime(100000 + 90*60*1000)
+	if !service.wrapScheduleMessage(msg) {
+		t.Fatalf("message with start deliver time should be scheduled")
+	}
+	if msg.QueueId != delayLevel2QueueId(6) || msg.TagsCode != 100000+3600*1000 {
+		t.Errorf("queueId=%d tagsCode=%d", msg.QueueId, msg.TagsCode)
+	}
+
+	msg = new(MessageExtBrokerInner)
+	msg.Topic = "TestTopic"
+	msg.StoreTimestamp = 100000
+	msg.SetStartDeliverTime(100300)
+	service.wrapScheduleMessage(msg)
+	if msg.QueueId != 0 || msg.TagsCode != 100300 {
+		t.Errorf("queueId=%d tagsCode=%d", msg.QueueId, msg.TagsCode)
+	}
+
+	timeupMsg := service.messageTimeup(&msg.MessageExt)
+	if timeupMsg.Topic != "TestTopic" || timeupMsg.GetProperty(message.PROPERTY_DELAY_TIME_LEVEL) != "" {
+		t.Errorf("timeup message topic=%s properties=%v", timeupMsg.Topic, timeupMsg.Properties)
+	}
+}
+
+func TestScheduleMessageServiceShutdownTwice(t *testing.T) {
+	service := newTestScheduleMessageService(t)
+	service.Shutdown()
+	service.Shutdown()
+	select {
+	case <-service.stopChan:
+	default:
+		t.Fatalf("stopChan not closed")
+	}
+}
@@ -0,0 +1,271 @@
+package stgstorelog
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// MapedFileInfo 单个映射文件的只读视图
+// Author: agent
+// Since: 2026/10/19
+type MapedFileInfo struct {
+	FileName          string `json:"fileName"`
+	FileFromOffset    int64  `json:"fileFromOffset"`
+	FileSize          int64  `json:"fileSize"`
+	WrotePosition     int64  `json:"wrotePosition"`
+	CommittedPosition int64  `json:"committedPosition"`
+	StoreTimestamp    int64  `json:"storeTimestamp"`
+	Full              bool   `json:"full"`
+}
+
+// MapedFileQueueInfo 映射文件队列的只读视图
+// Author: agent
+// Since: 2026/10/19
+type MapedFileQueueInfo struct {
+	StorePath      string           `json:"storePath"`
+	MapedFileSize  int64            `json:"mapedFileSize"`
+	MinOffset      int64            `json:"minOffset"`
+	MaxOffset      int64            `json:"maxOffset"`
+	CommittedWhere int64            `json:"committedWhere"`
+	FallBehind     int64            `json:"fallBehind"`
+	StoreTimestamp int64            `json:"storeTimestamp"`
+	MapedFiles     []*MapedFileInfo `json:"mapedFiles"`
+}
+
+// ConsumeQueueInfo 逻辑队列的只读视图
+// Author: agent
+// Since: 2026/10/19
+type ConsumeQueueInfo struct {
+	Topic           string              `json:"topic"`
+	QueueId         int32               `json:"queueId"`
+	MinOffset       int64               `json:"minOffset"`
+	MaxOffset       int64               `json:"maxOffset"`
+	MaxPhysicOffset int64               `json:"maxPhysicOffset"`
+	MinLogicOffset  int64               `json:"minLogicOffset"`
+	MapedFileQueue  *MapedFileQueueInfo `json:"mapedFileQueue"`
+}
+
+// CheckpointInfo 存储检查点的只读视图
+// Author: agent
+// Since: 2026/10/19
+type CheckpointInfo struct {
+	PhysicMsgTimestamp int64 `json:"physicMsgTimestamp"`
+	LogicsMsgTimestamp int64 `json:"logicsMsgTimestamp"`
+	IndexMsgTimestamp  int64 `json:"indexMsgTimestamp"`
+	MinTimestamp       int64 `json:"minTimestamp"`
+	MinTimestampIndex  int64 `json:"minTimestampIndex"`
+}
+
+// RunningFlagsInfo 运行标志位的只读视图
+// Author: agent
+// Since: 2026/10/19
+type RunningFlagsInfo struct {
+	FlagBits              int  `json:"flagBits"`
+	Readable              bool `json:"readable"`
+	Writeable             bool `json:"writeable"`
+	WriteLogicsQueueError bool `json:"writeLogicsQueueError"`
+	WriteIndexFileError   bool `json:"writeIndexFileError"`
+	DiskFull              bool `json:"diskFull"`
+}
+
+// FlushInfo 刷盘与分发进度的只读视图
+// Author: agent
+// Since: 2026/10/19
+type FlushInfo struct {
+	CommitLogMinOffset      int64 `json:"commitLogMinOffset"`
+	CommitLogMaxOffset      int64 `json:"commitLogMaxOffset"`
+	CommitLogCommittedWhere int64 `json:"commitLogCommittedWhere"`
+	CommitLogFallBehind     int64 `json:"commitLogFallBehind"`
+	DispatchBehindRequests  int32 `json:"dispatchBehindRequests"`
+}
+
+// HAConnectionInfo 主从复制连接的只读视图
+// Author agent
+// Since 2026/10/19
+type HAConnectionInfo struct {
+	ClientAddress      string `json:"clientAddress"`
+	SlaveRequestOffset int64  `json:"slaveRequestOffset"`
+	SlaveAckOffset     int64  `json:"slaveAckOffset"`
+}
+
+// HAServiceInfo 主从复制服务的只读视图
+// Author agent
+// Since 2026/10/19
+type HAServiceInfo struct {
+	ConnectionCount     int32               `json:"connectionCount"`
+	Push2SlaveMaxOffset int64               `json:"push2SlaveMaxOffset"`
+	Connections         []*HAConnectionInfo `json:"connections"`
+}
+
+// buildMapedFileInfo 构建映射文件只读视图
+// Author: agent
+// Since: 2026/10/19
+func (self *MapedFile) buildMapedFileInfo() *MapedFileInfo {
+	return &MapedFileInfo{
+		FileName:          self.fileName,
+		FileFromOffset:    self.fileFromOffset,
+		FileSize:          self.fileSize,
+		WrotePosition:     atomic.LoadInt64(&self.wrotePostion),
+		CommittedPosition: atomic.LoadInt64(&self.committedPosition),
+		StoreTimestamp:    atomic.LoadInt64(&self.storeTimestamp),
+		Full:              self.isFull(),
+	}
+}
+
+// buildMapedFileQueueInfo 构建映射文件队列只读视图
+// 注意：只读取已存在的文件，不会像getLastMapedFile()那样触发新文件的创建
+// Author: agent
+// Since: 2026/10/19
+func (self *MapedFileQueue) buildMapedFileQueueInfo() *MapedFileQueueInfo {
+	info := &MapedFileQueueInfo{
+		StorePath:      self.storePath,
+		MapedFileSize:  self.mapedFileSize,
+		MinOffset:      -1,
+		CommittedWhere: self.committedWhere,
+		StoreTimestamp: self.storeTimestamp,
+		MapedFiles:     make([]*MapedFileInfo, 0),
+	}
+
+	for _, mf := range self.copyMapedFiles(0) {
+		if mf != nil {
+			info.MapedFiles = append(info.MapedFiles, mf.buildMapedFileInfo())
+		}
+	}
+
+	if size := len(info.MapedFiles); size > 0 {
+		first, last := info.MapedFiles[0], info.MapedFiles[size-1]
+		info.MinOffset = first.FileFromOffset
+		info.MaxOffset = last.FileFromOffset + last.WrotePosition
+		if info.CommittedWhere != 0 {
+			info.FallBehind = info.MaxOffset - info.CommittedWhere
+		}
+	}
+	return info
+}
+
+// CommitLogInfo 获取物理队列映射文件布局
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultMessageStore) CommitLogInfo() *MapedFileQueueInfo {
+	return self.CommitLog.MapedFileQueue.buildMapedFileQueueInfo()
+}
+
+// ConsumeQueueInfos 获取所有逻辑队列的映射文件布局，topic非空时只返回该topic的队列
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultMessageStore) ConsumeQueueInfos(topic string) []*ConsumeQueueInfo {
+	queues := make([]*ConsumeQueue, 0)
+
+	self.consumeQueueTableMu.RLock()
+	for tp, table := range self.consumeTopicTable {
+		if topic != "" && topic != tp {
+			continue
+		}
+		table.consumeQueuesMu.RLock()
+		for _, cq := range table.consumeQueues {
+			queues = append(queues, cq)
+		}
+		table.consumeQueuesMu.RUnlock()
+	}
+	self.consumeQueueTableMu.RUnlock()
+
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].topic == queues[j].topic {
+			return queues[i].queueId < queues[j].queueId
+		}
+		return queues[i].topic < queues[j].topic
+	})
+
+	infos := make([]*ConsumeQueueInfo, 0, len(queues))
+	for _, cq := range queues {
+		queueInfo := cq.mapedFileQueue.buildMapedFileQueueInfo()
+		infos = append(infos, &ConsumeQueueInfo{
+			Topic:           cq.topic,
+			QueueId:         cq.queueId,
+			MinOffset:       cq.getMinOffsetInQueue(),
+			MaxOffset:       queueInfo.MaxOffset / CQStoreUnitSize,
+			MaxPhysicOffset: cq.maxPhysicOffset,
+			MinLogicOffset:  cq.minLogicOffset,
+			MapedFileQueue:  queueInfo,
+		})
+	}
+	return infos
+}
+
+// CheckpointInfo 获取存储检查点时间戳
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultMessageStore) CheckpointInfo() *CheckpointInfo {
+	scp := self.StoreCheckpoint
+	if scp == nil {
+		return nil
+	}
+	return &CheckpointInfo{
+		PhysicMsgTimestamp: scp.physicMsgTimestamp,
+		LogicsMsgTimestamp: scp.logicsMsgTimestamp,
+		IndexMsgTimestamp:  scp.indexMsgTimestamp,
+		MinTimestamp:       scp.getMinTimestamp(),
+		MinTimestampIndex:  scp.getMinTimestampIndex(),
+	}
+}
+
+// RunningFlagsInfo 获取存储运行标志位
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultMessageStore) RunningFlagsInfo() *RunningFlagsInfo {
+	flagBits := self.RunningFlags.flagBits
+	return &RunningFlagsInfo{
+		FlagBits:              flagBits,
+		Readable:              self.RunningFlags.isReadable(),
+		Writeable:             self.RunningFlags.isWriteable(),
+		WriteLogicsQueueError: flagBits&WriteLogicsQueueErrorBit != 0,
+		WriteIndexFileError:   flagBits&WriteIndexFileErrorBit != 0,
+		DiskFull:              flagBits&DiskFullBit != 0,
+	}
+}
+
+// FlushInfo 获取物理队列刷盘进度以及消息分发积压
+// Author: agent
+// Since: 2026/10/19
+func (self *DefaultMessageStore) FlushInfo() *FlushInfo {
+	commitLogInfo := self.CommitLogInfo()
+	info := &FlushInfo{
+		CommitLogMinOffset:      commitLogInfo.MinOffset,
+		CommitLogMaxOffset:      commitLogInfo.MaxOffset,
+		CommitLogCommittedWhere: commitLogInfo.CommittedWhere,
+		CommitLogFallBehind:     commitLogInfo.FallBehind,
+	}
+	if self.DispatchMessageService != nil {
+		info.DispatchBehindRequests = atomic.LoadInt32(&self.DispatchMessageService.requestSize)
+	}
+	return info
+}
+
+// HAServiceInfo 获取主从复制连接信息
+// Author agent
+// Since 2026/10/19
+func (self *DefaultMessageStore) HAServiceInfo() *HAServiceInfo {
+	service := self.HAService
+	if service == nil {
+		return nil
+	}
+
+	info := &HAServiceInfo{
+		ConnectionCount:     atomic.LoadInt32(&service.connectionCount),
+		Push2SlaveMaxOffset: atomic.LoadInt64(&service.push2SlaveMaxOffset),
+		Connections:         make([]*HAConnectionInfo, 0),
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	for element := service.connectionList.Front(); element != nil; element = element.Next() {
+		if conn, ok := element.Value.(*HAConnection); ok {
+			info.Connections = append(info.Connections, &HAConnectionInfo{
+				ClientAddress:      conn.clientAddress,
+				SlaveRequestOffset: conn.slaveRequestOffset,
+				SlaveAckOffset:     conn.slaveAckOffset,
+			})
+		}
+	}
+	return info
+}
@@ -18,6 +18,10 @@ const (
 	PrintTPSInterval     = 60 * 1
 )
 
+// PutMessageDistributeTimeBounds 写消息耗时分布(毫秒)的各区间上界(不含)，
+// 与putMessageDistributeTime一一对应，最后一个区间为>=10000ms
+var PutMessageDistributeTimeBounds = []int64{1, 10, 100, 500, 1000, 10000}
+
 type StoreStatsService struct {
 	putMessageFailedTimes        int64
 	putMessageTopicTimesTotal    map[string]int64
@@ -32,6 +36,7 @@ type StoreStatsService struct {
 	transferedMsgCountList       *list.List
 	messageStoreBootTimestamp    int64
 	putMessageEntireTimeMax      int64
+	putMessageEntireTimeTotal    int64
 	getMessageEntireTimeMax      int64
 	lockPut                      *sync.Mutex
 	lockGet                      *sync.Mutex
@@ -470,6 +475,7 @@ func (self *StoreStatsService) getSinglePutMessageTopicSizeTotal(topic string) i
 }
 
 func (self *StoreStatsService) setPutMessageEntireTimeMax(value int64) {
+	atomic.AddInt64(&self.putMessageEntireTimeTotal, value)
 	if value <= 0 {
 		atomic.AddInt64(&self.putMessageDistributeTime[0], 1)
 	} else if value < 10 {
@@ -513,6 +519,31 @@ func (self *StoreStatsService) getSinglePutMessageTopicTimesTotal(topic string)
 	return result
 }
 
+// GetPutMessageDistributeTime 获取写消息耗时分布，区间上界见PutMessageDistributeTimeBounds
+// Author agent
+// Since 2026/10/19
+func (self *StoreStatsService) GetPutMessageDistributeTime() []int64 {
+	result := make([]int64, len(self.putMessageDistributeTime))
+	for i := range self.putMessageDistributeTime {
+		result[i] = atomic.LoadInt64(&self.putMessageDistributeTime[i])
+	}
+	return result
+}
+
+// GetPutMessageEntireTimeTotal 获取写消息累计耗时(毫秒)
+// Author agent
+// Since 2026/10/19
+func (self *StoreStatsService) GetPutMessageEntireTimeTotal() int64 {
+	return atomic.LoadInt64(&self.putMessageEntireTimeTotal)
+}
+
+// GetPutMessageFailedTimes 获取写消息失败次数
+// Author agent
+// Since 2026/10/19
+func (self *StoreStatsService) GetPutMessageFailedTimes() int64 {
+	return atomic.LoadInt64(&self.putMessageFailedTimes)
+}
+
 func (self *StoreStatsService) setDispatchMaxBuffer(value int64) {
 	if value > self.dispatchMaxBuffer {
 		self.dispatchMaxBuffer = value
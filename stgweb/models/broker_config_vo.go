@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+// UpdateBrokerConfig 动态修改broker配置，未指定brokerAddr时修改集群内所有master
+// Author: agent
+// Since: 2026/10/19
+type UpdateBrokerConfig struct {
+	ClusterName string                 `json:"clusterName" valid:"required"` // 集群名称
+	BrokerAddr  string                 `json:"brokerAddr"`                   // broker地址，可选
+	Properties  map[string]interface{} `json:"properties"`                   // 待修改的配置项，例如{"longPollingEnable":false}
+}
+
+// Validate 参数验证
+// Author: agent
+// Since: 2026/10/19
+func (updateBrokerConfig *UpdateBrokerConfig) Validate() error {
+	if err := utils.ValidateStruct(updateBrokerConfig); err != nil {
+		return fmt.Errorf("clusterName字段值无效")
+	}
+	if len(updateBrokerConfig.Properties) == 0 {
+		return fmt.Errorf("properties字段值无效")
+	}
+	return nil
+}
+
+// BrokerConfigChangeVo broker配置项的变更结果
+// Author: agent
+// Since: 2026/10/19
+type BrokerConfigChangeVo struct {
+	BrokerAddr string `json:"brokerAddr"` // broker地址
+	Scope      string `json:"scope"`      // brokerConfig、messageStoreConfig
+	Key        string `json:"key"`        // 配置项名称
+	OldValue   string `json:"oldValue"`   // 修改前的值
+	NewValue   string `json:"newValue"`   // 修改后的值
+	HotReload  bool   `json:"hotReload"`  // true:已立即生效；false:重启broker后生效
+}
+
+// ToBrokerConfigChangeVo 转化为broker配置变更Vo
+// Author: agent
+// Since: 2026/10/19
+func ToBrokerConfigChangeVo(brokerAddr string, change *body.BrokerConfigChange) *BrokerConfigChangeVo {
+	return &BrokerConfigChangeVo{
+		BrokerAddr: brokerAddr,
+		Scope:      change.Scope,
+		Key:        change.Key,
+		OldValue:   change.OldValue,
+		NewValue:   change.NewValue,
+		HotReload:  change.HotReload,
+	}
+}
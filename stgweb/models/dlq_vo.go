@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"strings"
+)
+
+// DLQMessageVo 死信消息
+// Author: agent
+// Since: 2026/10/19
+type DLQMessageVo struct {
+	BrokerName     string            `json:"brokerName"`     // 死信所在的broker
+	MsgId          string            `json:"msgId"`          // 死信消息ID
+	OriginMsgId    string            `json:"originMsgId"`    // 原始消息ID
+	OriginTopic    string            `json:"originTopic"`    // 原始Topic
+	QueueId        int32             `json:"queueId"`        // 死信队列ID
+	QueueOffset    int64             `json:"queueOffset"`    // 死信队列偏移量
+	Tags           string            `json:"tags"`           // 消息标签
+	Keys           string            `json:"keys"`           // 消息Keys
+	ReconsumeTimes int32             `json:"reconsumeTimes"` // 重试次数
+	BornHost       string            `json:"bornHost"`       // 消息来源
+	BornTimestamp  string            `json:"bornTimestamp"`  // 消息在客户端创建时间
+	StoreTimestamp string            `json:"storeTimestamp"` // 进入死信队列的时间
+	BodySize       int               `json:"bodySize"`       // 消息体大小
+	Properties     map[string]string `json:"properties"`     // 消息属性
+}
+
+// ToDLQMessageVo 转化为死信消息Vo
+// Author: agent
+// Since: 2026/10/19
+func ToDLQMessageVo(msg *body.DLQMessage) *DLQMessageVo {
+	dlqMessageVo := &DLQMessageVo{
+		BrokerName:     msg.BrokerName,
+		MsgId:          msg.MsgId,
+		OriginMsgId:    msg.OriginMsgId,
+		OriginTopic:    msg.OriginTopic,
+		QueueId:        msg.QueueId,
+		QueueOffset:    msg.QueueOffset,
+		Tags:           msg.Tags,
+		Keys:           msg.Keys,
+		ReconsumeTimes: msg.ReconsumeTimes,
+		BornHost:       msg.BornHost,
+		BornTimestamp:  stgcommon.FormatTimestamp(msg.BornTimestamp),
+		StoreTimestamp: stgcommon.FormatTimestamp(msg.StoreTimestamp),
+		BodySize:       msg.BodySize,
+		Properties:     msg.Properties,
+	}
+	return dlqMessageVo
+}
+
+// DLQResendVo 重发死信消息请求，MsgIds为空则重发消费组全部死信
+// Author: agent
+// Since: 2026/10/19
+type DLQResendVo struct {
+	ConsumerGroup string   `json:"consumerGroup"` // 消费组
+	MsgIds        []string `json:"msgIds"`        // 死信消息ID
+}
+
+// Validate 参数验证
+// Author: agent
+// Since: 2026/10/19
+func (vo *DLQResendVo) Validate() error {
+	vo.ConsumerGroup = strings.TrimSpace(vo.ConsumerGroup)
+	if vo.ConsumerGroup == "" {
+		return fmt.Errorf("consumerGroup字段值无效")
+	}
+	for i, msgId := range vo.MsgIds {
+		vo.MsgIds[i] = strings.TrimSpace(msgId)
+		if vo.MsgIds[i] == "" {
+			return fmt.Errorf("msgIds字段值无效")
+		}
+	}
+	return nil
+}
@@ -1,12 +1,73 @@
 package models
 
 import (
+	"fmt"
 	"git.oschina.net/cloudzone/smartgo/stgcommon"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/admin"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"strconv"
 )
 
+// UpdateSubGroup 更新消费组参数，未传递的字段保持broker上的原有配置
+// Author: agent
+// Since: 2026/10/19
+type UpdateSubGroup struct {
+	ClusterName            string                    `json:"clusterName" valid:"required"`     // 集群名称
+	ConsumerGroupId        string                    `json:"consumerGroupId" valid:"required"` // 消费组ID
+	ConsumeEnable          *bool                     `json:"consumeEnable"`                    // 消费功能是否开启
+	ConsumeBroadcastEnable *bool                     `json:"consumeBroadcastEnable"`           // 是否允许广播方式消费
+	RetryQueueNums         *int32                    `json:"retryQueueNums"`                   // 重试队列个数
+	RetryMaxTimes          *int32                    `json:"retryMaxTimes"`                    // 重试消费最大次数
+	RetryPolicy            *subscription.RetryPolicy `json:"retryPolicy"`                      // 重试策略
+	ResetRetryPolicy       bool                      `json:"resetRetryPolicy"`                 // 清除重试策略，恢复为按服务器延时级别重试
+}
+
+// Validate 参数验证
+// Author: agent
+// Since: 2026/10/19
+func (updateSubGroup *UpdateSubGroup) Validate() error {
+	if err := utils.ValidateStruct(updateSubGroup); err != nil {
+		return fmt.Errorf("clusterName、consumerGroupId字段值无效")
+	}
+	if updateSubGroup.RetryQueueNums != nil && *updateSubGroup.RetryQueueNums <= 0 {
+		return fmt.Errorf("retryQueueNums字段值无效")
+	}
+	if updateSubGroup.RetryMaxTimes != nil && *updateSubGroup.RetryMaxTimes < 0 {
+		return fmt.Errorf("retryMaxTimes字段值无效")
+	}
+	if updateSubGroup.RetryPolicy != nil {
+		return updateSubGroup.RetryPolicy.Validate()
+	}
+	return nil
+}
+
+// ApplyTo 将需要更新的字段写入订阅组配置
+// Author: agent
+// Since: 2026/10/19
+func (updateSubGroup *UpdateSubGroup) ApplyTo(config *subscription.SubscriptionGroupConfig) {
+	config.GroupName = updateSubGroup.ConsumerGroupId
+	if updateSubGroup.ConsumeEnable != nil {
+		config.ConsumeEnable = *updateSubGroup.ConsumeEnable
+	}
+	if updateSubGroup.ConsumeBroadcastEnable != nil {
+		config.ConsumeBroadcastEnable = *updateSubGroup.ConsumeBroadcastEnable
+	}
+	if updateSubGroup.RetryQueueNums != nil {
+		config.RetryQueueNums = *updateSubGroup.RetryQueueNums
+	}
+	if updateSubGroup.RetryMaxTimes != nil {
+		config.RetryMaxTimes = *updateSubGroup.RetryMaxTimes
+	}
+	if updateSubGroup.ResetRetryPolicy {
+		config.RetryPolicy = nil
+	}
+	if updateSubGroup.RetryPolicy != nil {
+		config.RetryPolicy = updateSubGroup.RetryPolicy
+	}
+}
+
 // ConsumerGroup 消费组
 // Author: tianyuliang
 // Since: 2017/11/7
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
+)
+
+// UpdateQuota 创建或更新集群内所有master上的收发配额，各项配额<=0表示不限制
+// Author: agent
+// Since: 2026/10/19
+type UpdateQuota struct {
+	ClusterName        string `json:"clusterName" valid:"required"`  // 集群名称
+	ResourceType       string `json:"resourceType" valid:"required"` // 资源类型：TOPIC、PRODUCER_GROUP、CONSUMER_GROUP、CLIENT_ID
+	ResourceName       string `json:"resourceName" valid:"required"` // 资源名称
+	SendMsgPerSecond   int64  `json:"sendMsgPerSecond"`              // 每秒允许发送的消息条数
+	SendBytesPerSecond int64  `json:"sendBytesPerSecond"`            // 每秒允许发送的消息字节数
+	PullMsgPerSecond   int64  `json:"pullMsgPerSecond"`              // 每秒允许拉取的消息条数
+	PullBytesPerSecond int64  `json:"pullBytesPerSecond"`            // 每秒允许拉取的消息字节数
+}
+
+// Validate 参数验证
+// Author: agent
+// Since: 2026/10/19
+func (updateQuota *UpdateQuota) Validate() error {
+	if err := utils.ValidateStruct(updateQuota); err != nil {
+		return fmt.Errorf("clusterName、resourceType、resourceName字段值无效")
+	}
+	if !quota.IsValidResourceType(updateQuota.ResourceType) {
+		return fmt.Errorf("resourceType字段值无效")
+	}
+	return nil
+}
+
+// ToQuotaConfig 转化为broker上的配额配置
+// Author: agent
+// Since: 2026/10/19
+func (updateQuota *UpdateQuota) ToQuotaConfig() *quota.QuotaConfig {
+	return &quota.QuotaConfig{
+		ResourceType:       updateQuota.ResourceType,
+		ResourceName:       updateQuota.ResourceName,
+		SendMsgPerSecond:   updateQuota.SendMsgPerSecond,
+		SendBytesPerSecond: updateQuota.SendBytesPerSecond,
+		PullMsgPerSecond:   updateQuota.PullMsgPerSecond,
+		PullBytesPerSecond: updateQuota.PullBytesPerSecond,
+	}
+}
+
+// QuotaVo broker上的收发配额
+// Author: agent
+// Since: 2026/10/19
+type QuotaVo struct {
+	BrokerAddr         string `json:"brokerAddr"`         // 配额所在的broker地址
+	ResourceType       string `json:"resourceType"`       // 资源类型
+	ResourceName       string `json:"resourceName"`       // 资源名称
+	SendMsgPerSecond   int64  `json:"sendMsgPerSecond"`   // 每秒允许发送的消息条数
+	SendBytesPerSecond int64  `json:"sendBytesPerSecond"` // 每秒允许发送的消息字节数
+	PullMsgPerSecond   int64  `json:"pullMsgPerSecond"`   // 每秒允许拉取的消息条数
+	PullBytesPerSecond int64  `json:"pullBytesPerSecond"` // 每秒允许拉取的消息字节数
+}
+
+// ToQuotaVo 转化为收发配额Vo
+// Author: agent
+// Since: 2026/10/19
+func ToQuotaVo(brokerAddr string, config *quota.QuotaConfig) *QuotaVo {
+	return &QuotaVo{
+		BrokerAddr:         brokerAddr,
+		ResourceType:       config.ResourceType,
+		ResourceName:       config.ResourceName,
+		SendMsgPerSecond:   config.SendMsgPerSecond,
+		SendBytesPerSecond: config.SendBytesPerSecond,
+		PullMsgPerSecond:   config.PullMsgPerSecond,
+		PullBytesPerSecond: config.PullBytesPerSecond,
+	}
+}
@@ -3,6 +3,8 @@ package brokerService
 import (
 	"fmt"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/subscription"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgweb/models"
 	"git.oschina.net/cloudzone/smartgo/stgweb/modules"
@@ -60,8 +62,172 @@ func (service *BrokerService) SyncTopicToBroker() {
 // UpdateSubGroup 更新consumer消费组参数
 // Author: tianyuliang
 // Since: 2017/11/9
-func (service *BrokerService) UpdateSubGroup() {
+func (service *BrokerService) UpdateSubGroup(updateSubGroup *models.UpdateSubGroup) error {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	masterSet, err := defaultMQAdminExt.FetchMasterAddrByClusterName(updateSubGroup.ClusterName)
+	if err != nil {
+		return err
+	}
+	if masterSet == nil || masterSet.Cardinality() == 0 {
+		return fmt.Errorf("masterSet is empty, update subGroup failed. consumerGroupId=%s, clusterName=%s", updateSubGroup.ConsumerGroupId, updateSubGroup.ClusterName)
+	}
+
+	for itor := range masterSet.Iterator().C {
+		brokerAddr := itor.(string)
+		config, err := defaultMQAdminExt.ExamineSubscriptionGroupConfig(brokerAddr, updateSubGroup.ConsumerGroupId)
+		if err != nil {
+			return err
+		}
+		if config == nil {
+			config = subscription.NewSubscriptionGroupConfig()
+		}
+		updateSubGroup.ApplyTo(config)
+		if err := defaultMQAdminExt.CreateAndUpdateSubscriptionGroupConfig(brokerAddr, config); err != nil {
+			return fmt.Errorf("update subGroup failed. brokerAddr=%s, err: %s", brokerAddr, err.Error())
+		}
+	}
+	return nil
+}
+
+// UpdateQuota 在集群内所有master上创建或更新收发配额，slave通过主从同步获得配额
+// Author: agent
+// Since: 2026/10/19
+func (service *BrokerService) UpdateQuota(updateQuota *models.UpdateQuota) error {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	masterSet, err := defaultMQAdminExt.FetchMasterAddrByClusterName(updateQuota.ClusterName)
+	if err != nil {
+		return err
+	}
+	if masterSet == nil || masterSet.Cardinality() == 0 {
+		return fmt.Errorf("masterSet is empty, update quota failed. clusterName=%s", updateQuota.ClusterName)
+	}
+
+	config := updateQuota.ToQuotaConfig()
+	for itor := range masterSet.Iterator().C {
+		brokerAddr := itor.(string)
+		if err := defaultMQAdminExt.CreateAndUpdateQuotaConfig(brokerAddr, config); err != nil {
+			return fmt.Errorf("update quota failed. brokerAddr=%s, err: %s", brokerAddr, err.Error())
+		}
+	}
+	return nil
+}
+
+// DeleteQuota 删除集群内所有master上的收发配额
+// Author: agent
+// Since: 2026/10/19
+func (service *BrokerService) DeleteQuota(clusterName, resourceType, resourceName string) error {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	masterSet, err := defaultMQAdminExt.FetchMasterAddrByClusterName(clusterName)
+	if err != nil {
+		return err
+	}
+	if masterSet == nil || masterSet.Cardinality() == 0 {
+		return fmt.Errorf("masterSet is empty, delete quota failed. clusterName=%s", clusterName)
+	}
+
+	for itor := range masterSet.Iterator().C {
+		brokerAddr := itor.(string)
+		if err := defaultMQAdminExt.DeleteQuotaConfig(brokerAddr, resourceType, resourceName); err != nil {
+			return fmt.Errorf("delete quota failed. brokerAddr=%s, err: %s", brokerAddr, err.Error())
+		}
+	}
+	return nil
+}
+
+// DrainBroker 优雅下线指定broker
+// Author: agent
+// Since: 2026/10/19
+func (service *BrokerService) DrainBroker(brokerAddr string) error {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	if err := defaultMQAdminExt.DrainBroker(brokerAddr); err != nil {
+		return fmt.Errorf("drain broker failed. brokerAddr=%s, err: %s", brokerAddr, err.Error())
+	}
+	return nil
+}
 
+// QuotaList 查询集群内所有master上的收发配额
+// Author: agent
+// Since: 2026/10/19
+func (service *BrokerService) QuotaList(clusterName string) ([]*models.QuotaVo, error) {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	masterSet, err := defaultMQAdminExt.FetchMasterAddrByClusterName(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]*models.QuotaVo, 0)
+	if masterSet == nil {
+		return data, nil
+	}
+	for itor := range masterSet.Iterator().C {
+		brokerAddr := itor.(string)
+		quotaConfigTable, err := defaultMQAdminExt.ExamineQuotaConfig(brokerAddr)
+		if err != nil {
+			return nil, fmt.Errorf("query quota failed. brokerAddr=%s, err: %s", brokerAddr, err.Error())
+		}
+		quotaConfigTable.Foreach(func(k string, config *quota.QuotaConfig) {
+			data = append(data, models.ToQuotaVo(brokerAddr, config))
+		})
+	}
+	return data, nil
+}
+
+// UpdateBrokerConfig 动态修改broker配置，返回各broker上实际发生变化的配置项
+// Author: agent
+// Since: 2026/10/19
+func (service *BrokerService) UpdateBrokerConfig(updateBrokerConfig *models.UpdateBrokerConfig) ([]*models.BrokerConfigChangeVo, error) {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	brokerAddrs := make([]string, 0)
+	if updateBrokerConfig.BrokerAddr != "" {
+		brokerAddrs = append(brokerAddrs, updateBrokerConfig.BrokerAddr)
+	} else {
+		masterSet, err := defaultMQAdminExt.FetchMasterAddrByClusterName(updateBrokerConfig.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+		if masterSet == nil || masterSet.Cardinality() == 0 {
+			return nil, fmt.Errorf("masterSet is empty, update broker config failed. clusterName=%s", updateBrokerConfig.ClusterName)
+		}
+		for itor := range masterSet.Iterator().C {
+			brokerAddrs = append(brokerAddrs, itor.(string))
+		}
+	}
+
+	data := make([]*models.BrokerConfigChangeVo, 0)
+	for _, brokerAddr := range brokerAddrs {
+		result, err := defaultMQAdminExt.UpdateBrokerConfig(brokerAddr, updateBrokerConfig.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("update broker config failed. brokerAddr=%s, err: %s", brokerAddr, err.Error())
+		}
+		for _, change := range result.Changes {
+			data = append(data, models.ToBrokerConfigChangeVo(brokerAddr, change))
+		}
+	}
+	return data, nil
 }
 
 // DeleteSubGroup 删除consumer消费组参数
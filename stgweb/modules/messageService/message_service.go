@@ -6,6 +6,8 @@ import (
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/message/track"
 	code "git.oschina.net/cloudzone/smartgo/stgcommon/protocol"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/protocol/body"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/trace"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/utils"
 	"git.oschina.net/cloudzone/smartgo/stgweb/models"
 	"git.oschina.net/cloudzone/smartgo/stgweb/modules"
@@ -133,6 +135,18 @@ func (service *MessageService) QueryMsg(msgId string) (*models.BlotMessage, erro
 	return blotMessage, nil
 }
 
+// MessageTrace 根据msgId查询消息轨迹：生产者发送、broker存储，以及每个消费组的拉取、消费、确认过程
+// Author: agent
+// Since: 2026/10/19
+func (service *MessageService) MessageTrace(traceTopic, msgId string) (*trace.MessageTrace, error) {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	return defaultMQAdminExt.QueryMessageTrace(traceTopic, msgId)
+}
+
 // QueryMsg 查询消息结果
 // Author: tianyuliang
 // Since: 2017/11/9
@@ -230,3 +244,51 @@ func (service *MessageService) getMsgBodyPath(msgId string) string {
 	msgBodyPath := stgcommon.MSG_BODY_DIR + msgId
 	return msgBodyPath
 }
+
+// QueryDLQMessage 分页查询消费组的死信消息
+// Author: agent
+// Since: 2026/10/19
+func (service *MessageService) QueryDLQMessage(consumerGroup string, limit, offset int) ([]*models.DLQMessageVo, int64, error) {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	if limit <= 0 {
+		return nil, 0, fmt.Errorf("limit[%d] invalid", limit)
+	}
+	page, err := defaultMQAdminExt.QueryDLQMessage(consumerGroup, offset/limit+1, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := make([]*models.DLQMessageVo, 0, len(page.Messages))
+	for _, msg := range page.Messages {
+		data = append(data, models.ToDLQMessageVo(msg))
+	}
+	return data, page.Total, nil
+}
+
+// ResendDLQMessage 重发死信消息到消费组的重试队列
+// Author: agent
+// Since: 2026/10/19
+func (service *MessageService) ResendDLQMessage(resendVo *models.DLQResendVo) (*body.DLQResendResult, error) {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	return defaultMQAdminExt.ResendDLQMessage(resendVo.ConsumerGroup, resendVo.MsgIds)
+}
+
+// PurgeDLQMessage 清除消费组的死信消息，brokerName为空则清除所有broker
+// Author: agent
+// Since: 2026/10/19
+func (service *MessageService) PurgeDLQMessage(consumerGroup, brokerName string, offset, timestamp int64) (*body.DLQPurgeResult, error) {
+	defer utils.RecoveredFn()
+	defaultMQAdminExt := service.GetDefaultMQAdminExtImpl()
+	defaultMQAdminExt.Start()
+	defer defaultMQAdminExt.Shutdown()
+
+	return defaultMQAdminExt.PurgeDLQMessage(consumerGroup, brokerName, offset, timestamp)
+}
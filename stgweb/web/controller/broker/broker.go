@@ -2,7 +2,12 @@ package broker
 
 import (
 	"git.oschina.net/cloudzone/cloudcommon-go/web/resp"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgcommon/quota"
+	"git.oschina.net/cloudzone/smartgo/stgweb/models"
+	"git.oschina.net/cloudzone/smartgo/stgweb/modules/brokerService"
 	"github.com/kataras/iris/context"
+	"strings"
 )
 
 // WipeWritePermBroker 优雅关闭Broker写权限
@@ -23,7 +28,28 @@ func SyncTopicToBroker(ctx context.Context) {
 // Author: tianyuliang
 // Since: 2017/11/9
 func UpdateSubGroup(ctx context.Context) {
-	ctx.JSON(resp.NewSuccessResponse(""))
+	updateSubGroup := new(models.UpdateSubGroup)
+	if err := ctx.ReadJSON(updateSubGroup); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	updateSubGroup.ClusterName = strings.TrimSpace(updateSubGroup.ClusterName)
+	updateSubGroup.ConsumerGroupId = strings.TrimSpace(updateSubGroup.ConsumerGroupId)
+	if err := updateSubGroup.Validate(); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, err.Error()))
+		return
+	}
+
+	if err := brokerService.Default().UpdateSubGroup(updateSubGroup); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+
+	responseBody := &models.ResultVo{Result: true}
+	ctx.JSON(resp.NewSuccessResponse(responseBody))
 }
 
 // DeleteSubGroup 删除consumer消费组参数
@@ -32,3 +58,126 @@ func UpdateSubGroup(ctx context.Context) {
 func DeleteSubGroup(ctx context.Context) {
 	ctx.JSON(resp.NewSuccessResponse(""))
 }
+
+// UpdateQuota 创建或更新收发配额
+// Author: agent
+// Since: 2026/10/19
+func UpdateQuota(ctx context.Context) {
+	updateQuota := new(models.UpdateQuota)
+	if err := ctx.ReadJSON(updateQuota); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	updateQuota.ClusterName = strings.TrimSpace(updateQuota.ClusterName)
+	updateQuota.ResourceType = strings.TrimSpace(updateQuota.ResourceType)
+	updateQuota.ResourceName = strings.TrimSpace(updateQuota.ResourceName)
+	if err := updateQuota.Validate(); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, err.Error()))
+		return
+	}
+
+	if err := brokerService.Default().UpdateQuota(updateQuota); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+
+	responseBody := &models.ResultVo{Result: true}
+	ctx.JSON(resp.NewSuccessResponse(responseBody))
+}
+
+// DeleteQuota 删除收发配额
+// Author: agent
+// Since: 2026/10/19
+func DeleteQuota(ctx context.Context) {
+	clusterName := strings.TrimSpace(ctx.URLParam("clusterName"))
+	resourceType := strings.TrimSpace(ctx.URLParam("resourceType"))
+	resourceName := strings.TrimSpace(ctx.URLParam("resourceName"))
+	if clusterName == "" || resourceName == "" || !quota.IsValidResourceType(resourceType) {
+		errMsg := "clusterName、resourceType、resourceName字段值无效"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+
+	if err := brokerService.Default().DeleteQuota(clusterName, resourceType, resourceName); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+
+	responseBody := &models.ResultVo{Result: true}
+	ctx.JSON(resp.NewSuccessResponse(responseBody))
+}
+
+// DrainBroker 优雅下线指定broker，下线完成后broker进程退出
+// Author: agent
+// Since: 2026/10/19
+func DrainBroker(ctx context.Context) {
+	brokerAddr := strings.TrimSpace(ctx.URLParam("brokerAddr"))
+	if brokerAddr == "" {
+		errMsg := "brokerAddr字段值无效"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+
+	if err := brokerService.Default().DrainBroker(brokerAddr); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+
+	responseBody := &models.ResultVo{Result: true}
+	ctx.JSON(resp.NewSuccessResponse(responseBody))
+}
+
+// QuotaList 查询集群的收发配额
+// Author: agent
+// Since: 2026/10/19
+func QuotaList(ctx context.Context) {
+	clusterName := strings.TrimSpace(ctx.URLParam("clusterName"))
+	if clusterName == "" {
+		errMsg := "clusterName字段值无效"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+
+	data, err := brokerService.Default().QuotaList(clusterName)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	ctx.JSON(resp.NewSuccessResponse(data))
+}
+
+// UpdateBrokerConfig 动态修改broker配置
+// Author: agent
+// Since: 2026/10/19
+func UpdateBrokerConfig(ctx context.Context) {
+	updateBrokerConfig := new(models.UpdateBrokerConfig)
+	if err := ctx.ReadJSON(updateBrokerConfig); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	updateBrokerConfig.ClusterName = strings.TrimSpace(updateBrokerConfig.ClusterName)
+	updateBrokerConfig.BrokerAddr = strings.TrimSpace(updateBrokerConfig.BrokerAddr)
+	if err := updateBrokerConfig.Validate(); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, err.Error()))
+		return
+	}
+
+	data, err := brokerService.Default().UpdateBrokerConfig(updateBrokerConfig)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	ctx.JSON(resp.NewSuccessResponse(data))
+}
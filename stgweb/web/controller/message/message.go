@@ -1,10 +1,13 @@
 package message
 
 import (
+	"git.oschina.net/cloudzone/cloudcommon-go/web/req"
 	"git.oschina.net/cloudzone/cloudcommon-go/web/resp"
 	"git.oschina.net/cloudzone/smartgo/stgcommon/logger"
+	"git.oschina.net/cloudzone/smartgo/stgweb/models"
 	"git.oschina.net/cloudzone/smartgo/stgweb/modules/messageService"
 	"github.com/kataras/iris/context"
+	"strconv"
 	"strings"
 )
 
@@ -56,6 +59,29 @@ func MessageTrack(ctx context.Context) {
 	ctx.JSON(resp.NewSuccessResponse(data))
 }
 
+// MessageTrace 查询消息轨迹(需broker、客户端开启消息轨迹)
+// Author: agent
+// Since: 2026/10/19
+func MessageTrace(ctx context.Context) {
+	msgId := strings.TrimSpace(ctx.URLParam("msgId"))
+	if msgId == "" || len(msgId) != message_id_length {
+		errMsg := "msgId字段值无效"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+	traceTopic := strings.TrimSpace(ctx.URLParam("traceTopic"))
+
+	data, err := messageService.Default().MessageTrace(traceTopic, msgId)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+
+	ctx.JSON(resp.NewSuccessResponse(data))
+}
+
 // MessageQuery 查询消息消费结果
 // Author: tianyuliang
 // Since: 2017/11/9
@@ -77,3 +103,106 @@ func MessageQuery(ctx context.Context) {
 
 	ctx.JSON(resp.NewSuccessResponse(data))
 }
+
+// DLQMessageList 分页查询消费组的死信消息
+// Author: agent
+// Since: 2026/10/19
+func DLQMessageList(ctx context.Context) {
+	consumerGroup := strings.TrimSpace(ctx.URLParam("consumerGroup"))
+	if consumerGroup == "" {
+		errMsg := "consumerGroup字段值无效"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+
+	pageRequest, err := req.ToPageRequest(ctx)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, err.Error()))
+		return
+	}
+
+	data, total, err := messageService.Default().QueryDLQMessage(consumerGroup, pageRequest.Limit, pageRequest.Offset)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	ctx.JSON(resp.NewSuccessPageResponse(total, data))
+}
+
+// DLQMessageResend 重发死信消息，msgIds为空则重发消费组全部死信
+// Author: agent
+// Since: 2026/10/19
+func DLQMessageResend(ctx context.Context) {
+	resendVo := new(models.DLQResendVo)
+	if err := ctx.ReadJSON(resendVo); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	if err := resendVo.Validate(); err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, err.Error()))
+		return
+	}
+
+	data, err := messageService.Default().ResendDLQMessage(resendVo)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	ctx.JSON(resp.NewSuccessResponse(data))
+}
+
+// DLQMessagePurge 清除消费组的死信消息，offset与timestamp至少指定一个
+// Author: agent
+// Since: 2026/10/19
+func DLQMessagePurge(ctx context.Context) {
+	consumerGroup := strings.TrimSpace(ctx.URLParam("consumerGroup"))
+	if consumerGroup == "" {
+		errMsg := "consumerGroup字段值无效"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+	brokerName := strings.TrimSpace(ctx.URLParam("brokerName"))
+
+	offset, timestamp := int64(-1), int64(0)
+	if value := strings.TrimSpace(ctx.URLParam("offset")); value != "" {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v < 0 {
+			errMsg := "offset字段值无效"
+			logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+			ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+			return
+		}
+		offset = v
+	}
+	if value := strings.TrimSpace(ctx.URLParam("timestamp")); value != "" {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v <= 0 {
+			errMsg := "timestamp字段值无效"
+			logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+			ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+			return
+		}
+		timestamp = v
+	}
+	if offset < 0 && timestamp <= 0 {
+		errMsg := "offset、timestamp字段至少指定一个"
+		logger.Errorf("%s %s %s", errMsg, ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ParamNotValid, errMsg))
+		return
+	}
+
+	data, err := messageService.Default().PurgeDLQMessage(consumerGroup, brokerName, offset, timestamp)
+	if err != nil {
+		logger.Errorf("%s %s %s", err.Error(), ctx.Method(), ctx.Path())
+		ctx.JSON(resp.NewFailedResponse(resp.ResponseCodes.ServerError, err.Error()))
+		return
+	}
+	ctx.JSON(resp.NewSuccessResponse(data))
+}
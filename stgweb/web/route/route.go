@@ -52,11 +52,15 @@ func Route(ctx *web.Context) error {
 		api.Get("/connection/detail", connection.ConnectionDetail)
 	}
 
-	// 消息查询、消费轨迹
+	// 消息查询、消费轨迹、死信消息
 	{
 		api.Get("/msg/body", message.MessageBody)
 		api.Get("/msg/track", message.MessageTrack)
+		api.Get("/msg/trace", message.MessageTrace)
 		api.Get("/msg/query", message.MessageQuery)
+		api.Get("/msg/dlq/list", message.DLQMessageList)
+		api.Post("/msg/dlq/resend", message.DLQMessageResend)
+		api.Delete("/msg/dlq/purge", message.DLQMessagePurge)
 	}
 
 	// 运维
@@ -65,6 +69,11 @@ func Route(ctx *web.Context) error {
 		api.Put("/consumer/subGroup", broker.UpdateSubGroup)
 		api.Post("/broker/syncTopic", broker.SyncTopicToBroker)
 		api.Post("/broker/wipePerm", broker.WipeWritePermBroker)
+		api.Get("/broker/quota/list", broker.QuotaList)
+		api.Put("/broker/quota", broker.UpdateQuota)
+		api.Delete("/broker/quota", broker.DeleteQuota)
+		api.Put("/broker/config", broker.UpdateBrokerConfig)
+		api.Post("/broker/drain", broker.DrainBroker)
 	}
 
 	return nil